@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+var accountsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export accounts to move them to another machine",
+	Long: `Write the account pool as JSON, to stdout or --file, for 'accounts import' on
+another machine.
+
+Only configuration is exported: rate limit, invalid and usage state stay behind.
+Refresh tokens, API keys and proxy passwords are left out unless --include-secrets
+is given; secret references (${env:NAME}, file:PATH) are always kept as written.
+A redacted export can update the settings of accounts the other machine already has,
+but can't add new ones.
+
+Examples:
+  multi-claude-proxy accounts export > accounts-redacted.json
+  multi-claude-proxy accounts export --include-secrets --file pool.json
+  multi-claude-proxy accounts export --provider zai --include-secrets | ssh host multi-claude-proxy accounts import -`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runAccountsExport,
+}
+
+var accountsImportCmd = &cobra.Command{
+	Use:   "import <file|->",
+	Short: "Import accounts written by 'accounts export'",
+	Long: `Merge accounts from an 'accounts export' file (or stdin with -) into the pool.
+
+The file is validated before anything is changed. Accounts that aren't configured
+yet are added; accounts that are keep their settings unless --overwrite is given,
+which replaces them with the imported ones. Credentials of existing accounts are
+kept when the import has none, and their rate limit state is kept either way.
+A running server picks the changes up from the accounts file.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runAccountsImport,
+}
+
+var (
+	exportIncludeSecrets bool
+	exportFile           string
+	exportProvider       string
+	importOverwrite      bool
+	importDryRun         bool
+)
+
+func init() {
+	accountsCmd.AddCommand(accountsExportCmd)
+	accountsCmd.AddCommand(accountsImportCmd)
+
+	accountsExportCmd.Flags().BoolVar(&exportIncludeSecrets, "include-secrets", false, "Include refresh tokens, API keys and proxy passwords")
+	accountsExportCmd.Flags().StringVar(&exportFile, "file", "", "Write to this file instead of stdout")
+	accountsExportCmd.Flags().StringVar(&exportProvider, "provider", "", "Only export accounts of this provider")
+	accountsImportCmd.Flags().BoolVar(&importOverwrite, "overwrite", false, "Replace the settings of accounts that already exist")
+	accountsImportCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Show what would be imported without writing anything")
+	addOutputFlag(accountsImportCmd)
+
+	_ = accountsExportCmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions(
+		config.BuiltinProviders, cobra.ShellCompDirectiveNoFileComp))
+}
+
+func runAccountsExport(cmd *cobra.Command, args []string) error {
+	if exportFile == "" {
+		// Keep stdout for the export itself.
+		utils.SetOutput(os.Stderr)
+	}
+	manager := account.NewManager("")
+	if err := manager.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize account manager: %w", err)
+	}
+
+	export := manager.Export(exportProvider, exportIncludeSecrets)
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if exportFile == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	// An export with secrets holds credentials for every account in it.
+	if err := os.WriteFile(exportFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	utils.Success("Exported %d account(s) to %s", len(export.Accounts), exportFile)
+	if export.Redacted {
+		fmt.Println("Credentials were left out; use --include-secrets to export them.")
+	}
+	return nil
+}
+
+func runAccountsImport(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+	file, err := account.ParseExport(data)
+	if err != nil {
+		return err
+	}
+
+	manager := account.NewManager("")
+	if err := manager.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize account manager: %w", err)
+	}
+	res, err := manager.ImportExport(file, importOverwrite, importDryRun)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return printJSON(res)
+	}
+	prefix := ""
+	if importDryRun {
+		prefix = "Would be "
+	}
+	for _, w := range res.Warnings {
+		utils.Warn("%s", w)
+	}
+	for _, group := range []struct {
+		label  string
+		emails []string
+	}{{"added", res.Added}, {"updated", res.Updated}, {"skipped", res.Skipped}} {
+		if len(group.emails) > 0 {
+			label := prefix + group.label
+			fmt.Printf("%s%s (%d): %s\n", strings.ToUpper(label[:1]), label[1:], len(group.emails), strings.Join(group.emails, ", "))
+		}
+	}
+	if len(res.Skipped) > 0 && !importOverwrite {
+		fmt.Println("Skipped accounts already exist or have no credentials in the export; use --overwrite to update existing ones.")
+	}
+	if len(res.Added)+len(res.Updated) == 0 {
+		fmt.Println("Nothing to import.")
+	}
+	return nil
+}
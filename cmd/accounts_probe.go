@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/anthropic"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/antigravity"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/copilot"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/vertex"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/zai"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// probeTimeout bounds the whole probe of a new account.
+const probeTimeout = 2 * time.Minute
+
+var (
+	noProbeArg   bool
+	testModelArg string
+)
+
+func init() {
+	accountsAddCmd.Flags().BoolVar(&noProbeArg, "no-probe", false, "Skip probing the new account's models, quotas and a test request")
+	accountsAddCmd.Flags().StringVar(&testModelArg, "test-model", "", "Model for the 1-token test request (default: first non-thinking model with quota)")
+}
+
+// accountProbe is what probing a new account found out.
+type accountProbe struct {
+	Models   []string
+	Quotas   map[string]float64 // remaining fraction by model, where the provider reports it
+	Features []string
+
+	TestModel    string
+	TestErr      error
+	TestDuration time.Duration
+}
+
+// newProbeProvider builds a provider the way serve does, for probing an account.
+func newProbeProvider(name string, manager *account.Manager) provider.Provider {
+	switch name {
+	case "zai":
+		return zai.NewProvider(manager)
+	case "anthropic":
+		return anthropic.NewProvider(manager)
+	case "vertex":
+		return vertex.NewProvider(manager)
+	case "copilot":
+		return copilot.NewProvider(manager)
+	}
+	return antigravity.NewProvider(manager, false)
+}
+
+// probeNewAccount probes the account just added: it fetches the models and quotas the
+// account gets, sends a 1-token test request with it, prints what it unlocks and saves
+// the quotas as the account's initial quota baselines. Failures are reported but don't
+// undo the add.
+func probeNewAccount(providerName, email string) {
+	if noProbeArg {
+		return
+	}
+	fmt.Println()
+	utils.Info("Probing account %s...", email)
+
+	manager := account.NewManager("")
+	if err := manager.Initialize(); err != nil {
+		utils.Warn("Probe skipped: failed to initialize account manager: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(account.WithAccount(context.Background(), email), probeTimeout)
+	defer cancel()
+
+	prov := newProbeProvider(providerName, manager)
+	if err := prov.Initialize(ctx); err != nil {
+		utils.Warn("Probe skipped: failed to initialize %s provider: %v", providerName, err)
+		return
+	}
+	defer prov.Shutdown(context.Background())
+
+	probe, err := runProbe(ctx, prov, email)
+	if err != nil {
+		utils.Warn("Probe failed: %v", err)
+		return
+	}
+	printProbe(probe)
+
+	if len(probe.Quotas) > 0 {
+		if err := manager.SetQuotaBaselines(email, probe.Quotas); err != nil {
+			utils.Warn("Failed to save quota baselines: %v", err)
+		} else {
+			utils.Info("Saved quota baselines for %d model(s)", len(probe.Quotas))
+		}
+	}
+}
+
+// runProbe collects the account's models and quotas from prov and sends the test request,
+// pinned to the account by ctx.
+func runProbe(ctx context.Context, prov provider.Provider, email string) (*accountProbe, error) {
+	probe := &accountProbe{Quotas: make(map[string]float64)}
+
+	status, err := prov.GetStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch quotas: %w", err)
+	}
+	for _, acc := range status.Accounts {
+		if acc.Email != email {
+			continue
+		}
+		if acc.Error != "" {
+			utils.Warn("Provider reports %s for this account: %s", acc.Status, acc.Error)
+		}
+		for modelID, quota := range acc.Limits {
+			probe.Models = append(probe.Models, modelID)
+			if quota.RemainingFraction >= 0 {
+				probe.Quotas[modelID] = quota.RemainingFraction
+			}
+		}
+	}
+
+	// Providers without per-account quotas: the account unlocks the provider's models.
+	if len(probe.Models) == 0 {
+		models, err := prov.ListModels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list models: %w", err)
+		}
+		for _, m := range models.Data {
+			probe.Models = append(probe.Models, m.ID)
+		}
+	}
+	sort.Strings(probe.Models)
+	probe.Features = modelFeatures(probe.Models)
+
+	probe.TestModel = testModelArg
+	if probe.TestModel == "" {
+		probe.TestModel = pickTestModel(probe.Models, probe.Quotas)
+	}
+	if probe.TestModel == "" {
+		probe.TestErr = fmt.Errorf("no model to test")
+		return probe, nil
+	}
+
+	start := time.Now()
+	_, probe.TestErr = prov.SendMessage(ctx, &types.AnthropicRequest{
+		Model:     probe.TestModel,
+		MaxTokens: 1,
+		Messages:  []types.Message{{Role: "user", Content: json.RawMessage(`"Hi"`)}},
+	})
+	probe.TestDuration = time.Since(start)
+	return probe, nil
+}
+
+// pickTestModel returns the first model with quota left, preferring models that neither
+// think (a 1-token budget is too small) nor generate images.
+func pickTestModel(models []string, quotas map[string]float64) string {
+	var fallback string
+	for _, modelID := range models {
+		if remaining, ok := quotas[modelID]; ok && remaining <= 0 {
+			continue
+		}
+		if strings.Contains(strings.ToLower(modelID), "image") {
+			continue
+		}
+		if !config.IsThinkingModel(modelID) {
+			return modelID
+		}
+		if fallback == "" {
+			fallback = modelID
+		}
+	}
+	return fallback
+}
+
+// modelFeatures returns the features the models unlock, for the probe summary.
+func modelFeatures(models []string) []string {
+	var claude, gemini, thinking, images bool
+	for _, modelID := range models {
+		switch config.GetModelFamily(modelID) {
+		case config.ModelFamilyClaude:
+			claude = true
+		case config.ModelFamilyGemini:
+			gemini = true
+		}
+		if config.IsThinkingModel(modelID) {
+			thinking = true
+		}
+		if strings.Contains(strings.ToLower(modelID), "image") {
+			images = true
+		}
+	}
+
+	var features []string
+	if claude {
+		features = append(features, "Claude models")
+	}
+	if gemini {
+		features = append(features, "Gemini models")
+	}
+	if thinking {
+		features = append(features, "extended thinking")
+	}
+	if images {
+		features = append(features, "image generation")
+	}
+	return features
+}
+
+func printProbe(p *accountProbe) {
+	fmt.Println()
+	fmt.Printf("  Models (%d): %s\n", len(p.Models), orNone(strings.Join(p.Models, ", ")))
+	if len(p.Quotas) > 0 {
+		quotas := make([]string, 0, len(p.Quotas))
+		for _, modelID := range p.Models {
+			if remaining, ok := p.Quotas[modelID]; ok {
+				quotas = append(quotas, fmt.Sprintf("%s %.0f%%", modelID, remaining*100))
+			}
+		}
+		fmt.Printf("  Quotas: %s\n", strings.Join(quotas, ", "))
+	}
+	fmt.Printf("  Features: %s\n", orNone(strings.Join(p.Features, ", ")))
+
+	switch {
+	case p.TestModel == "":
+		fmt.Printf("  Test request: \033[33mSKIPPED\033[0m (%v)\n", p.TestErr)
+	case p.TestErr != nil:
+		fmt.Printf("  Test request (%s): \033[31mFAILED\033[0m\n", p.TestModel)
+		fmt.Printf("     Error: %v\n", p.TestErr)
+	default:
+		fmt.Printf("  Test request (%s): \033[32mOK\033[0m in %s\n", p.TestModel, p.TestDuration.Round(time.Millisecond))
+	}
+	fmt.Println()
+}
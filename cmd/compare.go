@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/compare"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare <model-a> <model-b>",
+	Short: "Send the same request to two models and diff the responses",
+	Long: `Send the same Messages API request to two models through a running proxy and print
+the differences in content blocks, tool calls, stop reason and token usage.
+
+Useful to check that a fallback model is an acceptable substitute for the primary one.
+Models use the same IDs as /v1/messages ("<provider>/<model>" or an alias). Both
+requests run at the same time and without streaming; the request's own model field is
+ignored.
+
+The proxy must be running; PROXY_API_KEY is used to authenticate.
+
+Examples:
+  multi-claude-proxy compare antigravity/gemini-3-flash zai/glm-4.7 --prompt "Say hi"
+  multi-claude-proxy compare antigravity/claude-sonnet-4-5 anthropic/claude-sonnet-4-5 --request req.json
+  cat req.json | multi-claude-proxy compare a/model b/model --request - --output json`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCompare,
+}
+
+var (
+	compareURL       string
+	compareRequest   string
+	comparePrompt    string
+	compareMaxTokens int
+	compareTimeout   time.Duration
+)
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+
+	compareCmd.Flags().StringVar(&compareURL, "url", "", "Proxy URL (default http://localhost:<PORT>)")
+	compareCmd.Flags().StringVar(&compareRequest, "request", "", "File with a /v1/messages request body, or - for stdin")
+	compareCmd.Flags().StringVar(&comparePrompt, "prompt", "", "Send a single user message instead of --request")
+	compareCmd.Flags().IntVar(&compareMaxTokens, "max-tokens", 1024, "max_tokens for --prompt")
+	compareCmd.Flags().DurationVar(&compareTimeout, "timeout", 5*time.Minute, "Timeout for each request")
+	compareCmd.MarkFlagsMutuallyExclusive("request", "prompt")
+	addOutputFlag(compareCmd)
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	body, err := compareRequestBody()
+	if err != nil {
+		return err
+	}
+	baseURL := compareURL
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://localhost:%d", config.GetPort())
+	}
+
+	results := make([]compare.Result, len(args))
+	var wg sync.WaitGroup
+	for i, model := range args {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(cmd.Context(), compareTimeout)
+			defer cancel()
+			start := time.Now()
+			resp, err := sendCompareRequest(ctx, baseURL, body, model)
+			results[i] = compare.Result{Model: model, Response: resp, Err: err, DurationMs: time.Since(start).Milliseconds()}
+		}()
+	}
+	wg.Wait()
+
+	report := compare.Diff(results[0], results[1])
+	if asJSON {
+		return printJSON(report)
+	}
+	printCompareReport(report)
+	return nil
+}
+
+// compareRequestBody returns the request to send as a JSON object, from --request or --prompt.
+func compareRequestBody() (map[string]interface{}, error) {
+	if comparePrompt != "" {
+		return map[string]interface{}{
+			"max_tokens": compareMaxTokens,
+			"messages":   []interface{}{map[string]interface{}{"role": "user", "content": comparePrompt}},
+		}, nil
+	}
+	if compareRequest == "" {
+		return nil, fmt.Errorf("either --request or --prompt is required")
+	}
+
+	var data []byte
+	var err error
+	if compareRequest == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(compareRequest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("invalid request JSON: %w", err)
+	}
+	return body, nil
+}
+
+// sendCompareRequest sends body to the proxy's /v1/messages for model without streaming.
+func sendCompareRequest(ctx context.Context, baseURL string, body map[string]interface{}, model string) (*types.AnthropicResponse, error) {
+	req := make(map[string]interface{}, len(body)+2)
+	for k, v := range body {
+		req[k] = v
+	}
+	req["model"] = model
+	req["stream"] = false
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/v1/messages", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-API-Key", config.GetProxyAPIKey())
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr types.AnthropicError
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("%d %s: %s", resp.StatusCode, apiErr.Error.Type, apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("%d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var out types.AnthropicResponse
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &out, nil
+}
+
+func printCompareReport(r compare.Report) {
+	for _, side := range []struct {
+		label string
+		s     compare.Summary
+	}{{"A", r.A}, {"B", r.B}} {
+		s := side.s
+		fmt.Printf("%s: %s (%s)\n", side.label, s.Model, time.Duration(s.DurationMs)*time.Millisecond)
+		if s.Error != "" {
+			fmt.Printf("   Error: %s\n\n", s.Error)
+			continue
+		}
+		fmt.Printf("   Stop reason: %s\n", s.StopReason)
+		fmt.Printf("   Blocks: %s\n", strings.Join(s.Blocks, ", "))
+		for _, call := range s.ToolCalls {
+			input, _ := json.Marshal(call.Input)
+			fmt.Printf("   Tool call: %s %s\n", call.Name, input)
+		}
+		fmt.Printf("   Usage: %d input, %d output tokens\n\n", s.Usage.InputTokens, s.Usage.OutputTokens)
+	}
+
+	if len(r.Differences) == 0 {
+		fmt.Println("No differences.")
+		return
+	}
+	fmt.Printf("Differences (%d):\n", len(r.Differences))
+	for _, d := range r.Differences {
+		fmt.Printf("  %s\n", d.Field)
+		fmt.Printf("    A: %s\n", orNone(d.A))
+		fmt.Printf("    B: %s\n", orNone(d.B))
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate a shell completion script",
+	Long: `Generate a shell completion script for multi-claude-proxy.
+
+Completions cover commands, flags, account emails for 'accounts remove' and
+'accounts restore', and backup names for 'restore --from'.
+
+Bash (requires bash-completion):
+  source <(multi-claude-proxy completion bash)
+  # Load for every session (Linux):
+  multi-claude-proxy completion bash > /etc/bash_completion.d/multi-claude-proxy
+
+Zsh:
+  multi-claude-proxy completion zsh > "${fpath[1]}/_multi-claude-proxy"
+  # If completion is not already enabled, add to ~/.zshrc: autoload -U compinit; compinit
+
+Fish:
+  multi-claude-proxy completion fish > ~/.config/fish/completions/multi-claude-proxy.fish
+
+PowerShell:
+  multi-claude-proxy completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	// Completion scripts must not depend on a valid config file.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(os.Stdout, true)
+		default:
+			return rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+	// Replaced by completionCmd, which documents installation for this binary.
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+}
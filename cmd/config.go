@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration file",
+	// The config file is read by the subcommands themselves so errors can be reported.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the configuration file",
+	Long: `Validate the YAML configuration file without starting the server.
+
+Reports syntax errors, unknown settings and values of the wrong type, and lists
+settings that are overridden by environment variables. Exits non-zero when the
+file has problems.
+
+Examples:
+  multi-claude-proxy config validate
+  multi-claude-proxy config validate --config ./config.yaml
+  multi-claude-proxy config validate --output json`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true, // validation failures are not usage errors
+	RunE:         runConfigValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	addOutputFlag(configValidateCmd)
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	path, _ := configFilePath()
+	out := struct {
+		Path       string   `json:"path"`
+		Valid      bool     `json:"valid"`
+		Settings   int      `json:"settings"`
+		Errors     []string `json:"errors"`
+		Overridden []string `json:"overridden"` // Settings also set in the environment, which wins
+	}{Path: path, Errors: []string{}, Overridden: []string{}}
+
+	values, err := config.LoadConfigFile(path)
+	if err != nil {
+		if !asJSON {
+			return err
+		}
+		out.Errors = append(out.Errors, err.Error())
+		_ = printJSON(out)
+		return fmt.Errorf("%s: invalid config file", path)
+	}
+	out.Settings = len(values)
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if env, set := os.LookupEnv(name); set && env != values[name] {
+			out.Overridden = append(out.Overridden, name)
+		}
+	}
+	for _, err := range config.ValidateSettings(values) {
+		out.Errors = append(out.Errors, err.Error())
+	}
+	out.Valid = len(out.Errors) == 0
+
+	if asJSON {
+		if err := printJSON(out); err != nil {
+			return err
+		}
+	} else {
+		for _, name := range out.Overridden {
+			utils.Warn("%s is set in the environment and overrides the config file", name)
+		}
+		for _, msg := range out.Errors {
+			utils.Error("%s", msg)
+		}
+	}
+	if !out.Valid {
+		return fmt.Errorf("%s: %d problem(s) found", path, len(out.Errors))
+	}
+	if !asJSON {
+		utils.Success("%s is valid (%d settings)", path, len(values))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Import accounts and settings from the Node proxy",
+	Long: `Import accounts and settings from the Node antigravity-claude-proxy.
+
+Reads accounts.json and config.json from the Node proxy's config directory:
+  - Accounts are added to this proxy's accounts file, keeping their refresh tokens,
+    projects and timestamps. Accounts that already exist are left untouched, and rate
+    limit state is not carried over.
+  - Settings with an equivalent here are written to the config file (see --config),
+    unless it already exists, in which case they are printed to add by hand.
+
+"database" accounts, whose token the Node proxy reads from the Antigravity IDE database,
+are imported only if the Node proxy saved a refresh token for them. The Node files are
+never modified.
+
+Examples:
+  multi-claude-proxy migrate --from-node ~/.config/antigravity-proxy
+  multi-claude-proxy migrate --from-node /path/to/node-config --dry-run`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runMigrate,
+}
+
+var (
+	migrateFromNode string
+	migrateDryRun   bool
+)
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.Flags().StringVar(&migrateFromNode, "from-node", "", "Node proxy config directory (default ~/.config/antigravity-proxy)")
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Show what would be imported without writing anything")
+	_ = migrateCmd.MarkFlagDirname("from-node")
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	dir := migrateFromNode
+	if dir == "" {
+		dir = config.NodeConfigDir()
+	}
+
+	accountsData, accountsErr := os.ReadFile(filepath.Join(dir, "accounts.json"))
+	if accountsErr != nil && !errors.Is(accountsErr, os.ErrNotExist) {
+		return accountsErr
+	}
+	configData, configErr := os.ReadFile(filepath.Join(dir, "config.json"))
+	if configErr != nil && !errors.Is(configErr, os.ErrNotExist) {
+		return configErr
+	}
+	if accountsErr != nil && configErr != nil {
+		return fmt.Errorf("no accounts.json or config.json found in %s", dir)
+	}
+
+	if accountsErr == nil {
+		if err := migrateNodeAccounts(accountsData); err != nil {
+			return err
+		}
+	}
+	if configErr == nil {
+		if err := migrateNodeConfig(configData); err != nil {
+			return err
+		}
+	}
+
+	if migrateDryRun {
+		fmt.Println("\nDry run: nothing was written.")
+	} else {
+		utils.Success("Migration from %s complete. Start the server with: multi-claude-proxy serve", dir)
+	}
+	return nil
+}
+
+// migrateNodeAccounts imports the accounts from a Node accounts.json.
+func migrateNodeAccounts(data []byte) error {
+	accounts, settings, warnings, err := account.ConvertNodeAccounts(data)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		utils.Warn("[Migrate] %s", w)
+	}
+	if len(accounts) == 0 {
+		fmt.Println("No accounts to import.")
+		return nil
+	}
+
+	if migrateDryRun {
+		fmt.Printf("Would import %d account(s):\n", len(accounts))
+		for _, acc := range accounts {
+			fmt.Printf("  %s (%s)\n", acc.Email, acc.Source)
+		}
+		return nil
+	}
+
+	manager := account.NewManager("")
+	if err := manager.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize account manager: %w", err)
+	}
+	existing, err := manager.ImportAccounts(accounts, settings)
+	if err != nil {
+		return err
+	}
+	for _, email := range existing {
+		utils.Info("[Migrate] %s already exists, left unchanged", email)
+	}
+	utils.Success("Imported %d account(s) into %s", len(accounts)-len(existing), config.GetAccountConfigPath())
+	return nil
+}
+
+// migrateNodeConfig converts a Node config.json into a config file.
+func migrateNodeConfig(data []byte) error {
+	values, skipped, err := config.ConvertNodeConfig(data)
+	if err != nil {
+		return err
+	}
+	for _, key := range skipped {
+		utils.Warn("[Migrate] config.json: %q has no equivalent setting, skipped", key)
+	}
+	if len(values) == 0 {
+		fmt.Println("No settings to import.")
+		return nil
+	}
+
+	yaml := config.FormatConfigFile(values)
+	path, _ := configFilePath()
+	if _, err := os.Stat(path); err == nil {
+		fmt.Printf("Config file %s already exists; add these settings to it:\n\n%s\n", path, yaml)
+		return nil
+	}
+	if migrateDryRun {
+		fmt.Printf("Would write %s:\n\n%s\n", path, yaml)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// The file may contain PROXY_API_KEY.
+	if err := os.WriteFile(path, yaml, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	utils.Success("Wrote %d setting(s) to %s", len(values), path)
+	return nil
+}
@@ -0,0 +1,471 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// modelsCmd represents the models command
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List the models of every provider with their quotas",
+	Long: `List the models the configured accounts unlock, across all enabled providers, with
+how many accounts can serve each one and the best quota left among them.
+
+Models are shown as "<provider>/<model>", the IDs /v1/messages accepts. Quotas are
+only known for providers that report them per account (Antigravity, Z.AI, Copilot).
+It doesn't need a running server.
+
+Examples:
+  multi-claude-proxy models
+  multi-claude-proxy models --provider antigravity -o json`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runModels,
+}
+
+var modelsBenchCmd = &cobra.Command{
+	Use:   "bench [model...]",
+	Short: "Send the same prompt to several models and compare them",
+	Long: `Send a standard prompt to each model in turn and compare latency, time to the first
+token, token counts and output speed side by side.
+
+Models are "<provider>/<model>" IDs as listed by 'models'. Without arguments, the
+models to compare are picked from a list. With --runs, each model is sent the prompt
+that many times and the averages of the successful runs are shown.
+
+Examples:
+  multi-claude-proxy models bench
+  multi-claude-proxy models bench antigravity/gemini-3-flash zai/glm-4.7 anthropic/claude-haiku-4-5
+  multi-claude-proxy models bench antigravity/claude-sonnet-4-5 zai/glm-4.7 --runs 3 -o json`,
+	SilenceUsage: true,
+	RunE:         runModelsBench,
+}
+
+var (
+	modelsProvider  string
+	benchPrompt     string
+	benchMaxTokens  int
+	benchRuns       int
+	benchNoStream   bool
+	benchTimeout    time.Duration
+	defaultBenchMsg = "Explain in three sentences how a load balancer decides where to send a request."
+)
+
+func init() {
+	rootCmd.AddCommand(modelsCmd)
+	modelsCmd.AddCommand(modelsBenchCmd)
+
+	modelsCmd.Flags().StringVar(&modelsProvider, "provider", "", "Only list this provider's models")
+	addOutputFlag(modelsCmd)
+	_ = modelsCmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions(
+		config.BuiltinProviders, cobra.ShellCompDirectiveNoFileComp))
+
+	modelsBenchCmd.Flags().StringVar(&benchPrompt, "prompt", defaultBenchMsg, "Prompt sent to every model")
+	modelsBenchCmd.Flags().IntVar(&benchMaxTokens, "max-tokens", 256, "max_tokens of each request")
+	modelsBenchCmd.Flags().IntVar(&benchRuns, "runs", 1, "Requests per model")
+	modelsBenchCmd.Flags().BoolVar(&benchNoStream, "no-stream", false, "Send the requests without streaming (no time to first token)")
+	modelsBenchCmd.Flags().DurationVar(&benchTimeout, "timeout", 2*time.Minute, "Timeout for each request")
+	addOutputFlag(modelsBenchCmd)
+}
+
+// modelEntry is a model in 'models --output json'.
+type modelEntry struct {
+	ID          string     `json:"id"` // <provider>/<model>
+	DisplayName string     `json:"displayName,omitempty"`
+	Provider    string     `json:"provider"`
+	Accounts    int        `json:"accounts"`        // Accounts that can serve it
+	WithQuota   *int       `json:"withQuota"`       // Accounts with quota left, when the provider reports quotas
+	BestQuota   *float64   `json:"bestQuota"`       // Highest remaining fraction among the accounts
+	NextReset   *time.Time `json:"nextReset"`       // Soonest reset of an exhausted account, when none has quota left
+	Error       string     `json:"error,omitempty"` // Provider failed to initialize or list models
+	model       string     // Provider's own model ID
+	prov        provider.Provider
+}
+
+// loadProviders builds and initializes the named providers. Providers that fail are
+// reported and left out.
+func loadProviders(ctx context.Context, names []string, manager *account.Manager) (map[string]provider.Provider, []modelEntry) {
+	providers := make(map[string]provider.Provider, len(names))
+	var failed []modelEntry
+	for _, name := range names {
+		prov := newProbeProvider(name, manager)
+		if err := prov.Initialize(ctx); err != nil {
+			failed = append(failed, modelEntry{Provider: name, Error: fmt.Sprintf("failed to initialize provider: %v", err)})
+			continue
+		}
+		providers[name] = prov
+	}
+	return providers, failed
+}
+
+// collectModels lists the models of each provider with their quotas across its accounts.
+func collectModels(ctx context.Context, providers map[string]provider.Provider, manager *account.Manager) []modelEntry {
+	var entries []modelEntry
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prov := providers[name]
+		list, err := prov.ListModels(ctx)
+		if err != nil {
+			entries = append(entries, modelEntry{Provider: name, Error: fmt.Sprintf("failed to list models: %v", err)})
+			continue
+		}
+		status, err := prov.GetStatus(ctx)
+		if err != nil {
+			utils.Warn("Failed to fetch %s quotas: %v", name, err)
+		}
+
+		accounts := manager.GetAccountCountByProvider(name)
+		now := time.Now()
+		for _, m := range list.Data {
+			entry := modelEntry{
+				ID:          name + "/" + m.ID,
+				DisplayName: m.DisplayName,
+				Provider:    name,
+				Accounts:    accounts,
+				model:       m.ID,
+				prov:        prov,
+			}
+			if status != nil {
+				withQuota, reported := 0, 0
+				for _, acc := range status.Accounts {
+					quota, ok := acc.Limits[m.ID]
+					if !ok || quota.RemainingFraction < 0 {
+						continue
+					}
+					reported++
+					if quota.RemainingFraction > 0 {
+						withQuota++
+					} else if quota.ResetTime != nil && quota.ResetTime.After(now) && (entry.NextReset == nil || quota.ResetTime.Before(*entry.NextReset)) {
+						entry.NextReset = quota.ResetTime
+					}
+					if entry.BestQuota == nil || quota.RemainingFraction > *entry.BestQuota {
+						best := quota.RemainingFraction
+						entry.BestQuota = &best
+					}
+				}
+				if reported > 0 {
+					entry.Accounts = reported
+					entry.WithQuota = &withQuota
+				}
+				if withQuota > 0 {
+					entry.NextReset = nil
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// modelsManager loads the accounts and the providers to list models of.
+func modelsManager(providerName string) (*account.Manager, []string, error) {
+	if providerName != "" && !slices.Contains(config.BuiltinProviders, providerName) {
+		return nil, nil, fmt.Errorf("unknown provider %q", providerName)
+	}
+	manager := account.NewManager("")
+	if err := manager.Initialize(); err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize account manager: %w", err)
+	}
+	names := providersWithAccounts(manager)
+	if providerName != "" {
+		names = []string{providerName}
+	}
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("no enabled provider has accounts; add one with 'accounts add'")
+	}
+	return manager, names, nil
+}
+
+func runModels(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	manager, names, err := modelsManager(modelsProvider)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	providers, failed := loadProviders(ctx, names, manager)
+	defer shutdownProviders(providers)
+	entries := append(failed, collectModels(ctx, providers, manager)...)
+
+	if asJSON {
+		return printJSON(entries)
+	}
+
+	now := time.Now()
+	var rows [][]statusCell
+	for _, e := range entries {
+		if e.Error != "" {
+			utils.Warn("%s: %s", e.Provider, e.Error)
+			continue
+		}
+		accounts := strconv.Itoa(e.Accounts)
+		quota := statusCell{text: "-"}
+		reset := "-"
+		if e.WithQuota != nil {
+			accounts = fmt.Sprintf("%d/%d", *e.WithQuota, e.Accounts)
+		}
+		if e.BestQuota != nil {
+			quota.text = fmt.Sprintf("%d%%", int64(*e.BestQuota*100+0.5))
+			quota.color = "\033[32m" // green
+			if *e.BestQuota <= 0 {
+				quota.color = "\033[31m" // red
+			}
+		}
+		if e.NextReset != nil {
+			reset = utils.FormatDuration(e.NextReset.Sub(now).Truncate(time.Second))
+		}
+		rows = append(rows, []statusCell{{text: e.ID}, {text: e.DisplayName}, {text: accounts}, quota, {text: reset}})
+	}
+	if len(rows) == 0 {
+		fmt.Println("No models available.")
+		return nil
+	}
+	fmt.Printf("Models (%d):\n\n", len(rows))
+	writeStatusTable(os.Stdout, []string{"MODEL", "NAME", "ACCOUNTS", "BEST QUOTA", "RESETS IN"}, rows, true)
+	fmt.Println()
+	fmt.Println("ACCOUNTS counts accounts with quota left out of those that report quotas.")
+	return nil
+}
+
+// benchResult is a model in 'models bench --output json'.
+type benchResult struct {
+	Model        string       `json:"model"`
+	Runs         int          `json:"runs"`
+	Succeeded    int          `json:"succeeded"`
+	LatencyMs    int64        `json:"latencyMs"`    // Average of the successful runs
+	FirstTokenMs int64        `json:"firstTokenMs"` // Average of the successful runs, streaming only
+	InputTokens  int          `json:"inputTokens"`
+	OutputTokens int          `json:"outputTokens"`
+	TokensPerSec float64      `json:"tokensPerSec"` // Output tokens per second after the first token
+	Errors       []string     `json:"errors,omitempty"`
+	Results      []testResult `json:"results"`
+}
+
+func runModelsBench(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	if benchRuns < 1 {
+		return fmt.Errorf("--runs must be at least 1")
+	}
+
+	manager, names, err := modelsManager("")
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]bool)
+	for _, id := range args {
+		name, _, ok := strings.Cut(id, "/")
+		if !ok || !slices.Contains(config.BuiltinProviders, name) {
+			return fmt.Errorf("invalid model %q (expected <provider>/<model>)", id)
+		}
+		wanted[name] = true
+	}
+	if len(args) > 0 {
+		names = slices.DeleteFunc(names, func(name string) bool { return !wanted[name] })
+		for name := range wanted {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	initCtx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	providers, failed := loadProviders(initCtx, names, manager)
+	defer shutdownProviders(providers)
+	for _, f := range failed {
+		utils.Warn("%s: %s", f.Provider, f.Error)
+	}
+
+	var selected []modelEntry
+	if len(args) == 0 {
+		selected, err = selectBenchModels(collectModels(initCtx, providers, manager))
+		cancel()
+		if err != nil || len(selected) == 0 {
+			return err
+		}
+	} else {
+		cancel()
+		for _, id := range args {
+			name, model, _ := strings.Cut(id, "/")
+			prov, ok := providers[name]
+			if !ok {
+				return fmt.Errorf("provider %s is not available", name)
+			}
+			selected = append(selected, modelEntry{ID: id, Provider: name, model: model, prov: prov})
+		}
+	}
+
+	req := sampleRequest{prompt: benchPrompt, maxTokens: benchMaxTokens, stream: !benchNoStream}
+	results := make([]benchResult, 0, len(selected))
+	for _, m := range selected {
+		b := benchResult{Model: m.ID, Runs: benchRuns, Results: []testResult{}}
+		for run := 1; run <= benchRuns; run++ {
+			if !asJSON {
+				utils.Info("Benchmarking %s (run %d/%d)...", m.ID, run, benchRuns)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), benchTimeout)
+			res := req.send(ctx, m.prov, m.model)
+			cancel()
+			b.Results = append(b.Results, res)
+		}
+		summarizeBench(&b)
+		results = append(results, b)
+	}
+
+	if asJSON {
+		return printJSON(results)
+	}
+	printBench(results)
+	return nil
+}
+
+// summarizeBench averages the successful runs of a benchmarked model.
+func summarizeBench(b *benchResult) {
+	var latency, firstToken, streaming int64
+	var speed float64
+	for _, r := range b.Results {
+		if !r.OK {
+			b.Errors = append(b.Errors, r.Error)
+			continue
+		}
+		b.Succeeded++
+		latency += r.LatencyMs
+		b.InputTokens += r.Usage.InputTokens
+		b.OutputTokens += r.Usage.OutputTokens
+		generating := r.LatencyMs
+		if r.FirstTokenMs > 0 {
+			firstToken += r.FirstTokenMs
+			streaming++
+			generating -= r.FirstTokenMs
+		}
+		if generating > 0 {
+			speed += float64(r.Usage.OutputTokens) / (float64(generating) / 1000)
+		}
+	}
+	if b.Succeeded == 0 {
+		return
+	}
+	n := b.Succeeded
+	b.LatencyMs = latency / int64(n)
+	b.InputTokens /= n
+	b.OutputTokens /= n
+	b.TokensPerSec = speed / float64(n)
+	if streaming > 0 {
+		b.FirstTokenMs = firstToken / streaming
+	}
+}
+
+func printBench(results []benchResult) {
+	var rows [][]statusCell
+	for _, b := range results {
+		if b.Succeeded == 0 {
+			rows = append(rows, []statusCell{{text: b.Model}, {text: "FAILED", color: "\033[31m"}, {text: "-"}, {text: "-"}, {text: "-"}, {text: "-"}, {text: "-"}})
+			continue
+		}
+		ok := statusCell{text: fmt.Sprintf("%d/%d", b.Succeeded, b.Runs), color: "\033[32m"}
+		if b.Succeeded < b.Runs {
+			ok.color = "\033[33m"
+		}
+		firstToken := "-"
+		if b.FirstTokenMs > 0 {
+			firstToken = (time.Duration(b.FirstTokenMs) * time.Millisecond).String()
+		}
+		rows = append(rows, []statusCell{
+			{text: b.Model},
+			ok,
+			{text: (time.Duration(b.LatencyMs) * time.Millisecond).String()},
+			{text: firstToken},
+			{text: strconv.Itoa(b.InputTokens)},
+			{text: strconv.Itoa(b.OutputTokens)},
+			{text: fmt.Sprintf("%.1f", b.TokensPerSec)},
+		})
+	}
+	fmt.Println()
+	writeStatusTable(os.Stdout, []string{"MODEL", "OK", "LATENCY", "FIRST TOKEN", "INPUT", "OUTPUT", "TOKENS/S"}, rows, true)
+
+	for _, b := range results {
+		for _, e := range b.Errors {
+			fmt.Printf("\n%s: %s", b.Model, e)
+		}
+	}
+	fmt.Println()
+}
+
+// selectBenchModels shows the models that can be benchmarked and returns those picked.
+func selectBenchModels(entries []modelEntry) ([]modelEntry, error) {
+	entries = slices.DeleteFunc(entries, func(e modelEntry) bool {
+		return e.Error != "" || (e.WithQuota != nil && *e.WithQuota == 0)
+	})
+	if len(entries) == 0 {
+		fmt.Println("No models with quota left to benchmark.")
+		return nil, nil
+	}
+
+	fmt.Println("Select models to benchmark:")
+	fmt.Println()
+	for i, e := range entries {
+		fmt.Printf("  %d. %s", i+1, e.ID)
+		if e.DisplayName != "" && e.DisplayName != e.model {
+			fmt.Printf(" (%s)", e.DisplayName)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+	fmt.Print("Enter model numbers separated by commas (or 'q' to cancel): ")
+
+	reader := bufio.NewReader(os.Stdin)
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	input = strings.TrimSpace(input)
+	if input == "q" || input == "" {
+		fmt.Println("Cancelled.")
+		return nil, nil
+	}
+
+	var selected []modelEntry
+	for _, field := range strings.Split(input, ",") {
+		num, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || num < 1 || num > len(entries) {
+			return nil, fmt.Errorf("invalid selection: %s", strings.TrimSpace(field))
+		}
+		selected = append(selected, entries[num-1])
+	}
+	return selected, nil
+}
+
+// shutdownProviders stops providers built by loadProviders.
+func shutdownProviders(providers map[string]provider.Provider) {
+	for _, prov := range providers {
+		_ = prov.Shutdown(context.Background())
+	}
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// Output formats accepted by --output.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// addOutputFlag adds --output to a command that can print its result as JSON.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", outputText, "Output format: text or json")
+	_ = cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(
+		[]string{outputText, outputJSON}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// jsonOutput reports whether --output json was requested. In JSON mode log lines are
+// sent to stderr so stdout carries only the JSON document.
+func jsonOutput(cmd *cobra.Command) (bool, error) {
+	format, _ := cmd.Flags().GetString("output")
+	switch format {
+	case outputText:
+		return false, nil
+	case outputJSON:
+		utils.SetOutput(os.Stderr)
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid --output %q (expected text or json)", format)
+	}
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/antigravity"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// preflightTimeout bounds the checks of one account.
+const preflightTimeout = 30 * time.Second
+
+// Outcomes of a preflight check.
+const (
+	checkOK      = "ok"
+	checkFailed  = "FAILED"
+	checkSkipped = "-"
+)
+
+// preflightResult is what the startup preflight found out about one account.
+type preflightResult struct {
+	email       string
+	provider    string
+	credentials string // Token refresh or API key check
+	project     string // Antigravity project discovery
+	models      string // Antigravity models fetch, with the number of models
+	err         error
+}
+
+// usable reports whether every check that ran passed.
+func (r preflightResult) usable() bool {
+	return r.err == nil && r.credentials == checkOK
+}
+
+// runPreflight checks every account in parallel: its credentials (the check of `accounts
+// verify`, which for Z.AI and Anthropic is a models request) and, for Antigravity, project
+// discovery and a models fetch. Disabled accounts are skipped. Results are in account order.
+func runPreflight(ctx context.Context, manager *account.Manager) []preflightResult {
+	accounts := manager.GetAllAccounts()
+	results := make([]preflightResult, len(accounts))
+	var wg sync.WaitGroup
+	for i, acc := range accounts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, preflightTimeout)
+			defer cancel()
+			results[i] = preflightAccount(checkCtx, manager, acc)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func preflightAccount(ctx context.Context, manager *account.Manager, acc account.Account) preflightResult {
+	r := preflightResult{email: acc.Email, provider: acc.Provider, credentials: checkSkipped, project: checkSkipped, models: checkSkipped}
+	if r.provider == "" {
+		r.provider = "antigravity"
+	}
+	switch {
+	case !acc.IsEnabled():
+		r.err = fmt.Errorf("disabled")
+		return r
+	case acc.IsInvalid:
+		r.credentials, r.err = checkFailed, fmt.Errorf("%s", acc.InvalidReason)
+		return r
+	}
+
+	if _, err := verifyAccount(ctx, manager, acc); err != nil {
+		r.credentials, r.err = checkFailed, err
+		return r
+	}
+	r.credentials = checkOK
+	if r.provider != "antigravity" {
+		return r
+	}
+
+	token, err := manager.GetTokenForAccount(&acc)
+	if err != nil {
+		r.project, r.err = checkFailed, err
+		return r
+	}
+	if _, err := manager.GetProjectForAccount(&acc, token); err != nil {
+		r.project, r.err = checkFailed, fmt.Errorf("project discovery: %w", err)
+		return r
+	}
+	r.project = checkOK
+
+	models, err := antigravity.NewClient().FetchAvailableModels(acc.RequestContext(ctx), token)
+	if err != nil {
+		r.models, r.err = checkFailed, fmt.Errorf("models: %w", err)
+		return r
+	}
+	r.models = fmt.Sprintf("%s (%d)", checkOK, len(models.Models))
+	return r
+}
+
+// printPreflight prints a table of the preflight results and returns how many accounts are usable.
+func printPreflight(results []preflightResult) int {
+	usable := 0
+	for _, r := range results {
+		if r.usable() {
+			usable++
+		}
+	}
+	utils.Info("[Preflight] %d of %d account(s) usable", usable, len(results))
+	if len(results) == 0 {
+		return 0
+	}
+
+	fmt.Printf("  %-32s %-12s %-12s %-8s %-8s %s\n", "ACCOUNT", "PROVIDER", "CREDENTIALS", "PROJECT", "MODELS", "ERROR")
+	for _, r := range results {
+		errMsg := ""
+		if r.err != nil {
+			errMsg = strings.ReplaceAll(r.err.Error(), "\n", " ")
+		}
+		fmt.Printf("  %-32s %-12s %-12s %-8s %-8s %s\n", r.email, r.provider, r.credentials, r.project, r.models, errMsg)
+	}
+	return usable
+}
+
+// preflight runs the startup preflight unless STARTUP_PREFLIGHT is off. In strict mode it
+// returns an error when no account is usable, so the server doesn't start.
+func preflight(ctx context.Context, manager *account.Manager) error {
+	mode := config.GetStartupPreflight()
+	if mode == config.PreflightOff {
+		return nil
+	}
+	utils.Info("[Preflight] Checking accounts...")
+	results := runPreflight(ctx, manager)
+	usable := printPreflight(results)
+	if usable > 0 {
+		return nil
+	}
+	if mode == config.PreflightStrict {
+		return fmt.Errorf("preflight found no usable account (%d configured); fix or re-authenticate them with 'accounts verify', or unset STARTUP_PREFLIGHT=strict", len(results))
+	}
+	utils.Warn("[Preflight] No usable accounts; requests will fail until one is fixed")
+	return nil
+}
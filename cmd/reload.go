@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/api"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// providerSlot is a provider the server can register, either at startup or when a
+// config reload enables it (<NAME>_ENABLED).
+type providerSlot struct {
+	name   string // registry name
+	label  string // name used in log lines
+	detail string // optional extra for the "registered" log line
+	build  func() provider.Provider
+	// lenient providers are registered even if initialization fails or finds no models.
+	lenient bool
+
+	p provider.Provider // nil until built and initialized
+}
+
+// enable builds and initializes the provider on first use and registers it.
+func (s *providerSlot) enable(ctx context.Context, registry *provider.Registry) error {
+	if s.p == nil {
+		p := s.build()
+		if err := p.Initialize(ctx); err != nil {
+			if !s.lenient {
+				return fmt.Errorf("init: %w", err)
+			}
+			utils.Warn("[Server] %s provider init: %v", s.label, err)
+		}
+		if len(p.Models()) == 0 && !s.lenient {
+			return errors.New("no models")
+		}
+		s.p = p
+	}
+	if err := registry.Register(s.p); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("[Server] %s provider registered with %d models", s.label, len(s.p.Models()))
+	if s.detail != "" {
+		msg += " (" + s.detail + ")"
+	}
+	utils.Info("%s", msg)
+	return nil
+}
+
+// configReloader re-applies the config file to a running server (POST /admin/reload, SIGHUP).
+// Only soft-limit thresholds, model aliases and provider enable/disable change at runtime;
+// other settings need a restart. Requests in flight keep the provider they started with.
+type configReloader struct {
+	mu             sync.Mutex
+	ctx            context.Context
+	cmd            *cobra.Command
+	accountManager *account.Manager
+	registry       *provider.Registry
+	apiServer      *api.Server
+	slots          []*providerSlot
+	aliases        map[string]string
+}
+
+// Reload re-reads the config file and returns a description of each applied change.
+func (r *configReloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := config.ReloadConfigFile(); err != nil {
+		return nil, fmt.Errorf("failed to reload config file: %w", err)
+	}
+
+	// Soft limit flags given on the command line win over the config file.
+	softLimitFromFlags := r.cmd.Flags().Changed("soft-limit") || r.cmd.Flags().Changed("no-soft-limit")
+	threshold := config.GetSoftLimitThreshold()
+	if !softLimitFromFlags && (math.IsNaN(threshold) || threshold < 0.0 || threshold > 1.0) {
+		return nil, fmt.Errorf("SOFT_LIMIT_THRESHOLD must be between 0.0 and 1.0, got %v", threshold)
+	}
+
+	var changes []string
+	if settings := r.accountManager.GetSettings(); !softLimitFromFlags && settings.SoftLimitThreshold != threshold {
+		r.accountManager.SetSoftLimitSettings(settings.SoftLimitEnabled, threshold)
+		changes = append(changes, fmt.Sprintf("soft limit threshold %.0f%% -> %.0f%%", settings.SoftLimitThreshold*100, threshold*100))
+	}
+
+	if aliases := config.GetModelAliases(); !maps.Equal(aliases, r.aliases) {
+		r.apiServer.SetModelAliases(aliases)
+		r.aliases = aliases
+		changes = append(changes, fmt.Sprintf("%d model alias(es) configured", len(aliases)))
+	}
+
+	for _, slot := range r.slots {
+		enabled := config.IsProviderEnabled(slot.name)
+		_, registered := r.registry.GetByName(slot.name)
+		switch {
+		case enabled && !registered:
+			if err := slot.enable(r.ctx, r.registry); err != nil {
+				utils.Warn("[Server] %s provider: %v", slot.label, err)
+				changes = append(changes, fmt.Sprintf("provider %s not enabled: %v", slot.name, err))
+				continue
+			}
+			changes = append(changes, fmt.Sprintf("provider %s enabled", slot.name))
+		case !enabled && registered:
+			r.registry.Unregister(slot.name)
+			utils.Info("[Server] %s provider disabled", slot.label)
+			changes = append(changes, fmt.Sprintf("provider %s disabled", slot.name))
+		}
+	}
+
+	for _, change := range changes {
+		utils.Info("[Server] Config reload: %s", change)
+	}
+	if len(changes) == 0 {
+		utils.Info("[Server] Config reload: no changes")
+	}
+	return changes, nil
+}
+
+// shutdownProviders stops every provider that was built, including disabled ones.
+func (r *configReloader) shutdownProviders(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, slot := range r.slots {
+		if slot.p == nil {
+			continue
+		}
+		if err := slot.p.Shutdown(ctx); err != nil {
+			utils.Warn("[Server] %s provider shutdown: %v", slot.p.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/backup"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore account state from a backup",
+	Long: `Restore accounts.json from a backup taken by the server (see BACKUP_DIR).
+
+The backup is validated before it replaces the current file, and the replaced file is
+kept in the backup directory as pre-restore-<timestamp>.json. A running server picks the
+restored accounts up within ACCOUNTS_SYNC_INTERVAL.
+
+Without --from, lists the available backups.
+
+Examples:
+  multi-claude-proxy restore                                        # List backups
+  multi-claude-proxy restore --from accounts-20260101T030000Z.json  # Restore by name
+  multi-claude-proxy restore --from /path/to/accounts.json          # Restore from a path
+  multi-claude-proxy restore --output json                          # List backups as JSON`,
+	RunE: runRestore,
+}
+
+var restoreFromArg string
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+
+	restoreCmd.Flags().StringVar(&restoreFromArg, "from", "", "Backup file name or path to restore")
+	addOutputFlag(restoreCmd)
+
+	_ = restoreCmd.RegisterFlagCompletionFunc("from", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		backups, err := backup.New(config.GetBackupConfig(), "").List()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		names := make([]string, 0, len(backups))
+		for _, path := range backups {
+			names = append(names, filepath.Base(path))
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	mgr := backup.New(config.GetBackupConfig(), "")
+
+	if restoreFromArg == "" {
+		backups, err := mgr.List()
+		if err != nil {
+			return fmt.Errorf("failed to list backups: %w", err)
+		}
+		if asJSON {
+			names := make([]string, 0, len(backups))
+			for _, path := range backups {
+				names = append(names, filepath.Base(path))
+			}
+			return printJSON(map[string]interface{}{"dir": mgr.Dir(), "backups": names})
+		}
+		if len(backups) == 0 {
+			fmt.Printf("No backups found in %s\n", mgr.Dir())
+			return nil
+		}
+		fmt.Printf("Backups in %s (newest first):\n\n", mgr.Dir())
+		for _, path := range backups {
+			fmt.Printf("  %s\n", filepath.Base(path))
+		}
+		fmt.Println()
+		fmt.Println("Restore one with: multi-claude-proxy restore --from <name>")
+		return nil
+	}
+
+	if err := mgr.Restore(restoreFromArg, time.Now()); err != nil {
+		return err
+	}
+
+	if asJSON {
+		return printJSON(map[string]interface{}{"restored": restoreFromArg})
+	}
+	utils.Success("Restored account state from %s", restoreFromArg)
+	return nil
+}
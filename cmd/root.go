@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
 )
 
 var (
@@ -21,10 +23,27 @@ var rootCmd = &cobra.Command{
 backed by multiple providers (Antigravity, OpenAI, etc.).
 
 It enables using Claude Code CLI with various model backends while maintaining
-full compatibility with the Anthropic Messages API.`,
-	Version: Version,
+full compatibility with the Anthropic Messages API.
+
+Settings are read from environment variables and, optionally, a YAML config file
+(--config, CONFIG_FILE, or ~/.config/multi-claude-proxy/config.yaml). Environment
+variables take precedence over the file.
+
+Profiles (--profile or MCP_PROFILE) keep independent account pools on one machine:
+each profile has its own config file, accounts, backups and audit logs under
+~/.config/multi-claude-proxy/profiles/<name>.`,
+	Version:           Version,
+	PersistentPreRunE: loadConfigFile,
 }
 
+var (
+	// configFileArg is the --config flag value.
+	configFileArg string
+
+	// profileArg is the --profile flag value.
+	profileArg string
+)
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
@@ -36,4 +55,35 @@ func Execute() {
 func init() {
 	// Global flags can be added here
 	rootCmd.PersistentFlags().Bool("debug", false, "Enable debug logging")
+	rootCmd.PersistentFlags().StringVar(&configFileArg, "config", "", "Path to a YAML config file (default: CONFIG_FILE or ~/.config/multi-claude-proxy/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profileArg, "profile", "", "Profile whose config, accounts and usage data to use (default: MCP_PROFILE)")
+}
+
+// configFilePath returns the config file to load and whether it was chosen explicitly.
+// An explicit file must exist; the default location is optional.
+func configFilePath() (string, bool) {
+	if configFileArg != "" {
+		return configFileArg, true
+	}
+	return config.GetConfigFilePath(), os.Getenv("CONFIG_FILE") != ""
+}
+
+// loadConfigFile selects the profile and applies its config file to the environment
+// before any command runs.
+func loadConfigFile(cmd *cobra.Command, args []string) error {
+	if profileArg != "" {
+		// The flag wins over MCP_PROFILE; setting the variable lets every path lookup see it.
+		os.Setenv("MCP_PROFILE", profileArg)
+	}
+	if profile := config.GetProfile(); profile != "" {
+		if err := config.ValidateProfile(profile); err != nil {
+			return err
+		}
+	}
+
+	path, required := configFilePath()
+	if err := config.ApplyConfigFile(path, required); err != nil {
+		return fmt.Errorf("failed to load config file: %w", err)
+	}
+	return nil
 }
@@ -14,11 +14,24 @@ import (
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/account"
 	"github.com/kuzerno1/multi-claude-proxy/internal/api"
+	"github.com/kuzerno1/multi-claude-proxy/internal/audit"
+	"github.com/kuzerno1/multi-claude-proxy/internal/backup"
+	"github.com/kuzerno1/multi-claude-proxy/internal/budget"
+	"github.com/kuzerno1/multi-claude-proxy/internal/cache"
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/notify"
+	"github.com/kuzerno1/multi-claude-proxy/internal/policy"
 	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/anthropic"
 	"github.com/kuzerno1/multi-claude-proxy/internal/provider/antigravity"
 	"github.com/kuzerno1/multi-claude-proxy/internal/provider/copilot"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/openaicompat"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/vertex"
 	"github.com/kuzerno1/multi-claude-proxy/internal/provider/zai"
+	"github.com/kuzerno1/multi-claude-proxy/internal/quota"
+	"github.com/kuzerno1/multi-claude-proxy/internal/session"
+	"github.com/kuzerno1/multi-claude-proxy/internal/telemetry"
+	"github.com/kuzerno1/multi-claude-proxy/internal/tracing"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 )
 
@@ -112,6 +125,9 @@ func runServe(cmd *cobra.Command, args []string) error {
 	}
 
 	utils.Info("Starting multi-claude-proxy server...")
+	if profile := config.GetProfile(); profile != "" {
+		utils.Info("Profile: %s (%s)", profile, config.GetDataDir())
+	}
 	utils.Info("Port: %d", port)
 	utils.Info("Fallback: %v", fallback)
 	utils.Info("Debug: %v", debug)
@@ -123,8 +139,20 @@ func runServe(cmd *cobra.Command, args []string) error {
 
 	ctx := context.Background()
 
-	// Initialize account manager
+	// Scheduled state backups (BACKUP_ENABLED). The first snapshot is taken before the
+	// account manager loads, so a good file is captured before anything rewrites it.
+	backupStop := make(chan struct{})
+	backupConfig := config.GetBackupConfig()
+	backups := backup.New(backupConfig, "")
+	if backupConfig.Enabled {
+		backups.Start(backupConfig.Interval, backupStop)
+		utils.Info("[Server] State backups enabled: %s (every %s, keep %d)", backupConfig.Dir, backupConfig.Interval, backupConfig.Retention)
+	}
+
+	// Initialize account manager. A corrupt accounts.json is replaced by the newest valid
+	// backup (from this or an earlier run) rather than starting without accounts.
 	accountManager := account.NewManager("")
+	accountManager.SetBackupSource(backups.List)
 	if err := accountManager.Initialize(); err != nil {
 		utils.Warn("[Server] Account manager initialization: %v", err)
 	}
@@ -132,59 +160,89 @@ func runServe(cmd *cobra.Command, args []string) error {
 	// Configure soft limit settings
 	accountManager.SetSoftLimitSettings(softLimitEnabled, softLimitThreshold)
 
+	// Configure account selection strategy
+	balancer, err := account.NewLoadBalancer(config.GetLoadBalancer())
+	if err != nil {
+		return err
+	}
+	accountManager.SetLoadBalancer(balancer)
+	utils.Info("Load Balancer: %s", balancer.Name())
+	accountManager.SetHealthConfig(config.GetAccountHealthConfig())
+	accountManager.SetStickyErrorConfig(config.GetStickyErrorConfig())
+
+	// Optional account state webhooks (NOTIFY_WEBHOOKS)
+	notifyConfig := config.GetNotifyConfig()
+	notifier, err := notify.New(notifyConfig)
+	if err != nil {
+		return err
+	}
+	if notifier != nil {
+		accountManager.SetNotifier(notifier)
+		utils.Info("[Server] Account state notifications enabled (%d webhook(s))", len(notifyConfig.Webhooks))
+	}
+
 	accounts := accountManager.GetAllAccounts()
 	if len(accounts) > 0 {
 		utils.Success("[Server] Loaded %d account(s)", len(accounts))
 	}
 
+	// Check every account before serving (STARTUP_PREFLIGHT=report or strict)
+	if err := preflight(ctx, accountManager); err != nil {
+		return err
+	}
+
 	// Initialize provider registry
 	registry := provider.NewRegistry()
 
-	// Initialize Antigravity provider
-	antigravityProvider := antigravity.NewProvider(accountManager, fallback)
-	if err := antigravityProvider.Initialize(ctx); err != nil {
-		utils.Warn("[Server] Antigravity provider init: %v", err)
+	// Providers: Antigravity is always available, the others only when they have accounts
+	// or are configured. Providers disabled with <NAME>_ENABLED=false are built only once a
+	// config reload enables them.
+	slots := []*providerSlot{{
+		name:    "antigravity",
+		label:   "Antigravity",
+		build:   func() provider.Provider { return antigravity.NewProvider(accountManager, fallback) },
+		lenient: true,
+	}}
+	builtins := []struct {
+		name  string
+		label string
+		build func() provider.Provider
+	}{
+		{"zai", "Z.AI", func() provider.Provider { return zai.NewProvider(accountManager) }},
+		{"anthropic", "Anthropic", func() provider.Provider { return anthropic.NewProvider(accountManager) }},
+		{"vertex", "Vertex", func() provider.Provider { return vertex.NewProvider(accountManager) }},
+		{"copilot", "Copilot", func() provider.Provider { return copilot.NewProvider(accountManager) }},
 	}
-	if err := registry.Register(antigravityProvider); err != nil {
-		return fmt.Errorf("failed to register antigravity provider: %w", err)
+	for _, b := range builtins {
+		if accountManager.GetAccountCountByProvider(b.name) > 0 {
+			slots = append(slots, &providerSlot{name: b.name, label: b.label, build: b.build})
+		}
 	}
-	utils.Info("[Server] Antigravity provider registered with %d models", len(antigravityProvider.Models()))
 
-	// Initialize Z.AI provider (only if Z.AI accounts exist)
-	zaiAccountCount := accountManager.GetAccountCountByProvider("zai")
-	if zaiAccountCount > 0 {
-		zaiProvider := zai.NewProvider(accountManager)
-		if err := zaiProvider.Initialize(ctx); err != nil {
-			utils.Warn("[Server] Z.AI provider init: %v", err)
-		} else {
-			if len(zaiProvider.Models()) > 0 {
-				if err := registry.Register(zaiProvider); err != nil {
-					utils.Warn("[Server] Z.AI provider registration: %v", err)
-				} else {
-					utils.Info("[Server] Z.AI provider registered with %d models", len(zaiProvider.Models()))
-				}
-			} else {
-				utils.Warn("[Server] Z.AI provider has no models, skipping registration")
-			}
-		}
+	// OpenAI-compatible upstreams (OPENAI_COMPATIBLE_CONFIG / OPENAI_COMPATIBLE_PROVIDERS)
+	compatConfigs, err := config.GetOpenAICompatibleConfigs()
+	if err != nil {
+		return err
+	}
+	for _, cfg := range compatConfigs {
+		slots = append(slots, &providerSlot{
+			name:   cfg.Name,
+			label:  cfg.Name,
+			detail: cfg.BaseURL,
+			build:  func() provider.Provider { return openaicompat.NewProvider(cfg) },
+		})
 	}
 
-	// Initialize Copilot provider (only if Copilot accounts exist)
-	copilotAccountCount := accountManager.GetAccountCountByProvider("copilot")
-	if copilotAccountCount > 0 {
-		copilotProvider := copilot.NewProvider(accountManager)
-		if err := copilotProvider.Initialize(ctx); err != nil {
-			utils.Warn("[Server] Copilot provider init: %v", err)
-		} else {
-			if len(copilotProvider.Models()) > 0 {
-				if err := registry.Register(copilotProvider); err != nil {
-					utils.Warn("[Server] Copilot provider registration: %v", err)
-				} else {
-					utils.Info("[Server] Copilot provider registered with %d models", len(copilotProvider.Models()))
-				}
-			} else {
-				utils.Warn("[Server] Copilot provider has no models, skipping registration")
+	for _, slot := range slots {
+		if !config.IsProviderEnabled(slot.name) {
+			utils.Info("[Server] %s provider disabled", slot.label)
+			continue
+		}
+		if err := slot.enable(ctx, registry); err != nil {
+			if slot.lenient {
+				return fmt.Errorf("failed to register %s provider: %w", slot.name, err)
 			}
+			utils.Warn("[Server] %s provider: %v, skipping registration", slot.label, err)
 		}
 	}
 
@@ -193,6 +251,215 @@ func runServe(cmd *cobra.Command, args []string) error {
 	// Create API server
 	apiServer := api.NewServer(registry, accountManager)
 
+	// Optional model aliases (MODEL_ALIASES)
+	aliases := config.GetModelAliases()
+	if len(aliases) > 0 {
+		apiServer.SetModelAliases(aliases)
+		utils.Info("[Server] %d model alias(es) configured", len(aliases))
+	}
+
+	// Cross-provider soft limit scheduling (SOFT_LIMIT_SCHEDULER=global, MODEL_EQUIVALENTS)
+	if config.GetSoftLimitScheduler() == config.SoftLimitSchedulerGlobal {
+		equivalents := config.GetModelEquivalents()
+		if len(equivalents) == 0 {
+			utils.Warn("[Server] SOFT_LIMIT_SCHEDULER=global has no effect without MODEL_EQUIVALENTS")
+		} else {
+			apiServer.SetModelEquivalents(equivalents)
+			utils.Info("[Server] Soft-limited models move to equivalents on other providers (%d model(s))", len(equivalents))
+		}
+	}
+
+	// Live config reload (POST /admin/reload, SIGHUP)
+	reloader := &configReloader{
+		ctx:            ctx,
+		cmd:            cmd,
+		accountManager: accountManager,
+		registry:       registry,
+		apiServer:      apiServer,
+		slots:          slots,
+		aliases:        aliases,
+	}
+	apiServer.SetReloadFunc(reloader.Reload)
+
+	// Optional response cache (RESPONSE_CACHE_ENABLED)
+	if cacheConfig := config.GetResponseCacheConfig(); cacheConfig.Enabled {
+		apiServer.SetResponseCache(cache.NewResponseCache(cacheConfig.TTL, cacheConfig.MaxEntries))
+		utils.Info("[Server] Response cache enabled (ttl=%s, max=%d)", cacheConfig.TTL, cacheConfig.MaxEntries)
+	}
+
+	// Optional request coalescing (REQUEST_COALESCING_ENABLED)
+	if config.GetRequestCoalescingEnabled() {
+		apiServer.SetRequestCoalescing(true)
+		utils.Info("[Server] Request coalescing enabled")
+	}
+
+	// Optional session affinity (SESSION_AFFINITY_ENABLED), persisted with SESSION_AFFINITY_PATH
+	var sessionStore *session.Store
+	sessionConfig := config.GetSessionAffinityConfig()
+	sessionStop := make(chan struct{})
+	if sessionConfig.Enabled {
+		sessionStore = session.NewStore(sessionConfig.TTL, sessionConfig.MaxEntries)
+		if sessionConfig.Path != "" {
+			loaded, err := sessionStore.LoadFromFile(sessionConfig.Path)
+			if err != nil {
+				utils.Warn("[Server] Could not restore sessions: %v", err)
+			} else if loaded > 0 {
+				utils.Info("[Server] Restored %d session(s) from %s", loaded, sessionConfig.Path)
+			}
+			sessionStore.StartPersistence(sessionConfig.Path, sessionConfig.SaveInterval, sessionStop)
+		}
+		apiServer.SetSessionStore(sessionStore)
+		utils.Info("[Server] Session affinity enabled (ttl=%s, max=%d)", sessionConfig.TTL, sessionConfig.MaxEntries)
+	}
+
+	// Optional spend estimates (MODEL_PRICING_CONFIG)
+	pricing, err := config.GetModelPricing()
+	if err != nil {
+		return fmt.Errorf("invalid model pricing: %w", err)
+	}
+	if len(pricing) > 0 {
+		apiServer.SetModelPricing(pricing)
+		utils.Info("[Server] Estimating spend with prices for %d model pattern(s)", len(pricing))
+	}
+
+	// Optional usage tracking and budgets for virtual keys (VIRTUAL_KEYS) and accounts
+	// (KEY_BUDGET_*, ACCOUNT_BUDGET_*), persisted with BUDGET_USAGE_PATH
+	var budgetTracker *budget.Tracker
+	budgetConfig := config.GetBudgetConfig()
+	budgetStop := make(chan struct{})
+	if budgetConfig.Enabled() || len(config.GetVirtualKeys()) > 0 || len(pricing) > 0 {
+		budgetTracker = budget.NewTracker()
+		loaded, err := budgetTracker.LoadFromFile(budgetConfig.Path)
+		if err != nil {
+			utils.Warn("[Server] Could not restore budget usage: %v", err)
+		} else if loaded > 0 {
+			utils.Info("[Server] Restored budget usage of %d key(s) and account(s) from %s", loaded, budgetConfig.Path)
+		}
+		budgetTracker.StartPersistence(budgetConfig.Path, time.Minute, budgetStop)
+		apiServer.SetBudgets(budgetTracker, budgetConfig)
+		utils.Info("[Server] Usage tracking enabled (%d virtual key(s), budgets=%t)", len(config.GetVirtualKeys()), budgetConfig.Enabled())
+	}
+
+	// Optional concurrency limits (MAX_CONCURRENT_PER_PROVIDER / _MODEL / _ACCOUNT)
+	if concurrencyConfig := config.GetConcurrencyConfig(); concurrencyConfig.Enabled() {
+		apiServer.SetConcurrencyLimits(concurrencyConfig)
+		utils.Info("[Server] Concurrency limits: provider=%d model=%d account=%d (queue %s)",
+			concurrencyConfig.PerProvider, concurrencyConfig.PerModel, concurrencyConfig.PerAccount, concurrencyConfig.QueueTimeout)
+	}
+
+	// Optional client rate limits (RATE_LIMIT_*)
+	if rateLimitConfig := config.GetClientRateLimitConfig(); rateLimitConfig.Enabled() {
+		apiServer.SetClientRateLimits(rateLimitConfig)
+		utils.Info("[Server] Client rate limits: per key %g rps (burst %d) %d tpm, global %g rps (burst %d) %d tpm",
+			rateLimitConfig.RPS, rateLimitConfig.Burst, rateLimitConfig.TPM,
+			rateLimitConfig.GlobalRPS, rateLimitConfig.GlobalBurst, rateLimitConfig.GlobalTPM)
+	}
+
+	// Optional content policy filters (POLICY_FILTER_CONFIG, POLICY_FILTER_URL)
+	policyConfig, err := config.GetPolicyFilterConfig()
+	if err != nil {
+		return fmt.Errorf("invalid policy filter configuration: %w", err)
+	}
+	policyChain, err := policy.New(policyConfig)
+	if err != nil {
+		return fmt.Errorf("invalid policy filter configuration: %w", err)
+	}
+	if policyChain != nil {
+		apiServer.SetPolicy(policyChain)
+		utils.Info("[Server] Content policy filters enabled: %d rule(s), external filter: %v (fail open: %v)",
+			len(policyConfig.Rules), policyConfig.URL != "", policyConfig.FailOpen)
+	}
+
+	// Optional declarative request rules (REQUEST_POLICY_CONFIG)
+	requestRuleConfig, err := config.GetRequestRules()
+	if err != nil {
+		return fmt.Errorf("invalid request policy configuration: %w", err)
+	}
+	requestRules, err := policy.NewRules(requestRuleConfig)
+	if err != nil {
+		return fmt.Errorf("invalid request policy configuration: %w", err)
+	}
+	if requestRules != nil {
+		apiServer.SetRequestRules(requestRules)
+		utils.Info("[Server] Request policy enabled: %d rule(s)", requestRules.Len())
+	}
+
+	// Quota history and low-quota alerts (QUOTA_ALERT_THRESHOLDS, QUOTA_ALERT_WEBHOOK)
+	quotaConfig := config.GetQuotaConfig()
+	apiServer.SetQuotaTracker(quota.NewTracker(quotaConfig, quota.Notifier(quotaConfig.AlertWebhook)))
+	if len(quotaConfig.AlertThresholds) > 0 {
+		utils.Info("[Server] Quota alerts at %v remaining (webhook: %v)", quotaConfig.AlertThresholds, quotaConfig.AlertWebhook != "")
+	}
+
+	// Background /health refresher (HEALTH_REFRESH_INTERVAL, 0 disables)
+	healthStop := make(chan struct{})
+	if interval := config.GetHealthRefreshInterval(); interval > 0 {
+		apiServer.StartHealthRefresher(interval, healthStop)
+		utils.Info("[Server] Health report refreshed every %s", interval)
+	}
+
+	// Pick up account changes saved by the CLI while running (ACCOUNTS_SYNC_INTERVAL, 0 disables)
+	accountsSyncStop := make(chan struct{})
+	if interval := config.GetAccountsSyncInterval(); interval > 0 {
+		accountManager.StartDiskSync(interval, accountsSyncStop)
+	}
+
+	// Re-test invalid accounts in the background (ACCOUNT_REVALIDATE_INTERVAL, 0 disables)
+	revalidateStop := make(chan struct{})
+	if revalidateConfig := config.GetAccountRevalidationConfig(); revalidateConfig.Interval > 0 {
+		accountManager.StartRevalidation(revalidateConfig.Interval, revalidateConfig.MaxAttempts,
+			func(ctx context.Context, acc account.Account) error {
+				_, err := verifyAccount(ctx, accountManager, acc)
+				return err
+			}, revalidateStop)
+	}
+
+	// Optional audit log (AUDIT_LOG_ENABLED)
+	auditConfig := config.GetAuditConfig()
+	auditLogger, err := audit.New(auditConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize audit log: %w", err)
+	}
+	if auditLogger != nil {
+		defer auditLogger.Close()
+		apiServer.SetAuditLogger(auditLogger)
+		utils.Info("[Server] Audit log enabled: %s (bodies: %v)", auditConfig.Dir, auditConfig.LogBodies)
+	}
+
+	// Opt-in anonymized usage report (TELEMETRY_ENABLED); previewable at /admin/telemetry
+	telemetryConfig := config.GetTelemetryConfig()
+	reporter := telemetry.New(telemetryConfig, Version, func() map[string]int {
+		counts := make(map[string]int)
+		for _, acc := range accountManager.GetAllAccounts() {
+			name := acc.Provider
+			if name == "" {
+				name = "antigravity"
+			}
+			counts[name]++
+		}
+		return counts
+	})
+	apiServer.SetTelemetry(reporter)
+	telemetryStop := make(chan struct{})
+	if reporter.Enabled() {
+		reporter.Start(telemetryStop)
+		utils.Info("[Server] Telemetry enabled: anonymized usage report sent to %s every %s", telemetryConfig.Endpoint, telemetryConfig.Interval)
+	} else if telemetryConfig.Enabled {
+		utils.Warn("[Server] TELEMETRY_ENABLED is set but TELEMETRY_ENDPOINT is not; no reports will be sent")
+	}
+
+	// OpenTelemetry tracing (OTEL_EXPORTER_OTLP_ENDPOINT)
+	tracingConfig := config.GetTracingConfig()
+	tracer := tracing.New(tracingConfig)
+	tracing.SetTracer(tracer)
+	if tracer != nil {
+		utils.Info("[Server] Exporting traces to %s (sample ratio %.2f)", tracingConfig.Endpoint, tracingConfig.SampleRatio)
+	}
+
+	// Probe paths, preStop hook and shutdown drain (PROBE_*_PATH, PRESTOP_PATH, SHUTDOWN_*)
+	probeConfig := config.GetProbeConfig()
+	apiServer.SetProbeConfig(probeConfig)
+
 	// Get configurable timeouts and bind address
 	timeouts := config.GetServerTimeouts()
 	bindAddr := config.GetBindAddress()
@@ -210,16 +477,58 @@ func runServe(cmd *cobra.Command, args []string) error {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			utils.Info("[Server] SIGHUP received, reloading config")
+			if _, err := reloader.Reload(); err != nil {
+				utils.Error("[Server] Config reload: %v", err)
+			}
+		}
+	}()
+
 	go func() {
 		<-quit
 		utils.Info("Shutting down server...")
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		// Keep serving while readiness reports 503 so load balancers stop routing here,
+		// unless a preStop hook already did so.
+		if apiServer.BeginDrain() && probeConfig.DrainDelay > 0 {
+			utils.Info("[Server] Waiting %s for traffic to drain", probeConfig.DrainDelay)
+			time.Sleep(probeConfig.DrainDelay)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), probeConfig.ShutdownTimeout)
 		defer cancel()
 
 		if err := server.Shutdown(ctx); err != nil {
 			utils.Error("Server forced to shutdown: %v", err)
 		}
+		close(backupStop)
+		close(healthStop)
+		close(accountsSyncStop)
+		close(revalidateStop)
+		close(telemetryStop)
+		close(sessionStop)
+		if sessionStore != nil && sessionConfig.Path != "" {
+			if err := sessionStore.SaveToFile(sessionConfig.Path); err != nil {
+				utils.Warn("[Server] Could not save sessions: %v", err)
+			}
+		}
+		close(budgetStop)
+		if budgetTracker != nil {
+			if err := budgetTracker.SaveToFile(budgetConfig.Path); err != nil {
+				utils.Warn("[Server] Could not save budget usage: %v", err)
+			}
+		}
+		if err := tracer.Shutdown(ctx); err != nil {
+			utils.Warn("[Server] Trace export shutdown: %v", err)
+		}
+
+		reloader.shutdownProviders(ctx)
+		// Let account changes saved in the background reach the disk.
+		accountManager.Flush()
 
 		close(done)
 	}()
@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the live state of a running server",
+	Long: `Show the account pool of a running server: each account's status, rate limit
+cooldown and last use, then the quota left on each model, when it resets and, with
+quota tracking enabled, when it is expected to run out.
+
+The state comes from the server's /health and /account-limits endpoints, so it
+includes the rate limits and quotas only the server knows about. Both fetch quotas
+from the providers; keep --interval generous with --watch.
+
+Examples:
+  multi-claude-proxy status
+  multi-claude-proxy status --watch --interval 30s
+  multi-claude-proxy status --url http://proxy.internal:8080 --output json`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runStatus,
+}
+
+var (
+	statusURL      string
+	statusWatch    bool
+	statusInterval time.Duration
+)
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().StringVar(&statusURL, "url", "", "Proxy URL (default http://localhost:<PORT>)")
+	statusCmd.Flags().BoolVarP(&statusWatch, "watch", "w", false, "Redraw continuously until interrupted")
+	statusCmd.Flags().DurationVar(&statusInterval, "interval", 10*time.Second, "Time between refreshes with --watch")
+	addOutputFlag(statusCmd)
+}
+
+// statusQuota is a model's quota in /health and /account-limits.
+type statusQuota struct {
+	RemainingFraction *float64   `json:"remainingFraction"`
+	ResetTime         any        `json:"resetTime"` // RFC 3339 string, Unix milliseconds or null, depending on the provider
+	IsSoftLimited     bool       `json:"isSoftLimited"`
+	ExhaustsAt        *time.Time `json:"exhaustsAt"` // Only in /account-limits, with quota tracking enabled
+}
+
+// statusReport is the part of /health the status command shows.
+type statusReport struct {
+	Summary  string `json:"summary"`
+	Accounts []struct {
+		Email    string                 `json:"email"`
+		Provider string                 `json:"provider"`
+		Status   string                 `json:"status"`
+		Error    string                 `json:"error"`
+		LastUsed *time.Time             `json:"lastUsed"`
+		Cooldown int64                  `json:"rateLimitCooldownRemaining"` // Milliseconds
+		Models   map[string]statusQuota `json:"models"`
+	} `json:"accounts"`
+	CachedAt *time.Time `json:"cachedAt"`
+}
+
+// statusLimits is the part of /account-limits the status command shows.
+type statusLimits struct {
+	Accounts []struct {
+		Email  string                 `json:"email"`
+		Limits map[string]statusQuota `json:"limits"`
+	} `json:"accounts"`
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	if statusWatch && statusInterval < time.Second {
+		return fmt.Errorf("--interval must be at least 1s")
+	}
+	baseURL := statusURL
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://localhost:%d", config.GetPort())
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	color := os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(os.Stdout.Fd()))
+	for {
+		var out bytes.Buffer
+		health, limits, err := fetchStatus(ctx, baseURL)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return nil
+		case err != nil && !statusWatch:
+			return err
+		case err != nil:
+			fmt.Fprintf(&out, "%s: %v\n", baseURL, err)
+		case asJSON:
+			if err := printJSON(map[string]json.RawMessage{"health": health, "accountLimits": limits}); err != nil {
+				return err
+			}
+		default:
+			if err := renderStatus(&out, baseURL, health, limits, time.Now(), color); err != nil {
+				return err
+			}
+		}
+
+		if statusWatch && !asJSON && color {
+			fmt.Print("\033[H\033[2J") // Redraw in place
+		}
+		os.Stdout.Write(out.Bytes())
+		if !statusWatch {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(statusInterval):
+		}
+	}
+}
+
+// fetchStatus gets /health and /account-limits from the server.
+func fetchStatus(ctx context.Context, baseURL string) (health, limits json.RawMessage, err error) {
+	get := func(path string) (json.RawMessage, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-API-Key", config.GetProxyAPIKey())
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("server not reachable: %w", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		if !json.Valid(body) {
+			return nil, fmt.Errorf("%s: invalid JSON response", path)
+		}
+		return body, nil
+	}
+
+	if health, err = get("/health"); err != nil {
+		return nil, nil, err
+	}
+	if limits, err = get("/account-limits"); err != nil {
+		return nil, nil, err
+	}
+	return health, limits, nil
+}
+
+// renderStatus writes the account and model quota tables for a /health and
+// /account-limits response.
+func renderStatus(w io.Writer, baseURL string, healthData, limitsData json.RawMessage, now time.Time, color bool) error {
+	var health statusReport
+	if err := json.Unmarshal(healthData, &health); err != nil {
+		return fmt.Errorf("invalid /health response: %w", err)
+	}
+	var limits statusLimits
+	if err := json.Unmarshal(limitsData, &limits); err != nil {
+		return fmt.Errorf("invalid /account-limits response: %w", err)
+	}
+	exhausts := make(map[string]*time.Time) // email + model -> expected exhaustion
+	for _, acc := range limits.Accounts {
+		for model, quota := range acc.Limits {
+			exhausts[acc.Email+"\x00"+model] = quota.ExhaustsAt
+		}
+	}
+
+	fmt.Fprintf(w, "%s (%s)\n", baseURL, now.Format("15:04:05"))
+	fmt.Fprintf(w, "Accounts: %s\n", health.Summary)
+	if health.CachedAt != nil {
+		fmt.Fprintf(w, "Quotas as of %s ago\n", utils.FormatDuration(now.Sub(*health.CachedAt).Truncate(time.Second)))
+	}
+	fmt.Fprintln(w)
+
+	var accounts, quotas [][]statusCell
+	for _, acc := range health.Accounts {
+		status := statusCell{text: acc.Status, color: statusColor(acc.Status)}
+		if acc.Error != "" {
+			status.text += ": " + acc.Error
+		}
+		cooldown, lastUsed := "-", "-"
+		if acc.Cooldown > 0 {
+			cooldown = utils.FormatDuration(time.Duration(acc.Cooldown) * time.Millisecond)
+		}
+		if acc.LastUsed != nil {
+			lastUsed = utils.FormatDuration(now.Sub(*acc.LastUsed).Truncate(time.Second)) + " ago"
+		}
+		accounts = append(accounts, []statusCell{{text: acc.Email}, {text: acc.Provider}, status, {text: cooldown}, {text: lastUsed}})
+
+		models := make([]string, 0, len(acc.Models))
+		for model := range acc.Models {
+			models = append(models, model)
+		}
+		sort.Strings(models)
+		for _, model := range models {
+			quota := acc.Models[model]
+			remaining := statusCell{text: "N/A"}
+			if f := quota.RemainingFraction; f != nil {
+				remaining.text = fmt.Sprintf("%d%%", int64(*f*100+0.5))
+				switch {
+				case *f <= 0:
+					remaining.color = "\033[31m" // red
+				case quota.IsSoftLimited:
+					remaining.color = "\033[33m" // yellow
+				default:
+					remaining.color = "\033[32m" // green
+				}
+			}
+			resets, exhaustsIn := "-", "-"
+			if t, ok := parseStatusTime(quota.ResetTime); ok && t.After(now) {
+				resets = utils.FormatDuration(t.Sub(now).Truncate(time.Second))
+			}
+			if t := exhausts[acc.Email+"\x00"+model]; t != nil {
+				exhaustsIn = utils.FormatDuration(max(t.Sub(now), 0).Truncate(time.Second))
+			}
+			quotas = append(quotas, []statusCell{{text: acc.Email}, {text: model}, remaining, {text: resets}, {text: exhaustsIn}})
+		}
+	}
+
+	if len(accounts) == 0 {
+		fmt.Fprintln(w, "No accounts configured.")
+		return nil
+	}
+	writeStatusTable(w, []string{"ACCOUNT", "PROVIDER", "STATUS", "COOLDOWN", "LAST USED"}, accounts, color)
+	if len(quotas) > 0 {
+		fmt.Fprintln(w)
+		writeStatusTable(w, []string{"ACCOUNT", "MODEL", "REMAINING", "RESETS IN", "EXHAUSTS IN"}, quotas, color)
+	}
+	return nil
+}
+
+// statusCell is a table cell, optionally colored with an ANSI escape.
+type statusCell struct {
+	text  string
+	color string
+}
+
+// writeStatusTable writes rows as aligned columns. Padding is computed from the plain
+// text, so escape codes don't throw off the alignment.
+func writeStatusTable(w io.Writer, header []string, rows [][]statusCell, color bool) {
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = len(h)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len([]rune(cell.text)))
+		}
+	}
+	writeRow := func(cells []statusCell) {
+		var line strings.Builder
+		for i, cell := range cells {
+			if color && cell.color != "" {
+				line.WriteString(cell.color + cell.text + "\033[0m")
+			} else {
+				line.WriteString(cell.text)
+			}
+			if i < len(cells)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-len([]rune(cell.text))+2))
+			}
+		}
+		fmt.Fprintln(w, strings.TrimRight(line.String(), " "))
+	}
+
+	headerCells := make([]statusCell, len(header))
+	for i, h := range header {
+		headerCells[i] = statusCell{text: h}
+	}
+	writeRow(headerCells)
+	for _, row := range rows {
+		writeRow(row)
+	}
+}
+
+// statusColor returns the color of an account status in /health.
+func statusColor(status string) string {
+	switch status {
+	case "ok":
+		return "\033[32m" // green
+	case "soft-limited", "rate-limited":
+		return "\033[33m" // yellow
+	case "disabled":
+		return "\033[90m" // gray
+	default:
+		return "\033[31m" // red
+	}
+}
+
+// parseStatusTime reads a reset time given as an RFC 3339 string or Unix milliseconds.
+func parseStatusTime(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	case float64:
+		return time.UnixMilli(int64(v)), v > 0
+	}
+	return time.Time{}, false
+}
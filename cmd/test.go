@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/streamcheck"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// testCmd represents the test command
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Send a small test message through each provider",
+	Long: `Send a small test message through each provider that has accounts, the way the
+server would: the provider selects an account, converts the request and streams the
+response, which is checked against the Messages API streaming protocol.
+
+For each provider it reports whether the request succeeded, the latency and time to
+the first token, the token usage and the account that served it. Use it to validate a
+new account or model before pointing clients at the proxy.
+
+Models are the provider's own IDs; "<provider>/<model>" also selects the provider.
+Without --model, the first model that doesn't think or generate images is used.
+It doesn't need a running server, and exits non-zero if any test failed.
+
+Examples:
+  multi-claude-proxy test
+  multi-claude-proxy test --provider zai --model glm-4.7
+  multi-claude-proxy test --model antigravity/gemini-3-flash --account me@gmail.com
+  multi-claude-proxy test --provider anthropic --no-stream -o json`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runTest,
+}
+
+var (
+	testProvider  string
+	testModel     string
+	testAccount   string
+	testPrompt    string
+	testMaxTokens int
+	testNoStream  bool
+	testTimeout   time.Duration
+)
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+
+	testCmd.Flags().StringVar(&testProvider, "provider", "", "Only test this provider (default: every enabled provider with accounts)")
+	testCmd.Flags().StringVar(&testModel, "model", "", "Model to test (default: a small non-thinking model)")
+	testCmd.Flags().StringVar(&testAccount, "account", "", "Send the request with this account instead of letting the provider select one")
+	testCmd.Flags().StringVar(&testPrompt, "prompt", "Reply with the single word: pong", "Message to send")
+	testCmd.Flags().IntVar(&testMaxTokens, "max-tokens", 16, "max_tokens of the test request")
+	testCmd.Flags().BoolVar(&testNoStream, "no-stream", false, "Send the request without streaming")
+	testCmd.Flags().DurationVar(&testTimeout, "timeout", time.Minute, "Timeout for each provider's test")
+	addOutputFlag(testCmd)
+
+	_ = testCmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions(
+		config.BuiltinProviders, cobra.ShellCompDirectiveNoFileComp))
+	_ = testCmd.RegisterFlagCompletionFunc("account", completeAccountEmails(false))
+}
+
+// testResult is the outcome of one provider's test, as printed by 'test --output json'.
+type testResult struct {
+	Provider     string       `json:"provider"`
+	Model        string       `json:"model,omitempty"`
+	Account      string       `json:"account,omitempty"`
+	OK           bool         `json:"ok"`
+	Error        string       `json:"error,omitempty"`
+	LatencyMs    int64        `json:"latencyMs"`
+	FirstTokenMs int64        `json:"firstTokenMs,omitempty"` // Streaming only
+	Attempts     int          `json:"attempts"`
+	StopReason   string       `json:"stopReason,omitempty"`
+	Usage        *types.Usage `json:"usage,omitempty"`
+	Text         string       `json:"text,omitempty"`
+}
+
+func runTest(cmd *cobra.Command, args []string) error {
+	asJSON, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	if p, m, ok := strings.Cut(testModel, "/"); ok && slices.Contains(config.BuiltinProviders, p) {
+		if testProvider != "" && testProvider != p {
+			return fmt.Errorf("--model %s doesn't belong to --provider %s", testModel, testProvider)
+		}
+		testProvider, testModel = p, m
+	}
+	if testProvider != "" && !slices.Contains(config.BuiltinProviders, testProvider) {
+		return fmt.Errorf("unknown provider %q", testProvider)
+	}
+
+	manager := account.NewManager("")
+	if err := manager.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize account manager: %w", err)
+	}
+
+	providers := []string{testProvider}
+	if testAccount != "" {
+		acc, ok := manager.GetAccount(testAccount)
+		if !ok {
+			return fmt.Errorf("account %s not found", testAccount)
+		}
+		if testProvider != "" && acc.Provider != testProvider {
+			return fmt.Errorf("account %s belongs to provider %s", testAccount, acc.Provider)
+		}
+		providers = []string{acc.Provider}
+	} else if testProvider == "" {
+		if providers = providersWithAccounts(manager); len(providers) == 0 {
+			return fmt.Errorf("no enabled provider has accounts; add one with 'accounts add'")
+		}
+	}
+
+	results := make([]testResult, 0, len(providers))
+	failed := 0
+	for _, name := range providers {
+		if !asJSON {
+			utils.Info("Testing %s...", name)
+		}
+		res := testProviderRequest(name, manager)
+		if !res.OK {
+			failed++
+		}
+		results = append(results, res)
+		if !asJSON {
+			printTestResult(res)
+		}
+	}
+
+	if asJSON {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d test(s) failed", failed, len(results))
+	}
+	return nil
+}
+
+// testProviderRequest builds the provider and sends the test request through it.
+func testProviderRequest(name string, manager *account.Manager) testResult {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if testAccount != "" {
+		ctx = account.WithAccount(ctx, testAccount)
+	}
+
+	prov := newProbeProvider(name, manager)
+	if err := prov.Initialize(ctx); err != nil {
+		return testResult{Provider: name, Model: testModel, Error: fmt.Sprintf("failed to initialize provider: %v", err)}
+	}
+	defer prov.Shutdown(context.Background())
+
+	model := testModel
+	if model == "" {
+		model = pickTestModel(prov.Models(), nil)
+	}
+	if model == "" {
+		return testResult{Provider: name, Error: "no model to test"}
+	}
+	return sampleRequest{prompt: testPrompt, maxTokens: testMaxTokens, stream: !testNoStream}.send(ctx, prov, model)
+}
+
+// sampleRequest is a single-message request sent by 'test' and 'models bench'.
+type sampleRequest struct {
+	prompt    string
+	maxTokens int
+	stream    bool
+}
+
+// send sends the request to model through prov and reports how it went.
+func (s sampleRequest) send(ctx context.Context, prov provider.Provider, model string) testResult {
+	res := testResult{Provider: prov.Name(), Model: model}
+	trace := &provider.Trace{}
+	ctx = provider.WithTrace(ctx, trace)
+
+	prompt, _ := json.Marshal(s.prompt)
+	req := &types.AnthropicRequest{
+		Model:     model,
+		MaxTokens: s.maxTokens,
+		Stream:    s.stream,
+		Messages:  []types.Message{{Role: "user", Content: prompt}},
+	}
+	start := time.Now()
+	var (
+		resp *types.AnthropicResponse
+		err  error
+	)
+	if s.stream {
+		resp, err = collectTestStream(ctx, prov, req, start, &res)
+	} else {
+		resp, err = prov.SendMessage(ctx, req)
+	}
+	res.LatencyMs = time.Since(start).Milliseconds()
+	res.Account = trace.Account()
+	res.Attempts = trace.Attempts()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	res.OK = true
+	res.StopReason = resp.StopReason
+	res.Usage = &resp.Usage
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			res.Text += block.Text
+		}
+	}
+	return res
+}
+
+// providersWithAccounts returns the enabled providers that have accounts.
+func providersWithAccounts(manager *account.Manager) []string {
+	var providers []string
+	for _, name := range config.BuiltinProviders {
+		if config.IsProviderEnabled(name) && manager.GetAccountCountByProvider(name) > 0 {
+			providers = append(providers, name)
+		}
+	}
+	return providers
+}
+
+// collectTestStream reads the provider's stream, checks it against the streaming protocol
+// and accumulates the final message the way the SDKs do.
+func collectTestStream(ctx context.Context, prov provider.Provider, req *types.AnthropicRequest, start time.Time, res *testResult) (*types.AnthropicResponse, error) {
+	ch, err := prov.SendMessageStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var events []types.StreamEvent
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return accumulateTestStream(events)
+			}
+			if res.FirstTokenMs == 0 && ev.Type == "content_block_delta" {
+				res.FirstTokenMs = max(time.Since(start).Milliseconds(), 1)
+			}
+			events = append(events, ev)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("stream did not finish: %w", ctx.Err())
+		}
+	}
+}
+
+func accumulateTestStream(events []types.StreamEvent) (*types.AnthropicResponse, error) {
+	serialized, err := streamcheck.FromStreamEvents(events)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stream event: %w", err)
+	}
+	msg, err := streamcheck.Accumulate(serialized)
+	if err != nil {
+		return nil, err
+	}
+	if err := streamcheck.Check(serialized); err != nil {
+		return nil, fmt.Errorf("stream violates the streaming protocol: %w", err)
+	}
+	return msg, nil
+}
+
+func printTestResult(r testResult) {
+	model := r.Model
+	if model == "" {
+		model = "no model"
+	}
+	if !r.OK {
+		fmt.Printf("  %s (%s): \033[31mFAILED\033[0m after %s\n", r.Provider, model, time.Duration(r.LatencyMs)*time.Millisecond)
+		fmt.Printf("     Error: %s\n", r.Error)
+	} else {
+		fmt.Printf("  %s (%s): \033[32mOK\033[0m in %s\n", r.Provider, model, time.Duration(r.LatencyMs)*time.Millisecond)
+		if r.FirstTokenMs > 0 {
+			fmt.Printf("     First token: %s\n", time.Duration(r.FirstTokenMs)*time.Millisecond)
+		}
+		fmt.Printf("     Usage: %d input, %d output tokens\n", r.Usage.InputTokens, r.Usage.OutputTokens)
+		fmt.Printf("     Stop reason: %s\n", r.StopReason)
+		fmt.Printf("     Response: %q\n", r.Text)
+	}
+	if r.Account != "" {
+		fmt.Printf("     Account: %s", r.Account)
+		if r.Attempts > 1 {
+			fmt.Printf(" (after %d attempts)", r.Attempts)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
@@ -0,0 +1,99 @@
+package account
+
+import (
+	"fmt"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// GetArchivedAccounts returns a copy of all soft-deleted accounts.
+func (m *Manager) GetArchivedAccounts() []Account {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]Account, len(m.archived))
+	copy(result, m.archived)
+	return result
+}
+
+// RestoreAccount moves an archived account back into the pool with its stored credentials.
+// Rate limit and invalid state are cleared so the account gets a fresh start.
+func (m *Manager) RestoreAccount(email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	i := m.findArchivedIndexLocked(email)
+	if i < 0 {
+		return fmt.Errorf("archived account %s not found", email)
+	}
+	if m.findAccountIndexLocked(email) >= 0 {
+		return fmt.Errorf("account %s already exists", email)
+	}
+	if len(m.accounts) >= config.MaxAccounts {
+		return fmt.Errorf("maximum number of accounts (%d) reached", config.MaxAccounts)
+	}
+
+	archived := m.archived
+	restored := m.archived[i]
+	restored.ArchivedAt = nil
+	restored.IsInvalid = false
+	restored.InvalidReason = ""
+	restored.InvalidAt = nil
+	restored.ModelRateLimits = make(map[string]ModelRateLimit)
+
+	m.archived = append(m.archived[:i:i], m.archived[i+1:]...)
+	m.accounts = append(m.accounts, restored)
+
+	if err := m.saveToDiskLocked(); err != nil {
+		m.accounts = m.accounts[:len(m.accounts)-1]
+		m.archived = archived
+		return fmt.Errorf("failed to save after restore: %w", err)
+	}
+	delete(m.blacklisted, email)
+
+	utils.Success("[AccountManager] Restored account: %s", email)
+	return nil
+}
+
+// PurgeAccount permanently deletes an account and its credentials, whether active or archived.
+func (m *Manager) PurgeAccount(email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	accounts, archived := m.accounts, m.archived
+	if i := m.findAccountIndexLocked(email); i >= 0 {
+		m.detachAccountLocked(i)
+	} else if i := m.findArchivedIndexLocked(email); i >= 0 {
+		m.archived = append(m.archived[:i:i], m.archived[i+1:]...)
+	} else {
+		return fmt.Errorf("account %s not found", email)
+	}
+
+	if err := m.saveToDiskLocked(); err != nil {
+		m.accounts, m.archived = accounts, archived
+		return fmt.Errorf("failed to save after purge: %w", err)
+	}
+
+	utils.Success("[AccountManager] Permanently deleted account: %s", email)
+	return nil
+}
+
+// findArchivedIndexLocked returns the index of an archived account, or -1.
+func (m *Manager) findArchivedIndexLocked(email string) int {
+	for i := range m.archived {
+		if m.archived[i].Email == email {
+			return i
+		}
+	}
+	return -1
+}
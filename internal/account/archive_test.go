@@ -0,0 +1,103 @@
+package account
+
+import (
+	"testing"
+)
+
+func TestRemoveAccount_ArchivesAndRestores(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.AddAccount(Account{Email: "a@example.com", Source: "oauth", Provider: "antigravity", RefreshToken: "rt-a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddAccount(Account{Email: "b@example.com", Source: "oauth", Provider: "antigravity", RefreshToken: "rt-b"}); err != nil {
+		t.Fatal(err)
+	}
+	m.MarkRateLimited("a@example.com", 60000, "model")
+
+	if err := m.RemoveAccount("a@example.com"); err != nil {
+		t.Fatalf("RemoveAccount: %v", err)
+	}
+	if got := m.GetAccountCountByProvider("antigravity"); got != 1 {
+		t.Fatalf("active accounts = %d, want 1", got)
+	}
+	for i := 0; i < 3; i++ {
+		if acc := m.PickNextByProvider("antigravity", "model"); acc == nil || acc.Email != "b@example.com" {
+			t.Fatalf("archived account must not be selected, got %+v", acc)
+		}
+	}
+
+	// Archived accounts survive a reload with their credentials.
+	reloaded := NewManager(m.storage.ConfigPath())
+	if err := reloaded.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	archived := reloaded.GetArchivedAccounts()
+	if len(archived) != 1 || archived[0].RefreshToken != "rt-a" || archived[0].ArchivedAt == nil {
+		t.Fatalf("unexpected archived accounts: %+v", archived)
+	}
+
+	if err := reloaded.RestoreAccount("a@example.com"); err != nil {
+		t.Fatalf("RestoreAccount: %v", err)
+	}
+	if len(reloaded.GetArchivedAccounts()) != 0 {
+		t.Error("expected archive to be empty after restore")
+	}
+	restored := reloaded.GetAllAccountsByProvider("antigravity")
+	if len(restored) != 2 {
+		t.Fatalf("active accounts = %d, want 2", len(restored))
+	}
+	for _, acc := range restored {
+		if acc.Email == "a@example.com" && (acc.RefreshToken != "rt-a" || acc.ArchivedAt != nil || len(acc.ModelRateLimits) != 0) {
+			t.Errorf("unexpected restored account: %+v", acc)
+		}
+	}
+
+	if err := reloaded.RestoreAccount("a@example.com"); err == nil {
+		t.Error("expected error restoring an account that is not archived")
+	}
+}
+
+func TestPurgeAccount(t *testing.T) {
+	m := newTestManager(t)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := m.AddAccount(Account{Email: email, Source: "manual", Provider: "zai", APIKey: "key"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.RemoveAccount("a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Purge works for both archived and active accounts.
+	if err := m.PurgeAccount("a@example.com"); err != nil {
+		t.Fatalf("PurgeAccount(archived): %v", err)
+	}
+	if err := m.PurgeAccount("b@example.com"); err != nil {
+		t.Fatalf("PurgeAccount(active): %v", err)
+	}
+	if m.GetAccountCount() != 0 || len(m.GetArchivedAccounts()) != 0 {
+		t.Error("expected no accounts after purge")
+	}
+	if err := m.RestoreAccount("a@example.com"); err == nil {
+		t.Error("expected purged account to be unrecoverable")
+	}
+	if err := m.PurgeAccount("missing@example.com"); err == nil {
+		t.Error("expected error for unknown account")
+	}
+}
+
+func TestAddAccount_ReplacesArchivedCopy(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.AddAccount(Account{Email: "a@example.com", Source: "oauth", RefreshToken: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemoveAccount("a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddAccount(Account{Email: "a@example.com", Source: "oauth", RefreshToken: "new"}); err != nil {
+		t.Fatalf("AddAccount: %v", err)
+	}
+	if len(m.GetArchivedAccounts()) != 0 {
+		t.Error("expected re-added account to drop its archived copy")
+	}
+}
@@ -0,0 +1,205 @@
+package account
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Load balancer strategy names accepted by NewLoadBalancer.
+const (
+	BalancerRoundRobin    = "round-robin"
+	BalancerLeastLoaded   = "least-loaded"
+	BalancerQuotaWeighted = "quota-weighted"
+)
+
+// Candidate is a usable account offered to a LoadBalancer.
+// Account is a snapshot; balancers must not modify it.
+type Candidate struct {
+	Index     int // Position in the manager's account list
+	Account   Account
+	Preferred bool // False when the account is soft-limited for the model or deprioritized as flaky
+	InFlight  int  // Requests dispatched to the account (BeginRequest) that haven't reported a result
+}
+
+// Result describes the outcome of a request served by an account.
+type Result struct {
+	Email       string
+	Provider    string
+	ModelID     string
+	Latency     time.Duration
+	StatusCode  int  // Upstream HTTP status, 0 if unknown
+	RateLimited bool // Upstream returned 429 / quota exhausted
+	Empty       bool // Upstream returned an empty response
+	Err         error
+}
+
+// LoadBalancer decides which usable account serves the next request.
+// Implementations must be safe for concurrent use.
+type LoadBalancer interface {
+	// Name returns the strategy name.
+	Name() string
+	// PickNext returns the index into candidates of the account to use, or -1 for none.
+	// lastIndex is the manager index of the account picked last time for this provider.
+	PickNext(provider, modelID string, candidates []Candidate, lastIndex int) int
+	// Feedback reports the outcome of a request served by a previously picked account.
+	Feedback(result Result)
+}
+
+// NewLoadBalancer creates a load balancer by strategy name. An empty name selects round-robin.
+func NewLoadBalancer(name string) (LoadBalancer, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", BalancerRoundRobin:
+		return NewRoundRobinBalancer(), nil
+	case BalancerLeastLoaded:
+		return NewLeastLoadedBalancer(), nil
+	case BalancerQuotaWeighted:
+		return NewQuotaWeightedBalancer(), nil
+	default:
+		return nil, fmt.Errorf("unknown load balancer %q (expected %s, %s or %s)",
+			name, BalancerRoundRobin, BalancerLeastLoaded, BalancerQuotaWeighted)
+	}
+}
+
+// preferredOrAll returns the positions of preferred candidates, or of all candidates if none is preferred.
+func preferredOrAll(candidates []Candidate) []int {
+	positions := make([]int, 0, len(candidates))
+	for i, c := range candidates {
+		if c.Preferred {
+			positions = append(positions, i)
+		}
+	}
+	if len(positions) > 0 {
+		return positions
+	}
+	for i := range candidates {
+		positions = append(positions, i)
+	}
+	return positions
+}
+
+// RoundRobinBalancer picks the next account after the last one used (the historical behavior).
+type RoundRobinBalancer struct{}
+
+// NewRoundRobinBalancer creates a round-robin balancer.
+func NewRoundRobinBalancer() *RoundRobinBalancer {
+	return &RoundRobinBalancer{}
+}
+
+// Name returns the strategy name.
+func (b *RoundRobinBalancer) Name() string { return BalancerRoundRobin }
+
+// PickNext returns the first preferred candidate after lastIndex, wrapping around.
+func (b *RoundRobinBalancer) PickNext(provider, modelID string, candidates []Candidate, lastIndex int) int {
+	positions := preferredOrAll(candidates)
+	if len(positions) == 0 {
+		return -1
+	}
+	// Candidates are ordered by manager index: take the first one after lastIndex, else wrap.
+	for _, pos := range positions {
+		if candidates[pos].Index > lastIndex {
+			return pos
+		}
+	}
+	return positions[0]
+}
+
+// Feedback is a no-op for round-robin.
+func (b *RoundRobinBalancer) Feedback(result Result) {}
+
+// LeastLoadedBalancer picks the account with the fewest in-flight requests. Load is what the
+// manager counts between BeginRequest and ReportResult, so picks that are never dispatched,
+// e.g. by a retry loop that moves on, don't count.
+type LeastLoadedBalancer struct{}
+
+// staleInFlight bounds how long a request without ReportResult counts towards an account's load.
+const staleInFlight = 10 * time.Minute
+
+// NewLeastLoadedBalancer creates a least-loaded balancer.
+func NewLeastLoadedBalancer() *LeastLoadedBalancer {
+	return &LeastLoadedBalancer{}
+}
+
+// Name returns the strategy name.
+func (b *LeastLoadedBalancer) Name() string { return BalancerLeastLoaded }
+
+// PickNext returns the preferred candidate with the lowest load; ties go round-robin after lastIndex.
+func (b *LeastLoadedBalancer) PickNext(provider, modelID string, candidates []Candidate, lastIndex int) int {
+	positions := preferredOrAll(candidates)
+	if len(positions) == 0 {
+		return -1
+	}
+
+	best := -1
+	bestAfterLast := false
+	for _, pos := range positions {
+		load := candidates[pos].InFlight
+		afterLast := candidates[pos].Index > lastIndex
+		if best < 0 || load < candidates[best].InFlight || (load == candidates[best].InFlight && afterLast && !bestAfterLast) {
+			best, bestAfterLast = pos, afterLast
+		}
+	}
+	return best
+}
+
+// Feedback is a no-op for least-loaded: the manager tracks in-flight requests.
+func (b *LeastLoadedBalancer) Feedback(result Result) {}
+
+// QuotaWeightedBalancer picks randomly, weighted by each account's remaining quota for the model.
+type QuotaWeightedBalancer struct {
+	mu   sync.Mutex
+	rand func() float64
+}
+
+// minQuotaWeight keeps accounts with unknown or exhausted quota selectable.
+const minQuotaWeight = 0.01
+
+// NewQuotaWeightedBalancer creates a quota-weighted balancer.
+func NewQuotaWeightedBalancer() *QuotaWeightedBalancer {
+	return &QuotaWeightedBalancer{rand: rand.Float64}
+}
+
+// Name returns the strategy name.
+func (b *QuotaWeightedBalancer) Name() string { return BalancerQuotaWeighted }
+
+// PickNext chooses among preferred candidates with probability proportional to remaining quota.
+// Accounts without quota data count as full (1.0).
+func (b *QuotaWeightedBalancer) PickNext(provider, modelID string, candidates []Candidate, lastIndex int) int {
+	positions := preferredOrAll(candidates)
+	if len(positions) == 0 {
+		return -1
+	}
+
+	weights := make([]float64, len(positions))
+	total := 0.0
+	for i, pos := range positions {
+		w := 1.0
+		if limit, ok := candidates[pos].Account.ModelRateLimits[modelID]; ok && limit.QuotaRemaining > 0 {
+			w = limit.QuotaRemaining
+		} else if ok && limit.IsSoftLimited {
+			w = minQuotaWeight
+		}
+		if w < minQuotaWeight {
+			w = minQuotaWeight
+		}
+		weights[i] = w
+		total += w
+	}
+
+	b.mu.Lock()
+	r := b.rand() * total
+	b.mu.Unlock()
+
+	for i, w := range weights {
+		if r < w {
+			return positions[i]
+		}
+		r -= w
+	}
+	return positions[len(positions)-1]
+}
+
+// Feedback is a no-op; quota data arrives through soft-limit updates.
+func (b *QuotaWeightedBalancer) Feedback(result Result) {}
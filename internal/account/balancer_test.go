@@ -0,0 +1,209 @@
+package account
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newTestManager returns a manager backed by a temp dir. The dir is removed with
+// os.RemoveAll rather than t.TempDir because the manager saves asynchronously.
+func newTestManager(t *testing.T) *Manager {
+	m := NewManager(filepath.Join(t.TempDir(), "accounts.json"))
+	// Runs before t.TempDir is removed.
+	t.Cleanup(m.Flush)
+	return m
+}
+
+func candidatesFor(accounts []Account, preferred ...bool) []Candidate {
+	out := make([]Candidate, len(accounts))
+	for i, acc := range accounts {
+		out[i] = Candidate{Index: i, Account: acc, Preferred: true}
+		if i < len(preferred) {
+			out[i].Preferred = preferred[i]
+		}
+	}
+	return out
+}
+
+func TestNewLoadBalancer(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"", BalancerRoundRobin, false},
+		{"round-robin", BalancerRoundRobin, false},
+		{"Least-Loaded", BalancerLeastLoaded, false},
+		{"quota-weighted", BalancerQuotaWeighted, false},
+		{"random", "", true},
+	}
+	for _, tt := range tests {
+		lb, err := NewLoadBalancer(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NewLoadBalancer(%q) expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil || lb.Name() != tt.want {
+			t.Errorf("NewLoadBalancer(%q) = %v, %v; want %s", tt.name, lb, err, tt.want)
+		}
+	}
+}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	accounts := []Account{{Email: "a"}, {Email: "b"}, {Email: "c"}}
+	lb := NewRoundRobinBalancer()
+
+	if got := lb.PickNext("p", "m", candidatesFor(accounts), 0); got != 1 {
+		t.Errorf("PickNext after 0 = %d, want 1", got)
+	}
+	if got := lb.PickNext("p", "m", candidatesFor(accounts), 2); got != 0 {
+		t.Errorf("PickNext after 2 = %d, want wrap to 0", got)
+	}
+	// Skips non-preferred accounts while a preferred one exists.
+	if got := lb.PickNext("p", "m", candidatesFor(accounts, true, false, true), 0); got != 2 {
+		t.Errorf("PickNext with soft-limited b = %d, want 2", got)
+	}
+	// Falls back to non-preferred accounts when none is preferred.
+	if got := lb.PickNext("p", "m", candidatesFor(accounts, false, false, false), 0); got != 1 {
+		t.Errorf("PickNext with all soft-limited = %d, want 1", got)
+	}
+}
+
+func TestLeastLoadedBalancer(t *testing.T) {
+	accounts := []Account{{Email: "a"}, {Email: "b"}}
+	lb := NewLeastLoadedBalancer()
+	cands := candidatesFor(accounts)
+
+	cands[0].InFlight = 2
+	cands[1].InFlight = 1
+	if got := lb.PickNext("p", "m", cands, 1); got != 1 {
+		t.Errorf("PickNext = %d, want least-loaded 1", got)
+	}
+	// Ties go round-robin after the last pick.
+	cands[0].InFlight = 1
+	if got := lb.PickNext("p", "m", cands, 1); got != 0 {
+		t.Errorf("PickNext = %d, want 0 on a tie after 1", got)
+	}
+}
+
+func TestLeastLoadedBalancer_UndispatchedPicks(t *testing.T) {
+	m := newTestManager(t)
+	m.SetLoadBalancer(NewLeastLoadedBalancer())
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := m.AddAccount(Account{Email: email, Source: "manual", Provider: "zai", APIKey: "key-" + email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Picks a retry loop throws away don't count as load.
+	for range 5 {
+		if acc := m.PickNextByProvider("zai", "model"); acc == nil {
+			t.Fatal("expected an account")
+		}
+	}
+	m.BeginRequest("a@example.com")
+	for range 3 {
+		if acc := m.PickNextByProvider("zai", "model"); acc == nil || acc.Email != "b@example.com" {
+			t.Fatalf("expected the idle account, got %+v", acc)
+		}
+	}
+
+	// Once the dispatched request reports its result, both accounts are idle again.
+	m.ReportResult(Result{Email: "a@example.com", Provider: "zai", ModelID: "model"})
+	seen := map[string]bool{}
+	for range 4 {
+		if acc := m.PickNextByProvider("zai", "model"); acc != nil {
+			seen[acc.Email] = true
+		}
+	}
+	if !seen["a@example.com"] || !seen["b@example.com"] {
+		t.Errorf("expected both idle accounts to be picked, got %v", seen)
+	}
+}
+
+func TestQuotaWeightedBalancer(t *testing.T) {
+	accounts := []Account{
+		{Email: "low", ModelRateLimits: map[string]ModelRateLimit{"m": {QuotaRemaining: 0.1}}},
+		{Email: "high", ModelRateLimits: map[string]ModelRateLimit{"m": {QuotaRemaining: 0.9}}},
+	}
+	lb := NewQuotaWeightedBalancer()
+
+	lb.rand = func() float64 { return 0.05 } // 0.05 * 1.0 falls into "low" (weight 0.1)
+	if got := lb.PickNext("p", "m", candidatesFor(accounts), 0); got != 0 {
+		t.Errorf("PickNext(r=0.05) = %d, want 0", got)
+	}
+	lb.rand = func() float64 { return 0.5 }
+	if got := lb.PickNext("p", "m", candidatesFor(accounts), 0); got != 1 {
+		t.Errorf("PickNext(r=0.5) = %d, want 1", got)
+	}
+}
+
+// Balancers are injected into the manager without touching its internals.
+type fixedBalancer struct {
+	pick     string
+	feedback []Result
+}
+
+func (b *fixedBalancer) Name() string { return "fixed" }
+func (b *fixedBalancer) PickNext(provider, modelID string, candidates []Candidate, lastIndex int) int {
+	for i, c := range candidates {
+		if c.Account.Email == b.pick {
+			return i
+		}
+	}
+	return -1
+}
+func (b *fixedBalancer) Feedback(result Result) { b.feedback = append(b.feedback, result) }
+
+func TestManager_UsesInjectedBalancer(t *testing.T) {
+	mgr := newTestManager(t)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := mgr.AddAccount(Account{Email: email, Provider: "zai", Source: "manual", APIKey: "k"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lb := &fixedBalancer{pick: "c@example.com"}
+	mgr.SetLoadBalancer(lb)
+
+	for i := 0; i < 3; i++ {
+		acc := mgr.PickNextByProvider("zai", "glm")
+		if acc == nil || acc.Email != "c@example.com" {
+			t.Fatalf("PickNextByProvider() = %v, want c@example.com", acc)
+		}
+	}
+
+	mgr.ReportResult(Result{Email: "c@example.com", StatusCode: 200})
+	if len(lb.feedback) != 1 {
+		t.Errorf("expected feedback to reach balancer, got %d results", len(lb.feedback))
+	}
+}
+
+func TestPickNextByProvider_SkipsAccountsAtConcurrencyCap(t *testing.T) {
+	mgr := newTestManager(t)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := mgr.AddAccount(Account{Email: email, Provider: "zai", Source: "manual", APIKey: "k"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mgr.SetMaxConcurrentPerAccount(1)
+
+	first := mgr.PickNextByProvider("zai", "glm")
+	mgr.BeginRequest(first.Email)
+	second := mgr.PickNextByProvider("zai", "glm")
+	if second == nil || second.Email == first.Email {
+		t.Fatalf("expected the idle account, got %v", second)
+	}
+	mgr.BeginRequest(second.Email)
+
+	if acc := mgr.PickNextByProvider("zai", "glm"); acc != nil {
+		t.Errorf("expected no account while both are at the cap, got %s", acc.Email)
+	}
+
+	mgr.ReportResult(Result{Email: first.Email, Provider: "zai", ModelID: "glm"})
+	if acc := mgr.PickNextByProvider("zai", "glm"); acc == nil || acc.Email != first.Email {
+		t.Errorf("expected %s after its request finished, got %v", first.Email, acc)
+	}
+}
@@ -0,0 +1,31 @@
+package account
+
+import (
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// An account marked invalid stays out of selection for config.InvalidBlacklistWindow even
+// if its invalid flag is cleared sooner: requests that picked the account before the
+// invalidation can still succeed a token refresh and clear the flag, and a disk sync may
+// keep a stale copy, so without the window the next pick would hand out the account that
+// just failed authentication. Re-authenticating, restoring or changing the account's
+// credentials ends the window early.
+
+// blacklistLocked starts the post-invalidation window for an account and prunes expired
+// entries. The caller must hold m.mu for writing.
+func (m *Manager) blacklistLocked(email string, now time.Time) {
+	for e, until := range m.blacklisted {
+		if !now.Before(until) {
+			delete(m.blacklisted, e)
+		}
+	}
+	m.blacklisted[email] = now.Add(config.InvalidBlacklistWindow)
+}
+
+// isBlacklistedLocked reports whether an account is within its post-invalidation window.
+func (m *Manager) isBlacklistedLocked(email string, now time.Time) bool {
+	until, ok := m.blacklisted[email]
+	return ok && now.Before(until)
+}
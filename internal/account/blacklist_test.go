@@ -0,0 +1,141 @@
+package account
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+func newBlacklistTestManager(t *testing.T) *Manager {
+	t.Helper()
+	mgr := newTestManager(t)
+	for _, email := range []string{"a@x", "b@x"} {
+		if err := mgr.AddAccount(Account{Email: email, Provider: "zai", Source: "manual", APIKey: "k"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mgr.SetHealthConfig(config.AccountHealthConfig{Enabled: false})
+	return mgr
+}
+
+// clearInvalid clears an account's invalid flag the way a token refresh that raced with
+// the invalidation does.
+func clearInvalid(mgr *Manager, email string) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if acc := mgr.findAccountLocked(email); acc != nil {
+		acc.IsInvalid = false
+		acc.InvalidReason = ""
+	}
+}
+
+func TestManager_BlacklistsInvalidatedAccounts(t *testing.T) {
+	mgr := newBlacklistTestManager(t)
+
+	mgr.MarkInvalid("a@x", "AUTH_INVALID")
+	clearInvalid(mgr, "a@x")
+
+	for i := 0; i < 4; i++ {
+		if acc := mgr.PickNextByProvider("zai", "m"); acc == nil || acc.Email != "b@x" {
+			t.Fatalf("pick %d: expected b@x during the blacklist window, got %v", i, acc)
+		}
+	}
+
+	mgr.mu.Lock()
+	mgr.blacklisted["a@x"] = time.Now().Add(-time.Second)
+	mgr.mu.Unlock()
+
+	picked := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		if acc := mgr.PickNextByProvider("zai", "m"); acc != nil {
+			picked[acc.Email] = true
+		}
+	}
+	if !picked["a@x"] {
+		t.Errorf("expected a@x to be selectable after the window, picked %v", picked)
+	}
+}
+
+func TestManager_PickNextSkipsBlacklistedAccounts(t *testing.T) {
+	mgr := newTestManager(t)
+	for _, email := range []string{"a@x", "b@x"} {
+		if err := mgr.AddAccount(Account{Email: email, Source: "manual", APIKey: "k"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mgr.MarkInvalid("a@x", "AUTH_INVALID")
+	clearInvalid(mgr, "a@x")
+
+	for i := 0; i < 4; i++ {
+		if acc := mgr.PickNext("m"); acc == nil || acc.Email != "b@x" {
+			t.Fatalf("pick %d: expected b@x, got %v", i, acc)
+		}
+	}
+
+	mgr.MarkInvalid("b@x", "AUTH_INVALID")
+	clearInvalid(mgr, "b@x")
+	if acc := mgr.PickNext("m"); acc != nil {
+		t.Errorf("expected no account while both are blacklisted, got %s", acc.Email)
+	}
+}
+
+// TestManager_InvalidationRacesConcurrentPicks checks that once MarkInvalid returns, no
+// pick hands out the account, even while its invalid flag is concurrently cleared.
+func TestManager_InvalidationRacesConcurrentPicks(t *testing.T) {
+	mgr := newBlacklistTestManager(t)
+
+	const pickers, picks = 8, 50
+	var invalidated atomic.Bool
+	var violations atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < pickers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < picks; j++ {
+				after := invalidated.Load()
+				if acc := mgr.PickNextByProvider("zai", "m"); after && acc != nil && acc.Email == "a@x" {
+					violations.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < picks; j++ {
+			clearInvalid(mgr, "a@x")
+		}
+	}()
+
+	mgr.MarkInvalid("a@x", "AUTH_INVALID")
+	invalidated.Store(true)
+	wg.Wait()
+
+	if n := violations.Load(); n > 0 {
+		t.Errorf("a@x was picked %d times after it was invalidated", n)
+	}
+}
+
+func TestManager_UpdateOAuthCredentialsEndsBlacklist(t *testing.T) {
+	mgr := newTestManager(t)
+	if err := mgr.AddAccount(Account{Email: "a@x", Provider: "antigravity", Source: "oauth", RefreshToken: "old"}); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr.MarkInvalid("a@x", "AUTH_INVALID")
+	if acc := mgr.PickNextByProvider("antigravity", "m"); acc != nil {
+		t.Fatalf("expected no account while invalid, got %s", acc.Email)
+	}
+	if err := mgr.UpdateOAuthCredentials("a@x", "new", ""); err != nil {
+		t.Fatal(err)
+	}
+	if acc := mgr.PickNextByProvider("antigravity", "m"); acc == nil {
+		t.Error("expected the re-authenticated account to be selectable")
+	}
+}
@@ -0,0 +1,57 @@
+package account
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// DisableAccount takes an account out of selection until EnableAccount, keeping its
+// credentials and rate limit state. Requests already in flight are left to finish.
+func (m *Manager) DisableAccount(email string) error {
+	return m.setEnabled(email, false)
+}
+
+// EnableAccount makes a disabled account selectable again.
+func (m *Manager) EnableAccount(email string) error {
+	return m.setEnabled(email, true)
+}
+
+func (m *Manager) setEnabled(email string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	idx := m.findAccountIndexLocked(email)
+	if idx < 0 {
+		return fmt.Errorf("account %s not found", email)
+	}
+	acc := &m.accounts[idx]
+	if acc.IsEnabled() == enabled {
+		return nil
+	}
+
+	previous := *acc
+	if enabled {
+		acc.Enabled, acc.DisabledAt = nil, nil
+	} else {
+		now := time.Now()
+		acc.Enabled, acc.DisabledAt = &enabled, &now
+	}
+	if err := m.saveToDiskLocked(); err != nil {
+		m.accounts[idx] = previous
+		return fmt.Errorf("failed to save account: %w", err)
+	}
+
+	if enabled {
+		utils.Success("[AccountManager] Enabled account: %s", email)
+	} else {
+		utils.Info("[AccountManager] Disabled account: %s", email)
+	}
+	return nil
+}
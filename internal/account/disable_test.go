@@ -0,0 +1,59 @@
+package account
+
+import (
+	"testing"
+)
+
+func TestDisableAccount(t *testing.T) {
+	m := newTestManager(t)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := m.AddAccount(Account{Email: email, Source: "manual", Provider: "zai", APIKey: "k-" + email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.DisableAccount("a@example.com"); err != nil {
+		t.Fatalf("DisableAccount: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if acc := m.PickNextByProvider("zai", "glm"); acc == nil || acc.Email != "b@example.com" {
+			t.Fatalf("disabled account must not be selected, got %+v", acc)
+		}
+	}
+
+	// With the other account rate-limited, the disabled one doesn't count as available.
+	m.MarkRateLimited("b@example.com", 60000, "glm")
+	if !m.IsAllRateLimitedByProvider("zai", "glm") {
+		t.Error("expected all usable accounts to be rate-limited")
+	}
+
+	// The disabled state survives a reload, with the credentials.
+	reloaded := NewManager(m.storage.ConfigPath())
+	if err := reloaded.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	acc, ok := reloaded.GetAccount("a@example.com")
+	if !ok || acc.IsEnabled() || acc.DisabledAt == nil || acc.APIKey != "k-a@example.com" {
+		t.Fatalf("unexpected disabled account after reload: %+v", acc)
+	}
+
+	if err := reloaded.EnableAccount("a@example.com"); err != nil {
+		t.Fatalf("EnableAccount: %v", err)
+	}
+	if acc, _ := reloaded.GetAccount("a@example.com"); !acc.IsEnabled() || acc.Enabled != nil || acc.DisabledAt != nil {
+		t.Errorf("unexpected enabled account: %+v", acc)
+	}
+	picked := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		if acc := reloaded.PickNextByProvider("zai", ""); acc != nil {
+			picked[acc.Email] = true
+		}
+	}
+	if !picked["a@example.com"] {
+		t.Errorf("expected the enabled account to be selected, got %v", picked)
+	}
+
+	if err := m.DisableAccount("missing@example.com"); err == nil {
+		t.Error("expected error disabling an unknown account")
+	}
+}
@@ -0,0 +1,166 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// drainPollInterval is how often WaitForDrain re-checks an account's in-flight requests.
+const drainPollInterval = 100 * time.Millisecond
+
+// DrainStatus describes an account that is (or isn't) being drained ahead of removal or re-auth.
+type DrainStatus struct {
+	Email         string     `json:"email"`
+	Draining      bool       `json:"draining"`
+	DrainingSince *time.Time `json:"drainingSince,omitempty"`
+	InFlight      int        `json:"inFlight"`
+	Idle          bool       `json:"idle"` // Draining and no requests in flight
+}
+
+// requestTracker counts upstream requests per account between BeginRequest and ReportResult.
+// Requests that never report (e.g. abandoned mid-retry) stop counting after staleInFlight.
+type requestTracker struct {
+	mu     sync.Mutex
+	starts map[string][]time.Time // email -> start times of outstanding requests
+}
+
+func newRequestTracker() *requestTracker {
+	return &requestTracker{starts: make(map[string][]time.Time)}
+}
+
+func (t *requestTracker) begin(email string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.starts[email] = append(t.starts[email], time.Now())
+}
+
+// finish ends the oldest outstanding request and returns how many remain.
+func (t *requestTracker) finish(email string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	starts := t.starts[email]
+	if len(starts) == 0 {
+		return 0
+	}
+	starts = starts[1:]
+	if len(starts) == 0 {
+		delete(t.starts, email)
+		return 0
+	}
+	t.starts[email] = starts
+	return len(starts)
+}
+
+func (t *requestTracker) count(email string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	kept := t.starts[email][:0]
+	for _, start := range t.starts[email] {
+		if now.Sub(start) < staleInFlight {
+			kept = append(kept, start)
+		}
+	}
+	if len(kept) == 0 {
+		delete(t.starts, email)
+		return 0
+	}
+	t.starts[email] = kept
+	return len(kept)
+}
+
+// BeginRequest marks the start of an upstream request for an account.
+// Providers must pair it with ReportResult once the request (or stream) ends.
+func (m *Manager) BeginRequest(email string) {
+	m.requests.begin(email)
+}
+
+// DrainAccount stops new selections of an account. Requests already in flight,
+// including open streams, are left to finish.
+func (m *Manager) DrainAccount(email string) (DrainStatus, error) {
+	m.mu.Lock()
+	if m.findAccountLocked(email) == nil {
+		m.mu.Unlock()
+		return DrainStatus{}, fmt.Errorf("account %s not found", email)
+	}
+	if _, ok := m.draining[email]; !ok {
+		m.draining[email] = time.Now()
+		utils.Info("[AccountManager] Draining account: %s", email)
+	}
+	m.mu.Unlock()
+
+	return m.GetDrainStatus(email)
+}
+
+// ResumeAccount makes a drained account selectable again.
+func (m *Manager) ResumeAccount(email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.findAccountLocked(email) == nil {
+		return fmt.Errorf("account %s not found", email)
+	}
+	if _, ok := m.draining[email]; ok {
+		delete(m.draining, email)
+		utils.Info("[AccountManager] Resumed account: %s", email)
+	}
+	return nil
+}
+
+// GetDrainStatus returns the drain state and in-flight request count of an account.
+func (m *Manager) GetDrainStatus(email string) (DrainStatus, error) {
+	m.mu.RLock()
+	found := m.findAccountLocked(email) != nil
+	since, draining := m.draining[email]
+	m.mu.RUnlock()
+
+	if !found {
+		return DrainStatus{}, fmt.Errorf("account %s not found", email)
+	}
+
+	status := DrainStatus{
+		Email:    email,
+		Draining: draining,
+		InFlight: m.requests.count(email),
+	}
+	if draining {
+		status.DrainingSince = &since
+		status.Idle = status.InFlight == 0
+	}
+	return status, nil
+}
+
+// WaitForDrain blocks until a draining account has no requests in flight or ctx is done.
+// It returns the latest status either way.
+func (m *Manager) WaitForDrain(ctx context.Context, email string) (DrainStatus, error) {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		status, err := m.GetDrainStatus(email)
+		if err != nil || !status.Draining || status.Idle {
+			return status, err
+		}
+		select {
+		case <-ctx.Done():
+			return status, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) isDrainingLocked(email string) bool {
+	_, ok := m.draining[email]
+	return ok
+}
+
+func (m *Manager) findAccountLocked(email string) *Account {
+	for i := range m.accounts {
+		if m.accounts[i].Email == email {
+			return &m.accounts[i]
+		}
+	}
+	return nil
+}
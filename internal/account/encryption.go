@@ -0,0 +1,299 @@
+package account
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// Credentials are encrypted one value at a time, so accounts.json stays readable JSON:
+// "enc:v1:" + base64(salt | nonce | AES-256-GCM ciphertext), with the key derived from
+// ACCOUNTS_ENCRYPTION_KEY and the salt by PBKDF2-SHA256. Encrypted values load like
+// secret references (see secrets.go), so they are written back unchanged when the
+// credential hasn't changed, and kept as they are when they can't be decrypted.
+const (
+	encryptedPrefix      = "enc:v1:"
+	encryptionSaltSize   = 16
+	encryptionIterations = 100_000
+)
+
+var (
+	// encryptionSalt is the salt of values encrypted by this process, so the key is
+	// derived once rather than for every value.
+	encryptionSalt = sync.OnceValues(func() ([]byte, error) {
+		salt := make([]byte, encryptionSaltSize)
+		_, err := rand.Read(salt)
+		return salt, err
+	})
+	derivedKeys sync.Map // passphrase + salt -> AES key
+)
+
+// encryptedFields are the credential fields encrypted at rest, by JSON name. The project
+// ID is an identifier, not a secret, and stays readable. So do the header overrides
+// ("headers"), which are meant for identifying headers such as User-Agent; credentials
+// belong in apiKey or refreshToken.
+var encryptedFields = []string{"refreshToken", "apiKey", "proxy"}
+
+// deriveKey returns the AES-256 key for a passphrase and salt.
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	id := passphrase + "\x00" + string(salt)
+	if key, ok := derivedKeys.Load(id); ok {
+		return key.([]byte), nil
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, encryptionIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	derivedKeys.Store(id, key)
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptSecret returns plaintext encrypted with the passphrase, as an "enc:v1:" value.
+func encryptSecret(passphrase, plaintext string) (string, error) {
+	salt, err := encryptionSalt()
+	if err != nil {
+		return "", err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out := append(append(append([]byte{}, salt...), nonce...), gcm.Seal(nil, nonce, []byte(plaintext), nil)...)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(out), nil
+}
+
+// decryptSecret returns the plaintext of an encrypted value (without its "enc:v1:" prefix).
+func decryptSecret(passphrase, encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	if len(data) < encryptionSaltSize {
+		return "", errors.New("malformed encrypted value")
+	}
+	key, err := deriveKey(passphrase, data[:encryptionSaltSize])
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	data = data[encryptionSaltSize:]
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("failed to decrypt (wrong ACCOUNTS_ENCRYPTION_KEY?)")
+	}
+	return string(plaintext), nil
+}
+
+// resolveEncrypted decrypts an encrypted value with the configured key.
+func resolveEncrypted(encoded string) (string, error) {
+	passphrase, err := config.GetAccountsEncryptionKey()
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("value is encrypted but ACCOUNTS_ENCRYPTION_KEY is not set")
+	}
+	return decryptSecret(passphrase, encoded)
+}
+
+// encryptCredentials encrypts the plaintext credentials of out, the serialized form of
+// live. The ciphertext is remembered on live like a secret reference, so later saves
+// write it back instead of encrypting the same value again.
+func encryptCredentials(live, out *Account, passphrase string) error {
+	fields := out.secretFields()
+	var refs map[string]secretRef
+	for _, name := range encryptedFields {
+		field := fields[name]
+		if *field == "" {
+			continue
+		}
+		if _, _, ok := parseSecretRef(*field); ok {
+			continue
+		}
+		encrypted, err := encryptSecret(passphrase, *field)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s of %s: %w", name, out.Email, err)
+		}
+		if refs == nil {
+			refs = maps.Clone(live.secretRefs)
+			if refs == nil {
+				refs = make(map[string]secretRef)
+			}
+		}
+		refs[name] = secretRef{ref: encrypted, resolved: *field}
+		*field = encrypted
+	}
+	if refs != nil {
+		live.secretRefs = refs
+	}
+	return nil
+}
+
+// hasPlaintextCredentials reports whether saving the account would write a credential
+// in plaintext.
+func (a *Account) hasPlaintextCredentials() bool {
+	out := serializableAccount(*a)
+	fields := out.secretFields()
+	for _, name := range encryptedFields {
+		if _, _, ok := parseSecretRef(*fields[name]); !ok && *fields[name] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutEncryption returns the account with the encrypted values it was loaded from
+// forgotten, so it serializes with its credentials in plaintext.
+func (a Account) withoutEncryption() Account {
+	a.secretRefs = maps.Clone(a.secretRefs)
+	maps.DeleteFunc(a.secretRefs, func(_ string, r secretRef) bool {
+		kind, _, _ := parseSecretRef(r.ref)
+		return kind == "enc"
+	})
+	return a
+}
+
+// undecryptable returns an error naming the first account whose encrypted credentials
+// couldn't be decrypted when loaded.
+func undecryptable(accounts []Account) error {
+	for _, acc := range accounts {
+		for name, r := range acc.secretRefs {
+			if kind, _, _ := parseSecretRef(r.ref); kind == "enc" && r.resolved == "" {
+				return fmt.Errorf("account %s: %s could not be decrypted with this key", acc.Email, name)
+			}
+		}
+	}
+	return nil
+}
+
+// EncryptCredentials rewrites the accounts file with every account's credentials
+// encrypted with ACCOUNTS_ENCRYPTION_KEY or, with decrypt, in plaintext. It returns the
+// number of accounts rewritten. Accounts whose credentials can't be decrypted with the
+// current key are an error, so a file never ends up encrypted with two keys.
+func (m *Manager) EncryptCredentials(decrypt bool) (int, error) {
+	passphrase, err := config.GetAccountsEncryptionKey()
+	if err != nil {
+		return 0, err
+	}
+	if passphrase == "" {
+		return 0, errors.New("ACCOUNTS_ENCRYPTION_KEY is not set")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if err := undecryptable(slices.Concat(m.accounts, m.archived)); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	if !decrypt {
+		for _, acc := range slices.Concat(m.accounts, m.archived) {
+			if acc.hasPlaintextCredentials() {
+				count++
+			}
+		}
+		if count == 0 {
+			return 0, nil
+		}
+		if err := m.saveToDiskLocked(); err != nil {
+			return 0, fmt.Errorf("failed to save accounts: %w", err)
+		}
+		return count, nil
+	}
+
+	prevAccounts, prevArchived := m.accounts, m.archived
+	m.accounts, m.archived = make([]Account, len(prevAccounts)), make([]Account, len(prevArchived))
+	for i, acc := range prevAccounts {
+		m.accounts[i] = acc.withoutEncryption()
+		if len(m.accounts[i].secretRefs) != len(acc.secretRefs) {
+			count++
+		}
+	}
+	for i, acc := range prevArchived {
+		m.archived[i] = acc.withoutEncryption()
+		if len(m.archived[i].secretRefs) != len(acc.secretRefs) {
+			count++
+		}
+	}
+	if count == 0 {
+		m.accounts, m.archived = prevAccounts, prevArchived
+		return 0, nil
+	}
+	if err := m.storage.save(&ConfigFile{
+		Accounts:    m.accounts,
+		Archived:    m.archived,
+		Settings:    m.settings,
+		ActiveIndex: m.currentIndex,
+	}, ""); err != nil {
+		m.accounts, m.archived = prevAccounts, prevArchived
+		return 0, fmt.Errorf("failed to save accounts: %w", err)
+	}
+	return count, nil
+}
+
+// encryptOnLoadLocked encrypts plaintext credentials right after loading when a key is
+// configured, so a plaintext file is migrated on the first start with the key set.
+func (m *Manager) encryptOnLoadLocked() {
+	passphrase, err := config.GetAccountsEncryptionKey()
+	if err != nil {
+		utils.Error("[AccountManager] %v", err)
+		return
+	}
+	if passphrase == "" {
+		return
+	}
+	count := 0
+	for _, acc := range slices.Concat(m.accounts, m.archived) {
+		if acc.hasPlaintextCredentials() {
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+
+	if err := m.saveToDiskSyncedLocked(); err != nil {
+		utils.Warn("[AccountManager] Failed to encrypt credentials: %v", err)
+		return
+	}
+	utils.Info("[AccountManager] Encrypted the plaintext credentials of %d account(s)", count)
+}
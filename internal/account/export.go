@@ -0,0 +1,219 @@
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// exportVersion is the version of the export document format.
+const exportVersion = 1
+
+// Export is an account pool as written by 'accounts export' and read by 'accounts import'.
+// Only configuration travels: rate limits, invalid state and usage stay behind.
+type Export struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Redacted   bool      `json:"redacted"` // Credentials were left out
+	Accounts   []Account `json:"accounts"`
+}
+
+// Export returns the active accounts, of one provider or all when provider is empty.
+// Unless includeSecrets is set, refresh tokens, API keys and proxy passwords are left out;
+// secret references ("${env:NAME}", "file:PATH") are kept either way, since they only
+// name the secret.
+func (m *Manager) Export(provider string, includeSecrets bool) Export {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := Export{Version: exportVersion, ExportedAt: time.Now().UTC(), Redacted: !includeSecrets, Accounts: []Account{}}
+	for _, acc := range m.accounts {
+		if provider != "" && acc.Provider != provider {
+			continue
+		}
+		// Encrypted credentials are exported decrypted, for a machine with another key.
+		acc = serializableAccount(acc.withoutEncryption())
+		acc.ModelRateLimits = nil
+		acc.LastUsed = nil
+		acc.IsInvalid, acc.InvalidReason, acc.InvalidAt = false, "", nil
+		if !includeSecrets {
+			acc.redactSecrets()
+		}
+		out.Accounts = append(out.Accounts, acc)
+	}
+	return out
+}
+
+// redactSecrets clears credentials that aren't secret references and proxy passwords.
+func (a *Account) redactSecrets() {
+	for _, field := range []*string{&a.RefreshToken, &a.APIKey} {
+		if _, _, ok := parseSecretRef(*field); !ok {
+			*field = ""
+		}
+	}
+	if _, _, ok := parseSecretRef(a.Proxy); !ok && a.Proxy != "" {
+		if u, err := url.Parse(a.Proxy); err == nil && u.User != nil {
+			u.User = url.User(u.User.Username())
+			a.Proxy = u.String()
+		}
+	}
+}
+
+// hasCredentials reports whether the account carries what its source needs to authenticate.
+func (a *Account) hasCredentials() bool {
+	if a.Source == "oauth" {
+		return a.RefreshToken != ""
+	}
+	return a.APIKey != ""
+}
+
+// ParseExport reads and validates an export document. Every problem found is reported,
+// so a file can be fixed in one go.
+func ParseExport(data []byte) (*Export, error) {
+	var file Export
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid account export: %w", err)
+	}
+	if file.Version != exportVersion {
+		return nil, fmt.Errorf("invalid account export: unsupported version %d (expected %d)", file.Version, exportVersion)
+	}
+
+	var errs []string
+	seen := make(map[string]bool, len(file.Accounts))
+	for i := range file.Accounts {
+		acc := &file.Accounts[i]
+		name := acc.Email
+		if name == "" {
+			errs = append(errs, fmt.Sprintf("account %d has no email", i+1))
+			continue
+		}
+		if seen[acc.Email] {
+			errs = append(errs, fmt.Sprintf("%s: duplicate account", name))
+		}
+		seen[acc.Email] = true
+		if acc.Provider == "" {
+			acc.Provider = "antigravity"
+		}
+		if !slices.Contains(config.BuiltinProviders, acc.Provider) {
+			errs = append(errs, fmt.Sprintf("%s: unknown provider %q", name, acc.Provider))
+		}
+		if acc.Source != "oauth" && acc.Source != "manual" {
+			errs = append(errs, fmt.Sprintf("%s: source must be oauth or manual, got %q", name, acc.Source))
+		}
+		if !acc.hasCredentials() && !file.Redacted {
+			errs = append(errs, fmt.Sprintf("%s: missing credentials", name))
+		}
+		if _, _, ref := parseSecretRef(acc.Proxy); !ref {
+			if err := acc.Egress().Validate(); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid account export:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return &file, nil
+}
+
+// ImportResult lists what ImportExport did with each account, by email.
+type ImportResult struct {
+	Added    []string `json:"added"`
+	Updated  []string `json:"updated"`
+	Skipped  []string `json:"skipped"`  // Already configured, or without credentials to add it with
+	Warnings []string `json:"warnings"` // e.g. secret references that don't resolve here
+}
+
+// ImportExport merges an export into the pool in one save. New accounts are added. Accounts
+// that already exist are left alone unless overwrite is set, in which case their
+// configuration is replaced and their credentials too when the export has them; rate
+// limits and usage are kept. Accounts an export has no credentials for can only update
+// existing ones. With dryRun, nothing is changed.
+func (m *Manager) ImportExport(file *Export, overwrite, dryRun bool) (ImportResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return ImportResult{}, err
+	}
+	defer unlock()
+
+	res := ImportResult{Added: []string{}, Updated: []string{}, Skipped: []string{}, Warnings: []string{}}
+	accounts := slices.Clone(m.accounts)
+	archived := m.archived
+	now := time.Now()
+	for _, acc := range file.Accounts {
+		acc.secretRefs = nil
+		hasCredentials := acc.hasCredentials()
+		if err := acc.resolveSecrets(); err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s: %v", acc.Email, err))
+		}
+
+		i := slices.IndexFunc(accounts, func(a Account) bool { return a.Email == acc.Email })
+		if i < 0 {
+			if !hasCredentials {
+				res.Skipped = append(res.Skipped, acc.Email)
+				continue
+			}
+			if len(accounts) >= config.MaxAccounts {
+				return ImportResult{}, fmt.Errorf("maximum number of accounts (%d) reached", config.MaxAccounts)
+			}
+			acc.ModelRateLimits = make(map[string]ModelRateLimit)
+			if acc.AddedAt == nil {
+				acc.AddedAt = &now
+			}
+			if j := slices.IndexFunc(archived, func(a Account) bool { return a.Email == acc.Email }); j >= 0 {
+				archived = append(archived[:j:j], archived[j+1:]...)
+			}
+			accounts = append(accounts, acc)
+			res.Added = append(res.Added, acc.Email)
+			continue
+		}
+		if !overwrite {
+			res.Skipped = append(res.Skipped, acc.Email)
+			continue
+		}
+
+		existing := accounts[i]
+		if !hasCredentials {
+			acc.RefreshToken, acc.APIKey = existing.RefreshToken, existing.APIKey
+			for name, ref := range existing.secretRefs {
+				if name == "refreshToken" || name == "apiKey" {
+					if acc.secretRefs == nil {
+						acc.secretRefs = make(map[string]secretRef)
+					}
+					acc.secretRefs[name] = ref
+				}
+			}
+		}
+		acc.AddedAt = existing.AddedAt
+		acc.ModelRateLimits = existing.ModelRateLimits
+		acc.LastUsed = existing.LastUsed
+		acc.IsInvalid, acc.InvalidReason, acc.InvalidAt = existing.IsInvalid, existing.InvalidReason, existing.InvalidAt
+		if !sameCredentials(existing, acc) {
+			acc.IsInvalid, acc.InvalidReason, acc.InvalidAt = false, "", nil
+			delete(m.tokenCache, acc.Email)
+			delete(m.projectCache, acc.Email)
+			delete(m.blacklisted, acc.Email)
+		}
+		accounts[i] = acc
+		res.Updated = append(res.Updated, acc.Email)
+	}
+	if dryRun || len(res.Added)+len(res.Updated) == 0 {
+		return res, nil
+	}
+
+	prevAccounts, prevArchived := m.accounts, m.archived
+	m.accounts, m.archived = accounts, archived
+	if err := m.saveToDiskLocked(); err != nil {
+		m.accounts, m.archived = prevAccounts, prevArchived
+		return ImportResult{}, fmt.Errorf("failed to save accounts: %w", err)
+	}
+	utils.Success("[AccountManager] Imported accounts: %d added, %d updated", len(res.Added), len(res.Updated))
+	return res, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package account
+
+// lockFile is a no-op where flock is unavailable; saves still detect changes made by
+// other processes, but two writers can race between the check and the write.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,179 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// Failed reports whether the result counts against the account's health.
+// Client cancellations are not the account's fault and are ignored.
+func (r Result) Failed(slowThreshold time.Duration) bool {
+	if r.RateLimited || r.Empty || r.StatusCode >= 500 {
+		return true
+	}
+	if r.Err != nil && !errors.Is(r.Err, context.Canceled) {
+		return true
+	}
+	return slowThreshold > 0 && r.Latency > slowThreshold
+}
+
+// AccountHealth is a snapshot of an account's recent request outcomes.
+type AccountHealth struct {
+	FailureScore       float64    `json:"failureScore"` // EWMA of failures, 0 (healthy) to 1
+	Samples            int        `json:"samples"`
+	AvgLatencyMs       int64      `json:"avgLatencyMs"`
+	DeprioritizedUntil *time.Time `json:"deprioritizedUntil,omitempty"`
+}
+
+type healthState struct {
+	score        float64
+	samples      int
+	avgLatency   time.Duration
+	penaltyUntil time.Time
+}
+
+// healthTracker turns request outcomes into temporary deprioritization of flaky accounts.
+// Deprioritized accounts stay selectable, but only when no healthy account is available.
+type healthTracker struct {
+	mu     sync.Mutex
+	cfg    config.AccountHealthConfig
+	states map[string]*healthState // email -> state
+}
+
+func newHealthTracker(cfg config.AccountHealthConfig) *healthTracker {
+	return &healthTracker{cfg: cfg, states: make(map[string]*healthState)}
+}
+
+func (h *healthTracker) setConfig(cfg config.AccountHealthConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cfg = cfg
+	if !cfg.Enabled {
+		h.states = make(map[string]*healthState)
+	}
+}
+
+func (h *healthTracker) record(result Result) {
+	if result.Email == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.cfg.Enabled {
+		return
+	}
+	if result.Err != nil && errors.Is(result.Err, context.Canceled) {
+		return
+	}
+
+	st := h.states[result.Email]
+	if st == nil {
+		st = &healthState{}
+		h.states[result.Email] = st
+	}
+
+	failed := 0.0
+	if result.Failed(h.cfg.SlowThreshold) {
+		failed = 1.0
+	}
+	st.score = st.score*(1-config.HealthDecay) + failed*config.HealthDecay
+	st.samples++
+	if result.Latency > 0 {
+		if st.avgLatency == 0 {
+			st.avgLatency = result.Latency
+		} else {
+			st.avgLatency = time.Duration(float64(st.avgLatency)*(1-config.HealthDecay) + float64(result.Latency)*config.HealthDecay)
+		}
+	}
+
+	now := time.Now()
+	if failed > 0 && st.samples >= config.HealthMinSamples && st.score >= h.cfg.FailureThreshold && !now.Before(st.penaltyUntil) {
+		st.penaltyUntil = now.Add(h.cfg.Cooldown)
+		utils.Warn("[AccountManager] Deprioritizing flaky account %s for %s (failure score %.2f)",
+			result.Email, utils.FormatDuration(h.cfg.Cooldown), st.score)
+	}
+}
+
+func (h *healthTracker) isDeprioritized(email string, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := h.states[email]
+	return st != nil && now.Before(st.penaltyUntil)
+}
+
+func (h *healthTracker) snapshot(email string) (AccountHealth, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := h.states[email]
+	if st == nil {
+		return AccountHealth{}, false
+	}
+	snap := AccountHealth{
+		FailureScore: st.score,
+		Samples:      st.samples,
+		AvgLatencyMs: st.avgLatency.Milliseconds(),
+	}
+	if time.Now().Before(st.penaltyUntil) {
+		until := st.penaltyUntil
+		snap.DeprioritizedUntil = &until
+	}
+	return snap, true
+}
+
+func (h *healthTracker) forget(email string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.states, email)
+}
+
+// StreamTracker observes the events of a streaming response so its outcome can be
+// reported once the stream ends. Latency is measured to the first event.
+type StreamTracker struct {
+	result     Result
+	start      time.Time
+	gotEvent   bool
+	gotContent bool
+}
+
+// NewStreamTracker starts tracking a stream served by email.
+func NewStreamTracker(email, provider, modelID string, start time.Time) *StreamTracker {
+	return &StreamTracker{
+		result: Result{Email: email, Provider: provider, ModelID: modelID},
+		start:  start,
+	}
+}
+
+// Observe records a stream event.
+func (t *StreamTracker) Observe(evt types.StreamEvent) {
+	if !t.gotEvent {
+		t.gotEvent = true
+		t.result.Latency = time.Since(t.start)
+	}
+	switch {
+	case evt.Type == "error" || evt.Error != nil:
+		if t.result.Err == nil {
+			t.result.Err = errors.New("stream error event")
+		}
+	case evt.Type == "content_block_start" || evt.Type == "content_block_delta":
+		t.gotContent = true
+	}
+}
+
+// Result returns the stream's outcome. err is the error that ended the stream, if any.
+func (t *StreamTracker) Result(err error) Result {
+	r := t.result
+	if err != nil {
+		r.Err = err
+	}
+	if !t.gotEvent {
+		r.Latency = time.Since(t.start)
+	}
+	r.Empty = r.Err == nil && !t.gotContent
+	return r
+}
@@ -0,0 +1,97 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestResult_Failed(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Result
+		slow   time.Duration
+		want   bool
+	}{
+		{"success", Result{StatusCode: 200, Latency: time.Second}, 0, false},
+		{"rate limited", Result{RateLimited: true}, 0, true},
+		{"server error", Result{StatusCode: 503}, 0, true},
+		{"empty", Result{Empty: true}, 0, true},
+		{"network error", Result{Err: errors.New("connection reset")}, 0, true},
+		{"client canceled", Result{Err: context.Canceled}, 0, false},
+		{"slow", Result{Latency: 3 * time.Second}, 2 * time.Second, true},
+		{"slow disabled", Result{Latency: 3 * time.Second}, 0, false},
+	}
+	for _, tt := range tests {
+		if got := tt.result.Failed(tt.slow); got != tt.want {
+			t.Errorf("%s: Failed() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestManager_DeprioritizesFlakyAccount(t *testing.T) {
+	mgr := newTestManager(t)
+	for _, email := range []string{"a@x", "b@x"} {
+		if err := mgr.AddAccount(Account{Email: email, Provider: "zai", Source: "manual", APIKey: "k"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mgr.SetHealthConfig(config.AccountHealthConfig{Enabled: true, FailureThreshold: 0.5, Cooldown: time.Minute})
+
+	for i := 0; i < 5; i++ {
+		mgr.ReportResult(Result{Email: "a@x", Provider: "zai", StatusCode: 500})
+	}
+	health, ok := mgr.GetAccountHealth("a@x")
+	if !ok || health.DeprioritizedUntil == nil {
+		t.Fatalf("expected a@x to be deprioritized, got %+v", health)
+	}
+
+	for i := 0; i < 4; i++ {
+		if acc := mgr.PickNextByProvider("zai", "m"); acc == nil || acc.Email != "b@x" {
+			t.Fatalf("pick %d: expected healthy b@x, got %v", i, acc)
+		}
+	}
+
+	// A flaky account is still used when nothing healthier is available.
+	mgr.MarkRateLimited("b@x", 60000, "m")
+	if acc := mgr.PickNextByProvider("zai", "m"); acc == nil || acc.Email != "a@x" {
+		t.Fatalf("expected fallback to a@x, got %v", acc)
+	}
+}
+
+func TestManager_HealthDisabled(t *testing.T) {
+	mgr := NewManager("")
+	mgr.SetHealthConfig(config.AccountHealthConfig{Enabled: false})
+	for i := 0; i < 5; i++ {
+		mgr.ReportResult(Result{Email: "a@x", RateLimited: true})
+	}
+	if _, ok := mgr.GetAccountHealth("a@x"); ok {
+		t.Error("expected no health tracking when disabled")
+	}
+}
+
+func TestStreamTracker(t *testing.T) {
+	tr := NewStreamTracker("a@x", "zai", "m", time.Now())
+	tr.Observe(types.StreamEvent{Type: "message_start"})
+	tr.Observe(types.StreamEvent{Type: "message_stop"})
+	if r := tr.Result(nil); !r.Empty || !r.Failed(0) {
+		t.Errorf("expected stream without content to be empty, got %+v", r)
+	}
+
+	tr = NewStreamTracker("a@x", "zai", "m", time.Now())
+	tr.Observe(types.StreamEvent{Type: "content_block_delta"})
+	if r := tr.Result(nil); r.Failed(0) {
+		t.Errorf("expected stream with content to succeed, got %+v", r)
+	}
+
+	tr = NewStreamTracker("a@x", "zai", "m", time.Now())
+	tr.Observe(types.StreamEvent{Type: "content_block_delta"})
+	tr.Observe(types.StreamEvent{Type: "error"})
+	if r := tr.Result(nil); !r.Failed(0) {
+		t.Errorf("expected stream with error event to fail, got %+v", r)
+	}
+}
@@ -18,7 +18,7 @@ func IsAllRateLimited(accounts []Account, modelID string) bool {
 
 	now := time.Now().UnixMilli()
 	for _, acc := range accounts {
-		if acc.IsInvalid {
+		if acc.IsInvalid || !acc.IsEnabled() {
 			continue // Invalid accounts count as unavailable
 		}
 		limit, ok := acc.ModelRateLimits[modelID]
@@ -29,13 +29,13 @@ func IsAllRateLimited(accounts []Account, modelID string) bool {
 	return true
 }
 
-// GetAvailableAccounts returns accounts that are not rate-limited or invalid for a model.
+// GetAvailableAccounts returns accounts that are not rate-limited, invalid or disabled for a model.
 func GetAvailableAccounts(accounts []Account, modelID string) []Account {
 	available := make([]Account, 0)
 	now := time.Now().UnixMilli()
 
 	for _, acc := range accounts {
-		if acc.IsInvalid {
+		if acc.IsInvalid || !acc.IsEnabled() {
 			continue
 		}
 
@@ -105,20 +105,25 @@ func ResetAllRateLimits(accounts []Account) {
 	utils.Warn("[AccountManager] Reset all rate limits for optimistic retry")
 }
 
+// cooldownMs returns how long a rate limit lasts: resetMs when the upstream said, else the
+// configured or default cooldown.
+func cooldownMs(resetMs int64, settings Settings) int64 {
+	if resetMs != 0 {
+		return resetMs
+	}
+	if settings.CooldownDurationMs > 0 {
+		return settings.CooldownDurationMs
+	}
+	return int64(config.DefaultCooldownDuration / time.Millisecond)
+}
+
 // MarkRateLimited marks an account as rate-limited for a specific model.
 // Returns true if the account was found and marked.
 // Preserves soft limit status and quota remaining values.
 func MarkRateLimited(accounts []Account, email string, resetMs int64, settings Settings, modelID string) bool {
 	for i := range accounts {
 		if accounts[i].Email == email {
-			cooldownMs := resetMs
-			if cooldownMs == 0 {
-				if settings.CooldownDurationMs > 0 {
-					cooldownMs = settings.CooldownDurationMs
-				} else {
-					cooldownMs = int64(config.DefaultCooldownDuration / time.Millisecond)
-				}
-			}
+			cooldownMs := cooldownMs(resetMs, settings)
 
 			resetTime := time.Now().UnixMilli() + cooldownMs
 
@@ -273,7 +278,7 @@ func GetSoftLimitedCount(accounts []Account, modelID string, settings Settings)
 
 	count := 0
 	for _, acc := range accounts {
-		if acc.IsInvalid {
+		if acc.IsInvalid || !acc.IsEnabled() {
 			continue
 		}
 		if modelID != "" {
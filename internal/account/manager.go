@@ -1,13 +1,17 @@
 package account
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/auth"
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/notify"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
@@ -29,10 +33,28 @@ type Manager struct {
 	settings               Settings
 	storage                *Storage
 	initialized            bool
+	balancer               LoadBalancer
+	health                 *healthTracker
+	sticky                 *stickyErrorTracker
+	requests               *requestTracker
+	draining               map[string]time.Time    // email -> drain start; excluded from selection
+	blacklisted            map[string]time.Time    // email -> end of the post-invalidation window; excluded from selection
+	maxInFlightPerAccount  int                     // 0 = unlimited; accounts at the cap are skipped
+	archived               []Account               // Soft-deleted accounts, never selected
+	notifier               *notify.Notifier        // Receives account state changes; nil disables
+	overBudget             func(email string) bool // Accounts it reports are excluded from selection; nil disables
 
 	// Per-account caches
 	tokenCache   map[string]TokenCacheEntry // email -> token entry
 	projectCache map[string]string          // email -> projectId
+
+	projectLimits      map[projectLimitKey]int64 // Reset times (Unix ms) of rate-limited projects of multi-project accounts
+	revalidateAttempts map[string]int            // email -> re-validation attempts since it became invalid
+
+	// Saves running in the background, for Flush.
+	savesMu   sync.Mutex
+	saving    int
+	savesIdle chan struct{} // Closed when saving drops to 0
 }
 
 // NewManager creates a new AccountManager.
@@ -41,10 +63,114 @@ func NewManager(configPath string) *Manager {
 		storage:                NewStorage(configPath),
 		tokenCache:             make(map[string]TokenCacheEntry),
 		projectCache:           make(map[string]string),
+		projectLimits:          make(map[projectLimitKey]int64),
+		revalidateAttempts:     make(map[string]int),
 		currentIndexByProvider: make(map[string]int),
+		balancer:               NewRoundRobinBalancer(),
+		requests:               newRequestTracker(),
+		draining:               make(map[string]time.Time),
+		blacklisted:            make(map[string]time.Time),
+		health: newHealthTracker(config.AccountHealthConfig{
+			Enabled:          true,
+			FailureThreshold: config.DefaultHealthFailureThreshold,
+			Cooldown:         config.DefaultHealthCooldown,
+		}),
+		sticky: newStickyErrorTracker(config.StickyErrorConfig{
+			TTL:       config.DefaultStickyErrorTTL,
+			Threshold: config.DefaultStickyErrorThreshold,
+		}),
 	}
 }
 
+// SetBackupSource lets Initialize recover from a corrupt accounts file using the newest
+// valid backup among those list returns (newest first), instead of starting without accounts.
+func (m *Manager) SetBackupSource(list func() ([]string, error)) {
+	m.storage.SetBackupSource(list)
+}
+
+// SetLoadBalancer replaces the account selection strategy. A nil balancer restores round-robin.
+func (m *Manager) SetLoadBalancer(lb LoadBalancer) {
+	if lb == nil {
+		lb = NewRoundRobinBalancer()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.balancer = lb
+}
+
+// SetMaxConcurrentPerAccount caps in-flight requests per account (0 disables the cap).
+// Accounts at the cap are skipped during selection until a request finishes.
+func (m *Manager) SetMaxConcurrentPerAccount(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxInFlightPerAccount = n
+}
+
+// SetNotifier sets where account state changes (invalid, rate-limited, soft-limited,
+// recovered, provider exhausted) are sent. A nil notifier disables notifications.
+func (m *Manager) SetNotifier(n *notify.Notifier) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifier = n
+}
+
+// SetBudgetCheck takes accounts that have used up their budget out of selection until
+// overBudget stops reporting them. A nil func disables the check. overBudget is called with
+// the manager locked and must not call back into it.
+func (m *Manager) SetBudgetCheck(overBudget func(email string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.overBudget = overBudget
+}
+
+// SetHealthConfig configures how reported results deprioritize flaky accounts.
+func (m *Manager) SetHealthConfig(cfg config.AccountHealthConfig) {
+	m.health.setConfig(cfg)
+}
+
+// SetStickyErrorConfig configures how repeated deterministic failures take an account out
+// of selection for a model.
+func (m *Manager) SetStickyErrorConfig(cfg config.StickyErrorConfig) {
+	m.sticky.setConfig(cfg)
+}
+
+// ReportResult feeds a request outcome into account health tracking, the sticky error
+// cache and the load balancer. Providers call it once per upstream attempt.
+func (m *Manager) ReportResult(result Result) {
+	m.health.record(result)
+	m.sticky.record(result, time.Now())
+	if remaining := m.requests.finish(result.Email); remaining == 0 {
+		m.mu.RLock()
+		draining := m.isDrainingLocked(result.Email)
+		m.mu.RUnlock()
+		if draining {
+			utils.Success("[AccountManager] Account %s is drained (no requests in flight)", result.Email)
+		}
+	}
+
+	m.mu.RLock()
+	lb := m.balancer
+	m.mu.RUnlock()
+	lb.Feedback(result)
+}
+
+// GetAccountHealth returns the recent outcome summary for an account, if any results were reported.
+func (m *Manager) GetAccountHealth(email string) (AccountHealth, bool) {
+	return m.health.snapshot(email)
+}
+
+// GetStickyErrors returns the account/model pairs currently skipped because of repeated
+// deterministic failures.
+func (m *Manager) GetStickyErrors() []StickyError {
+	return m.sticky.active(time.Now())
+}
+
+// ClearStickyErrors makes skipped account/model pairs selectable again, for one account or
+// all accounts when email is empty. It returns how many pairs were cleared.
+func (m *Manager) ClearStickyErrors(email string) int {
+	return m.sticky.forget(email, time.Now())
+}
+
 // Initialize loads the account configuration.
 func (m *Manager) Initialize() error {
 	m.mu.Lock()
@@ -60,6 +186,7 @@ func (m *Manager) Initialize() error {
 	}
 
 	m.accounts = cfg.Accounts
+	m.archived = cfg.Archived
 	m.settings = cfg.Settings
 	m.currentIndex = cfg.ActiveIndex
 	// Backwards-compat: ActiveIndex historically tracked Antigravity selection.
@@ -67,6 +194,7 @@ func (m *Manager) Initialize() error {
 
 	// Clear any expired rate limits
 	m.clearExpiredLimitsLocked()
+	m.encryptOnLoadLocked()
 
 	m.initialized = true
 	return nil
@@ -113,7 +241,7 @@ func (m *Manager) IsAllRateLimitedByProvider(provider, modelID string) bool {
 			continue
 		}
 		count++
-		if acc.IsInvalid {
+		if acc.IsInvalid || !acc.IsEnabled() {
 			continue
 		}
 		limit, ok := acc.ModelRateLimits[modelID]
@@ -128,6 +256,42 @@ func (m *Manager) IsAllRateLimitedByProvider(provider, modelID string) bool {
 	return true
 }
 
+// IsAllSoftLimitedByProvider reports whether a provider has accounts usable for a model but
+// all of them are soft-limited, so serving it would spend the last of their quota.
+func (m *Manager) IsAllSoftLimitedByProvider(provider, modelID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.settings.SoftLimitEnabled || modelID == "" {
+		return false
+	}
+	usable := false
+	for i := range m.accounts {
+		acc := &m.accounts[i]
+		if acc.Provider != provider || !m.isAccountUsableForModelLocked(acc, modelID) {
+			continue
+		}
+		if m.isAccountPreferredForModelLocked(acc, modelID) {
+			return false
+		}
+		usable = true
+	}
+	return usable
+}
+
+// HasPreferredAccountByProvider reports whether a provider has an account usable for a
+// model that is not soft-limited.
+func (m *Manager) HasPreferredAccountByProvider(provider, modelID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := range m.accounts {
+		acc := &m.accounts[i]
+		if acc.Provider == provider && m.isAccountPreferredForModelLocked(acc, modelID) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetAvailableAccounts returns non-rate-limited accounts.
 func (m *Manager) GetAvailableAccounts(modelID string) []Account {
 	m.mu.RLock()
@@ -150,9 +314,28 @@ func (m *Manager) ClearExpiredLimits() int {
 }
 
 func (m *Manager) clearExpiredLimitsLocked() int {
+	now := time.Now().UnixMilli()
+	var recovered []notify.Event
+	for _, acc := range m.accounts {
+		for modelID, limit := range acc.ModelRateLimits {
+			if limit.IsRateLimited && limit.ResetTime <= now {
+				recovered = append(recovered, notify.Event{Type: notify.EventAccountRecovered, Email: acc.Email, Provider: acc.Provider, Model: modelID})
+			}
+		}
+	}
+
+	for key, resetTime := range m.projectLimits {
+		if resetTime <= now {
+			delete(m.projectLimits, key)
+		}
+	}
+
 	cleared := ClearExpiredLimits(m.accounts)
+	for _, e := range recovered {
+		m.notifier.Notify(e)
+	}
 	if cleared > 0 {
-		go m.saveToDiskAsync()
+		m.saveInBackground()
 	}
 	return cleared
 }
@@ -162,6 +345,7 @@ func (m *Manager) ResetAllRateLimits() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	ResetAllRateLimits(m.accounts)
+	clear(m.projectLimits)
 }
 
 // PickNext picks the next available account.
@@ -169,9 +353,15 @@ func (m *Manager) PickNext(modelID string) *Account {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	result := PickNextWithSettings(m.accounts, m.currentIndex, modelID, m.settings, func() { go m.saveToDiskAsync() })
-	m.currentIndex = result.NewIndex
-	return result.Account
+	now := time.Now()
+	for range m.accounts {
+		result := PickNextWithSettings(m.accounts, m.currentIndex, modelID, m.settings, func() { m.saveInBackground() })
+		m.currentIndex = result.NewIndex
+		if result.Account == nil || !m.isBlacklistedLocked(result.Account.Email, now) {
+			return result.Account
+		}
+	}
+	return nil
 }
 
 // MarkRateLimited marks an account as rate-limited.
@@ -179,8 +369,26 @@ func (m *Manager) MarkRateLimited(email string, resetMs int64, modelID string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	idx := m.findAccountIndexLocked(email)
+	if idx < 0 {
+		MarkRateLimited(m.accounts, email, resetMs, m.settings, modelID)
+		m.saveInBackground()
+		return
+	}
+	provider := m.accounts[idx].Provider
+	prev := m.accounts[idx].ModelRateLimits[modelID]
+	wasLimited := prev.IsRateLimited && prev.ResetTime > time.Now().UnixMilli()
+	wasExhausted := m.providerExhaustedLocked(provider, modelID)
+
 	MarkRateLimited(m.accounts, email, resetMs, m.settings, modelID)
-	go m.saveToDiskAsync()
+	m.saveInBackground()
+
+	if !wasLimited {
+		m.notifier.Notify(notify.Event{Type: notify.EventAccountRateLimited, Email: email, Provider: provider, Model: modelID})
+	}
+	if !wasExhausted && m.providerExhaustedLocked(provider, modelID) {
+		m.notifier.Notify(notify.Event{Type: notify.EventProviderExhausted, Provider: provider, Model: modelID})
+	}
 }
 
 // MarkInvalid marks an account as invalid.
@@ -188,8 +396,30 @@ func (m *Manager) MarkInvalid(email string, reason string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.markInvalidLocked(email, reason)
+	m.saveInBackground()
+}
+
+// markInvalidLocked marks an account invalid and notifies on the transition.
+func (m *Manager) markInvalidLocked(email, reason string) {
+	idx := m.findAccountIndexLocked(email)
+	if idx < 0 {
+		MarkInvalid(m.accounts, email, reason)
+		return
+	}
+	provider := m.accounts[idx].Provider
+	wasInvalid := m.accounts[idx].IsInvalid
+	wasExhausted := m.providerExhaustedLocked(provider, "")
+
 	MarkInvalid(m.accounts, email, reason)
-	go m.saveToDiskAsync()
+	m.blacklistLocked(email, time.Now())
+
+	if !wasInvalid {
+		m.notifier.Notify(notify.Event{Type: notify.EventAccountInvalid, Email: email, Provider: provider, Reason: reason})
+	}
+	if !wasExhausted && m.providerExhaustedLocked(provider, "") {
+		m.notifier.Notify(notify.Event{Type: notify.EventProviderExhausted, Provider: provider, Reason: "all accounts invalid"})
+	}
 }
 
 // GetMinWaitTimeMs returns the minimum wait time until any account is available.
@@ -245,7 +475,7 @@ func (m *Manager) PickNextByProvider(provider, modelID string) *Account {
 		return nil
 	}
 
-	return m.pickNextByProviderLocked(provider, modelID)
+	return m.pickNextByProviderLocked(provider, modelID, nil, "", softLimitPrefer)
 }
 
 func (m *Manager) getAccountCountByProviderLocked(provider string) int {
@@ -283,7 +513,10 @@ func (m *Manager) ensureProviderIndexLocked(provider string) int {
 }
 
 func (m *Manager) isAccountUsableForModelLocked(acc *Account, modelID string) bool {
-	if acc == nil || acc.IsInvalid {
+	if acc == nil || acc.IsInvalid || !acc.IsEnabled() || m.isDrainingLocked(acc.Email) || m.isBlacklistedLocked(acc.Email, time.Now()) {
+		return false
+	}
+	if m.overBudget != nil && m.overBudget(acc.Email) {
 		return false
 	}
 	if modelID == "" {
@@ -318,59 +551,90 @@ func (m *Manager) isAccountPreferredForModelLocked(acc *Account, modelID string)
 	return true
 }
 
-func (m *Manager) pickNextByProviderLocked(provider, modelID string) *Account {
+// pickNextByProviderLocked picks the next account. Accounts in avoid are only picked when
+// no other account is usable. The account prefer is picked over the balancer's choice when
+// it is usable and preferred. softLimits sets how soft-limited accounts are treated.
+func (m *Manager) pickNextByProviderLocked(provider, modelID string, avoid map[string]bool, prefer string, softLimits softLimitMode) *Account {
 	start := m.ensureProviderIndexLocked(provider)
 	if start < 0 {
 		return nil
 	}
 
-	// First pass: try preferred (non-soft-limited) accounts.
-	if m.settings.SoftLimitEnabled {
-		for i := 1; i <= len(m.accounts); i++ {
-			idx := (start + i) % len(m.accounts)
-			acc := &m.accounts[idx]
-			if acc.Provider != provider {
-				continue
-			}
-			if m.isAccountPreferredForModelLocked(acc, modelID) {
-				now := time.Now()
-				acc.LastUsed = &now
-				m.currentIndexByProvider[provider] = idx
-				if provider == "antigravity" {
-					m.currentIndex = idx
-				}
-				go m.saveToDiskAsync()
-				utils.Info("[AccountManager] Using preferred account: %s", acc.Email)
-				return acc
-			}
+	now := time.Now()
+	candidates := make([]Candidate, 0, len(m.accounts))
+	var sticky, avoided []Candidate
+	for i := range m.accounts {
+		acc := &m.accounts[i]
+		if acc.Provider != provider || !m.isAccountUsableForModelLocked(acc, modelID) {
+			continue
 		}
-	}
-
-	// Second pass: any usable account (including soft-limited).
-	for i := 1; i <= len(m.accounts); i++ {
-		idx := (start + i) % len(m.accounts)
-		acc := &m.accounts[idx]
-		if acc.Provider != provider {
+		inFlight := m.requests.count(acc.Email)
+		if m.maxInFlightPerAccount > 0 && inFlight >= m.maxInFlightPerAccount {
 			continue
 		}
-		if m.isAccountUsableForModelLocked(acc, modelID) {
-			now := time.Now()
-			acc.LastUsed = &now
-			m.currentIndexByProvider[provider] = idx
-			if provider == "antigravity" {
-				m.currentIndex = idx
-			}
-			go m.saveToDiskAsync()
-			if m.settings.SoftLimitEnabled {
-				utils.Warn("[AccountManager] Using soft-limited account: %s - no preferred accounts available", acc.Email)
-			} else {
-				utils.Info("[AccountManager] Using account: %s", acc.Email)
-			}
-			return acc
+		preferred := m.isAccountPreferredForModelLocked(acc, modelID)
+		if !preferred && softLimits == softLimitExclude {
+			continue
 		}
+		candidate := Candidate{
+			Index:   i,
+			Account: *acc,
+			// Flaky accounts are only used when nothing healthier is available.
+			Preferred: (preferred || softLimits == softLimitInclude) && !m.health.isDeprioritized(acc.Email, now),
+			InFlight:  inFlight,
+		}
+		if avoid[acc.Email] {
+			avoided = append(avoided, candidate)
+			continue
+		}
+		if modelID != "" && m.sticky.isSticky(acc.Email, modelID, now) {
+			sticky = append(sticky, candidate)
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+	if len(candidates) == 0 {
+		// Every usable account keeps failing for this model: let the request through so
+		// the caller sees the upstream error instead of "no accounts available".
+		candidates = sticky
+	}
+	if len(candidates) == 0 {
+		candidates = avoided
+	}
+	if len(candidates) == 0 {
+		return nil
 	}
 
-	return nil
+	choice := -1
+	if prefer != "" {
+		choice = slices.IndexFunc(candidates, func(c Candidate) bool { return c.Account.Email == prefer && c.Preferred })
+	}
+	if choice < 0 {
+		choice = m.balancer.PickNext(provider, modelID, candidates, start)
+	}
+	if choice < 0 || choice >= len(candidates) {
+		return nil
+	}
+	picked := candidates[choice]
+
+	idx := picked.Index
+	acc := &m.accounts[idx]
+	acc.LastUsed = &now
+	m.currentIndexByProvider[provider] = idx
+	if provider == "antigravity" {
+		m.currentIndex = idx
+	}
+	m.saveInBackground()
+
+	switch {
+	case !m.settings.SoftLimitEnabled:
+		utils.Info("[AccountManager] Using account: %s", acc.Email)
+	case picked.Preferred:
+		utils.Info("[AccountManager] Using preferred account: %s", acc.Email)
+	default:
+		utils.Warn("[AccountManager] Using soft-limited or flaky account: %s - no preferred accounts available", acc.Email)
+	}
+	return acc
 }
 
 func (m *Manager) isAllRateLimitedByProviderLocked(provider, modelID string) bool {
@@ -384,7 +648,7 @@ func (m *Manager) isAllRateLimitedByProviderLocked(provider, modelID string) boo
 			continue
 		}
 		count++
-		if acc.IsInvalid {
+		if acc.IsInvalid || !acc.IsEnabled() {
 			continue
 		}
 		limit, ok := acc.ModelRateLimits[modelID]
@@ -395,6 +659,25 @@ func (m *Manager) isAllRateLimitedByProviderLocked(provider, modelID string) boo
 	return count > 0
 }
 
+// providerExhaustedLocked reports whether no account of a provider can serve modelID.
+// An empty modelID only considers invalid and disabled accounts.
+func (m *Manager) providerExhaustedLocked(provider, modelID string) bool {
+	if modelID != "" {
+		return m.isAllRateLimitedByProviderLocked(provider, modelID)
+	}
+	count := 0
+	for _, acc := range m.accounts {
+		if acc.Provider != provider {
+			continue
+		}
+		count++
+		if !acc.IsInvalid && acc.IsEnabled() {
+			return false
+		}
+	}
+	return count > 0
+}
+
 // ResetAllRateLimitsByProvider clears all rate limits for a specific provider (optimistic retry).
 func (m *Manager) ResetAllRateLimitsByProvider(provider string) {
 	m.mu.Lock()
@@ -412,6 +695,11 @@ func (m *Manager) ResetAllRateLimitsByProvider(provider string) {
 			}
 		}
 	}
+	for key := range m.projectLimits {
+		if idx := m.findAccountIndexLocked(key.email); idx < 0 || m.accounts[idx].Provider == provider {
+			delete(m.projectLimits, key)
+		}
+	}
 	utils.Warn("[AccountManager] Reset all rate limits for provider %s (optimistic retry)", provider)
 }
 
@@ -434,15 +722,21 @@ func (m *Manager) GetTokenForAccount(account *Account) (string, error) {
 		if account.RefreshToken == "" {
 			return "", fmt.Errorf("no refresh token for OAuth account")
 		}
-		tokens, err := auth.RefreshAccessToken(account.RefreshToken)
+		ctx, cancel := tokenFetchContext(account)
+		tokens, err := auth.RefreshAccessToken(ctx, account.RefreshToken)
+		cancel()
 		if err != nil {
+			// A refresh cut short by TOKEN_FETCH_TIMEOUT says nothing about the account.
+			if te := merrors.TimeoutCause(ctx); te != nil {
+				return "", te
+			}
 			// Check if it's a network error (shouldn't mark invalid)
 			if isNetworkError(err) {
 				return "", fmt.Errorf("AUTH_NETWORK_ERROR: %v", err)
 			}
 			// Mark as invalid
-			MarkInvalid(m.accounts, account.Email, err.Error())
-			go m.saveToDiskAsync()
+			m.markInvalidLocked(account.Email, err.Error())
+			m.saveInBackground()
 			return "", fmt.Errorf("AUTH_INVALID: %s: %v", account.Email, err)
 		}
 		token = tokens.AccessToken
@@ -450,7 +744,8 @@ func (m *Manager) GetTokenForAccount(account *Account) (string, error) {
 		if account.IsInvalid {
 			account.IsInvalid = false
 			account.InvalidReason = ""
-			go m.saveToDiskAsync()
+			m.saveInBackground()
+			m.notifier.Notify(notify.Event{Type: notify.EventAccountRecovered, Email: account.Email, Provider: account.Provider})
 		}
 		utils.Success("[AccountManager] Refreshed OAuth token for: %s", account.Email)
 
@@ -473,6 +768,13 @@ func (m *Manager) GetTokenForAccount(account *Account) (string, error) {
 	return token, nil
 }
 
+// tokenFetchContext returns the context of a token fetch for an account, bounded by the
+// provider's TOKEN_FETCH_TIMEOUT.
+func tokenFetchContext(account *Account) (context.Context, context.CancelFunc) {
+	timeout := config.GetRequestTimeouts(account.Provider).TokenFetch
+	return merrors.WithTimeout(account.RequestContext(context.Background()), merrors.PhaseTokenFetch, timeout)
+}
+
 // GetProjectForAccount gets the project ID for an account.
 func (m *Manager) GetProjectForAccount(account *Account, token string) (string, error) {
 	m.mu.Lock()
@@ -490,7 +792,9 @@ func (m *Manager) GetProjectForAccount(account *Account, token string) (string,
 	}
 
 	// Discover project via loadCodeAssist API
-	projectID, err := auth.DiscoverProjectID(token)
+	ctx, cancel := tokenFetchContext(account)
+	projectID, err := auth.DiscoverProjectID(ctx, token)
+	cancel()
 	if err != nil {
 		utils.Warn("[AccountManager] Project discovery failed, using default: %v", err)
 		projectID = config.DefaultProjectID
@@ -524,17 +828,20 @@ func (m *Manager) ClearTokenCache(email string) {
 	}
 }
 
-// SaveToDisk saves the current state to disk.
+// SaveToDisk saves the current state to disk, first applying changes other processes saved
+// (see SyncFromDisk).
 func (m *Manager) SaveToDisk() error {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return m.saveToDiskLocked()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.saveToDiskSyncedLocked()
 }
 
-// saveToDiskLocked saves without acquiring the lock (caller must hold lock).
+// saveToDiskLocked saves without acquiring the lock (caller must hold lock). Mutations
+// call lockStorageLocked first so they don't overwrite changes made by other processes.
 func (m *Manager) saveToDiskLocked() error {
 	cfg := &ConfigFile{
 		Accounts:    m.accounts,
+		Archived:    m.archived,
 		Settings:    m.settings,
 		ActiveIndex: m.currentIndex,
 	}
@@ -542,9 +849,36 @@ func (m *Manager) saveToDiskLocked() error {
 	return m.storage.Save(cfg)
 }
 
-func (m *Manager) saveToDiskAsync() {
-	if err := m.SaveToDisk(); err != nil {
-		utils.Error("[AccountManager] Failed to save config: %v", err)
+// saveInBackground saves the current state to disk without waiting for it.
+func (m *Manager) saveInBackground() {
+	m.savesMu.Lock()
+	if m.saving == 0 {
+		m.savesIdle = make(chan struct{})
+	}
+	m.saving++
+	m.savesMu.Unlock()
+
+	go func() {
+		if err := m.SaveToDisk(); err != nil {
+			utils.Error("[AccountManager] Failed to save config: %v", err)
+		}
+		m.savesMu.Lock()
+		m.saving--
+		if m.saving == 0 {
+			close(m.savesIdle)
+		}
+		m.savesMu.Unlock()
+	}()
+}
+
+// Flush waits until the saves running in the background have finished, e.g. before the
+// process exits.
+func (m *Manager) Flush() {
+	m.savesMu.Lock()
+	idle, saving := m.savesIdle, m.saving
+	m.savesMu.Unlock()
+	if saving > 0 {
+		<-idle
 	}
 }
 
@@ -576,8 +910,12 @@ func (m *Manager) GetStatus() map[string]interface{} {
 			"modelRateLimits": acc.ModelRateLimits,
 			"isInvalid":       acc.IsInvalid,
 			"invalidReason":   acc.InvalidReason,
+			"enabled":         acc.IsEnabled(),
 			"lastUsed":        acc.LastUsed,
 		}
+		if health, ok := m.health.snapshot(acc.Email); ok {
+			accountsInfo[i]["health"] = health
+		}
 	}
 
 	return map[string]interface{}{
@@ -621,7 +959,6 @@ func (m *Manager) GetAccountStatuses() []types.AccountStatus {
 			status.Status = "invalid"
 			status.Error = string(acc.InvalidReason)
 		}
-
 		// Initialize all models with 100% remaining
 		for _, modelID := range supportedModels {
 			status.Limits[modelID] = types.ModelQuota{
@@ -643,6 +980,9 @@ func (m *Manager) GetAccountStatuses() []types.AccountStatus {
 				status.Status = "rate-limited"
 			}
 		}
+		if !acc.IsEnabled() {
+			status.Status = "disabled"
+		}
 
 		statuses[i] = status
 	}
@@ -720,6 +1060,20 @@ func (m *Manager) updateSoftLimitStatusInternal(email string, modelID string, re
 
 		m.accounts[i].ModelRateLimits[modelID] = limit
 
+		if limit.IsSoftLimited != oldSoftLimited {
+			event := notify.Event{
+				Type:     notify.EventAccountSoftLimited,
+				Email:    email,
+				Provider: m.accounts[i].Provider,
+				Model:    modelID,
+				Reason:   fmt.Sprintf("%.0f%% remaining", remainingFraction*100),
+			}
+			if !limit.IsSoftLimited {
+				event.Type = notify.EventAccountRecovered
+			}
+			m.notifier.Notify(event)
+		}
+
 		// Log and persist on ANY state transition (into OR out of soft-limited)
 		if persist && limit.IsSoftLimited != oldSoftLimited {
 			if limit.IsSoftLimited {
@@ -729,12 +1083,50 @@ func (m *Manager) updateSoftLimitStatusInternal(email string, modelID string, re
 				utils.Info("[AccountManager] Account %s is no longer soft-limited for %s (%.0f%% remaining)",
 					email, modelID, remainingFraction*100)
 			}
-			go m.saveToDiskAsync()
+			m.saveInBackground()
 		}
 		return
 	}
 }
 
+// SetQuotaBaselines records the remaining quota fraction of an account per model, e.g.
+// the quotas probed right after the account was added, and saves them to disk.
+// Fractions are clamped to [0, 1]; soft limits are applied when enabled.
+func (m *Manager) SetQuotaBaselines(email string, remaining map[string]float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	idx := m.findAccountIndexLocked(email)
+	if idx < 0 {
+		return fmt.Errorf("account %s not found", email)
+	}
+	acc := &m.accounts[idx]
+	if acc.ModelRateLimits == nil {
+		acc.ModelRateLimits = make(map[string]ModelRateLimit)
+	}
+	for modelID, fraction := range remaining {
+		if math.IsNaN(fraction) || fraction < 0 {
+			fraction = 0
+		} else if fraction > 1 {
+			fraction = 1
+		}
+		limit := acc.ModelRateLimits[modelID]
+		limit.QuotaRemaining = fraction
+		limit.IsSoftLimited = m.settings.SoftLimitEnabled && fraction < m.settings.SoftLimitThreshold
+		acc.ModelRateLimits[modelID] = limit
+	}
+
+	if err := m.saveToDiskLocked(); err != nil {
+		return fmt.Errorf("failed to save quota baselines: %w", err)
+	}
+	return nil
+}
+
 // IsSoftLimited checks if an account is soft-limited for a specific model.
 func (m *Manager) IsSoftLimited(email string, modelID string) bool {
 	m.mu.RLock()
@@ -777,6 +1169,16 @@ func (m *Manager) GetPreferredAccounts(modelID string) []Account {
 	return GetPreferredAccounts(m.accounts, modelID, m.settings)
 }
 
+// GetAccount returns a copy of an active (not archived) account.
+func (m *Manager) GetAccount(email string) (Account, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if acc := m.findAccountLocked(email); acc != nil {
+		return *acc, true
+	}
+	return Account{}, false
+}
+
 // GetAllAccounts returns all accounts (for quota fetching).
 func (m *Manager) GetAllAccounts() []Account {
 	m.mu.RLock()
@@ -860,6 +1262,11 @@ func (m *Manager) GetAllAccountsByProvider(provider string) []Account {
 func (m *Manager) AddAccount(account Account) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Check for duplicate
 	for _, acc := range m.accounts {
@@ -881,12 +1288,19 @@ func (m *Manager) AddAccount(account Account) error {
 	now := time.Now()
 	account.AddedAt = &now
 
+	// Re-adding an archived account supersedes the archived copy.
+	archived := m.archived
+	if i := m.findArchivedIndexLocked(account.Email); i >= 0 {
+		m.archived = append(m.archived[:i:i], m.archived[i+1:]...)
+	}
+
 	m.accounts = append(m.accounts, account)
 
 	// Save synchronously for CLI commands (async would exit before write completes)
 	if err := m.saveToDiskLocked(); err != nil {
 		// Remove the account we just added since save failed
 		m.accounts = m.accounts[:len(m.accounts)-1]
+		m.archived = archived
 		return fmt.Errorf("failed to save account: %w", err)
 	}
 
@@ -894,51 +1308,129 @@ func (m *Manager) AddAccount(account Account) error {
 	return nil
 }
 
-// RemoveAccount removes an account from the pool.
+// UpdateOAuthCredentials replaces the refresh token (and project, when set) of an existing
+// OAuth account after re-authentication, clearing its invalid state and cached tokens.
+func (m *Manager) UpdateOAuthCredentials(email, refreshToken, projectID string) error {
+	if refreshToken == "" {
+		return fmt.Errorf("no refresh token for %s", email)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	idx := m.findAccountIndexLocked(email)
+	if idx < 0 {
+		return fmt.Errorf("account %s not found", email)
+	}
+	acc := &m.accounts[idx]
+	if acc.Source != "oauth" {
+		return fmt.Errorf("account %s does not use OAuth", email)
+	}
+
+	previous := *acc
+	acc.RefreshToken = refreshToken
+	if projectID != "" {
+		acc.ProjectID = projectID
+	}
+	acc.IsInvalid = false
+	acc.InvalidReason = ""
+	acc.InvalidAt = nil
+
+	if err := m.saveToDiskLocked(); err != nil {
+		m.accounts[idx] = previous
+		return fmt.Errorf("failed to save account: %w", err)
+	}
+
+	delete(m.tokenCache, email)
+	delete(m.projectCache, email)
+	delete(m.blacklisted, email)
+	if previous.IsInvalid {
+		m.notifier.Notify(notify.Event{Type: notify.EventAccountRecovered, Email: email, Provider: acc.Provider, Reason: "re-authenticated"})
+	}
+	utils.Success("[AccountManager] Updated OAuth credentials for: %s", email)
+	return nil
+}
+
+// RemoveAccount soft-deletes an account: it is archived with its credentials and never
+// selected again until restored with RestoreAccount. Use PurgeAccount to delete it permanently.
 func (m *Manager) RemoveAccount(email string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	for i, acc := range m.accounts {
-		if acc.Email == email {
-			removed := acc
-			removedIdx := i
-			m.accounts = append(m.accounts[:i], m.accounts[i+1:]...)
+	idx := m.findAccountIndexLocked(email)
+	if idx < 0 {
+		return fmt.Errorf("account %s not found", email)
+	}
 
-			// Clear caches
-			delete(m.tokenCache, email)
-			delete(m.projectCache, email)
+	accounts, archived := m.accounts, m.archived
+	removed := m.detachAccountLocked(idx)
+	now := time.Now()
+	removed.ArchivedAt = &now
+	m.archived = append(m.archived, removed)
 
-			// Adjust current index if needed
-			if m.currentIndex >= len(m.accounts) {
-				m.currentIndex = 0
-			}
+	// Save synchronously for CLI commands
+	if err := m.saveToDiskLocked(); err != nil {
+		// Restore the account since save failed
+		m.accounts, m.archived = accounts, archived
+		return fmt.Errorf("failed to save after removal: %w", err)
+	}
 
-			// Adjust per-provider indices: delete entries pointing to the removed index
-			// and decrement indices greater than the removed index.
-			for provider, idx := range m.currentIndexByProvider {
-				if idx == removedIdx {
-					// This provider was pointing to the removed account - reset to first for that provider.
-					delete(m.currentIndexByProvider, provider)
-				} else if idx > removedIdx {
-					// Shift down indices that were after the removed account.
-					m.currentIndexByProvider[provider] = idx - 1
-				}
-			}
+	utils.Success("[AccountManager] Removed account: %s (restore with 'accounts restore')", email)
+	return nil
+}
 
-			// Save synchronously for CLI commands
-			if err := m.saveToDiskLocked(); err != nil {
-				// Restore the account since save failed
-				m.accounts = append(m.accounts[:i], append([]Account{removed}, m.accounts[i:]...)...)
-				return fmt.Errorf("failed to save after removal: %w", err)
-			}
+// detachAccountLocked removes the account at idx from the active pool, clears its cached
+// state and fixes up selection indices. The previous m.accounts slice is left untouched.
+// The caller must hold m.mu and persist the change.
+func (m *Manager) detachAccountLocked(idx int) Account {
+	removed := m.accounts[idx]
+	m.accounts = append(m.accounts[:idx:idx], m.accounts[idx+1:]...)
+
+	// Clear caches
+	delete(m.tokenCache, removed.Email)
+	delete(m.projectCache, removed.Email)
+	m.health.forget(removed.Email)
+	m.sticky.forget(removed.Email, time.Now())
+	delete(m.draining, removed.Email)
+	delete(m.blacklisted, removed.Email)
+
+	// Adjust current index if needed
+	if m.currentIndex >= len(m.accounts) {
+		m.currentIndex = 0
+	}
 
-			utils.Success("[AccountManager] Removed account: %s", email)
-			return nil
+	// Adjust per-provider indices: delete entries pointing to the removed index
+	// and decrement indices greater than the removed index.
+	for provider, i := range m.currentIndexByProvider {
+		if i == idx {
+			// This provider was pointing to the removed account - reset to first for that provider.
+			delete(m.currentIndexByProvider, provider)
+		} else if i > idx {
+			// Shift down indices that were after the removed account.
+			m.currentIndexByProvider[provider] = i - 1
 		}
 	}
+	return removed
+}
 
-	return fmt.Errorf("account %s not found", email)
+// findAccountIndexLocked returns the index of an active account, or -1.
+func (m *Manager) findAccountIndexLocked(email string) int {
+	for i := range m.accounts {
+		if m.accounts[i].Email == email {
+			return i
+		}
+	}
+	return -1
 }
 
 // isNetworkError checks if an error is a transient network error.
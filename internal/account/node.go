@@ -0,0 +1,141 @@
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// nodeAccount is an account as stored in the Node proxy's accounts.json.
+type nodeAccount struct {
+	Email        string          `json:"email"`
+	Source       string          `json:"source"` // "oauth", "database" or "manual"
+	RefreshToken NullableString  `json:"refreshToken"`
+	APIKey       NullableString  `json:"apiKey"`
+	ProjectID    NullableString  `json:"projectId"`
+	DBPath       NullableString  `json:"dbPath"`
+	AddedAt      json.RawMessage `json:"addedAt"`
+	LastUsed     json.RawMessage `json:"lastUsed"`
+}
+
+// nodeConfigFile is the Node proxy's accounts.json.
+type nodeConfigFile struct {
+	Accounts []nodeAccount `json:"accounts"`
+	Settings Settings      `json:"settings"`
+}
+
+// ConvertNodeAccounts converts the Node proxy's accounts.json into Antigravity accounts.
+// Rate limit state is not carried over. Accounts that can't be used here, such as
+// "database" accounts whose token lives in the Antigravity IDE database, are reported as
+// warnings instead.
+func ConvertNodeAccounts(data []byte) ([]Account, Settings, []string, error) {
+	var node nodeConfigFile
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, Settings{}, nil, fmt.Errorf("invalid Node accounts file: %w", err)
+	}
+
+	var accounts []Account
+	var warnings []string
+	for _, na := range node.Accounts {
+		if na.Email == "" {
+			warnings = append(warnings, "skipped an account without an email")
+			continue
+		}
+		acc := Account{
+			Email:        na.Email,
+			Source:       na.Source,
+			Provider:     "antigravity",
+			RefreshToken: string(na.RefreshToken),
+			APIKey:       string(na.APIKey),
+			ProjectID:    string(na.ProjectID),
+			AddedAt:      nodeTime(na.AddedAt),
+			LastUsed:     nodeTime(na.LastUsed),
+		}
+		switch na.Source {
+		case "oauth", "manual":
+		case "database":
+			// The Node proxy reads the token from the IDE database on every start; keep
+			// the last token it saved, if any.
+			if acc.RefreshToken == "" {
+				warnings = append(warnings, fmt.Sprintf("skipped %s: its token is in the Antigravity database (%s); add it with 'accounts add'", na.Email, na.DBPath))
+				continue
+			}
+			acc.Source = "oauth"
+		default:
+			warnings = append(warnings, fmt.Sprintf("skipped %s: unknown source %q", na.Email, na.Source))
+			continue
+		}
+		if acc.RefreshToken == "" && acc.APIKey == "" {
+			warnings = append(warnings, fmt.Sprintf("skipped %s: no refresh token or API key", na.Email))
+			continue
+		}
+		accounts = append(accounts, acc)
+	}
+	return accounts, node.Settings, warnings, nil
+}
+
+// nodeTime parses a Node timestamp: an ISO 8601 string or Unix milliseconds. Missing or
+// unparseable values yield nil.
+func nodeTime(raw json.RawMessage) *time.Time {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return &t
+		}
+		return nil
+	}
+	if ms, err := strconv.ParseInt(string(raw), 10, 64); err == nil && ms > 0 {
+		t := time.UnixMilli(ms)
+		return &t
+	}
+	return nil
+}
+
+// ImportAccounts adds accounts migrated from another installation in one save, keeping
+// their timestamps. Accounts whose email is already configured are left untouched and
+// returned. A cooldown in settings is used only if none is configured.
+func (m *Manager) ImportAccounts(accounts []Account, settings Settings) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	prevAccounts, prevArchived, prevSettings := m.accounts, m.archived, m.settings
+	var existing []string
+	for _, acc := range accounts {
+		if m.findAccountIndexLocked(acc.Email) >= 0 {
+			existing = append(existing, acc.Email)
+			continue
+		}
+		if len(m.accounts) >= config.MaxAccounts {
+			m.accounts, m.archived = prevAccounts, prevArchived
+			return nil, fmt.Errorf("maximum number of accounts (%d) reached", config.MaxAccounts)
+		}
+		if acc.ModelRateLimits == nil {
+			acc.ModelRateLimits = make(map[string]ModelRateLimit)
+		}
+		if acc.AddedAt == nil {
+			now := time.Now()
+			acc.AddedAt = &now
+		}
+		if i := m.findArchivedIndexLocked(acc.Email); i >= 0 {
+			m.archived = append(m.archived[:i:i], m.archived[i+1:]...)
+		}
+		m.accounts = append(m.accounts[:len(m.accounts):len(m.accounts)], acc)
+	}
+	if m.settings.CooldownDurationMs == 0 {
+		m.settings.CooldownDurationMs = settings.CooldownDurationMs
+	}
+
+	if err := m.saveToDiskLocked(); err != nil {
+		m.accounts, m.archived, m.settings = prevAccounts, prevArchived, prevSettings
+		return nil, fmt.Errorf("failed to save accounts: %w", err)
+	}
+	return existing, nil
+}
@@ -0,0 +1,106 @@
+package account
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const nodeAccountsJSON = `{
+  "accounts": [
+    {"email": "a@x", "source": "oauth", "refreshToken": "rt-a", "apiKey": null, "projectId": "proj-a",
+     "addedAt": "2025-06-01T10:00:00.000Z", "lastUsed": 1750000000000, "isRateLimited": true,
+     "modelRateLimits": {"claude-sonnet-4-5": {"isRateLimited": true, "resetTime": 1750000100000}}},
+    {"email": "b@x", "source": "database", "refreshToken": "rt-b", "dbPath": "/db/state.vscdb", "lastUsed": null},
+    {"email": "c@x", "source": "database", "refreshToken": null, "dbPath": "/db/state.vscdb"},
+    {"email": "d@x", "source": "manual", "apiKey": "key-d"},
+    {"email": "e@x", "source": "oauth"}
+  ],
+  "settings": {"cooldownDurationMs": 30000},
+  "activeIndex": 1
+}`
+
+func TestConvertNodeAccounts(t *testing.T) {
+	accounts, settings, warnings, err := ConvertNodeAccounts([]byte(nodeAccountsJSON))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if settings.CooldownDurationMs != 30000 {
+		t.Errorf("expected cooldown 30000, got %d", settings.CooldownDurationMs)
+	}
+
+	var emails []string
+	for _, acc := range accounts {
+		emails = append(emails, acc.Email)
+		if acc.Provider != "antigravity" {
+			t.Errorf("%s: expected antigravity provider, got %q", acc.Email, acc.Provider)
+		}
+	}
+	if got := strings.Join(emails, ","); got != "a@x,b@x,d@x" {
+		t.Fatalf("expected a@x,b@x,d@x, got %s", got)
+	}
+
+	a := accounts[0]
+	if a.RefreshToken != "rt-a" || a.ProjectID != "proj-a" || a.Source != "oauth" || len(a.ModelRateLimits) != 0 {
+		t.Errorf("unexpected account a: %+v", a)
+	}
+	if a.AddedAt == nil || !a.AddedAt.Equal(time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected addedAt from ISO string, got %v", a.AddedAt)
+	}
+	if a.LastUsed == nil || a.LastUsed.UnixMilli() != 1750000000000 {
+		t.Errorf("expected lastUsed from milliseconds, got %v", a.LastUsed)
+	}
+	if accounts[1].Source != "oauth" || accounts[1].LastUsed != nil {
+		t.Errorf("expected database account with a token to become oauth, got %+v", accounts[1])
+	}
+	if accounts[2].APIKey != "key-d" {
+		t.Errorf("expected manual API key, got %+v", accounts[2])
+	}
+
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "c@x") || !strings.Contains(warnings[1], "e@x") {
+		t.Errorf("expected warnings for c@x and e@x, got %v", warnings)
+	}
+
+	if _, _, _, err := ConvertNodeAccounts([]byte("{")); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestManager_ImportAccounts(t *testing.T) {
+	mgr := newTestManager(t)
+	if err := mgr.AddAccount(Account{Email: "a@x", Source: "oauth", Provider: "antigravity", RefreshToken: "current"}); err != nil {
+		t.Fatal(err)
+	}
+	accounts, settings, _, err := ConvertNodeAccounts([]byte(nodeAccountsJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing, err := mgr.ImportAccounts(accounts, settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(existing) != 1 || existing[0] != "a@x" {
+		t.Errorf("expected a@x to be reported as existing, got %v", existing)
+	}
+	if got := len(mgr.GetAllAccounts()); got != 3 {
+		t.Errorf("expected 3 accounts, got %d", got)
+	}
+	if got := mgr.GetSettings().CooldownDurationMs; got != 30000 {
+		t.Errorf("expected imported cooldown, got %d", got)
+	}
+
+	// The import is persisted and existing accounts keep their credentials.
+	reloaded := NewManager(mgr.storage.configPath)
+	if err := reloaded.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	for _, acc := range reloaded.GetAllAccounts() {
+		if acc.Email == "a@x" && acc.RefreshToken != "current" {
+			t.Errorf("expected existing a@x to keep its token, got %q", acc.RefreshToken)
+		}
+	}
+	if got := len(reloaded.GetAllAccounts()); got != 3 {
+		t.Errorf("expected 3 persisted accounts, got %d", got)
+	}
+}
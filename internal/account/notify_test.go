@@ -0,0 +1,71 @@
+package account
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/notify"
+)
+
+func TestManager_NotifiesStateChanges(t *testing.T) {
+	events := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var e notify.Event
+		json.Unmarshal(body, &e)
+		events <- string(e.Type) + " " + e.Email
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t)
+	for _, email := range []string{"a", "b"} {
+		if err := m.AddAccount(Account{Email: email, Provider: "zai", Source: "manual", APIKey: "k"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := notify.New(config.NotifyConfig{Webhooks: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetNotifier(n)
+
+	expect := func(want ...string) {
+		t.Helper()
+		got := map[string]bool{}
+		for range want {
+			select {
+			case e := <-events:
+				got[e] = true
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for %v, got %v", want, got)
+			}
+		}
+		for _, w := range want {
+			if !got[w] {
+				t.Errorf("missing event %q, got %v", w, got)
+			}
+		}
+	}
+
+	m.MarkRateLimited("a", 60000, "glm-4.6")
+	expect("account_rate_limited a")
+	m.MarkRateLimited("a", 60000, "glm-4.6") // already limited: no event
+	// Long enough that b is still limited when exhaustion is checked on a slow machine.
+	m.MarkRateLimited("b", 200, "glm-4.6")
+	expect("account_rate_limited b", "provider_exhausted ")
+
+	time.Sleep(250 * time.Millisecond)
+	m.ClearExpiredLimits()
+	expect("account_recovered b")
+
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %q", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
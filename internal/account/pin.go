@@ -0,0 +1,154 @@
+package account
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+type pinnedAccountKey struct{}
+
+type avoidedAccountsKey struct{}
+
+type firstPickKey struct{}
+
+type preferredAccountKey struct{}
+
+type softLimitModeKey struct{}
+
+// softLimitMode is how account selection treats accounts soft-limited for the model.
+type softLimitMode int
+
+const (
+	softLimitPrefer  softLimitMode = iota // Picked only when no other account is usable
+	softLimitExclude                      // Never picked
+	softLimitInclude                      // Picked like any other account
+)
+
+// preferredAccount is the account a request prefers on its first pick.
+type preferredAccount struct {
+	mu    sync.Mutex
+	email string
+}
+
+// take returns the preferred account the first time it is called and "" afterwards.
+func (p *preferredAccount) take() string {
+	if p == nil {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	email := p.email
+	p.email = ""
+	return email
+}
+
+// firstPick is the account a request without failover was given first.
+type firstPick struct {
+	mu    sync.Mutex
+	email string
+}
+
+// WithAccount returns ctx restricting account selection by PickNextByProviderContext to
+// the account with email, e.g. to replay a request against one account.
+func WithAccount(ctx context.Context, email string) context.Context {
+	return context.WithValue(ctx, pinnedAccountKey{}, email)
+}
+
+// WithoutAccounts returns ctx making PickNextByProviderContext pass over the accounts with
+// emails, in addition to those already avoided in ctx, unless no other account is usable,
+// e.g. to retry a request that failed on them.
+func WithoutAccounts(ctx context.Context, emails ...string) context.Context {
+	avoid := make(map[string]bool)
+	for email := range avoidedAccounts(ctx) {
+		avoid[email] = true
+	}
+	for _, email := range emails {
+		avoid[email] = true
+	}
+	return context.WithValue(ctx, avoidedAccountsKey{}, avoid)
+}
+
+// WithoutFailover returns ctx making PickNextByProviderContext keep returning the first
+// account it picks, as if it were pinned with WithAccount, so a failing request isn't
+// retried on other accounts.
+func WithoutFailover(ctx context.Context) context.Context {
+	return context.WithValue(ctx, firstPickKey{}, &firstPick{})
+}
+
+// WithPreferredAccount returns ctx making the first PickNextByProviderContext call pick the
+// account with email while it is usable and preferred for the model, e.g. to keep a
+// conversation on the account that served its earlier turns. Unlike WithAccount, retries
+// and an unusable account fall back to normal selection.
+func WithPreferredAccount(ctx context.Context, email string) context.Context {
+	return context.WithValue(ctx, preferredAccountKey{}, &preferredAccount{email: email})
+}
+
+// WithoutSoftLimited returns ctx making PickNextByProviderContext pass over accounts that are
+// soft-limited for the model even when no other account is usable, e.g. for requests that
+// must not spend the last of an account's quota.
+func WithoutSoftLimited(ctx context.Context) context.Context {
+	return context.WithValue(ctx, softLimitModeKey{}, softLimitExclude)
+}
+
+// WithSoftLimited returns ctx making PickNextByProviderContext pick accounts that are
+// soft-limited for the model like any other, rather than only when no other is usable.
+func WithSoftLimited(ctx context.Context) context.Context {
+	return context.WithValue(ctx, softLimitModeKey{}, softLimitInclude)
+}
+
+func avoidedAccounts(ctx context.Context) map[string]bool {
+	avoid, _ := ctx.Value(avoidedAccountsKey{}).(map[string]bool)
+	return avoid
+}
+
+// PickNextByProviderContext is PickNextByProvider for a request context. An account pinned
+// with WithAccount is returned whenever it belongs to provider, even if it is rate-limited,
+// invalid or at its concurrency cap, so the caller sees what the upstream says; nil otherwise.
+// Accounts avoided with WithoutAccounts are picked last, an account preferred with
+// WithPreferredAccount is picked first, soft-limited accounts are picked as set with
+// WithoutSoftLimited or WithSoftLimited, and with WithoutFailover the first account picked
+// is pinned.
+func (m *Manager) PickNextByProviderContext(ctx context.Context, provider, modelID string) *Account {
+	first, ok := ctx.Value(firstPickKey{}).(*firstPick)
+	if !ok {
+		return m.pickNextByProviderContext(ctx, provider, modelID)
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	if first.email != "" {
+		return m.pickNextByProviderContext(WithAccount(ctx, first.email), provider, modelID)
+	}
+	acc := m.pickNextByProviderContext(ctx, provider, modelID)
+	if acc != nil {
+		first.email = acc.Email
+	}
+	return acc
+}
+
+func (m *Manager) pickNextByProviderContext(ctx context.Context, provider, modelID string) *Account {
+	email, ok := ctx.Value(pinnedAccountKey{}).(string)
+	if !ok {
+		avoid := avoidedAccounts(ctx)
+		prefer, _ := ctx.Value(preferredAccountKey{}).(*preferredAccount)
+		email := prefer.take()
+		softLimits, _ := ctx.Value(softLimitModeKey{}).(softLimitMode)
+		if len(avoid) == 0 && email == "" && softLimits == softLimitPrefer {
+			return m.PickNextByProvider(provider, modelID)
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.clearExpiredLimitsLocked()
+		return m.pickNextByProviderLocked(provider, modelID, avoid, email, softLimits)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := m.findAccountIndexLocked(email)
+	if idx < 0 || m.accounts[idx].Provider != provider {
+		return nil
+	}
+	utils.Info("[AccountManager] Using pinned account: %s", email)
+	return &m.accounts[idx]
+}
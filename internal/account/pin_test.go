@@ -0,0 +1,139 @@
+package account
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPickNextByProviderContext_PinnedAccount(t *testing.T) {
+	m := newTestManager(t)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := m.AddAccount(Account{Email: email, Source: "manual", Provider: "zai", APIKey: "key-" + email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.MarkRateLimited("b@example.com", 60000, "model")
+
+	ctx := WithAccount(context.Background(), "b@example.com")
+	if acc := m.PickNextByProviderContext(ctx, "zai", "model"); acc == nil || acc.Email != "b@example.com" {
+		t.Fatalf("expected pinned rate-limited account, got %+v", acc)
+	}
+	if acc := m.PickNextByProviderContext(ctx, "antigravity", "model"); acc != nil {
+		t.Errorf("expected no account for another provider, got %s", acc.Email)
+	}
+	if acc := m.PickNextByProviderContext(context.Background(), "zai", "model"); acc == nil || acc.Email != "a@example.com" {
+		t.Errorf("expected normal selection without a pin, got %+v", acc)
+	}
+}
+
+func TestPickNextByProviderContext_AvoidedAccounts(t *testing.T) {
+	m := newTestManager(t)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := m.AddAccount(Account{Email: email, Source: "manual", Provider: "zai", APIKey: "key-" + email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := WithoutAccounts(context.Background(), "a@example.com")
+	for range 3 {
+		if acc := m.PickNextByProviderContext(ctx, "zai", "model"); acc == nil || acc.Email != "b@example.com" {
+			t.Fatalf("expected the account that wasn't avoided, got %+v", acc)
+		}
+	}
+
+	// With every account avoided, one is still picked rather than failing the request.
+	ctx = WithoutAccounts(ctx, "b@example.com")
+	if acc := m.PickNextByProviderContext(ctx, "zai", "model"); acc == nil {
+		t.Error("expected an avoided account when no other is usable")
+	}
+}
+
+func TestPickNextByProviderContext_WithoutFailover(t *testing.T) {
+	m := newTestManager(t)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := m.AddAccount(Account{Email: email, Source: "manual", Provider: "zai", APIKey: "key-" + email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := WithoutFailover(context.Background())
+	first := m.PickNextByProviderContext(ctx, "zai", "model")
+	if first == nil {
+		t.Fatal("expected an account")
+	}
+	// A failed attempt on the first account is retried on it, not on the other one.
+	m.MarkRateLimited(first.Email, 60000, "model")
+	if acc := m.PickNextByProviderContext(ctx, "zai", "model"); acc == nil || acc.Email != first.Email {
+		t.Errorf("expected %s again, got %+v", first.Email, acc)
+	}
+	if acc := m.PickNextByProviderContext(context.Background(), "zai", "model"); acc == nil || acc.Email == first.Email {
+		t.Errorf("expected failover without the option, got %+v", acc)
+	}
+}
+
+func TestPickNextByProviderContext_PreferredAccount(t *testing.T) {
+	m := newTestManager(t)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := m.AddAccount(Account{Email: email, Source: "manual", Provider: "zai", APIKey: "key-" + email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for range 3 {
+		ctx := WithPreferredAccount(context.Background(), "c@example.com")
+		if acc := m.PickNextByProviderContext(ctx, "zai", "model"); acc == nil || acc.Email != "c@example.com" {
+			t.Fatalf("expected the preferred account, got %+v", acc)
+		}
+		// Retries within the request use normal selection.
+		if acc := m.PickNextByProviderContext(ctx, "zai", "model"); acc == nil || acc.Email == "c@example.com" {
+			t.Fatalf("expected another account on the second pick, got %+v", acc)
+		}
+	}
+
+	// A rate-limited preferred account is passed over.
+	m.MarkRateLimited("c@example.com", 60000, "model")
+	ctx := WithPreferredAccount(context.Background(), "c@example.com")
+	if acc := m.PickNextByProviderContext(ctx, "zai", "model"); acc == nil || acc.Email == "c@example.com" {
+		t.Errorf("expected another account while the preferred one is rate-limited, got %+v", acc)
+	}
+}
+
+func TestPickNextByProviderContext_SoftLimited(t *testing.T) {
+	m := newTestManager(t)
+	m.SetSoftLimitSettings(true, 0.2)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := m.AddAccount(Account{Email: email, Source: "manual", Provider: "zai", APIKey: "key-" + email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.UpdateSoftLimitStatus("b@example.com", "model", 0.1)
+
+	ctx := WithoutSoftLimited(context.Background())
+	for range 3 {
+		if acc := m.PickNextByProviderContext(ctx, "zai", "model"); acc == nil || acc.Email != "a@example.com" {
+			t.Fatalf("expected the account that isn't soft-limited, got %+v", acc)
+		}
+	}
+	// Unlike normal selection, a soft-limited account is never the fallback.
+	m.MarkRateLimited("a@example.com", 60000, "model")
+	if acc := m.PickNextByProviderContext(ctx, "zai", "model"); acc != nil {
+		t.Errorf("expected no account while the other one is soft-limited, got %s", acc.Email)
+	}
+	if acc := m.PickNextByProviderContext(context.Background(), "zai", "model"); acc == nil || acc.Email != "b@example.com" {
+		t.Errorf("expected the soft-limited account as a fallback without the option, got %+v", acc)
+	}
+
+	// With soft-limited accounts picked like any other, selection rotates over both.
+	m.ResetAllRateLimitsByProvider("zai")
+	m.UpdateSoftLimitStatus("b@example.com", "model", 0.1)
+	ctx = WithSoftLimited(context.Background())
+	seen := map[string]bool{}
+	for range 4 {
+		if acc := m.PickNextByProviderContext(ctx, "zai", "model"); acc != nil {
+			seen[acc.Email] = true
+		}
+	}
+	if !seen["a@example.com"] || !seen["b@example.com"] {
+		t.Errorf("expected both accounts to be picked, got %v", seen)
+	}
+}
@@ -0,0 +1,79 @@
+package account
+
+import (
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// projectLimitKey identifies the rate limit of one Cloud project of an account for a model.
+type projectLimitKey struct {
+	email   string
+	project string
+	model   string
+}
+
+// GetProjectForModel returns the project to send a request for modelID to. Accounts with
+// several projects (ProjectIDs) get the first one that isn't rate-limited for the model;
+// the others get GetProjectForAccount's.
+func (m *Manager) GetProjectForModel(account *Account, token, modelID string) (string, error) {
+	m.mu.Lock()
+	projects := account.Projects()
+	if len(projects) < 2 {
+		m.mu.Unlock()
+		return m.GetProjectForAccount(account, token)
+	}
+	defer m.mu.Unlock()
+	if next, _, ok := m.nextProjectLocked(account.Email, projects, modelID, time.Now().UnixMilli()); ok {
+		return next, nil
+	}
+	// All are limited, which MarkProjectRateLimited records on the account; try the first.
+	return projects[0], nil
+}
+
+// MarkProjectRateLimited records that a project of an account was rate-limited for modelID
+// and reports whether the account has another project to rotate to. When it hasn't (or has
+// a single project), the account itself is marked rate-limited until its first project
+// resets, so it is only failed over once all of its projects are exhausted.
+func (m *Manager) MarkProjectRateLimited(email, projectID string, resetMs int64, modelID string) bool {
+	m.mu.Lock()
+	idx := m.findAccountIndexLocked(email)
+	var projects []string
+	if idx >= 0 {
+		projects = m.accounts[idx].Projects()
+	}
+	if len(projects) < 2 {
+		m.mu.Unlock()
+		m.MarkRateLimited(email, resetMs, modelID)
+		return false
+	}
+
+	now := time.Now().UnixMilli()
+	m.projectLimits[projectLimitKey{email, projectID, modelID}] = now + cooldownMs(resetMs, m.settings)
+	next, resetTime, ok := m.nextProjectLocked(email, projects, modelID, now)
+	m.mu.Unlock()
+
+	if ok {
+		utils.Info("[AccountManager] Project %s of %s rate-limited (model: %s), rotating to project %s",
+			projectID, email, modelID, next)
+		return true
+	}
+	m.MarkRateLimited(email, resetTime-now, modelID)
+	return false
+}
+
+// nextProjectLocked returns the first of projects not rate-limited for modelID, or else
+// when the first of them resets.
+func (m *Manager) nextProjectLocked(email string, projects []string, modelID string, now int64) (string, int64, bool) {
+	var earliest int64
+	for _, project := range projects {
+		resetTime := m.projectLimits[projectLimitKey{email, project, modelID}]
+		if resetTime <= now {
+			return project, 0, true
+		}
+		if earliest == 0 || resetTime < earliest {
+			earliest = resetTime
+		}
+	}
+	return "", earliest, false
+}
@@ -0,0 +1,55 @@
+package account
+
+import "testing"
+
+func TestMarkProjectRateLimited(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.AddAccount(Account{Email: "a@example.com", Source: "manual", Provider: "antigravity", APIKey: "k",
+		ProjectID: "p1", ProjectIDs: []string{"p2", "p1"}}); err != nil {
+		t.Fatal(err)
+	}
+	acc, _ := m.GetAccount("a@example.com")
+	if got := acc.Projects(); len(got) != 2 || got[0] != "p1" || got[1] != "p2" {
+		t.Fatalf("Projects = %v, want [p1 p2]", got)
+	}
+	project := func() string {
+		t.Helper()
+		id, err := m.GetProjectForModel(&acc, "token", "gemini-3-flash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	if got := project(); got != "p1" {
+		t.Fatalf("project = %s, want p1", got)
+	}
+	if !m.MarkProjectRateLimited("a@example.com", "p1", 60000, "gemini-3-flash") {
+		t.Fatal("expected a rotation to p2")
+	}
+	if got := project(); got != "p2" {
+		t.Fatalf("project = %s, want p2", got)
+	}
+	if m.IsAllRateLimitedByProvider("antigravity", "gemini-3-flash") {
+		t.Fatal("the account must stay available while p2 has quota")
+	}
+	// Other models still use the first project.
+	if id, _ := m.GetProjectForModel(&acc, "token", "claude-sonnet-4-5"); id != "p1" {
+		t.Errorf("project for another model = %s, want p1", id)
+	}
+
+	if m.MarkProjectRateLimited("a@example.com", "p2", 120000, "gemini-3-flash") {
+		t.Fatal("expected no project left")
+	}
+	if !m.IsAllRateLimitedByProvider("antigravity", "gemini-3-flash") {
+		t.Fatal("expected the account to be rate-limited once all its projects are")
+	}
+	if wait := m.GetMinWaitTimeMsByProvider("antigravity", "gemini-3-flash"); wait > 60000 || wait < 50000 {
+		t.Errorf("wait = %dms, want about 60s (until p1 resets)", wait)
+	}
+
+	m.ResetAllRateLimitsByProvider("antigravity")
+	if got := project(); got != "p1" {
+		t.Errorf("project after reset = %s, want p1", got)
+	}
+}
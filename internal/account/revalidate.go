@@ -0,0 +1,127 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/notify"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// VerifyFunc checks that an account's credentials are accepted by its provider.
+type VerifyFunc func(ctx context.Context, acc Account) error
+
+// revalidateTimeout bounds each re-test so a hung provider can't stall the pass.
+const revalidateTimeout = 30 * time.Second
+
+// RevalidateInvalidAccounts re-tests every enabled invalid account with verify and clears
+// the invalid flag of those that pass, saving the change. Each account is re-tested at most
+// maxAttempts times (0 = no limit) while it stays invalid; the count starts over once it
+// recovers, or when the server restarts. Returns the number of accounts recovered.
+func (m *Manager) RevalidateInvalidAccounts(ctx context.Context, maxAttempts int, verify VerifyFunc) int {
+	m.mu.Lock()
+	var candidates []Account
+	for email := range m.revalidateAttempts {
+		if acc := m.findAccountLocked(email); acc == nil || !acc.IsInvalid {
+			delete(m.revalidateAttempts, email)
+		}
+	}
+	for _, acc := range m.accounts {
+		if !acc.IsInvalid || !acc.IsEnabled() {
+			continue
+		}
+		if maxAttempts > 0 && m.revalidateAttempts[acc.Email] >= maxAttempts {
+			continue
+		}
+		m.revalidateAttempts[acc.Email]++
+		candidates = append(candidates, acc)
+	}
+	m.mu.Unlock()
+
+	recovered := 0
+	for _, acc := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		verifyCtx, cancel := context.WithTimeout(ctx, revalidateTimeout)
+		err := verify(verifyCtx, acc)
+		cancel()
+		if err != nil {
+			m.mu.RLock()
+			attempts := m.revalidateAttempts[acc.Email]
+			m.mu.RUnlock()
+			if maxAttempts > 0 && attempts >= maxAttempts {
+				utils.Warn("[AccountManager] Re-validation of %s failed (attempt %d of %d, giving up): %v", acc.Email, attempts, maxAttempts, err)
+			} else {
+				utils.Warn("[AccountManager] Re-validation of %s failed (attempt %d): %v", acc.Email, attempts, err)
+			}
+			continue
+		}
+		if err := m.clearInvalid(acc.Email); err != nil {
+			utils.Error("[AccountManager] Failed to save re-validated account %s: %v", acc.Email, err)
+			continue
+		}
+		recovered++
+	}
+	return recovered
+}
+
+// clearInvalid marks an invalid account valid again and saves it. It is a no-op when the
+// account was recovered meanwhile, e.g. by a successful token refresh.
+func (m *Manager) clearInvalid(email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	delete(m.revalidateAttempts, email)
+	idx := m.findAccountIndexLocked(email)
+	if idx < 0 || !m.accounts[idx].IsInvalid {
+		return nil
+	}
+	acc := &m.accounts[idx]
+	previous := *acc
+	acc.IsInvalid = false
+	acc.InvalidReason = ""
+	acc.InvalidAt = nil
+	if err := m.saveToDiskLocked(); err != nil {
+		m.accounts[idx] = previous
+		return fmt.Errorf("failed to save account: %w", err)
+	}
+
+	delete(m.blacklisted, email)
+	m.notifier.Notify(notify.Event{Type: notify.EventAccountRecovered, Email: email, Provider: acc.Provider, Reason: "re-validated"})
+	utils.Success("[AccountManager] Re-validated account: %s", email)
+	return nil
+}
+
+// StartRevalidation calls RevalidateInvalidAccounts every interval until stop is closed.
+func (m *Manager) StartRevalidation(interval time.Duration, maxAttempts int, verify VerifyFunc, stop <-chan struct{}) {
+	if interval <= 0 || verify == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-stop
+			cancel()
+		}()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.RevalidateInvalidAccounts(ctx, maxAttempts, verify)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,68 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRevalidateInvalidAccounts(t *testing.T) {
+	m := newTestManager(t)
+	for _, email := range []string{"ok@example.com", "bad@example.com", "valid@example.com"} {
+		if err := m.AddAccount(Account{Email: email, Source: "manual", Provider: "zai", APIKey: "k-" + email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.MarkInvalid("ok@example.com", "transient")
+	m.MarkInvalid("bad@example.com", "revoked")
+
+	var tested []string
+	verify := func(ctx context.Context, acc Account) error {
+		tested = append(tested, acc.Email)
+		if acc.Email == "bad@example.com" {
+			return errors.New("still revoked")
+		}
+		return nil
+	}
+
+	if n := m.RevalidateInvalidAccounts(context.Background(), 2, verify); n != 1 {
+		t.Fatalf("expected 1 recovered account, got %d", n)
+	}
+	if len(tested) != 2 {
+		t.Errorf("expected only the invalid accounts to be tested, got %v", tested)
+	}
+	if acc := m.PickNextByProvider("zai", ""); acc == nil {
+		t.Fatal("expected an available account")
+	}
+
+	// The recovery is saved (invalid flags themselves are reset on load).
+	reloaded := NewManager(m.storage.ConfigPath())
+	if err := reloaded.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if acc, _ := reloaded.GetAccount("ok@example.com"); acc.IsInvalid || acc.InvalidReason != "" || acc.InvalidAt != nil {
+		t.Errorf("expected the re-validated account to be saved valid, got %+v", acc)
+	}
+	if acc, _ := m.GetAccount("bad@example.com"); !acc.IsInvalid {
+		t.Error("expected the failing account to stay invalid")
+	}
+
+	// The failing account is re-tested until it runs out of attempts.
+	tested = nil
+	m.RevalidateInvalidAccounts(context.Background(), 2, verify)
+	m.RevalidateInvalidAccounts(context.Background(), 2, verify)
+	if len(tested) != 1 || tested[0] != "bad@example.com" {
+		t.Errorf("expected one more attempt for the failing account, got %v", tested)
+	}
+
+	// Disabled accounts are left alone.
+	m.MarkInvalid("ok@example.com", "transient")
+	if err := m.DisableAccount("ok@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	tested = nil
+	m.RevalidateInvalidAccounts(context.Background(), 0, verify)
+	if len(tested) != 1 || tested[0] != "bad@example.com" {
+		t.Errorf("expected the disabled account to be skipped, got %v", tested)
+	}
+}
@@ -0,0 +1,103 @@
+package account
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// secretRef remembers the reference an account field was loaded from, so saving the
+// account writes the reference back instead of the secret it resolved to.
+type secretRef struct {
+	ref      string // e.g. "${env:ZAI_KEY}" or "file:/run/secrets/zai"
+	resolved string
+}
+
+// parseSecretRef recognizes "${env:NAME}", "${file:PATH}" and "file:PATH" values, and
+// encrypted "enc:v1:..." values (see encryption.go).
+func parseSecretRef(value string) (kind, target string, ok bool) {
+	if target, ok := strings.CutPrefix(value, encryptedPrefix); ok && target != "" {
+		return "enc", target, true
+	}
+	if strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}") {
+		inner := value[2 : len(value)-1]
+		if kind, target, ok = strings.Cut(inner, ":"); ok && (kind == "env" || kind == "file") && target != "" {
+			return kind, target, true
+		}
+		return "", "", false
+	}
+	if target, ok := strings.CutPrefix(value, "file:"); ok && target != "" {
+		return "file", target, true
+	}
+	return "", "", false
+}
+
+// resolveSecretRef returns the value a reference points to. Files have trailing
+// newlines trimmed, as written by most secret mounts.
+func resolveSecretRef(kind, target string) (string, error) {
+	switch kind {
+	case "env":
+		v, ok := os.LookupEnv(target)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", target)
+		}
+		return v, nil
+	case "file":
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case "enc":
+		return resolveEncrypted(target)
+	default:
+		return "", fmt.Errorf("unknown secret reference type %q", kind)
+	}
+}
+
+// secretFields returns the account fields that may hold secret references, by JSON name.
+func (a *Account) secretFields() map[string]*string {
+	return map[string]*string{
+		"refreshToken": &a.RefreshToken,
+		"apiKey":       &a.APIKey,
+		"projectId":    &a.ProjectID,
+		"proxy":        &a.Proxy, // proxy URLs may carry credentials
+	}
+}
+
+// resolveSecrets replaces secret references in the account's credential fields with
+// the values they point to. Fields that fail to resolve are left empty and reported.
+func (a *Account) resolveSecrets() error {
+	var errs []string
+	for name, field := range a.secretFields() {
+		kind, target, ok := parseSecretRef(*field)
+		if !ok {
+			continue
+		}
+		ref := *field
+		resolved, err := resolveSecretRef(kind, target)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+		*field = resolved
+		if a.secretRefs == nil {
+			a.secretRefs = make(map[string]secretRef)
+		}
+		a.secretRefs[name] = secretRef{ref: ref, resolved: resolved}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unresolved secret reference (%s)", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// restoreSecretRefs puts the original references back into fields whose value is still
+// the one resolved at load time. A value changed since (e.g. a re-authenticated refresh
+// token) is saved as-is, since the reference no longer describes it.
+func (a *Account) restoreSecretRefs() {
+	for name, field := range a.secretFields() {
+		if r, ok := a.secretRefs[name]; ok && *field == r.resolved {
+			*field = r.ref
+		}
+	}
+}
@@ -14,7 +14,7 @@ type SelectionResult struct {
 
 // isAccountUsable checks if an account is usable for a specific model.
 func isAccountUsable(account *Account, modelID string) bool {
-	if account == nil || account.IsInvalid {
+	if account == nil || account.IsInvalid || !account.IsEnabled() {
 		return false
 	}
 
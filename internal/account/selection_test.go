@@ -253,3 +253,35 @@ func TestGetSoftLimitedCount_ReturnsZeroWhenDisabled(t *testing.T) {
 		t.Errorf("expected 0 when soft limits disabled, got %d", count)
 	}
 }
+
+func TestSetQuotaBaselines(t *testing.T) {
+	m := newTestManager(t)
+	m.SetSoftLimitSettings(true, 0.2)
+	if err := m.AddAccount(Account{Email: "a@example.com", Source: "oauth", Provider: "antigravity", RefreshToken: "rt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.SetQuotaBaselines("a@example.com", map[string]float64{"gemini-3-flash": 0.8, "claude-opus-4-5": 0.1, "gemini-3-pro-high": 1.5})
+	if err != nil {
+		t.Fatalf("SetQuotaBaselines: %v", err)
+	}
+	if err := m.SetQuotaBaselines("missing@example.com", map[string]float64{"gemini-3-flash": 1}); err == nil {
+		t.Error("expected an error for an unknown account")
+	}
+
+	reloaded := NewManager(m.storage.ConfigPath())
+	if err := reloaded.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	limits := reloaded.GetAllAccounts()[0].ModelRateLimits
+	want := map[string]ModelRateLimit{
+		"gemini-3-flash":    {QuotaRemaining: 0.8},
+		"claude-opus-4-5":   {QuotaRemaining: 0.1, IsSoftLimited: true},
+		"gemini-3-pro-high": {QuotaRemaining: 1},
+	}
+	for modelID, w := range want {
+		if got := limits[modelID]; got != w {
+			t.Errorf("%s: got %+v, want %+v", modelID, got, w)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// StickyError is an account/model pair that keeps failing the same way and is skipped
+// during selection until it expires.
+type StickyError struct {
+	Email      string    `json:"email"`
+	ModelID    string    `json:"modelId"`
+	StatusCode int       `json:"statusCode"`
+	Message    string    `json:"message"`
+	Failures   int       `json:"failures"`
+	Until      time.Time `json:"until"`
+}
+
+// isStickyStatus reports whether an upstream status fails the same way on every retry
+// with the same account and model: permission denied (e.g. the account's project lacks
+// access to the model) or model not found.
+func isStickyStatus(code int) bool {
+	return code == http.StatusForbidden || code == http.StatusNotFound
+}
+
+// stickyMessageMaxLen caps the upstream error message kept for display.
+const stickyMessageMaxLen = 200
+
+type stickyKey struct {
+	email string
+	model string
+}
+
+type stickyState struct {
+	status   int
+	message  string
+	failures int
+	until    time.Time
+}
+
+// stickyErrorTracker caches repeated deterministic failures per account and model so
+// selection skips the pair instead of spending a failover attempt on it every request.
+type stickyErrorTracker struct {
+	mu     sync.Mutex
+	cfg    config.StickyErrorConfig
+	states map[stickyKey]*stickyState
+}
+
+func newStickyErrorTracker(cfg config.StickyErrorConfig) *stickyErrorTracker {
+	return &stickyErrorTracker{cfg: cfg, states: make(map[stickyKey]*stickyState)}
+}
+
+func (t *stickyErrorTracker) setConfig(cfg config.StickyErrorConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cfg = cfg
+	if cfg.TTL <= 0 {
+		t.states = make(map[stickyKey]*stickyState)
+	}
+}
+
+func (t *stickyErrorTracker) record(result Result, now time.Time) {
+	if result.Email == "" || result.ModelID == "" {
+		return
+	}
+	if result.Err != nil && errors.Is(result.Err, context.Canceled) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cfg.TTL <= 0 {
+		return
+	}
+
+	key := stickyKey{email: result.Email, model: result.ModelID}
+	if !isStickyStatus(result.StatusCode) {
+		// Any other outcome breaks the streak.
+		delete(t.states, key)
+		return
+	}
+
+	st := t.states[key]
+	if st == nil || st.status != result.StatusCode {
+		st = &stickyState{status: result.StatusCode}
+		t.states[key] = st
+	}
+	st.failures++
+	if result.Err != nil {
+		st.message = result.Err.Error()
+		if len(st.message) > stickyMessageMaxLen {
+			st.message = st.message[:stickyMessageMaxLen] + "..."
+		}
+	}
+	if st.failures >= t.cfg.Threshold && !now.Before(st.until) {
+		st.until = now.Add(t.cfg.TTL)
+		utils.Warn("[AccountManager] Skipping %s for %s for %s after %d consecutive %d errors",
+			result.Email, result.ModelID, utils.FormatDuration(t.cfg.TTL), st.failures, st.status)
+	}
+}
+
+func (t *stickyErrorTracker) isSticky(email, modelID string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.states[stickyKey{email: email, model: modelID}]
+	return st != nil && now.Before(st.until)
+}
+
+// active returns the pairs currently skipped, sorted by account then model.
+func (t *stickyErrorTracker) active(now time.Time) []StickyError {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]StickyError, 0)
+	for key, st := range t.states {
+		if !now.Before(st.until) {
+			continue
+		}
+		out = append(out, StickyError{
+			Email:      key.email,
+			ModelID:    key.model,
+			StatusCode: st.status,
+			Message:    st.message,
+			Failures:   st.failures,
+			Until:      st.until,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Email != out[j].Email {
+			return out[i].Email < out[j].Email
+		}
+		return out[i].ModelID < out[j].ModelID
+	})
+	return out
+}
+
+// forget drops the state of one account, or of every account when email is empty.
+// It returns how many skipped pairs were cleared.
+func (t *stickyErrorTracker) forget(email string, now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cleared := 0
+	for key, st := range t.states {
+		if email != "" && key.email != email {
+			continue
+		}
+		if now.Before(st.until) {
+			cleared++
+		}
+		delete(t.states, key)
+	}
+	return cleared
+}
@@ -0,0 +1,91 @@
+package account
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+func TestManager_SkipsStickyErrors(t *testing.T) {
+	mgr := newTestManager(t)
+	for _, email := range []string{"a@x", "b@x"} {
+		if err := mgr.AddAccount(Account{Email: email, Provider: "zai", Source: "manual", APIKey: "k"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mgr.SetHealthConfig(config.AccountHealthConfig{Enabled: false}) // isolate from flaky-account deprioritization
+	mgr.SetStickyErrorConfig(config.StickyErrorConfig{TTL: time.Minute, Threshold: 2})
+	denied := Result{Email: "a@x", Provider: "zai", ModelID: "m", StatusCode: 403, Err: errors.New("permission denied")}
+
+	// One failure is not enough, and a different outcome breaks the streak.
+	mgr.ReportResult(denied)
+	mgr.ReportResult(Result{Email: "a@x", Provider: "zai", ModelID: "m", StatusCode: 500})
+	mgr.ReportResult(denied)
+	if got := mgr.GetStickyErrors(); len(got) != 0 {
+		t.Fatalf("expected no sticky errors yet, got %+v", got)
+	}
+
+	mgr.ReportResult(denied)
+	got := mgr.GetStickyErrors()
+	if len(got) != 1 || got[0].Email != "a@x" || got[0].ModelID != "m" || got[0].StatusCode != 403 || got[0].Message != "permission denied" {
+		t.Fatalf("unexpected sticky errors: %+v", got)
+	}
+
+	for i := 0; i < 4; i++ {
+		if acc := mgr.PickNextByProvider("zai", "m"); acc == nil || acc.Email != "b@x" {
+			t.Fatalf("pick %d: expected b@x, got %v", i, acc)
+		}
+	}
+	// Other models are unaffected.
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		if acc := mgr.PickNextByProvider("zai", "other"); acc != nil {
+			seen[acc.Email] = true
+		}
+	}
+	if !seen["a@x"] {
+		t.Error("expected a@x to stay selectable for other models")
+	}
+
+	// When every account is sticky the request still goes through.
+	mgr.MarkRateLimited("b@x", 60000, "m")
+	if acc := mgr.PickNextByProvider("zai", "m"); acc == nil || acc.Email != "a@x" {
+		t.Errorf("expected sticky a@x as last resort, got %v", acc)
+	}
+
+	if n := mgr.ClearStickyErrors("a@x"); n != 1 {
+		t.Errorf("expected 1 cleared sticky error, got %d", n)
+	}
+	if got := mgr.GetStickyErrors(); len(got) != 0 {
+		t.Errorf("expected sticky errors to be cleared, got %+v", got)
+	}
+}
+
+func TestStickyErrorTracker(t *testing.T) {
+	tracker := newStickyErrorTracker(config.StickyErrorConfig{TTL: time.Minute, Threshold: 1})
+	now := time.Now()
+
+	tracker.record(Result{Email: "a@x", ModelID: "m", StatusCode: 404}, now)
+	if !tracker.isSticky("a@x", "m", now) {
+		t.Fatal("expected 404 to be sticky at threshold 1")
+	}
+	if tracker.isSticky("a@x", "m", now.Add(2*time.Minute)) {
+		t.Error("expected sticky error to expire after the TTL")
+	}
+
+	// Success clears the pair; non-deterministic errors never make it sticky.
+	tracker.record(Result{Email: "a@x", ModelID: "m", StatusCode: 200}, now)
+	tracker.record(Result{Email: "a@x", ModelID: "m", StatusCode: 429, RateLimited: true}, now)
+	tracker.record(Result{Email: "a@x", ModelID: "m", StatusCode: 503}, now)
+	if tracker.isSticky("a@x", "m", now) {
+		t.Error("expected success to clear the sticky error")
+	}
+
+	tracker.setConfig(config.StickyErrorConfig{TTL: 0, Threshold: 1})
+	tracker.record(Result{Email: "a@x", ModelID: "m", StatusCode: 403}, now)
+	if tracker.isSticky("a@x", "m", now) {
+		t.Error("expected TTL 0 to disable sticky errors")
+	}
+}
@@ -2,31 +2,77 @@
 package account
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/egress"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 )
 
-// Account represents a single account for a provider (Antigravity, Z.AI, or Copilot).
+// Account represents a single account for a provider (Antigravity, Z.AI, Anthropic, Vertex, or Copilot).
 type Account struct {
 	Email           string                    `json:"email"`
 	Source          string                    `json:"source"`             // "oauth" or "manual"
-	Provider        string                    `json:"provider,omitempty"` // "antigravity" (default), "zai", or "copilot"
+	Provider        string                    `json:"provider,omitempty"` // "antigravity" (default), "zai", "anthropic", "vertex", or "copilot"
 	RefreshToken    string                    `json:"refreshToken,omitempty"`
 	APIKey          string                    `json:"apiKey,omitempty"`
 	ProjectID       string                    `json:"projectId,omitempty"`
+	ProjectIDs      []string                  `json:"projectIds,omitempty"`  // For Antigravity: more Cloud projects with their own quotas, tried after ProjectID
 	AccountType     string                    `json:"accountType,omitempty"` // For Copilot: "individual", "business", "enterprise"
+	Region          string                    `json:"region,omitempty"`      // For Vertex: overrides VERTEX_REGION
+	Proxy           string                    `json:"proxy,omitempty"`       // Outbound http(s)/socks5(h) proxy URL for this account's upstream requests
+	BindAddress     string                    `json:"bindAddress,omitempty"` // Local IP address this account's upstream requests are sent from
+	Headers         map[string]string         `json:"headers,omitempty"`     // Overrides provider headers (e.g. User-Agent) on this account's upstream requests
 	AddedAt         *time.Time                `json:"addedAt,omitempty"`
 	IsInvalid       bool                      `json:"isInvalid,omitempty"`
 	InvalidReason   NullableString            `json:"invalidReason,omitempty"`
 	InvalidAt       *time.Time                `json:"invalidAt,omitempty"`
 	ModelRateLimits map[string]ModelRateLimit `json:"modelRateLimits,omitempty"`
 	LastUsed        *time.Time                `json:"lastUsed,omitempty"`
+	ArchivedAt      *time.Time                `json:"archivedAt,omitempty"` // Set while soft-deleted
+	Enabled         *bool                     `json:"enabled,omitempty"`    // false benches the account; unset means enabled
+	DisabledAt      *time.Time                `json:"disabledAt,omitempty"`
+
+	// Secret references ("${env:NAME}", "file:PATH") the credentials were loaded from, by JSON field name.
+	secretRefs map[string]secretRef
+}
+
+// IsEnabled reports whether the account may be selected. Accounts are enabled unless
+// disabled with DisableAccount.
+func (a *Account) IsEnabled() bool {
+	return a.Enabled == nil || *a.Enabled
+}
+
+// Projects returns the Cloud projects the account can send requests to, in the order they
+// are tried: ProjectID, then ProjectIDs, without duplicates.
+func (a *Account) Projects() []string {
+	var projects []string
+	for _, id := range append([]string{a.ProjectID}, a.ProjectIDs...) {
+		if id != "" && !slices.Contains(projects, id) {
+			projects = append(projects, id)
+		}
+	}
+	return projects
+}
+
+// Egress returns where the account's upstream requests leave from (Proxy, BindAddress).
+func (a *Account) Egress() egress.Config {
+	return egress.Config{Proxy: a.Proxy, BindAddress: a.BindAddress}
+}
+
+// RequestContext returns ctx carrying the account's egress config and header overrides,
+// for upstream requests made on its behalf.
+func (a *Account) RequestContext(ctx context.Context) context.Context {
+	return config.WithHeaders(egress.WithConfig(ctx, a.Egress()), a.Headers)
 }
 
 // ModelRateLimit tracks rate limit state for a specific model.
@@ -47,14 +93,26 @@ type Settings struct {
 // ConfigFile represents the account configuration file structure.
 type ConfigFile struct {
 	Accounts    []Account `json:"accounts"`
+	Archived    []Account `json:"archivedAccounts,omitempty"` // Soft-deleted accounts kept for restore
 	Settings    Settings  `json:"settings"`
 	ActiveIndex int       `json:"activeIndex"`
 }
 
+// errChangedOnDisk is returned by Save when another process changed the file since it
+// was last loaded or saved, so saving would overwrite that change.
+var errChangedOnDisk = errors.New("account config was changed by another process")
+
 // Storage handles loading and saving account configuration.
 type Storage struct {
 	configPath string
 	mu         sync.RWMutex
+
+	// Fingerprint of the file as last loaded or saved, to detect writes by other processes.
+	known  bool // false until the first Load or Save
+	exists bool
+	sum    [sha256.Size]byte
+
+	backups func() ([]string, error) // Backup files to recover from, newest first; nil disables recovery
 }
 
 // NewStorage creates a new Storage instance.
@@ -65,15 +123,25 @@ func NewStorage(configPath string) *Storage {
 	return &Storage{configPath: configPath}
 }
 
+// SetBackupSource lets Load recover from a corrupt configuration file using the newest
+// valid backup among those list returns (newest first).
+func (s *Storage) SetBackupSource(list func() ([]string, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backups = list
+}
+
 // Load loads accounts from the configuration file.
-// Returns empty config if file doesn't exist.
+// Returns empty config if file doesn't exist. A file that doesn't parse (e.g. truncated by
+// a crash) is replaced by the newest valid backup when a backup source is set.
 func (s *Storage) Load() (*ConfigFile, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	data, err := os.ReadFile(s.configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
+			s.remember(nil, false)
 			// No config file yet - return empty config
 			utils.Info("[AccountManager] No config file found. Add an account using 'accounts add' command")
 			return &ConfigFile{
@@ -91,10 +159,16 @@ func (s *Storage) Load() (*ConfigFile, error) {
 		}, nil
 	}
 
+	s.remember(data, true)
+
 	var cfg ConfigFile
 	if err := json.Unmarshal(data, &cfg); err != nil {
+		if recovered := s.recoverLocked(data, err); recovered != nil {
+			return recovered, nil
+		}
 		// Node parity: treat parse errors as "no accounts" (don't fail init).
 		utils.Error("[AccountManager] Failed to parse config: %v", err)
+		utils.Error("[AccountManager] Run 'multi-claude-proxy restore' to recover from a backup")
 		return &ConfigFile{
 			Accounts:    []Account{},
 			Settings:    Settings{},
@@ -102,6 +176,73 @@ func (s *Storage) Load() (*ConfigFile, error) {
 		}, nil
 	}
 
+	prepareLoaded(&cfg)
+	utils.Info("[AccountManager] Loaded %d account(s) from config", len(cfg.Accounts))
+
+	return &cfg, nil
+}
+
+// Reload re-reads the file if another process changed it since it was last loaded or
+// saved, returning nil when it is unchanged or was deleted. Unlike Load, a file that
+// doesn't parse is an error, so a half-written edit never replaces the loaded accounts.
+func (s *Storage) Reload() (*ConfigFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, exists, err := s.changedLocked()
+	if err != nil || data == nil || !exists {
+		return nil, err
+	}
+
+	var cfg ConfigFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.configPath, err)
+	}
+	s.remember(data, true)
+	prepareLoaded(&cfg)
+	return &cfg, nil
+}
+
+// Lock takes the lock other processes saving the same file also take (the CLI while the
+// server is running), so a reload, change and save happen without interleaving. The
+// returned function releases it.
+func (s *Storage) Lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.configPath), 0755); err != nil {
+		return nil, err
+	}
+	unlock, err := lockFile(s.configPath + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock account config: %w", err)
+	}
+	return unlock, nil
+}
+
+// changedLocked returns the file's content when it differs from the last load or save.
+// It returns nil data when the file is unchanged.
+func (s *Storage) changedLocked() (data []byte, exists bool, err error) {
+	data, err = os.ReadFile(s.configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if s.known && s.exists {
+				return []byte{}, false, nil
+			}
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if !s.known || (s.exists && sha256.Sum256(data) == s.sum) {
+		return nil, true, nil
+	}
+	return data, true, nil
+}
+
+func (s *Storage) remember(data []byte, exists bool) {
+	s.known, s.exists = true, exists
+	s.sum = sha256.Sum256(data)
+}
+
+// prepareLoaded fills in defaults and resolves secret references of freshly read accounts.
+func prepareLoaded(cfg *ConfigFile) {
 	// Initialize maps and reset invalid flag on startup
 	for i := range cfg.Accounts {
 		if cfg.Accounts[i].ModelRateLimits == nil {
@@ -114,56 +255,81 @@ func (s *Storage) Load() (*ConfigFile, error) {
 		// Reset invalid flag on startup - give accounts a fresh chance to refresh
 		cfg.Accounts[i].IsInvalid = false
 		cfg.Accounts[i].InvalidReason = ""
+
+		// Resolve secret references; an account whose secret can't be read is unusable.
+		if err := cfg.Accounts[i].resolveSecrets(); err != nil {
+			now := time.Now()
+			cfg.Accounts[i].IsInvalid = true
+			cfg.Accounts[i].InvalidReason = NullableString(err.Error())
+			cfg.Accounts[i].InvalidAt = &now
+			utils.Error("[AccountManager] Account %s: %v", cfg.Accounts[i].Email, err)
+		} else if err := cfg.Accounts[i].Egress().Validate(); err != nil {
+			now := time.Now()
+			cfg.Accounts[i].IsInvalid = true
+			cfg.Accounts[i].InvalidReason = NullableString(err.Error())
+			cfg.Accounts[i].InvalidAt = &now
+			utils.Error("[AccountManager] Account %s: %v", cfg.Accounts[i].Email, err)
+		}
+	}
+	for i := range cfg.Archived {
+		if cfg.Archived[i].Provider == "" {
+			cfg.Archived[i].Provider = "antigravity"
+		}
+		if err := cfg.Archived[i].resolveSecrets(); err != nil {
+			utils.Warn("[AccountManager] Archived account %s: %v", cfg.Archived[i].Email, err)
+		}
 	}
 
 	// Clamp activeIndex to valid range
 	if cfg.ActiveIndex >= len(cfg.Accounts) {
 		cfg.ActiveIndex = 0
 	}
-
-	utils.Info("[AccountManager] Loaded %d account(s) from config", len(cfg.Accounts))
-
-	return &cfg, nil
 }
 
-// Save saves accounts to the configuration file atomically.
+// Save saves accounts to the configuration file atomically. It returns errChangedOnDisk
+// instead of overwriting changes another process made since the last load or save.
 func (s *Storage) Save(cfg *ConfigFile) error {
+	passphrase, err := config.GetAccountsEncryptionKey()
+	if err != nil {
+		return err
+	}
+	return s.save(cfg, passphrase)
+}
+
+// save is Save with credentials written in plaintext unless encrypted with passphrase.
+// Encrypting records the ciphertext on cfg's accounts (see encryptCredentials).
+func (s *Storage) save(cfg *ConfigFile, passphrase string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Ensure directory exists
-	dir := filepath.Dir(s.configPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if changed, exists, err := s.changedLocked(); err != nil {
 		return err
+	} else if changed != nil && exists {
+		return errChangedOnDisk
 	}
 
-	// Create serializable copy (exclude sensitive data from non-oauth sources)
 	accounts := make([]Account, len(cfg.Accounts))
 	for i, acc := range cfg.Accounts {
-		accounts[i] = Account{
-			Email:           acc.Email,
-			Source:          acc.Source,
-			Provider:        acc.Provider,
-			ProjectID:       acc.ProjectID,
-			AccountType:     acc.AccountType,
-			AddedAt:         acc.AddedAt,
-			IsInvalid:       acc.IsInvalid,
-			InvalidReason:   acc.InvalidReason,
-			ModelRateLimits: acc.ModelRateLimits,
-			LastUsed:        acc.LastUsed,
+		accounts[i] = serializableAccount(acc)
+		if passphrase != "" {
+			if err := encryptCredentials(&cfg.Accounts[i], &accounts[i], passphrase); err != nil {
+				return err
+			}
 		}
-		// Only save refresh token for OAuth accounts
-		if acc.Source == "oauth" {
-			accounts[i].RefreshToken = acc.RefreshToken
-		}
-		// Only save API key for manual accounts
-		if acc.Source == "manual" {
-			accounts[i].APIKey = acc.APIKey
+	}
+	var archived []Account
+	for i, acc := range cfg.Archived {
+		archived = append(archived, serializableAccount(acc))
+		if passphrase != "" {
+			if err := encryptCredentials(&cfg.Archived[i], &archived[i], passphrase); err != nil {
+				return err
+			}
 		}
 	}
 
 	output := ConfigFile{
 		Accounts:    accounts,
+		Archived:    archived,
 		Settings:    cfg.Settings,
 		ActiveIndex: cfg.ActiveIndex,
 	}
@@ -172,6 +338,15 @@ func (s *Storage) Save(cfg *ConfigFile) error {
 	if err != nil {
 		return err
 	}
+	return s.writeLocked(data)
+}
+
+// writeLocked atomically replaces the configuration file with data.
+func (s *Storage) writeLocked(data []byte) error {
+	dir := filepath.Dir(s.configPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
 
 	// Atomic write: write to temp file, then rename
 	tempFile, err := os.CreateTemp(dir, ".accounts-*.tmp")
@@ -212,10 +387,123 @@ func (s *Storage) Save(cfg *ConfigFile) error {
 		return err
 	}
 
+	s.remember(data, true)
 	success = true
 	return nil
 }
 
+// recoverLocked replaces a configuration file that failed to parse with the newest valid
+// backup, keeping the broken file next to it as <name>.corrupt-<timestamp>. It returns nil
+// when there is no backup source or no usable backup.
+func (s *Storage) recoverLocked(broken []byte, cause error) *ConfigFile {
+	if s.backups == nil {
+		return nil
+	}
+	paths, err := s.backups()
+	if err != nil {
+		utils.Error("[AccountManager] Failed to list backups: %v", err)
+		return nil
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			utils.Warn("[AccountManager] Skipping backup %s: %v", path, err)
+			continue
+		}
+		cfg, err := ValidateConfigData(data)
+		if err != nil {
+			utils.Warn("[AccountManager] Skipping backup %s: %v", path, err)
+			continue
+		}
+
+		kept := s.configPath + ".corrupt-" + time.Now().UTC().Format("20060102T150405Z")
+		if err := os.WriteFile(kept, broken, 0600); err != nil {
+			utils.Error("[AccountManager] Failed to keep the corrupt config as %s: %v", kept, err)
+			return nil
+		}
+		if err := s.writeLocked(data); err != nil {
+			utils.Error("[AccountManager] Failed to restore %s from %s: %v", s.configPath, path, err)
+			return nil
+		}
+
+		utils.Error("[AccountManager] ==================================================================")
+		utils.Error("[AccountManager] %s is corrupt: %v", s.configPath, cause)
+		utils.Error("[AccountManager] Recovered %d account(s) from backup %s", len(cfg.Accounts), path)
+		utils.Error("[AccountManager] Account changes made after that backup are lost; the corrupt file was kept as %s", kept)
+		utils.Error("[AccountManager] ==================================================================")
+		prepareLoaded(cfg)
+		return cfg
+	}
+	utils.Error("[AccountManager] No valid backup to recover %s from", s.configPath)
+	return nil
+}
+
+// serializableAccount returns the persisted form of an account
+// (excludes sensitive data from non-oauth sources, keeps secret references unresolved).
+func serializableAccount(acc Account) Account {
+	out := Account{
+		Email:           acc.Email,
+		Source:          acc.Source,
+		Provider:        acc.Provider,
+		ProjectID:       acc.ProjectID,
+		ProjectIDs:      acc.ProjectIDs,
+		AccountType:     acc.AccountType,
+		Region:          acc.Region,
+		Proxy:           acc.Proxy,
+		BindAddress:     acc.BindAddress,
+		Headers:         acc.Headers,
+		AddedAt:         acc.AddedAt,
+		IsInvalid:       acc.IsInvalid,
+		InvalidReason:   acc.InvalidReason,
+		ModelRateLimits: acc.ModelRateLimits,
+		LastUsed:        acc.LastUsed,
+		ArchivedAt:      acc.ArchivedAt,
+		Enabled:         acc.Enabled,
+		DisabledAt:      acc.DisabledAt,
+		secretRefs:      acc.secretRefs,
+	}
+	// Only save refresh token for OAuth accounts
+	if acc.Source == "oauth" {
+		out.RefreshToken = acc.RefreshToken
+	}
+	// Only save API key for manual accounts
+	if acc.Source == "manual" {
+		out.APIKey = acc.APIKey
+	}
+	// Write secret references back rather than the secrets they resolved to
+	out.restoreSecretRefs()
+	return out
+}
+
+// ValidateConfigData checks that data is a well-formed account configuration file.
+// Unlike Load, it rejects files that don't parse instead of treating them as empty.
+func ValidateConfigData(data []byte) (*ConfigFile, error) {
+	var cfg ConfigFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid account config: %w", err)
+	}
+	if cfg.Accounts == nil {
+		return nil, fmt.Errorf("invalid account config: missing accounts")
+	}
+
+	seen := make(map[string]bool, len(cfg.Accounts))
+	for i, acc := range cfg.Accounts {
+		if acc.Email == "" {
+			return nil, fmt.Errorf("invalid account config: account %d has no email", i)
+		}
+		if seen[acc.Email] {
+			return nil, fmt.Errorf("invalid account config: duplicate account %s", acc.Email)
+		}
+		seen[acc.Email] = true
+	}
+	for i, acc := range cfg.Archived {
+		if acc.Email == "" {
+			return nil, fmt.Errorf("invalid account config: archived account %d has no email", i)
+		}
+	}
+	return &cfg, nil
+}
+
 // ConfigPath returns the path to the configuration file.
 func (s *Storage) ConfigPath() string {
 	return s.configPath
@@ -0,0 +1,146 @@
+package account
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// SyncFromDisk applies changes another process (usually the accounts CLI while the server
+// is running) saved to the account config since this manager last loaded or saved it.
+// Accounts and archived accounts follow the file; settings, and rate limit and invalid
+// state of accounts whose credentials didn't change, are kept from memory.
+func (m *Manager) SyncFromDisk() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.syncFromDiskLocked()
+}
+
+// StartDiskSync calls SyncFromDisk every interval until stop is closed.
+func (m *Manager) StartDiskSync(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.SyncFromDisk(); err != nil {
+					utils.Warn("[AccountManager] Failed to reload account config: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// lockStorageLocked takes the cross-process lock on the account config and applies any
+// changes saved by other processes, so the caller's change and save build on the latest
+// file. The caller must hold m.mu and call the returned function once saved.
+func (m *Manager) lockStorageLocked() (func(), error) {
+	unlock, err := m.storage.Lock()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.syncFromDiskLocked(); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// saveToDiskSyncedLocked saves under the cross-process lock after applying changes other
+// processes saved. The caller must hold m.mu.
+func (m *Manager) saveToDiskSyncedLocked() error {
+	unlock, err := m.lockStorageLocked()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = m.saveToDiskLocked()
+	if errors.Is(err, errChangedOnDisk) {
+		// Without file locks (non-unix) a write can land between the sync and the save.
+		if err := m.syncFromDiskLocked(); err != nil {
+			return err
+		}
+		err = m.saveToDiskLocked()
+	}
+	return err
+}
+
+func (m *Manager) syncFromDiskLocked() error {
+	cfg, err := m.storage.Reload()
+	if err != nil || cfg == nil {
+		return err
+	}
+
+	previous := make(map[string]Account, len(m.accounts))
+	for _, acc := range m.accounts {
+		previous[acc.Email] = acc
+	}
+	providerEmails := make(map[string]string, len(m.currentIndexByProvider))
+	for provider, i := range m.currentIndexByProvider {
+		if i >= 0 && i < len(m.accounts) {
+			providerEmails[provider] = m.accounts[i].Email
+		}
+	}
+
+	for i := range cfg.Accounts {
+		acc := &cfg.Accounts[i]
+		old, ok := previous[acc.Email]
+		if !ok {
+			continue
+		}
+		delete(previous, acc.Email)
+		if !sameCredentials(old, *acc) {
+			delete(m.tokenCache, acc.Email)
+			delete(m.projectCache, acc.Email)
+			delete(m.blacklisted, acc.Email)
+			continue
+		}
+		acc.ModelRateLimits = old.ModelRateLimits
+		acc.LastUsed = old.LastUsed
+		acc.IsInvalid = old.IsInvalid
+		acc.InvalidReason = old.InvalidReason
+		acc.InvalidAt = old.InvalidAt
+	}
+	for email := range previous {
+		delete(m.tokenCache, email)
+		delete(m.projectCache, email)
+		m.health.forget(email)
+		m.sticky.forget(email, time.Now())
+		delete(m.draining, email)
+		delete(m.blacklisted, email)
+	}
+
+	m.accounts = cfg.Accounts
+	m.archived = cfg.Archived
+	if m.currentIndex >= len(m.accounts) {
+		m.currentIndex = 0
+	}
+	m.currentIndexByProvider = make(map[string]int, len(providerEmails))
+	for provider, email := range providerEmails {
+		if i := m.findAccountIndexLocked(email); i >= 0 {
+			m.currentIndexByProvider[provider] = i
+		}
+	}
+
+	utils.Info("[AccountManager] Reloaded account config changed by another process (%d account(s))", len(m.accounts))
+	return nil
+}
+
+// sameCredentials reports whether a and b authenticate the same way, so cached tokens and
+// runtime state of one still apply to the other.
+func sameCredentials(a, b Account) bool {
+	return a.Source == b.Source && a.Provider == b.Provider &&
+		a.RefreshToken == b.RefreshToken && a.APIKey == b.APIKey &&
+		a.ProjectID == b.ProjectID && slices.Equal(a.ProjectIDs, b.ProjectIDs) &&
+		a.AccountType == b.AccountType && a.Region == b.Region
+}
@@ -0,0 +1,113 @@
+package account
+
+import (
+	"testing"
+)
+
+func TestSaveToDisk_KeepsChangesFromOtherProcess(t *testing.T) {
+	server := newTestManager(t)
+	if err := server.AddAccount(Account{Email: "a@example.com", Source: "oauth", Provider: "antigravity", RefreshToken: "rt-a"}); err != nil {
+		t.Fatal(err)
+	}
+	server.MarkRateLimited("a@example.com", 60000, "model")
+
+	// The CLI adds an account while the server is running.
+	cli := NewManager(server.storage.ConfigPath())
+	if err := cli.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.AddAccount(Account{Email: "b@example.com", Source: "manual", Provider: "zai", APIKey: "key-b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server's next save must not drop it.
+	if err := server.SaveToDisk(); err != nil {
+		t.Fatalf("SaveToDisk: %v", err)
+	}
+	if got := server.GetAccountCount(); got != 2 {
+		t.Fatalf("server accounts = %d, want 2", got)
+	}
+	if !server.IsAllRateLimitedByProvider("antigravity", "model") {
+		t.Error("expected rate limit state to be kept across the reload")
+	}
+
+	reloaded := NewManager(server.storage.ConfigPath())
+	if err := reloaded.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.GetAccountCount(); got != 2 {
+		t.Fatalf("saved accounts = %d, want 2", got)
+	}
+}
+
+func TestSyncFromDisk(t *testing.T) {
+	server := newTestManager(t)
+	if err := server.AddAccount(Account{Email: "a@example.com", Source: "oauth", Provider: "antigravity", RefreshToken: "rt-a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddAccount(Account{Email: "b@example.com", Source: "oauth", Provider: "antigravity", RefreshToken: "rt-b"}); err != nil {
+		t.Fatal(err)
+	}
+	server.tokenCache["a@example.com"] = TokenCacheEntry{Token: "cached-a"}
+	server.tokenCache["b@example.com"] = TokenCacheEntry{Token: "cached-b"}
+
+	// Nothing changed: no reload.
+	if err := server.SyncFromDisk(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.tokenCache["a@example.com"]; !ok {
+		t.Fatal("expected token cache to survive a sync without changes")
+	}
+
+	cli := NewManager(server.storage.ConfigPath())
+	if err := cli.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.UpdateOAuthCredentials("a@example.com", "rt-a2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.RemoveAccount("b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.SyncFromDisk(); err != nil {
+		t.Fatalf("SyncFromDisk: %v", err)
+	}
+	accounts := server.GetAllAccounts()
+	if len(accounts) != 1 || accounts[0].Email != "a@example.com" || accounts[0].RefreshToken != "rt-a2" {
+		t.Fatalf("unexpected accounts after sync: %+v", accounts)
+	}
+	if archived := server.GetArchivedAccounts(); len(archived) != 1 || archived[0].Email != "b@example.com" {
+		t.Fatalf("unexpected archived accounts after sync: %+v", archived)
+	}
+	if _, ok := server.tokenCache["a@example.com"]; ok {
+		t.Error("expected token cache of re-authenticated account to be cleared")
+	}
+	if _, ok := server.tokenCache["b@example.com"]; ok {
+		t.Error("expected token cache of removed account to be cleared")
+	}
+}
+
+func TestStorage_SaveRejectsExternalChange(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.AddAccount(Account{Email: "a@example.com", Source: "manual", Provider: "zai", APIKey: "key-a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewStorage(m.storage.ConfigPath())
+	cfg, err := other.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Accounts = append(cfg.Accounts, Account{Email: "b@example.com", Source: "manual", Provider: "zai", APIKey: "key-b"})
+	if err := other.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.saveToDiskLocked(); err != errChangedOnDisk {
+		t.Fatalf("expected errChangedOnDisk, got %v", err)
+	}
+	if err := other.Save(cfg); err != nil {
+		t.Fatalf("saving over its own write should succeed, got %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/antigravity"
+)
+
+// maxDrainWait caps the ?wait= duration accepted by the drain endpoint.
+const maxDrainWait = 5 * time.Minute
+
+// handleAccountDrain handles /admin/accounts/{email}/drain.
+//
+//	POST   stops new selections of the account; ?wait=30s blocks until it is idle (or the wait expires)
+//	GET    reports drain state and in-flight requests
+//	DELETE resumes the account
+//
+// Responds 200 once the account is idle and 202 while requests are still in flight.
+func (s *Server) handleAccountDrain(w http.ResponseWriter, r *http.Request) {
+	if s.accountManager == nil {
+		writeAdminError(w, http.StatusInternalServerError, "No account manager configured")
+		return
+	}
+	email := r.PathValue("email")
+
+	var (
+		status account.DrainStatus
+		err    error
+	)
+	switch r.Method {
+	case http.MethodPost:
+		var wait time.Duration
+		if raw := r.URL.Query().Get("wait"); raw != "" {
+			wait, err = time.ParseDuration(raw)
+			if err != nil || wait < 0 {
+				writeAdminError(w, http.StatusBadRequest, "Invalid wait duration: "+raw)
+				return
+			}
+			wait = min(wait, maxDrainWait)
+		}
+		status, err = s.accountManager.DrainAccount(email)
+		if err == nil && wait > 0 && !status.Idle {
+			ctx, cancel := context.WithTimeout(r.Context(), wait)
+			status, err = s.accountManager.WaitForDrain(ctx, email)
+			cancel()
+		}
+	case http.MethodGet:
+		status, err = s.accountManager.GetDrainStatus(email)
+	case http.MethodDelete:
+		if err = s.accountManager.ResumeAccount(email); err == nil {
+			status, err = s.accountManager.GetDrainStatus(email)
+		}
+	default:
+		s.handleNotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		writeAdminError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	code := http.StatusOK
+	if status.Draining && !status.Idle {
+		code = http.StatusAccepted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"account": status,
+	})
+}
+
+// handleAccountDisable handles /admin/accounts/{email}/disable.
+//
+//	POST   takes the account out of selection, keeping its credentials
+//	GET    reports whether the account is enabled
+//	DELETE enables the account again
+func (s *Server) handleAccountDisable(w http.ResponseWriter, r *http.Request) {
+	if s.accountManager == nil {
+		writeAdminError(w, http.StatusInternalServerError, "No account manager configured")
+		return
+	}
+	email := r.PathValue("email")
+	if _, ok := s.accountManager.GetAccount(email); !ok {
+		writeAdminError(w, http.StatusNotFound, "account "+email+" not found")
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		err = s.accountManager.DisableAccount(email)
+	case http.MethodGet:
+	case http.MethodDelete:
+		err = s.accountManager.EnableAccount(email)
+	default:
+		s.handleNotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	acc, _ := s.accountManager.GetAccount(email)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok",
+		"account": map[string]interface{}{
+			"email":      acc.Email,
+			"enabled":    acc.IsEnabled(),
+			"disabledAt": acc.DisabledAt,
+		},
+	})
+}
+
+// handleStickyErrors handles /admin/sticky-errors.
+//
+//	GET    lists account/model pairs skipped after repeated deterministic failures
+//	DELETE makes them selectable again, for one account with ?email= or for all accounts
+func (s *Server) handleStickyErrors(w http.ResponseWriter, r *http.Request) {
+	if s.accountManager == nil {
+		writeAdminError(w, http.StatusInternalServerError, "No account manager configured")
+		return
+	}
+
+	resp := map[string]interface{}{"status": "ok"}
+	switch r.Method {
+	case http.MethodGet:
+		resp["stickyErrors"] = s.accountManager.GetStickyErrors()
+	case http.MethodDelete:
+		resp["cleared"] = s.accountManager.ClearStickyErrors(r.URL.Query().Get("email"))
+	default:
+		s.handleNotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// handleReload handles POST /admin/reload, which re-reads the config file and applies
+// the settings that can change at runtime. Requests in flight are not interrupted.
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.handleNotFound(w, r)
+		return
+	}
+	if s.reload == nil {
+		writeAdminError(w, http.StatusNotImplemented, "Config reload is not available")
+		return
+	}
+
+	changes, err := s.reload()
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if changes == nil {
+		changes = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"changes": changes,
+	})
+}
+
+// handleDebugEndpoints handles GET /debug/endpoints, listing the Cloud Code endpoints in
+// the order the next Antigravity request tries them, with their rolling latency, error
+// rate and circuit breaker state.
+func (s *Server) handleDebugEndpoints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.handleNotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "ok",
+		"endpoints": antigravity.EndpointStats(),
+	})
+}
+
+// writeAdminError writes an error in the {"status":"error"} shape used by the admin endpoints.
+func writeAdminError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "error",
+		"error":  message,
+	})
+}
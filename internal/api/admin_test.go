@@ -0,0 +1,262 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/telemetry"
+)
+
+func TestHandleAccountDrain(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	dir, err := os.MkdirTemp("", "mcp-admin-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	mgr := account.NewManager(filepath.Join(dir, "accounts.json"))
+	if err := mgr.AddAccount(account.Account{Email: "a@x", Provider: "zai", Source: "manual", APIKey: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewServer(nil, mgr).Handler()
+
+	do := func(method, path string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("x-api-key", "test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var body map[string]interface{}
+		_ = json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body
+	}
+
+	// A request in flight keeps the account busy after draining starts.
+	mgr.BeginRequest("a@x")
+	code, body := do(http.MethodPost, "/admin/accounts/a@x/drain")
+	if code != http.StatusAccepted {
+		t.Fatalf("expected 202 while busy, got %d: %v", code, body)
+	}
+	if acc := mgr.PickNextByProvider("zai", "m"); acc != nil {
+		t.Errorf("expected draining account not to be selected, got %s", acc.Email)
+	}
+
+	mgr.ReportResult(account.Result{Email: "a@x", Provider: "zai", StatusCode: 200})
+	code, body = do(http.MethodGet, "/admin/accounts/a@x/drain")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 once idle, got %d: %v", code, body)
+	}
+	status := body["account"].(map[string]interface{})
+	if status["idle"] != true || status["draining"] != true {
+		t.Errorf("expected draining idle account, got %v", status)
+	}
+
+	code, _ = do(http.MethodDelete, "/admin/accounts/a@x/drain")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 on resume, got %d", code)
+	}
+	if acc := mgr.PickNextByProvider("zai", "m"); acc == nil {
+		t.Error("expected resumed account to be selectable")
+	}
+
+	if code, _ = do(http.MethodPost, "/admin/accounts/missing@x/drain"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown account, got %d", code)
+	}
+	if code, _ = do(http.MethodPost, "/admin/accounts/a@x/drain?wait=soon"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid wait, got %d", code)
+	}
+}
+
+func TestHandleAccountDisable(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	mgr := newTestManager(t, testAccount("a@x", "zai"))
+	handler := NewServer(nil, mgr).Handler()
+
+	do := func(method, path string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("x-api-key", "test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var body map[string]interface{}
+		_ = json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body
+	}
+
+	code, body := do(http.MethodPost, "/admin/accounts/a@x/disable")
+	if code != http.StatusOK || body["account"].(map[string]interface{})["enabled"] != false {
+		t.Fatalf("expected disabled account, got %d: %v", code, body)
+	}
+	if acc := mgr.PickNextByProvider("zai", "m"); acc != nil {
+		t.Errorf("expected disabled account not to be selected, got %s", acc.Email)
+	}
+
+	code, body = do(http.MethodDelete, "/admin/accounts/a@x/disable")
+	if code != http.StatusOK || body["account"].(map[string]interface{})["enabled"] != true {
+		t.Fatalf("expected enabled account, got %d: %v", code, body)
+	}
+	if acc := mgr.PickNextByProvider("zai", "m"); acc == nil {
+		t.Error("expected enabled account to be selectable")
+	}
+
+	if code, _ = do(http.MethodPost, "/admin/accounts/missing@x/disable"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown account, got %d", code)
+	}
+}
+
+func TestHandleStickyErrors(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	mgr := account.NewManager(filepath.Join(t.TempDir(), "accounts.json"))
+	if err := mgr.AddAccount(account.Account{Email: "a@x", Provider: "zai", Source: "manual", APIKey: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		mgr.ReportResult(account.Result{Email: "a@x", Provider: "zai", ModelID: "m", StatusCode: 403})
+	}
+	handler := NewServer(nil, mgr).Handler()
+
+	do := func(method, path string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("x-api-key", "test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var body map[string]interface{}
+		_ = json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body
+	}
+
+	code, body := do(http.MethodGet, "/admin/sticky-errors")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, body)
+	}
+	if list, _ := body["stickyErrors"].([]interface{}); len(list) != 1 {
+		t.Fatalf("expected 1 sticky error, got %v", body)
+	}
+
+	code, body = do(http.MethodDelete, "/admin/sticky-errors?email=a@x")
+	if code != http.StatusOK || body["cleared"] != float64(1) {
+		t.Errorf("expected 1 cleared, got %d: %v", code, body)
+	}
+	_, body = do(http.MethodGet, "/admin/sticky-errors")
+	if list, _ := body["stickyErrors"].([]interface{}); len(list) != 0 {
+		t.Errorf("expected no sticky errors after clearing, got %v", body)
+	}
+}
+
+func TestHandleReload(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	s := NewServer(nil, nil)
+	do := func(method string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, "/admin/reload", nil)
+		req.Header.Set("x-api-key", "test-key")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		var body map[string]interface{}
+		_ = json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body
+	}
+
+	if code, _ := do(http.MethodPost); code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without a reload func, got %d", code)
+	}
+
+	var reloadErr error
+	s.SetReloadFunc(func() ([]string, error) {
+		if reloadErr != nil {
+			return nil, reloadErr
+		}
+		return []string{"provider zai disabled"}, nil
+	})
+	code, body := do(http.MethodPost)
+	if changes, _ := body["changes"].([]interface{}); code != http.StatusOK || len(changes) != 1 || changes[0] != "provider zai disabled" {
+		t.Errorf("unexpected reload response %d: %v", code, body)
+	}
+
+	reloadErr = errors.New("invalid config")
+	if code, body := do(http.MethodPost); code != http.StatusBadRequest || body["error"] != "invalid config" {
+		t.Errorf("expected 400 with the reload error, got %d: %v", code, body)
+	}
+	if code, _ := do(http.MethodGet); code != http.StatusNotFound {
+		t.Errorf("expected 404 for GET, got %d", code)
+	}
+}
+
+func TestHandleDebugEndpoints(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	handler := NewServer(nil, nil).Handler()
+	do := func(method string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, "/debug/endpoints", nil)
+		req.Header.Set("x-api-key", "test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var body map[string]interface{}
+		_ = json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body
+	}
+
+	code, body := do(http.MethodGet)
+	endpoints, _ := body["endpoints"].([]interface{})
+	if code != http.StatusOK || len(endpoints) != len(config.AntigravityEndpointFallbacks) {
+		t.Fatalf("expected every Cloud Code endpoint, got %d: %v", code, body)
+	}
+	for _, e := range endpoints {
+		stat, _ := e.(map[string]interface{})
+		if stat["endpoint"] == "" || stat["breaker"] == nil || stat["healthy"] == nil {
+			t.Errorf("incomplete endpoint stats: %v", stat)
+		}
+	}
+	if code, _ := do(http.MethodPost); code != http.StatusNotFound {
+		t.Errorf("expected 404 for POST, got %d", code)
+	}
+}
+
+func TestHandleTelemetry(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	registry := provider.NewRegistry()
+	if err := registry.Register(&retryingProvider{&mockProvider{name: "zai", models: []string{"glm-4.7"}}}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+	s.SetTelemetry(telemetry.New(config.TelemetryConfig{}, "1.2.3", nil))
+	handler := s.Handler()
+
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("x-api-key", "test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var resp map[string]interface{}
+		_ = json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp
+	}
+
+	do(http.MethodPost, "/v1/messages", `{"model":"zai/glm-4.7","messages":[{"role":"user","content":"hi"}]}`)
+
+	code, body := do(http.MethodGet, "/admin/telemetry", "")
+	if code != http.StatusOK || body["enabled"] != false {
+		t.Fatalf("expected a disabled preview, got %d: %v", code, body)
+	}
+	payload, _ := body["payload"].(map[string]interface{})
+	providers, _ := payload["providers"].(map[string]interface{})
+	zai, _ := providers["zai"].(map[string]interface{})
+	if payload["version"] != "1.2.3" || zai["requests"] != float64(1) {
+		t.Errorf("expected the zai request in the preview, got %v", payload)
+	}
+	if code, _ := do(http.MethodPost, "/admin/telemetry", ""); code != http.StatusNotFound {
+		t.Errorf("expected 404 for POST, got %d", code)
+	}
+}
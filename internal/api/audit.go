@@ -0,0 +1,153 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/audit"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// headerRequestID returns the audit record ID of a /v1 request, e.g. for POST /admin/replay.
+const headerRequestID = "X-MCP-Request-Id"
+
+// maxAuditBodyBytes caps how much of a single request or response body is kept for the audit log.
+const maxAuditBodyBytes = 4 * 1024 * 1024
+
+// AuditLog records metadata (and optionally redacted bodies) for every /v1 request.
+// A nil logger disables the middleware.
+func AuditLog(logger *audit.Logger, next http.Handler) http.Handler {
+	if logger == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		requestID := uuid.NewString()
+		w.Header().Set(headerRequestID, requestID)
+		reqCapture := &capturingReadCloser{ReadCloser: r.Body}
+		r.Body = reqCapture
+		rw := &auditResponseWriter{ResponseWriter: w, statusCode: http.StatusOK, captureBody: logger.LogBodies()}
+		stats := &requestStats{}
+
+		next.ServeHTTP(rw, r.WithContext(withRequestStats(r.Context(), stats)))
+
+		entry := audit.Entry{
+			Timestamp:     start.UTC(),
+			RequestID:     requestID,
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			RemoteAddr:    r.RemoteAddr,
+			Headers:       audit.RedactHeaders(r.Header),
+			Status:        rw.statusCode,
+			DurationMs:    time.Since(start).Milliseconds(),
+			RequestBytes:  reqCapture.n,
+			ResponseBytes: rw.n,
+			RequestBody:   reqCapture.buf.Bytes(),
+			ResponseBody:  rw.buf.Bytes(),
+
+			RequestTruncated:  reqCapture.n > int64(reqCapture.buf.Len()),
+			ResponseTruncated: rw.captureBody && rw.n > int64(rw.buf.Len()),
+
+			User:            stats.User,
+			InputTokens:     stats.InputTokens,
+			OutputTokens:    stats.OutputTokens,
+			TokensPerSecond: stats.TokensPerSecond,
+			Annotations:     stats.Annotations,
+		}
+
+		entry.Model, entry.Stream = requestMeta(reqCapture.buf.Bytes())
+
+		if err := logger.Log(entry); err != nil {
+			utils.Warn("[Audit] Failed to write audit entry: %v", err)
+		}
+	})
+}
+
+// requestMeta returns the model and stream fields of a request body. A body cut off at
+// maxAuditBodyBytes still gives the fields that come before the cut.
+func requestMeta(body []byte) (model string, stream bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return "", false
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch key {
+		case "model":
+			err = dec.Decode(&model)
+		case "stream":
+			err = dec.Decode(&stream)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return model, stream
+}
+
+// capturingReadCloser tees the request body into a bounded buffer as the handler reads it.
+type capturingReadCloser struct {
+	io.ReadCloser
+	buf bytes.Buffer
+	n   int64
+}
+
+func (c *capturingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		c.n += int64(n)
+		if remaining := maxAuditBodyBytes - c.buf.Len(); remaining > 0 {
+			c.buf.Write(p[:min(n, remaining)])
+		}
+	}
+	return n, err
+}
+
+// auditResponseWriter captures status, size and (optionally) the body of a response.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	captureBody bool
+	buf         bytes.Buffer
+	n           int64
+}
+
+func (rw *auditResponseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *auditResponseWriter) Write(p []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(p)
+	rw.n += int64(n)
+	if rw.captureBody {
+		if remaining := maxAuditBodyBytes - rw.buf.Len(); remaining > 0 {
+			rw.buf.Write(p[:min(n, remaining)])
+		}
+	}
+	return n, err
+}
+
+func (rw *auditResponseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,25 @@
+package api
+
+import "testing"
+
+func TestRequestMeta(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantModel  string
+		wantStream bool
+	}{
+		{"whole body", `{"messages":[{"role":"user","content":"hi"}],"model":"zai/glm-4.7","stream":true}`, "zai/glm-4.7", true},
+		{"cut after the fields", `{"model":"zai/glm-4.7","stream":true,"messages":[{"role":"user","content":"h`, "zai/glm-4.7", true},
+		{"cut before stream", `{"model":"zai/glm-4.7","messages":[{"role":"user","content":"h`, "zai/glm-4.7", false},
+		{"not an object", `data: {}`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, stream := requestMeta([]byte(tt.body))
+			if model != tt.wantModel || stream != tt.wantStream {
+				t.Errorf("got %q, %v; want %q, %v", model, stream, tt.wantModel, tt.wantStream)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"crypto/subtle"
 	"encoding/json"
 	"errors"
@@ -18,12 +19,15 @@ var errInvalidAuthFormat = errors.New("invalid authorization header format")
 //   - Header: x-api-key: <key>
 //   - Header: Authorization: Bearer <key>
 //
-// Health endpoint (/health) is exempt from authentication.
+// Health endpoints (/health, /health/live, /health/ready and the PROBE_LIVE_PATH and
+// PROBE_READY_PATH overrides) and the /dashboard page (which prompts for the key itself)
+// are exempt from authentication. The preStop hook is not.
+// Virtual keys (VIRTUAL_KEYS) are accepted on /v1 endpoints and /usage only.
 // Returns 500 Internal Server Error if PROXY_API_KEY is not configured.
 func APIKeyAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Health endpoint is exempt from authentication
-		if r.URL.Path == "/health" {
+		// Health endpoints and the dashboard page are exempt from authentication
+		if isHealthPath(r.URL.Path) || r.URL.Path == "/dashboard" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -54,14 +58,43 @@ func APIKeyAuth(next http.Handler) http.Handler {
 
 		// Validate API key using constant-time comparison to prevent timing attacks
 		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(expectedKey)) != 1 {
-			writeAuthError(w, "Invalid API key")
-			return
+			name, ok := matchVirtualKey(apiKey)
+			if !ok {
+				writeAuthError(w, "Invalid API key")
+				return
+			}
+			if !strings.HasPrefix(r.URL.Path, "/v1/") && r.URL.Path != "/usage" {
+				writeError(w, http.StatusForbidden, "permission_error", "Virtual API keys can only use /v1 endpoints and /usage")
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), virtualKeyKey{}, name))
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+type virtualKeyKey struct{}
+
+// virtualKeyFromContext returns the name of the virtual key a request authenticated with,
+// or "" for PROXY_API_KEY.
+func virtualKeyFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(virtualKeyKey{}).(string)
+	return name
+}
+
+// matchVirtualKey returns the name of the virtual key apiKey is. Every key is compared, in
+// constant time, so the time taken doesn't tell which one nearly matched.
+func matchVirtualKey(apiKey string) (string, bool) {
+	var match string
+	for name, key := range config.GetVirtualKeys() {
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) == 1 {
+			match = name
+		}
+	}
+	return match, match != ""
+}
+
 // extractAPIKey extracts the API key from the request headers.
 // Returns the API key and nil error if found.
 // Returns empty string and nil error if no key found.
@@ -125,3 +158,13 @@ func writeServerError(w http.ResponseWriter, message string) {
 	// Error handling: if encoding fails, response is already on wire - nothing more to do
 	_ = json.NewEncoder(w).Encode(resp)
 }
+
+// isHealthPath reports whether path is one of the health check endpoints, including the
+// configured probe paths.
+func isHealthPath(path string) bool {
+	if path == "/health" || path == "/health/live" || path == "/health/ready" {
+		return true
+	}
+	probes := config.GetProbeConfig()
+	return path == probes.LivePath || path == probes.ReadyPath
+}
@@ -227,3 +227,37 @@ func TestAPIKeyAuth_EmptyAPIKeyConfigured(t *testing.T) {
 		t.Errorf("error type = %q, want %q", resp.Error.Type, expectedType)
 	}
 }
+
+func TestAPIKeyAuth_VirtualKeys(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "master-key")
+	t.Setenv("VIRTUAL_KEYS", "team-a=sk-team-a")
+
+	var gotKey string
+	handler := APIKeyAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = virtualKeyFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(path, key string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("x-api-key", key)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := send("/v1/messages", "sk-team-a"); code != http.StatusOK || gotKey != "team-a" {
+		t.Errorf("virtual key on /v1: status %d, key %q", code, gotKey)
+	}
+	if code := send("/usage", "sk-team-a"); code != http.StatusOK {
+		t.Errorf("virtual key on /usage: status %d", code)
+	}
+	if code := send("/admin/reload", "sk-team-a"); code != http.StatusForbidden {
+		t.Errorf("virtual key on /admin: status %d, want 403", code)
+	}
+	if code := send("/v1/messages", "sk-other"); code != http.StatusUnauthorized {
+		t.Errorf("unknown key: status %d, want 401", code)
+	}
+	if code := send("/admin/reload", "master-key"); code != http.StatusOK || gotKey != "" {
+		t.Errorf("master key: status %d, key %q", code, gotKey)
+	}
+}
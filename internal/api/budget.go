@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/budget"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// Budget headers report what is left of the budget of the virtual key a request used,
+// after counting the request. They are omitted when the key has no such budget.
+const (
+	headerBudgetRemainingTokens   = "X-MCP-Budget-Remaining-Tokens"
+	headerBudgetRemainingRequests = "X-MCP-Budget-Remaining-Requests"
+)
+
+// budgetEnforcer holds virtual keys and accounts to their budgets (KEY_BUDGET_* and
+// ACCOUNT_BUDGET_*).
+type budgetEnforcer struct {
+	tracker *budget.Tracker
+	cfg     config.BudgetConfig
+}
+
+func keySubject(name string) string      { return "key:" + name }
+func accountSubject(email string) string { return "account:" + email }
+
+// accountOverBudget reports whether an account has used up its budget. It is the account
+// manager's budget check, so over-budget accounts are skipped like rate-limited ones.
+func (b *budgetEnforcer) accountOverBudget(email string) bool {
+	_, _, exceeded := b.tracker.Exceeded(accountSubject(email), b.cfg.ForAccount(email))
+	return exceeded
+}
+
+// providerExhausted reports whether every one of the usable accounts emails has used up its
+// budget, with the reason of the one that resets first and when it does.
+func (b *budgetEnforcer) providerExhausted(emails []string) (string, time.Time, bool) {
+	var (
+		reason string
+		reset  time.Time
+	)
+	for _, email := range emails {
+		r, t, exceeded := b.tracker.Exceeded(accountSubject(email), b.cfg.ForAccount(email))
+		if !exceeded {
+			return "", time.Time{}, false
+		}
+		if reset.IsZero() || t.Before(reset) {
+			reason, reset = fmt.Sprintf("account %s has used its %s", email, r), t
+		}
+	}
+	return reason, reset, len(emails) > 0
+}
+
+// writeBudgetExceeded rejects a request with a 429 budget_exceeded error and a Retry-After
+// until the budget resets.
+func writeBudgetExceeded(w http.ResponseWriter, reason string, reset time.Time) {
+	wait := max(time.Until(reset), 0)
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+	writeError(w, http.StatusTooManyRequests, "budget_exceeded",
+		fmt.Sprintf("Budget exceeded: %s; resets in %s", reason, formatDuration(wait)))
+}
+
+// setBudgetHeaders sets the budget headers from what is left of a budget.
+func setBudgetHeaders(h http.Header, left budget.Remaining) {
+	if left.Tokens != nil {
+		h.Set(headerBudgetRemainingTokens, strconv.FormatInt(*left.Tokens, 10))
+	}
+	if left.Requests != nil {
+		h.Set(headerBudgetRemainingRequests, strconv.FormatInt(*left.Requests, 10))
+	}
+}
+
+// Budgets rejects /v1 requests from virtual keys that have used up their budget with a 429
+// budget_exceeded error, and counts the requests it admits, their tokens and their estimated
+// cost against the key and the account that served them. /v1/models, which never reaches an upstream, is exempt.
+// A nil enforcer disables it.
+func Budgets(b *budgetEnforcer, next http.Handler) http.Handler {
+	if b == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1/") || r.URL.Path == "/v1/models" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := virtualKeyFromContext(r.Context())
+		if key != "" {
+			limits := b.cfg.ForKey(key)
+			if reason, reset, exceeded := b.tracker.Exceeded(keySubject(key), limits); exceeded {
+				writeBudgetExceeded(w, fmt.Sprintf("API key %s has used its %s", key, reason), reset)
+				return
+			}
+			b.tracker.Add(keySubject(key), 1, 0)
+			if limits.Limited() {
+				setBudgetHeaders(w.Header(), b.tracker.Usage(keySubject(key)).Remaining(limits))
+			}
+		}
+
+		stats := requestStatsFromContext(r.Context())
+		if stats == nil {
+			stats = &requestStats{}
+			r = r.WithContext(withRequestStats(r.Context(), stats))
+		}
+		next.ServeHTTP(w, r)
+
+		tokens := int64(stats.InputTokens + stats.OutputTokens)
+		if key != "" {
+			b.tracker.Add(keySubject(key), 0, tokens)
+			b.tracker.AddSpend(keySubject(key), stats.Cost)
+		}
+		if stats.Account != "" {
+			b.tracker.Add(accountSubject(stats.Account), 1, tokens)
+			b.tracker.AddSpend(accountSubject(stats.Account), stats.Cost)
+		}
+	})
+}
+
+// budgetUsage is the /usage entry of one virtual key or account.
+type budgetUsage struct {
+	Name      string              `json:"name"`
+	Usage     budget.Usage        `json:"usage"`
+	Limits    config.BudgetLimits `json:"limits"`
+	Remaining budget.Remaining    `json:"remaining"`
+}
+
+func (b *budgetEnforcer) usage(name, subject string, limits config.BudgetLimits) budgetUsage {
+	u := b.tracker.Usage(subject)
+	return budgetUsage{Name: name, Usage: u, Limits: limits, Remaining: u.Remaining(limits)}
+}
+
+// handleUsage handles GET /usage. A virtual key sees its own usage, estimated spend and
+// budget; PROXY_API_KEY sees those of every virtual key and account.
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.handleNotFound(w, r)
+		return
+	}
+	if s.budgets == nil {
+		writeAdminError(w, http.StatusNotImplemented, "Usage tracking is not enabled; configure VIRTUAL_KEYS, a budget or MODEL_PRICING_CONFIG")
+		return
+	}
+	b := s.budgets
+
+	if key := virtualKeyFromContext(r.Context()); key != "" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "ok",
+			"key":    b.usage(key, keySubject(key), b.cfg.ForKey(key)),
+		})
+		return
+	}
+
+	keys := []budgetUsage{}
+	for name := range config.GetVirtualKeys() {
+		keys = append(keys, b.usage(name, keySubject(name), b.cfg.ForKey(name)))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	accounts := []budgetUsage{}
+	if s.accountManager != nil {
+		for _, acc := range s.accountManager.GetAllAccounts() {
+			accounts = append(accounts, b.usage(acc.Email, accountSubject(acc.Email), b.cfg.ForAccount(acc.Email)))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "ok",
+		"keys":     keys,
+		"accounts": accounts,
+	})
+}
+
+// providerOverBudget reports whether every enabled, valid account of a provider has used
+// up its budget, so a request for it can't be served until one resets.
+func (s *Server) providerOverBudget(providerName string) (string, time.Time, bool) {
+	if s.budgets == nil || !s.budgets.cfg.Enabled() || s.accountManager == nil {
+		return "", time.Time{}, false
+	}
+	var emails []string
+	for _, acc := range s.accountManager.GetAllAccountsByProvider(providerName) {
+		if !acc.IsInvalid && acc.IsEnabled() {
+			emails = append(emails, acc.Email)
+		}
+	}
+	return s.budgets.providerExhausted(emails)
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/budget"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+func newBudgetTestServer(t *testing.T, emails ...string) *Server {
+	t.Helper()
+	var accounts []account.Account
+	for _, email := range emails {
+		accounts = append(accounts, testAccount(email, "zai"))
+	}
+	return NewServer(nil, newTestManager(t, accounts...))
+}
+
+func TestBudgets_Middleware(t *testing.T) {
+	t.Setenv("KEY_BUDGET_DAILY_REQUESTS", "team-a=2")
+	t.Setenv("KEY_BUDGET_MONTHLY_TOKENS", "*=1000")
+	b := &budgetEnforcer{tracker: budget.NewTracker(), cfg: config.GetBudgetConfig()}
+	handler := Budgets(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := requestStatsFromContext(r.Context())
+		stats.InputTokens, stats.OutputTokens, stats.Account, stats.Cost = 100, 50, "a@example.com", 0.25
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+		if key != "" {
+			r = r.WithContext(context.WithValue(r.Context(), virtualKeyKey{}, key))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send("team-a")
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", w.Code)
+	}
+	if got := w.Header().Get(headerBudgetRemainingRequests); got != "1" {
+		t.Errorf("%s = %q, want 1", headerBudgetRemainingRequests, got)
+	}
+	if got := w.Header().Get(headerBudgetRemainingTokens); got != "1000" {
+		t.Errorf("%s = %q, want 1000 (tokens are counted once the request completes)", headerBudgetRemainingTokens, got)
+	}
+	send("team-a")
+
+	w = send("team-a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("over budget: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	var body struct {
+		Error struct{ Type, Message string } `json:"error"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	if body.Error.Type != "budget_exceeded" || !strings.Contains(body.Error.Message, "team-a") {
+		t.Errorf("error = %+v, want budget_exceeded naming the key", body.Error)
+	}
+
+	// PROXY_API_KEY has no budget, but the accounts it uses are still counted.
+	if w := send(""); w.Code != http.StatusOK || w.Header().Get(headerBudgetRemainingRequests) != "" {
+		t.Errorf("master key: status %d, budget header %q", w.Code, w.Header().Get(headerBudgetRemainingRequests))
+	}
+	if u := b.tracker.Usage(accountSubject("a@example.com")); u.DailyRequests != 3 || u.DailyTokens != 450 || u.MonthlySpend != 0.75 {
+		t.Errorf("account usage = %+v, want 3 requests, 450 tokens and $0.75", u)
+	}
+	if u := b.tracker.Usage(keySubject("team-a")); u.DailySpend != 0.5 {
+		t.Errorf("key spend = %g, want $0.50", u.DailySpend)
+	}
+}
+
+func TestProviderOverBudget(t *testing.T) {
+	t.Setenv("ACCOUNT_BUDGET_DAILY_TOKENS", "*=100")
+	s := newBudgetTestServer(t, "a@example.com", "b@example.com")
+	tracker := budget.NewTracker()
+	s.SetBudgets(tracker, config.GetBudgetConfig())
+
+	tracker.Add(accountSubject("a@example.com"), 1, 100)
+	if _, _, exhausted := s.providerOverBudget("zai"); exhausted {
+		t.Fatal("expected b@example.com to still have budget")
+	}
+	if acc := s.accountManager.PickNextByProvider("zai", ""); acc == nil || acc.Email != "b@example.com" {
+		t.Fatalf("picked %v, want the account with budget left", acc)
+	}
+
+	tracker.Add(accountSubject("b@example.com"), 1, 100)
+	reason, _, exhausted := s.providerOverBudget("zai")
+	if !exhausted || !strings.Contains(reason, "daily budget of 100 tokens") {
+		t.Fatalf("providerOverBudget = %q %v, want exhausted", reason, exhausted)
+	}
+}
+
+func TestHandleUsage(t *testing.T) {
+	t.Setenv("VIRTUAL_KEYS", "team-a=sk-a,team-b=sk-b")
+	t.Setenv("KEY_BUDGET_MONTHLY_REQUESTS", "team-a=10")
+	s := newBudgetTestServer(t, "a@example.com")
+	tracker := budget.NewTracker()
+	s.SetBudgets(tracker, config.GetBudgetConfig())
+	tracker.Add(keySubject("team-a"), 4, 0)
+
+	get := func(key string) map[string]json.RawMessage {
+		r := httptest.NewRequest(http.MethodGet, "/usage", nil)
+		if key != "" {
+			r = r.WithContext(context.WithValue(r.Context(), virtualKeyKey{}, key))
+		}
+		w := httptest.NewRecorder()
+		s.handleUsage(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /usage: status %d: %s", w.Code, w.Body.String())
+		}
+		var resp map[string]json.RawMessage
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	own := get("team-a")
+	var entry budgetUsage
+	_ = json.Unmarshal(own["key"], &entry)
+	if entry.Name != "team-a" || entry.Remaining.Requests == nil || *entry.Remaining.Requests != 6 {
+		t.Errorf("key usage = %+v, want 6 requests left", entry)
+	}
+	if _, ok := own["keys"]; ok {
+		t.Error("a virtual key must not see other keys")
+	}
+
+	all := get("")
+	var keys, accounts []budgetUsage
+	_ = json.Unmarshal(all["keys"], &keys)
+	_ = json.Unmarshal(all["accounts"], &accounts)
+	if len(keys) != 2 || keys[0].Name != "team-a" || len(accounts) != 1 {
+		t.Errorf("keys = %+v, accounts = %+v", keys, accounts)
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// coalescer sends identical concurrent non-streaming requests upstream once and hands the
+// response to every caller (REQUEST_COALESCING_ENABLED). Requests are only joined while the
+// first one is in flight; nothing is kept after it completes.
+type coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall is an upstream request shared by its waiters.
+type coalescedCall struct {
+	done    chan struct{}
+	resp    *types.AnthropicResponse
+	err     error
+	trace   *provider.Trace
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{calls: make(map[string]*coalescedCall)}
+}
+
+// do returns the response of send for key, joining a call with the same key already in
+// flight; shared is true when it did. send runs under a context of its own, carrying none of
+// the caller's values but a trace that is merged into each caller's, so send must add what
+// routing the request needs. The call outlives the caller that started it and is cancelled
+// when every waiter has gone away. Each caller gets its own copy of the response.
+func (c *coalescer) do(ctx context.Context, key string, send func(context.Context) (*types.AnthropicResponse, error)) (resp *types.AnthropicResponse, shared bool, err error) {
+	c.mu.Lock()
+	call, shared := c.calls[key]
+	if !shared {
+		callCtx, cancel := context.WithCancel(context.Background())
+		call = &coalescedCall{done: make(chan struct{}), trace: &provider.Trace{}, cancel: cancel}
+		callCtx = provider.WithTrace(callCtx, call.trace)
+		c.calls[key] = call
+		go func() {
+			call.resp, call.err = send(callCtx)
+			c.mu.Lock()
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+			c.mu.Unlock()
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		c.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Nobody wants the response any more; later requests start a new call.
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+			call.cancel()
+		}
+		c.mu.Unlock()
+		return nil, shared, ctx.Err()
+	}
+	provider.TraceFromContext(ctx).Merge(call.trace)
+	if call.err != nil {
+		return nil, shared, call.err
+	}
+	copied := *call.resp
+	copied.Content = slices.Clone(call.resp.Content)
+	return &copied, shared, nil
+}
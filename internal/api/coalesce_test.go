@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// gatedProvider answers non-streaming requests once release is closed, counting the calls.
+type gatedProvider struct {
+	mockProvider
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (p *gatedProvider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	p.calls.Add(1)
+	provider.TraceFromContext(ctx).Attempt("a@example.com")
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &types.AnthropicResponse{
+		ID: "msg_1", Type: "message", Role: "assistant", Model: req.Model, StopReason: "end_turn",
+		Content: []types.ContentBlock{{Type: "text", Text: "pong"}},
+		Usage:   types.Usage{InputTokens: 3, OutputTokens: 1},
+	}, nil
+}
+
+func TestHandleMessages_RequestCoalescing(t *testing.T) {
+	metrics.CoalescedRequests.Reset()
+	defer metrics.CoalescedRequests.Reset()
+
+	registry := provider.NewRegistry()
+	prov := &gatedProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, release: make(chan struct{})}
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+	s.SetRequestCoalescing(true)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.handleMessages(w, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body)))
+		return w
+	}
+	const identical = 4
+	results := make([]*httptest.ResponseRecorder, identical+1)
+	var wg sync.WaitGroup
+	for i := range identical {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Key order and whitespace don't make requests different.
+			body := `{"model":"zai/glm-4.7","max_tokens":16,"messages":[{"role":"user","content":"ping"}]}`
+			if i%2 == 1 {
+				body = `{"messages": [{"content": "ping", "role": "user"}], "max_tokens": 16, "model": "zai/glm-4.7"}`
+			}
+			results[i] = send(body)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[identical] = send(`{"model":"zai/glm-4.7","max_tokens":16,"messages":[{"role":"user","content":"other"}]}`)
+	}()
+
+	waiters := func() int {
+		s.coalescer.mu.Lock()
+		defer s.coalescer.mu.Unlock()
+		n := 0
+		for _, call := range s.coalescer.calls {
+			n += call.waiters
+		}
+		return n
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for waiters() < identical+1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d requests waiting, got %d", identical+1, waiters())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(prov.release)
+	wg.Wait()
+
+	if n := prov.calls.Load(); n != 2 {
+		t.Errorf("expected 2 upstream calls (one per distinct request), got %d", n)
+	}
+	coalesced := 0
+	for _, w := range results {
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"pong"`) {
+			t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+		}
+		// Every caller reports the upstream call that served it.
+		if w.Header().Get("X-MCP-Account") != "a@example.com" || w.Header().Get("X-MCP-Attempts") != "1" {
+			t.Errorf("expected the trace of the upstream call, got headers %v", w.Header())
+		}
+		if w.Header().Get("X-Proxy-Coalesced") == "true" {
+			coalesced++
+		}
+	}
+	if coalesced != identical-1 || metrics.CoalescedRequests.Count("zai", "glm-4.7") != identical-1 {
+		t.Errorf("expected %d coalesced responses, got %d", identical-1, coalesced)
+	}
+}
+
+func TestCoalescer_CancelledWaiters(t *testing.T) {
+	c := newCoalescer()
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	send := func(ctx context.Context) (*types.AnthropicResponse, error) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := c.do(ctx, "key", send)
+		done <- err
+	}()
+	<-started
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatal("expected the cancelled caller to get an error")
+	}
+
+	// With its only waiter gone, the upstream call is cancelled and later requests start anew.
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the upstream call to be cancelled")
+	}
+	resp, shared, err := c.do(context.Background(), "key", func(ctx context.Context) (*types.AnthropicResponse, error) {
+		return &types.AnthropicResponse{ID: "msg_2"}, nil
+	})
+	if err != nil || shared || resp.ID != "msg_2" {
+		t.Errorf("expected a new call, got %+v, shared=%v, err=%v", resp, shared, err)
+	}
+}
+
+func TestCoalescer_SharedCallContext(t *testing.T) {
+	type callerKey struct{}
+	c := newCoalescer()
+	trace := &provider.Trace{}
+	ctx := provider.WithTrace(context.WithValue(context.Background(), callerKey{}, "leader"), trace)
+	_, _, err := c.do(ctx, "key", func(ctx context.Context) (*types.AnthropicResponse, error) {
+		if ctx.Value(callerKey{}) != nil {
+			t.Error("expected the shared call not to carry the caller's values")
+		}
+		provider.TraceFromContext(ctx).Attempt("a@example.com")
+		provider.TraceFromContext(ctx).RateLimited("b@example.com")
+		return &types.AnthropicResponse{ID: "msg_1"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace.Attempts() != 1 || trace.Account() != "a@example.com" || trace.RateLimitedAccounts() != 1 {
+		t.Errorf("expected the shared call's trace merged into the caller's, got %d attempts by %q, %d rate-limited",
+			trace.Attempts(), trace.Account(), trace.RateLimitedAccounts())
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+var errConcurrencyLimit = errors.New("concurrency limit reached")
+
+// concurrencyLimiter caps in-flight /v1/messages requests per provider and per provider/model.
+// Requests beyond a limit wait up to QueueTimeout for a slot before being rejected.
+type concurrencyLimiter struct {
+	cfg config.ConcurrencyConfig
+
+	mu       sync.Mutex
+	inFlight map[string]int
+	wake     chan struct{} // closed and replaced whenever a slot is released
+}
+
+func newConcurrencyLimiter(cfg config.ConcurrencyConfig) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		cfg:      cfg,
+		inFlight: make(map[string]int),
+		wake:     make(chan struct{}),
+	}
+}
+
+type concurrencySlot struct {
+	key string
+	max int
+}
+
+// slots returns the limits that apply to a request. The per-account limit also caps the
+// provider at accounts*limit, so requests queue here instead of finding every account busy.
+func (l *concurrencyLimiter) slots(providerName, model string, accountCount int) []concurrencySlot {
+	providerMax := l.cfg.PerProvider
+	if l.cfg.PerAccount > 0 && accountCount > 0 {
+		if accountMax := l.cfg.PerAccount * accountCount; providerMax <= 0 || accountMax < providerMax {
+			providerMax = accountMax
+		}
+	}
+
+	var slots []concurrencySlot
+	if providerMax > 0 {
+		slots = append(slots, concurrencySlot{key: providerName, max: providerMax})
+	}
+	if l.cfg.PerModel > 0 {
+		slots = append(slots, concurrencySlot{key: providerName + "/" + model, max: l.cfg.PerModel})
+	}
+	return slots
+}
+
+// acquire reserves a slot for a request, waiting up to the queue timeout.
+// The returned release func must be called once the request (or stream) ends.
+func (l *concurrencyLimiter) acquire(ctx context.Context, providerName, model string, accountCount int) (func(), error) {
+	slots := l.slots(providerName, model, accountCount)
+	if len(slots) == 0 {
+		return func() {}, nil
+	}
+
+	var deadline <-chan time.Time
+	if l.cfg.QueueTimeout > 0 {
+		timer := time.NewTimer(l.cfg.QueueTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		l.mu.Lock()
+		if l.freeLocked(slots) {
+			for _, s := range slots {
+				l.inFlight[s.key]++
+			}
+			l.mu.Unlock()
+
+			var once sync.Once
+			return func() { once.Do(func() { l.release(slots) }) }, nil
+		}
+		wake := l.wake
+		l.mu.Unlock()
+
+		if deadline == nil {
+			return nil, errConcurrencyLimit
+		}
+		select {
+		case <-wake:
+		case <-deadline:
+			return nil, errConcurrencyLimit
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (l *concurrencyLimiter) freeLocked(slots []concurrencySlot) bool {
+	for _, s := range slots {
+		if l.inFlight[s.key] >= s.max {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *concurrencyLimiter) release(slots []concurrencySlot) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, s := range slots {
+		l.inFlight[s.key]--
+		if l.inFlight[s.key] <= 0 {
+			delete(l.inFlight, s.key)
+		}
+	}
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
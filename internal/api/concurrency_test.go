@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+)
+
+func TestConcurrencyLimiter_RejectsBeyondLimit(t *testing.T) {
+	l := newConcurrencyLimiter(config.ConcurrencyConfig{PerModel: 1})
+
+	release, err := l.acquire(context.Background(), "zai", "glm", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.acquire(context.Background(), "zai", "glm", 0); !errors.Is(err, errConcurrencyLimit) {
+		t.Fatalf("expected concurrency limit error, got %v", err)
+	}
+
+	// Other models have their own slots.
+	if releaseOther, err := l.acquire(context.Background(), "zai", "glm-air", 0); err != nil {
+		t.Errorf("unexpected error for another model: %v", err)
+	} else {
+		releaseOther()
+	}
+
+	release()
+	release() // Releasing twice must not free an extra slot.
+	if r, err := l.acquire(context.Background(), "zai", "glm", 0); err != nil {
+		t.Errorf("expected slot after release, got %v", err)
+	} else {
+		r()
+	}
+	if len(l.inFlight) != 0 {
+		t.Errorf("expected no in-flight requests, got %v", l.inFlight)
+	}
+}
+
+func TestConcurrencyLimiter_QueuesUntilRelease(t *testing.T) {
+	l := newConcurrencyLimiter(config.ConcurrencyConfig{PerProvider: 1, QueueTimeout: 5 * time.Second})
+
+	release, err := l.acquire(context.Background(), "zai", "glm", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		r, err := l.acquire(context.Background(), "zai", "glm-air", 0)
+		if err == nil {
+			r()
+		}
+		acquired <- err
+	}()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("queued request should wait, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	if err := <-acquired; err != nil {
+		t.Errorf("queued request failed: %v", err)
+	}
+}
+
+func TestConcurrencyLimiter_PerAccountCapsProvider(t *testing.T) {
+	l := newConcurrencyLimiter(config.ConcurrencyConfig{PerAccount: 2, PerProvider: 10})
+
+	slots := l.slots("zai", "glm", 3)
+	if len(slots) != 1 || slots[0].key != "zai" || slots[0].max != 6 {
+		t.Errorf("expected provider slot capped at 3 accounts x 2, got %+v", slots)
+	}
+	// Providers without accounts only use their own limit.
+	if slots := l.slots("vllm", "llama", 0); len(slots) != 1 || slots[0].max != 10 {
+		t.Errorf("unexpected slots without accounts: %+v", slots)
+	}
+}
+
+func TestHandleMessages_ConcurrencyLimit(t *testing.T) {
+	registry := provider.NewRegistry()
+	if err := registry.Register(&mockProvider{name: "antigravity", models: []string{"claude-x"}}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+	s.SetConcurrencyLimits(config.ConcurrencyConfig{PerProvider: 1})
+
+	// Hold the only slot.
+	release, err := s.limiter.acquire(context.Background(), "antigravity", "claude-x", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages",
+		strings.NewReader(`{"model":"antigravity/claude-x","messages":[{"role":"user","content":"hi"}]}`))
+	w := httptest.NewRecorder()
+	s.handleMessages(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+)
+
+// dashboardHTML is the single-page dashboard. It holds no data itself: the page asks for
+// the proxy API key and reads /health, /admin/requests and the admin endpoints with it.
+//
+//go:embed static/dashboard.html
+var dashboardHTML []byte
+
+// handleDashboard handles GET /dashboard.
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.handleNotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	_, _ = w.Write(dashboardHTML)
+}
+
+// handleResetRateLimits handles POST /admin/rate-limits/reset[?provider=...], clearing
+// rate limits for one provider or for every account.
+func (s *Server) handleResetRateLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.handleNotFound(w, r)
+		return
+	}
+	if s.accountManager == nil {
+		writeAdminError(w, http.StatusInternalServerError, "No account manager configured")
+		return
+	}
+
+	providerName := r.URL.Query().Get("provider")
+	if providerName != "" {
+		s.accountManager.ResetAllRateLimitsByProvider(providerName)
+	} else {
+		s.accountManager.ResetAllRateLimits()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "ok",
+		"provider": providerName,
+	})
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestDashboard_ServedWithoutAPIKey(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+	handler := NewServer(nil, nil).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected dashboard page, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	// The data endpoints it calls still require the key.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/requests", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for /admin/requests without key, got %d", rec.Code)
+	}
+}
+
+func TestRecentRequests_RecordsV1Requests(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	registry := provider.NewRegistry()
+	prov := &mockProvider{
+		name:    "zai",
+		models:  []string{"glm-4.7"},
+		account: "a@example.com",
+		streamEvents: []types.StreamEvent{
+			{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant"}},
+			{Type: "message_delta", Delta: &types.Delta{StopReason: "end_turn"}, Usage: &types.Usage{OutputTokens: 30}},
+			{Type: "message_stop"},
+		},
+	}
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewServer(registry, nil).Handler()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("x-api-key", "test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	do(http.MethodPost, "/v1/messages", `{"model":"zai/glm-4.7","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	do(http.MethodPost, "/v1/messages", `{"model":"zai/glm-4.7","messages":"hi"}`)
+	do(http.MethodGet, "/health/live", "")
+
+	rec := do(http.MethodGet, "/admin/requests?limit=10", "")
+	var body struct {
+		Requests  []recentRequest `json:"requests"`
+		Usage     []usageBucket   `json:"usage"`
+		Latencies []modelLatency  `json:"latencies"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Requests) != 2 {
+		t.Fatalf("expected 2 /v1 requests, got %+v", body.Requests)
+	}
+	if r := body.Requests[0]; r.Status != http.StatusBadRequest {
+		t.Errorf("expected newest request first, got %+v", r)
+	}
+	if r := body.Requests[1]; r.Provider != "zai" || r.Model != "glm-4.7" || r.Account != "a@example.com" || r.OutputTokens != 30 || r.FirstEventMs <= 0 || r.Status != http.StatusOK {
+		t.Errorf("unexpected streamed request entry: %+v", r)
+	}
+	if len(body.Usage) != 1 || body.Usage[0].Requests != 2 || body.Usage[0].Errors != 1 || body.Usage[0].OutputTokens != 30 {
+		t.Errorf("unexpected usage buckets: %+v", body.Usage)
+	}
+	if len(body.Latencies) != 1 || body.Latencies[0].Account != "a@example.com" || body.Latencies[0].Requests != 1 || body.Latencies[0].AvgFirstEventMs <= 0 {
+		t.Errorf("unexpected latencies: %+v", body.Latencies)
+	}
+}
+
+func TestRequestLog_RingAndUsageWindow(t *testing.T) {
+	log := newRequestLog(3)
+	now := time.Now().UTC().Truncate(time.Minute)
+	log.add(recentRequest{Timestamp: now.Add(-2 * time.Hour), Path: "old", OutputTokens: 5})
+	for i, path := range []string{"a", "b", "c"} {
+		log.add(recentRequest{Timestamp: now.Add(time.Duration(i) * time.Second), Path: path, OutputTokens: 10})
+	}
+
+	requests, usage := log.snapshot(0)
+	if len(requests) != 3 || requests[0].Path != "c" || requests[2].Path != "a" {
+		t.Errorf("unexpected ring contents: %+v", requests)
+	}
+	if len(usage) != 1 || usage[0].OutputTokens != 30 {
+		t.Errorf("expected only the current minute in usage, got %+v", usage)
+	}
+}
+
+func TestHandleResetRateLimits(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	dir, err := os.MkdirTemp("", "mcp-dashboard-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	mgr := account.NewManager(filepath.Join(dir, "accounts.json"))
+	for _, acc := range []account.Account{
+		{Email: "z@x", Provider: "zai", Source: "manual", APIKey: "k"},
+		{Email: "a@x", Provider: "anthropic", Source: "manual", APIKey: "k"},
+	} {
+		if err := mgr.AddAccount(acc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mgr.MarkRateLimited("z@x", 60000, "m")
+	mgr.MarkRateLimited("a@x", 60000, "m")
+	handler := NewServer(nil, mgr).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/rate-limits/reset?provider=zai", nil)
+	req.Header.Set("x-api-key", "test-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if mgr.IsAllRateLimitedByProvider("zai", "m") {
+		t.Error("expected zai rate limits to be reset")
+	}
+	if !mgr.IsAllRateLimitedByProvider("anthropic", "m") {
+		t.Error("expected anthropic rate limits to be kept")
+	}
+}
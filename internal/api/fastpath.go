@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// streamEventData returns the JSON an event is forwarded as. Events from the stream fast
+// path (STREAM_FAST_PATH_ENABLED) carry their upstream bytes, which are used as they are.
+func streamEventData(event types.StreamEvent) ([]byte, error) {
+	if raw, ok := event.Raw.(json.RawMessage); ok {
+		return raw, nil
+	}
+	var payload interface{} = event
+	if event.Raw != nil {
+		payload = event.Raw
+	}
+	return json.Marshal(payload)
+}
+
+// setRawMessageModel sets message.model in a fast path message_start event, decoding only
+// the two objects on the way to it; every other value keeps its upstream bytes. Message ids
+// are left as sent, since the proxy never changes them. data is returned as is when it has
+// no message object.
+func setRawMessageModel(data json.RawMessage, model string) json.RawMessage {
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(data, &event); err != nil {
+		return data
+	}
+	var message map[string]json.RawMessage
+	if err := json.Unmarshal(event["message"], &message); err != nil || message == nil {
+		return data
+	}
+	name, err := json.Marshal(model)
+	if err != nil {
+		return data
+	}
+	message["model"] = name
+	if event["message"], err = json.Marshal(message); err != nil {
+		return data
+	}
+	out, err := json.Marshal(event)
+	if err != nil {
+		return data
+	}
+	return out
+}
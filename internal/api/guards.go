@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/policy"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// checkRequestGuard returns why req exceeds guard (MAX_REQUEST_*), or "" when it doesn't.
+// model is the public model ID named in the error.
+func checkRequestGuard(req *types.AnthropicRequest, guard config.RequestGuard, model string) string {
+	if guard.MaxMessages > 0 && len(req.Messages) > guard.MaxMessages {
+		return fmt.Sprintf("Request has %d messages, over the limit of %d for %s; start a new conversation or compact it",
+			len(req.Messages), guard.MaxMessages, model)
+	}
+	if guard.MaxTextChars > 0 {
+		chars := 0
+		for _, text := range policy.RequestTexts(req) {
+			chars += utf8.RuneCountInString(text)
+		}
+		if chars > guard.MaxTextChars {
+			return fmt.Sprintf("Request has %d characters of text, over the limit of %d for %s; shorten the conversation (e.g. fewer or smaller tool results)",
+				chars, guard.MaxTextChars, model)
+		}
+	}
+	if guard.MaxImages > 0 {
+		if images := countImages(req); images > guard.MaxImages {
+			return fmt.Sprintf("Request has %d images, over the limit of %d for %s; remove some images from the conversation",
+				images, guard.MaxImages, model)
+		}
+	}
+	return ""
+}
+
+// countImages counts the image blocks of req's messages, including those in tool results.
+func countImages(req *types.AnthropicRequest) int {
+	n := 0
+	for _, msg := range req.Messages {
+		n += countContentImages(msg.Content)
+	}
+	return n
+}
+
+func countContentImages(content json.RawMessage) int {
+	blocks, err := types.ParseMessageContent(content)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, block := range blocks {
+		switch block.Type {
+		case "image":
+			n++
+		case "tool_result":
+			n += countContentImages(block.Content)
+		}
+	}
+	return n
+}
@@ -12,15 +12,27 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/audit"
+	"github.com/kuzerno1/multi-claude-proxy/internal/budget"
+	"github.com/kuzerno1/multi-claude-proxy/internal/cache"
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
 	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/policy"
 	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
 	"github.com/kuzerno1/multi-claude-proxy/internal/provider/antigravity"
 	"github.com/kuzerno1/multi-claude-proxy/internal/provider/copilot"
 	"github.com/kuzerno1/multi-claude-proxy/internal/provider/zai"
+	"github.com/kuzerno1/multi-claude-proxy/internal/quota"
+	"github.com/kuzerno1/multi-claude-proxy/internal/session"
+	"github.com/kuzerno1/multi-claude-proxy/internal/telemetry"
+	"github.com/kuzerno1/multi-claude-proxy/internal/tracing"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
@@ -32,6 +44,27 @@ type Server struct {
 	registry       *provider.Registry
 	accountManager *account.Manager
 	agClient       *antigravity.Client
+	auditLog       *audit.Logger
+	respCache      *cache.ResponseCache
+	sessions       *session.Store
+	budgets        *budgetEnforcer
+	pricing        config.ModelPricing
+	equivalents    map[string][]string // Soft-limit rerouting targets (SOFT_LIMIT_SCHEDULER=global); nil disables
+	coalescer      *coalescer
+	limiter        *concurrencyLimiter
+	clientLimiter  *clientRateLimiter
+	health         *healthCache
+	quotaTracker   *quota.Tracker
+	oauth          oauthFlows
+	recent         *requestLog
+	streams        *streamRegistry
+	telemetry      *telemetry.Reporter
+	policy         *policy.Chain
+	requestRules   *policy.Rules
+	lifecycle      lifecycle
+	modelAliases   atomic.Pointer[map[string]string]
+	reload         func() ([]string, error)
+	pingInterval   time.Duration // idle time before a stream gets a ping; 0 disables idle pings
 }
 
 // NewServer creates a new API server with the given provider registry.
@@ -40,6 +73,98 @@ func NewServer(registry *provider.Registry, accountManager *account.Manager) *Se
 		registry:       registry,
 		accountManager: accountManager,
 		agClient:       antigravity.NewClient(),
+		recent:         newRequestLog(recentRequestsCapacity),
+		streams:        newStreamRegistry(),
+		pingInterval:   config.GetStreamPingInterval(),
+	}
+}
+
+// SetAuditLogger enables request/response auditing. Pass nil to disable it.
+func (s *Server) SetAuditLogger(logger *audit.Logger) {
+	s.auditLog = logger
+}
+
+// SetResponseCache enables caching of identical /v1/messages requests. Pass nil to disable it.
+func (s *Server) SetResponseCache(c *cache.ResponseCache) {
+	s.respCache = c
+}
+
+// SetSessionStore enables session affinity: later turns of a conversation prefer the
+// account that served the earlier ones. Pass nil to disable it.
+func (s *Server) SetSessionStore(store *session.Store) {
+	s.sessions = store
+}
+
+// SetBudgets enables usage tracking for virtual keys and accounts and holds them to the
+// budgets in cfg: over-budget keys are rejected and over-budget accounts are skipped. Pass
+// a nil tracker to disable it. Call it before Handler.
+func (s *Server) SetBudgets(tracker *budget.Tracker, cfg config.BudgetConfig) {
+	s.budgets = nil
+	if tracker != nil {
+		s.budgets = &budgetEnforcer{tracker: tracker, cfg: cfg}
+	}
+	if s.accountManager != nil {
+		if s.budgets != nil && cfg.Enabled() {
+			s.accountManager.SetBudgetCheck(s.budgets.accountOverBudget)
+		} else {
+			s.accountManager.SetBudgetCheck(nil)
+		}
+	}
+}
+
+// SetModelPricing sets the prices used to estimate what requests would cost
+// (MODEL_PRICING_CONFIG). Pass nil to disable spend estimates.
+func (s *Server) SetModelPricing(pricing config.ModelPricing) {
+	s.pricing = pricing
+}
+
+// SetModelEquivalents enables the global soft limit scheduler: a request for a model whose
+// provider has only soft-limited accounts left goes to the first equivalent model whose
+// provider has an account that isn't, keyed by "provider/model" (MODEL_EQUIVALENTS). Pass
+// nil to keep soft limits within each provider.
+func (s *Server) SetModelEquivalents(equivalents map[string][]string) {
+	s.equivalents = equivalents
+}
+
+// SetRequestCoalescing enables sending identical concurrent non-streaming /v1/messages
+// requests upstream once.
+func (s *Server) SetRequestCoalescing(enabled bool) {
+	s.coalescer = nil
+	if enabled {
+		s.coalescer = newCoalescer()
+	}
+}
+
+// SetModelAliases maps alias model IDs to the model IDs they resolve to (MODEL_ALIASES).
+// It is safe to call while serving requests.
+func (s *Server) SetModelAliases(aliases map[string]string) {
+	s.modelAliases.Store(&aliases)
+}
+
+// SetReloadFunc enables POST /admin/reload. fn re-applies the configuration and returns
+// a description of each change.
+func (s *Server) SetReloadFunc(fn func() ([]string, error)) {
+	s.reload = fn
+}
+
+// SetConcurrencyLimits caps in-flight /v1/messages requests per provider, model and account.
+func (s *Server) SetConcurrencyLimits(cfg config.ConcurrencyConfig) {
+	if !cfg.Enabled() {
+		s.limiter = nil
+		return
+	}
+	s.limiter = newConcurrencyLimiter(cfg)
+	if s.accountManager != nil {
+		s.accountManager.SetMaxConcurrentPerAccount(cfg.PerAccount)
+	}
+}
+
+// SetClientRateLimits caps the request and token rates of downstream clients, per API key
+// and globally. Call it before Handler.
+func (s *Server) SetClientRateLimits(cfg config.ClientRateLimitConfig) {
+	s.clientLimiter = nil
+	if cfg.Enabled() {
+		s.clientLimiter = newClientRateLimiter(cfg)
 	}
 }
 
@@ -52,18 +177,56 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/v1/messages/count_tokens", s.handleCountTokens)
 	mux.HandleFunc("/v1/models", s.handleModels)
 	mux.HandleFunc("/v1/images/generate", s.handleImageGenerate)
+	mux.HandleFunc("/v1/images/edit", s.handleImageEdit)
 	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/health/live", s.handleHealthLive)
+	mux.HandleFunc("/health/ready", s.handleHealthReady)
+	mux.HandleFunc("/metrics", s.handleMetrics)
 	mux.HandleFunc("/account-limits", s.handleAccountLimits)
 	mux.HandleFunc("/refresh-token", s.handleRefreshToken)
+	mux.HandleFunc("/usage", s.handleUsage)
+	mux.HandleFunc("/admin/accounts/{email}/drain", s.handleAccountDrain)
+	mux.HandleFunc("/admin/accounts/{email}/disable", s.handleAccountDisable)
+	mux.HandleFunc("/auth/antigravity/start", s.handleAntigravityAuthStart)
+	mux.HandleFunc("/auth/antigravity/callback", s.handleAntigravityAuthCallback)
+	mux.HandleFunc("/admin/requests", s.handleRecentRequests)
+	mux.HandleFunc("/admin/rate-limits/reset", s.handleResetRateLimits)
+	mux.HandleFunc("/admin/sticky-errors", s.handleStickyErrors)
+	mux.HandleFunc("/admin/reload", s.handleReload)
+	mux.HandleFunc("/admin/replay", s.handleReplay)
+	mux.HandleFunc("/admin/streams", s.handleStreams)
+	mux.HandleFunc("/admin/streams/{id}", s.handleStreams)
+	mux.HandleFunc("/debug/endpoints", s.handleDebugEndpoints)
+	mux.HandleFunc("/admin/telemetry", s.handleTelemetry)
+	mux.HandleFunc("/dashboard", s.handleDashboard)
+
+	// Kubernetes probes and preStop hook at their configured paths.
+	probes := s.lifecycle.probes
+	if probes.LivePath != "" && probes.LivePath != "/health/live" {
+		mux.HandleFunc(probes.LivePath, s.handleHealthLive)
+	}
+	if probes.ReadyPath != "" && probes.ReadyPath != "/health/ready" {
+		mux.HandleFunc(probes.ReadyPath, s.handleHealthReady)
+	}
+	if probes.PreStopPath != "" {
+		mux.HandleFunc(probes.PreStopPath, s.handlePreStop)
+	}
 
 	// Catch-all for unsupported endpoints (Node parity).
 	mux.HandleFunc("/", s.handleNotFound)
 
 	// Apply middleware (order matters: outermost first)
 	handler := http.Handler(mux)
+	handler = s.TrackInFlight(handler)
+	handler = TelemetryCounts(s.telemetry, handler)
+	handler = Budgets(s.budgets, handler)               // KEY_BUDGET_* and ACCOUNT_BUDGET_*
+	handler = ClientRateLimit(s.clientLimiter, handler) // RATE_LIMIT_*: per API key and global
+	handler = RecentRequests(s.recent, handler)
+	handler = AuditLog(s.auditLog, handler)
+	handler = Tracing(handler) // OpenTelemetry server spans (OTEL_EXPORTER_OTLP_ENDPOINT)
 	handler = Logger(handler)
 	handler = Recovery(handler)
-	handler = APIKeyAuth(handler) // Auth middleware (skips /health)
+	handler = APIKeyAuth(handler) // Auth middleware (skips /health endpoints)
 	handler = ConfigurableCORS(handler) // CORS middleware (configurable via env)
 
 	return handler
@@ -76,22 +239,11 @@ func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Apply request body size limit (Node parity)
-	r.Body = http.MaxBytesReader(w, r.Body, config.RequestBodyLimit)
-
-	// Read request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		// Check if it's a size limit error
-		if err.Error() == "http: request body too large" {
-			writeError(w, http.StatusRequestEntityTooLarge, "invalid_request_error",
-				fmt.Sprintf("Request body too large (max %d bytes)", config.RequestBodyLimit))
-			return
-		}
-		writeError(w, http.StatusBadRequest, "invalid_request_error", "Failed to read request body")
+	// Read request body within the endpoint's size limit (Node parity)
+	body, ok := readRequestBody(w, r)
+	if !ok {
 		return
 	}
-	defer r.Body.Close()
 
 	// Parse request (Node parity: validate messages is an array; default model/max_tokens).
 	req, err := parseMessagesRequest(body)
@@ -100,6 +252,11 @@ func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusBadRequest, "invalid_request_error", "messages is required and must be an array")
 			return
 		}
+		var validationErr *requestValidationError
+		if stderrors.As(err, &validationErr) {
+			writeError(w, http.StatusBadRequest, "invalid_request_error", validationErr.Error())
+			return
+		}
 		writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid JSON: %v", err))
 		return
 	}
@@ -113,61 +270,472 @@ func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
 	}
 
 	publicModel := req.Model
+	_, selectSpan := tracing.StartChild(r.Context(), "provider.select", tracing.KindInternal, tracing.String("model", publicModel))
 	prov, rawModel, err := s.resolveProviderForModel(publicModel)
+	// Debugging overrides (x-proxy-provider, x-proxy-account, x-proxy-no-fallback).
+	prov, rawModel, overrides, err := s.applyRoutingOverrides(r, publicModel, prov, rawModel, err)
 	if err != nil {
+		selectSpan.SetError(err)
+		selectSpan.End()
 		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 		return
 	}
+	selectSpan.SetAttributes(tracing.String("provider", prov.Name()), tracing.String("upstream_model", rawModel))
+	selectSpan.End()
 
 	// Use raw model IDs internally (rate limits, quotas, upstream requests).
 	reqForProvider := *req
 	reqForProvider.Model = rawModel
 
-	// Optimistic Retry: If ALL provider accounts are rate-limited for this model, reset them to force a fresh check (Node parity).
+	// Upstreams only ever see a hash of metadata.user_id.
+	var user string
+	if req.Metadata != nil && req.Metadata.UserID != "" {
+		user = accountingUser(req.Metadata.UserID)
+		reqForProvider.Metadata = &types.Metadata{UserID: hashUserID(req.Metadata.UserID)}
+	}
+
+	// Content policy filters (POLICY_FILTER_*): reject or redact before anything is dispatched.
+	flags, ok := s.applyRequestPolicy(r.Context(), w, &reqForProvider, prov.Name(), user)
+	if !ok {
+		return
+	}
+
+	// Request rules (REQUEST_POLICY_CONFIG): allow, deny or transform by expression. The rules
+	// see the request as sent; a transformed model is routed like a requested one.
+	decision, ok := s.applyRequestRules(r, w, req, prov.Name(), user, flags)
+	if !ok {
+		return
+	}
+	if decision.Model != "" && decision.Model != publicModel {
+		newProv, newModel, err := s.resolveProviderForModel(decision.Model)
+		if err != nil {
+			utils.Error("[Policy] Request rule routes %s to %s: %v", publicModel, decision.Model, err)
+			writeError(w, http.StatusInternalServerError, string(merrors.ErrorTypeAPI), "Request policy routes to an unavailable model")
+			return
+		}
+		utils.Debug("[Policy] Request rules route %s to %s", publicModel, decision.Model)
+		publicModel, prov, rawModel = decision.Model, newProv, newModel
+		req.Model, reqForProvider.Model = publicModel, rawModel
+	}
+	if decision.MaxTokens > 0 {
+		req.MaxTokens, reqForProvider.MaxTokens = decision.MaxTokens, decision.MaxTokens
+	}
+
+	// Global soft limit scheduler (SOFT_LIMIT_SCHEDULER=global): move off a provider whose
+	// accounts are all soft-limited for the model when an equivalent model isn't. Requests
+	// with a service_tier other than auto stay on the requested provider.
+	if !overrides.set() && reroutesSoftLimited(req.ServiceTier) {
+		if newProv, newModel, equivalent, ok := s.rerouteSoftLimited(prov, rawModel); ok {
+			publicModel, prov, rawModel = equivalent, newProv, newModel
+			req.Model, reqForProvider.Model = publicModel, rawModel
+		}
+	}
+
+	// Operator system prompt (SYSTEM_PROMPT_*), added before the provider converts the request.
+	applySystemPrompt(&reqForProvider, config.GetSystemPromptConfig(prov.Name()))
+
+	// Thinking policy (THINKING_*, opt-in per provider): budgets clamped or derived per model,
+	// thinking enabled for -thinking models and removed where unsupported.
+	normalizeThinking(&reqForProvider, prov, config.GetThinkingPolicyConfig())
+
+	// Image tool (IMAGE_TOOL_*): calls to it are run here, with Antigravity image models.
+	imageTool, useImageTool := s.prepareImageTool(&reqForProvider)
+
+	// Response cache (opt-in): identical requests are served without touching upstream quota.
+	var cacheKey string
+	if s.respCache != nil && !overrides.set() {
+		cacheKey = cache.Key(virtualKeyFromContext(r.Context()), user, publicModel, req)
+		if entry, ok := s.respCache.Get(cacheKey); ok && s.writeCachedResponse(w, entry, req.Stream) {
+			utils.Debug("[Messages] Served %s from response cache", publicModel)
+			// A hit counts in the caller's usage and the dashboard like any request, but no
+			// account served it, so no account quota or cost is charged.
+			if stats := requestStatsFromContext(r.Context()); stats != nil {
+				stats.Provider, stats.Model, stats.User, stats.Cached = prov.Name(), rawModel, user, true
+				recordCachedUsage(r.Context(), entry)
+			}
+			return
+		}
+		w.Header().Set("X-Proxy-Cache", "MISS")
+	}
+
+	// Upstream size limit (MAX_UPSTREAM_REQUEST_KB): reject before any account is used.
 	providerName := prov.Name()
+	if limit := config.GetMaxUpstreamRequestBytes(providerName); limit > 0 && int64(len(body)) > limit {
+		metrics.RequestsTooLarge.Inc(providerName, rawModel)
+		writeError(w, http.StatusRequestEntityTooLarge, "request_too_large",
+			fmt.Sprintf("Request is %d KB, over the %d KB limit for provider %s; shorten the conversation (e.g. fewer or smaller tool results)",
+				(len(body)+1023)/1024, limit/1024, providerName))
+		return
+	}
+	// Per-model request guards (MAX_REQUEST_*): reject requests that are bound to fail upstream.
+	if guard := config.GetRequestGuardConfig().ForModel(providerName, rawModel); guard != (config.RequestGuard{}) {
+		if reason := checkRequestGuard(&reqForProvider, guard, publicModel); reason != "" {
+			metrics.RequestGuardRejections.Inc(providerName, rawModel)
+			writeError(w, http.StatusBadRequest, "invalid_request_error", reason)
+			return
+		}
+	}
+	metrics.RequestBytes.Observe(providerName, rawModel, float64(len(body)))
+
+	// Optimistic Retry: If ALL provider accounts are rate-limited for this model, reset them to force a fresh check (Node parity).
 	if s.accountManager != nil && s.accountManager.IsAllRateLimitedByProvider(providerName, rawModel) {
 		utils.Warn("[Server] All %s accounts rate-limited for %s. Resetting state for optimistic retry.", providerName, rawModel)
 		s.accountManager.ResetAllRateLimitsByProvider(providerName)
 	}
+	// Account budgets (ACCOUNT_BUDGET_*): over-budget accounts are skipped, so fail fast when all are.
+	if reason, reset, exhausted := s.providerOverBudget(providerName); exhausted {
+		writeBudgetExceeded(w, reason, reset)
+		return
+	}
+	// service_tier priority only uses accounts that aren't soft-limited, so fail fast when all are.
+	if req.ServiceTier == serviceTierPriority && overrides.account == "" && s.accountManager != nil &&
+		s.accountManager.IsAllSoftLimitedByProvider(providerName, rawModel) {
+		writeError(w, http.StatusTooManyRequests, "rate_limit_error",
+			fmt.Sprintf("No priority capacity for %s/%s: every account is soft-limited; retry later or use service_tier standard_only", providerName, rawModel))
+		return
+	}
 
-	ctx := r.Context()
+	trace := &provider.Trace{}
+	ctx := provider.WithTrace(withServiceTier(overrides.context(r.Context()), req.ServiceTier), trace)
+	// REQUEST_TIMEOUT bounds the whole request, including the wait for a concurrency slot.
+	ctx, cancelRequest := merrors.WithTimeout(ctx, merrors.PhaseRequest, config.GetRequestTimeouts(providerName).Request)
+	defer cancelRequest()
+	// Session affinity (SESSION_AFFINITY_ENABLED): later turns prefer the account of earlier ones.
+	if overrides.account == "" {
+		ctx = s.withSessionAffinity(ctx, requestSessionID(r, req, user), providerName, rawModel)
+	}
+	stats := requestStatsFromContext(ctx)
+	if stats == nil && s.quotaTracker != nil {
+		stats = &requestStats{}
+		ctx = withRequestStats(ctx, stats)
+	}
+	if stats != nil {
+		stats.Provider, stats.Model, stats.User = providerName, rawModel, user
+		defer s.recordAccountUsage(trace, stats)
+	}
+
+	// Concurrency limits (MAX_CONCURRENT_*): queue for a slot, then reject with 429.
+	if s.limiter != nil {
+		accountCount := 0
+		if s.accountManager != nil {
+			accountCount = s.accountManager.GetAccountCountByProvider(providerName)
+		}
+		queuedAt := time.Now()
+		release, err := s.limiter.acquire(ctx, providerName, rawModel, accountCount)
+		trace.Wait(time.Since(queuedAt))
+		if err != nil {
+			if te := merrors.TimeoutCause(ctx); te != nil {
+				s.writeMessagesError(w, r, te)
+				return
+			}
+			if stderrors.Is(err, errConcurrencyLimit) {
+				writeTraceHeaders(w.Header(), providerName, trace)
+				w.Header().Set("Retry-After", "1")
+				writeError(w, http.StatusTooManyRequests, "rate_limit_error",
+					fmt.Sprintf("Too many concurrent requests for %s/%s; retry shortly", providerName, rawModel))
+			}
+			return
+		}
+		defer release()
+	}
+
+	if useImageTool {
+		s.handleImageToolMessage(ctx, w, r, prov, &reqForProvider, publicModel, imageTool)
+		return
+	}
 
 	// Handle streaming vs non-streaming (Node parity: centralized error shaping + auth refresh attempt).
 	if req.Stream {
-		s.handleStreamingMessage(ctx, w, prov, &reqForProvider, publicModel)
+		s.handleStreamingMessage(ctx, w, prov, &reqForProvider, publicModel, cacheKey)
 		return
 	}
 
-	resp, err := prov.SendMessage(ctx, &reqForProvider)
+	start := time.Now()
+	sendCtx, span := tracing.StartChild(ctx, "provider.send", tracing.KindInternal,
+		tracing.String("provider", providerName), tracing.String("model", rawModel))
+	var (
+		resp   *types.AnthropicResponse
+		shared bool // Coalesced with an identical request in flight
+	)
+	if s.coalescer != nil && !overrides.set() {
+		key := cache.Key(virtualKeyFromContext(r.Context()), user, providerName+"/"+rawModel, &reqForProvider)
+		resp, shared, err = s.coalescer.do(sendCtx, key, func(ctx context.Context) (*types.AnthropicResponse, error) {
+			// The call isn't any one caller's: route it as above, minus the caller's lifetime.
+			ctx, cancel := merrors.WithTimeout(withServiceTier(ctx, req.ServiceTier), merrors.PhaseRequest, config.GetRequestTimeouts(providerName).Request)
+			defer cancel()
+			ctx = s.withSessionAffinity(ctx, requestSessionID(r, req, user), providerName, rawModel)
+			resp, err := prov.SendMessage(ctx, &reqForProvider)
+			return resp, timeoutOr(ctx, err)
+		})
+		if shared {
+			if stats != nil {
+				stats.Coalesced = true
+			}
+			metrics.CoalescedRequests.Inc(providerName, rawModel)
+			w.Header().Set("X-Proxy-Coalesced", "true")
+			span.SetAttributes(tracing.Bool("coalesced", true))
+		}
+	} else {
+		resp, err = prov.SendMessage(sendCtx, &reqForProvider)
+	}
+	span.SetAttributes(tracing.Int("attempts", trace.Attempts()))
+	span.SetError(err)
+	span.End()
+	writeTraceHeaders(w.Header(), providerName, trace)
 	if err != nil {
+		err = timeoutOr(ctx, err)
+		if ctx.Err() != nil && merrors.TimeoutCause(ctx) == nil {
+			recordClientCancellation(providerName, rawModel)
+			return
+		}
+		if merrors.FromError(err).StatusCode() == http.StatusTooManyRequests {
+			s.setRetryAfter(w.Header(), providerName, rawModel, trace)
+		}
 		s.writeMessagesError(w, r, err)
 		return
 	}
+	if shared {
+		// The request that went upstream records the throughput.
+		if stats != nil {
+			stats.OutputTokens = resp.Usage.OutputTokens
+		}
+	} else {
+		recordThroughput(ctx, providerName, rawModel, resp.Usage.OutputTokens, time.Since(start))
+	}
+	recordInputTokens(ctx, resp.Usage)
+	s.recordSession(ctx, providerName, rawModel)
+	// Some upstreams ignore stop_sequences; cut the response at the first one.
+	if applyStopSequences(resp, reqForProvider.StopSequences) {
+		metrics.StopSequencesEnforced.Inc(providerName, rawModel)
+	}
+	if !s.applyResponsePolicy(ctx, w, resp, providerName, rawModel, user) {
+		return
+	}
 	resp.Model = publicModel
+	if resp.Usage.ServiceTier == "" {
+		resp.Usage.ServiceTier = effectiveServiceTier(req.ServiceTier)
+	}
+	if s.respCache != nil && cacheKey != "" {
+		s.respCache.Put(cacheKey, cache.Entry{Response: resp})
+	}
 
+	data, _ := json.Marshal(toNodeMessageResponse(resp))
+	metrics.ResponseBytes.Observe(providerName, rawModel, float64(len(data)))
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(toNodeMessageResponse(resp))
+	w.Write(append(data, '\n'))
 }
 
 // handleStreamingMessage handles streaming message requests.
-func (s *Server) handleStreamingMessage(ctx context.Context, w http.ResponseWriter, prov provider.Provider, req *types.AnthropicRequest, publicModel string) {
+// A non-empty cacheKey records the stream for replay when response caching is enabled.
+func (s *Server) handleStreamingMessage(ctx context.Context, w http.ResponseWriter, prov provider.Provider, req *types.AnthropicRequest, publicModel, cacheKey string) {
 	utils.Debug("[Messages] Streaming request for model: %s", req.Model)
 
-	sse, err := NewSSEWriter(w)
-	if err != nil {
+	if trace := provider.TraceFromContext(ctx); trace != nil {
+		declareTraceTrailers(w.Header())
+		defer writeTraceHeaders(w.Header(), prov.Name(), trace)
+	}
+
+	// Active streams are listed and can be terminated by request ID (/admin/streams).
+	requestID := w.Header().Get(headerRequestID)
+	if requestID == "" {
+		requestID = uuid.NewString()
+		w.Header().Set(headerRequestID, requestID)
+	}
+
+	if _, ok := w.(http.Flusher); !ok {
 		writeError(w, http.StatusInternalServerError, "api_error", "Streaming not supported")
 		return
 	}
 
-	// NOTE: Headers are now sent. Any errors from this point must be sent as SSE error events.
-	eventsCh, err := prov.SendMessageStream(ctx, req)
+	ctx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+	var user string
+	if stats := requestStatsFromContext(ctx); stats != nil {
+		user = stats.User
+	}
+	active := s.streams.add(requestID, prov.Name(), req.Model, user, provider.TraceFromContext(ctx))
+	defer s.streams.remove(active)
+
+	// The span covers account selection, the upstream call and SSE parsing until the
+	// provider's event channel closes.
+	streamCtx, span := tracing.StartChild(ctx, "provider.stream", tracing.KindInternal,
+		tracing.String("provider", prov.Name()), tracing.String("model", req.Model))
+	defer span.End()
+	streamStart := time.Now()
+	// Cancelling upstreamCtx ends the upstream request without the client having gone away.
+	upstreamCtx, cancelUpstreamCause := context.WithCancelCause(streamCtx)
+	cancelUpstream := func() { cancelUpstreamCause(nil) }
+	defer cancelUpstream()
+	// FIRST_TOKEN_TIMEOUT and STREAM_TIMEOUT end the upstream request with a TimeoutError.
+	deadline := newStreamDeadline(config.GetRequestTimeouts(prov.Name()), cancelUpstreamCause)
+	defer deadline.stop()
+
+	// As with Anthropic's API, a failure before the stream starts is an HTTP error: the SDKs
+	// pick the error class and whether to retry from the status, which an error event after
+	// a 200 doesn't have. A slow start has already been committed to keep it alive.
+	eventsCh, sse, err := s.startStream(upstreamCtx, w, prov, req)
+	defer func() {
+		if sse != nil && ctx.Err() == nil {
+			writeTraceComment(sse, prov.Name(), provider.TraceFromContext(ctx))
+		}
+	}()
 	if err != nil {
-		s.writeMessagesStreamError(sse, err)
+		span.SetError(err)
+		err = timeoutOr(upstreamCtx, err)
+		if ctx.Err() != nil && merrors.TimeoutCause(ctx) == nil {
+			recordClientCancellation(prov.Name(), req.Model)
+			return
+		}
+		if sse != nil {
+			s.writeMessagesStreamError(sse, err)
+			return
+		}
+		if merrors.FromError(err).StatusCode() == http.StatusTooManyRequests {
+			s.setRetryAfter(w.Header(), prov.Name(), req.Model, provider.TraceFromContext(ctx))
+		}
+		s.writeMessagesError(w, nil, err)
 		return
 	}
 
-	// Stream events to client
-	for event := range eventsCh {
+	// NOTE: Headers are now sent. Any errors from this point must be sent as SSE error events.
+	if sse == nil {
+		sse, _ = NewSSEWriter(w) // w is a Flusher, checked above
+	}
+
+	recording := s.respCache != nil && cacheKey != ""
+	var (
+		recorded  []cache.Event
+		completed bool
+		failed    bool
+		streamed  strings.Builder // response text for the policy filters
+
+		// Throughput is measured from the first event, so time to first token doesn't dilute it.
+		firstEventAt time.Time
+		outputTokens int
+	)
+	defer func() {
+		if !failed && !firstEventAt.IsZero() {
+			recordThroughput(ctx, prov.Name(), req.Model, outputTokens, time.Since(firstEventAt))
+			s.recordSession(ctx, prov.Name(), req.Model)
+		}
+		if !firstEventAt.IsZero() {
+			recordFirstEvent(ctx, prov.Name(), req.Model, firstEventAt.Sub(streamStart))
+			metrics.ResponseBytes.Observe(prov.Name(), req.Model, float64(sse.BytesWritten()))
+		}
+		span.SetAttributes(tracing.Int("attempts", provider.TraceFromContext(ctx).Attempts()), tracing.Int("output_tokens", outputTokens))
+		if !firstEventAt.IsZero() {
+			span.SetAttributes(tracing.Int("time_to_first_event_ms", int(firstEventAt.Sub(streamStart).Milliseconds())))
+		}
+		if failed {
+			span.Fail("stream failed")
+		}
+	}()
+
+	// With STREAM_RECOVERY, a stream that fails after it started continues on another account.
+	var recovery *streamRecovery
+	if provider.FallbackAllowed(ctx) {
+		recovery = newStreamRecovery(prov.Name())
+	}
+	// Some upstreams ignore stop_sequences; the proxy ends the message at the first one.
+	stopper := newStopSequenceFilter(req.StopSequences)
+	resume := func(cause string) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		next, closing, ok := s.resumeStream(upstreamCtx, prov, req, recovery, cause)
+		for _, ev := range closing {
+			if err := sse.WriteRaw(ev.Type, ev.Data); err != nil {
+				utils.Error("[Messages] Failed to write SSE event: %v", err)
+				return false
+			}
+			if recording {
+				recorded = append(recorded, ev)
+			}
+		}
+		if ok {
+			// The resumed stream starts from the text written, not from the text held back.
+			stopper.discard()
+			// Drain the failed stream, so its provider isn't blocked on a full channel.
+			go func(failed <-chan types.StreamEvent) {
+				for range failed {
+				}
+			}(eventsCh)
+			eventsCh = next
+		}
+		return ok
+	}
+
+	// Stream events to client. As in Anthropic's streams, a ping follows message_start, and
+	// pings keep the connection alive while the provider is idle (e.g. long thinking phases).
+	var (
+		pingTimer *time.Timer
+		pingC     <-chan time.Time
+		ended     bool // message_stop or an error event was written; later events are dropped
+
+		stopEnforced bool // The message was ended at a stop sequence and the upstream cancelled
+	)
+	if s.pingInterval > 0 {
+		pingTimer = time.NewTimer(s.pingInterval)
+		defer pingTimer.Stop()
+		pingC = pingTimer.C
+	}
+	for {
+		var (
+			event types.StreamEvent
+			ok    bool
+		)
+		select {
+		case event, ok = <-eventsCh:
+		case <-pingC:
+			if err := sse.WriteRaw("ping", pingEventData); err != nil {
+				utils.Error("[Messages] Failed to write SSE ping: %v", err)
+				failed = true
+				return
+			}
+			pingTimer.Reset(s.pingInterval)
+			continue
+		case <-ctx.Done():
+			// The client went away, or REQUEST_TIMEOUT passed. Returning cancels the upstream request.
+			failed = true
+			if te := merrors.TimeoutCause(ctx); te != nil && !ended {
+				s.writeMessagesStreamError(sse, te)
+			} else if !ended {
+				recordClientCancellation(prov.Name(), req.Model)
+			}
+			return
+		case <-active.killed:
+			// Terminated through /admin/streams: cancel the upstream request and end the stream.
+			cancelStream()
+			failed = true
+			if !ended {
+				if err := sse.WriteError(string(merrors.ErrorTypeAPI), "Stream terminated by the proxy administrator"); err != nil {
+					utils.Error("[Messages] Failed to write SSE error event: %v", err)
+				}
+			}
+			return
+		}
+		if !ok {
+			if te := merrors.TimeoutCause(upstreamCtx); te != nil && !ended {
+				failed = true
+				ended = true
+				s.writeMessagesStreamError(sse, te)
+				break
+			}
+			if !ended && ctx.Err() == nil && resume("stream ended before message_stop") {
+				continue
+			}
+			break
+		}
+		if ended {
+			continue // drain, so the provider isn't blocked on a full channel
+		}
+		if pingTimer != nil {
+			pingTimer.Reset(s.pingInterval)
+		}
+		if firstEventAt.IsZero() {
+			firstEventAt = time.Now()
+			deadline.firstEvent()
+		}
 		s.applyPublicModelToStreamEvent(&event, publicModel)
 
 		eventType := event.Type
@@ -175,26 +743,172 @@ func (s *Server) handleStreamingMessage(ctx context.Context, w http.ResponseWrit
 			eventType = "message"
 		}
 
-		// Check for error events from the provider.
-		if event.Error != nil {
-			// Provider sent an error event, forward it (Node parity shape).
-			if writeErr := sse.WriteEvent("error", event); writeErr != nil {
+		// Error events end the stream. Forward them (Node parity shape) with a documented
+		// error type, so clients can tell retryable overloads from other failures.
+		if ae, isErr := streamEventError(event); isErr {
+			// A stream cut short by a deadline reports the timeout, not the cancelled read.
+			if te := merrors.TimeoutCause(upstreamCtx); te != nil {
+				ae = te.AnthropicError()
+			} else if streamErrorResumable(ae) && resume(ae.Detail.Message) {
+				continue
+			}
+			failed = true
+			ended = true
+			pingC = nil
+			if writeErr := sse.WriteError(string(ae.Detail.Type), ae.Detail.Message); writeErr != nil {
 				utils.Error("[Messages] Failed to write SSE error event: %v", writeErr)
 			}
 			continue
 		}
 
-		if event.Raw != nil {
-			if err := sse.WriteEvent(eventType, event.Raw); err != nil {
+		data, err := streamEventData(event)
+		if err != nil {
+			utils.Error("[Messages] Failed to marshal SSE event: %v", err)
+			return
+		}
+		if eventType == "message_start" {
+			data = setRawMessageServiceTier(data, effectiveServiceTier(req.ServiceTier))
+		}
+		// A resumed stream's events are renumbered to continue the message, then cut at a
+		// stop sequence.
+		for _, ev := range stopper.filter(recovery.relay(eventType, data)) {
+			eventType, data := ev.Type, ev.Data
+			if err := sse.WriteRaw(eventType, data); err != nil {
 				utils.Error("[Messages] Failed to write SSE event: %v", err)
+				failed = true
 				return
 			}
-			continue
+			if eventType == "message_start" {
+				if usage, ok := streamInputUsage(data); ok {
+					recordInputTokens(ctx, usage)
+				}
+				if err := sse.WriteRaw("ping", pingEventData); err != nil {
+					utils.Error("[Messages] Failed to write SSE ping: %v", err)
+					failed = true
+					return
+				}
+			}
+			if eventType == "message_stop" {
+				ended = true
+				pingC = nil
+			}
+			if eventType == "message_delta" {
+				if n, ok := streamOutputTokens(data); ok {
+					outputTokens = n
+				}
+				if usage, ok := streamDeltaInputUsage(data); ok {
+					recordInputTokens(ctx, usage)
+				}
+			}
+
+			if s.policy != nil {
+				if text, ok := streamTextDelta(data); ok {
+					streamed.WriteString(text)
+				}
+			}
+
+			buffered := 0
+			if recording {
+				buffered = len(data)
+			}
+			active.observe(sse.BytesWritten(), buffered)
+
+			if recording {
+				recorded = append(recorded, cache.Event{Type: eventType, Data: data})
+				if eventType == "message_start" {
+					recorded = append(recorded, cache.Event{Type: "ping", Data: pingEventData})
+				}
+				completed = completed || eventType == "message_stop"
+			}
+			recovery.observe(eventType, data)
+		}
+		if stopper.done() && ended && !stopEnforced {
+			stopEnforced = true
+			metrics.StopSequencesEnforced.Inc(prov.Name(), req.Model)
+			cancelUpstream()
+		}
+	}
+
+	// A stream that ends without message_stop was cut short upstream, or by the client
+	// going away; tell the client in the first case rather than leaving it with a
+	// truncated message.
+	if !ended && ctx.Err() != nil {
+		failed = true
+		recordClientCancellation(prov.Name(), req.Model)
+	} else if !ended {
+		failed = true
+		utils.Warn("[Messages] %s stream for %s ended without message_stop", prov.Name(), req.Model)
+		if err := sse.WriteError(string(merrors.ErrorTypeAPI), "Upstream stream ended unexpectedly before the message was complete"); err != nil {
+			utils.Error("[Messages] Failed to write SSE error event: %v", err)
 		}
+	}
 
-		if err := sse.WriteEvent(eventType, event); err != nil {
-			utils.Error("[Messages] Failed to write SSE event: %v", err)
-			return
+	if s.policy != nil && ended && !failed {
+		s.checkStreamedPolicy(ctx, prov.Name(), req.Model, user, streamed.String())
+	}
+
+	// Only complete, error-free streams are cached for replay.
+	if recording && completed && !failed {
+		s.respCache.Put(cacheKey, cache.Entry{Events: recorded})
+	}
+}
+
+// writeCachedResponse serves a cache hit. Streaming entries are replayed event by event.
+// Returns false if the entry doesn't match the requested mode.
+func (s *Server) writeCachedResponse(w http.ResponseWriter, entry cache.Entry, stream bool) bool {
+	if stream {
+		if len(entry.Events) == 0 {
+			return false
+		}
+		w.Header().Set("X-Proxy-Cache", "HIT")
+		sse, err := NewSSEWriter(w)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "api_error", "Streaming not supported")
+			return true
+		}
+		for _, ev := range entry.Events {
+			if err := sse.WriteRaw(ev.Type, ev.Data); err != nil {
+				utils.Error("[Messages] Failed to replay cached SSE event: %v", err)
+				return true
+			}
+		}
+		return true
+	}
+
+	if entry.Response == nil {
+		return false
+	}
+	w.Header().Set("X-Proxy-Cache", "HIT")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toNodeMessageResponse(entry.Response))
+	return true
+}
+
+// recordCachedUsage records the tokens of a cached response for the request's usage, as
+// they were counted when the response came from upstream.
+func recordCachedUsage(ctx context.Context, entry cache.Entry) {
+	stats := requestStatsFromContext(ctx)
+	if stats == nil {
+		return
+	}
+	if entry.Response != nil {
+		recordInputTokens(ctx, entry.Response.Usage)
+		stats.OutputTokens = entry.Response.Usage.OutputTokens
+		return
+	}
+	for _, ev := range entry.Events {
+		switch ev.Type {
+		case "message_start":
+			if usage, ok := streamInputUsage(ev.Data); ok {
+				recordInputTokens(ctx, usage)
+			}
+		case "message_delta":
+			if n, ok := streamOutputTokens(ev.Data); ok {
+				stats.OutputTokens = n
+			}
+			if usage, ok := streamDeltaInputUsage(ev.Data); ok {
+				recordInputTokens(ctx, usage)
+			}
 		}
 	}
 }
@@ -204,6 +918,13 @@ func (s *Server) resolveProviderForModel(model string) (provider.Provider, strin
 		return nil, "", fmt.Errorf("no provider registry configured")
 	}
 
+	// Aliases resolve to their target before provider selection.
+	if aliases := s.modelAliases.Load(); aliases != nil {
+		if target, ok := (*aliases)[model]; ok {
+			model = target
+		}
+	}
+
 	// Explicit provider selection: "<provider>/<model>".
 	// Only treat as explicit provider selection if the prefix is a registered provider.
 	if providerName, rawModel, ok := splitModelID(model); ok {
@@ -253,6 +974,13 @@ func (s *Server) applyPublicModelToStreamEvent(event *types.StreamEvent, publicM
 		event.Message.Model = publicModel
 	}
 
+	if data, ok := event.Raw.(json.RawMessage); ok {
+		if event.Type == "message_start" {
+			event.Raw = setRawMessageModel(data, publicModel)
+		}
+		return
+	}
+
 	raw, ok := event.Raw.(map[string]interface{})
 	if !ok || raw == nil {
 		return
@@ -330,6 +1058,75 @@ func (s *Server) writeMessagesStreamError(sse *SSEWriter, err error) {
 	}
 }
 
+// recordClientCancellation counts a request the client abandoned before its response was
+// complete. The upstream request is cancelled along with the client's context.
+func recordClientCancellation(providerName, model string) {
+	utils.Debug("[Messages] Client disconnected, cancelled %s request for %s", providerName, model)
+	metrics.ClientCancellations.Inc(providerName, model)
+}
+
+// startStream starts the provider's stream. If the provider takes longer than the ping
+// interval to start (e.g. while it waits for a rate-limited account), the response is
+// committed as a stream and pinged until it does, so idle timeouts of clients and proxies
+// don't cut it; sse is then non-nil and errors must be sent as SSE error events.
+func (s *Server) startStream(ctx context.Context, w http.ResponseWriter, prov provider.Provider, req *types.AnthropicRequest) (<-chan types.StreamEvent, *SSEWriter, error) {
+	if s.pingInterval <= 0 {
+		eventsCh, err := prov.SendMessageStream(ctx, req)
+		return eventsCh, nil, err
+	}
+
+	type started struct {
+		eventsCh <-chan types.StreamEvent
+		err      error
+	}
+	done := make(chan started, 1)
+	go func() {
+		eventsCh, err := prov.SendMessageStream(ctx, req)
+		done <- started{eventsCh, err}
+	}()
+
+	var sse *SSEWriter
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case res := <-done:
+			return res.eventsCh, sse, res.err
+		case <-ticker.C:
+			if sse == nil {
+				sse, _ = NewSSEWriter(w)
+			}
+			// A write error means the client is gone; the request context is cancelled and
+			// the provider returns shortly.
+			if err := sse.WriteRaw("ping", pingEventData); err != nil {
+				utils.Debug("[Messages] Failed to write SSE ping: %v", err)
+			}
+		}
+	}
+}
+
+// streamEventError returns the error carried by a provider's error event, with its type
+// mapped to one Anthropic documents. ok is false for other events.
+func streamEventError(event types.StreamEvent) (ae *merrors.AnthropicError, ok bool) {
+	detail := event.Error
+	if detail == nil {
+		if event.Type != "error" {
+			return nil, false
+		}
+		// Passthrough providers forward the upstream error event as Raw.
+		var payload types.AnthropicError
+		if data, err := json.Marshal(event.Raw); err == nil {
+			_ = json.Unmarshal(data, &payload)
+		}
+		detail = &payload.Error
+	}
+	message := detail.Message
+	if message == "" {
+		message = "Upstream stream error"
+	}
+	return merrors.StreamError(detail.Type, message), true
+}
+
 func parseMessagesRequest(body []byte) (*types.AnthropicRequest, error) {
 	var raw map[string]json.RawMessage
 	if err := json.Unmarshal(body, &raw); err != nil {
@@ -344,24 +1141,37 @@ func parseMessagesRequest(body []byte) (*types.AnthropicRequest, error) {
 
 	var req types.AnthropicRequest
 
-	_ = json.Unmarshal(raw["model"], &req.Model)
-	_ = json.Unmarshal(messagesRaw, &req.Messages)
-	_ = json.Unmarshal(raw["max_tokens"], &req.MaxTokens)
-	_ = json.Unmarshal(raw["stream"], &req.Stream)
+	// Every field is checked, so a request with several mistakes is told about all of them.
+	v := &requestValidator{raw: raw}
+	v.decode("model", &req.Model)
+	v.decode("messages", &req.Messages)
+	hasMaxTokens := v.decode("max_tokens", &req.MaxTokens)
+	v.decode("stream", &req.Stream)
 
 	// Preserve raw system prompt content.
 	if sys, ok := raw["system"]; ok {
 		req.System = sys
 	}
 
-	_ = json.Unmarshal(raw["tools"], &req.Tools)
-	_ = json.Unmarshal(raw["tool_choice"], &req.ToolChoice)
-	_ = json.Unmarshal(raw["thinking"], &req.Thinking)
-	_ = json.Unmarshal(raw["temperature"], &req.Temperature)
-	_ = json.Unmarshal(raw["top_p"], &req.TopP)
-	_ = json.Unmarshal(raw["top_k"], &req.TopK)
-	_ = json.Unmarshal(raw["stop_sequences"], &req.StopSequences)
-
+	v.decode("tools", &req.Tools)
+	v.decode("tool_choice", &req.ToolChoice)
+	v.decode("thinking", &req.Thinking)
+	v.decode("temperature", &req.Temperature)
+	v.decode("top_p", &req.TopP)
+	v.decode("top_k", &req.TopK)
+	v.decode("stop_sequences", &req.StopSequences)
+	v.decode("metadata", &req.Metadata)
+	v.decode("service_tier", &req.ServiceTier)
+
+	// A missing max_tokens is defaulted by the handler; an explicit one must be positive.
+	v.check(hasMaxTokens && req.MaxTokens < 1, "max_tokens", "Input should be greater than or equal to 1")
+	v.check(req.Temperature != nil && *req.Temperature < 0, "temperature", "Input should be greater than or equal to 0")
+	v.check(req.TopP != nil && (*req.TopP < 0 || *req.TopP > 1), "top_p", "Input should be between 0 and 1")
+	v.check(req.TopK != nil && *req.TopK < 0, "top_k", "Input should be greater than or equal to 0")
+	v.check(!validServiceTier(req.ServiceTier), "service_tier", "Input should be 'auto', 'standard_only' or 'priority'")
+	if err := v.err(); err != nil {
+		return nil, err
+	}
 	return &req, nil
 }
 
@@ -401,7 +1211,11 @@ func toNodeMessageResponse(resp *types.AnthropicResponse) map[string]interface{}
 			}
 			content = append(content, tool)
 		default:
-			// Preserve unknown blocks best-effort.
+			// Pass extended blocks (search_result, mcp_tool_use, ...) through unchanged.
+			if len(block.Raw) > 0 {
+				content = append(content, block.Raw)
+				continue
+			}
 			content = append(content, map[string]interface{}{
 				"type": block.Type,
 			})
@@ -413,6 +1227,16 @@ func toNodeMessageResponse(resp *types.AnthropicResponse) map[string]interface{}
 		content = []interface{}{map[string]interface{}{"type": "text", "text": ""}}
 	}
 
+	usage := map[string]interface{}{
+		"input_tokens":                resp.Usage.InputTokens,
+		"output_tokens":               resp.Usage.OutputTokens,
+		"cache_read_input_tokens":     resp.Usage.CacheReadInputTokens,
+		"cache_creation_input_tokens": resp.Usage.CacheCreationInputTokens,
+	}
+	if resp.Usage.ServiceTier != "" {
+		usage["service_tier"] = resp.Usage.ServiceTier
+	}
+
 	return map[string]interface{}{
 		"id":            resp.ID,
 		"type":          resp.Type,
@@ -420,13 +1244,8 @@ func toNodeMessageResponse(resp *types.AnthropicResponse) map[string]interface{}
 		"content":       content,
 		"model":         resp.Model,
 		"stop_reason":   resp.StopReason,
-		"stop_sequence": nil,
-		"usage": map[string]interface{}{
-			"input_tokens":                resp.Usage.InputTokens,
-			"output_tokens":               resp.Usage.OutputTokens,
-			"cache_read_input_tokens":     resp.Usage.CacheReadInputTokens,
-			"cache_creation_input_tokens": resp.Usage.CacheCreationInputTokens,
-		},
+		"stop_sequence": resp.StopSequence,
+		"usage":         usage,
 	}
 }
 
@@ -700,21 +1519,11 @@ func (s *Server) handleImageGenerate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Apply request body size limit
-	r.Body = http.MaxBytesReader(w, r.Body, config.RequestBodyLimit)
-
-	// Read request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		if err.Error() == "http: request body too large" {
-			writeError(w, http.StatusRequestEntityTooLarge, "invalid_request_error",
-				fmt.Sprintf("Request body too large (max %d bytes)", config.RequestBodyLimit))
-			return
-		}
-		writeError(w, http.StatusBadRequest, "invalid_request_error", "Failed to read request body")
+	// Read request body within the endpoint's size limit
+	body, ok := readRequestBody(w, r)
+	if !ok {
 		return
 	}
-	defer r.Body.Close()
 
 	// Parse request
 	req, err := parseImageGenerationRequest(body)
@@ -730,18 +1539,7 @@ func (s *Server) handleImageGenerate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get antigravity provider
-	if s.registry == nil {
-		writeError(w, http.StatusInternalServerError, "api_error", "Image generation provider not available")
-		return
-	}
-	prov, ok := s.registry.GetByName("antigravity")
-	if !ok || prov == nil {
-		writeError(w, http.StatusInternalServerError, "api_error", "Image generation provider not available")
-		return
-	}
-
-	// Type assert to access GenerateImage method
-	agProvider, ok := prov.(*antigravity.Provider)
+	agProvider, ok := s.imageProvider()
 	if !ok {
 		writeError(w, http.StatusInternalServerError, "api_error", "Image generation provider not available")
 		return
@@ -765,21 +1563,12 @@ func parseImageGenerationRequest(body []byte) (*types.ImageGenerationRequest, er
 		return nil, err
 	}
 
-	// Apply defaults
-	if req.Model == "" {
-		req.Model = config.DefaultImageModel
-	}
-	if req.Count <= 0 {
-		req.Count = config.DefaultImageCount
-	}
-	if req.Count > config.MaxImageCount {
-		req.Count = config.MaxImageCount
-	}
-
+	applyImageDefaults(&req)
 	return &req, nil
 }
 
 // handleHealth handles GET /health requests.
+// While the health refresher runs, the report comes from its cache and carries cachedAt/cacheAgeMs.
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		s.handleNotFound(w, r)
@@ -798,9 +1587,54 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// ?mode=light, ?provider= and ?account= build a narrower report on demand; the cached
+	// report is always the full deep check.
+	opts, err := s.parseHealthOptions(r.URL.Query())
+	if err != nil {
+		writeHealthStatus(w, http.StatusBadRequest, map[string]interface{}{"status": "error", "error": err.Error()})
+		return
+	}
+	var (
+		report      map[string]interface{}
+		refreshedAt time.Time
+	)
+	if opts == (healthOptions{}) {
+		report, refreshedAt = s.healthReport(r.Context())
+	} else {
+		report = s.buildHealthReport(r.Context(), opts)
+	}
+
+	// The report may be shared with other requests, so copy before adding per-request fields.
+	response := make(map[string]interface{}, len(report)+4)
+	for k, v := range report {
+		response[k] = v
+	}
+	now := time.Now()
+	response["timestamp"] = formatISOTimeUTC(now)
+	response["latencyMs"] = time.Since(start).Milliseconds()
+	if !refreshedAt.IsZero() {
+		response["cachedAt"] = formatISOTimeUTC(refreshedAt)
+		response["cacheAgeMs"] = now.Sub(refreshedAt).Milliseconds()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// buildHealthReport fetches quotas for the accounts opts selects and summarizes them. With
+// opts.light no quotas are fetched and statuses come from the locally tracked rate limits.
+func (s *Server) buildHealthReport(ctx context.Context, opts healthOptions) map[string]interface{} {
 	allAccounts := []account.Account{}
 	if s.accountManager != nil {
-		allAccounts = s.accountManager.GetAllAccounts()
+		for _, acc := range s.accountManager.GetAllAccounts() {
+			providerName := acc.Provider
+			if providerName == "" {
+				providerName = "antigravity"
+			}
+			if opts.includes(acc, providerName) {
+				allAccounts = append(allAccounts, acc)
+			}
+		}
 	}
 
 	// Get soft limit settings
@@ -918,7 +1752,18 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 				baseInfo["rateLimitCooldownRemaining"] = remaining
 			}
 
-			// Invalid accounts: skip quota fetch.
+			// Disabled and invalid accounts: skip quota fetch.
+			if !a.IsEnabled() {
+				baseInfo["status"] = "disabled"
+				if a.DisabledAt != nil {
+					baseInfo["disabledAt"] = formatISOTimeUTC(*a.DisabledAt)
+				}
+				baseInfo["models"] = map[string]interface{}{}
+				mu.Lock()
+				results = append(results, accountDetail{idx: idx, val: baseInfo})
+				mu.Unlock()
+				return
+			}
 			if a.IsInvalid {
 				baseInfo["status"] = "invalid"
 				baseInfo["error"] = a.InvalidReason
@@ -929,10 +1774,27 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			// Light checks report what is tracked locally, without asking the upstream.
+			if opts.light {
+				switch {
+				case isLimited:
+					baseInfo["status"] = "rate-limited"
+				case softLimitEnabled && accIsSoftLimited:
+					baseInfo["status"] = "soft-limited"
+				default:
+					baseInfo["status"] = "ok"
+				}
+				baseInfo["models"] = map[string]interface{}{}
+				mu.Lock()
+				results = append(results, accountDetail{idx: idx, val: baseInfo})
+				mu.Unlock()
+				return
+			}
+
 			quotas := map[string]interface{}{}
 
 			// Use a shorter timeout for quota fetches in health checks.
-			quotaCtx, quotaCancel := context.WithTimeout(r.Context(), config.QuotaFetchTimeout)
+			quotaCtx, quotaCancel := context.WithTimeout(ctx, config.QuotaFetchTimeout)
 
 			switch providerName {
 			case "zai":
@@ -966,6 +1828,32 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 
+			case "anthropic":
+				// The Anthropic API has no quota endpoint; rate limits are tracked per request.
+				quotaCancel()
+				if a.APIKey == "" {
+					baseInfo["status"] = "error"
+					baseInfo["error"] = "no API key"
+					baseInfo["models"] = map[string]interface{}{}
+					mu.Lock()
+					results = append(results, accountDetail{idx: idx, val: baseInfo})
+					mu.Unlock()
+					return
+				}
+
+			case "vertex":
+				// Vertex AI quotas are per project and not queryable here; rate limits are tracked per request.
+				quotaCancel()
+				if a.APIKey == "" {
+					baseInfo["status"] = "error"
+					baseInfo["error"] = "no service account key"
+					baseInfo["models"] = map[string]interface{}{}
+					mu.Lock()
+					results = append(results, accountDetail{idx: idx, val: baseInfo})
+					mu.Unlock()
+					return
+				}
+
 			case "copilot":
 				// Copilot accounts use GitHub token -> Copilot token exchange
 				if a.RefreshToken == "" {
@@ -1026,7 +1914,7 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 
-				quotas, err = s.getModelQuotas(quotaCtx, token)
+				quotas, err = s.getModelQuotas(quotaCtx, a.Email, token)
 				quotaCancel()
 				if err != nil {
 					baseInfo["status"] = "error"
@@ -1095,6 +1983,8 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 
+			s.recordQuotas(a.Email, providerName, formatted, time.Now())
+
 			if isLimited {
 				baseInfo["status"] = "rate-limited"
 			} else if accIsSoftLimited {
@@ -1123,9 +2013,12 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	rateLimited = 0
 	softLimited = 0
 	errorCount := 0
+	disabled := 0
 	for _, r := range results {
 		status, _ := r.val["status"].(string)
 		switch status {
+		case "disabled":
+			disabled++
 		case "invalid":
 			invalid++
 		case "rate-limited":
@@ -1136,21 +2029,21 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 			errorCount++ // Track errors separately from invalid
 		}
 	}
-	// Unavailable = invalid + error (accounts we can't use right now)
-	unavailable := invalid + errorCount
+	// Unavailable = invalid + error + disabled (accounts we can't use right now)
+	unavailable := invalid + errorCount + disabled
 	available = total - unavailable
 	if softLimitEnabled {
 		summary = fmt.Sprintf("%d total, %d available, %d soft-limited, %d rate-limited, %d invalid", total, available, softLimited, rateLimited, invalid)
 	} else {
 		summary = fmt.Sprintf("%d total, %d available, %d rate-limited, %d invalid", total, available, rateLimited, invalid)
 	}
+	if disabled > 0 {
+		summary += fmt.Sprintf(", %d disabled", disabled)
+	}
 
-	w.Header().Set("Content-Type", "application/json")
 	response := map[string]interface{}{
-		"status":    "ok",
-		"timestamp": formatISOTimeUTC(time.Now()),
-		"latencyMs": time.Since(start).Milliseconds(),
-		"summary":   summary,
+		"status":  "ok",
+		"summary": summary,
 		"counts": map[string]interface{}{
 			"total":       total,
 			"available":   available,
@@ -1158,10 +2051,15 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 			"softLimited": softLimited,
 			"invalid":     invalid,
 			"error":       errorCount,
+			"disabled":    disabled,
 		},
 		"accounts": detailed,
 	}
 
+	if opts.light {
+		response["mode"] = "light"
+	}
+
 	// Add soft limit settings to response
 	if softLimitEnabled {
 		response["softLimit"] = map[string]interface{}{
@@ -1170,7 +2068,30 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	_ = json.NewEncoder(w).Encode(response)
+	// Accounts that failed to fetch models at startup (per provider).
+	if initFailures := s.collectInitFailures(); len(initFailures) > 0 {
+		response["initFailures"] = initFailures
+	}
+
+	return response
+}
+
+// collectInitFailures gathers per-account startup failures from providers that report them.
+func (s *Server) collectInitFailures() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	if s.registry == nil {
+		return result
+	}
+	for _, p := range s.registry.All() {
+		reporter, ok := p.(provider.InitReporter)
+		if !ok {
+			continue
+		}
+		if failures := reporter.InitFailures(); len(failures) > 0 {
+			result[p.Name()] = failures
+		}
+	}
+	return result
 }
 
 // handleAccountLimits handles GET /account-limits requests.
@@ -1265,6 +2186,7 @@ func (s *Server) handleAccountLimits(w http.ResponseWriter, r *http.Request) {
 					"resetTime":         quotaInfo.ResetTime,
 				}
 			}
+			s.recordQuotas(acc.Email, providerName, quotas, time.Now())
 
 			accountLimits = append(accountLimits, map[string]interface{}{
 				"email":    acc.Email,
@@ -1273,6 +2195,36 @@ func (s *Server) handleAccountLimits(w http.ResponseWriter, r *http.Request) {
 				"models":   quotas,
 			})
 
+		case "anthropic":
+			// The Anthropic API has no quota endpoint; rate limits are tracked per request.
+			quotaCancel()
+			accountStatus := map[string]interface{}{
+				"email":    acc.Email,
+				"provider": providerName,
+				"status":   "ok",
+				"models":   map[string]interface{}{},
+			}
+			if acc.APIKey == "" {
+				accountStatus["status"] = "error"
+				accountStatus["error"] = "no API key"
+			}
+			accountLimits = append(accountLimits, accountStatus)
+
+		case "vertex":
+			// Vertex AI quotas are per project and not queryable here; rate limits are tracked per request.
+			quotaCancel()
+			accountStatus := map[string]interface{}{
+				"email":    acc.Email,
+				"provider": providerName,
+				"status":   "ok",
+				"models":   map[string]interface{}{},
+			}
+			if acc.APIKey == "" {
+				accountStatus["status"] = "error"
+				accountStatus["error"] = "no service account key"
+			}
+			accountLimits = append(accountLimits, accountStatus)
+
 		case "copilot":
 			// Copilot accounts use GitHub token -> Copilot token exchange
 			if acc.RefreshToken == "" {
@@ -1322,6 +2274,7 @@ func (s *Server) handleAccountLimits(w http.ResponseWriter, r *http.Request) {
 			if usage.QuotaResetDate != "" {
 				limits["quotaResetDate"] = usage.QuotaResetDate
 			}
+			s.recordQuotas(acc.Email, providerName, limits, time.Now())
 
 			accountLimits = append(accountLimits, map[string]interface{}{
 				"email":    acc.Email,
@@ -1344,7 +2297,7 @@ func (s *Server) handleAccountLimits(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			rawQuotas, err := s.getModelQuotas(quotaCtx, token)
+			rawQuotas, err := s.getModelQuotas(quotaCtx, acc.Email, token)
 			quotaCancel()
 			if err != nil {
 				accountLimits = append(accountLimits, map[string]interface{}{
@@ -1361,6 +2314,7 @@ func (s *Server) handleAccountLimits(w http.ResponseWriter, r *http.Request) {
 			for modelID, info := range rawQuotas {
 				quotas[fmt.Sprintf("%s/%s", providerName, modelID)] = info
 			}
+			s.recordQuotas(acc.Email, providerName, quotas, time.Now())
 
 			accountLimits = append(accountLimits, map[string]interface{}{
 				"email":    acc.Email,
@@ -1386,10 +2340,19 @@ func (s *Server) handleAccountLimits(w http.ResponseWriter, r *http.Request) {
 	}
 	sort.Strings(sortedModels)
 
-	if format == "table" {
+	switch format {
+	case "table":
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.Write([]byte(renderAccountLimitsTable(time.Now(), allAccounts, accountLimits, sortedModels)))
 		return
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Write([]byte(renderAccountLimitsCSV(accountLimits)))
+		return
+	case "prom":
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write([]byte(renderAccountLimitsProm(accountLimits)))
+		return
 	}
 
 	// Default: JSON format (Node parity).
@@ -1404,6 +2367,28 @@ func (s *Server) handleAccountLimits(w http.ResponseWriter, r *http.Request) {
 
 // Helper functions
 
+// readRequestBody reads the request body, enforcing the body limit configured for the
+// endpoint (see config.GetBodyLimitConfig). On failure it writes a 413 or 400 error and
+// returns false.
+func readRequestBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	limit := config.GetBodyLimitConfig().ForPath(r.URL.Path)
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if stderrors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "invalid_request_error",
+				fmt.Sprintf("Request body too large for %s (max %d bytes)", r.URL.Path, tooLarge.Limit))
+			return nil, false
+		}
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "Failed to read request body")
+		return nil, false
+	}
+	return body, true
+}
+
 func writeError(w http.ResponseWriter, statusCode int, errorType, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -1439,8 +2424,10 @@ func (s *Server) handleNotFound(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (s *Server) getModelQuotas(ctx context.Context, token string) (map[string]interface{}, error) {
-	data, err := s.agClient.FetchAvailableModels(ctx, token)
+// getModelQuotas returns an Antigravity account's per-model quotas. Responses are cached
+// for config.QuotaCacheTTL, shared with the provider's GetStatus.
+func (s *Server) getModelQuotas(ctx context.Context, email, token string) (map[string]interface{}, error) {
+	data, err := s.agClient.FetchAccountQuotas(ctx, email, token)
 	if err != nil {
 		return nil, err
 	}
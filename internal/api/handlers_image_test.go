@@ -144,6 +144,24 @@ func TestHandleImageGenerate_Validation(t *testing.T) {
 		}
 	})
 
+	t.Run("returns 413 with the endpoint limit", func(t *testing.T) {
+		t.Setenv("IMAGES_BODY_LIMIT_MB", "1")
+		server := NewServer(nil, nil)
+
+		body := strings.NewReader(`{"prompt":"` + strings.Repeat("a", 1024*1024) + `"}`)
+		req := httptest.NewRequest(http.MethodPost, "/v1/images/generate", body)
+		rr := httptest.NewRecorder()
+
+		server.handleImageGenerate(rr, req)
+
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected 413, got %d", rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), "max 1048576 bytes") {
+			t.Errorf("expected the images limit in the error, got %s", rr.Body.String())
+		}
+	})
+
 	t.Run("returns 400 for missing prompt", func(t *testing.T) {
 		server := NewServer(nil, nil)
 
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// healthCache holds the latest /health report built by the background refresher.
+type healthCache struct {
+	refreshMu sync.Mutex // serializes refreshes so concurrent callers don't fan out twice
+
+	mu          sync.RWMutex
+	report      map[string]interface{}
+	refreshedAt time.Time
+}
+
+func (c *healthCache) get() (map[string]interface{}, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.report, c.refreshedAt
+}
+
+func (c *healthCache) refresh(build func() map[string]interface{}) (map[string]interface{}, time.Time) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	report := build()
+	refreshedAt := time.Now()
+
+	c.mu.Lock()
+	c.report, c.refreshedAt = report, refreshedAt
+	c.mu.Unlock()
+	return report, refreshedAt
+}
+
+// StartHealthRefresher rebuilds the /health report every interval until stop is closed,
+// so health checks are answered from cache instead of fetching quotas for every account.
+// The first refresh starts immediately in the background.
+func (s *Server) StartHealthRefresher(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+	s.health = &healthCache{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.refreshHealth(ctx)
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+}
+
+func (s *Server) refreshHealth(ctx context.Context) {
+	if err := s.ensureInitialized(); err != nil {
+		utils.Debug("[Health] Skipping refresh: %v", err)
+		return
+	}
+	start := time.Now()
+	s.health.refresh(func() map[string]interface{} { return s.buildHealthReport(ctx, healthOptions{}) })
+	utils.Debug("[Health] Refreshed health report in %s", formatDuration(time.Since(start)))
+}
+
+// healthReport returns the cached health report and when it was built. Without a refresher
+// (or before its first refresh completes) the report is built on demand; refreshedAt is zero
+// when the report was not cached.
+func (s *Server) healthReport(ctx context.Context) (map[string]interface{}, time.Time) {
+	if s.health == nil {
+		return s.buildHealthReport(ctx, healthOptions{}), time.Time{}
+	}
+	if report, refreshedAt := s.health.get(); report != nil {
+		return report, refreshedAt
+	}
+	return s.health.refresh(func() map[string]interface{} {
+		// Another caller may have finished the first refresh while we waited.
+		if report, _ := s.health.get(); report != nil {
+			return report
+		}
+		return s.buildHealthReport(ctx, healthOptions{})
+	})
+}
+
+// healthOptions narrows a /health report: light skips the upstream quota fetches and reports
+// only locally tracked state; provider and account limit it to matching accounts.
+type healthOptions struct {
+	light    bool
+	provider string
+	account  string
+}
+
+// parseHealthOptions reads the mode, provider and account query parameters of /health.
+func (s *Server) parseHealthOptions(q url.Values) (healthOptions, error) {
+	opts := healthOptions{provider: q.Get("provider"), account: q.Get("account")}
+	switch mode := q.Get("mode"); mode {
+	case "", "deep":
+	case "light":
+		opts.light = true
+	default:
+		return opts, fmt.Errorf("unknown mode %q; use light or deep", mode)
+	}
+	if opts.provider != "" && s.registry != nil {
+		if p, ok := s.registry.GetByName(opts.provider); !ok || p == nil {
+			return opts, fmt.Errorf("unknown provider %q", opts.provider)
+		}
+	}
+	if opts.account != "" && s.accountManager != nil {
+		if _, ok := s.accountManager.GetAccount(opts.account); !ok {
+			return opts, fmt.Errorf("unknown account %q", opts.account)
+		}
+	}
+	return opts, nil
+}
+
+// includes reports whether acc is part of a report narrowed by o.
+func (o healthOptions) includes(acc account.Account, providerName string) bool {
+	return (o.provider == "" || o.provider == providerName) && (o.account == "" || o.account == acc.Email)
+}
+
+// handleHealthLive handles GET /health/live: the process is up and serving requests.
+// It stays 200 when every account is rate-limited or invalid and while draining, since
+// restarting the process would not help; readiness covers those cases.
+func (s *Server) handleHealthLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.handleNotFound(w, r)
+		return
+	}
+	writeHealthStatus(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// handleHealthReady handles GET /health/ready: accounts are loaded and, if any are
+// configured, at least one of them is available according to the (cached) health report.
+// It reports 503 once the server is draining for shutdown.
+func (s *Server) handleHealthReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.handleNotFound(w, r)
+		return
+	}
+	if s.Draining() {
+		writeHealthStatus(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "draining"})
+		return
+	}
+	if err := s.ensureInitialized(); err != nil {
+		writeHealthStatus(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status": "error",
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	report, refreshedAt := s.healthReport(r.Context())
+	counts, _ := report["counts"].(map[string]interface{})
+	total, _ := counts["total"].(int)
+	available, _ := counts["available"].(int)
+
+	body := map[string]interface{}{
+		"status":  "ok",
+		"summary": report["summary"],
+	}
+	if !refreshedAt.IsZero() {
+		body["cachedAt"] = formatISOTimeUTC(refreshedAt)
+		body["cacheAgeMs"] = time.Since(refreshedAt).Milliseconds()
+	}
+
+	code := http.StatusOK
+	if total > 0 && available == 0 {
+		code = http.StatusServiceUnavailable
+		body["status"] = "unavailable"
+	}
+	writeHealthStatus(w, code, body)
+}
+
+func writeHealthStatus(w http.ResponseWriter, code int, body map[string]interface{}) {
+	body["timestamp"] = formatISOTimeUTC(time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+)
+
+func newHealthTestServer(t *testing.T) (*Server, *account.Manager) {
+	dir, err := os.MkdirTemp("", "mcp-health-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	// Anthropic accounts have no quota endpoint, so building a report needs no network.
+	mgr := account.NewManager(filepath.Join(dir, "accounts.json"))
+	if err := mgr.AddAccount(account.Account{Email: "a@x", Provider: "anthropic", Source: "manual", APIKey: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(nil, mgr), mgr
+}
+
+func getHealth(t *testing.T, s *Server, path string) (int, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("%s: invalid JSON: %v", path, err)
+	}
+	return rec.Code, body
+}
+
+func TestHandleHealth_ServesCachedReport(t *testing.T) {
+	s, mgr := newHealthTestServer(t)
+	stop := make(chan struct{})
+	defer close(stop)
+	s.StartHealthRefresher(time.Hour, stop)
+
+	code, body := getHealth(t, s, "/health")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, body)
+	}
+	if _, ok := body["cachedAt"].(string); !ok {
+		t.Fatalf("expected cachedAt in cached report, got %v", body)
+	}
+	if body["summary"] != "1 total, 1 available, 0 rate-limited, 0 invalid" {
+		t.Errorf("unexpected summary: %v", body["summary"])
+	}
+
+	// Changes show up only after the next refresh.
+	mgr.MarkInvalid("a@x", "revoked")
+	if _, body = getHealth(t, s, "/health"); body["summary"] != "1 total, 1 available, 0 rate-limited, 0 invalid" {
+		t.Errorf("expected cached summary, got %v", body["summary"])
+	}
+	if code, _ := getHealth(t, s, "/health/ready"); code != http.StatusOK {
+		t.Errorf("expected ready from cache, got %d", code)
+	}
+
+	s.refreshHealth(t.Context())
+	if _, body = getHealth(t, s, "/health"); body["summary"] != "1 total, 0 available, 0 rate-limited, 1 invalid" {
+		t.Errorf("expected refreshed summary, got %v", body["summary"])
+	}
+	if code, body := getHealth(t, s, "/health/ready"); code != http.StatusServiceUnavailable || body["status"] != "unavailable" {
+		t.Errorf("expected 503 unavailable, got %d: %v", code, body)
+	}
+	if code, _ := getHealth(t, s, "/health/live"); code != http.StatusOK {
+		t.Errorf("expected live 200, got %d", code)
+	}
+}
+
+func TestHandleHealth_WithoutRefresher(t *testing.T) {
+	s, mgr := newHealthTestServer(t)
+
+	_, body := getHealth(t, s, "/health")
+	if _, ok := body["cachedAt"]; ok {
+		t.Errorf("live report should not carry cachedAt: %v", body)
+	}
+
+	mgr.MarkInvalid("a@x", "revoked")
+	if _, body = getHealth(t, s, "/health"); body["summary"] != "1 total, 0 available, 0 rate-limited, 1 invalid" {
+		t.Errorf("expected live summary, got %v", body["summary"])
+	}
+}
+
+func TestHandleHealth_LightModeAndFilters(t *testing.T) {
+	s, mgr := newHealthTestServer(t)
+	// A Z.AI quota fetch would fail here, so only light checks can report it as usable.
+	if err := mgr.AddAccount(account.Account{Email: "z@x", Provider: "zai", Source: "manual", APIKey: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	mgr.MarkRateLimited("z@x", 60000, "glm-4.7")
+	stop := make(chan struct{})
+	defer close(stop)
+	s.StartHealthRefresher(time.Hour, stop)
+
+	statuses := func(body map[string]interface{}) map[string]string {
+		result := map[string]string{}
+		accounts, _ := body["accounts"].([]interface{})
+		for _, a := range accounts {
+			acc, _ := a.(map[string]interface{})
+			email, _ := acc["email"].(string)
+			result[email], _ = acc["status"].(string)
+		}
+		return result
+	}
+
+	code, body := getHealth(t, s, "/health?mode=light")
+	if code != http.StatusOK || body["mode"] != "light" {
+		t.Fatalf("expected a light report, got %d: %v", code, body)
+	}
+	if _, ok := body["cachedAt"]; ok {
+		t.Errorf("light report should not come from the cache: %v", body)
+	}
+	if got := statuses(body); got["a@x"] != "ok" || got["z@x"] != "rate-limited" {
+		t.Errorf("unexpected light statuses: %v", got)
+	}
+	if body["summary"] != "2 total, 2 available, 1 rate-limited, 0 invalid" {
+		t.Errorf("unexpected light summary: %v", body["summary"])
+	}
+
+	_, body = getHealth(t, s, "/health?mode=light&provider=zai")
+	if got := statuses(body); len(got) != 1 || got["z@x"] != "rate-limited" {
+		t.Errorf("expected only the zai account, got %v", got)
+	}
+	_, body = getHealth(t, s, "/health?account=a@x")
+	if got := statuses(body); len(got) != 1 || got["a@x"] != "ok" || body["mode"] != nil {
+		t.Errorf("expected a deep check of a@x only, got %v", body)
+	}
+
+	for _, path := range []string{"/health?mode=shallow", "/health?account=nobody@x"} {
+		if code, body := getHealth(t, s, path); code != http.StatusBadRequest || body["status"] != "error" {
+			t.Errorf("%s: expected 400, got %d: %v", path, code, body)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// imageToolResultType is the block that carries an image tool call's result back to the
+// client, after the server_tool_use block of the call.
+const imageToolResultType = "image_generation_tool_result"
+
+const imageToolDescription = "Generate an image from a text description. The generated image is returned to you " +
+	"and shown to the user, so don't describe it unless asked."
+
+// imageToolInputSchema is used when the client lists the image tool without a schema.
+var imageToolInputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"prompt": map[string]interface{}{
+			"type":        "string",
+			"description": "Detailed description of the image to generate",
+		},
+		"aspect_ratio": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"1:1", "16:9", "9:16", "4:3", "3:4"},
+			"description": "Aspect ratio of the image (default 1:1)",
+		},
+	},
+	"required": []string{"prompt"},
+}
+
+// prepareImageTool sets req up for the image tool (IMAGE_TOOL_*) and returns the tool's
+// config. ok is false when the tool is disabled, no image provider is registered, or the
+// request doesn't list the tool (and IMAGE_TOOL_INJECT doesn't add it).
+//
+// Earlier image tool calls in the history are rewritten to text either way: they come back
+// as server_tool_use and image_generation_tool_result blocks no upstream accepts.
+func (s *Server) prepareImageTool(req *types.AnthropicRequest) (cfg config.ImageToolConfig, ok bool) {
+	cfg = config.GetImageToolConfig()
+	if !cfg.Enabled {
+		return cfg, false
+	}
+	if _, ok := s.imageProvider(); !ok {
+		return cfg, false
+	}
+	req.Messages = rewriteImageToolHistory(req.Messages, cfg.Name)
+
+	index := slices.IndexFunc(req.Tools, func(t types.Tool) bool {
+		return t.Name == cfg.Name && t.Raw == nil
+	})
+	switch {
+	case index >= 0:
+		tool := req.Tools[index]
+		if tool.Description != "" && tool.InputSchema != nil {
+			return cfg, true
+		}
+		if tool.Description == "" {
+			tool.Description = imageToolDescription
+		}
+		if tool.InputSchema == nil {
+			tool.InputSchema = imageToolInputSchema
+		}
+		req.Tools = slices.Clone(req.Tools)
+		req.Tools[index] = tool
+	case cfg.Inject && len(req.Tools) > 0:
+		req.Tools = append(slices.Clone(req.Tools), types.Tool{
+			Name:        cfg.Name,
+			Description: imageToolDescription,
+			InputSchema: imageToolInputSchema,
+		})
+	default:
+		return cfg, false
+	}
+	return cfg, true
+}
+
+// rewriteImageToolHistory replaces the image tool's server_tool_use and result blocks in
+// assistant messages with their text rendering. Messages are copied, not modified.
+func rewriteImageToolHistory(messages []types.Message, toolName string) []types.Message {
+	var out []types.Message
+	for i, msg := range messages {
+		if msg.Role != "assistant" || !bytes.Contains(msg.Content, []byte(imageToolResultType)) {
+			continue
+		}
+		blocks, err := types.ParseMessageContent(msg.Content)
+		if err != nil {
+			continue
+		}
+		for j, block := range blocks {
+			isCall := block.Type == "server_tool_use" && block.Name == toolName
+			if !isCall && block.Type != imageToolResultType {
+				continue
+			}
+			text, _ := block.ExtendedText()
+			blocks[j] = types.ContentBlock{Type: "text", Text: text}
+		}
+		content, err := json.Marshal(blocks)
+		if err != nil {
+			continue
+		}
+		if out == nil {
+			out = slices.Clone(messages)
+		}
+		out[i].Content = content
+	}
+	if out == nil {
+		return messages
+	}
+	return out
+}
+
+// handleImageToolMessage serves a /v1/messages request that offers the image tool: the
+// model's calls to it are run by the proxy (see runImageTool). Streaming requests get the
+// final response as a stream, with pings while the model and image calls run.
+func (s *Server) handleImageToolMessage(ctx context.Context, w http.ResponseWriter, r *http.Request, prov provider.Provider, req *types.AnthropicRequest, publicModel string, cfg config.ImageToolConfig) {
+	utils.Debug("[Messages] Image tool %s offered for %s", cfg.Name, req.Model)
+	trace := provider.TraceFromContext(ctx)
+
+	if !req.Stream {
+		resp, err := s.runImageTool(ctx, prov, req, cfg)
+		writeTraceHeaders(w.Header(), prov.Name(), trace)
+		if err != nil {
+			s.writeMessagesError(w, r, timeoutOr(ctx, err))
+			return
+		}
+		resp.Model = publicModel
+		resp.Usage.ServiceTier = effectiveServiceTier(req.ServiceTier)
+		data, _ := json.Marshal(toNodeMessageResponse(resp))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(data, '\n'))
+		return
+	}
+
+	declareTraceTrailers(w.Header())
+	defer writeTraceHeaders(w.Header(), prov.Name(), trace)
+	sse, err := NewSSEWriter(w)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "api_error", "Streaming not supported")
+		return
+	}
+	defer func() {
+		if ctx.Err() == nil {
+			writeTraceComment(sse, prov.Name(), trace)
+		}
+	}()
+
+	type result struct {
+		resp *types.AnthropicResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := s.runImageTool(ctx, prov, req, cfg)
+		done <- result{resp, err}
+	}()
+
+	var pingC <-chan time.Time
+	if s.pingInterval > 0 {
+		ticker := time.NewTicker(s.pingInterval)
+		defer ticker.Stop()
+		pingC = ticker.C
+	}
+	var res result
+	for waiting := true; waiting; {
+		select {
+		case res = <-done:
+			waiting = false
+		case <-pingC:
+			if err := sse.WriteRaw("ping", pingEventData); err != nil {
+				utils.Error("[Messages] Failed to write SSE ping: %v", err)
+				return
+			}
+		}
+	}
+	if res.err != nil {
+		s.writeMessagesStreamError(sse, timeoutOr(ctx, res.err))
+		return
+	}
+
+	res.resp.Model = publicModel
+	res.resp.Usage.ServiceTier = effectiveServiceTier(req.ServiceTier)
+	for _, event := range responseStreamEvents(res.resp) {
+		data, err := json.Marshal(event)
+		if err != nil {
+			utils.Error("[Messages] Failed to marshal SSE event: %v", err)
+			return
+		}
+		if err := sse.WriteRaw(event.Type, data); err != nil {
+			utils.Error("[Messages] Failed to write SSE event: %v", err)
+			return
+		}
+		if event.Type == "message_start" {
+			if err := sse.WriteRaw("ping", pingEventData); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// runImageTool sends req and runs the model's image tool calls: each call's images go back
+// to the model as a tool_result, and the model is called again, for up to
+// config.MaxImageToolRounds model calls. The returned response holds the content of every
+// round, with each call as a server_tool_use block followed by an image_generation_tool_result
+// block holding the images. If the model also calls client tools, the response ends there
+// with stop_reason tool_use.
+func (s *Server) runImageTool(ctx context.Context, prov provider.Provider, req *types.AnthropicRequest, cfg config.ImageToolConfig) (*types.AnthropicResponse, error) {
+	conv := *req
+	conv.Stream = false
+	conv.Messages = slices.Clone(req.Messages)
+
+	out := &types.AnthropicResponse{}
+	for round := 1; ; round++ {
+		resp, err := prov.SendMessage(ctx, &conv)
+		if err != nil {
+			return nil, err
+		}
+		out.ID, out.Type, out.Role, out.Model = resp.ID, resp.Type, resp.Role, resp.Model
+		out.StopReason, out.StopSequence = resp.StopReason, resp.StopSequence
+		out.Usage.InputTokens += resp.Usage.InputTokens
+		out.Usage.OutputTokens += resp.Usage.OutputTokens
+		out.Usage.CacheReadInputTokens += resp.Usage.CacheReadInputTokens
+		out.Usage.CacheCreationInputTokens += resp.Usage.CacheCreationInputTokens
+
+		calls, clientCalls := 0, 0
+		for _, block := range resp.Content {
+			if block.Type == "tool_use" {
+				if block.Name == cfg.Name {
+					calls++
+				} else {
+					clientCalls++
+				}
+			}
+		}
+		if calls == 0 || round >= config.MaxImageToolRounds {
+			out.Content = append(out.Content, resp.Content...)
+			break
+		}
+
+		var results []types.ContentBlock
+		for _, block := range resp.Content {
+			if block.Type != "tool_use" || block.Name != cfg.Name {
+				out.Content = append(out.Content, block)
+				continue
+			}
+			images, err := s.generateToolImages(ctx, block.Input, cfg.Model)
+			if err != nil {
+				utils.Warn("[Messages] Image tool call %s failed: %v", block.ID, err)
+			}
+			out.Content = append(out.Content, imageToolBlocks(block, images, err)...)
+			results = append(results, imageToolResult(block.ID, images, err))
+		}
+		if clientCalls > 0 {
+			break
+		}
+
+		assistant, err := json.Marshal(resp.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode assistant turn: %w", err)
+		}
+		user, err := json.Marshal(results)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode image tool results: %w", err)
+		}
+		conv.Messages = append(conv.Messages,
+			types.Message{Role: "assistant", Content: assistant},
+			types.Message{Role: "user", Content: user})
+	}
+
+	recordInputTokens(ctx, out.Usage)
+	if stats := requestStatsFromContext(ctx); stats != nil {
+		stats.OutputTokens = out.Usage.OutputTokens
+	}
+	return out, nil
+}
+
+// generateToolImages runs one image tool call.
+func (s *Server) generateToolImages(ctx context.Context, input map[string]interface{}, model string) ([]types.GeneratedImage, error) {
+	prompt, _ := input["prompt"].(string)
+	if prompt == "" {
+		return nil, fmt.Errorf("prompt is required")
+	}
+	aspectRatio, _ := input["aspect_ratio"].(string)
+
+	gen, ok := s.imageProvider()
+	if !ok {
+		return nil, fmt.Errorf("image generation provider not available")
+	}
+	req := &types.ImageGenerationRequest{Prompt: prompt, Model: model, AspectRatio: aspectRatio}
+	applyImageDefaults(req)
+	resp, err := gen.GenerateImage(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Images) == 0 {
+		return nil, fmt.Errorf("no image was generated")
+	}
+	return resp.Images, nil
+}
+
+// imageBlocks returns images as image content blocks, or err as a text block.
+func imageBlocks(images []types.GeneratedImage, err error) []types.ContentBlock {
+	if err != nil {
+		return []types.ContentBlock{{Type: "text", Text: fmt.Sprintf("Image generation failed: %v", err)}}
+	}
+	blocks := make([]types.ContentBlock, len(images))
+	for i, img := range images {
+		blocks[i] = types.ContentBlock{Type: "image", Source: &types.ImageSource{Type: "base64", MediaType: img.MediaType, Data: img.Data}}
+	}
+	return blocks
+}
+
+// imageToolResult is the tool_result the model gets for an image tool call. A text note
+// follows the images for models that drop image tool results.
+func imageToolResult(toolUseID string, images []types.GeneratedImage, err error) types.ContentBlock {
+	blocks := imageBlocks(images, err)
+	if err == nil {
+		blocks = append(blocks, types.ContentBlock{Type: "text", Text: fmt.Sprintf("Generated %d image(s); they are shown to the user.", len(images))})
+	}
+	content, _ := json.Marshal(blocks)
+	return types.ContentBlock{Type: "tool_result", ToolUseID: toolUseID, Content: content, IsError: err != nil}
+}
+
+// imageToolBlocks returns the blocks the client gets for an image tool call.
+func imageToolBlocks(call types.ContentBlock, images []types.GeneratedImage, err error) []types.ContentBlock {
+	input := call.Input
+	if input == nil {
+		input = map[string]interface{}{}
+	}
+	use, _ := json.Marshal(map[string]interface{}{
+		"type":  "server_tool_use",
+		"id":    call.ID,
+		"name":  call.Name,
+		"input": input,
+	})
+	result := map[string]interface{}{
+		"type":        imageToolResultType,
+		"tool_use_id": call.ID,
+		"content":     imageBlocks(images, err),
+	}
+	if err != nil {
+		result["is_error"] = true
+	}
+	resultJSON, _ := json.Marshal(result)
+	return []types.ContentBlock{
+		{Type: "server_tool_use", ID: call.ID, Name: call.Name, Input: input, Raw: use},
+		{Type: imageToolResultType, Raw: resultJSON},
+	}
+}
+
+// responseStreamEvents returns the stream events of a complete response.
+func responseStreamEvents(resp *types.AnthropicResponse) []types.StreamEvent {
+	start := *resp
+	start.Content = nil
+	start.StopReason, start.StopSequence = "", nil
+	start.Usage.OutputTokens = 0
+	events := []types.StreamEvent{{Type: "message_start", Message: &start}}
+
+	for i, block := range resp.Content {
+		var deltas []*types.Delta
+		startBlock := block
+		switch block.Type {
+		case "text":
+			startBlock = types.ContentBlock{Type: "text"}
+			deltas = append(deltas, &types.Delta{Type: "text_delta", Text: block.Text})
+		case "thinking":
+			startBlock = types.ContentBlock{Type: "thinking"}
+			deltas = append(deltas, &types.Delta{Type: "thinking_delta", Thinking: block.Thinking})
+			if block.Signature != "" {
+				deltas = append(deltas, &types.Delta{Type: "signature_delta", Signature: block.Signature})
+			}
+		case "tool_use":
+			startBlock = types.ContentBlock{Type: "tool_use", ID: block.ID, Name: block.Name}
+			input, _ := json.Marshal(block.Input)
+			if block.Input == nil {
+				input = []byte("{}")
+			}
+			deltas = append(deltas, &types.Delta{Type: "input_json_delta", PartialJSON: string(input)})
+		}
+		events = append(events, types.StreamEvent{Type: "content_block_start", Index: i, ContentBlock: &startBlock})
+		for _, delta := range deltas {
+			events = append(events, types.StreamEvent{Type: "content_block_delta", Index: i, Delta: delta})
+		}
+		events = append(events, types.StreamEvent{Type: "content_block_stop", Index: i})
+	}
+
+	var stopSequence string
+	if resp.StopSequence != nil {
+		stopSequence = *resp.StopSequence
+	}
+	return append(events,
+		types.StreamEvent{
+			Type:  "message_delta",
+			Delta: &types.Delta{StopReason: resp.StopReason, StopSequence: stopSequence},
+			Usage: &types.Usage{OutputTokens: resp.Usage.OutputTokens},
+		},
+		types.StreamEvent{Type: "message_stop"})
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/streamcheck"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// scriptedProvider answers SendMessage with its responses in turn and records the requests.
+type scriptedProvider struct {
+	mockProvider
+	responses []*types.AnthropicResponse
+	requests  []*types.AnthropicRequest
+}
+
+func (p *scriptedProvider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	p.requests = append(p.requests, req)
+	if len(p.requests) > len(p.responses) {
+		return nil, errors.New("unexpected request")
+	}
+	return p.responses[len(p.requests)-1], nil
+}
+
+// imageGenProvider stands in for Antigravity's image generation.
+type imageGenProvider struct {
+	mockProvider
+	err     error
+	prompts []string
+}
+
+func (p *imageGenProvider) GenerateImage(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
+	p.prompts = append(p.prompts, req.Prompt)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &types.ImageGenerationResponse{Images: []types.GeneratedImage{{MediaType: "image/png", Data: pngData}}}, nil
+}
+
+func newImageToolServer(t *testing.T, chat *scriptedProvider, gen *imageGenProvider) *Server {
+	t.Helper()
+	registry := provider.NewRegistry()
+	for _, prov := range []provider.Provider{chat, gen} {
+		if err := registry.Register(prov); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewServer(registry, nil)
+	s.pingInterval = 0
+	return s
+}
+
+func imageToolResponses() []*types.AnthropicResponse {
+	return []*types.AnthropicResponse{
+		{ID: "msg_1", Type: "message", Role: "assistant", StopReason: "tool_use", Usage: types.Usage{InputTokens: 10, OutputTokens: 5}, Content: []types.ContentBlock{
+			{Type: "text", Text: "Drawing it."},
+			{Type: "tool_use", ID: "toolu_1", Name: "generate_image", Input: map[string]interface{}{"prompt": "a red fox"}},
+		}},
+		{ID: "msg_2", Type: "message", Role: "assistant", StopReason: "end_turn", Usage: types.Usage{InputTokens: 20, OutputTokens: 3}, Content: []types.ContentBlock{
+			{Type: "text", Text: "Here is your fox."},
+		}},
+	}
+}
+
+const imageToolRequest = `{"model":"zai/glm-4.7","max_tokens":100,"tools":[{"name":"generate_image"}],"messages":[{"role":"user","content":"draw a fox"}]}`
+
+func TestHandleMessages_ImageTool(t *testing.T) {
+	tests := []struct {
+		name       string
+		genErr     error
+		wantResult string // text of the tool_result the model gets back
+		wantError  bool
+	}{
+		{name: "image generated", wantResult: "Generated"},
+		{name: "generation fails", genErr: errors.New("quota exhausted"), wantResult: "Image generation failed: quota exhausted", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat := &scriptedProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, responses: imageToolResponses()}
+			gen := &imageGenProvider{mockProvider: mockProvider{name: "antigravity"}, err: tt.genErr}
+			s := newImageToolServer(t, chat, gen)
+
+			w := httptest.NewRecorder()
+			s.handleMessages(w, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(imageToolRequest)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			if len(gen.prompts) != 1 || gen.prompts[0] != "a red fox" {
+				t.Errorf("expected one image call for the tool's prompt, got %v", gen.prompts)
+			}
+			if len(chat.requests) != 2 {
+				t.Fatalf("expected the model to be called again with the image, got %d calls", len(chat.requests))
+			}
+			if tool := chat.requests[0].Tools[0]; tool.Description == "" || tool.InputSchema == nil {
+				t.Errorf("expected the tool definition to be filled in, got %+v", tool)
+			}
+			followUp := chat.requests[1].Messages
+			if len(followUp) != 3 || followUp[2].Role != "user" {
+				t.Fatalf("expected assistant turn and tool result appended, got %d messages", len(followUp))
+			}
+			var results []types.ContentBlock
+			if err := json.Unmarshal(followUp[2].Content, &results); err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].Type != "tool_result" || results[0].ToolUseID != "toolu_1" || results[0].IsError != tt.wantError {
+				t.Fatalf("unexpected tool result: %+v", results)
+			}
+			if !strings.Contains(string(results[0].Content), tt.wantResult) {
+				t.Errorf("tool result %s doesn't contain %q", results[0].Content, tt.wantResult)
+			}
+
+			var resp struct {
+				StopReason string                   `json:"stop_reason"`
+				Usage      types.Usage              `json:"usage"`
+				Content    []map[string]interface{} `json:"content"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var blockTypes []string
+			for _, block := range resp.Content {
+				blockTypes = append(blockTypes, block["type"].(string))
+			}
+			if got := strings.Join(blockTypes, " "); got != "text server_tool_use image_generation_tool_result text" {
+				t.Errorf("content = %s", got)
+			}
+			if resp.StopReason != "end_turn" || resp.Usage.InputTokens != 30 || resp.Usage.OutputTokens != 8 {
+				t.Errorf("expected final stop reason and summed usage, got %s %+v", resp.StopReason, resp.Usage)
+			}
+			result := resp.Content[2]
+			if _, isError := result["is_error"]; isError != tt.wantError {
+				t.Errorf("unexpected result block: %v", result)
+			}
+		})
+	}
+}
+
+func TestHandleMessages_ImageToolStream(t *testing.T) {
+	chat := &scriptedProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, responses: imageToolResponses()}
+	s := newImageToolServer(t, chat, &imageGenProvider{mockProvider: mockProvider{name: "antigravity"}})
+
+	body := strings.Replace(imageToolRequest, `"max_tokens"`, `"stream":true,"max_tokens"`, 1)
+	w := httptest.NewRecorder()
+	s.handleMessages(w, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body)))
+
+	events, err := streamcheck.ParseSSE(w.Body.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := streamcheck.Check(events); err != nil {
+		t.Errorf("stream violates the protocol:\n%v", err)
+	}
+	if !strings.Contains(w.Body.String(), `"image_generation_tool_result"`) {
+		t.Errorf("expected the image result block in the stream:\n%s", w.Body.String())
+	}
+}
+
+func TestHandleMessages_ImageToolNotOffered(t *testing.T) {
+	chat := &scriptedProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, responses: imageToolResponses()[1:]}
+	gen := &imageGenProvider{mockProvider: mockProvider{name: "antigravity"}}
+	s := newImageToolServer(t, chat, gen)
+
+	body := `{"model":"zai/glm-4.7","max_tokens":100,"messages":[
+		{"role":"user","content":"draw a fox"},
+		{"role":"assistant","content":[
+			{"type":"server_tool_use","id":"toolu_1","name":"generate_image","input":{"prompt":"a red fox"}},
+			{"type":"image_generation_tool_result","tool_use_id":"toolu_1","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + pngData + `"}}]}]},
+		{"role":"user","content":"thanks"}]}`
+	w := httptest.NewRecorder()
+	s.handleMessages(w, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(chat.requests) != 1 || len(gen.prompts) != 0 {
+		t.Fatalf("expected a plain request, got %d model calls and %d image calls", len(chat.requests), len(gen.prompts))
+	}
+	history := string(chat.requests[0].Messages[1].Content)
+	if strings.Contains(history, "server_tool_use") || !strings.Contains(history, "Image generation result: 1 image(s)") {
+		t.Errorf("expected the earlier image call rewritten to text, got %s", history)
+	}
+}
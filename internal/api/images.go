@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// editableImageTypes are the source image media types the Gemini image models accept.
+var editableImageTypes = []string{"image/png", "image/jpeg", "image/webp", "image/heic", "image/heif"}
+
+// imageEditRequest is the body of POST /v1/images/edit.
+type imageEditRequest struct {
+	Prompt      string `json:"prompt"`               // Required: how to edit the image
+	Image       string `json:"image"`                // Required: base64 image or data URL
+	MediaType   string `json:"media_type,omitempty"` // Optional: detected from the image data
+	Model       string `json:"model,omitempty"`      // Optional: defaults to gemini-3-pro-image
+	AspectRatio string `json:"aspect_ratio,omitempty"`
+	Count       int    `json:"count,omitempty"`
+	SessionID   string `json:"session_id,omitempty"`
+}
+
+// imageProvider returns the Antigravity provider, which serves image generation.
+func (s *Server) imageProvider() (provider.Provider, bool) {
+	if s.registry == nil {
+		return nil, false
+	}
+	prov, ok := s.registry.GetByName("antigravity")
+	return prov, ok && prov != nil
+}
+
+// handleImageEdit handles POST /v1/images/edit requests: the source image and prompt are
+// sent to an Antigravity image model, with the same account failover as generation.
+func (s *Server) handleImageEdit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	body, ok := readRequestBody(w, r)
+	if !ok {
+		return
+	}
+	req, err := parseImageEditRequest(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	agProvider, ok := s.imageProvider()
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "api_error", "Image editing provider not available")
+		return
+	}
+
+	resp, err := agProvider.GenerateImage(r.Context(), req)
+	if err != nil {
+		ae := merrors.FromError(err)
+		writeError(w, ae.StatusCode(), string(ae.Detail.Type), ae.Detail.Message)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseImageEditRequest parses and validates an image edit request into the image
+// generation request that carries the source image.
+func parseImageEditRequest(body []byte) (*types.ImageGenerationRequest, error) {
+	var edit imageEditRequest
+	if err := json.Unmarshal(body, &edit); err != nil {
+		return nil, fmt.Errorf("Invalid JSON: %v", err)
+	}
+	if edit.Prompt == "" {
+		return nil, fmt.Errorf("prompt is required")
+	}
+	if edit.Image == "" {
+		return nil, fmt.Errorf("image is required")
+	}
+
+	data, mediaType := edit.Image, edit.MediaType
+	if rest, ok := strings.CutPrefix(data, "data:"); ok {
+		header, encoded, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(header, ";base64") {
+			return nil, fmt.Errorf("image must be a base64 data URL or base64 data")
+		}
+		if mediaType == "" {
+			mediaType = strings.TrimSuffix(header, ";base64")
+		}
+		data = encoded
+	}
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("image is not valid base64: %v", err)
+	}
+	if mediaType == "" {
+		mediaType = http.DetectContentType(decoded)
+	}
+	if !slices.Contains(editableImageTypes, mediaType) {
+		return nil, fmt.Errorf("unsupported image media type %s (supported: %s)", mediaType, strings.Join(editableImageTypes, ", "))
+	}
+
+	req := &types.ImageGenerationRequest{
+		Prompt:              edit.Prompt,
+		Model:               edit.Model,
+		AspectRatio:         edit.AspectRatio,
+		Count:               edit.Count,
+		InputImage:          data,
+		InputImageMediaType: mediaType,
+		SessionID:           edit.SessionID,
+	}
+	applyImageDefaults(req)
+	if !strings.Contains(strings.ToLower(req.Model), "image") {
+		return nil, fmt.Errorf("model %s does not support image editing; use an image model such as %s", req.Model, config.DefaultImageModel)
+	}
+	return req, nil
+}
+
+// applyImageDefaults fills in the default model and clamps the image count.
+func applyImageDefaults(req *types.ImageGenerationRequest) {
+	if req.Model == "" {
+		req.Model = config.DefaultImageModel
+	}
+	if req.Count <= 0 {
+		req.Count = config.DefaultImageCount
+	}
+	if req.Count > config.MaxImageCount {
+		req.Count = config.MaxImageCount
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// pngData is a 1x1 PNG, base64-encoded.
+const pngData = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg=="
+
+func TestParseImageEditRequest(t *testing.T) {
+	t.Run("detects the media type and applies defaults", func(t *testing.T) {
+		req, err := parseImageEditRequest([]byte(`{"prompt": "make it blue", "image": "` + pngData + `", "count": 9}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.Prompt != "make it blue" || req.InputImage != pngData || req.InputImageMediaType != "image/png" {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		if req.Model != config.DefaultImageModel || req.Count != config.MaxImageCount {
+			t.Errorf("expected defaults, got model %s count %d", req.Model, req.Count)
+		}
+	})
+
+	t.Run("accepts a data URL", func(t *testing.T) {
+		req, err := parseImageEditRequest([]byte(`{"prompt": "crop it", "image": "data:image/webp;base64,` + pngData + `"}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.InputImage != pngData || req.InputImageMediaType != "image/webp" {
+			t.Errorf("expected data URL to be unpacked, got %s / %s", req.InputImageMediaType, req.InputImage)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"invalid JSON", `{invalid`, "Invalid JSON"},
+		{"missing prompt", `{"image": "` + pngData + `"}`, "prompt is required"},
+		{"missing image", `{"prompt": "x"}`, "image is required"},
+		{"invalid base64", `{"prompt": "x", "image": "not base64!"}`, "not valid base64"},
+		{"not an image", `{"prompt": "x", "image": "aGVsbG8gd29ybGQ="}`, "unsupported image media type text/plain"},
+		{"non-image model", `{"prompt": "x", "image": "` + pngData + `", "model": "gemini-3-flash"}`, "does not support image editing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseImageEditRequest([]byte(tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHandleImageEdit_Validation(t *testing.T) {
+	server := NewServer(nil, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"non-POST", http.MethodGet, "", http.StatusNotFound},
+		{"missing image", http.MethodPost, `{"prompt": "x"}`, http.StatusBadRequest},
+		{"no provider", http.MethodPost, `{"prompt": "x", "image": "` + pngData + `"}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/images/edit", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			server.handleImageEdit(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
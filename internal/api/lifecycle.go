@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// inFlightPollInterval is how often the preStop hook checks whether in-flight requests finished.
+const inFlightPollInterval = 100 * time.Millisecond
+
+// lifecycle tracks shutdown state for the readiness probe and the preStop hook.
+type lifecycle struct {
+	probes   config.ProbeConfig
+	draining atomic.Bool
+	inFlight atomic.Int64
+}
+
+// SetProbeConfig sets the probe paths, preStop path and drain delay used by Handler.
+func (s *Server) SetProbeConfig(cfg config.ProbeConfig) {
+	s.lifecycle.probes = cfg
+}
+
+// BeginDrain marks the server as shutting down: readiness turns 503 while liveness and
+// all other endpoints keep serving. It reports whether this call started the drain.
+func (s *Server) BeginDrain() bool {
+	if !s.lifecycle.draining.CompareAndSwap(false, true) {
+		return false
+	}
+	utils.Info("[Server] Draining: readiness probe now reports 503")
+	return true
+}
+
+// Draining reports whether BeginDrain has been called.
+func (s *Server) Draining() bool {
+	return s.lifecycle.draining.Load()
+}
+
+// TrackInFlight counts /v1 requests in progress so the preStop hook can wait for them.
+func (s *Server) TrackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.lifecycle.inFlight.Add(1)
+		defer s.lifecycle.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// waitForInFlight blocks until no /v1 requests are in progress or ctx is done,
+// returning how many are still running.
+func (s *Server) waitForInFlight(ctx context.Context) int64 {
+	ticker := time.NewTicker(inFlightPollInterval)
+	defer ticker.Stop()
+	for {
+		n := s.lifecycle.inFlight.Load()
+		if n == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return n
+		case <-ticker.C:
+		}
+	}
+}
+
+// handlePreStop handles GET/POST on the preStop path (PRESTOP_PATH). It marks the server
+// not ready, keeps serving for SHUTDOWN_DRAIN_DELAY so endpoints controllers stop routing
+// new traffic, then waits for in-flight /v1 requests. GET is accepted because Kubernetes
+// httpGet hooks can only send GET.
+func (s *Server) handlePreStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	s.BeginDrain()
+	if delay := s.lifecycle.probes.DrainDelay; delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+		}
+	}
+	remaining := s.waitForInFlight(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "ok",
+		"draining": true,
+		"inFlight": remaining,
+	})
+}
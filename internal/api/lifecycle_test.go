@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+func TestHealthReady_DrainingKeepsLiveness(t *testing.T) {
+	s, _ := newHealthTestServer(t)
+
+	if code, body := getHealth(t, s, "/health/ready"); code != http.StatusOK {
+		t.Fatalf("expected ready before draining, got %d: %v", code, body)
+	}
+	if !s.BeginDrain() {
+		t.Fatal("expected first BeginDrain to start the drain")
+	}
+	if s.BeginDrain() {
+		t.Error("expected second BeginDrain to report an existing drain")
+	}
+
+	code, body := getHealth(t, s, "/health/ready")
+	if code != http.StatusServiceUnavailable || body["status"] != "draining" {
+		t.Errorf("expected 503 draining, got %d: %v", code, body)
+	}
+	if code, body := getHealth(t, s, "/health/live"); code != http.StatusOK {
+		t.Errorf("expected liveness to stay 200 while draining, got %d: %v", code, body)
+	}
+}
+
+func TestProbePaths_ConfiguredAndExemptFromAuth(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "secret")
+	t.Setenv("PROBE_LIVE_PATH", "/livez")
+	t.Setenv("PROBE_READY_PATH", "/readyz")
+
+	s, _ := newHealthTestServer(t)
+	s.SetProbeConfig(config.GetProbeConfig())
+
+	for _, path := range []string{"/livez", "/readyz", "/health/live", "/health/ready"} {
+		if code, body := getHealth(t, s, path); code != http.StatusOK {
+			t.Errorf("%s: expected 200 without an API key, got %d: %v", path, code, body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, config.DefaultPreStopPath, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected preStop hook to require the API key, got %d", rec.Code)
+	}
+	if s.Draining() {
+		t.Error("unauthenticated preStop request must not start draining")
+	}
+}
+
+func TestPreStop_WaitsForInFlightRequests(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "secret")
+
+	s, _ := newHealthTestServer(t)
+	s.SetProbeConfig(config.ProbeConfig{PreStopPath: "/prestop", DrainDelay: 20 * time.Millisecond})
+	handler := s.Handler()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := s.TrackInFlight(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	go slow.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/messages", nil))
+	<-started
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "/prestop", nil)
+		req.Header.Set("x-api-key", "secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		done <- rec
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("preStop returned while a request was still in flight")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if !s.Draining() {
+		t.Error("expected preStop to start draining")
+	}
+
+	close(release)
+	rec := <-done
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["inFlight"] != float64(0) {
+		t.Errorf("expected no requests left in flight, got %v", body["inFlight"])
+	}
+}
+
+func TestWaitForInFlight_ContextDone(t *testing.T) {
+	s := NewServer(nil, nil)
+	s.lifecycle.inFlight.Add(2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if n := s.waitForInFlight(ctx); n != 2 {
+		t.Errorf("expected 2 requests still in flight, got %d", n)
+	}
+}
@@ -1,12 +1,15 @@
 package api
 
 import (
+	"encoding/csv"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
 )
 
 // Table column widths for account status table.
@@ -63,11 +66,18 @@ func renderAccountLimitsJSON(sortedModels []string, accountLimits []map[string]i
 				}
 			}
 
-			limits[modelID] = map[string]interface{}{
+			limit := map[string]interface{}{
 				"remaining":         remaining,
 				"remainingFraction": rf,
 				"resetTime":         rt,
 			}
+			// Quota tracking estimates (see recordQuotas), when enabled.
+			for _, key := range []string{"burnRatePerHour", "exhaustsAt", "usageDiscrepancy"} {
+				if v, ok := quota[key]; ok {
+					limit[key] = v
+				}
+			}
+			limits[modelID] = limit
 		}
 
 		accounts = append(accounts, map[string]interface{}{
@@ -409,3 +419,137 @@ func filterModelsForProvider(models []string, provider string) []string {
 	}
 	return result
 }
+
+// accountQuotaRow is one quota of one account, as exported by the CSV and Prometheus
+// formats. An account without quota data (an error, or a provider without a quota API)
+// has a single row with no model.
+type accountQuotaRow struct {
+	email     string
+	provider  string
+	status    string
+	errMsg    string
+	model     string
+	remaining *float64
+	reset     time.Time
+}
+
+// accountQuotaRows flattens account limits into one row per account and model, in account
+// order with models sorted. Copilot quotas (chat, premium_interactions) are named like
+// models of the copilot provider.
+func accountQuotaRows(accountLimits []map[string]interface{}) []accountQuotaRow {
+	rows := make([]accountQuotaRow, 0, len(accountLimits))
+	for _, acc := range accountLimits {
+		base := accountQuotaRow{}
+		base.email, _ = acc["email"].(string)
+		base.provider, _ = acc["provider"].(string)
+		base.status, _ = acc["status"].(string)
+		base.errMsg, _ = acc["error"].(string)
+
+		quotas := map[string]map[string]interface{}{}
+		models, _ := acc["models"].(map[string]interface{})
+		for modelID, v := range models {
+			if q, ok := v.(map[string]interface{}); ok {
+				quotas[modelID] = q
+			}
+		}
+		limits, _ := acc["limits"].(map[string]interface{})
+		for name, v := range limits {
+			if q, ok := v.(map[string]interface{}); ok {
+				quotas[base.provider+"/"+name] = q
+			}
+		}
+		if len(quotas) == 0 {
+			rows = append(rows, base)
+			continue
+		}
+
+		modelIDs := make([]string, 0, len(quotas))
+		for modelID := range quotas {
+			modelIDs = append(modelIDs, modelID)
+		}
+		sort.Strings(modelIDs)
+		for _, modelID := range modelIDs {
+			row := base
+			row.model = modelID
+			if rf, ok := quotas[modelID]["remainingFraction"].(float64); ok {
+				row.remaining = &rf
+			}
+			row.reset = quotaResetTime(quotas[modelID]["resetTime"])
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// quotaResetTime reads a quota's resetTime, which providers report as an RFC 3339 string
+// or a time. The zero time means none.
+func quotaResetTime(v interface{}) time.Time {
+	switch rt := v.(type) {
+	case string:
+		t, _ := time.Parse(time.RFC3339, rt)
+		return t
+	case time.Time:
+		return rt
+	case *time.Time:
+		if rt != nil {
+			return *rt
+		}
+	}
+	return time.Time{}
+}
+
+// renderAccountLimitsCSV formats account limits as CSV with a header row, one row per
+// account and model.
+func renderAccountLimitsCSV(accountLimits []map[string]interface{}) string {
+	var b strings.Builder
+	cw := csv.NewWriter(&b)
+	_ = cw.Write([]string{"email", "provider", "status", "model", "remaining_fraction", "reset_time", "error"})
+	for _, row := range accountQuotaRows(accountLimits) {
+		remaining, reset := "", ""
+		if row.remaining != nil {
+			remaining = strconv.FormatFloat(*row.remaining, 'f', -1, 64)
+		}
+		if !row.reset.IsZero() {
+			reset = formatISOTimeUTC(row.reset)
+		}
+		_ = cw.Write([]string{row.email, row.provider, row.status, row.model, remaining, reset, row.errMsg})
+	}
+	cw.Flush()
+	return b.String()
+}
+
+// renderAccountLimitsProm formats account limits in the Prometheus text exposition format.
+func renderAccountLimitsProm(accountLimits []map[string]interface{}) string {
+	rows := accountQuotaRows(accountLimits)
+	var status, remaining, reset strings.Builder
+	seen := make(map[string]bool, len(accountLimits))
+	for _, row := range rows {
+		account := fmt.Sprintf(`email="%s",provider="%s"`, metrics.EscapeLabel(row.email), metrics.EscapeLabel(row.provider))
+		if !seen[row.email] {
+			seen[row.email] = true
+			fmt.Fprintf(&status, "proxy_account_status{%s,status=\"%s\"} 1\n", account, metrics.EscapeLabel(row.status))
+		}
+		if row.model == "" {
+			continue
+		}
+		labels := fmt.Sprintf(`%s,model="%s"`, account, metrics.EscapeLabel(row.model))
+		if row.remaining != nil {
+			fmt.Fprintf(&remaining, "proxy_account_quota_remaining_fraction{%s} %s\n", labels, strconv.FormatFloat(*row.remaining, 'g', -1, 64))
+		}
+		if !row.reset.IsZero() {
+			fmt.Fprintf(&reset, "proxy_account_quota_reset_timestamp_seconds{%s} %d\n", labels, row.reset.Unix())
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# HELP proxy_account_status Status of each account when its quota was fetched (ok, invalid or error).\n")
+	b.WriteString("# TYPE proxy_account_status gauge\n")
+	b.WriteString(status.String())
+	b.WriteString("# HELP proxy_account_quota_remaining_fraction Fraction of the account's quota left for the model.\n")
+	b.WriteString("# TYPE proxy_account_quota_remaining_fraction gauge\n")
+	b.WriteString(remaining.String())
+	b.WriteString("# HELP proxy_account_quota_reset_timestamp_seconds When the account's quota for the model resets, as a Unix timestamp.\n")
+	b.WriteString("# TYPE proxy_account_quota_reset_timestamp_seconds gauge\n")
+	b.WriteString(reset.String())
+	return b.String()
+}
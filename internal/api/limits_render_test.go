@@ -462,3 +462,75 @@ func TestHasProviderPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderAccountLimitsCSVAndProm(t *testing.T) {
+	reset := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	accountLimits := []map[string]interface{}{
+		{
+			"email":    "ag@example.com",
+			"provider": "antigravity",
+			"status":   "ok",
+			"models": map[string]interface{}{
+				"antigravity/gemini-3-flash":    map[string]interface{}{"remainingFraction": 0.5, "resetTime": "2025-01-01T00:00:00Z"},
+				"antigravity/claude-sonnet-4-5": map[string]interface{}{"remainingFraction": nil, "resetTime": "2025-01-01T00:00:00Z"},
+			},
+		},
+		{
+			"email":    "zai@example.com",
+			"provider": "zai",
+			"status":   "ok",
+			"models": map[string]interface{}{
+				"zai/glm-4.7": map[string]interface{}{"remainingFraction": 0.25, "resetTime": &reset},
+			},
+		},
+		{
+			"email":    "cp@example.com",
+			"provider": "copilot",
+			"status":   "ok",
+			"limits": map[string]interface{}{
+				"chat":           map[string]interface{}{"remainingFraction": 1.0, "unlimited": true},
+				"quotaResetDate": "2025-02-01",
+			},
+		},
+		{
+			"email":    "bad@example.com",
+			"provider": "zai",
+			"status":   "error",
+			"error":    `quota fetch failed: "timeout"`,
+			"models":   map[string]interface{}{},
+		},
+	}
+
+	wantCSV := strings.Join([]string{
+		"email,provider,status,model,remaining_fraction,reset_time,error",
+		"ag@example.com,antigravity,ok,antigravity/claude-sonnet-4-5,,2025-01-01T00:00:00.000Z,",
+		"ag@example.com,antigravity,ok,antigravity/gemini-3-flash,0.5,2025-01-01T00:00:00.000Z,",
+		"zai@example.com,zai,ok,zai/glm-4.7,0.25,2025-01-01T00:00:00.000Z,",
+		"cp@example.com,copilot,ok,copilot/chat,1,,",
+		`bad@example.com,zai,error,,,,"quota fetch failed: ""timeout"""`,
+		"",
+	}, "\n")
+	if got := renderAccountLimitsCSV(accountLimits); got != wantCSV {
+		t.Errorf("CSV:\n%s\nwant:\n%s", got, wantCSV)
+	}
+
+	prom := renderAccountLimitsProm(accountLimits)
+	for _, want := range []string{
+		"# TYPE proxy_account_status gauge\n",
+		`proxy_account_status{email="ag@example.com",provider="antigravity",status="ok"} 1`,
+		`proxy_account_status{email="bad@example.com",provider="zai",status="error"} 1`,
+		`proxy_account_quota_remaining_fraction{email="ag@example.com",provider="antigravity",model="antigravity/gemini-3-flash"} 0.5`,
+		`proxy_account_quota_remaining_fraction{email="cp@example.com",provider="copilot",model="copilot/chat"} 1`,
+		`proxy_account_quota_reset_timestamp_seconds{email="zai@example.com",provider="zai",model="zai/glm-4.7"} 1735689600`,
+	} {
+		if !strings.Contains(prom, want) {
+			t.Errorf("Prometheus output is missing %q:\n%s", want, prom)
+		}
+	}
+	if strings.Count(prom, "proxy_account_status{") != 4 {
+		t.Errorf("expected one status line per account:\n%s", prom)
+	}
+	if strings.Contains(prom, `remaining_fraction{email="ag@example.com",provider="antigravity",model="antigravity/claude-sonnet-4-5"}`) {
+		t.Errorf("a quota without a remaining fraction must not be exported:\n%s", prom)
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/cache"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestToNodeMessageResponse_ExtendedBlocks(t *testing.T) {
+	var resp types.AnthropicResponse
+	err := json.Unmarshal([]byte(`{
+		"id": "msg_1", "type": "message", "role": "assistant", "model": "claude",
+		"content": [
+			{"type": "mcp_tool_use", "id": "m1", "name": "lookup", "server_name": "docs", "input": {"q": "x"}},
+			{"type": "text", "text": "done"}
+		]
+	}`), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, _ := json.Marshal(toNodeMessageResponse(&resp))
+	var got struct {
+		Content []map[string]interface{} `json:"content"`
+	}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Content) != 2 {
+		t.Fatalf("expected 2 blocks, got %d", len(got.Content))
+	}
+	mcp := got.Content[0]
+	if mcp["type"] != "mcp_tool_use" || mcp["server_name"] != "docs" || mcp["input"].(map[string]interface{})["q"] != "x" {
+		t.Errorf("mcp_tool_use block not passed through: %v", mcp)
+	}
+}
+
+func TestHandleMessages_ResponseCachePerAPIKey(t *testing.T) {
+	registry := provider.NewRegistry()
+	prov := &gatedProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, release: make(chan struct{})}
+	close(prov.release)
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+	s.SetResponseCache(cache.NewResponseCache(time.Minute, 10))
+
+	send := func(virtualKey string) string {
+		r := httptest.NewRequest(http.MethodPost, "/v1/messages",
+			strings.NewReader(`{"model":"zai/glm-4.7","max_tokens":16,"messages":[{"role":"user","content":"ping"}]}`))
+		if virtualKey != "" {
+			r = r.WithContext(context.WithValue(r.Context(), virtualKeyKey{}, virtualKey))
+		}
+		w := httptest.NewRecorder()
+		s.handleMessages(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w.Header().Get("X-Proxy-Cache")
+	}
+	for _, step := range []struct{ key, want string }{
+		{"team-a", "MISS"},
+		{"team-a", "HIT"},
+		{"team-b", "MISS"},
+		{"", "MISS"},
+		{"team-b", "HIT"},
+	} {
+		if got := send(step.key); got != step.want {
+			t.Errorf("key %q: X-Proxy-Cache = %q, want %q", step.key, got, step.want)
+		}
+	}
+	if n := prov.calls.Load(); n != 3 {
+		t.Errorf("expected one upstream call per API key, got %d", n)
+	}
+}
+
+func TestHandleMessages_ResponseCacheHitUsage(t *testing.T) {
+	registry := provider.NewRegistry()
+	prov := &gatedProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, release: make(chan struct{})}
+	close(prov.release)
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+	s.SetResponseCache(cache.NewResponseCache(time.Minute, 10))
+
+	send := func(user string) (string, *requestStats) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/messages",
+			strings.NewReader(`{"model":"zai/glm-4.7","max_tokens":16,"metadata":{"user_id":"`+user+`"},"messages":[{"role":"user","content":"ping"}]}`))
+		stats := &requestStats{}
+		r = r.WithContext(withRequestStats(r.Context(), stats))
+		w := httptest.NewRecorder()
+		s.handleMessages(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w.Header().Get("X-Proxy-Cache"), stats
+	}
+
+	send("alice")
+	cached, stats := send("alice")
+	if cached != "HIT" {
+		t.Fatalf("X-Proxy-Cache = %q, want HIT", cached)
+	}
+	want := requestStats{Provider: "zai", Model: "glm-4.7", User: "alice", InputTokens: 3, OutputTokens: 1, Cached: true}
+	if stats.Provider != want.Provider || stats.Model != want.Model || stats.User != want.User ||
+		stats.InputTokens != want.InputTokens || stats.OutputTokens != want.OutputTokens || !stats.Cached || stats.Account != "" {
+		t.Errorf("cache hit stats = %+v, want %+v", *stats, want)
+	}
+
+	// Users sharing an API key don't share responses.
+	if cached, _ := send("bob"); cached != "MISS" {
+		t.Errorf("another user: X-Proxy-Cache = %q, want MISS", cached)
+	}
+}
+
+func TestRecordCachedUsage_Stream(t *testing.T) {
+	stats := &requestStats{}
+	recordCachedUsage(withRequestStats(context.Background(), stats), cache.Entry{Events: []cache.Event{
+		{Type: "message_start", Data: []byte(`{"type":"message_start","message":{"usage":{"input_tokens":5,"cache_read_input_tokens":2,"output_tokens":1}}}`)},
+		{Type: "content_block_delta", Data: []byte(`{"type":"content_block_delta","delta":{"type":"text_delta","text":"pong"}}`)},
+		{Type: "message_delta", Data: []byte(`{"type":"message_delta","usage":{"output_tokens":4}}`)},
+	}})
+	if stats.InputTokens != 7 || stats.OutputTokens != 4 {
+		t.Errorf("tokens = %d in, %d out; want 7 in, 4 out", stats.InputTokens, stats.OutputTokens)
+	}
+}
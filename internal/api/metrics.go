@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// requestStats carries per-request results from the handlers back to the audit and
+// recent-request middlewares.
+type requestStats struct {
+	Provider        string
+	Model           string
+	User            string // metadata.user_id, see accountingUser
+	Account         string // Account that served the request, when the provider reports it
+	InputTokens     int    // Including cache reads and writes
+	OutputTokens    int
+	Cost            float64 // Estimated, in US dollars (MODEL_PRICING_CONFIG)
+	TokensPerSecond float64
+	FirstEventMs    int64    // Streaming only
+	Annotations     []string // Added by the content policy filters
+	Coalesced       bool     // Served by an identical request's upstream call
+	Cached          bool     // Served from the response cache, without an upstream call
+}
+
+type requestStatsKey struct{}
+
+func withRequestStats(ctx context.Context, stats *requestStats) context.Context {
+	return context.WithValue(ctx, requestStatsKey{}, stats)
+}
+
+func requestStatsFromContext(ctx context.Context) *requestStats {
+	stats, _ := ctx.Value(requestStatsKey{}).(*requestStats)
+	return stats
+}
+
+// recordThroughput records output tokens per second for a completed response in the
+// throughput histogram and, when the request is audited, in its audit record.
+func recordThroughput(ctx context.Context, providerName, model string, outputTokens int, elapsed time.Duration) {
+	if outputTokens <= 0 || elapsed <= 0 {
+		return
+	}
+	tps := float64(outputTokens) / elapsed.Seconds()
+	metrics.OutputTokensPerSecond.ObserveAccount(providerName, model, provider.TraceFromContext(ctx).Account(), tps)
+
+	if stats := requestStatsFromContext(ctx); stats != nil {
+		stats.OutputTokens = outputTokens
+		stats.TokensPerSecond = tps
+	}
+	utils.Debug("[Messages] %s/%s: %d output tokens in %s (%.1f tok/s)", providerName, model, outputTokens, formatDuration(elapsed), tps)
+}
+
+// recordFirstEvent records the time from sending a streaming request upstream to its first
+// event, by the account that served it.
+func recordFirstEvent(ctx context.Context, providerName, model string, elapsed time.Duration) {
+	metrics.TimeToFirstEvent.ObserveAccount(providerName, model, provider.TraceFromContext(ctx).Account(), elapsed.Seconds())
+	if stats := requestStatsFromContext(ctx); stats != nil {
+		stats.FirstEventMs = max(elapsed.Milliseconds(), 1)
+	}
+}
+
+// recordInputTokens records a response's input tokens, including cached ones, for the
+// audit record and per-user usage.
+func recordInputTokens(ctx context.Context, usage types.Usage) {
+	if stats := requestStatsFromContext(ctx); stats != nil {
+		stats.InputTokens = usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
+	}
+}
+
+// streamInputUsage extracts message.usage from a serialized message_start event.
+func streamInputUsage(data []byte) (types.Usage, bool) {
+	var start struct {
+		Message *struct {
+			Usage *types.Usage `json:"usage"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(data, &start); err != nil || start.Message == nil || start.Message.Usage == nil {
+		return types.Usage{}, false
+	}
+	return *start.Message.Usage, true
+}
+
+// streamOutputTokens extracts usage.output_tokens from a serialized message_delta event.
+// Upstreams report the cumulative count, so the last message_delta wins.
+func streamOutputTokens(data []byte) (int, bool) {
+	var delta struct {
+		Usage *struct {
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &delta); err != nil || delta.Usage == nil {
+		return 0, false
+	}
+	return delta.Usage.OutputTokens, true
+}
+
+// streamDeltaInputUsage extracts the input token counts of a serialized message_delta event.
+// ok is false when it reports none; upstreams such as Z.AI count input tokens only there.
+func streamDeltaInputUsage(data []byte) (types.Usage, bool) {
+	var delta struct {
+		Usage types.Usage `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &delta); err != nil {
+		return types.Usage{}, false
+	}
+	u := delta.Usage
+	return u, u.InputTokens+u.CacheReadInputTokens+u.CacheCreationInputTokens > 0
+}
+
+// handleMetrics handles GET /metrics in the Prometheus text exposition format.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.handleNotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := metrics.WriteAll(w); err != nil {
+		utils.Warn("[Metrics] Failed to write metrics: %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestStreamOutputTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		tokens int
+		ok     bool
+	}{
+		{"struct usage", `{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":42}}`, 42, true},
+		{"node shape", `{"type":"message_delta","usage":{"output_tokens":7,"cache_read_input_tokens":0}}`, 7, true},
+		{"no usage", `{"type":"message_delta","delta":{"stop_reason":"end_turn"}}`, 0, false},
+		{"invalid", `{`, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, ok := streamOutputTokens([]byte(tt.data))
+			if tokens != tt.tokens || ok != tt.ok {
+				t.Errorf("got (%d, %v), want (%d, %v)", tokens, ok, tt.tokens, tt.ok)
+			}
+		})
+	}
+}
+
+func TestStreamDeltaInputUsage(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		input int
+		ok    bool
+	}{
+		{"input tokens", `{"type":"message_delta","usage":{"input_tokens":120,"output_tokens":42}}`, 120, true},
+		{"cache reads only", `{"type":"message_delta","usage":{"output_tokens":42,"cache_read_input_tokens":80}}`, 0, true},
+		{"output tokens only", `{"type":"message_delta","usage":{"output_tokens":42}}`, 0, false},
+		{"invalid", `{`, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, ok := streamDeltaInputUsage([]byte(tt.data))
+			if usage.InputTokens != tt.input || ok != tt.ok {
+				t.Errorf("got (%d, %v), want (%d, %v)", usage.InputTokens, ok, tt.input, tt.ok)
+			}
+		})
+	}
+}
+
+func TestHandleStreamingMessage_RecordsThroughput(t *testing.T) {
+	metrics.OutputTokensPerSecond.Reset()
+	metrics.TimeToFirstEvent.Reset()
+	defer metrics.OutputTokensPerSecond.Reset()
+	defer metrics.TimeToFirstEvent.Reset()
+
+	registry := provider.NewRegistry()
+	prov := &mockProvider{
+		name:    "zai",
+		models:  []string{"glm-4.7"},
+		account: "a@example.com",
+		streamEvents: []types.StreamEvent{
+			{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant"}},
+			{Type: "content_block_delta", Delta: &types.Delta{Type: "text_delta", Text: "hi"}},
+			{Type: "message_delta", Delta: &types.Delta{StopReason: "end_turn"}, Usage: &types.Usage{OutputTokens: 120}},
+			{Type: "message_stop"},
+		},
+	}
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+
+	stats := &requestStats{}
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages",
+		strings.NewReader(`{"model":"zai/glm-4.7","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	req = req.WithContext(withRequestStats(context.Background(), stats))
+	w := httptest.NewRecorder()
+	s.handleMessages(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if stats.OutputTokens != 120 || stats.TokensPerSecond <= 0 || stats.FirstEventMs <= 0 || stats.Account != "a@example.com" {
+		t.Errorf("unexpected request stats: %+v", stats)
+	}
+	if n := metrics.OutputTokensPerSecond.Count("zai", "glm-4.7"); n != 1 {
+		t.Errorf("expected 1 observation, got %d", n)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`proxy_output_tokens_per_second_count{provider="zai",model="glm-4.7",account="a@example.com"} 1`,
+		`proxy_time_to_first_event_seconds_count{provider="zai",model="glm-4.7",account="a@example.com"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("%s missing from /metrics output:\n%s", want, body)
+		}
+	}
+}
+
+func TestHandleMessages_UpstreamRequestLimit(t *testing.T) {
+	metrics.RequestBytes.Reset()
+	metrics.ResponseBytes.Reset()
+	metrics.RequestsTooLarge.Reset()
+	defer metrics.RequestBytes.Reset()
+	defer metrics.ResponseBytes.Reset()
+	defer metrics.RequestsTooLarge.Reset()
+	t.Setenv("ZAI_MAX_UPSTREAM_REQUEST_KB", "1")
+
+	registry := provider.NewRegistry()
+	prov := &mockProvider{
+		name:   "zai",
+		models: []string{"glm-4.7"},
+		streamEvents: []types.StreamEvent{
+			{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant"}},
+			{Type: "message_stop"},
+		},
+	}
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+
+	send := func(content string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages",
+			strings.NewReader(`{"model":"zai/glm-4.7","stream":true,"messages":[{"role":"user","content":"`+content+`"}]}`))
+		w := httptest.NewRecorder()
+		s.handleMessages(w, req)
+		return w
+	}
+
+	w := send(strings.Repeat("x", 2048))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "request_too_large") || !strings.Contains(w.Body.String(), "1 KB limit for provider zai") {
+		t.Errorf("unexpected error body: %s", w.Body.String())
+	}
+	if n := metrics.RequestsTooLarge.Count("zai", "glm-4.7"); n != 1 {
+		t.Errorf("expected 1 rejected request, got %d", n)
+	}
+	if n := metrics.RequestBytes.Count("zai", "glm-4.7"); n != 0 {
+		t.Errorf("expected rejected request not to be observed, got %d", n)
+	}
+
+	w = send("hi")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if n := metrics.RequestBytes.Count("zai", "glm-4.7"); n != 1 {
+		t.Errorf("expected 1 request size observation, got %d", n)
+	}
+	if n := metrics.ResponseBytes.Count("zai", "glm-4.7"); n != 1 {
+		t.Errorf("expected 1 response size observation, got %d", n)
+	}
+}
+
+func TestHandleMessages_RequestGuards(t *testing.T) {
+	metrics.RequestGuardRejections.Reset()
+	defer metrics.RequestGuardRejections.Reset()
+	t.Setenv("MAX_REQUEST_MESSAGES", "*=2")
+	t.Setenv("MAX_REQUEST_TEXT_CHARS", "*=1000,zai/glm-4.7=10")
+	t.Setenv("MAX_REQUEST_IMAGES", "glm-4.7=1")
+
+	registry := provider.NewRegistry()
+	prov := &mockProvider{
+		name:   "zai",
+		models: []string{"glm-4.7"},
+		streamEvents: []types.StreamEvent{
+			{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant"}},
+			{Type: "message_stop"},
+		},
+	}
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+
+	send := func(messages string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages",
+			strings.NewReader(`{"model":"zai/glm-4.7","stream":true,"messages":`+messages+`}`))
+		w := httptest.NewRecorder()
+		s.handleMessages(w, req)
+		return w
+	}
+	image := `{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AA=="}}`
+
+	for name, tc := range map[string]struct {
+		messages string
+		want     string
+	}{
+		"messages": {`[{"role":"user","content":"a"},{"role":"assistant","content":"b"},{"role":"user","content":"c"}]`, "3 messages, over the limit of 2"},
+		"text":     {`[{"role":"user","content":"` + strings.Repeat("x", 11) + `"}]`, "11 characters of text, over the limit of 10"},
+		"images": {`[{"role":"user","content":[` + image + `,{"type":"tool_result","tool_use_id":"t","content":[` + image + `]}]}]`,
+			"2 images, over the limit of 1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := send(tc.messages)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tc.want) || !strings.Contains(w.Body.String(), "zai/glm-4.7") {
+				t.Errorf("expected a 400 mentioning %q, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+	if n := metrics.RequestGuardRejections.Count("zai", "glm-4.7"); n != 3 {
+		t.Errorf("expected 3 rejected requests, got %d", n)
+	}
+
+	if w := send(`[{"role":"user","content":[{"type":"text","text":"hi"},` + image + `]}]`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 within the limits, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -2,9 +2,11 @@ package api
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/tracing"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 )
 
@@ -51,7 +53,7 @@ func Logger(next http.Handler) http.Handler {
 		duration := time.Since(start)
 
 		// Skip logging for health checks in non-debug mode
-		if r.URL.Path == "/health" && !utils.IsDebugEnabled() {
+		if isHealthPath(r.URL.Path) && !utils.IsDebugEnabled() {
 			return
 		}
 
@@ -64,6 +66,34 @@ func Logger(next http.Handler) http.Handler {
 	})
 }
 
+// Tracing starts a server span for every /v1 request, continuing the caller's trace when
+// a W3C traceparent header is present. It does nothing unless a tracer is installed.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+r.URL.Path, tracing.KindServer,
+			tracing.String("http.request.method", r.Method),
+			tracing.String("url.path", r.URL.Path),
+		)
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		span.SetAttributes(tracing.Int("http.response.status_code", rw.statusCode))
+		if rw.statusCode >= 500 {
+			span.Fail(http.StatusText(rw.statusCode))
+		}
+	})
+}
+
 // Recovery recovers from panics and returns a 500 error.
 func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
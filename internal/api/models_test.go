@@ -5,18 +5,41 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
 	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
 
+// newTestManager returns an account manager with accounts, saving into a temporary directory.
+func newTestManager(t *testing.T, accounts ...account.Account) *account.Manager {
+	t.Helper()
+	mgr := account.NewManager(filepath.Join(t.TempDir(), "accounts.json"))
+	// Runs before t.TempDir is removed.
+	t.Cleanup(mgr.Flush)
+	for _, acc := range accounts {
+		if err := mgr.AddAccount(acc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mgr
+}
+
+// testAccount returns a manual account of provider with an API key.
+func testAccount(email, provider string) account.Account {
+	return account.Account{Email: email, Source: "manual", Provider: provider, APIKey: "key-" + email}
+}
+
 // mockProvider implements provider.Provider for testing.
 type mockProvider struct {
 	name           string
 	models         []string
 	modelsResponse *types.ModelsResponse
 	modelsError    error
+	streamEvents   []types.StreamEvent
+	account        string // Reported to the request's trace, when set
 }
 
 func (m *mockProvider) Name() string { return m.name }
@@ -37,7 +60,15 @@ func (m *mockProvider) SendMessage(ctx context.Context, req *types.AnthropicRequ
 }
 
 func (m *mockProvider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
-	return nil, nil
+	if m.account != "" {
+		provider.TraceFromContext(ctx).Attempt(m.account)
+	}
+	ch := make(chan types.StreamEvent, len(m.streamEvents))
+	for _, event := range m.streamEvents {
+		ch <- event
+	}
+	close(ch)
+	return ch, nil
 }
 
 func (m *mockProvider) ListModels(ctx context.Context) (*types.ModelsResponse, error) {
@@ -731,3 +762,34 @@ func TestHandleModels_ContentType(t *testing.T) {
 		}
 	})
 }
+
+func TestResolveProviderForModel_Aliases(t *testing.T) {
+	registry := provider.NewRegistry()
+	registry.Register(&mockProvider{name: "antigravity", models: []string{"gemini-3-pro"}})
+	registry.Register(&mockProvider{name: "copilot", models: []string{"claude-opus-4-1"}})
+
+	s := NewServer(registry, nil)
+	s.SetModelAliases(map[string]string{
+		"smart": "copilot/claude-opus-4-1",
+		"cheap": "gemini-3-pro",
+	})
+
+	tests := []struct {
+		model        string
+		wantProvider string
+		wantModel    string
+	}{
+		{"smart", "copilot", "claude-opus-4-1"},
+		{"cheap", "antigravity", "gemini-3-pro"},
+		{"claude-opus-4-1", "copilot", "claude-opus-4-1"},
+	}
+	for _, tt := range tests {
+		prov, rawModel, err := s.resolveProviderForModel(tt.model)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.model, err)
+		}
+		if prov.Name() != tt.wantProvider || rawModel != tt.wantModel {
+			t.Errorf("%s resolved to %s/%s, want %s/%s", tt.model, prov.Name(), rawModel, tt.wantProvider, tt.wantModel)
+		}
+	}
+}
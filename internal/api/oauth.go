@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/auth"
+)
+
+// oauthFlowTTL is how long an authorization URL from /auth/antigravity/start stays usable.
+const oauthFlowTTL = 10 * time.Minute
+
+// completeOAuthFlow exchanges an authorization code; replaced in tests.
+var completeOAuthFlow = auth.CompleteOAuthFlow
+
+// oauthFlow is a pending re-authentication started via /auth/antigravity/start.
+type oauthFlow struct {
+	verifier  string
+	email     string // Account the flow must re-authenticate; empty accepts any existing account
+	expiresAt time.Time
+}
+
+// oauthFlows holds pending flows keyed by OAuth state.
+type oauthFlows struct {
+	mu    sync.Mutex
+	flows map[string]oauthFlow
+}
+
+func (f *oauthFlows) add(state string, flow oauthFlow) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.flows == nil {
+		f.flows = make(map[string]oauthFlow)
+	}
+	now := time.Now()
+	for s, pending := range f.flows {
+		if now.After(pending.expiresAt) {
+			delete(f.flows, s)
+		}
+	}
+	f.flows[state] = flow
+}
+
+// take removes and returns the flow for state, if it exists and has not expired.
+func (f *oauthFlows) take(state string) (oauthFlow, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	flow, ok := f.flows[state]
+	delete(f.flows, state)
+	if !ok || time.Now().After(flow.expiresAt) {
+		return oauthFlow{}, false
+	}
+	return flow, true
+}
+
+// handleAntigravityAuthStart handles GET /auth/antigravity/start[?email=...].
+// It returns the Google authorization URL and the state to send back to the callback.
+func (s *Server) handleAntigravityAuthStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	email := strings.TrimSpace(r.URL.Query().Get("email"))
+	if email != "" && s.accountManager != nil {
+		if _, ok := s.findOAuthAccount(email); !ok {
+			writeAdminError(w, http.StatusNotFound, "OAuth account "+email+" not found")
+			return
+		}
+	}
+
+	authURL, pkce, err := auth.GetAuthorizationURL()
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	expiresAt := time.Now().Add(oauthFlowTTL)
+	s.oauth.add(pkce.State, oauthFlow{verifier: pkce.Verifier, email: email, expiresAt: expiresAt})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "ok",
+		"authUrl":   authURL,
+		"state":     pkce.State,
+		"expiresAt": expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// handleAntigravityAuthCallback handles POST /auth/antigravity/callback.
+// The body is {"code": "...", "state": "..."}; code may also be the full redirect URL,
+// in which case the state is taken from it. The matching account's refresh token is
+// replaced in place.
+func (s *Server) handleAntigravityAuthCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.handleNotFound(w, r)
+		return
+	}
+	if s.accountManager == nil {
+		writeAdminError(w, http.StatusInternalServerError, "No account manager configured")
+		return
+	}
+
+	var req struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+		return
+	}
+	code, state, err := auth.ExtractCodeFromInput(req.Code)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if state == "" {
+		state = req.State
+	}
+	flow, ok := s.oauth.take(state)
+	if !ok {
+		writeAdminError(w, http.StatusBadRequest, "Unknown or expired state; call /auth/antigravity/start again")
+		return
+	}
+
+	result, err := completeOAuthFlow(code, flow.verifier)
+	if err != nil {
+		writeAdminError(w, http.StatusBadGateway, "OAuth flow failed: "+err.Error())
+		return
+	}
+	if flow.email != "" && !strings.EqualFold(result.Email, flow.email) {
+		writeAdminError(w, http.StatusConflict, "Authorized as "+result.Email+", expected "+flow.email)
+		return
+	}
+	email, ok := s.findOAuthAccount(result.Email)
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, "No antigravity OAuth account for "+result.Email+"; add it with 'multi-claude-proxy accounts add'")
+		return
+	}
+	if err := s.accountManager.UpdateOAuthCredentials(email, result.RefreshToken, result.ProjectID); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok",
+		"account": map[string]string{
+			"email":     email,
+			"projectId": result.ProjectID,
+		},
+	})
+}
+
+// findOAuthAccount returns the stored email of the antigravity OAuth account matching email.
+func (s *Server) findOAuthAccount(email string) (string, bool) {
+	for _, acc := range s.accountManager.GetAllAccountsByProvider("antigravity") {
+		if acc.Source == "oauth" && strings.EqualFold(acc.Email, email) {
+			return acc.Email, true
+		}
+	}
+	return "", false
+}
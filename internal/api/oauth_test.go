@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/auth"
+)
+
+func TestAntigravityReauthFlow(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	dir, err := os.MkdirTemp("", "mcp-oauth-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	mgr := account.NewManager(filepath.Join(dir, "accounts.json"))
+	if err := mgr.AddAccount(account.Account{Email: "a@example.com", Provider: "antigravity", Source: "oauth", RefreshToken: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	mgr.MarkInvalid("a@example.com", "invalid_grant")
+	handler := NewServer(nil, mgr).Handler()
+
+	var gotVerifier string
+	completeOAuthFlow = func(code, verifier string) (*auth.AuthorizationResult, error) {
+		if code != "4/0-test-code" {
+			return nil, errors.New("bad code")
+		}
+		gotVerifier = verifier
+		return &auth.AuthorizationResult{Email: "A@example.com", RefreshToken: "new", ProjectID: "proj-1"}, nil
+	}
+	t.Cleanup(func() { completeOAuthFlow = auth.CompleteOAuthFlow })
+
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("x-api-key", "test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var out map[string]interface{}
+		_ = json.NewDecoder(rec.Body).Decode(&out)
+		return rec.Code, out
+	}
+
+	if code, body := do(http.MethodGet, "/auth/antigravity/start?email=missing@example.com", ""); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown account, got %d: %v", code, body)
+	}
+
+	code, start := do(http.MethodGet, "/auth/antigravity/start?email=a@example.com", "")
+	if code != http.StatusOK {
+		t.Fatalf("start: got %d: %v", code, start)
+	}
+	authURL, _ := url.Parse(start["authUrl"].(string))
+	state := start["state"].(string)
+	if authURL.Query().Get("state") != state {
+		t.Fatalf("state %q not in auth URL %s", state, authURL)
+	}
+
+	if code, body := do(http.MethodPost, "/auth/antigravity/callback", `{"code":"4/0-test-code","state":"bogus"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown state, got %d: %v", code, body)
+	}
+
+	callback := "http://localhost:51121/oauth-callback?code=4/0-test-code&state=" + state
+	code, body := do(http.MethodPost, "/auth/antigravity/callback", `{"code":"`+callback+`"}`)
+	if code != http.StatusOK {
+		t.Fatalf("callback: got %d: %v", code, body)
+	}
+	if gotVerifier == "" {
+		t.Error("expected PKCE verifier to be passed to the token exchange")
+	}
+
+	accounts := mgr.GetAllAccounts()
+	if len(accounts) != 1 || accounts[0].RefreshToken != "new" || accounts[0].ProjectID != "proj-1" || accounts[0].IsInvalid {
+		t.Errorf("account not updated in place: %+v", accounts)
+	}
+
+	// A state can only be used once.
+	if code, _ := do(http.MethodPost, "/auth/antigravity/callback", `{"code":"`+callback+`"}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 when reusing state, got %d", code)
+	}
+}
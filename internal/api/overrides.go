@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+)
+
+// Request headers overriding the routing of a /v1/messages request, for debugging.
+const (
+	headerProxyProvider   = "X-Proxy-Provider"
+	headerProxyAccount    = "X-Proxy-Account"
+	headerProxyNoFallback = "X-Proxy-No-Fallback"
+)
+
+// routingOverrides force a request onto a provider or account without changing its model
+// string, so a misbehaving upstream can be reproduced.
+type routingOverrides struct {
+	provider   provider.Provider // Replaces the model's provider; nil if not overridden
+	account    string            // Pinned account
+	noFallback bool              // No other account, fallback model or stream recovery
+}
+
+// set reports whether any override was requested. Such requests bypass the response cache
+// and coalescing, since they are meant to reach the upstream.
+func (o routingOverrides) set() bool {
+	return o.provider != nil || o.account != "" || o.noFallback
+}
+
+// context returns ctx restricting account selection and fallbacks as o asks.
+func (o routingOverrides) context(ctx context.Context) context.Context {
+	if o.account != "" {
+		ctx = account.WithAccount(ctx, o.account)
+	}
+	if o.noFallback {
+		ctx = provider.WithoutFallback(ctx)
+		if o.account == "" {
+			ctx = account.WithoutFailover(ctx)
+		}
+	}
+	return ctx
+}
+
+// applyRoutingOverrides reads the x-proxy-* headers of r and returns the provider and
+// upstream model to use. prov, rawModel and resolveErr are the resolution of model, which a
+// provider override replaces. Overrides that name an unknown provider or account, or a
+// provider that doesn't serve the model, are errors.
+func (s *Server) applyRoutingOverrides(r *http.Request, model string, prov provider.Provider, rawModel string, resolveErr error) (provider.Provider, string, routingOverrides, error) {
+	var o routingOverrides
+	if raw := strings.TrimSpace(r.Header.Get(headerProxyNoFallback)); raw != "" {
+		noFallback, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, "", o, fmt.Errorf("Invalid %s header %q: expected true or false", headerProxyNoFallback, raw)
+		}
+		o.noFallback = noFallback
+	}
+
+	if name := strings.TrimSpace(r.Header.Get(headerProxyProvider)); name != "" {
+		var override provider.Provider
+		if s.registry != nil {
+			override, _ = s.registry.GetByName(name)
+		}
+		if override == nil {
+			return nil, "", o, fmt.Errorf("Unknown provider in %s header: %s", headerProxyProvider, name)
+		}
+		if resolveErr != nil {
+			rawModel = model
+			if _, m, ok := splitModelID(model); ok {
+				rawModel = m
+			}
+		}
+		if !override.SupportsModel(rawModel) {
+			return nil, "", o, fmt.Errorf("Provider %s (from the %s header) does not serve model %s", name, headerProxyProvider, rawModel)
+		}
+		o.provider, prov, resolveErr = override, override, nil
+	}
+	if resolveErr != nil {
+		return nil, "", o, resolveErr
+	}
+
+	if email := strings.TrimSpace(r.Header.Get(headerProxyAccount)); email != "" {
+		if !s.hasAccount(prov.Name(), email) {
+			return nil, "", o, fmt.Errorf("No %s account %s (from the %s header)", prov.Name(), email, headerProxyAccount)
+		}
+		o.account = email
+	}
+	return prov, rawModel, o, nil
+}
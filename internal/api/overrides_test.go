@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// pickingProvider picks an account twice, as a provider retrying a failed attempt would,
+// and records what it was routed to.
+type pickingProvider struct {
+	mockProvider
+	accounts *account.Manager
+
+	called   bool
+	picked   []string
+	fallback bool
+}
+
+func (p *pickingProvider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	p.called = true
+	p.fallback = provider.FallbackAllowed(ctx)
+	for range 2 {
+		if acc := p.accounts.PickNextByProviderContext(ctx, p.name, req.Model); acc != nil {
+			p.picked = append(p.picked, acc.Email)
+			p.accounts.MarkRateLimited(acc.Email, 60000, req.Model)
+		}
+	}
+	return &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: req.Model, StopReason: "end_turn",
+		Content: []types.ContentBlock{{Type: "text", Text: "pong"}}}, nil
+}
+
+func TestHandleMessages_RoutingOverrides(t *testing.T) {
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		wantStatus   int
+		wantError    string
+		wantProvider string
+		wantPicked   []string // nil: any
+		wantSticky   bool     // Both picks return the same account
+		wantFallback bool
+	}{
+		{
+			name:         "no overrides",
+			wantStatus:   http.StatusOK,
+			wantProvider: "zai",
+			wantFallback: true,
+		},
+		{
+			name:         "provider",
+			headers:      map[string]string{"x-proxy-provider": "copilot"},
+			wantStatus:   http.StatusOK,
+			wantProvider: "copilot",
+			wantFallback: true,
+		},
+		{
+			name:         "account",
+			headers:      map[string]string{"x-proxy-account": "b@example.com"},
+			wantStatus:   http.StatusOK,
+			wantProvider: "zai",
+			wantPicked:   []string{"b@example.com", "b@example.com"},
+			wantFallback: true,
+		},
+		{
+			name:         "no fallback",
+			headers:      map[string]string{"x-proxy-no-fallback": "true"},
+			wantStatus:   http.StatusOK,
+			wantProvider: "zai",
+			wantSticky:   true,
+		},
+		{
+			name:       "unknown provider",
+			headers:    map[string]string{"x-proxy-provider": "nope"},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "Unknown provider",
+		},
+		{
+			name:       "provider without the model",
+			headers:    map[string]string{"x-proxy-provider": "vertex"},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "does not serve model glm-4.7",
+		},
+		{
+			name:       "account of another provider",
+			headers:    map[string]string{"x-proxy-provider": "copilot", "x-proxy-account": "a@example.com"},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "No copilot account a@example.com",
+		},
+		{
+			name:       "invalid no fallback",
+			headers:    map[string]string{"x-proxy-no-fallback": "maybe"},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "X-Proxy-No-Fallback",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := newTestManager(t, testAccount("a@example.com", "zai"), testAccount("b@example.com", "zai"))
+			registry := provider.NewRegistry()
+			providers := map[string]*pickingProvider{}
+			for name, models := range map[string][]string{"zai": {"glm-4.7"}, "copilot": {"glm-4.7"}, "vertex": {"claude-sonnet-4-5"}} {
+				providers[name] = &pickingProvider{mockProvider: mockProvider{name: name, models: models}, accounts: mgr}
+				if err := registry.Register(providers[name]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := NewServer(registry, mgr)
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/messages",
+				strings.NewReader(`{"model":"zai/glm-4.7","max_tokens":16,"messages":[{"role":"user","content":"ping"}]}`))
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			s.handleMessages(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantError != "" {
+				if !strings.Contains(w.Body.String(), tt.wantError) || !strings.Contains(w.Body.String(), "invalid_request_error") {
+					t.Errorf("expected an invalid_request_error about %q, got %s", tt.wantError, w.Body.String())
+				}
+				return
+			}
+			for name, prov := range providers {
+				if prov.called != (name == tt.wantProvider) {
+					t.Errorf("provider %s called = %v, want it called only for %s", name, prov.called, tt.wantProvider)
+				}
+			}
+			prov := providers[tt.wantProvider]
+			if tt.wantPicked != nil && strings.Join(prov.picked, ",") != strings.Join(tt.wantPicked, ",") {
+				t.Errorf("picked %v, want %v", prov.picked, tt.wantPicked)
+			}
+			if tt.wantSticky && (len(prov.picked) != 2 || prov.picked[0] != prov.picked[1]) {
+				t.Errorf("expected the first account to be kept, picked %v", prov.picked)
+			}
+			if prov.fallback != tt.wantFallback {
+				t.Errorf("fallback allowed = %v, want %v", prov.fallback, tt.wantFallback)
+			}
+		})
+	}
+}
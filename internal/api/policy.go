@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"unicode/utf8"
+
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/policy"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// defaultPolicyReason is returned when a filter rejects without giving a reason.
+const defaultPolicyReason = "Content violates the proxy's usage policy"
+
+// SetPolicy sets the content policy filters run on /v1/messages requests and responses.
+// Pass nil to disable them.
+func (s *Server) SetPolicy(c *policy.Chain) {
+	s.policy = c
+}
+
+// SetRequestRules sets the declarative request rules evaluated on /v1/messages requests.
+// Pass nil to disable them.
+func (s *Server) SetRequestRules(r *policy.Rules) {
+	s.requestRules = r
+}
+
+// checkPolicy runs the policy filters and records their annotations for the audit log.
+// It writes the error response and returns ok=false when the content is refused.
+func (s *Server) checkPolicy(ctx context.Context, w http.ResponseWriter, in policy.Input) (policy.Decision, bool) {
+	d, err := s.policy.Check(ctx, in)
+	if stats := requestStatsFromContext(ctx); stats != nil {
+		stats.Annotations = append(stats.Annotations, d.Annotations...)
+	}
+	if err != nil {
+		utils.Error("[Policy] Filter failed on %s %s: %v", in.Provider, in.Stage, err)
+		writeError(w, http.StatusServiceUnavailable, string(merrors.ErrorTypeAPI), "Content policy filter is unavailable; retry shortly")
+		return d, false
+	}
+	if d.Reject {
+		metrics.PolicyRejections.Inc(in.Provider, in.Model)
+		utils.Warn("[Policy] Rejected %s %s for %s: %s", in.Provider, in.Stage, in.Model, d.Reason)
+		reason := d.Reason
+		if reason == "" {
+			reason = defaultPolicyReason
+		}
+		writeError(w, http.StatusForbidden, string(merrors.ErrorTypePermission), reason)
+		return d, false
+	}
+	return d, true
+}
+
+// applyRequestPolicy runs the request stage of the policy filters, redacting req in place,
+// and returns the filters' annotations. It returns false after writing the error response
+// when the request is refused.
+func (s *Server) applyRequestPolicy(ctx context.Context, w http.ResponseWriter, req *types.AnthropicRequest, providerName, user string) ([]string, bool) {
+	if s.policy == nil {
+		return nil, true
+	}
+	d, ok := s.checkPolicy(ctx, w, policy.Input{
+		Stage:    policy.StageRequest,
+		Provider: providerName,
+		Model:    req.Model,
+		User:     user,
+		Texts:    policy.RequestTexts(req),
+	})
+	if ok && d.Texts != nil {
+		policy.SetRequestTexts(req, d.Texts)
+	}
+	return d.Annotations, ok
+}
+
+// applyRequestRules evaluates the request rules (REQUEST_POLICY_CONFIG) and records the
+// rules that matched for the audit log. It writes the error response and returns ok=false
+// when a rule denies the request. The caller applies the decision's model and max_tokens.
+func (s *Server) applyRequestRules(r *http.Request, w http.ResponseWriter, req *types.AnthropicRequest, providerName, user string, flags []string) (policy.RuleDecision, bool) {
+	if s.requestRules == nil {
+		return policy.RuleDecision{}, true
+	}
+	chars := 0
+	for _, text := range policy.RequestTexts(req) {
+		chars += utf8.RuneCountInString(text)
+	}
+	d := s.requestRules.Evaluate(policy.RuleInput{
+		APIKey:          apiKeyFingerprint(r),
+		Model:           req.Model,
+		Provider:        providerName,
+		User:            user,
+		EstimatedTokens: chars / 4,
+		MaxTokens:       req.MaxTokens,
+		Stream:          req.Stream,
+		Messages:        len(req.Messages),
+		Images:          countImages(req),
+		Tools:           len(req.Tools),
+		Flags:           flags,
+	})
+	if stats := requestStatsFromContext(r.Context()); stats != nil {
+		for _, name := range d.Matched {
+			stats.Annotations = append(stats.Annotations, "rule:"+name)
+		}
+	}
+	if d.Deny {
+		metrics.PolicyRejections.Inc(providerName, req.Model)
+		utils.Warn("[Policy] Request rule %q denied %s request for %s", d.Rule, providerName, req.Model)
+		writeError(w, http.StatusForbidden, string(merrors.ErrorTypePermission), d.Reason)
+		return d, false
+	}
+	return d, true
+}
+
+// apiKeyFingerprint identifies the client's proxy API key to request rules without
+// exposing it: the first 16 hex digits of its SHA-256. Empty when the request has no key.
+func apiKeyFingerprint(r *http.Request) string {
+	key, err := extractAPIKey(r)
+	if err != nil || key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// applyResponsePolicy runs the response stage of the policy filters on a complete
+// (non-streamed) response, redacting it in place. It returns false after writing the error
+// response when the response is refused.
+func (s *Server) applyResponsePolicy(ctx context.Context, w http.ResponseWriter, resp *types.AnthropicResponse, providerName, model, user string) bool {
+	if s.policy == nil {
+		return true
+	}
+	d, ok := s.checkPolicy(ctx, w, policy.Input{
+		Stage:    policy.StageResponse,
+		Provider: providerName,
+		Model:    model,
+		User:     user,
+		Texts:    policy.ResponseTexts(resp),
+	})
+	if ok && d.Texts != nil {
+		policy.SetResponseTexts(resp, d.Texts)
+	}
+	return ok
+}
+
+// checkStreamedPolicy runs the response stage on the text of a completed stream. The client
+// already has the text, so only annotations apply; a rejection is logged and annotated.
+func (s *Server) checkStreamedPolicy(ctx context.Context, providerName, model, user, text string) {
+	d, err := s.policy.Check(ctx, policy.Input{
+		Stage:    policy.StageResponse,
+		Provider: providerName,
+		Model:    model,
+		User:     user,
+		Texts:    []string{text},
+	})
+	if err != nil {
+		utils.Error("[Policy] Filter failed on %s streamed response: %v", providerName, err)
+		return
+	}
+	if d.Reject {
+		metrics.PolicyRejections.Inc(providerName, model)
+		utils.Warn("[Policy] Streamed %s response for %s violates the policy (already sent): %s", providerName, model, d.Reason)
+		d.Annotations = append(d.Annotations, "rejected-after-stream")
+	}
+	if stats := requestStatsFromContext(ctx); stats != nil {
+		stats.Annotations = append(stats.Annotations, d.Annotations...)
+	}
+}
+
+// streamTextDelta extracts the text of a serialized text_delta event.
+func streamTextDelta(data []byte) (string, bool) {
+	var event struct {
+		Delta *struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"delta"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil || event.Delta == nil || event.Delta.Type != "text_delta" {
+		return "", false
+	}
+	return event.Delta.Text, true
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/policy"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// echoProvider answers with the text it was sent, so tests can see what reached upstream.
+type echoProvider struct {
+	*mockProvider
+	calls int
+	last  *types.AnthropicRequest
+}
+
+func (p *echoProvider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	p.calls++
+	p.last = req
+	return &types.AnthropicResponse{
+		ID: "msg_1", Type: "message", Role: "assistant", Model: req.Model,
+		Content: []types.ContentBlock{{Type: "text", Text: "echo: " + strings.Join(policy.RequestTexts(req), " ")}},
+	}, nil
+}
+
+func TestMessages_Policy(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	registry := provider.NewRegistry()
+	prov := &echoProvider{mockProvider: &mockProvider{name: "zai", models: []string{"glm-4.7"}}}
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := policy.NewRegexFilter([]config.PolicyRule{
+		{Name: "key", Pattern: `sk-\w+`, Action: config.PolicyActionRedact, Replacement: "[KEY]"},
+		{Name: "secret", Pattern: `(?i)top secret`, Action: config.PolicyActionReject, Stage: "request", Reason: "No classified material"},
+		{Name: "host", Pattern: `internal\.corp`, Action: config.PolicyActionRedact, Stage: "response", Replacement: "[HOST]"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+	s.SetPolicy(policy.NewChain(false, rules))
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	post := func(text string) (int, string) {
+		body := `{"model":"zai/glm-4.7","messages":[{"role":"user","content":` + strings.TrimSpace(mustJSON(text)) + `}]}`
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/messages", strings.NewReader(body))
+		req.Header.Set("x-api-key", "test-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Content []types.ContentBlock `json:"content"`
+			Error   types.ErrorDetail    `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		if len(out.Content) > 0 {
+			return resp.StatusCode, out.Content[0].Text
+		}
+		return resp.StatusCode, out.Error.Message
+	}
+
+	status, msg := post("this is TOP SECRET")
+	if status != http.StatusForbidden || msg != "No classified material" || prov.calls != 0 {
+		t.Errorf("expected a 403 before dispatch, got %d %q (calls %d)", status, msg, prov.calls)
+	}
+
+	status, text := post("deploy with sk-abc123 to internal.corp")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d (%s)", status, text)
+	}
+	if want := "echo: deploy with [KEY] to [HOST]"; text != want {
+		t.Errorf("response text = %q, want %q", text, want)
+	}
+}
+
+func TestMessages_RequestRules(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	registry := provider.NewRegistry()
+	prov := &echoProvider{mockProvider: &mockProvider{name: "zai", models: []string{"glm-4.7", "glm-4.5"}}}
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	filter, err := policy.NewRegexFilter([]config.PolicyRule{
+		{Name: "secret", Pattern: `(?i)secret`, Action: config.PolicyActionAnnotate},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	req.Header.Set("x-api-key", "test-key")
+	rules, err := policy.NewRules([]config.RequestRule{
+		{Name: "flagged", When: `"secret" in flags && api_key == "` + apiKeyFingerprint(req) + `"`, Action: config.RequestRuleDeny, Reason: "No secrets"},
+		{Name: "big", When: `estimated_tokens > 100`, Action: config.RequestRuleDeny, Reason: "Too big"},
+		{Name: "downgrade", When: `model == "zai/glm-4.7" && max_tokens > 1000`, Action: config.RequestRuleTransform,
+			Set: config.RequestRuleChanges{Model: "zai/glm-4.5", MaxTokens: 1000}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+	s.SetPolicy(policy.NewChain(false, filter))
+	s.SetRequestRules(rules)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	post := func(text string, maxTokens int) (int, string) {
+		body := fmt.Sprintf(`{"model":"zai/glm-4.7","max_tokens":%d,"messages":[{"role":"user","content":%s}]}`, maxTokens, mustJSON(text))
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/messages", strings.NewReader(body))
+		req.Header.Set("x-api-key", "test-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Error types.ErrorDetail `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.Error.Message
+	}
+
+	if status, msg := post("a secret", 100); status != http.StatusForbidden || msg != "No secrets" {
+		t.Errorf("expected the flagged request denied, got %d %q", status, msg)
+	}
+	if status, msg := post(strings.Repeat("word ", 100), 100); status != http.StatusForbidden || msg != "Too big" {
+		t.Errorf("expected the large request denied, got %d %q", status, msg)
+	}
+	if prov.calls != 0 {
+		t.Fatalf("denied requests reached the provider %d times", prov.calls)
+	}
+
+	if status, msg := post("hello", 4096); status != http.StatusOK {
+		t.Fatalf("status = %d (%s)", status, msg)
+	}
+	if prov.last.Model != "glm-4.5" || prov.last.MaxTokens != 1000 {
+		t.Errorf("expected the request transformed to glm-4.5 with 1000 max tokens, got %s with %d", prov.last.Model, prov.last.MaxTokens)
+	}
+	if status, _ := post("hello", 500); status != http.StatusOK || prov.last.Model != "glm-4.7" || prov.last.MaxTokens != 500 {
+		t.Errorf("expected a small request unchanged, got %d %s with %d", status, prov.last.Model, prov.last.MaxTokens)
+	}
+}
+
+func mustJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestCheckStreamedPolicy(t *testing.T) {
+	rules, err := policy.NewRegexFilter([]config.PolicyRule{
+		{Name: "host", Pattern: `internal\.corp`, Action: config.PolicyActionReject, Stage: "response"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(provider.NewRegistry(), nil)
+	s.SetPolicy(policy.NewChain(false, rules))
+
+	var text strings.Builder
+	for _, data := range []string{
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"see internal"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"x"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":".corp"}}`,
+	} {
+		if delta, ok := streamTextDelta([]byte(data)); ok {
+			text.WriteString(delta)
+		}
+	}
+
+	stats := &requestStats{}
+	s.checkStreamedPolicy(withRequestStats(context.Background(), stats), "zai", "glm-4.7", "", text.String())
+	if want := []string{"host", "rejected-after-stream"}; !reflect.DeepEqual(stats.Annotations, want) {
+		t.Errorf("annotations = %v, want %v", stats.Annotations, want)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/quota"
+)
+
+// SetQuotaTracker enables quota history, exhaustion estimates and low-quota alerts for
+// quotas fetched by /health and /account-limits. Pass nil to disable it.
+func (s *Server) SetQuotaTracker(t *quota.Tracker) {
+	s.quotaTracker = t
+}
+
+// recordQuotas feeds fetched quotas into the quota tracker and annotates each entry with
+// burnRatePerHour, exhaustsAt and, when the quota recently moved out of line with the tokens
+// sent through the account, usageDiscrepancy. quotas maps model IDs (with or without the provider
+// prefix) to maps carrying a float64 "remainingFraction"; other entries are left alone.
+func (s *Server) recordQuotas(email, providerName string, quotas map[string]interface{}, now time.Time) {
+	if s.quotaTracker == nil {
+		return
+	}
+	for modelID, val := range quotas {
+		info, _ := val.(map[string]interface{})
+		rf, ok := info["remainingFraction"].(float64)
+		if !ok {
+			continue
+		}
+
+		key := modelID
+		if !hasProviderPrefix(key, providerName) {
+			key = providerName + "/" + modelID
+		}
+		s.quotaTracker.Record(email, key, rf, now)
+
+		prediction, ok := s.quotaTracker.Predict(email, key)
+		if !ok {
+			continue
+		}
+		info["burnRatePerHour"] = prediction.BurnRatePerHour
+		info["exhaustsAt"] = nil
+		if prediction.ExhaustsAt != nil {
+			info["exhaustsAt"] = formatISOTimeUTC(*prediction.ExhaustsAt)
+		}
+		if d, ok := s.quotaTracker.Discrepancy(email, key, now); ok {
+			info["usageDiscrepancy"] = d
+		}
+	}
+}
+
+// recordAccountUsage notes the account that served a finished request and its estimated
+// cost in its stats, and credits its tokens to the account, for reconciliation with the
+// account's quota. Only
+// Antigravity reports per-model quotas; the others are account-wide or counted in requests,
+// so tokens can't be matched against them.
+func (s *Server) recordAccountUsage(trace *provider.Trace, stats *requestStats) {
+	stats.Account = trace.Account()
+	stats.Cost = s.pricing.Cost(stats.Provider, stats.Model, stats.InputTokens, stats.OutputTokens)
+	// A coalesced request's tokens were credited by the request whose call served it.
+	if s.quotaTracker == nil || stats.Provider != "antigravity" || stats.Coalesced {
+		return
+	}
+	if email := trace.Account(); email != "" {
+		s.quotaTracker.RecordUsage(email, stats.Provider+"/"+stats.Model, stats.InputTokens+stats.OutputTokens)
+	}
+}
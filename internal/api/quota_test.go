@@ -0,0 +1,97 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/quota"
+)
+
+func TestRecordQuotas_AnnotatesAccountLimits(t *testing.T) {
+	s := NewServer(nil, nil)
+	s.SetQuotaTracker(quota.NewTracker(config.QuotaConfig{}, nil))
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var quotas map[string]interface{}
+	for i, rf := range []float64{0.8, 0.6} {
+		quotas = map[string]interface{}{
+			"antigravity/gemini-3-flash": map[string]interface{}{"remainingFraction": rf, "resetTime": nil},
+			"antigravity/claude-x":       map[string]interface{}{"remainingFraction": nil, "resetTime": nil},
+		}
+		s.recordQuotas("a@x", "antigravity", quotas, start.Add(time.Duration(i)*time.Hour))
+	}
+
+	accounts := renderAccountLimitsJSON(
+		[]string{"antigravity/claude-x", "antigravity/gemini-3-flash"},
+		[]map[string]interface{}{{"email": "a@x", "provider": "antigravity", "status": "ok", "models": quotas}},
+	)
+	limits := accounts[0]["limits"].(map[string]interface{})
+
+	flash := limits["antigravity/gemini-3-flash"].(map[string]interface{})
+	if rate, _ := flash["burnRatePerHour"].(float64); rate < 0.199 || rate > 0.201 {
+		t.Errorf("burnRatePerHour = %v, want 0.2", flash["burnRatePerHour"])
+	}
+	if flash["exhaustsAt"] != "2026-01-01T04:00:00.000Z" {
+		t.Errorf("exhaustsAt = %v", flash["exhaustsAt"])
+	}
+
+	// Models without a known fraction are not tracked.
+	if _, ok := limits["antigravity/claude-x"].(map[string]interface{})["exhaustsAt"]; ok {
+		t.Error("unexpected estimate for a model without remainingFraction")
+	}
+}
+
+func TestRecordQuotas_FlagsUsageDiscrepancy(t *testing.T) {
+	s := NewServer(nil, nil)
+	s.SetQuotaTracker(quota.NewTracker(config.QuotaConfig{ReconcileTolerance: 3}, nil))
+
+	trace := &provider.Trace{}
+	trace.Attempt("a@x")
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var quotas map[string]interface{}
+	for i, rf := range []float64{0.8, 0.6} {
+		if i > 0 {
+			// Other providers' tokens are not reconciled.
+			s.recordAccountUsage(trace, &requestStats{Provider: "zai", Model: "gemini-3-flash", InputTokens: 500})
+		}
+		quotas = map[string]interface{}{
+			"antigravity/gemini-3-flash": map[string]interface{}{"remainingFraction": rf, "resetTime": nil},
+		}
+		s.recordQuotas("a@x", "antigravity", quotas, start.Add(time.Duration(i)*time.Hour))
+	}
+
+	d, ok := quotas["antigravity/gemini-3-flash"].(map[string]interface{})["usageDiscrepancy"].(quota.Discrepancy)
+	if !ok || d.Kind != quota.UntrackedUsage || d.LocalTokens != 0 {
+		t.Fatalf("expected an untracked usage discrepancy, got %+v", d)
+	}
+
+	// With the tokens credited to the account, the next drop is accounted for.
+	s.recordAccountUsage(trace, &requestStats{Provider: "antigravity", Model: "gemini-3-flash", InputTokens: 700, OutputTokens: 300})
+	s.recordQuotas("a@x", "antigravity", map[string]interface{}{
+		"antigravity/gemini-3-flash": map[string]interface{}{"remainingFraction": 0.4, "resetTime": nil},
+	}, start.Add(2*time.Hour))
+	if d, _ := s.quotaTracker.Discrepancy("a@x", "antigravity/gemini-3-flash", start.Add(2*time.Hour)); !d.At.Equal(start.Add(time.Hour)) {
+		t.Errorf("expected no new discrepancy, got %+v", d)
+	}
+}
+
+func TestRecordAccountUsage_EstimatesCost(t *testing.T) {
+	s := NewServer(nil, nil)
+	s.SetModelPricing(config.ModelPricing{"claude-sonnet-4-5": {Input: 0.003, Output: 0.015}})
+	trace := &provider.Trace{}
+	trace.Attempt("a@example.com")
+
+	stats := &requestStats{Provider: "antigravity", Model: "claude-sonnet-4-5", InputTokens: 2000, OutputTokens: 1000}
+	s.recordAccountUsage(trace, stats)
+	if stats.Account != "a@example.com" || stats.Cost < 0.0209 || stats.Cost > 0.0211 {
+		t.Errorf("stats = %+v, want the account and a cost of $0.021", stats)
+	}
+
+	unpriced := &requestStats{Provider: "zai", Model: "glm-4.7", InputTokens: 2000}
+	s.recordAccountUsage(trace, unpriced)
+	if unpriced.Cost != 0 {
+		t.Errorf("cost of an unpriced model = %g, want 0", unpriced.Cost)
+	}
+}
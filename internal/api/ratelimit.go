@@ -0,0 +1,181 @@
+package api
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// tokenBucket refills at rate per second up to burst. Token costs are only known once a
+// response is complete, so its balance can go negative, which holds back later requests
+// until it has refilled.
+type tokenBucket struct {
+	rate    float64
+	burst   float64
+	balance float64
+	last    time.Time
+}
+
+func newTokenBucket(rate, burst float64, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, balance: burst, last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.balance = math.Min(b.burst, b.balance+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+// wait returns how long until the balance reaches need; 0 if it already has.
+func (b *tokenBucket) wait(need float64) time.Duration {
+	if b.balance >= need {
+		return 0
+	}
+	return time.Duration((need - b.balance) / b.rate * float64(time.Second))
+}
+
+// clientBuckets are the request and token buckets of one client, or of all of them.
+type clientBuckets struct {
+	requests *tokenBucket // nil when the request rate isn't limited
+	tokens   *tokenBucket // nil when the token rate isn't limited
+}
+
+// clientRateLimiter caps the request and token rates of downstream clients, per API key
+// and globally (RATE_LIMIT_*), so one client can't exhaust the account pool.
+type clientRateLimiter struct {
+	cfg config.ClientRateLimitConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	global  clientBuckets
+	clients map[[sha256.Size]byte]*clientBuckets // By hashed API key
+}
+
+func newClientRateLimiter(cfg config.ClientRateLimitConfig) *clientRateLimiter {
+	l := &clientRateLimiter{cfg: cfg, now: time.Now, clients: make(map[[sha256.Size]byte]*clientBuckets)}
+	l.global = l.buckets(cfg.GlobalRPS, cfg.GlobalBurst, cfg.GlobalTPM, l.now())
+	return l
+}
+
+func (l *clientRateLimiter) buckets(rps float64, burst, tpm int, now time.Time) clientBuckets {
+	var b clientBuckets
+	if rps > 0 {
+		b.requests = newTokenBucket(rps, float64(max(burst, 1)), now)
+	}
+	if tpm > 0 {
+		b.tokens = newTokenBucket(float64(tpm)/60, float64(tpm), now)
+	}
+	return b
+}
+
+func (l *clientRateLimiter) clientLocked(apiKey string, now time.Time) *clientBuckets {
+	key := sha256.Sum256([]byte(apiKey))
+	b, ok := l.clients[key]
+	if !ok {
+		buckets := l.buckets(l.cfg.RPS, l.cfg.Burst, l.cfg.TPM, now)
+		b = &buckets
+		l.clients[key] = b
+	}
+	return b
+}
+
+// allow admits a request from the client with apiKey, taking one request from its
+// buckets and the global ones. A rejected request takes nothing; the returned wait is
+// how long until it would be admitted, and the reason names the limit it hit.
+func (l *clientRateLimiter) allow(apiKey string) (time.Duration, string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	scopes := []struct {
+		name    string
+		buckets *clientBuckets
+	}{
+		{"this API key", l.clientLocked(apiKey, now)},
+		{"the proxy", &l.global},
+	}
+
+	var (
+		wait   time.Duration
+		reason string
+	)
+	for _, scope := range scopes {
+		if b := scope.buckets.requests; b != nil {
+			b.refill(now)
+			if d := b.wait(1); d > wait {
+				wait, reason = d, fmt.Sprintf("%s is limited to %g requests per second", scope.name, b.rate)
+			}
+		}
+		if b := scope.buckets.tokens; b != nil {
+			b.refill(now)
+			// Any balance left admits a request: its cost is charged when it completes.
+			if d := b.wait(0); d > wait {
+				wait, reason = d, fmt.Sprintf("%s is limited to %g tokens per minute", scope.name, b.burst)
+			}
+		}
+	}
+	if wait > 0 {
+		return wait, reason, false
+	}
+	for _, scope := range scopes {
+		if b := scope.buckets.requests; b != nil {
+			b.balance--
+		}
+	}
+	return 0, "", true
+}
+
+// charge takes the tokens a completed request used from the client's and the global
+// token buckets.
+func (l *clientRateLimiter) charge(apiKey string, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for _, b := range []*tokenBucket{l.clientLocked(apiKey, now).tokens, l.global.tokens} {
+		if b != nil {
+			b.refill(now)
+			b.balance -= float64(tokens)
+		}
+	}
+}
+
+// ClientRateLimit rejects /v1 requests beyond the client rate limits with a 429
+// rate_limit_error and a Retry-After, and charges the tokens of the requests it admits.
+// /v1/models, which never reaches an upstream, is exempt. A nil limiter disables it.
+func ClientRateLimit(l *clientRateLimiter, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1/") || r.URL.Path == "/v1/models" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		apiKey, _ := extractAPIKey(r)
+		wait, reason, ok := l.allow(apiKey)
+		if !ok {
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+			writeError(w, http.StatusTooManyRequests, "rate_limit_error",
+				fmt.Sprintf("Rate limit exceeded: %s; retry after %s", reason, formatDuration(wait)))
+			return
+		}
+
+		stats := requestStatsFromContext(r.Context())
+		if stats == nil {
+			stats = &requestStats{}
+			r = r.WithContext(withRequestStats(r.Context(), stats))
+		}
+		next.ServeHTTP(w, r)
+		l.charge(apiKey, stats.InputTokens+stats.OutputTokens)
+	})
+}
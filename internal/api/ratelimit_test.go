@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+func TestClientRateLimiter_Requests(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newClientRateLimiter(config.ClientRateLimitConfig{RPS: 2, Burst: 2, GlobalRPS: 3, GlobalBurst: 3})
+	l.now = func() time.Time { return now }
+	l.global = l.buckets(3, 3, 0, now)
+
+	for range 2 {
+		if _, _, ok := l.allow("key-a"); !ok {
+			t.Fatal("expected the burst to be admitted")
+		}
+	}
+	wait, reason, ok := l.allow("key-a")
+	if ok || wait != 500*time.Millisecond || !strings.Contains(reason, "this API key is limited to 2 requests per second") {
+		t.Fatalf("allow = %s %q %v, want a 500ms wait on the key's limit", wait, reason, ok)
+	}
+
+	// Another key has its own bucket, but shares the global one.
+	if _, _, ok := l.allow("key-b"); !ok {
+		t.Fatal("expected another key to be admitted")
+	}
+	if _, reason, ok := l.allow("key-b"); ok || !strings.Contains(reason, "the proxy") {
+		t.Fatalf("expected the global limit, got %q %v", reason, ok)
+	}
+
+	now = now.Add(time.Second)
+	if _, _, ok := l.allow("key-a"); !ok {
+		t.Error("expected the key to be admitted after refilling")
+	}
+}
+
+func TestClientRateLimiter_Tokens(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newClientRateLimiter(config.ClientRateLimitConfig{TPM: 600})
+	l.now = func() time.Time { return now }
+	l.global = l.buckets(0, 0, 0, now)
+
+	// A request is admitted while tokens are left; its usage is charged afterwards.
+	if _, _, ok := l.allow("key-a"); !ok {
+		t.Fatal("expected the first request to be admitted")
+	}
+	l.charge("key-a", 700)
+	wait, reason, ok := l.allow("key-a")
+	if ok || wait != 10*time.Second || !strings.Contains(reason, "600 tokens per minute") {
+		t.Fatalf("allow = %s %q %v, want a 10s wait for 100 tokens at 10/s", wait, reason, ok)
+	}
+	now = now.Add(10 * time.Second)
+	if _, _, ok := l.allow("key-a"); !ok {
+		t.Error("expected the key to be admitted once the debt is repaid")
+	}
+}
+
+func TestClientRateLimit_Middleware(t *testing.T) {
+	l := newClientRateLimiter(config.ClientRateLimitConfig{RPS: 0.5, Burst: 1})
+	handler := ClientRateLimit(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if stats := requestStatsFromContext(r.Context()); stats != nil {
+			stats.InputTokens = 10
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.Header.Set("x-api-key", "test-key")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	if w := send("/v1/messages"); w.Code != http.StatusOK {
+		t.Fatalf("expected the first request through, got %d", w.Code)
+	}
+	w := send("/v1/messages")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" || !strings.Contains(w.Body.String(), "rate_limit_error") {
+		t.Fatalf("expected a 429 rate_limit_error with Retry-After 2, got %d %q %s", w.Code, w.Header().Get("Retry-After"), w.Body.String())
+	}
+	for _, path := range []string{"/v1/models", "/health"} {
+		if w := send(path); w.Code != http.StatusOK {
+			t.Errorf("%s: expected no rate limit, got %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// recentRequestsCapacity is how many /v1 requests the dashboard's request log keeps.
+	recentRequestsCapacity = 200
+	// usageHistoryMinutes is how many one-minute token usage buckets are kept.
+	usageHistoryMinutes = 60
+	// maxTrackedUsers caps the per-user usage totals; the least recently seen user is dropped first.
+	maxTrackedUsers = 1000
+	// maxTrackedLatencies caps the per provider/model/account latency totals, dropped like users.
+	maxTrackedLatencies = 1000
+)
+
+// recentRequest is a completed /v1 request as shown in the dashboard.
+type recentRequest struct {
+	Timestamp       time.Time `json:"timestamp"`
+	Method          string    `json:"method"`
+	Path            string    `json:"path"`
+	Provider        string    `json:"provider,omitempty"`
+	Model           string    `json:"model,omitempty"`
+	User            string    `json:"user,omitempty"`
+	Account         string    `json:"account,omitempty"`
+	Status          int       `json:"status"`
+	DurationMs      int64     `json:"durationMs"`
+	FirstEventMs    int64     `json:"firstEventMs,omitempty"` // Streaming only
+	InputTokens     int       `json:"inputTokens,omitempty"`
+	OutputTokens    int       `json:"outputTokens,omitempty"`
+	EstimatedCost   float64   `json:"estimatedCost,omitempty"` // US dollars, with MODEL_PRICING_CONFIG
+	TokensPerSecond float64   `json:"tokensPerSecond,omitempty"`
+	Cached          bool      `json:"cached,omitempty"` // Served from the response cache
+}
+
+// usageBucket aggregates requests and output tokens for one minute.
+type usageBucket struct {
+	Minute       time.Time `json:"minute"`
+	Requests     int       `json:"requests"`
+	Errors       int       `json:"errors"`
+	OutputTokens int       `json:"outputTokens"`
+}
+
+// userUsage totals the requests and tokens of one metadata.user_id since the server started.
+type userUsage struct {
+	User          string    `json:"user"`
+	Requests      int       `json:"requests"`
+	Errors        int       `json:"errors"`
+	InputTokens   int       `json:"inputTokens"`
+	OutputTokens  int       `json:"outputTokens"`
+	EstimatedCost float64   `json:"estimatedCost"` // US dollars, with MODEL_PRICING_CONFIG
+	LastSeen      time.Time `json:"lastSeen"`
+}
+
+// latencyKey identifies the latency totals of a model served by one account.
+type latencyKey struct {
+	provider string
+	model    string
+	account  string
+}
+
+// modelLatency totals the time to first event and throughput of a model on one account since
+// the server started.
+type modelLatency struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Account  string `json:"account,omitempty"`
+	Requests int    `json:"requests"`
+	// Averages over the requests that measured them: streams for the first event, responses
+	// with output tokens for throughput.
+	AvgFirstEventMs    int64     `json:"avgFirstEventMs,omitempty"`
+	AvgTokensPerSecond float64   `json:"avgTokensPerSecond,omitempty"`
+	LastSeen           time.Time `json:"lastSeen"`
+
+	firstEvents, throughputs int
+	firstEventMs             int64
+	tokensPerSecond          float64
+}
+
+// requestLog keeps the most recent requests in a ring buffer plus per-minute, per-user and
+// per-account latency totals. It lives in memory only; the audit log is the durable record.
+type requestLog struct {
+	mu        sync.Mutex
+	entries   []recentRequest
+	next      int
+	full      bool
+	usage     []usageBucket // oldest first, at most usageHistoryMinutes
+	users     map[string]*userUsage
+	latencies map[latencyKey]*modelLatency
+}
+
+func newRequestLog(capacity int) *requestLog {
+	return &requestLog{
+		entries:   make([]recentRequest, capacity),
+		users:     make(map[string]*userUsage),
+		latencies: make(map[latencyKey]*modelLatency),
+	}
+}
+
+func (l *requestLog) add(e recentRequest) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+
+	minute := e.Timestamp.Truncate(time.Minute)
+	if n := len(l.usage); n == 0 || l.usage[n-1].Minute.Before(minute) {
+		l.usage = append(l.usage, usageBucket{Minute: minute})
+	}
+	b := &l.usage[len(l.usage)-1]
+	b.Requests++
+	if e.Status >= 400 {
+		b.Errors++
+	}
+	b.OutputTokens += e.OutputTokens
+	l.pruneLocked(minute)
+
+	if e.User != "" {
+		l.addUserLocked(e)
+	}
+	if e.FirstEventMs > 0 || e.TokensPerSecond > 0 {
+		l.addLatencyLocked(e)
+	}
+}
+
+func (l *requestLog) addLatencyLocked(e recentRequest) {
+	key := latencyKey{provider: e.Provider, model: e.Model, account: e.Account}
+	m, ok := l.latencies[key]
+	if !ok {
+		if len(l.latencies) >= maxTrackedLatencies {
+			var oldest *modelLatency
+			var oldestKey latencyKey
+			for k, candidate := range l.latencies {
+				if oldest == nil || candidate.LastSeen.Before(oldest.LastSeen) {
+					oldest, oldestKey = candidate, k
+				}
+			}
+			delete(l.latencies, oldestKey)
+		}
+		m = &modelLatency{Provider: e.Provider, Model: e.Model, Account: e.Account}
+		l.latencies[key] = m
+	}
+	m.Requests++
+	if e.FirstEventMs > 0 {
+		m.firstEvents++
+		m.firstEventMs += e.FirstEventMs
+	}
+	if e.TokensPerSecond > 0 {
+		m.throughputs++
+		m.tokensPerSecond += e.TokensPerSecond
+	}
+	if e.Timestamp.After(m.LastSeen) {
+		m.LastSeen = e.Timestamp
+	}
+}
+
+// latencyTotals returns the latency totals by provider, model and account.
+func (l *requestLog) latencyTotals() []modelLatency {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	latencies := make([]modelLatency, 0, len(l.latencies))
+	for _, m := range l.latencies {
+		total := *m
+		if m.firstEvents > 0 {
+			total.AvgFirstEventMs = m.firstEventMs / int64(m.firstEvents)
+		}
+		if m.throughputs > 0 {
+			total.AvgTokensPerSecond = m.tokensPerSecond / float64(m.throughputs)
+		}
+		latencies = append(latencies, total)
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		a, b := latencies[i], latencies[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.Account < b.Account
+	})
+	return latencies
+}
+
+func (l *requestLog) addUserLocked(e recentRequest) {
+	u, ok := l.users[e.User]
+	if !ok {
+		if len(l.users) >= maxTrackedUsers {
+			var oldest *userUsage
+			for _, candidate := range l.users {
+				if oldest == nil || candidate.LastSeen.Before(oldest.LastSeen) {
+					oldest = candidate
+				}
+			}
+			delete(l.users, oldest.User)
+		}
+		u = &userUsage{User: e.User}
+		l.users[e.User] = u
+	}
+	u.Requests++
+	if e.Status >= 400 {
+		u.Errors++
+	}
+	u.InputTokens += e.InputTokens
+	u.OutputTokens += e.OutputTokens
+	u.EstimatedCost += e.EstimatedCost
+	if e.Timestamp.After(u.LastSeen) {
+		u.LastSeen = e.Timestamp
+	}
+}
+
+// userTotals returns the per-user usage totals, heaviest users first.
+func (l *requestLog) userTotals() []userUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	users := make([]userUsage, 0, len(l.users))
+	for _, u := range l.users {
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		ti, tj := users[i].InputTokens+users[i].OutputTokens, users[j].InputTokens+users[j].OutputTokens
+		if ti != tj {
+			return ti > tj
+		}
+		return users[i].User < users[j].User
+	})
+	return users
+}
+
+// pruneLocked drops usage buckets older than the history window ending at now.
+func (l *requestLog) pruneLocked(now time.Time) {
+	cutoff := now.Add(-usageHistoryMinutes * time.Minute)
+	i := 0
+	for i < len(l.usage) && !l.usage[i].Minute.After(cutoff) {
+		i++
+	}
+	l.usage = l.usage[i:]
+}
+
+// snapshot returns up to limit requests (newest first) and the usage buckets (oldest first).
+func (l *requestLog) snapshot(limit int) ([]recentRequest, []usageBucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	size := l.next
+	if l.full {
+		size = len(l.entries)
+	}
+	if limit <= 0 || limit > size {
+		limit = size
+	}
+	requests := make([]recentRequest, 0, limit)
+	for i := 1; i <= limit; i++ {
+		requests = append(requests, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+
+	l.pruneLocked(time.Now().Truncate(time.Minute))
+	return requests, append([]usageBucket(nil), l.usage...)
+}
+
+// RecentRequests records every /v1 request in log for the dashboard. A nil log disables it.
+func RecentRequests(log *requestLog, next http.Handler) http.Handler {
+	if log == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		stats := requestStatsFromContext(r.Context())
+		if stats == nil {
+			stats = &requestStats{}
+			r = r.WithContext(withRequestStats(r.Context(), stats))
+		}
+
+		next.ServeHTTP(rw, r)
+
+		log.add(recentRequest{
+			Timestamp:       start.UTC(),
+			Method:          r.Method,
+			Path:            r.URL.Path,
+			Provider:        stats.Provider,
+			Model:           stats.Model,
+			User:            stats.User,
+			Account:         stats.Account,
+			Status:          rw.statusCode,
+			DurationMs:      time.Since(start).Milliseconds(),
+			FirstEventMs:    stats.FirstEventMs,
+			InputTokens:     stats.InputTokens,
+			OutputTokens:    stats.OutputTokens,
+			EstimatedCost:   stats.Cost,
+			TokensPerSecond: stats.TokensPerSecond,
+			Cached:          stats.Cached,
+		})
+	})
+}
+
+// handleRecentRequests handles GET /admin/requests[?limit=N]: the most recent /v1 requests,
+// per-minute usage for the last hour, and per-user and per-account latency totals since startup.
+func (s *Server) handleRecentRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	limit := 50
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeAdminError(w, http.StatusBadRequest, "Invalid limit: "+raw)
+			return
+		}
+		limit = n
+	}
+
+	requests, usage := s.recent.snapshot(limit)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "ok",
+		"requests":  requests,
+		"usage":     usage,
+		"users":     s.recent.userTotals(),
+		"latencies": s.recent.latencyTotals(),
+	})
+}
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/cache"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// streamRecovery resumes a stream that fails after it has started (STREAM_RECOVERY). The
+// request is sent again, preferably on another account, with the text written to the client
+// so far as a prefilled assistant turn, and the new stream's events are renumbered to
+// continue the client's message.
+type streamRecovery struct {
+	attemptsLeft int
+	avoid        []string // Accounts whose streams failed
+
+	started  bool           // message_start was written
+	blocks   []recoveryText // Content blocks written, by index
+	open     int            // Block started but not stopped, or -1
+	toolUse  bool           // A block that can't be continued from text was written
+	finished bool           // message_delta was written; nothing can follow it
+
+	// While relaying a resumed stream:
+	offset    int  // Added to the resumed stream's block indexes
+	merge     bool // The resumed stream's first text block continues the open block
+	trimmed   bool // Whitespace was cut from the end of the prefill; drop it from the continuation
+	mergeDone bool // The resumed stream's first block has started
+	resumed   bool
+}
+
+// recoveryText is a content block written to the client.
+type recoveryText struct {
+	blockType string
+	text      string
+}
+
+// newStreamRecovery returns the recovery state of a stream, or nil when recovery is disabled
+// for the provider.
+func newStreamRecovery(providerName string) *streamRecovery {
+	attempts := config.GetStreamRecoveryAttempts(providerName)
+	if attempts <= 0 {
+		return nil
+	}
+	return &streamRecovery{attemptsLeft: attempts, open: -1}
+}
+
+// streamEventFields is the part of a serialized content block event the handlers look at.
+type streamEventFields struct {
+	Index        int `json:"index"`
+	ContentBlock *struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content_block"`
+	Delta *struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+// observe records an event written to the client.
+func (r *streamRecovery) observe(eventType string, data []byte) {
+	if r == nil {
+		return
+	}
+	switch eventType {
+	case "message_start":
+		r.started = true
+		return
+	case "message_delta":
+		r.finished = true
+		return
+	}
+	var ev streamEventFields
+	if err := json.Unmarshal(data, &ev); err != nil || ev.Index < 0 {
+		return
+	}
+	switch eventType {
+	case "content_block_start":
+		if ev.ContentBlock == nil {
+			return
+		}
+		for len(r.blocks) <= ev.Index {
+			r.blocks = append(r.blocks, recoveryText{})
+		}
+		r.blocks[ev.Index].blockType = ev.ContentBlock.Type
+		r.blocks[ev.Index].text += ev.ContentBlock.Text
+		switch ev.ContentBlock.Type {
+		case "text", "thinking", "redacted_thinking":
+		default:
+			r.toolUse = true
+		}
+		r.open = ev.Index
+	case "content_block_delta":
+		if ev.Delta != nil && ev.Delta.Type == "text_delta" && ev.Index < len(r.blocks) {
+			r.blocks[ev.Index].text += ev.Delta.Text
+		}
+	case "content_block_stop":
+		r.open = -1
+	}
+}
+
+// canResume reports whether the stream can be resumed: it has started but not finished,
+// no tool call was streamed (a partial tool call can't be continued) and attempts are left.
+func (r *streamRecovery) canResume() bool {
+	return r != nil && r.started && !r.finished && !r.toolUse && r.attemptsLeft > 0
+}
+
+// prepare sets up relaying a resumed stream and returns the continuation request and the
+// events that close a partially written block the resumed stream can't continue.
+func (r *streamRecovery) prepare(req *types.AnthropicRequest) (*types.AnthropicRequest, []cache.Event) {
+	r.attemptsLeft--
+	r.resumed = true
+	r.mergeDone = false
+
+	var text strings.Builder
+	for i := range r.blocks {
+		if r.blocks[i].blockType == "text" {
+			text.WriteString(r.blocks[i].text)
+		}
+	}
+	prefill := strings.TrimRightFunc(text.String(), unicode.IsSpace)
+	r.trimmed = len(prefill) < text.Len()
+
+	var closing []cache.Event
+	r.merge = r.open >= 0 && r.blocks[r.open].blockType == "text"
+	switch {
+	case r.merge:
+		r.offset = r.open
+	case r.open >= 0:
+		// A thinking block can't be continued without thinking; close it as it is.
+		data, _ := json.Marshal(types.StreamEvent{Type: "content_block_stop", Index: r.open})
+		closing = append(closing, cache.Event{Type: "content_block_stop", Data: data})
+		r.open = -1
+		r.offset = len(r.blocks)
+	default:
+		r.offset = len(r.blocks)
+	}
+
+	cont := *req
+	// Thinking doesn't work with a prefilled assistant turn; the thinking already streamed
+	// stands for the rest of the message.
+	cont.Thinking = nil
+	cont.Messages = slices.Clone(req.Messages)
+	if prefill != "" {
+		block := types.ContentBlock{Type: "text", Text: prefill}
+		last := len(cont.Messages) - 1
+		if last >= 0 && cont.Messages[last].Role == "assistant" {
+			// The client prefilled the turn; the streamed text continues it.
+			blocks, err := types.ParseMessageContent(cont.Messages[last].Content)
+			if err == nil {
+				content, _ := json.Marshal(append(blocks, block))
+				cont.Messages[last] = types.Message{Role: "assistant", Content: content}
+			}
+		} else {
+			content, _ := json.Marshal([]types.ContentBlock{block})
+			cont.Messages = append(cont.Messages, types.Message{Role: "assistant", Content: content})
+		}
+	}
+	return &cont, closing
+}
+
+// relay rewrites an event of a resumed stream into the events that continue the client's
+// message. The resumed message_start is dropped and content block indexes are shifted past
+// the blocks already written.
+func (r *streamRecovery) relay(eventType string, data []byte) []cache.Event {
+	if r == nil || !r.resumed {
+		return []cache.Event{{Type: eventType, Data: data}}
+	}
+	switch eventType {
+	case "message_start":
+		return nil
+	case "content_block_start", "content_block_delta", "content_block_stop":
+	default:
+		return []cache.Event{{Type: eventType, Data: data}}
+	}
+
+	var fields map[string]json.RawMessage
+	var ev streamEventFields
+	if json.Unmarshal(data, &fields) != nil || json.Unmarshal(data, &ev) != nil {
+		return []cache.Event{{Type: eventType, Data: data}}
+	}
+
+	var out []cache.Event
+	if r.merge && ev.Index == 0 {
+		if eventType == "content_block_start" && !r.mergeDone {
+			r.mergeDone = true
+			if ev.ContentBlock != nil && ev.ContentBlock.Type == "text" && ev.ContentBlock.Text == "" {
+				return nil // The open block continues
+			}
+			// Something else follows the partial text: close the text block first.
+			stop, _ := json.Marshal(types.StreamEvent{Type: "content_block_stop", Index: r.offset})
+			out = append(out, cache.Event{Type: "content_block_stop", Data: stop})
+			r.merge = false
+			r.offset++
+		}
+		if r.merge && r.trimmed && ev.Delta != nil && ev.Delta.Type == "text_delta" {
+			text := strings.TrimLeftFunc(ev.Delta.Text, unicode.IsSpace)
+			if text == "" {
+				return nil
+			}
+			r.trimmed = false
+			delta, _ := json.Marshal(types.Delta{Type: "text_delta", Text: text})
+			fields["delta"] = delta
+		}
+	}
+	if !r.merge || ev.Index != 0 {
+		r.trimmed = false
+	}
+
+	index, _ := json.Marshal(ev.Index + r.offset)
+	fields["index"] = index
+	rewritten, err := json.Marshal(fields)
+	if err != nil {
+		return append(out, cache.Event{Type: eventType, Data: data})
+	}
+	return append(out, cache.Event{Type: eventType, Data: rewritten})
+}
+
+// streamErrorResumable reports whether a stream that failed with an error event may be
+// resumed: the upstream failed or is overloaded or rate-limited, not the request itself.
+func streamErrorResumable(ae *merrors.AnthropicError) bool {
+	switch ae.Detail.Type {
+	case merrors.ErrorTypeAPI, merrors.ErrorTypeOverloaded, merrors.ErrorTypeRateLimit:
+		return true
+	}
+	return false
+}
+
+// resumeStream sends the continuation of a stream that failed with cause after it started.
+// It returns the resumed stream's events and the events to write first; ok is false when
+// the stream can't be resumed and should end with an error as usual.
+func (s *Server) resumeStream(ctx context.Context, prov provider.Provider, req *types.AnthropicRequest, r *streamRecovery, cause string) (eventsCh <-chan types.StreamEvent, closing []cache.Event, ok bool) {
+	for r.canResume() {
+		if email := provider.TraceFromContext(ctx).Account(); email != "" && !slices.Contains(r.avoid, email) {
+			r.avoid = append(r.avoid, email)
+		}
+		cont, events := r.prepare(req)
+		closing = append(closing, events...)
+		metrics.StreamRecoveries.Inc(prov.Name(), req.Model)
+		utils.Warn("[Messages] %s stream for %s failed after it started (%s); resuming on another account", prov.Name(), req.Model, cause)
+
+		ch, err := prov.SendMessageStream(account.WithoutAccounts(ctx, r.avoid...), cont)
+		if err == nil {
+			return ch, closing, true
+		}
+		cause = err.Error()
+	}
+	if r != nil && r.resumed {
+		utils.Warn("[Messages] Giving up resuming %s stream for %s: %s", prov.Name(), req.Model, cause)
+	}
+	return nil, closing, false
+}
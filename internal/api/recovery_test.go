@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/streamcheck"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// failoverProvider serves its streams in turn, one per request, and records the requests.
+type failoverProvider struct {
+	mockProvider
+	streams [][]types.StreamEvent
+
+	mu       sync.Mutex
+	requests []*types.AnthropicRequest
+}
+
+func (p *failoverProvider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	events := p.streams[min(len(p.requests), len(p.streams))-1]
+	p.mu.Unlock()
+
+	ch := make(chan types.StreamEvent, len(events))
+	for _, event := range events {
+		ch <- event
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestHandleStreamingMessage_StreamRecovery(t *testing.T) {
+	metrics.StreamRecoveries.Reset()
+	defer metrics.StreamRecoveries.Reset()
+
+	start := types.StreamEvent{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: "glm-4.7"}}
+	textStart := types.StreamEvent{Type: "content_block_start", Index: 0, ContentBlock: &types.ContentBlock{Type: "text"}}
+	text := func(index int, s string) types.StreamEvent {
+		return types.StreamEvent{Type: "content_block_delta", Index: index, Delta: &types.Delta{Type: "text_delta", Text: s}}
+	}
+	end := []types.StreamEvent{
+		{Type: "content_block_stop", Index: 0},
+		{Type: "message_delta", Delta: &types.Delta{StopReason: "end_turn"}, Usage: &types.Usage{OutputTokens: 3}},
+		{Type: "message_stop"},
+	}
+	overloaded := types.StreamEvent{Type: "error", Error: &types.ErrorDetail{Type: "overloaded_error", Message: "Overloaded"}}
+	thinking := []types.StreamEvent{
+		start,
+		{Type: "content_block_start", Index: 0, ContentBlock: &types.ContentBlock{Type: "thinking"}},
+		{Type: "content_block_delta", Index: 0, Delta: &types.Delta{Type: "thinking_delta", Thinking: "Hmm"}},
+	}
+
+	tests := []struct {
+		name        string
+		disabled    bool
+		streams     [][]types.StreamEvent
+		wantText    string
+		wantPrefill string // Text of the assistant turn appended to the resumed request
+		wantBlocks  int
+		wantError   bool
+	}{
+		{
+			name: "truncated text block",
+			streams: [][]types.StreamEvent{
+				{start, textStart, text(0, "Hello, wor")},
+				append([]types.StreamEvent{start, textStart, text(0, "ld!")}, end...),
+			},
+			wantText:    "Hello, world!",
+			wantPrefill: "Hello, wor",
+			wantBlocks:  1,
+		},
+		{
+			name: "error event after trailing whitespace",
+			streams: [][]types.StreamEvent{
+				{start, textStart, text(0, "Hello "), overloaded},
+				append([]types.StreamEvent{start, textStart, text(0, " world")}, end...),
+			},
+			wantText:    "Hello world",
+			wantPrefill: "Hello",
+			wantBlocks:  1,
+		},
+		{
+			name: "open thinking block",
+			streams: [][]types.StreamEvent{
+				thinking,
+				append([]types.StreamEvent{start, textStart, text(0, "Done.")}, end...),
+			},
+			wantText:   "Done.",
+			wantBlocks: 2,
+		},
+		{
+			name: "every attempt fails",
+			streams: [][]types.StreamEvent{
+				{start, textStart, text(0, "Hel")},
+			},
+			wantError: true,
+		},
+		{
+			name:     "disabled",
+			disabled: true,
+			streams: [][]types.StreamEvent{
+				{start, textStart, text(0, "Hel")},
+				append([]types.StreamEvent{start, textStart, text(0, "lo")}, end...),
+			},
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STREAM_RECOVERY", "true")
+			if tt.disabled {
+				t.Setenv("ZAI_STREAM_RECOVERY", "false")
+			}
+			prov := &failoverProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, streams: tt.streams}
+			events := streamFrom(t, prov, 0)
+
+			if err := streamcheck.Check(events); err != nil {
+				t.Errorf("stream violates the protocol:\n%v", err)
+			}
+			if tt.wantError {
+				if last := events[len(events)-1]; last.Type != "error" {
+					t.Errorf("expected the stream to end with an error, got %s", strings.Join(eventTypes(events), " "))
+				}
+				return
+			}
+
+			msg, err := streamcheck.Accumulate(events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			for _, block := range msg.Content {
+				got += block.Text
+			}
+			if got != tt.wantText || len(msg.Content) != tt.wantBlocks {
+				t.Errorf("message = %q in %d block(s), want %q in %d", got, len(msg.Content), tt.wantText, tt.wantBlocks)
+			}
+
+			if len(prov.requests) != 2 {
+				t.Fatalf("expected the request to be resumed once, got %d requests", len(prov.requests))
+			}
+			resumed := prov.requests[1]
+			last := resumed.Messages[len(resumed.Messages)-1]
+			if tt.wantPrefill == "" {
+				if len(resumed.Messages) != 1 {
+					t.Errorf("expected no prefill, got %s", last.Content)
+				}
+			} else {
+				var blocks []types.ContentBlock
+				if err := json.Unmarshal(last.Content, &blocks); err != nil || last.Role != "assistant" || len(blocks) != 1 || blocks[0].Text != tt.wantPrefill {
+					t.Errorf("unexpected prefill: %s %s", last.Role, last.Content)
+				}
+			}
+			if resumed.Thinking != nil {
+				t.Error("expected thinking to be disabled in the resumed request")
+			}
+			if n := metrics.StreamRecoveries.Count("zai", "glm-4.7"); n != 1 {
+				t.Errorf("expected 1 recovery, got %d", n)
+			}
+			metrics.StreamRecoveries.Reset()
+		})
+	}
+}
+
+func TestStreamRecovery_NoResumeAfterToolUse(t *testing.T) {
+	r := &streamRecovery{attemptsLeft: 1, open: -1}
+	r.observe("message_start", []byte(`{"type":"message_start"}`))
+	if !r.canResume() {
+		t.Fatal("expected a started stream to be resumable")
+	}
+	r.observe("content_block_start", []byte(`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"t","name":"f","input":{}}}`))
+	if r.canResume() {
+		t.Error("expected no resume after a tool call was streamed")
+	}
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/audit"
+	"github.com/kuzerno1/multi-claude-proxy/internal/capture"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// replayRequest is the body of POST /admin/replay.
+type replayRequest struct {
+	RequestID string `json:"requestId"`          // X-MCP-Request-Id of the audited /v1/messages request
+	Provider  string `json:"provider,omitempty"` // Overrides the provider the model resolves to
+	Account   string `json:"account,omitempty"`  // Pins the account (email) instead of normal selection
+}
+
+// replayEvent is one streaming event of a replayed request.
+type replayEvent struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// replayResult reports how a replayed request went, including every upstream exchange.
+type replayResult struct {
+	Status     string                 `json:"status"`
+	RequestID  string                 `json:"requestId"`
+	Provider   string                 `json:"provider"`
+	Model      string                 `json:"model"`
+	Account    string                 `json:"account,omitempty"`
+	Stream     bool                   `json:"stream"`
+	DurationMs int64                  `json:"durationMs"`
+	Attempts   int                    `json:"attempts"`
+	Response   map[string]interface{} `json:"response,omitempty"`
+	Events     []replayEvent          `json:"events,omitempty"`
+	Error      *types.ErrorDetail     `json:"error,omitempty"`
+	Upstream   []capture.Exchange     `json:"upstream"`
+}
+
+// handleReplay handles POST /admin/replay, which re-runs a /v1/messages request from the
+// audit log (AUDIT_LOG_ENABLED with AUDIT_LOG_BODIES) and reports the response together
+// with the upstream requests and responses, to reproduce conversion bugs on demand.
+// The response cache, concurrency limits and optimistic rate limit reset are bypassed.
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.handleNotFound(w, r)
+		return
+	}
+	if s.auditLog == nil {
+		writeAdminError(w, http.StatusNotImplemented, "Replay needs the audit log (AUDIT_LOG_ENABLED=true, AUDIT_LOG_BODIES=true)")
+		return
+	}
+
+	var in replayRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+	if in.RequestID == "" {
+		writeAdminError(w, http.StatusBadRequest, "requestId is required")
+		return
+	}
+
+	entry, err := s.auditLog.Find(in.RequestID)
+	if err != nil {
+		if stderrors.Is(err, audit.ErrNotFound) {
+			writeAdminError(w, http.StatusNotFound, "No audit entry for request "+in.RequestID)
+			return
+		}
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if entry.Path != "/v1/messages" {
+		writeAdminError(w, http.StatusBadRequest, "Only /v1/messages requests can be replayed, not "+entry.Path)
+		return
+	}
+	if len(entry.RequestBody) == 0 {
+		writeAdminError(w, http.StatusUnprocessableEntity, "Audit entry has no request body (AUDIT_LOG_BODIES=true is needed)")
+		return
+	}
+	if entry.RequestTruncated {
+		writeAdminError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Audited request body was truncated (%d bytes), so it can't be replayed", entry.RequestBytes))
+		return
+	}
+	req, err := parseMessagesRequest(entry.RequestBody)
+	if err != nil {
+		writeAdminError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Audited request body can't be replayed: %v", err))
+		return
+	}
+	if req.Model == "" {
+		req.Model = "antigravity/claude-3-5-sonnet-20241022"
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = 4096
+	}
+
+	prov, rawModel, err := s.resolveProviderForModel(req.Model)
+	if in.Provider != "" {
+		var override provider.Provider
+		if s.registry != nil {
+			override, _ = s.registry.GetByName(in.Provider)
+		}
+		if override == nil {
+			writeAdminError(w, http.StatusBadRequest, "Unknown provider: "+in.Provider)
+			return
+		}
+		if err != nil {
+			rawModel = req.Model
+			if _, m, ok := splitModelID(req.Model); ok {
+				rawModel = m
+			}
+		}
+		prov, err = override, nil
+	}
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	providerName := prov.Name()
+
+	ctx := r.Context()
+	if in.Account != "" {
+		if !s.hasAccount(providerName, in.Account) {
+			writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("No %s account %s", providerName, in.Account))
+			return
+		}
+		ctx = account.WithAccount(ctx, in.Account)
+	}
+	trace := &provider.Trace{}
+	recorder := &capture.Recorder{}
+	ctx = capture.WithRecorder(provider.WithTrace(ctx, trace), recorder)
+
+	reqForProvider := *req
+	reqForProvider.Model = rawModel
+	applySystemPrompt(&reqForProvider, config.GetSystemPromptConfig(providerName))
+	normalizeThinking(&reqForProvider, prov, config.GetThinkingPolicyConfig())
+	result := replayResult{
+		Status:    "ok",
+		RequestID: in.RequestID,
+		Provider:  providerName,
+		Model:     rawModel,
+		Account:   in.Account,
+		Stream:    req.Stream,
+	}
+
+	utils.Info("[Replay] Replaying request %s on %s/%s", in.RequestID, providerName, rawModel)
+	start := time.Now()
+	if req.Stream {
+		result.Events, err = collectReplayEvents(ctx, prov, &reqForProvider, &result)
+	} else {
+		var resp *types.AnthropicResponse
+		resp, err = prov.SendMessage(ctx, &reqForProvider)
+		if err == nil && resp != nil {
+			result.Response = toNodeMessageResponse(resp)
+		}
+	}
+	if err != nil {
+		detail := merrors.FromError(err).Detail
+		result.Error = &types.ErrorDetail{Type: string(detail.Type), Message: detail.Message}
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.Attempts = trace.Attempts()
+	result.Upstream = recorder.Exchanges()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// collectReplayEvents drains a replayed stream. An error event ends up in result.Error.
+// The channel is always drained so the provider's stream goroutine can finish.
+func collectReplayEvents(ctx context.Context, prov provider.Provider, req *types.AnthropicRequest, result *replayResult) ([]replayEvent, error) {
+	eventsCh, err := prov.SendMessageStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []replayEvent
+	for event := range eventsCh {
+		if event.Error != nil && result.Error == nil {
+			result.Error = event.Error
+		}
+		data, err := streamEventData(event)
+		if err != nil {
+			data, _ = json.Marshal(err.Error())
+		}
+		events = append(events, replayEvent{Type: event.Type, Data: data})
+	}
+	return events, nil
+}
+
+// hasAccount reports whether providerName has an account with email.
+func (s *Server) hasAccount(providerName, email string) bool {
+	if s.accountManager == nil {
+		return false
+	}
+	for _, acc := range s.accountManager.GetAllAccountsByProvider(providerName) {
+		if acc.Email == email {
+			return true
+		}
+	}
+	return false
+}
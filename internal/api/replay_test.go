@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/audit"
+	"github.com/kuzerno1/multi-claude-proxy/internal/capture"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// upstreamProvider forwards the prompt to an upstream HTTP server through capture.Transport.
+type upstreamProvider struct {
+	*mockProvider
+	url    string
+	client *http.Client
+}
+
+func (p *upstreamProvider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	body, _ := json.Marshal(map[string]string{"model": req.Model})
+	httpReq, _ := http.NewRequestWithContext(ctx, http.MethodPost, p.url+"?key=secret", strings.NewReader(string(body)))
+	httpReq.Header.Set("Authorization", "Bearer upstream-token")
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: req.Model}, nil
+}
+
+func TestHandleReplay(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	registry := provider.NewRegistry()
+	prov := &upstreamProvider{
+		mockProvider: &mockProvider{name: "zai", models: []string{"glm-4.7"}},
+		url:          upstream.URL,
+		client:       &http.Client{Transport: capture.Transport(nil)},
+	}
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+
+	logger, err := audit.New(config.AuditConfig{Enabled: true, Dir: t.TempDir(), LogBodies: true, MaxSizeMB: 1, MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	server := NewServer(registry, nil)
+	server.SetAuditLogger(logger)
+	srv := httptest.NewServer(server.Handler())
+	defer srv.Close()
+
+	post := func(path, body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("x-api-key", "test-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := post("/v1/messages", `{"model":"zai/glm-4.7","messages":[{"role":"user","content":"hi"}]}`)
+	io.Copy(io.Discard, resp.Body)
+	requestID := resp.Header.Get(headerRequestID)
+	if requestID == "" {
+		t.Fatal("expected request ID header")
+	}
+
+	resp = post("/admin/replay", `{"requestId":"`+requestID+`"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var result replayResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Provider != "zai" || result.Model != "glm-4.7" || result.Error != nil || result.Response["id"] != "msg_1" {
+		t.Errorf("unexpected replay result: %+v", result)
+	}
+	if len(result.Upstream) != 1 {
+		t.Fatalf("expected 1 upstream exchange, got %d", len(result.Upstream))
+	}
+	ex := result.Upstream[0]
+	if ex.Status != http.StatusOK || strings.Contains(ex.URL, "secret") || ex.RequestHeaders["Authorization"] == "Bearer upstream-token" {
+		t.Errorf("unexpected upstream exchange: %+v", ex)
+	}
+	if string(ex.RequestBody) != `{"model":"glm-4.7"}` || string(ex.ResponseBody) != `{"ok":true}` {
+		t.Errorf("unexpected upstream bodies: %s / %s", ex.RequestBody, ex.ResponseBody)
+	}
+
+	logger.Log(audit.Entry{
+		RequestID:        "truncated",
+		Path:             "/v1/messages",
+		RequestBytes:     maxAuditBodyBytes + 1,
+		RequestBody:      []byte(`{"model":"zai/glm-4.7","messages":[{"role":"user","content":"h`),
+		RequestTruncated: true,
+	})
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"missing request ID", `{}`, http.StatusBadRequest},
+		{"unknown request ID", `{"requestId":"nope"}`, http.StatusNotFound},
+		{"unknown provider", `{"requestId":"` + requestID + `","provider":"nope"}`, http.StatusBadRequest},
+		{"unknown account", `{"requestId":"` + requestID + `","account":"a@x"}`, http.StatusBadRequest},
+		{"truncated request body", `{"requestId":"truncated"}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := post("/admin/replay", tt.body); resp.StatusCode != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+}
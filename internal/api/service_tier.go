@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+)
+
+// Service tiers of a messages request. Priority capacity is an account that isn't
+// soft-limited for the model (SOFT_LIMIT_*), on the provider the model resolves to.
+const (
+	// serviceTierAuto keeps the default routing: soft-limited accounts are used only when no
+	// other is, and the global soft limit scheduler may move the request to another provider.
+	serviceTierAuto = "auto"
+	// serviceTierStandardOnly uses soft-limited accounts like any other and never reroutes.
+	serviceTierStandardOnly = "standard_only"
+	// serviceTierPriority uses only accounts that aren't soft-limited, on the requested
+	// provider, and is rejected when there are none.
+	serviceTierPriority = "priority"
+)
+
+// validServiceTier reports whether tier is a service_tier the proxy accepts ("" is auto).
+func validServiceTier(tier string) bool {
+	switch tier {
+	case "", serviceTierAuto, serviceTierStandardOnly, serviceTierPriority:
+		return true
+	}
+	return false
+}
+
+// reroutesSoftLimited reports whether the global soft limit scheduler may move a request of
+// tier to another provider.
+func reroutesSoftLimited(tier string) bool {
+	return tier == "" || tier == serviceTierAuto
+}
+
+// withServiceTier returns ctx selecting accounts for tier.
+func withServiceTier(ctx context.Context, tier string) context.Context {
+	switch tier {
+	case serviceTierPriority:
+		return account.WithoutSoftLimited(ctx)
+	case serviceTierStandardOnly:
+		return account.WithSoftLimited(ctx)
+	}
+	return ctx
+}
+
+// effectiveServiceTier is the usage.service_tier reported for a request of tier.
+func effectiveServiceTier(tier string) string {
+	if tier == serviceTierPriority {
+		return "priority"
+	}
+	return "standard"
+}
+
+// setRawMessageServiceTier sets message.usage.service_tier in a message_start event, keeping
+// the upstream bytes of every other value. data is returned as is when it has no usage or
+// the upstream already reports the tier that served it.
+func setRawMessageServiceTier(data []byte, tier string) []byte {
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(data, &event); err != nil {
+		return data
+	}
+	var message map[string]json.RawMessage
+	if err := json.Unmarshal(event["message"], &message); err != nil || message == nil {
+		return data
+	}
+	var usage map[string]json.RawMessage
+	if err := json.Unmarshal(message["usage"], &usage); err != nil || usage == nil {
+		return data
+	}
+	if _, ok := usage["service_tier"]; ok {
+		return data
+	}
+	var err error
+	if usage["service_tier"], err = json.Marshal(tier); err != nil {
+		return data
+	}
+	if message["usage"], err = json.Marshal(usage); err != nil {
+		return data
+	}
+	if event["message"], err = json.Marshal(message); err != nil {
+		return data
+	}
+	out, err := json.Marshal(event)
+	if err != nil {
+		return data
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+)
+
+func TestHandleMessages_ServiceTier(t *testing.T) {
+	tests := []struct {
+		name         string
+		tier         string
+		softLimited  []string
+		wantStatus   int
+		wantProvider string
+		wantPicked   []string // Accounts picked on zai, sorted
+		wantTier     string
+	}{
+		{
+			name:         "auto falls back to soft-limited accounts",
+			softLimited:  []string{"b@example.com"},
+			wantStatus:   http.StatusOK,
+			wantProvider: "zai",
+			wantPicked:   []string{"a@example.com", "b@example.com"},
+			wantTier:     "standard",
+		},
+		{
+			name:         "priority skips soft-limited accounts",
+			tier:         "priority",
+			softLimited:  []string{"b@example.com"},
+			wantStatus:   http.StatusOK,
+			wantProvider: "zai",
+			wantPicked:   []string{"a@example.com"},
+			wantTier:     "priority",
+		},
+		{
+			name:         "auto reroutes when all are soft-limited",
+			tier:         "auto",
+			softLimited:  []string{"a@example.com", "b@example.com"},
+			wantStatus:   http.StatusOK,
+			wantProvider: "copilot",
+			wantTier:     "standard",
+		},
+		{
+			name:         "standard_only stays on soft-limited accounts",
+			tier:         "standard_only",
+			softLimited:  []string{"a@example.com", "b@example.com"},
+			wantStatus:   http.StatusOK,
+			wantProvider: "zai",
+			wantPicked:   []string{"a@example.com", "b@example.com"},
+			wantTier:     "standard",
+		},
+		{
+			name:        "priority without priority capacity",
+			tier:        "priority",
+			softLimited: []string{"a@example.com", "b@example.com"},
+			wantStatus:  http.StatusTooManyRequests,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := newTestManager(t, testAccount("a@example.com", "zai"), testAccount("b@example.com", "zai"), testAccount("c@example.com", "copilot"))
+			mgr.SetSoftLimitSettings(true, 0.2)
+			for _, email := range tt.softLimited {
+				mgr.UpdateSoftLimitStatus(email, "glm-4.7", 0.1)
+			}
+			registry := provider.NewRegistry()
+			providers := map[string]*pickingProvider{}
+			for _, name := range []string{"zai", "copilot"} {
+				providers[name] = &pickingProvider{mockProvider: mockProvider{name: name, models: []string{"glm-4.7"}}, accounts: mgr}
+				if err := registry.Register(providers[name]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := NewServer(registry, mgr)
+			s.SetModelEquivalents(map[string][]string{"zai/glm-4.7": {"copilot/glm-4.7"}})
+
+			body := `{"model":"zai/glm-4.7","max_tokens":16,"messages":[{"role":"user","content":"ping"}]}`
+			if tt.tier != "" {
+				body = strings.Replace(body, `{`, `{"service_tier":"`+tt.tier+`",`, 1)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			s.handleMessages(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			for name, prov := range providers {
+				if prov.called != (name == tt.wantProvider) {
+					t.Errorf("provider %s called = %v, want it called only for %q", name, prov.called, tt.wantProvider)
+				}
+			}
+			if picked := providers["zai"].picked; tt.wantPicked != nil {
+				slices.Sort(picked)
+				if !slices.Equal(picked, tt.wantPicked) {
+					t.Errorf("picked %v, want %v", picked, tt.wantPicked)
+				}
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Usage struct {
+					ServiceTier string `json:"service_tier"`
+				} `json:"usage"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Usage.ServiceTier != tt.wantTier {
+				t.Errorf("usage.service_tier = %q, want %q", resp.Usage.ServiceTier, tt.wantTier)
+			}
+		})
+	}
+}
+
+func TestSetRawMessageServiceTier(t *testing.T) {
+	data := []byte(`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":3,"output_tokens":1}}}`)
+	got := string(setRawMessageServiceTier(data, "priority"))
+	want := `{"message":{"id":"msg_1","usage":{"input_tokens":3,"output_tokens":1,"service_tier":"priority"}},"type":"message_start"}`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	noUsage := []byte(`{"type":"message_start","message":{"id":"msg_1"}}`)
+	if got := setRawMessageServiceTier(noUsage, "standard"); string(got) != string(noUsage) {
+		t.Errorf("expected an event without usage unchanged, got %s", got)
+	}
+
+	reported := []byte(`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":3,"service_tier":"standard"}}}`)
+	if got := setRawMessageServiceTier(reported, "priority"); string(got) != string(reported) {
+		t.Errorf("expected the upstream's service_tier kept, got %s", got)
+	}
+}
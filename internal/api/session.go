@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/session"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// headerSessionID names the conversation a /v1/messages request belongs to, for clients
+// that want to choose their own session boundaries.
+const headerSessionID = "X-Session-Id"
+
+type sessionKey struct{}
+
+// requestSessionID returns the store key of the conversation r belongs to: the
+// X-Session-Id header, else the session in metadata.user_id (Claude Code's
+// "..._session_<uuid>"), else the user and the first user message, which stay the same
+// across turns. It is hashed, so the store doesn't keep user IDs. "" means no session.
+func requestSessionID(r *http.Request, req *types.AnthropicRequest, user string) string {
+	id := strings.TrimSpace(r.Header.Get(headerSessionID))
+	if id != "" {
+		id = "header:" + id
+	} else if req.Metadata != nil && strings.Contains(req.Metadata.UserID, "_session_") {
+		id = "user:" + req.Metadata.UserID
+	} else if text := firstUserText(req.Messages); text != "" {
+		id = "derived:" + user + "\x00" + text
+	} else {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
+// firstUserText returns the text of the first user message that has any.
+func firstUserText(messages []types.Message) string {
+	for _, msg := range messages {
+		if msg.Role != "user" {
+			continue
+		}
+		blocks, err := types.ParseMessageContent(msg.Content)
+		if err != nil {
+			continue
+		}
+		var text strings.Builder
+		for _, block := range blocks {
+			if block.Type == "text" {
+				text.WriteString(block.Text)
+			}
+		}
+		if text.Len() > 0 {
+			return text.String()
+		}
+	}
+	return ""
+}
+
+// withSessionAffinity returns ctx carrying session id, preferring the account that served
+// the session's last turn when it used the same provider and signature family: thinking
+// signatures and prompt caches don't carry over to another model family.
+func (s *Server) withSessionAffinity(ctx context.Context, id, providerName, model string) context.Context {
+	if s.sessions == nil || id == "" {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, sessionKey{}, id)
+	e, ok := s.sessions.Get(id)
+	if !ok || e.Account == "" || e.Provider != providerName || e.SignatureFamily != string(config.GetModelFamily(model)) {
+		return ctx
+	}
+	utils.Debug("[Session] Preferring %s for session %s (last served %s)", e.Account, id, e.Model)
+	return account.WithPreferredAccount(ctx, e.Account)
+}
+
+// recordSession records the account that served a successful turn of the request's
+// session, if it has one. Turns that reached no account leave the session as it was.
+func (s *Server) recordSession(ctx context.Context, providerName, model string) {
+	id, _ := ctx.Value(sessionKey{}).(string)
+	email := provider.TraceFromContext(ctx).Account()
+	if s.sessions == nil || id == "" || email == "" {
+		return
+	}
+	s.sessions.Put(id, session.Entry{
+		Provider:        providerName,
+		Account:         email,
+		Model:           model,
+		SignatureFamily: string(config.GetModelFamily(model)),
+	})
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/session"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// accountProvider serves each request from one account picked by the account manager.
+type accountProvider struct {
+	mockProvider
+	accounts *account.Manager
+}
+
+func (p *accountProvider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	acc := p.accounts.PickNextByProviderContext(ctx, p.name, req.Model)
+	provider.TraceFromContext(ctx).Attempt(acc.Email)
+	return &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: req.Model, StopReason: "end_turn",
+		Content: []types.ContentBlock{{Type: "text", Text: acc.Email}}}, nil
+}
+
+func TestHandleMessages_SessionAffinity(t *testing.T) {
+	mgr := newTestManager(t, testAccount("a@example.com", "zai"), testAccount("b@example.com", "zai"), testAccount("c@example.com", "zai"))
+	registry := provider.NewRegistry()
+	if err := registry.Register(&accountProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, accounts: mgr}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, mgr)
+	s.SetSessionStore(session.NewStore(time.Hour, 0))
+
+	send := func(sessionID string) string {
+		r := httptest.NewRequest(http.MethodPost, "/v1/messages",
+			strings.NewReader(`{"model":"zai/glm-4.7","max_tokens":16,"messages":[{"role":"user","content":"ping"}]}`))
+		r.Header.Set("X-Session-Id", sessionID)
+		w := httptest.NewRecorder()
+		s.handleMessages(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w.Header().Get(headerAccount)
+	}
+
+	first := send("s1")
+	for range 3 {
+		if got := send("s1"); got != first {
+			t.Fatalf("session s1 moved from %s to %s", first, got)
+		}
+		send("s2") // Other sessions keep rotating through the accounts.
+	}
+
+	// A rate-limited account is left for another one, which the session then sticks to.
+	mgr.MarkRateLimited(first, 60000, "glm-4.7")
+	second := send("s1")
+	if second == first {
+		t.Fatalf("expected the session to leave rate-limited %s", first)
+	}
+	mgr.ResetAllRateLimitsByProvider("zai")
+	if got := send("s1"); got != second {
+		t.Errorf("expected the session to stay on %s, got %s", second, got)
+	}
+}
+
+func TestRequestSessionID(t *testing.T) {
+	turn := func(userID string, messages ...string) *types.AnthropicRequest {
+		req := &types.AnthropicRequest{}
+		if userID != "" {
+			req.Metadata = &types.Metadata{UserID: userID}
+		}
+		for i, text := range messages {
+			role := "user"
+			if i%2 == 1 {
+				role = "assistant"
+			}
+			req.Messages = append(req.Messages, types.Message{Role: role, Content: []byte(`"` + text + `"`)})
+		}
+		return req
+	}
+	plain := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	withHeader := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	withHeader.Header.Set("X-Session-Id", "abc")
+
+	// Later turns of a conversation keep its session.
+	if requestSessionID(plain, turn("", "hi"), "u1") != requestSessionID(plain, turn("", "hi", "hello", "more"), "u1") {
+		t.Error("expected the derived session to survive a new turn")
+	}
+	if requestSessionID(plain, turn("", "hi"), "u1") == requestSessionID(plain, turn("", "hi"), "u2") {
+		t.Error("expected different users to get different sessions")
+	}
+	claudeCode := "user_1_account_2_session_3"
+	if requestSessionID(plain, turn(claudeCode, "hi"), "user_1_account_2") != requestSessionID(plain, turn(claudeCode, "other"), "user_1_account_2") {
+		t.Error("expected the metadata session to take precedence over the messages")
+	}
+	if requestSessionID(withHeader, turn(claudeCode, "hi"), "") != requestSessionID(withHeader, turn("", "other"), "") {
+		t.Error("expected the header to take precedence")
+	}
+	if id := requestSessionID(plain, turn(""), ""); id != "" {
+		t.Errorf("expected no session without messages, got %q", id)
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// rerouteSoftLimited returns the provider, upstream model and public model ID of the first
+// equivalent of rawModel (MODEL_EQUIVALENTS) that has an account which isn't soft-limited,
+// when every usable account of prov is soft-limited for rawModel. ok is false when the
+// request should stay on prov, soft-limited accounts included.
+func (s *Server) rerouteSoftLimited(prov provider.Provider, rawModel string) (provider.Provider, string, string, bool) {
+	if s.equivalents == nil || s.accountManager == nil {
+		return nil, "", "", false
+	}
+	from := prov.Name() + "/" + rawModel
+	targets := s.equivalents[from]
+	if len(targets) == 0 || !s.accountManager.IsAllSoftLimitedByProvider(prov.Name(), rawModel) {
+		return nil, "", "", false
+	}
+	for _, target := range targets {
+		newProv, newModel, err := s.resolveProviderForModel(target)
+		if err != nil || newProv.Name() == prov.Name() || !newProv.SupportsModel(newModel) {
+			continue
+		}
+		if _, _, exhausted := s.providerOverBudget(newProv.Name()); exhausted {
+			continue
+		}
+		if !s.accountManager.HasPreferredAccountByProvider(newProv.Name(), newModel) {
+			continue
+		}
+		metrics.SoftLimitReroutes.Inc(prov.Name(), rawModel)
+		utils.Info("[Server] All %s accounts soft-limited for %s; routing to %s", prov.Name(), rawModel, target)
+		return newProv, newModel, target, true
+	}
+	return nil, "", "", false
+}
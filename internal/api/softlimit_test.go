@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+)
+
+func TestHandleMessages_SoftLimitScheduler(t *testing.T) {
+	tests := []struct {
+		name         string
+		equivalents  map[string][]string
+		softLimited  []string
+		wantProvider string
+	}{
+		{
+			name:         "provider has preferred accounts",
+			equivalents:  map[string][]string{"zai/glm-4.7": {"copilot/glm-4.7"}},
+			wantProvider: "zai",
+		},
+		{
+			name:         "all soft-limited",
+			equivalents:  map[string][]string{"zai/glm-4.7": {"vertex/glm-4.7", "copilot/glm-4.7"}},
+			softLimited:  []string{"a@example.com"},
+			wantProvider: "copilot",
+		},
+		{
+			name:         "equivalent soft-limited too",
+			equivalents:  map[string][]string{"zai/glm-4.7": {"copilot/glm-4.7"}},
+			softLimited:  []string{"a@example.com", "c@example.com"},
+			wantProvider: "zai",
+		},
+		{
+			name:         "per-provider scheduling",
+			softLimited:  []string{"a@example.com"},
+			wantProvider: "zai",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := newTestManager(t, testAccount("a@example.com", "zai"), testAccount("c@example.com", "copilot"), testAccount("v@example.com", "vertex"))
+			mgr.SetSoftLimitSettings(true, 0.2)
+			for _, email := range tt.softLimited {
+				mgr.UpdateSoftLimitStatus(email, "glm-4.7", 0.1)
+			}
+			registry := provider.NewRegistry()
+			providers := map[string]*pickingProvider{}
+			for name, models := range map[string][]string{"zai": {"glm-4.7"}, "copilot": {"glm-4.7"}, "vertex": {"claude-sonnet-4-5"}} {
+				providers[name] = &pickingProvider{mockProvider: mockProvider{name: name, models: models}, accounts: mgr}
+				if err := registry.Register(providers[name]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := NewServer(registry, mgr)
+			s.SetModelEquivalents(tt.equivalents)
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/messages",
+				strings.NewReader(`{"model":"zai/glm-4.7","max_tokens":16,"messages":[{"role":"user","content":"ping"}]}`))
+			w := httptest.NewRecorder()
+			s.handleMessages(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+			for name, prov := range providers {
+				if prov.called != (name == tt.wantProvider) {
+					t.Errorf("provider %s called = %v, want it called only for %s", name, prov.called, tt.wantProvider)
+				}
+			}
+		})
+	}
+}
@@ -7,10 +7,14 @@ import (
 	"net/http"
 )
 
+// pingEventData is the data of the ping events sent after message_start and on idle streams.
+var pingEventData = []byte(`{"type":"ping"}`)
+
 // SSEWriter wraps http.ResponseWriter to provide SSE streaming capabilities.
 type SSEWriter struct {
 	w       http.ResponseWriter
 	flusher http.Flusher
+	written int64
 }
 
 // NewSSEWriter creates a new SSE writer and configures the response for streaming.
@@ -42,7 +46,8 @@ func (s *SSEWriter) WriteEvent(eventType string, data interface{}) error {
 	}
 
 	// Format: event: <type>\ndata: <json>\n\n
-	_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", eventType, jsonData)
+	n, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", eventType, jsonData)
+	s.written += int64(n)
 	if err != nil {
 		return fmt.Errorf("failed to write event: %w", err)
 	}
@@ -58,7 +63,8 @@ func (s *SSEWriter) WriteData(data interface{}) error {
 		return fmt.Errorf("failed to marshal data: %w", err)
 	}
 
-	_, err = fmt.Fprintf(s.w, "data: %s\n\n", jsonData)
+	n, err := fmt.Fprintf(s.w, "data: %s\n\n", jsonData)
+	s.written += int64(n)
 	if err != nil {
 		return fmt.Errorf("failed to write data: %w", err)
 	}
@@ -69,7 +75,8 @@ func (s *SSEWriter) WriteData(data interface{}) error {
 
 // WriteRaw writes raw SSE data without JSON marshaling.
 func (s *SSEWriter) WriteRaw(eventType string, rawJSON []byte) error {
-	_, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", eventType, rawJSON)
+	n, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", eventType, rawJSON)
+	s.written += int64(n)
 	if err != nil {
 		return fmt.Errorf("failed to write raw event: %w", err)
 	}
@@ -78,11 +85,28 @@ func (s *SSEWriter) WriteRaw(eventType string, rawJSON []byte) error {
 	return nil
 }
 
+// WriteComment writes an SSE comment line, which clients ignore.
+func (s *SSEWriter) WriteComment(text string) error {
+	n, err := fmt.Fprintf(s.w, ": %s\n\n", text)
+	s.written += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write comment: %w", err)
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
 // Flush manually flushes the response.
 func (s *SSEWriter) Flush() {
 	s.flusher.Flush()
 }
 
+// BytesWritten returns the number of bytes written to the response so far.
+func (s *SSEWriter) BytesWritten() int64 {
+	return s.written
+}
+
 // WriteError writes an SSE error event (Node parity).
 // This is used when an error occurs after headers have been sent.
 func (s *SSEWriter) WriteError(errorType, message string) error {
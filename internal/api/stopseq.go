@@ -0,0 +1,184 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/cache"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// stopSequenceFilter enforces a request's stop_sequences on its stream, for upstreams that
+// ignore them. Text that could be the start of a stop sequence is held back until the next
+// delta settles it; at the first match the text block is cut before the sequence and the
+// message ends with stop_reason "stop_sequence", as Anthropic's API does.
+type stopSequenceFilter struct {
+	sequences []string
+	hold      int // Bytes of text held back: the longest sequence's length minus one
+
+	index   int    // Content block the pending text belongs to
+	pending string // Text held back
+	written int    // Bytes of text written, for the estimated output tokens
+	stopped bool   // A stop sequence matched; the upstream's remaining events are dropped
+}
+
+// newStopSequenceFilter returns the filter for a stream, or nil when the request has no
+// stop sequences.
+func newStopSequenceFilter(sequences []string) *stopSequenceFilter {
+	f := &stopSequenceFilter{}
+	for _, seq := range sequences {
+		if seq != "" {
+			f.sequences = append(f.sequences, seq)
+			f.hold = max(f.hold, len(seq)-1)
+		}
+	}
+	if len(f.sequences) == 0 {
+		return nil
+	}
+	return f
+}
+
+// filter returns the events to write in place of events.
+func (f *stopSequenceFilter) filter(events []cache.Event) []cache.Event {
+	if f == nil {
+		return events
+	}
+	var out []cache.Event
+	for _, ev := range events {
+		if f.stopped {
+			break
+		}
+		if ev.Type == "content_block_delta" {
+			var fields streamEventFields
+			if json.Unmarshal(ev.Data, &fields) == nil && fields.Delta != nil && fields.Delta.Type == "text_delta" {
+				out = append(out, f.text(fields.Index, fields.Delta.Text)...)
+				continue
+			}
+		}
+		out = append(out, f.flush()...)
+		out = append(out, ev)
+	}
+	return out
+}
+
+// done reports whether a stop sequence matched, so the upstream request can be cancelled.
+func (f *stopSequenceFilter) done() bool {
+	return f != nil && f.stopped
+}
+
+// discard drops the text held back, when the stream is resumed from the text written.
+func (f *stopSequenceFilter) discard() {
+	if f != nil {
+		f.pending = ""
+	}
+}
+
+// text handles a text delta of the block at index.
+func (f *stopSequenceFilter) text(index int, text string) []cache.Event {
+	var out []cache.Event
+	if index != f.index {
+		out = f.flush()
+		f.index = index
+	}
+	buf := f.pending + text
+	f.pending = ""
+
+	if at, seq, ok := matchStopSequence(buf, f.sequences); ok {
+		f.stopped = true
+		if at > 0 {
+			out = append(out, f.delta(buf[:at]))
+		}
+		return append(out, f.stop(seq)...)
+	}
+
+	// Hold back what could be the start of a sequence, without splitting a character.
+	cut := len(buf) - f.hold
+	for cut > 0 && !utf8.RuneStart(buf[cut]) {
+		cut--
+	}
+	if cut <= 0 {
+		f.pending = buf
+		return out
+	}
+	f.pending = buf[cut:]
+	return append(out, f.delta(buf[:cut]))
+}
+
+// flush writes the text held back, before any event that isn't a text delta.
+func (f *stopSequenceFilter) flush() []cache.Event {
+	if f.pending == "" {
+		return nil
+	}
+	text := f.pending
+	f.pending = ""
+	return []cache.Event{f.delta(text)}
+}
+
+func (f *stopSequenceFilter) delta(text string) cache.Event {
+	f.written += len(text)
+	data, _ := json.Marshal(types.StreamEvent{Type: "content_block_delta", Index: f.index, Delta: &types.Delta{Type: "text_delta", Text: text}})
+	return cache.Event{Type: "content_block_delta", Data: data}
+}
+
+// stop returns the events that end the message at seq. The upstream's usage never arrives,
+// so output tokens are estimated from the text written.
+func (f *stopSequenceFilter) stop(seq string) []cache.Event {
+	events := []types.StreamEvent{
+		{Type: "content_block_stop", Index: f.index},
+		{Type: "message_delta", Delta: &types.Delta{StopReason: "stop_sequence", StopSequence: seq}, Usage: &types.Usage{OutputTokens: max(1, f.written/4)}},
+		{Type: "message_stop"},
+	}
+	out := make([]cache.Event, 0, len(events))
+	for _, event := range events {
+		data, _ := json.Marshal(event)
+		out = append(out, cache.Event{Type: event.Type, Data: data})
+	}
+	return out
+}
+
+// matchStopSequence returns where the earliest stop sequence in text starts and which one.
+func matchStopSequence(text string, sequences []string) (at int, seq string, ok bool) {
+	at = -1
+	for _, candidate := range sequences {
+		if i := strings.Index(text, candidate); i >= 0 && (at < 0 || i < at) {
+			at, seq = i, candidate
+		}
+	}
+	return at, seq, at >= 0
+}
+
+// applyStopSequences cuts a non-streaming response at the first stop sequence in its text,
+// dropping the blocks after it. It reports whether the response was cut.
+func applyStopSequences(resp *types.AnthropicResponse, sequences []string) bool {
+	if resp.StopReason == "stop_sequence" {
+		return false // The upstream honored them
+	}
+	var nonEmpty []string
+	for _, seq := range sequences {
+		if seq != "" {
+			nonEmpty = append(nonEmpty, seq)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return false
+	}
+	for i, block := range resp.Content {
+		if block.Type != "text" {
+			continue
+		}
+		at, seq, ok := matchStopSequence(block.Text, nonEmpty)
+		if !ok {
+			continue
+		}
+		resp.Content = resp.Content[:i+1]
+		resp.Content[i].Text = block.Text[:at]
+		if at == 0 {
+			resp.Content = resp.Content[:i]
+		}
+		resp.StopReason = "stop_sequence"
+		resp.StopSequence = &seq
+		return true
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/cache"
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/streamcheck"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// ignoringProvider ignores stop_sequences: it answers with text and, when streaming, keeps
+// the stream open after its events until the request is cancelled.
+type ignoringProvider struct {
+	mockProvider
+	text      string
+	cancelled chan struct{}
+}
+
+func (p *ignoringProvider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	return &types.AnthropicResponse{
+		ID: "msg_1", Type: "message", Role: "assistant", Model: req.Model, StopReason: "end_turn",
+		Content: []types.ContentBlock{{Type: "text", Text: p.text}, {Type: "tool_use", ID: "t", Name: "f"}},
+		Usage:   types.Usage{InputTokens: 3, OutputTokens: 9},
+	}, nil
+}
+
+func (p *ignoringProvider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
+	ch := make(chan types.StreamEvent)
+	go func() {
+		defer close(ch)
+		events := []types.StreamEvent{
+			{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: req.Model}},
+			{Type: "content_block_start", Index: 0, ContentBlock: &types.ContentBlock{Type: "text"}},
+		}
+		// One delta per character, so stop sequences are split across deltas.
+		for _, r := range p.text {
+			events = append(events, types.StreamEvent{Type: "content_block_delta", Index: 0, Delta: &types.Delta{Type: "text_delta", Text: string(r)}})
+		}
+		for _, event := range events {
+			select {
+			case ch <- event:
+			case <-ctx.Done():
+			}
+		}
+		select {
+		case <-ctx.Done():
+			close(p.cancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}()
+	return ch, nil
+}
+
+func TestHandleStreamingMessage_StopSequences(t *testing.T) {
+	metrics.StopSequencesEnforced.Reset()
+	defer metrics.StopSequencesEnforced.Reset()
+	metrics.ClientCancellations.Reset()
+
+	registry := provider.NewRegistry()
+	prov := &ignoringProvider{
+		mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}},
+		text:         "Über 1, 2, 3\n\nHuman: go on",
+		cancelled:    make(chan struct{}),
+	}
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+
+	w := httptest.NewRecorder()
+	s.handleMessages(w, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(
+		`{"model":"zai/glm-4.7","stream":true,"stop_sequences":["\n\nHuman:","3, 4"],"messages":[{"role":"user","content":"count"}]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	events, err := streamcheck.ParseSSE(w.Body.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := streamcheck.Check(events); err != nil {
+		t.Errorf("stream violates the protocol:\n%v", err)
+	}
+	msg, err := streamcheck.Accumulate(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Content) != 1 || msg.Content[0].Text != "Über 1, 2, 3" {
+		t.Errorf("unexpected content: %+v", msg.Content)
+	}
+	if msg.StopReason != "stop_sequence" || msg.StopSequence == nil || *msg.StopSequence != "\n\nHuman:" {
+		t.Errorf("unexpected stop: %q %v", msg.StopReason, msg.StopSequence)
+	}
+
+	select {
+	case <-prov.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the upstream request to be cancelled")
+	}
+	if n := metrics.StopSequencesEnforced.Count("zai", "glm-4.7"); n != 1 {
+		t.Errorf("expected 1 enforced stop sequence, got %d", n)
+	}
+	if n := metrics.ClientCancellations.Count("zai", "glm-4.7"); n != 0 {
+		t.Errorf("expected no client cancellation, got %d", n)
+	}
+}
+
+// filterText runs text deltas and a closing content_block_stop through f and returns the
+// text written.
+func filterText(f *stopSequenceFilter, deltas ...string) string {
+	var events []types.StreamEvent
+	for _, delta := range deltas {
+		events = append(events, types.StreamEvent{Type: "content_block_delta", Index: 0, Delta: &types.Delta{Type: "text_delta", Text: delta}})
+	}
+	events = append(events, types.StreamEvent{Type: "content_block_stop", Index: 0})
+
+	var text string
+	for _, event := range events {
+		data, _ := json.Marshal(event)
+		for _, ev := range f.filter([]cache.Event{{Type: event.Type, Data: data}}) {
+			if delta, ok := streamTextDelta(ev.Data); ok {
+				text += delta
+			}
+		}
+	}
+	return text
+}
+
+func TestStopSequenceFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		deltas   []string
+		wantText string
+		wantDone bool
+	}{
+		{name: "split across deltas", deltas: []string{"ok ST", "O", "P later"}, wantText: "ok ", wantDone: true},
+		{name: "partial match released", deltas: []string{"no, STO", "NE", " here"}, wantText: "no, STONE here"},
+		{name: "held text flushed at block end", deltas: []string{"ends with ST"}, wantText: "ends with ST"},
+		{name: "match at start", deltas: []string{"STOP"}, wantText: "", wantDone: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newStopSequenceFilter([]string{"STOP", ""})
+			if got := filterText(f, tt.deltas...); got != tt.wantText || f.done() != tt.wantDone {
+				t.Errorf("text = %q, done = %v; want %q, %v", got, f.done(), tt.wantText, tt.wantDone)
+			}
+		})
+	}
+
+	if newStopSequenceFilter([]string{""}) != nil {
+		t.Error("expected no filter without stop sequences")
+	}
+}
+
+func TestApplyStopSequences(t *testing.T) {
+	prov := &ignoringProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, text: "one\ntwo END three"}
+	registry := provider.NewRegistry()
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+
+	w := httptest.NewRecorder()
+	s.handleMessages(w, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(
+		`{"model":"zai/glm-4.7","max_tokens":16,"stop_sequences":["END","two"],"messages":[{"role":"user","content":"count"}]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Content      []types.ContentBlock `json:"content"`
+		StopReason   string               `json:"stop_reason"`
+		StopSequence *string              `json:"stop_sequence"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Text != "one\n" {
+		t.Errorf("unexpected content: %+v", resp.Content)
+	}
+	if resp.StopReason != "stop_sequence" || resp.StopSequence == nil || *resp.StopSequence != "two" {
+		t.Errorf("unexpected stop: %q %v", resp.StopReason, resp.StopSequence)
+	}
+
+	honored := &types.AnthropicResponse{StopReason: "stop_sequence", Content: []types.ContentBlock{{Type: "text", Text: "a two"}}}
+	if applyStopSequences(honored, []string{"two"}) {
+		t.Error("expected a response that already stopped at a sequence to be left alone")
+	}
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/streamcheck"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// slowProvider streams its events with a delay after message_start.
+type slowProvider struct {
+	mockProvider
+	delay time.Duration
+}
+
+func (p *slowProvider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
+	ch := make(chan types.StreamEvent)
+	go func() {
+		defer close(ch)
+		for i, event := range p.streamEvents {
+			if i == 1 {
+				time.Sleep(p.delay)
+			}
+			ch <- event
+		}
+	}()
+	return ch, nil
+}
+
+// streamFrom serves a streaming /v1/messages request from prov and returns the parsed events.
+func streamFrom(t *testing.T, prov provider.Provider, pingInterval time.Duration) []streamcheck.Event {
+	t.Helper()
+	registry := provider.NewRegistry()
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+	s.pingInterval = pingInterval
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages",
+		strings.NewReader(`{"model":"zai/glm-4.7","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	w := httptest.NewRecorder()
+	s.handleMessages(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	events, err := streamcheck.ParseSSE(w.Body.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func eventTypes(events []streamcheck.Event) []string {
+	names := make([]string, len(events))
+	for i, ev := range events {
+		names[i] = ev.Type
+	}
+	return names
+}
+
+func TestHandleStreamingMessage_Conformance(t *testing.T) {
+	start := types.StreamEvent{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: "glm-4.7"}}
+	text := []types.StreamEvent{
+		start,
+		{Type: "content_block_start", Index: 0, ContentBlock: &types.ContentBlock{Type: "text"}},
+		{Type: "content_block_delta", Index: 0, Delta: &types.Delta{Type: "text_delta", Text: "hi"}},
+		{Type: "content_block_stop", Index: 0},
+	}
+	end := []types.StreamEvent{
+		{Type: "message_delta", Delta: &types.Delta{StopReason: "end_turn"}, Usage: &types.Usage{OutputTokens: 1}},
+		{Type: "message_stop"},
+	}
+
+	tests := []struct {
+		name      string
+		events    []types.StreamEvent
+		wantTypes string
+		wantError string // error type of the final error event
+	}{
+		{
+			name:      "complete stream",
+			events:    append(append([]types.StreamEvent{}, text...), end...),
+			wantTypes: "message_start ping content_block_start content_block_delta content_block_stop message_delta message_stop",
+		},
+		{
+			name: "overloaded error event",
+			events: append(append([]types.StreamEvent{}, text...),
+				types.StreamEvent{Type: "error", Error: &types.ErrorDetail{Type: "stream_error", Message: "upstream returned 529: Overloaded"}},
+				types.StreamEvent{Type: "message_stop"}),
+			wantTypes: "message_start ping content_block_start content_block_delta content_block_stop error",
+			wantError: "overloaded_error",
+		},
+		{
+			name: "raw error event",
+			events: []types.StreamEvent{start, {Type: "error", Raw: map[string]interface{}{
+				"type":  "error",
+				"error": map[string]interface{}{"type": "stream_error", "message": "unexpected EOF"},
+			}}},
+			wantTypes: "message_start ping error",
+			wantError: "api_error",
+		},
+		{
+			name:      "truncated stream",
+			events:    text,
+			wantTypes: "message_start ping content_block_start content_block_delta content_block_stop error",
+			wantError: "api_error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := streamFrom(t, &mockProvider{name: "zai", models: []string{"glm-4.7"}, streamEvents: tt.events}, 0)
+
+			if err := streamcheck.Check(events); err != nil {
+				t.Errorf("stream violates the protocol:\n%v", err)
+			}
+			if got := strings.Join(eventTypes(events), " "); got != tt.wantTypes {
+				t.Errorf("events = %s, want %s", got, tt.wantTypes)
+			}
+			if tt.wantError != "" {
+				var payload types.AnthropicError
+				if err := json.Unmarshal(events[len(events)-1].Data, &payload); err != nil {
+					t.Fatal(err)
+				}
+				if payload.Error.Type != tt.wantError {
+					t.Errorf("error type = %s, want %s", payload.Error.Type, tt.wantError)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleStreamingMessage_FastPath(t *testing.T) {
+	raw := func(data string) types.StreamEvent {
+		var head struct{ Type string }
+		if err := json.Unmarshal([]byte(data), &head); err != nil {
+			t.Fatal(err)
+		}
+		return types.StreamEvent{Type: head.Type, Raw: json.RawMessage(data)}
+	}
+	delta := `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`
+	prov := &mockProvider{name: "zai", models: []string{"glm-4.7"}, streamEvents: []types.StreamEvent{
+		raw(`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"glm-4.7","content":[],"usage":{"input_tokens":3,"output_tokens":1}}}`),
+		raw(`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`),
+		raw(delta),
+		raw(`{"type":"content_block_stop","index":0}`),
+		raw(`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":1}}`),
+		raw(`{"type":"message_stop"}`),
+	}}
+
+	events := streamFrom(t, prov, 0)
+	if err := streamcheck.Check(events); err != nil {
+		t.Errorf("stream violates the protocol:\n%v", err)
+	}
+	var start struct {
+		Message struct {
+			ID    string `json:"id"`
+			Model string `json:"model"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(events[0].Data, &start); err != nil {
+		t.Fatal(err)
+	}
+	if start.Message.Model != "zai/glm-4.7" || start.Message.ID != "msg_1" {
+		t.Errorf("message_start message = %+v, want the public model and the upstream id", start.Message)
+	}
+	if got := string(events[3].Data); got != delta {
+		t.Errorf("content_block_delta = %s, want the upstream bytes %s", got, delta)
+	}
+}
+
+func TestHandleStreamingMessage_IdlePings(t *testing.T) {
+	prov := &slowProvider{
+		mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}, streamEvents: []types.StreamEvent{
+			{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: "glm-4.7"}},
+			{Type: "message_delta", Delta: &types.Delta{StopReason: "end_turn"}, Usage: &types.Usage{}},
+			{Type: "message_stop"},
+		}},
+		delay: 100 * time.Millisecond,
+	}
+
+	events := streamFrom(t, prov, 20*time.Millisecond)
+	if err := streamcheck.Check(events); err != nil {
+		t.Errorf("stream violates the protocol:\n%v", err)
+	}
+	pings := 0
+	for _, ev := range events {
+		if ev.Type == "ping" {
+			pings++
+		}
+	}
+	if pings < 3 {
+		t.Errorf("expected idle pings while the provider was silent, got %v", eventTypes(events))
+	}
+}
+
+// slowStartProvider takes a while to start its stream, as when waiting for an account.
+type slowStartProvider struct {
+	mockProvider
+	delay time.Duration
+	err   error
+}
+
+func (p *slowStartProvider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
+	time.Sleep(p.delay)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.mockProvider.SendMessageStream(ctx, req)
+}
+
+func TestHandleStreamingMessage_PingsWhileStarting(t *testing.T) {
+	events := []types.StreamEvent{
+		{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: "glm-4.7"}},
+		{Type: "message_delta", Delta: &types.Delta{StopReason: "end_turn"}, Usage: &types.Usage{}},
+		{Type: "message_stop"},
+	}
+
+	t.Run("slow start", func(t *testing.T) {
+		prov := &slowStartProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}, streamEvents: events}, delay: 100 * time.Millisecond}
+		got := streamFrom(t, prov, 20*time.Millisecond)
+		if err := streamcheck.Check(got); err != nil {
+			t.Errorf("stream violates the protocol:\n%v", err)
+		}
+		if len(got) < 3 || got[0].Type != "ping" || got[1].Type != "ping" {
+			t.Errorf("expected pings before message_start, got %v", eventTypes(got))
+		}
+	})
+
+	t.Run("slow failure is an error event", func(t *testing.T) {
+		prov := &slowStartProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, delay: 60 * time.Millisecond,
+			err: merrors.RateLimitError("All accounts are rate-limited")}
+		got := streamFrom(t, prov, 20*time.Millisecond)
+		if err := streamcheck.Check(got); err != nil {
+			t.Errorf("stream violates the protocol:\n%v", err)
+		}
+		if last := got[len(got)-1]; last.Type != "error" || !strings.Contains(string(last.Data), "rate_limit_error") {
+			t.Errorf("expected a rate_limit_error event, got %v", eventTypes(got))
+		}
+	})
+
+	t.Run("fast failure is an HTTP error", func(t *testing.T) {
+		registry := provider.NewRegistry()
+		if err := registry.Register(&slowStartProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}},
+			err: merrors.RateLimitError("All accounts are rate-limited")}); err != nil {
+			t.Fatal(err)
+		}
+		s := NewServer(registry, nil)
+		s.pingInterval = time.Second
+		w := httptest.NewRecorder()
+		s.handleMessages(w, httptest.NewRequest(http.MethodPost, "/v1/messages",
+			strings.NewReader(`{"model":"zai/glm-4.7","stream":true,"messages":[{"role":"user","content":"hi"}]}`)))
+		if w.Code != http.StatusTooManyRequests || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			t.Errorf("expected a 429 JSON response, got %d %s", w.Code, w.Header().Get("Content-Type"))
+		}
+	})
+}
+
+// stalledProvider starts a stream and then waits for its context to end, like an upstream
+// that is still thinking.
+type stalledProvider struct {
+	mockProvider
+	started   chan struct{}
+	cancelled chan struct{}
+}
+
+func (p *stalledProvider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
+	ch := make(chan types.StreamEvent)
+	go func() {
+		defer close(ch)
+		ch <- types.StreamEvent{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: req.Model}}
+		close(p.started)
+		<-ctx.Done()
+		close(p.cancelled)
+	}()
+	return ch, nil
+}
+
+func TestHandleStreamingMessage_ClientDisconnect(t *testing.T) {
+	prov := &stalledProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, started: make(chan struct{}), cancelled: make(chan struct{})}
+	registry := provider.NewRegistry()
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+	before := metrics.ClientCancellations.Count("zai", "glm-4.7")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages",
+		strings.NewReader(`{"model":"zai/glm-4.7","stream":true,"messages":[{"role":"user","content":"hi"}]}`)).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.handleMessages(httptest.NewRecorder(), req)
+	}()
+
+	<-prov.started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler didn't return after the client disconnected")
+	}
+	select {
+	case <-prov.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream context wasn't cancelled")
+	}
+	if got := metrics.ClientCancellations.Count("zai", "glm-4.7") - before; got != 1 {
+		t.Errorf("proxy_client_cancellations_total increased by %d, want 1", got)
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// activeStream is a /v1/messages stream in progress. Counters are updated by the handler
+// writing the stream and read by the admin endpoint.
+type activeStream struct {
+	id       string
+	provider string
+	model    string
+	user     string
+	trace    *provider.Trace
+	started  time.Time
+
+	events    atomic.Int64
+	written   atomic.Int64 // bytes sent to the client
+	buffered  atomic.Int64 // bytes of events held in memory for the response cache
+	lastEvent atomic.Int64 // unix nanoseconds; 0 until the first event
+
+	killOnce sync.Once
+	killed   chan struct{} // closed by kill
+}
+
+// observe records an event written to the client. buffered is the size of the event if
+// it was kept for the response cache.
+func (a *activeStream) observe(written int64, buffered int) {
+	a.events.Add(1)
+	a.written.Store(written)
+	a.buffered.Add(int64(buffered))
+	a.lastEvent.Store(time.Now().UnixNano())
+}
+
+func (a *activeStream) kill() {
+	a.killOnce.Do(func() { close(a.killed) })
+}
+
+// streamInfo is an active stream as listed by GET /admin/streams.
+type streamInfo struct {
+	ID            string    `json:"id"`
+	Provider      string    `json:"provider"`
+	Model         string    `json:"model"`
+	Account       string    `json:"account,omitempty"`
+	User          string    `json:"user,omitempty"`
+	StartedAt     time.Time `json:"startedAt"`
+	DurationMs    int64     `json:"durationMs"`
+	IdleMs        int64     `json:"idleMs"` // since the last event, or the start if none was sent yet
+	Events        int64     `json:"events"`
+	BytesWritten  int64     `json:"bytesWritten"`
+	BufferedBytes int64     `json:"bufferedBytes"`
+}
+
+func (a *activeStream) info(now time.Time) streamInfo {
+	last := a.started
+	if ns := a.lastEvent.Load(); ns != 0 {
+		last = time.Unix(0, ns)
+	}
+	return streamInfo{
+		ID:            a.id,
+		Provider:      a.provider,
+		Model:         a.model,
+		Account:       a.trace.Account(),
+		User:          a.user,
+		StartedAt:     a.started.UTC(),
+		DurationMs:    now.Sub(a.started).Milliseconds(),
+		IdleMs:        now.Sub(last).Milliseconds(),
+		Events:        a.events.Load(),
+		BytesWritten:  a.written.Load(),
+		BufferedBytes: a.buffered.Load(),
+	}
+}
+
+// streamRegistry tracks active streams by request ID so they can be listed and terminated.
+type streamRegistry struct {
+	mu      sync.Mutex
+	streams map[string]*activeStream
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{streams: make(map[string]*activeStream)}
+}
+
+// add registers a stream; the caller must remove it when the stream ends.
+func (r *streamRegistry) add(id, providerName, model, user string, trace *provider.Trace) *activeStream {
+	a := &activeStream{
+		id:       id,
+		provider: providerName,
+		model:    model,
+		user:     user,
+		trace:    trace,
+		started:  time.Now(),
+		killed:   make(chan struct{}),
+	}
+	r.mu.Lock()
+	r.streams[id] = a
+	r.mu.Unlock()
+	return a
+}
+
+func (r *streamRegistry) remove(a *activeStream) {
+	r.mu.Lock()
+	if r.streams[a.id] == a {
+		delete(r.streams, a.id)
+	}
+	r.mu.Unlock()
+}
+
+// list returns the active streams, longest-running first.
+func (r *streamRegistry) list() []streamInfo {
+	now := time.Now()
+	r.mu.Lock()
+	out := make([]streamInfo, 0, len(r.streams))
+	for _, a := range r.streams {
+		out = append(out, a.info(now))
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// kill terminates the stream with the given request ID, reporting whether it was active.
+func (r *streamRegistry) kill(id string) (streamInfo, bool) {
+	r.mu.Lock()
+	a, ok := r.streams[id]
+	r.mu.Unlock()
+	if !ok {
+		return streamInfo{}, false
+	}
+	a.kill()
+	return a.info(time.Now()), true
+}
+
+// handleStreams handles /admin/streams and /admin/streams/{id}.
+//
+//	GET    /admin/streams       lists active /v1/messages streams with their duration and size
+//	DELETE /admin/streams/{id}  terminates the stream with that X-MCP-Request-Id
+//
+// A terminated stream ends with an error event and its upstream request is cancelled;
+// other streams are not affected.
+func (s *Server) handleStreams(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	resp := map[string]interface{}{"status": "ok"}
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		resp["streams"] = s.streams.list()
+	case r.Method == http.MethodDelete && id != "":
+		info, ok := s.streams.kill(id)
+		if !ok {
+			writeAdminError(w, http.StatusNotFound, "No active stream with request ID "+id)
+			return
+		}
+		utils.Warn("[Streams] Terminating %s stream %s for %s after %s", info.Provider, id, info.Model,
+			utils.FormatDuration(time.Duration(info.DurationMs)*time.Millisecond))
+		resp["stream"] = info
+	default:
+		s.handleNotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/streamcheck"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// stuckProvider sends message_start and then nothing until its request is cancelled.
+type stuckProvider struct {
+	mockProvider
+	cancelled chan struct{}
+}
+
+func (p *stuckProvider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
+	ch := make(chan types.StreamEvent)
+	go func() {
+		defer close(ch)
+		select {
+		case ch <- types.StreamEvent{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: "glm-4.7"}}:
+		case <-ctx.Done():
+		}
+		<-ctx.Done()
+		close(p.cancelled)
+	}()
+	return ch, nil
+}
+
+func TestHandleStreams_ListAndTerminate(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+	prov := &stuckProvider{mockProvider: mockProvider{name: "zai", models: []string{"glm-4.7"}}, cancelled: make(chan struct{})}
+	registry := provider.NewRegistry()
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+	s.pingInterval = 0
+	handler := s.Handler()
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("x-api-key", "test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	streamRec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages",
+			strings.NewReader(`{"model":"zai/glm-4.7","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+		s.handleMessages(streamRec, req)
+	}()
+
+	var streams []streamInfo
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		streams = s.streams.list()
+		if len(streams) == 1 && streams[0].Events > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream was not registered: %+v", streams)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	w := do(http.MethodGet, "/admin/streams")
+	var listed struct {
+		Streams []streamInfo `json:"streams"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Streams) != 1 || listed.Streams[0].Provider != "zai" || listed.Streams[0].Model != "glm-4.7" || listed.Streams[0].BytesWritten == 0 {
+		t.Fatalf("unexpected stream listing: %s", w.Body.String())
+	}
+	id := listed.Streams[0].ID
+
+	w = do(http.MethodDelete, "/admin/streams/"+id)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 terminating the stream, got %d: %s", w.Code, w.Body.String())
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream handler did not return after termination")
+	}
+	select {
+	case <-prov.cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request was not cancelled")
+	}
+
+	if got := streamRec.Header().Get(headerRequestID); got != id {
+		t.Errorf("expected %s header %s, got %q", headerRequestID, id, got)
+	}
+	events, err := streamcheck.ParseSSE(streamRec.Body.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := events[len(events)-1]; last.Type != "error" || !strings.Contains(string(last.Data), "terminated") {
+		t.Errorf("expected the stream to end with an error event, got %s %s", last.Type, last.Data)
+	}
+	if n := len(s.streams.list()); n != 0 {
+		t.Errorf("expected no active streams, got %d", n)
+	}
+
+	w = do(http.MethodDelete, "/admin/streams/"+id)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a finished stream, got %d", w.Code)
+	}
+}
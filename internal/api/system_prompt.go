@@ -0,0 +1,48 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// applySystemPrompt adds the operator's system prompt (SYSTEM_PROMPT_*) to req: the prefix
+// as the first system block, the suffix as the last, and without the client's blocks when
+// cfg.StripClient is set. The client's blocks are kept as sent, including cache_control.
+// A system prompt that is neither a string nor an array is left alone.
+func applySystemPrompt(req *types.AnthropicRequest, cfg config.SystemPromptConfig) {
+	if !cfg.Enabled() {
+		return
+	}
+
+	var blocks []json.RawMessage
+	if system := bytes.TrimSpace(req.System); len(system) > 0 && !cfg.StripClient && !bytes.Equal(system, []byte("null")) {
+		var text string
+		if err := json.Unmarshal(system, &text); err == nil {
+			if text != "" {
+				blocks = append(blocks, textBlock(text))
+			}
+		} else if err := json.Unmarshal(system, &blocks); err != nil {
+			return
+		}
+	}
+
+	if cfg.Prefix != "" {
+		blocks = append([]json.RawMessage{textBlock(cfg.Prefix)}, blocks...)
+	}
+	if cfg.Suffix != "" {
+		blocks = append(blocks, textBlock(cfg.Suffix))
+	}
+	if len(blocks) == 0 {
+		req.System = nil
+		return
+	}
+	req.System, _ = json.Marshal(blocks)
+}
+
+func textBlock(text string) json.RawMessage {
+	data, _ := json.Marshal(types.SystemBlock{Type: "text", Text: text})
+	return data
+}
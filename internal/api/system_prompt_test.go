@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestApplySystemPrompt(t *testing.T) {
+	tests := []struct {
+		name   string
+		system string
+		cfg    config.SystemPromptConfig
+		want   string
+	}{
+		{"disabled", `"client"`, config.SystemPromptConfig{}, `"client"`},
+		{"string", `"client"`, config.SystemPromptConfig{Prefix: "pre", Suffix: "post"},
+			`[{"type":"text","text":"pre"},{"type":"text","text":"client"},{"type":"text","text":"post"}]`},
+		{"blocks keep cache_control", `[{"type":"text","text":"client","cache_control":{"type":"ephemeral"}}]`, config.SystemPromptConfig{Prefix: "pre"},
+			`[{"type":"text","text":"pre"},{"type":"text","text":"client","cache_control":{"type":"ephemeral"}}]`},
+		{"no client prompt", ``, config.SystemPromptConfig{Suffix: "post"}, `[{"type":"text","text":"post"}]`},
+		{"strip", `"client"`, config.SystemPromptConfig{StripClient: true, Prefix: "pre"}, `[{"type":"text","text":"pre"}]`},
+		{"strip only", `"client"`, config.SystemPromptConfig{StripClient: true}, ``},
+		{"invalid left alone", `42`, config.SystemPromptConfig{Prefix: "pre"}, `42`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &types.AnthropicRequest{}
+			if tt.system != "" {
+				req.System = json.RawMessage(tt.system)
+			}
+			applySystemPrompt(req, tt.cfg)
+			if got := string(req.System); got != tt.want {
+				t.Errorf("system = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
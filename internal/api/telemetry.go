@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/telemetry"
+)
+
+// SetTelemetry counts /v1/messages requests for the opt-in telemetry report and serves its
+// preview on GET /admin/telemetry. Pass nil to disable both.
+func (s *Server) SetTelemetry(r *telemetry.Reporter) {
+	s.telemetry = r
+}
+
+// TelemetryCounts counts /v1/messages requests per provider for the telemetry report.
+// A nil reporter disables it.
+func TelemetryCounts(rep *telemetry.Reporter, next http.Handler) http.Handler {
+	if rep == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		stats := requestStatsFromContext(r.Context())
+		if stats == nil {
+			stats = &requestStats{}
+			r = r.WithContext(withRequestStats(r.Context(), stats))
+		}
+
+		next.ServeHTTP(rw, r)
+		rep.Record(stats.Provider, rw.statusCode)
+	})
+}
+
+// handleTelemetry handles GET /admin/telemetry: whether telemetry is enabled, where reports
+// go and the exact payload the next report would send. The preview works while telemetry
+// is disabled, so the data can be reviewed before opting in.
+func (s *Server) handleTelemetry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.handleNotFound(w, r)
+		return
+	}
+	if s.telemetry == nil {
+		writeAdminError(w, http.StatusNotImplemented, "Telemetry is not available")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "ok",
+		"enabled":  s.telemetry.Enabled(),
+		"endpoint": s.telemetry.Endpoint(),
+		"payload":  s.telemetry.Preview(),
+	})
+}
@@ -0,0 +1,103 @@
+// Runs the official Anthropic TypeScript SDK against the scenarios served by
+// newSDKCompatServer in sdk_compat_test.go. Started by TestSDKCompat_TypeScript with
+// ANTHROPIC_BASE_URL and ANTHROPIC_API_KEY set; exits non-zero if any scenario fails.
+import Anthropic from '@anthropic-ai/sdk';
+
+const client = new Anthropic({ maxRetries: 0 });
+const params = (model) => ({
+  model: `zai/${model}`,
+  max_tokens: 256,
+  messages: [{ role: 'user', content: 'Hi' }],
+});
+
+function assert(cond, message) {
+  if (!cond) throw new Error(message);
+}
+
+async function expectError(fn, ErrorClass, status, type) {
+  try {
+    await fn();
+  } catch (err) {
+    assert(err instanceof ErrorClass, `expected ${ErrorClass.name}, got ${err?.constructor?.name}: ${err?.message}`);
+    if (status !== undefined) assert(err.status === status, `expected status ${status}, got ${err.status}`);
+    const errType = err.error?.error?.type;
+    assert(errType === type, `expected error type ${type}, got ${errType}`);
+    return;
+  }
+  throw new Error(`expected ${ErrorClass.name}, but the call succeeded`);
+}
+
+const scenarios = {
+  async 'create text'() {
+    const msg = await client.messages.create(params('sdk-text'));
+    assert(msg.content[0].text === 'Hello, world', `text = ${JSON.stringify(msg.content)}`);
+    assert(msg.stop_reason === 'end_turn', `stop_reason = ${msg.stop_reason}`);
+  },
+
+  async 'stream text'() {
+    let text = '';
+    const stream = client.messages.stream(params('sdk-text')).on('text', (delta) => { text += delta; });
+    const msg = await stream.finalMessage();
+    assert(text === 'Hello, world', `streamed text = ${text}`);
+    assert(msg.content[0].text === 'Hello, world', `final text = ${JSON.stringify(msg.content)}`);
+    assert(msg.usage.output_tokens === 5, `usage = ${JSON.stringify(msg.usage)}`);
+  },
+
+  async 'stream tool use'() {
+    const msg = await client.messages.stream(params('sdk-tool')).finalMessage();
+    const tool = msg.content.find((block) => block.type === 'tool_use');
+    assert(tool?.name === 'get_weather', `content = ${JSON.stringify(msg.content)}`);
+    assert(JSON.stringify(tool.input) === JSON.stringify({ city: 'Paris', units: 'metric' }), `input = ${JSON.stringify(tool.input)}`);
+    assert(msg.stop_reason === 'tool_use', `stop_reason = ${msg.stop_reason}`);
+  },
+
+  async 'raw stream events'() {
+    const types = [];
+    const stream = await client.messages.create({ ...params('sdk-text'), stream: true });
+    for await (const event of stream) types.push(event.type);
+    assert(types[0] === 'message_start' && types.at(-1) === 'message_stop', `events = ${types}`);
+  },
+
+  async 'mid-stream overload'() {
+    await expectError(() => client.messages.stream(params('sdk-midstream')).finalMessage(),
+      Anthropic.APIError, undefined, 'overloaded_error');
+  },
+
+  async 'overloaded'() {
+    await expectError(() => client.messages.create(params('sdk-overloaded')),
+      Anthropic.InternalServerError, 503, 'overloaded_error');
+  },
+
+  async 'overloaded stream'() {
+    await expectError(() => client.messages.stream(params('sdk-overloaded')).finalMessage(),
+      Anthropic.InternalServerError, 503, 'overloaded_error');
+  },
+
+  async 'rate limited'() {
+    await expectError(() => client.messages.create(params('sdk-ratelimit')),
+      Anthropic.RateLimitError, 429, 'rate_limit_error');
+  },
+
+  async 'rate limited stream'() {
+    await expectError(() => client.messages.stream(params('sdk-ratelimit')).finalMessage(),
+      Anthropic.RateLimitError, 429, 'rate_limit_error');
+  },
+
+  async 'bad api key'() {
+    const other = new Anthropic({ apiKey: 'wrong-key', maxRetries: 0 });
+    await expectError(() => other.messages.create(params('sdk-text')),
+      Anthropic.AuthenticationError, 401, 'authentication_error');
+  },
+};
+
+let failed = 0;
+for (const [name, run] of Object.entries(scenarios)) {
+  try {
+    await run();
+    console.log(`ok   ${name}`);
+  } catch (err) {
+    failed++;
+    console.log(`FAIL ${name}: ${err.message}`);
+  }
+}
+process.exit(failed ? 1 : 0);
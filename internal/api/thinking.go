@@ -0,0 +1,77 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// thinkingSuffix marks models that think by default, e.g. claude-sonnet-4-5-thinking.
+const thinkingSuffix = "-thinking"
+
+// normalizeThinking applies the thinking policy (THINKING_*) to req for a provider's model
+// when THINKING_POLICY_PROVIDERS lists the provider: thinking is removed for models that
+// don't support it, enabled with the default budget for -thinking models when the client
+// left it out, and otherwise has its budget clamped to the model's bounds. A budget always
+// leaves MinThinkingOutputTokens of max_tokens for the answer; thinking that can't fit with
+// at least the model's minimum budget is left out. req.Thinking is replaced, never modified,
+// since it is shared with the client's request.
+func normalizeThinking(req *types.AnthropicRequest, prov provider.Provider, cfg config.ThinkingPolicyConfig) {
+	providerName, model := prov.Name(), req.Model
+	if !cfg.Enabled(providerName) {
+		return
+	}
+	if cfg.Unsupported(providerName, model) {
+		if req.Thinking != nil {
+			utils.Info("[Thinking] %s/%s does not support thinking; removing it from the request", providerName, model)
+			req.Thinking = nil
+		}
+		return
+	}
+
+	budget := cfg.ForModel(providerName, model)
+	if req.Thinking == nil {
+		if !strings.HasSuffix(model, thinkingSuffix) {
+			return
+		}
+		n, ok := fitThinkingBudget(budget, 0, req.MaxTokens)
+		if !ok {
+			utils.Debug("[Thinking] max_tokens %d of %s/%s leaves no room for thinking; not enabling it", req.MaxTokens, providerName, model)
+			return
+		}
+		req.Thinking = &types.ThinkingConfig{Type: "enabled", BudgetTokens: n}
+		utils.Debug("[Thinking] Enabled thinking for %s/%s with a budget of %d tokens", providerName, model, n)
+		return
+	}
+
+	if req.Thinking.Type != "enabled" {
+		return
+	}
+	n, ok := fitThinkingBudget(budget, req.Thinking.BudgetTokens, req.MaxTokens)
+	if !ok {
+		utils.Info("[Thinking] max_tokens %d of %s/%s leaves no room for a thinking budget of at least %d tokens; removing thinking",
+			req.MaxTokens, providerName, model, budget.Min)
+		req.Thinking = nil
+		return
+	}
+	if n != req.Thinking.BudgetTokens {
+		utils.Info("[Thinking] Thinking budget for %s/%s adjusted from %d to %d tokens (min %d, max %d)",
+			providerName, model, req.Thinking.BudgetTokens, n, budget.Min, budget.Max)
+		thinking := *req.Thinking
+		thinking.BudgetTokens = n
+		req.Thinking = &thinking
+	}
+}
+
+// fitThinkingBudget clamps a requested budget to b and caps it so MinThinkingOutputTokens of
+// maxTokens are left for the answer. ok is false when that cap is below b.Min.
+func fitThinkingBudget(b config.ThinkingBudget, requested, maxTokens int) (n int, ok bool) {
+	n = b.Clamp(requested)
+	if maxTokens > 0 {
+		n = min(n, maxTokens-config.MinThinkingOutputTokens)
+	}
+	return n, n >= b.Min
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestNormalizeThinking(t *testing.T) {
+	t.Setenv("THINKING_BUDGET_MIN", "glm-4.7=2048")
+	t.Setenv("THINKING_BUDGET_MAX", "*=32000")
+	t.Setenv("THINKING_BUDGET_DEFAULT", "")
+	t.Setenv("THINKING_UNSUPPORTED_MODELS", "zai/glm-4.5-air")
+	t.Setenv("THINKING_POLICY_PROVIDERS", "antigravity,zai")
+	cfg := config.GetThinkingPolicyConfig()
+
+	antigravity := &mockProvider{name: "antigravity", models: []string{"claude-sonnet-4-5", "claude-sonnet-4-5-thinking"}}
+	zai := &mockProvider{name: "zai", models: []string{"glm-4.7", "glm-4.5-air"}}
+	copilot := &mockProvider{name: "copilot", models: []string{"claude-sonnet-4-thinking"}}
+	enabled := func(n int) *types.ThinkingConfig { return &types.ThinkingConfig{Type: "enabled", BudgetTokens: n} }
+
+	tests := []struct {
+		name      string
+		prov      *mockProvider
+		model     string
+		maxTokens int
+		thinking  *types.ThinkingConfig
+		want      *types.ThinkingConfig
+	}{
+		{"auto-enabled", antigravity, "claude-sonnet-4-5-thinking", 64000, nil, enabled(config.DefaultThinkingBudget)},
+		// The answer keeps MinThinkingOutputTokens of max_tokens.
+		{"auto-enabled below max_tokens", antigravity, "claude-sonnet-4-5-thinking", 8192, nil, enabled(8192 - config.MinThinkingOutputTokens)},
+		{"not auto-enabled without room", antigravity, "claude-sonnet-4-5-thinking", 1500, nil, nil},
+		{"not auto-enabled", zai, "glm-4.7", 4096, nil, nil},
+		{"clamped up", zai, "glm-4.7", 4096, enabled(500), enabled(2048)},
+		{"clamped down", antigravity, "claude-sonnet-4-5-thinking", 64000, enabled(50000), enabled(32000)},
+		{"default budget", zai, "glm-4.7", 64000, enabled(0), enabled(config.DefaultThinkingBudget)},
+		{"kept", zai, "glm-4.7", 4096, enabled(3000), enabled(3000)},
+		{"capped below max_tokens", zai, "glm-4.7", 4096, enabled(4000), enabled(4096 - config.MinThinkingOutputTokens)},
+		{"removed without room", zai, "glm-4.7", 2048, enabled(500), nil},
+		{"disabled kept", zai, "glm-4.7", 4096, &types.ThinkingConfig{Type: "disabled"}, &types.ThinkingConfig{Type: "disabled"}},
+		{"kept for non-thinking variant", antigravity, "claude-sonnet-4-5", 4096, enabled(2000), enabled(2000)},
+		{"stripped by config", zai, "glm-4.5-air", 4096, enabled(2000), nil},
+		{"provider not opted in", copilot, "claude-sonnet-4-thinking", 4096, enabled(100), enabled(100)},
+		{"not auto-enabled for provider not opted in", copilot, "claude-sonnet-4-thinking", 4096, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var original types.ThinkingConfig
+			if tt.thinking != nil {
+				original = *tt.thinking
+			}
+			req := &types.AnthropicRequest{Model: tt.model, MaxTokens: tt.maxTokens, Thinking: tt.thinking}
+			normalizeThinking(req, tt.prov, cfg)
+			switch {
+			case tt.want == nil && req.Thinking != nil:
+				t.Errorf("thinking = %+v, want none", *req.Thinking)
+			case tt.want != nil && (req.Thinking == nil || *req.Thinking != *tt.want):
+				t.Errorf("thinking = %+v, want %+v", req.Thinking, *tt.want)
+			}
+			if tt.thinking != nil && *tt.thinking != original {
+				t.Errorf("the client's thinking block was modified: %+v", *tt.thinking)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+)
+
+// timeoutOr returns the TimeoutError that ended ctx (REQUEST_TIMEOUT and the per-phase
+// deadlines), or err if no deadline did.
+func timeoutOr(ctx context.Context, err error) error {
+	if te := merrors.TimeoutCause(ctx); te != nil {
+		return te
+	}
+	return err
+}
+
+// streamDeadline enforces FIRST_TOKEN_TIMEOUT and STREAM_TIMEOUT on a stream by cancelling
+// its upstream request with a TimeoutError as the cause.
+type streamDeadline struct {
+	start      time.Time
+	stream     time.Duration
+	cancel     context.CancelCauseFunc
+	timer      *time.Timer
+	firstToken bool // The timer is the first token deadline
+}
+
+// newStreamDeadline starts the deadlines of a stream starting now.
+func newStreamDeadline(timeouts config.RequestTimeouts, cancel context.CancelCauseFunc) *streamDeadline {
+	d := &streamDeadline{start: time.Now(), stream: timeouts.Stream, cancel: cancel}
+	switch {
+	case timeouts.FirstToken > 0 && (timeouts.Stream <= 0 || timeouts.FirstToken < timeouts.Stream):
+		d.firstToken = true
+		d.arm(merrors.PhaseFirstToken, timeouts.FirstToken)
+	case timeouts.Stream > 0:
+		d.arm(merrors.PhaseStream, timeouts.Stream)
+	}
+	return d
+}
+
+func (d *streamDeadline) arm(phase string, timeout time.Duration) {
+	te := &merrors.TimeoutError{Phase: phase, Timeout: timeout}
+	d.timer = time.AfterFunc(time.Until(d.start.Add(timeout)), func() { d.cancel(te) })
+}
+
+// firstEvent lifts the first token deadline once the stream's first event arrived; the
+// stream deadline still applies.
+func (d *streamDeadline) firstEvent() {
+	if !d.firstToken {
+		return
+	}
+	d.firstToken = false
+	d.timer.Stop()
+	d.timer = nil
+	if d.stream > 0 {
+		d.arm(merrors.PhaseStream, d.stream)
+	}
+}
+
+func (d *streamDeadline) stop() {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// hangingProvider answers with its stream events, if any, then hangs until the request is
+// cancelled.
+type hangingProvider struct {
+	mockProvider
+}
+
+func (p *hangingProvider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *hangingProvider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
+	ch := make(chan types.StreamEvent, len(p.streamEvents))
+	for _, event := range p.streamEvents {
+		ch <- event
+	}
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+func TestHandleMessages_Timeouts(t *testing.T) {
+	start := types.StreamEvent{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Model: "glm-4.7"}}
+
+	t.Run("request timeout", func(t *testing.T) {
+		t.Setenv("REQUEST_TIMEOUT", "50ms")
+		registry := provider.NewRegistry()
+		if err := registry.Register(&hangingProvider{mockProvider{name: "zai", models: []string{"glm-4.7"}}}); err != nil {
+			t.Fatal(err)
+		}
+		s := NewServer(registry, nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages",
+			strings.NewReader(`{"model":"zai/glm-4.7","messages":[{"role":"user","content":"hi"}]}`))
+		w := httptest.NewRecorder()
+		s.handleMessages(w, req)
+
+		if w.Code != http.StatusGatewayTimeout {
+			t.Fatalf("expected 504, got %d: %s", w.Code, w.Body.String())
+		}
+		var payload types.AnthropicError
+		if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Error.Type != "api_error" || !strings.Contains(payload.Error.Message, "request deadline") {
+			t.Errorf("unexpected error: %+v", payload.Error)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		env       string
+		events    []types.StreamEvent
+		wantTypes string
+		wantPhase string
+	}{
+		{"first token timeout", "FIRST_TOKEN_TIMEOUT", nil, "error", "first token"},
+		{"stream timeout", "ZAI_STREAM_TIMEOUT", []types.StreamEvent{start}, "message_start ping error", "stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, "50ms")
+			events := streamFrom(t, &hangingProvider{mockProvider{name: "zai", models: []string{"glm-4.7"}, streamEvents: tt.events}}, 0)
+
+			if got := strings.Join(eventTypes(events), " "); got != tt.wantTypes {
+				t.Fatalf("events = %s, want %s", got, tt.wantTypes)
+			}
+			var payload types.AnthropicError
+			if err := json.Unmarshal(events[len(events)-1].Data, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Error.Type != "api_error" || !strings.Contains(payload.Error.Message, tt.wantPhase+" deadline") {
+				t.Errorf("unexpected error: %+v", payload.Error)
+			}
+		})
+	}
+}
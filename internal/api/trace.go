@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// Response headers describing proxy-side work for a /v1/messages request, so clients can
+// tell queuing and retries in the proxy apart from a slow upstream, and correlate failures
+// with the account that served them.
+const (
+	headerProvider            = "X-MCP-Provider"              // Provider that served the request
+	headerAccount             = "X-MCP-Account"               // Account of the last attempt; unset for providers without accounts
+	headerQueueWaitMs         = "X-MCP-Queue-Wait-Ms"         // Concurrency queueing, rate-limit cooldowns and retry backoff
+	headerAttempts            = "X-MCP-Attempts"              // Upstream requests made
+	headerRateLimitedAccounts = "X-MCP-Rate-Limited-Accounts" // Distinct accounts that returned 429
+)
+
+var traceHeaders = []string{headerProvider, headerAccount, headerQueueWaitMs, headerAttempts, headerRateLimitedAccounts}
+
+// writeTraceHeaders sets the trace headers (or, for streams, the declared trailers), along
+// with the anthropic-ratelimit-* headers of the last upstream response.
+func writeTraceHeaders(h http.Header, providerName string, t *provider.Trace) {
+	h.Set(headerProvider, providerName)
+	if account := t.Account(); account != "" {
+		h.Set(headerAccount, account)
+	}
+	h.Set(headerQueueWaitMs, strconv.FormatInt(t.Waited().Milliseconds(), 10))
+	h.Set(headerAttempts, strconv.Itoa(t.Attempts()))
+	h.Set(headerRateLimitedAccounts, strconv.Itoa(t.RateLimitedAccounts()))
+	for name, values := range t.RateLimit() {
+		if name != "Retry-After" { // only meaningful on a 429; see setRetryAfter
+			h[name] = values
+		}
+	}
+}
+
+// declareTraceTrailers announces the trace headers as trailers. Streaming responses send
+// their headers before the upstream is contacted, so the values follow the body instead.
+func declareTraceTrailers(h http.Header) {
+	h.Set("Trailer", strings.Join(traceHeaders, ", ")+", "+strings.Join(provider.AnthropicRateLimitHeaders, ", "))
+}
+
+// writeTraceComment ends a stream with an SSE comment carrying the trace headers
+// (STREAM_TRACE_COMMENT), for clients that can't read trailers. SSE parsers ignore comments.
+func writeTraceComment(sse *SSEWriter, providerName string, t *provider.Trace) {
+	if !config.GetStreamTraceComment() {
+		return
+	}
+	comment := fmt.Sprintf("proxy provider=%s account=%s attempts=%d queue_wait_ms=%d rate_limited_accounts=%d",
+		providerName, t.Account(), t.Attempts(), t.Waited().Milliseconds(), t.RateLimitedAccounts())
+	if err := sse.WriteComment(comment); err != nil {
+		utils.Error("[Messages] Failed to write SSE trace comment: %v", err)
+	}
+}
+
+// setRetryAfter sets Retry-After on a 429 to the time until an account of the provider is
+// available for the model, so clients back off for as long as the proxy would have to.
+// Without a known wait it falls back to the upstream's own Retry-After, if any.
+func (s *Server) setRetryAfter(h http.Header, providerName, model string, t *provider.Trace) {
+	var waitMs int64
+	if s.accountManager != nil {
+		waitMs = s.accountManager.GetMinWaitTimeMsByProvider(providerName, model)
+	}
+	if waitMs > 0 {
+		h.Set("Retry-After", strconv.FormatInt(int64(math.Ceil(float64(waitMs)/1000)), 10))
+		return
+	}
+	if v := t.RateLimit().Get("Retry-After"); v != "" {
+		h.Set("Retry-After", v)
+	}
+}
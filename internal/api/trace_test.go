@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// retryingProvider simulates a provider that hit a rate-limited account and waited
+// before succeeding on a second attempt.
+type retryingProvider struct {
+	*mockProvider
+}
+
+func (p *retryingProvider) simulateRetry(ctx context.Context) {
+	trace := provider.TraceFromContext(ctx)
+	trace.Attempt("a@example.com")
+	trace.RateLimited("a@example.com")
+	trace.Wait(1500 * time.Millisecond)
+	trace.Attempt("b@example.com")
+	trace.RecordRateLimit(http.Header{
+		"Anthropic-Ratelimit-Requests-Remaining": {"41"},
+		"X-Ratelimit-Limit-Tokens":               {"100000"},
+		"Retry-After":                            {"7"},
+	})
+}
+
+func (p *retryingProvider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	p.simulateRetry(ctx)
+	return &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: req.Model}, nil
+}
+
+func (p *retryingProvider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
+	p.simulateRetry(ctx)
+	return p.mockProvider.SendMessageStream(ctx, req)
+}
+
+func TestMessages_TraceHeaders(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+	t.Setenv("STREAM_TRACE_COMMENT", "true")
+
+	registry := provider.NewRegistry()
+	prov := &retryingProvider{&mockProvider{
+		name:   "zai",
+		models: []string{"glm-4.7"},
+		streamEvents: []types.StreamEvent{
+			{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant"}},
+			{Type: "message_stop"},
+		},
+	}}
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewServer(registry, nil).Handler())
+	defer srv.Close()
+
+	post := func(stream bool) (*http.Response, string) {
+		body := `{"model":"zai/glm-4.7","messages":[{"role":"user","content":"hi"}]}`
+		if stream {
+			body = `{"model":"zai/glm-4.7","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+		}
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/messages", strings.NewReader(body))
+		req.Header.Set("x-api-key", "test-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(resp.Body) // trailers are available once the body is read
+		resp.Body.Close()
+		return resp, string(data)
+	}
+
+	want := map[string]string{
+		headerProvider:                           "zai",
+		headerAccount:                            "b@example.com",
+		headerQueueWaitMs:                        "1500",
+		headerAttempts:                           "2",
+		headerRateLimitedAccounts:                "1",
+		"Anthropic-Ratelimit-Requests-Remaining": "41",
+		"Anthropic-Ratelimit-Tokens-Limit":       "100000",
+	}
+
+	resp, _ := post(false)
+	for name, value := range want {
+		if got := resp.Header.Get(name); got != value {
+			t.Errorf("non-streaming %s = %q, want %q", name, got, value)
+		}
+	}
+	if got := resp.Header.Get("Retry-After"); got != "" {
+		t.Errorf("successful response has Retry-After %q", got)
+	}
+
+	resp, body := post(true)
+	for name, value := range want {
+		if got := resp.Trailer.Get(name); got != value {
+			t.Errorf("streaming trailer %s = %q, want %q", name, got, value)
+		}
+	}
+	// STREAM_TRACE_COMMENT repeats them after message_stop, for clients that can't read trailers.
+	comment := ": proxy provider=zai account=b@example.com attempts=2 queue_wait_ms=1500 rate_limited_accounts=1\n\n"
+	if !strings.HasSuffix(body, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"+comment) {
+		t.Errorf("expected the stream to end with the trace comment, got:\n%s", body)
+	}
+}
+
+// rateLimitedProvider fails every request with an upstream 429.
+type rateLimitedProvider struct {
+	*mockProvider
+}
+
+func (p *rateLimitedProvider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	provider.TraceFromContext(ctx).RecordRateLimit(http.Header{"Retry-After": {"12"}})
+	return nil, merrors.RateLimitError("Rate limited")
+}
+
+func TestMessages_RetryAfterOnRateLimit(t *testing.T) {
+	t.Setenv("PROXY_API_KEY", "test-key")
+
+	registry := provider.NewRegistry()
+	if err := registry.Register(&rateLimitedProvider{&mockProvider{name: "zai", models: []string{"glm-4.7"}}}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewServer(registry, nil).Handler())
+	defer srv.Close()
+
+	body := `{"model":"zai/glm-4.7","messages":[{"role":"user","content":"hi"}]}`
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/messages", strings.NewReader(body))
+	req.Header.Set("x-api-key", "test-key")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", resp.StatusCode)
+	}
+	// Without an account manager there is no known wait, so the upstream's value is used.
+	if got := resp.Header.Get("Retry-After"); got != "12" {
+		t.Errorf("Retry-After = %q, want %q", got, "12")
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	got := provider.RateLimitHeaders(http.Header{
+		"Anthropic-Ratelimit-Tokens-Remaining": {"900"},
+		"X-Ratelimit-Remaining-Tokens":         {"1"}, // the Anthropic header wins
+		"X-Ratelimit-Remaining-Requests":       {"59"},
+		"X-Ratelimit-Reset-Requests":           {"1m30s"},
+		"X-Ratelimit-Reset-Tokens":             {"soon"}, // not a duration; dropped
+		"Content-Type":                         {"application/json"},
+	}, now)
+
+	want := http.Header{
+		"Anthropic-Ratelimit-Tokens-Remaining":   {"900"},
+		"Anthropic-Ratelimit-Requests-Remaining": {"59"},
+		"Anthropic-Ratelimit-Requests-Reset":     {"2026-01-02T03:05:35Z"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("RateLimitHeaders = %v, want %v", got, want)
+	}
+	for name := range want {
+		if got.Get(name) != want.Get(name) {
+			t.Errorf("%s = %q, want %q", name, got.Get(name), want.Get(name))
+		}
+	}
+
+	if h := provider.RateLimitHeaders(http.Header{"Content-Type": {"text/plain"}}, now); h != nil {
+		t.Errorf("RateLimitHeaders without rate-limit headers = %v, want nil", h)
+	}
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// maxUserLength caps the user IDs kept for usage accounting.
+const maxUserLength = 128
+
+// accountingUser returns the usage-accounting user for a metadata.user_id. Claude Code
+// sends "user_<hash>_account_<uuid>_session_<uuid>"; the session part is dropped so all
+// sessions of one user add up.
+func accountingUser(userID string) string {
+	if i := strings.Index(userID, "_session_"); i > 0 {
+		userID = userID[:i]
+	}
+	if len(userID) > maxUserLength {
+		userID = userID[:maxUserLength]
+	}
+	return userID
+}
+
+// hashUserID returns the opaque user ID sent upstream in place of metadata.user_id, so
+// upstreams can tell users apart without learning who they are.
+func hashUserID(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// recordingProvider remembers the last request it was sent.
+type recordingProvider struct {
+	*mockProvider
+	last *types.AnthropicRequest
+}
+
+func (p *recordingProvider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	p.last = req
+	return &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: req.Model,
+		Usage: types.Usage{InputTokens: 10, CacheReadInputTokens: 90, OutputTokens: 5}}, nil
+}
+
+func TestAccountingUser(t *testing.T) {
+	tests := []struct {
+		userID string
+		want   string
+	}{
+		{"user_abc_account_123_session_456", "user_abc_account_123"},
+		{"alice@example.com", "alice@example.com"},
+		{"_session_only", "_session_only"},
+		{strings.Repeat("x", 200), strings.Repeat("x", maxUserLength)},
+	}
+	for _, tt := range tests {
+		if got := accountingUser(tt.userID); got != tt.want {
+			t.Errorf("accountingUser(%q) = %q, want %q", tt.userID, got, tt.want)
+		}
+	}
+}
+
+func TestMessages_MetadataUserID(t *testing.T) {
+	registry := provider.NewRegistry()
+	prov := &recordingProvider{mockProvider: &mockProvider{name: "zai", models: []string{"glm-4.7"}}}
+	if err := registry.Register(prov); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+
+	stats := &requestStats{}
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(
+		`{"model":"zai/glm-4.7","metadata":{"user_id":"user_abc_account_1_session_2"},"messages":[{"role":"user","content":"hi"}]}`))
+	req = req.WithContext(withRequestStats(context.Background(), stats))
+	w := httptest.NewRecorder()
+	s.handleMessages(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if prov.last.Metadata == nil || prov.last.Metadata.UserID != hashUserID("user_abc_account_1_session_2") {
+		t.Errorf("expected hashed user ID upstream, got %+v", prov.last.Metadata)
+	}
+	if stats.User != "user_abc_account_1" || stats.InputTokens != 100 {
+		t.Errorf("unexpected request stats: %+v", stats)
+	}
+}
+
+func TestRequestLog_UserTotals(t *testing.T) {
+	log := newRequestLog(10)
+	now := time.Now().UTC()
+	log.add(recentRequest{Timestamp: now, User: "a", Status: 200, InputTokens: 100, OutputTokens: 10})
+	log.add(recentRequest{Timestamp: now, User: "b", Status: 429})
+	log.add(recentRequest{Timestamp: now.Add(time.Second), User: "a", Status: 200, InputTokens: 50, OutputTokens: 5})
+	log.add(recentRequest{Timestamp: now, Status: 200, InputTokens: 1000})
+
+	users := log.userTotals()
+	if len(users) != 2 {
+		t.Fatalf("expected 2 users, got %+v", users)
+	}
+	if a := users[0]; a.User != "a" || a.Requests != 2 || a.InputTokens != 150 || a.OutputTokens != 15 || !a.LastSeen.Equal(now.Add(time.Second)) {
+		t.Errorf("unexpected totals for a: %+v", a)
+	}
+	if b := users[1]; b.User != "b" || b.Requests != 1 || b.Errors != 1 {
+		t.Errorf("unexpected totals for b: %+v", b)
+	}
+}
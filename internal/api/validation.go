@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// requestValidationError lists every field of a request body that failed validation, so a
+// client sees all of its mistakes at once instead of the proxy quietly using defaults.
+type requestValidationError struct {
+	problems []string // "field: problem", in the order the fields are checked
+}
+
+func (e *requestValidationError) Error() string {
+	if len(e.problems) == 1 {
+		return e.problems[0]
+	}
+	return fmt.Sprintf("%d validation errors: %s", len(e.problems), strings.Join(e.problems, "; "))
+}
+
+// requestValidator collects the problems of the fields of a JSON object.
+type requestValidator struct {
+	raw      map[string]json.RawMessage
+	problems []string
+}
+
+// decode unmarshals field into v when it is present and not null, recording why it can't be.
+// Reports whether a value was decoded.
+func (v *requestValidator) decode(field string, dst interface{}) bool {
+	data, ok := v.raw[field]
+	if !ok || string(data) == "null" {
+		return false
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		v.problems = append(v.problems, describeFieldError(field, err))
+		return false
+	}
+	return true
+}
+
+// check records problem for field when failed is true.
+func (v *requestValidator) check(failed bool, field, problem string) {
+	if failed {
+		v.problems = append(v.problems, field+": "+problem)
+	}
+}
+
+// err returns the collected problems as a *requestValidationError, or nil if there are none.
+func (v *requestValidator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &requestValidationError{problems: v.problems}
+}
+
+// describeFieldError words an unmarshal error of field like Anthropic's validation errors,
+// e.g. "temperature: Input should be a valid number, got string".
+func describeFieldError(field string, err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if !stderrors.As(err, &typeErr) {
+		return fmt.Sprintf("%s: %v", field, err)
+	}
+	if typeErr.Field != "" {
+		field += "." + typeErr.Field
+	}
+	got, _, _ := strings.Cut(typeErr.Value, " ")
+	return fmt.Sprintf("%s: Input should be %s, got %s", field, describeJSONType(typeErr.Type), got)
+}
+
+// describeJSONType names the JSON type a Go type is decoded from.
+func describeJSONType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a valid string"
+	case reflect.Bool:
+		return "a valid boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a valid integer"
+	case reflect.Float32, reflect.Float64:
+		return "a valid number"
+	case reflect.Slice, reflect.Array:
+		return "a valid list"
+	default:
+		return "a valid dictionary"
+	}
+}
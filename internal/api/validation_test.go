@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestParseMessagesRequest_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string // Error message; empty for a valid request
+	}{
+		{"valid", `{"model":"m","messages":[],"max_tokens":10,"temperature":0.5,"top_k":5,"stream":null}`, ""},
+		{"wrong type", `{"messages":[],"temperature":"0.5"}`,
+			"temperature: Input should be a valid number, got string"},
+		{"all problems", `{"messages":[{"role":1}],"max_tokens":"many","stream":"yes","top_k":1.5,"top_p":2}`,
+			"5 validation errors: messages.0.role: Input should be a valid string, got number; " +
+				"max_tokens: Input should be a valid integer, got string; stream: Input should be a valid boolean, got string; " +
+				"top_k: Input should be a valid integer, got number; top_p: Input should be between 0 and 1"},
+		{"out of range", `{"messages":[],"max_tokens":-1,"temperature":-0.1}`,
+			"2 validation errors: max_tokens: Input should be greater than or equal to 1; temperature: Input should be greater than or equal to 0"},
+		{"zero max_tokens", `{"messages":[],"max_tokens":0}`, "max_tokens: Input should be greater than or equal to 1"},
+		{"missing max_tokens", `{"messages":[],"max_tokens":null}`, ""},
+		{"wrong container", `{"messages":[],"metadata":[],"stop_sequences":"END"}`,
+			"2 validation errors: stop_sequences: Input should be a valid list, got string; metadata: Input should be a valid dictionary, got array"},
+		{"unknown service tier", `{"messages":[],"service_tier":"fast"}`,
+			"service_tier: Input should be 'auto', 'standard_only' or 'priority'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseMessagesRequest([]byte(tt.body))
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.want != "" && (err == nil || err.Error() != tt.want):
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleMessages_ValidationError(t *testing.T) {
+	registry := provider.NewRegistry()
+	if err := registry.Register(&mockProvider{name: "zai", models: []string{"glm-4.7"}}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(registry, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages",
+		strings.NewReader(`{"model":"zai/glm-4.7","messages":[{"role":"user","content":"hi"}],"temperature":"hot","top_k":-1}`))
+	w := httptest.NewRecorder()
+	s.handleMessages(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	var payload types.AnthropicError
+	if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+	want := "2 validation errors: temperature: Input should be a valid number, got string; top_k: Input should be greater than or equal to 0"
+	if payload.Error.Type != "invalid_request_error" || payload.Error.Message != want {
+		t.Errorf("unexpected error: %+v", payload.Error)
+	}
+}
@@ -0,0 +1,275 @@
+// Package audit records request/response metadata for /v1 endpoints to rotating JSONL files.
+// Bodies are optional and always redacted before they are written.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// LogFileName is the name of the active audit log file inside the audit directory.
+const LogFileName = "audit.jsonl"
+
+// Entry is a single audit record.
+type Entry struct {
+	Timestamp     time.Time         `json:"timestamp"`
+	RequestID     string            `json:"requestId"`
+	Method        string            `json:"method"`
+	Path          string            `json:"path"`
+	RemoteAddr    string            `json:"remoteAddr,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Model         string            `json:"model,omitempty"`
+	User          string            `json:"user,omitempty"` // metadata.user_id of /v1/messages, without the session
+	Stream        bool              `json:"stream,omitempty"`
+	Status        int               `json:"status"`
+	DurationMs    int64             `json:"durationMs"`
+	RequestBytes  int64             `json:"requestBytes"`
+	ResponseBytes int64             `json:"responseBytes"`
+	RequestBody   json.RawMessage   `json:"requestBody,omitempty"`
+	ResponseBody  json.RawMessage   `json:"responseBody,omitempty"`
+
+	// Bodies cut off at the capture limit can't be redacted structurally, so they are
+	// logged as a {"truncated":true,"bytes":N} marker instead.
+	RequestTruncated  bool `json:"requestTruncated,omitempty"`
+	ResponseTruncated bool `json:"responseTruncated,omitempty"`
+
+	// Token usage of /v1/messages responses; input includes cached tokens and streams are
+	// timed from their first event.
+	InputTokens     int     `json:"inputTokens,omitempty"`
+	OutputTokens    int     `json:"outputTokens,omitempty"`
+	TokensPerSecond float64 `json:"tokensPerSecond,omitempty"`
+
+	// Annotations added by the content policy filters (POLICY_FILTER_*).
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// Logger writes audit entries as JSON lines. It is safe for concurrent use.
+type Logger struct {
+	mu        sync.Mutex
+	out       *rotatingFile
+	logBodies bool
+}
+
+// New creates an audit logger writing into cfg.Dir.
+// Returns (nil, nil) when auditing is disabled.
+func New(cfg config.AuditConfig) (*Logger, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	maxBytes := int64(cfg.MaxSizeMB) * 1024 * 1024
+	if maxBytes <= 0 {
+		maxBytes = 50 * 1024 * 1024
+	}
+	out, err := openRotatingFile(filepath.Join(cfg.Dir, LogFileName), maxBytes, cfg.MaxBackups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Logger{out: out, logBodies: cfg.LogBodies}, nil
+}
+
+// LogBodies reports whether request/response bodies should be captured.
+func (l *Logger) LogBodies() bool {
+	return l != nil && l.logBodies
+}
+
+// Log writes an entry. Bodies are redacted here so callers can pass raw bytes.
+func (l *Logger) Log(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	if !l.logBodies {
+		e.RequestBody = nil
+		e.ResponseBody = nil
+	} else {
+		e.RequestBody = loggedBody(e.RequestBody, e.RequestTruncated, e.RequestBytes)
+		e.ResponseBody = loggedBody(e.ResponseBody, e.ResponseTruncated, e.ResponseBytes)
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.out.Write(line)
+	return err
+}
+
+// ErrNotFound is returned by Find when no audit entry has the requested ID.
+var ErrNotFound = errors.New("audit entry not found")
+
+// Find returns the entry with requestID, searching the active log and then its rotated
+// backups, newest first. Entries rotated out of the backups can't be found.
+func (l *Logger) Find(requestID string) (*Entry, error) {
+	if l == nil {
+		return nil, ErrNotFound
+	}
+	paths := []string{l.out.path}
+	for i := 1; i <= l.out.maxBackups; i++ {
+		paths = append(paths, fmt.Sprintf("%s.%d", l.out.path, i))
+	}
+
+	needle := []byte(`"requestId":"` + requestID + `"`)
+	for _, path := range paths {
+		entry, err := findInFile(path, requestID, needle)
+		if err != nil || entry != nil {
+			return entry, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func findInFile(path, requestID string, needle []byte) (*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	// Lines can be several MB when bodies are logged, so read them whole rather than
+	// through a bufio.Scanner with a fixed token limit.
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if bytes.Contains(line, needle) {
+			var e Entry
+			if json.Unmarshal(line, &e) == nil && e.RequestID == requestID {
+				return &e, nil
+			}
+		}
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+}
+
+// Close flushes and closes the underlying file.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.out.Close()
+}
+
+// loggedBody is the redacted body, or the marker of a truncated one of size bytes.
+func loggedBody(body []byte, truncated bool, size int64) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if truncated {
+		return json.RawMessage(fmt.Sprintf(`{"truncated":true,"bytes":%d}`, size))
+	}
+	return redactedBody(body)
+}
+
+// redactedBody redacts a captured body and makes sure it is valid JSON.
+// Non-JSON bodies (e.g. SSE streams) are stored as a JSON string.
+func redactedBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	redacted := RedactBody(body)
+	if json.Valid(redacted) {
+		return redacted
+	}
+	quoted, err := json.Marshal(string(redacted))
+	if err != nil {
+		return nil
+	}
+	return quoted
+}
+
+// rotatingFile is an append-only file that rotates to name.1, name.2, ... once it exceeds maxBytes.
+type rotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func openRotatingFile(path string, maxBytes int64, maxBackups int) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	rf.file = f
+	rf.size = info.Size()
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxBytes {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+
+	if rf.maxBackups <= 0 {
+		os.Remove(rf.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", rf.path, rf.maxBackups))
+		for i := rf.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+		}
+		if err := os.Rename(rf.path, rf.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+
+	return rf.open()
+}
+
+func (rf *rotatingFile) Close() error {
+	if rf.file == nil {
+		return nil
+	}
+	return rf.file.Close()
+}
@@ -0,0 +1,250 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		mustContain []string
+		mustNotHave []string
+	}{
+		{
+			name:        "base64 image source",
+			body:        `{"messages":[{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgoAAAANSUhEUgAAAAEAAAAB"}}]}]}`,
+			mustContain: []string{`"media_type":"image/png"`, "bytes omitted"},
+			mustNotHave: []string{"iVBORw0KGgo"},
+		},
+		{
+			name:        "google inline data",
+			body:        `{"inlineData":{"mimeType":"image/jpeg","data":"/9j/4AAQSkZJRgABAQ"}}`,
+			mustContain: []string{"bytes omitted"},
+			mustNotHave: []string{"/9j/4AAQ"},
+		},
+		{
+			name:        "sensitive keys",
+			body:        `{"api_key":"abc","refreshToken":"def","model":"claude"}`,
+			mustContain: []string{`"model":"claude"`, Redacted},
+			mustNotHave: []string{`"abc"`, `"def"`},
+		},
+		{
+			name:        "secret in free text",
+			body:        `{"text":"my key is sk-ant-REDACTED please"}`,
+			mustContain: []string{"my key is " + Redacted},
+			mustNotHave: []string{"abcdefghijklmnop"},
+		},
+		{
+			name:        "data url in text",
+			body:        `{"text":"see data:image/png;base64,AAAABBBBCCCC"}`,
+			mustContain: []string{"data:image/png;base64,["},
+			mustNotHave: []string{"AAAABBBBCCCC"},
+		},
+		{
+			name:        "non-JSON SSE body",
+			body:        "event: message\ndata: {\"token\":\"x\"} Bearer abc.def\n\n",
+			mustContain: []string{"event: message", Redacted},
+			mustNotHave: []string{"abc.def"},
+		},
+		{
+			name:        "usage tokens are kept",
+			body:        `{"usage":{"input_tokens":10,"output_tokens":5}}`,
+			mustContain: []string{`"input_tokens":10`, `"output_tokens":5`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(RedactBody([]byte(tt.body)))
+			for _, s := range tt.mustContain {
+				if !strings.Contains(got, s) {
+					t.Errorf("RedactBody() = %s, want it to contain %q", got, s)
+				}
+			}
+			for _, s := range tt.mustNotHave {
+				if strings.Contains(got, s) {
+					t.Errorf("RedactBody() = %s, must not contain %q", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set("X-API-Key", "secret")
+	h.Set("Anthropic-Version", "2023-06-01")
+
+	got := RedactHeaders(h)
+	if got["Authorization"] != Redacted || got["X-Api-Key"] != Redacted {
+		t.Errorf("credentials not redacted: %v", got)
+	}
+	if got["Anthropic-Version"] != "2023-06-01" {
+		t.Errorf("Anthropic-Version = %q, want 2023-06-01", got["Anthropic-Version"])
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	logger, err := New(config.AuditConfig{Enabled: false})
+	if err != nil || logger != nil {
+		t.Fatalf("New(disabled) = %v, %v; want nil, nil", logger, err)
+	}
+	// Nil logger must be safe to use.
+	if err := logger.Log(Entry{}); err != nil {
+		t.Errorf("nil Log() error = %v", err)
+	}
+	if err := logger.Close(); err != nil {
+		t.Errorf("nil Close() error = %v", err)
+	}
+}
+
+func TestLogger_WritesAndRotates(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := New(config.AuditConfig{Enabled: true, Dir: dir, LogBodies: true, MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	// Shrink the limit so rotation triggers quickly.
+	logger.out.maxBytes = 512
+
+	for i := 0; i < 10; i++ {
+		err := logger.Log(Entry{
+			Method:      "POST",
+			Path:        "/v1/messages",
+			Status:      200,
+			RequestBody: []byte(`{"model":"claude","x-api-key":"secret-value"}`),
+		})
+		if err != nil {
+			t.Fatalf("Log() error = %v", err)
+		}
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, LogFileName+".1")); err != nil {
+		t.Errorf("expected rotated backup: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LogFileName+".3")); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 backups, stat err = %v", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, LogFileName))
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lines := 0
+	for scanner.Scan() {
+		lines++
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid JSON line: %v", err)
+		}
+		if strings.Contains(string(e.RequestBody), "secret-value") {
+			t.Errorf("request body not redacted: %s", e.RequestBody)
+		}
+	}
+	if lines == 0 {
+		t.Error("expected entries in active audit log")
+	}
+}
+
+func TestLogger_OmitsBodiesWhenDisabled(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := New(config.AuditConfig{Enabled: true, Dir: dir, LogBodies: false, MaxSizeMB: 1})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	logger.Log(Entry{Path: "/v1/messages", RequestBody: []byte(`{"model":"m"}`)})
+	logger.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, LogFileName))
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	if strings.Contains(string(data), "requestBody") {
+		t.Errorf("body logged although disabled: %s", data)
+	}
+}
+
+func TestLogger_TruncatedBodies(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := New(config.AuditConfig{Enabled: true, Dir: dir, LogBodies: true, MaxSizeMB: 1})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	logger.Log(Entry{
+		Path:              "/v1/messages",
+		RequestBytes:      5 << 20,
+		RequestBody:       []byte(`{"model":"m","apiKey":"plain-secret","messages":[{"source":{"type":"base64","data":"iVBORw0KGgo`),
+		RequestTruncated:  true,
+		ResponseBytes:     9,
+		ResponseBody:      []byte(`{"ok":tr`),
+		ResponseTruncated: true,
+	})
+	logger.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, LogFileName))
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		t.Fatal(err)
+	}
+	if string(e.RequestBody) != `{"truncated":true,"bytes":5242880}` || string(e.ResponseBody) != `{"truncated":true,"bytes":9}` {
+		t.Errorf("expected truncation markers, got %s / %s", e.RequestBody, e.ResponseBody)
+	}
+	if strings.Contains(string(data), "plain-secret") || strings.Contains(string(data), "iVBOR") {
+		t.Errorf("truncated body content logged: %s", data)
+	}
+}
+
+func TestLogger_Find(t *testing.T) {
+	logger, err := New(config.AuditConfig{Enabled: true, Dir: t.TempDir(), LogBodies: true, MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer logger.Close()
+	logger.out.maxBytes = 512
+
+	for i := 0; i < 10; i++ {
+		logger.Log(Entry{
+			RequestID:   fmt.Sprintf("req-%d", i),
+			Path:        "/v1/messages",
+			RequestBody: []byte(fmt.Sprintf(`{"model":"m","n":%d}`, i)),
+		})
+	}
+
+	// req-9 is in the active log, req-7 in a rotated backup.
+	for _, id := range []string{"req-9", "req-7"} {
+		e, err := logger.Find(id)
+		if err != nil {
+			t.Fatalf("Find(%s) error = %v", id, err)
+		}
+		if e.RequestID != id || !strings.Contains(string(e.RequestBody), `"n":`+id[4:]) {
+			t.Errorf("Find(%s) = %+v", id, e)
+		}
+	}
+	if _, err := logger.Find("req-0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected rotated-out entry to be gone, got %v", err)
+	}
+	if _, err := logger.Find("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
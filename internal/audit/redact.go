@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secret values in audit output.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are JSON object keys (lowercased, without separators) whose values are always redacted.
+var sensitiveKeys = map[string]bool{
+	"apikey":        true,
+	"xapikey":       true,
+	"authorization": true,
+	"accesstoken":   true,
+	"refreshtoken":  true,
+	"idtoken":       true,
+	"token":         true,
+	"secret":        true,
+	"clientsecret":  true,
+	"password":      true,
+}
+
+// sensitiveHeaders are request headers whose values are never written.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"X-Api-Key":           true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+var (
+	// secretPatterns match well-known credential formats inside free text.
+	secretPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`sk-[A-Za-z0-9_\-]{16,}`),
+		regexp.MustCompile(`gh[opsu]_[A-Za-z0-9]{20,}`),
+		regexp.MustCompile(`ya29\.[A-Za-z0-9_\-\.]+`),
+		regexp.MustCompile(`1//[A-Za-z0-9_\-]{20,}`),
+		regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9_\-\.=]+`),
+	}
+	dataURLPattern = regexp.MustCompile(`data:([a-zA-Z0-9.+/\-]+);base64,[A-Za-z0-9+/=]+`)
+)
+
+// RedactHeaders returns a flat copy of the headers with credentials removed.
+func RedactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		canonical := http.CanonicalHeaderKey(name)
+		if sensitiveHeaders[canonical] {
+			out[canonical] = Redacted
+			continue
+		}
+		out[canonical] = RedactText(strings.Join(values, ", "))
+	}
+	return out
+}
+
+// RedactBody redacts API keys and base64 image payloads from a request or response body.
+// JSON bodies are walked structurally; anything else is treated as free text.
+func RedactBody(body []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []byte(RedactText(string(body)))
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return []byte(RedactText(string(body)))
+	}
+	return out
+}
+
+// RedactText scrubs credentials and inline base64 data URLs from a string.
+func RedactText(s string) string {
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return dataURLPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := dataURLPattern.FindStringSubmatch(m)
+		return fmt.Sprintf("data:%s;base64,[%d bytes omitted]", sub[1], len(m))
+	})
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return redactObject(val)
+	case []interface{}:
+		for i := range val {
+			val[i] = redactValue(val[i])
+		}
+		return val
+	case string:
+		return RedactText(val)
+	default:
+		return v
+	}
+}
+
+func redactObject(obj map[string]interface{}) map[string]interface{} {
+	// Anthropic image/document sources: {"type":"base64","media_type":"...","data":"..."}
+	// Google inline data: {"mimeType":"...","data":"..."}
+	_, hasMime := obj["mimeType"]
+	isBase64Source := obj["type"] == "base64" || hasMime
+
+	for key, value := range obj {
+		if sensitiveKeys[normalizeKey(key)] {
+			obj[key] = Redacted
+			continue
+		}
+		if key == "data" && isBase64Source {
+			if s, ok := value.(string); ok {
+				obj[key] = fmt.Sprintf("[base64 %d bytes omitted]", len(s))
+				continue
+			}
+		}
+		obj[key] = redactValue(value)
+	}
+	return obj
+}
+
+func normalizeKey(key string) string {
+	key = strings.ToLower(key)
+	key = strings.ReplaceAll(key, "_", "")
+	return strings.ReplaceAll(key, "-", "")
+}
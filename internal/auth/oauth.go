@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/egress"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 )
 
@@ -260,15 +261,25 @@ func ExchangeCode(code string, verifier string) (*TokenResponse, error) {
 	return &tokens, nil
 }
 
+// accountClient sends requests made on behalf of an account through the account's
+// egress config in the request context (see egress.WithConfig).
+var accountClient = &http.Client{Transport: egress.Transport()}
+
 // RefreshAccessToken refreshes an access token using a refresh token.
-func RefreshAccessToken(refreshToken string) (*TokenResponse, error) {
+func RefreshAccessToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
 	data := url.Values{}
 	data.Set("client_id", config.OAuthConfig.ClientID)
 	data.Set("client_secret", config.OAuthConfig.ClientSecret)
 	data.Set("refresh_token", refreshToken)
 	data.Set("grant_type", "refresh_token")
 
-	resp, err := http.Post(config.OAuthConfig.TokenURL, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", config.OAuthConfig.TokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := accountClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("token refresh request failed: %w", err)
 	}
@@ -324,9 +335,9 @@ func GetUserEmail(accessToken string) (string, error) {
 }
 
 // DiscoverProjectID discovers the project ID for the authenticated user.
-func DiscoverProjectID(accessToken string) (string, error) {
+func DiscoverProjectID(ctx context.Context, accessToken string) (string, error) {
 	for _, endpoint := range config.AntigravityEndpointFallbacks {
-		projectID, err := tryDiscoverProject(endpoint, accessToken)
+		projectID, err := tryDiscoverProject(ctx, endpoint, accessToken)
 		if err == nil && projectID != "" {
 			return projectID, nil
 		}
@@ -336,10 +347,10 @@ func DiscoverProjectID(accessToken string) (string, error) {
 	return "", fmt.Errorf("project discovery failed for all endpoints")
 }
 
-func tryDiscoverProject(endpoint string, accessToken string) (string, error) {
+func tryDiscoverProject(ctx context.Context, endpoint string, accessToken string) (string, error) {
 	reqBody := `{"metadata":{"ideType":"IDE_UNSPECIFIED","platform":"PLATFORM_UNSPECIFIED","pluginType":"GEMINI"}}`
 
-	req, err := http.NewRequest("POST", endpoint+"/v1internal:loadCodeAssist", strings.NewReader(reqBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint+"/v1internal:loadCodeAssist", strings.NewReader(reqBody))
 	if err != nil {
 		return "", err
 	}
@@ -349,8 +360,9 @@ func tryDiscoverProject(endpoint string, accessToken string) (string, error) {
 	for k, v := range config.GetAntigravityHeaders() {
 		req.Header.Set(k, v)
 	}
+	config.SetProviderHeaders(req, "antigravity")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := accountClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -403,7 +415,7 @@ func CompleteOAuthFlow(code string, verifier string) (*AuthorizationResult, erro
 	}
 
 	// Discover project ID
-	projectID, err := DiscoverProjectID(tokens.AccessToken)
+	projectID, err := DiscoverProjectID(context.Background(), tokens.AccessToken)
 	if err != nil {
 		utils.Warn("[OAuth] Project discovery failed, using default: %v", err)
 		projectID = config.DefaultProjectID
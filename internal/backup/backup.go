@@ -0,0 +1,219 @@
+// Package backup takes scheduled snapshots of the account state file and restores them.
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+const (
+	backupPrefix     = "accounts-"
+	preRestorePrefix = "pre-restore-"
+	timestampFormat  = "20060102T150405Z"
+)
+
+// Manager snapshots the account state file into a backup directory and prunes old snapshots.
+type Manager struct {
+	dir       string
+	statePath string
+	retention int
+}
+
+// New creates a backup manager for the account state file at statePath
+// (empty uses the default accounts.json location).
+func New(cfg config.BackupConfig, statePath string) *Manager {
+	if statePath == "" {
+		statePath = config.GetAccountConfigPath()
+	}
+	return &Manager{dir: cfg.Dir, statePath: statePath, retention: cfg.Retention}
+}
+
+// Dir returns the backup directory.
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Snapshot copies the state file into the backup directory and prunes backups beyond the
+// retention limit. It returns "" when there is no state file yet. A state file that fails
+// validation is not backed up, so a corrupted file never rotates good backups out.
+func (m *Manager) Snapshot(now time.Time) (string, error) {
+	data, err := os.ReadFile(m.statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read state file: %w", err)
+	}
+	if _, err := account.ValidateConfigData(data); err != nil {
+		return "", fmt.Errorf("refusing to back up %s: %w", m.statePath, err)
+	}
+
+	path := filepath.Join(m.dir, backupPrefix+now.UTC().Format(timestampFormat)+".json")
+	if err := writeFileAtomic(path, data); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := m.prune(); err != nil {
+		utils.Warn("[Backup] Failed to prune old backups: %v", err)
+	}
+	return path, nil
+}
+
+// List returns the scheduled backups, newest first.
+func (m *Manager) List() ([]string, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, ".json") {
+			backups = append(backups, filepath.Join(m.dir, name))
+		}
+	}
+	// Timestamps sort lexically, so reverse name order is newest first.
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+func (m *Manager) prune() error {
+	if m.retention <= 0 {
+		return nil
+	}
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+	for _, path := range backups[min(m.retention, len(backups)):] {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start takes a snapshot now and then every interval until stop is closed.
+func (m *Manager) Start(interval time.Duration, stop <-chan struct{}) {
+	m.snapshotAndLog()
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.snapshotAndLog()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (m *Manager) snapshotAndLog() {
+	path, err := m.Snapshot(time.Now())
+	if err != nil {
+		utils.Warn("[Backup] %v", err)
+		return
+	}
+	if path != "" {
+		utils.Debug("[Backup] Saved %s", path)
+	}
+}
+
+// Restore validates the backup at from and atomically replaces the state file with it.
+// from may be a path or the name of a file in the backup directory. The replaced state
+// file, if any, is kept in the backup directory as pre-restore-<timestamp>.json.
+func (m *Manager) Restore(from string, now time.Time) error {
+	path := from
+	if _, err := os.Stat(path); os.IsNotExist(err) && !strings.ContainsRune(from, os.PathSeparator) {
+		path = filepath.Join(m.dir, from)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	if _, err := account.ValidateConfigData(data); err != nil {
+		return fmt.Errorf("backup %s is not usable: %w", path, err)
+	}
+
+	// A running server picks the restored file up instead of overwriting it.
+	unlock, err := account.NewStorage(m.statePath).Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := os.ReadFile(m.statePath)
+	if err == nil {
+		keep := filepath.Join(m.dir, preRestorePrefix+now.UTC().Format(timestampFormat)+".json")
+		if err := writeFileAtomic(keep, current); err != nil {
+			return fmt.Errorf("failed to keep current state: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := writeFileAtomic(m.statePath, data); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temp file in the target directory, syncs it, and renames it into place.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(dir, ".backup-*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+
+	success := false
+	defer func() {
+		if !success {
+			os.Remove(tempPath)
+		}
+	}()
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempPath, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return err
+	}
+
+	success = true
+	return nil
+}
@@ -0,0 +1,118 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+const validState = `{"accounts":[{"email":"a@example.com","source":"oauth"}],"settings":{},"activeIndex":0}`
+
+func newTestBackupManager(t *testing.T, retention int) (*Manager, string) {
+	tmpDir, err := os.MkdirTemp("", "mcp-backup-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	statePath := filepath.Join(tmpDir, "accounts.json")
+	cfg := config.BackupConfig{Dir: filepath.Join(tmpDir, "backups"), Retention: retention}
+	return New(cfg, statePath), statePath
+}
+
+func TestSnapshot_Retention(t *testing.T) {
+	mgr, statePath := newTestBackupManager(t, 2)
+
+	if path, err := mgr.Snapshot(time.Now()); err != nil || path != "" {
+		t.Fatalf("expected no backup without a state file, got %q, %v", path, err)
+	}
+
+	if err := os.WriteFile(statePath, []byte(validState), 0600); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if _, err := mgr.Snapshot(base.Add(time.Duration(i) * 24 * time.Hour)); err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+	}
+
+	backups, err := mgr.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups after pruning, got %v", backups)
+	}
+	if filepath.Base(backups[0]) != "accounts-20260103T030000Z.json" || filepath.Base(backups[1]) != "accounts-20260102T030000Z.json" {
+		t.Errorf("expected newest backups first, got %v", backups)
+	}
+}
+
+func TestSnapshot_RefusesCorruptState(t *testing.T) {
+	mgr, statePath := newTestBackupManager(t, 7)
+	if err := os.WriteFile(statePath, []byte(`{"accounts":[{"email":"a@`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mgr.Snapshot(time.Now()); err == nil {
+		t.Fatal("expected error for corrupt state file")
+	}
+	if backups, _ := mgr.List(); len(backups) != 0 {
+		t.Errorf("corrupt state should not be backed up, got %v", backups)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	mgr, statePath := newTestBackupManager(t, 7)
+	if err := os.WriteFile(statePath, []byte(validState), 0600); err != nil {
+		t.Fatal(err)
+	}
+	backupPath, err := mgr.Snapshot(time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash that truncated the state file.
+	if err := os.WriteFile(statePath, []byte(`{"acc`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mgr.Restore(filepath.Base(backupPath), time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(statePath)
+	if string(data) != validState {
+		t.Errorf("state not restored: %s", data)
+	}
+	kept, err := os.ReadFile(filepath.Join(mgr.Dir(), "pre-restore-20260102T000000Z.json"))
+	if err != nil || string(kept) != `{"acc` {
+		t.Errorf("replaced state not kept: %q, %v", kept, err)
+	}
+}
+
+func TestRestore_RejectsInvalidBackup(t *testing.T) {
+	mgr, statePath := newTestBackupManager(t, 7)
+	if err := os.WriteFile(statePath, []byte(validState), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := filepath.Join(filepath.Dir(statePath), "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"accounts":[{"email":"a"},{"email":"a"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := mgr.Restore(bad, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "duplicate account") {
+		t.Fatalf("expected duplicate account error, got %v", err)
+	}
+	data, _ := os.ReadFile(statePath)
+	if string(data) != validState {
+		t.Errorf("state should be untouched, got %s", data)
+	}
+}
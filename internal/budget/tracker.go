@@ -0,0 +1,258 @@
+// Package budget counts the requests, tokens and estimated spend of virtual API keys and
+// accounts per UTC day and month, so they can be held to configured budgets.
+package budget
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// Usage is what a subject used in the current day and month.
+type Usage struct {
+	Day             string  `json:"day"` // 2006-01-02, UTC
+	DailyTokens     int64   `json:"dailyTokens"`
+	DailyRequests   int64   `json:"dailyRequests"`
+	DailySpend      float64 `json:"dailySpend"` // Estimated, in US dollars (MODEL_PRICING_CONFIG)
+	Month           string  `json:"month"`      // 2006-01, UTC
+	MonthlyTokens   int64   `json:"monthlyTokens"`
+	MonthlyRequests int64   `json:"monthlyRequests"`
+	MonthlySpend    float64 `json:"monthlySpend"`
+}
+
+// rollover resets the counters of periods that have ended.
+func (u *Usage) rollover(now time.Time) {
+	now = now.UTC()
+	if day := now.Format("2006-01-02"); u.Day != day {
+		u.Day, u.DailyTokens, u.DailyRequests, u.DailySpend = day, 0, 0, 0
+	}
+	if month := now.Format("2006-01"); u.Month != month {
+		u.Month, u.MonthlyTokens, u.MonthlyRequests, u.MonthlySpend = month, 0, 0, 0
+	}
+}
+
+// empty reports whether nothing was used this month.
+func (u Usage) empty() bool {
+	return u.MonthlyRequests == 0 && u.MonthlyTokens == 0 && u.MonthlySpend == 0
+}
+
+// Remaining is what is left of a budget; nil fields are unlimited.
+type Remaining struct {
+	Tokens   *int64 `json:"tokens"`
+	Requests *int64 `json:"requests"`
+}
+
+// Remaining returns the smaller of the daily and monthly allowances left.
+func (u Usage) Remaining(limits config.BudgetLimits) Remaining {
+	left := func(daily int, usedDaily int64, monthly int, usedMonthly int64) *int64 {
+		var r *int64
+		for _, c := range []struct {
+			limit int
+			used  int64
+		}{{daily, usedDaily}, {monthly, usedMonthly}} {
+			if c.limit <= 0 {
+				continue
+			}
+			n := max(int64(c.limit)-c.used, 0)
+			if r == nil || n < *r {
+				r = &n
+			}
+		}
+		return r
+	}
+	return Remaining{
+		Tokens:   left(limits.DailyTokens, u.DailyTokens, limits.MonthlyTokens, u.MonthlyTokens),
+		Requests: left(limits.DailyRequests, u.DailyRequests, limits.MonthlyRequests, u.MonthlyRequests),
+	}
+}
+
+// Exceeded returns which budget u has used up, if any, and when that budget resets.
+func (u Usage) Exceeded(limits config.BudgetLimits, now time.Time) (string, time.Time, bool) {
+	now = now.UTC()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	nextMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	switch {
+	case limits.MonthlyTokens > 0 && u.MonthlyTokens >= int64(limits.MonthlyTokens):
+		return fmt.Sprintf("monthly budget of %d tokens", limits.MonthlyTokens), nextMonth, true
+	case limits.MonthlyRequests > 0 && u.MonthlyRequests >= int64(limits.MonthlyRequests):
+		return fmt.Sprintf("monthly budget of %d requests", limits.MonthlyRequests), nextMonth, true
+	case limits.DailyTokens > 0 && u.DailyTokens >= int64(limits.DailyTokens):
+		return fmt.Sprintf("daily budget of %d tokens", limits.DailyTokens), tomorrow, true
+	case limits.DailyRequests > 0 && u.DailyRequests >= int64(limits.DailyRequests):
+		return fmt.Sprintf("daily budget of %d requests", limits.DailyRequests), tomorrow, true
+	}
+	return "", time.Time{}, false
+}
+
+// Tracker counts usage by subject, e.g. "key:<name>" or "account:<email>". It is safe for
+// concurrent use.
+type Tracker struct {
+	mu    sync.Mutex
+	usage map[string]*Usage
+	now   func() time.Time
+}
+
+// NewTracker creates an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{usage: make(map[string]*Usage), now: time.Now}
+}
+
+// Add counts requests and tokens against subject in the current day and month.
+func (t *Tracker) Add(subject string, requests, tokens int64) {
+	if subject == "" || (requests == 0 && tokens == 0) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u := t.usageLocked(subject)
+	u.DailyRequests += requests
+	u.MonthlyRequests += requests
+	u.DailyTokens += tokens
+	u.MonthlyTokens += tokens
+}
+
+// AddSpend counts an estimated cost in US dollars against subject in the current day and month.
+func (t *Tracker) AddSpend(subject string, usd float64) {
+	if subject == "" || usd <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u := t.usageLocked(subject)
+	u.DailySpend += usd
+	u.MonthlySpend += usd
+}
+
+// usageLocked returns the usage of subject, creating it and resetting ended periods.
+func (t *Tracker) usageLocked(subject string) *Usage {
+	u, ok := t.usage[subject]
+	if !ok {
+		u = &Usage{}
+		t.usage[subject] = u
+	}
+	u.rollover(t.now())
+	return u
+}
+
+// Usage returns what subject used in the current day and month.
+func (t *Tracker) Usage(subject string) Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var u Usage
+	if existing, ok := t.usage[subject]; ok {
+		u = *existing
+	}
+	u.rollover(t.now())
+	return u
+}
+
+// Exceeded is Usage(subject).Exceeded(limits).
+func (t *Tracker) Exceeded(subject string, limits config.BudgetLimits) (string, time.Time, bool) {
+	if !limits.Limited() {
+		return "", time.Time{}, false
+	}
+	return t.Usage(subject).Exceeded(limits, t.now())
+}
+
+// snapshot is the on-disk format of a Tracker.
+type snapshot struct {
+	Version int              `json:"version"`
+	Usage   map[string]Usage `json:"usage"`
+}
+
+// SaveToFile writes the usage of the current month to path atomically (temp file + rename).
+func (t *Tracker) SaveToFile(path string) error {
+	snap := snapshot{Version: 1, Usage: make(map[string]Usage)}
+	t.mu.Lock()
+	now := t.now()
+	for subject, u := range t.usage {
+		u.rollover(now)
+		if !u.empty() {
+			snap.Usage[subject] = *u
+		}
+	}
+	t.mu.Unlock()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to marshal budget usage: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create budget usage directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write budget usage: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save budget usage: %w", err)
+	}
+	return nil
+}
+
+// LoadFromFile adds the usage saved by SaveToFile to the tracker, skipping ended periods.
+// A missing file is not an error. Returns the number of subjects loaded.
+func (t *Tracker) LoadFromFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read budget usage: %w", err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("failed to parse budget usage: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	loaded := 0
+	for subject, saved := range snap.Usage {
+		saved.rollover(now)
+		if subject == "" || saved.empty() {
+			continue
+		}
+		u, ok := t.usage[subject]
+		if !ok {
+			u = &Usage{}
+			t.usage[subject] = u
+		}
+		u.rollover(now)
+		u.DailyRequests += saved.DailyRequests
+		u.DailyTokens += saved.DailyTokens
+		u.MonthlyRequests += saved.MonthlyRequests
+		u.MonthlyTokens += saved.MonthlyTokens
+		u.DailySpend += saved.DailySpend
+		u.MonthlySpend += saved.MonthlySpend
+		loaded++
+	}
+	return loaded, nil
+}
+
+// StartPersistence periodically saves the usage to path until stop is closed. Callers
+// should save a final time with SaveToFile after closing stop.
+func (t *Tracker) StartPersistence(path string, interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.SaveToFile(path); err != nil {
+					utils.Warn("[Budget] %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
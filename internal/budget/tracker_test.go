@@ -0,0 +1,65 @@
+package budget
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+func TestTracker_Exceeded(t *testing.T) {
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	tr := NewTracker()
+	tr.now = func() time.Time { return now }
+	limits := config.BudgetLimits{DailyRequests: 2, MonthlyTokens: 1000}
+
+	tr.Add("key:a", 1, 400)
+	if _, _, exceeded := tr.Exceeded("key:a", limits); exceeded {
+		t.Fatal("expected the budget not to be exceeded yet")
+	}
+	left := tr.Usage("key:a").Remaining(limits)
+	if *left.Requests != 1 || *left.Tokens != 600 {
+		t.Fatalf("remaining = %d requests %d tokens, want 1 and 600", *left.Requests, *left.Tokens)
+	}
+
+	tr.Add("key:a", 1, 0)
+	reason, reset, exceeded := tr.Exceeded("key:a", limits)
+	if !exceeded || reason != "daily budget of 2 requests" || !reset.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Exceeded = %q %s %v, want the daily request budget resetting at midnight", reason, reset, exceeded)
+	}
+
+	// The next day and month start from zero.
+	now = now.Add(2 * time.Hour)
+	if _, _, exceeded := tr.Exceeded("key:a", limits); exceeded {
+		t.Error("expected the budget to reset in the new period")
+	}
+}
+
+func TestTracker_Persistence(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "usage.json")
+	tr := NewTracker()
+	tr.now = func() time.Time { return now }
+	tr.Add("account:a@example.com", 3, 1500)
+	tr.AddSpend("account:a@example.com", 1.5)
+	tr.AddSpend("account:b@example.com", 0.5) // Spend alone is worth keeping
+	if err := tr.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile: %v", err)
+	}
+
+	restored := NewTracker()
+	restored.now = func() time.Time { return now.Add(24 * time.Hour) }
+	loaded, err := restored.LoadFromFile(path)
+	if err != nil || loaded != 2 {
+		t.Fatalf("LoadFromFile = %d, %v; want 2", loaded, err)
+	}
+	u := restored.Usage("account:a@example.com")
+	if u.DailyRequests != 0 || u.DailySpend != 0 || u.MonthlyRequests != 3 || u.MonthlyTokens != 1500 || u.MonthlySpend != 1.5 {
+		t.Errorf("restored usage = %+v, want only the monthly counters carried over", u)
+	}
+
+	if n, err := NewTracker().LoadFromFile(filepath.Join(t.TempDir(), "missing.json")); n != 0 || err != nil {
+		t.Errorf("missing file: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,173 @@
+// Package cache provides an in-memory response cache for identical Messages API requests.
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// Event is a recorded SSE event (type + JSON payload) for streaming replay.
+type Event struct {
+	Type string
+	Data []byte
+}
+
+// Entry is a cached response. Exactly one of Response or Events is set.
+type Entry struct {
+	Response *types.AnthropicResponse // Non-streaming response
+	Events   []Event                  // Streaming response, in order
+	storedAt time.Time
+}
+
+// ResponseCache is a TTL + LRU cache of upstream responses. It is safe for concurrent use.
+type ResponseCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // front = most recently used
+
+	hits   int64
+	misses int64
+}
+
+type cacheItem struct {
+	key   string
+	entry Entry
+}
+
+// NewResponseCache creates a cache. maxEntries <= 0 means unbounded.
+func NewResponseCache(ttl time.Duration, maxEntries int) *ResponseCache {
+	return &ResponseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// cacheKeyFields is the normalized request used for hashing. Field order is fixed by the struct,
+// so semantically identical requests produce the same key regardless of client JSON key order.
+type cacheKeyFields struct {
+	Tenant        string                `json:"tenant,omitempty"`
+	User          string                `json:"user,omitempty"`
+	Model         string                `json:"model"`
+	Stream        bool                  `json:"stream"`
+	MaxTokens     int                   `json:"max_tokens"`
+	System        json.RawMessage       `json:"system,omitempty"`
+	Messages      []json.RawMessage     `json:"messages"`
+	Tools         []types.Tool          `json:"tools,omitempty"`
+	ToolChoice    *types.ToolChoice     `json:"tool_choice,omitempty"`
+	Thinking      *types.ThinkingConfig `json:"thinking,omitempty"`
+	Temperature   *float64              `json:"temperature,omitempty"`
+	TopP          *float64              `json:"top_p,omitempty"`
+	TopK          *int                  `json:"top_k,omitempty"`
+	StopSequences []string              `json:"stop_sequences,omitempty"`
+	ServiceTier   string                `json:"service_tier,omitempty"`
+}
+
+// Key returns a stable hash of the normalized request. tenant identifies the API key the
+// request authenticated with and user the user of its metadata.user_id, if any, so neither
+// clients of different keys nor different users of one key share a response; model is the
+// public model ID.
+func Key(tenant, user, model string, req *types.AnthropicRequest) string {
+	fields := cacheKeyFields{
+		Tenant:        tenant,
+		User:          user,
+		Model:         model,
+		Stream:        req.Stream,
+		MaxTokens:     req.MaxTokens,
+		System:        normalizeJSON(req.System),
+		Tools:         req.Tools,
+		ToolChoice:    req.ToolChoice,
+		Thinking:      req.Thinking,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		TopK:          req.TopK,
+		StopSequences: req.StopSequences,
+		ServiceTier:   req.ServiceTier,
+	}
+	for _, msg := range req.Messages {
+		m, _ := json.Marshal(struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		}{msg.Role, normalizeJSON(msg.Content)})
+		fields.Messages = append(fields.Messages, m)
+	}
+
+	data, _ := json.Marshal(fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeJSON re-encodes raw JSON so whitespace and object key order don't affect the key.
+func normalizeJSON(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return raw
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
+// Get returns the entry for key if present and not expired.
+func (c *ResponseCache) Get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return Entry{}, false
+	}
+	item := el.Value.(*cacheItem)
+	if c.ttl > 0 && time.Since(item.entry.storedAt) > c.ttl {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		c.misses++
+		return Entry{}, false
+	}
+	c.order.MoveToFront(el)
+	c.hits++
+	return item.entry, true
+}
+
+// Put stores an entry, evicting the least recently used entries beyond maxEntries.
+func (c *ResponseCache) Put(key string, entry Entry) {
+	entry.storedAt = time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheItem).entry = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheItem{key: key, entry: entry})
+
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheItem).key)
+	}
+}
+
+// Stats returns hit/miss counters and the current size.
+func (c *ResponseCache) Stats() (hits, misses int64, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses, c.order.Len()
+}
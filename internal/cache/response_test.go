@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func testRequest(content string) *types.AnthropicRequest {
+	return &types.AnthropicRequest{
+		Model:     "claude-sonnet-4-5",
+		MaxTokens: 1024,
+		Messages: []types.Message{
+			{Role: "user", Content: json.RawMessage(content)},
+		},
+	}
+}
+
+func TestKey_NormalizesJSON(t *testing.T) {
+	a := testRequest(`[{"type":"text","text":"hi"}]`)
+	b := testRequest(`[ { "text": "hi",  "type": "text" } ]`)
+	if Key("", "", "m", a) != Key("", "", "m", b) {
+		t.Error("expected whitespace and key order to be ignored")
+	}
+
+	c := testRequest(`[{"type":"text","text":"bye"}]`)
+	if Key("", "", "m", a) == Key("", "", "m", c) {
+		t.Error("expected different content to produce different keys")
+	}
+	if Key("", "", "m", a) == Key("", "", "other", a) {
+		t.Error("expected different models to produce different keys")
+	}
+	if Key("", "", "m", a) == Key("team-a", "", "m", a) || Key("team-a", "", "m", a) == Key("team-b", "", "m", a) {
+		t.Error("expected different API keys to produce different keys")
+	}
+	if Key("team-a", "alice", "m", a) == Key("team-a", "bob", "m", a) || Key("team-a", "alice", "m", a) == Key("team-a", "", "m", a) {
+		t.Error("expected different users to produce different keys")
+	}
+
+	streamed := testRequest(`[{"type":"text","text":"hi"}]`)
+	streamed.Stream = true
+	if Key("", "", "m", a) == Key("", "", "m", streamed) {
+		t.Error("expected stream flag to be part of the key")
+	}
+}
+
+func TestResponseCache_GetPut(t *testing.T) {
+	c := NewResponseCache(time.Minute, 10)
+
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("expected miss on empty cache")
+	}
+	c.Put("k", Entry{Response: &types.AnthropicResponse{ID: "msg_1"}})
+
+	entry, ok := c.Get("k")
+	if !ok || entry.Response == nil || entry.Response.ID != "msg_1" {
+		t.Fatalf("expected hit with msg_1, got %+v, %v", entry, ok)
+	}
+
+	hits, misses, size := c.Stats()
+	if hits != 1 || misses != 1 || size != 1 {
+		t.Errorf("unexpected stats: hits=%d misses=%d size=%d", hits, misses, size)
+	}
+}
+
+func TestResponseCache_TTL(t *testing.T) {
+	c := NewResponseCache(10*time.Millisecond, 10)
+	c.Put("k", Entry{Events: []Event{{Type: "message_stop", Data: []byte(`{}`)}}})
+
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get("k"); ok {
+		t.Error("expected expired entry to miss")
+	}
+	if _, _, size := c.Stats(); size != 0 {
+		t.Errorf("expected expired entry to be removed, size=%d", size)
+	}
+}
+
+func TestResponseCache_LRUEviction(t *testing.T) {
+	c := NewResponseCache(time.Minute, 2)
+	c.Put("a", Entry{})
+	c.Put("b", Entry{})
+	c.Get("a") // a is now most recently used
+	c.Put("c", Entry{})
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("expected recently used entry to be kept")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Error("expected newest entry to be kept")
+	}
+}
@@ -0,0 +1,185 @@
+// Package capture records the upstream HTTP exchanges made on behalf of one request, so a
+// replayed request (POST /admin/replay) can be inspected down to what each upstream saw.
+// Headers and bodies are redacted like audit records.
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/audit"
+)
+
+// maxBodyBytes caps how much of a single upstream request or response body is kept.
+const maxBodyBytes = 1024 * 1024
+
+// Exchange is one upstream request and its response.
+type Exchange struct {
+	Method          string            `json:"method"`
+	URL             string            `json:"url"` // Without the query string, which may carry API keys
+	Status          int               `json:"status,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	DurationMs      int64             `json:"durationMs"`
+	RequestHeaders  map[string]string `json:"requestHeaders,omitempty"`
+	RequestBody     json.RawMessage   `json:"requestBody,omitempty"`
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+	ResponseBody    json.RawMessage   `json:"responseBody,omitempty"` // Complete once the body was read or closed
+}
+
+// Recorder collects the exchanges of one request. It is safe for concurrent use.
+type Recorder struct {
+	mu        sync.Mutex
+	exchanges []*Exchange
+}
+
+// Exchanges returns a copy of the exchanges recorded so far, oldest first.
+func (r *Recorder) Exchanges() []Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Exchange, len(r.exchanges))
+	for i, e := range r.exchanges {
+		out[i] = *e
+	}
+	return out
+}
+
+func (r *Recorder) add(e *Exchange) {
+	r.mu.Lock()
+	r.exchanges = append(r.exchanges, e)
+	r.mu.Unlock()
+}
+
+func (r *Recorder) update(fn func()) {
+	r.mu.Lock()
+	fn()
+	r.mu.Unlock()
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns ctx carrying r. Requests made with the returned context through
+// Transport are recorded in r.
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+func fromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return r
+}
+
+// Transport returns a RoundTripper that records requests whose context carries a
+// Recorder and passes all others straight to base.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := fromContext(req.Context())
+	if rec == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	u := *req.URL
+	u.RawQuery = ""
+	e := &Exchange{
+		Method:         req.Method,
+		URL:            u.String(),
+		RequestHeaders: audit.RedactHeaders(req.Header),
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, maxBodyBytes))
+			body.Close()
+			e.RequestBody = redactedBody(data)
+		}
+	}
+	rec.add(e)
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		rec.update(func() {
+			e.Error = err.Error()
+			e.DurationMs = time.Since(start).Milliseconds()
+		})
+		return nil, err
+	}
+	rec.update(func() {
+		e.Status = resp.StatusCode
+		e.ResponseHeaders = audit.RedactHeaders(resp.Header)
+	})
+	resp.Body = &recordingBody{ReadCloser: resp.Body, rec: rec, exchange: e, start: start}
+	return resp, nil
+}
+
+// recordingBody keeps a bounded copy of the response body and stores it in the exchange
+// once the body is drained or closed.
+type recordingBody struct {
+	io.ReadCloser
+	rec      *Recorder
+	exchange *Exchange
+	start    time.Time
+	buf      bytes.Buffer
+	once     sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if remaining := maxBodyBytes - b.buf.Len(); remaining > 0 {
+			b.buf.Write(p[:min(n, remaining)])
+		}
+	}
+	if err != nil {
+		b.finish(err)
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish(nil)
+	return err
+}
+
+func (b *recordingBody) finish(readErr error) {
+	b.once.Do(func() {
+		b.rec.update(func() {
+			b.exchange.ResponseBody = redactedBody(b.buf.Bytes())
+			b.exchange.DurationMs = time.Since(b.start).Milliseconds()
+			if readErr != nil && readErr != io.EOF {
+				b.exchange.Error = readErr.Error()
+			}
+		})
+	})
+}
+
+// redactedBody redacts a captured body, keeping JSON as JSON and storing anything else
+// (e.g. SSE streams) as a JSON string.
+func redactedBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	redacted := audit.RedactBody(body)
+	if json.Valid(redacted) {
+		return redacted
+	}
+	quoted, err := json.Marshal(string(redacted))
+	if err != nil {
+		return nil
+	}
+	return quoted
+}
@@ -0,0 +1,75 @@
+package capture
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransport_RecordsExchanges(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, `{"error":"short and stout"}`)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: Transport(nil)}
+	rec := &Recorder{}
+	ctx := WithRecorder(context.Background(), rec)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", srv.URL+"/v1/generate?key=secret", strings.NewReader(`{"apiKey":"k","prompt":"hi"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// Requests without a recorder are not recorded.
+	plain, _ := http.NewRequest("GET", srv.URL, nil)
+	if resp, err := client.Do(plain); err == nil {
+		resp.Body.Close()
+	}
+
+	exchanges := rec.Exchanges()
+	if len(exchanges) != 1 {
+		t.Fatalf("expected 1 exchange, got %d", len(exchanges))
+	}
+	e := exchanges[0]
+	if e.Method != "POST" || e.URL != srv.URL+"/v1/generate" {
+		t.Errorf("unexpected request line %s %s", e.Method, e.URL)
+	}
+	if e.Status != http.StatusTeapot {
+		t.Errorf("expected status 418, got %d", e.Status)
+	}
+	if got := e.RequestHeaders["Authorization"]; got != "[REDACTED]" {
+		t.Errorf("expected Authorization to be redacted, got %q", got)
+	}
+	if got := string(e.RequestBody); got != `{"apiKey":"[REDACTED]","prompt":"hi"}` {
+		t.Errorf("unexpected request body %s", got)
+	}
+	if got := string(e.ResponseBody); got != `{"error":"short and stout"}` {
+		t.Errorf("unexpected response body %s", got)
+	}
+}
+
+func TestTransport_RecordsTransportErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	rec := &Recorder{}
+	req, _ := http.NewRequestWithContext(WithRecorder(context.Background(), rec), "GET", srv.URL, nil)
+	if _, err := (&http.Client{Transport: Transport(nil)}).Do(req); err == nil {
+		t.Fatal("expected an error from a closed server")
+	}
+
+	exchanges := rec.Exchanges()
+	if len(exchanges) != 1 || exchanges[0].Error == "" || exchanges[0].Status != 0 {
+		t.Fatalf("expected one failed exchange, got %+v", exchanges)
+	}
+}
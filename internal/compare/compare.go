@@ -0,0 +1,137 @@
+// Package compare diffs the responses two models gave to the same Messages API request,
+// e.g. to check that a fallback model is an acceptable substitute for the primary one.
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// maxSnippet caps the text shown for a differing text answer.
+const maxSnippet = 80
+
+// Result is one model's outcome: its response, or the error it failed with.
+type Result struct {
+	Model      string
+	Response   *types.AnthropicResponse
+	Err        error
+	DurationMs int64
+}
+
+// ToolCall is a tool_use block of a response.
+type ToolCall struct {
+	Name  string                 `json:"name"`
+	Input map[string]interface{} `json:"input"`
+}
+
+// Summary is the comparable shape of one result.
+type Summary struct {
+	Model      string      `json:"model"`
+	DurationMs int64       `json:"durationMs"`
+	Error      string      `json:"error,omitempty"`
+	StopReason string      `json:"stopReason,omitempty"`
+	Blocks     []string    `json:"blocks"`
+	ToolCalls  []ToolCall  `json:"toolCalls"`
+	Text       string      `json:"text"`
+	Usage      types.Usage `json:"usage"`
+}
+
+// Difference is one field whose value differs between the two results.
+type Difference struct {
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+}
+
+// Report is the structured diff of two results.
+type Report struct {
+	A           Summary      `json:"a"`
+	B           Summary      `json:"b"`
+	Differences []Difference `json:"differences"`
+}
+
+// Diff compares a and b by error, stop reason, content block types, tool calls, text and
+// token usage. Thinking text and block IDs are not compared.
+func Diff(a, b Result) Report {
+	r := Report{A: summarize(a), B: summarize(b), Differences: []Difference{}}
+	add := func(field, va, vb string) {
+		if va != vb {
+			r.Differences = append(r.Differences, Difference{Field: field, A: va, B: vb})
+		}
+	}
+
+	add("error", r.A.Error, r.B.Error)
+	if r.A.Error != "" || r.B.Error != "" {
+		return r
+	}
+
+	add("stop_reason", r.A.StopReason, r.B.StopReason)
+	add("blocks", strings.Join(r.A.Blocks, ", "), strings.Join(r.B.Blocks, ", "))
+
+	add("tool_calls", fmt.Sprint(len(r.A.ToolCalls)), fmt.Sprint(len(r.B.ToolCalls)))
+	for i := 0; i < min(len(r.A.ToolCalls), len(r.B.ToolCalls)); i++ {
+		ca, cb := r.A.ToolCalls[i], r.B.ToolCalls[i]
+		add(fmt.Sprintf("tool_calls[%d].name", i), ca.Name, cb.Name)
+		if !reflect.DeepEqual(ca.Input, cb.Input) {
+			add(fmt.Sprintf("tool_calls[%d].input", i), compactJSON(ca.Input), compactJSON(cb.Input))
+		}
+	}
+
+	if r.A.Text != r.B.Text {
+		add("text", describeText(r.A.Text), describeText(r.B.Text))
+	}
+
+	add("usage.input_tokens", fmt.Sprint(r.A.Usage.InputTokens), fmt.Sprint(r.B.Usage.InputTokens))
+	add("usage.output_tokens", fmt.Sprint(r.A.Usage.OutputTokens), fmt.Sprint(r.B.Usage.OutputTokens))
+	return r
+}
+
+func summarize(res Result) Summary {
+	s := Summary{Model: res.Model, DurationMs: res.DurationMs, Blocks: []string{}, ToolCalls: []ToolCall{}}
+	if res.Err != nil {
+		s.Error = res.Err.Error()
+		return s
+	}
+	if res.Response == nil {
+		s.Error = "empty response"
+		return s
+	}
+
+	resp := res.Response
+	s.StopReason = resp.StopReason
+	s.Usage = resp.Usage
+	var text []string
+	for _, block := range resp.Content {
+		s.Blocks = append(s.Blocks, block.Type)
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "tool_use":
+			s.ToolCalls = append(s.ToolCalls, ToolCall{Name: block.Name, Input: block.Input})
+		}
+	}
+	s.Text = strings.TrimSpace(strings.Join(text, "\n"))
+	return s
+}
+
+// describeText shortens a text answer for a Difference.
+func describeText(text string) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	snippet := string(runes)
+	if len(runes) > maxSnippet {
+		snippet = string(runes[:maxSnippet]) + "..."
+	}
+	return fmt.Sprintf("%d chars: %q", len([]rune(text)), snippet)
+}
+
+func compactJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,69 @@
+package compare
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestDiff(t *testing.T) {
+	a := Result{Model: "antigravity/gemini-3-flash", Response: &types.AnthropicResponse{
+		Content: []types.ContentBlock{
+			{Type: "thinking", Thinking: "let me look"},
+			{Type: "text", Text: "Reading it."},
+			{Type: "tool_use", ID: "toolu_1", Name: "read_file", Input: map[string]interface{}{"path": "/a"}},
+		},
+		StopReason: "tool_use",
+		Usage:      types.Usage{InputTokens: 100, OutputTokens: 20},
+	}}
+	b := Result{Model: "zai/glm-4.7", Response: &types.AnthropicResponse{
+		Content: []types.ContentBlock{
+			{Type: "text", Text: "Reading it."},
+			{Type: "tool_use", ID: "call_9", Name: "read_file", Input: map[string]interface{}{"path": "/b"}},
+		},
+		StopReason: "tool_use",
+		Usage:      types.Usage{InputTokens: 100, OutputTokens: 25},
+	}}
+
+	report := Diff(a, b)
+	want := []Difference{
+		{Field: "blocks", A: "thinking, text, tool_use", B: "text, tool_use"},
+		{Field: "tool_calls[0].input", A: `{"path":"/a"}`, B: `{"path":"/b"}`},
+		{Field: "usage.output_tokens", A: "20", B: "25"},
+	}
+	if !reflect.DeepEqual(report.Differences, want) {
+		t.Errorf("unexpected differences:\n got %+v\nwant %+v", report.Differences, want)
+	}
+	if len(report.A.ToolCalls) != 1 || report.B.Text != "Reading it." {
+		t.Errorf("unexpected summaries: %+v / %+v", report.A, report.B)
+	}
+
+	if report := Diff(a, a); len(report.Differences) != 0 {
+		t.Errorf("expected identical results to have no differences, got %+v", report.Differences)
+	}
+}
+
+func TestDiff_Error(t *testing.T) {
+	a := Result{Model: "a", Response: &types.AnthropicResponse{StopReason: "end_turn"}}
+	b := Result{Model: "b", Err: errors.New("rate limited")}
+
+	report := Diff(a, b)
+	want := []Difference{{Field: "error", A: "", B: "rate limited"}}
+	if !reflect.DeepEqual(report.Differences, want) {
+		t.Errorf("expected only the error to differ, got %+v", report.Differences)
+	}
+}
+
+func TestDescribeText(t *testing.T) {
+	long := ""
+	for i := 0; i < 20; i++ {
+		long += "word "
+	}
+	got := describeText(long)
+	want := `100 chars: "word word word word word word word word word word word word word word word word ..."`
+	if got != want {
+		t.Errorf("describeText = %s, want %s", got, want)
+	}
+}
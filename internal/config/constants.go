@@ -48,6 +48,96 @@ const (
 	GeminiMaxOutputTokens   = 16384
 	GeminiSkipSignature     = "skip_thought_signature_validator"
 	GeminiSignatureCacheTTL = 2 * time.Hour
+
+	DefaultSignatureCacheMaxEntries   = 10000 // Per cache map (tool + thinking)
+	DefaultSignatureCacheMaxSessions  = 1000  // Conversations whose signatures are kept
+	DefaultSignatureCacheSaveInterval = time.Minute
+
+	DefaultThinkingBudgetMin = 1024  // Smallest budget_tokens Anthropic accepts
+	DefaultThinkingBudget    = 16000 // budget_tokens of auto-enabled thinking
+	MinThinkingOutputTokens  = 1024  // Tokens of max_tokens a thinking budget leaves for the answer
+)
+
+// Account health constants (result feedback into account selection)
+const (
+	DefaultHealthFailureThreshold = 0.6 // Failure score at which an account is deprioritized
+	DefaultHealthCooldown         = time.Minute
+	HealthMinSamples              = 3   // Results needed before an account can be deprioritized
+	HealthDecay                   = 0.3 // EWMA weight of the newest result
+)
+
+// Sticky error constants (deterministic per-account/model failures skipped during selection)
+const (
+	DefaultStickyErrorTTL       = 10 * time.Minute
+	DefaultStickyErrorThreshold = 2 // Identical consecutive failures before the pair is skipped
+)
+
+// InvalidBlacklistWindow is how long an account is kept out of selection after it is marked
+// invalid, even if its invalid flag is cleared in the meantime (e.g. by a token refresh that
+// raced with the invalidation).
+const InvalidBlacklistWindow = time.Minute
+
+// Health check constants
+const (
+	DefaultHealthRefreshInterval = 30 * time.Second // How often the cached /health report is rebuilt
+	DefaultProbeLivePath         = "/health/live"
+	DefaultProbeReadyPath        = "/health/ready"
+	DefaultPreStopPath           = "/lifecycle/prestop"
+	DefaultShutdownTimeout       = 30 * time.Second // Time in-flight requests get to finish on shutdown
+)
+
+// Streaming constants
+const (
+	DefaultStreamPingInterval = 15 * time.Second // Idle time before a ping event keeps a stream alive
+)
+
+// Tracing constants
+const (
+	DefaultTracingServiceName = "multi-claude-proxy"
+	TracingExportInterval     = 5 * time.Second  // How often queued spans are sent to the collector
+	TracingExportTimeout      = 10 * time.Second // Timeout for one OTLP export request
+	TracingBatchSize          = 512              // Spans per export request
+	TracingQueueSize          = 4096             // Spans buffered before new ones are dropped
+)
+
+// Quota tracking constants
+const (
+	DefaultQuotaHistoryWindow = 6 * time.Hour // Samples older than this don't count toward the burn rate
+	QuotaMaxSamples           = 360           // Per account/model, oldest dropped first
+
+	DefaultQuotaReconcileTolerance = 3.0  // Local tokens may be off from the quota change by this factor
+	QuotaReconcileMinDrop          = 0.02 // Smaller quota changes are within the upstream's rounding
+	QuotaCapacitySmoothing         = 0.2  // Weight of the newest interval in the learned tokens per quota
+)
+
+// Account state notification constants
+const (
+	DefaultNotifyMaxRetries = 3
+	DefaultNotifyRetryDelay = 2 * time.Second  // Doubled after each failed attempt
+	NotifyWebhookTimeout    = 10 * time.Second // Per delivery attempt
+)
+
+// Telemetry constants (opt-in, see TELEMETRY_ENABLED)
+const (
+	DefaultTelemetryInterval = 24 * time.Hour
+	TelemetryTimeout         = 10 * time.Second // Per report delivery
+)
+
+// Policy filter constants (see POLICY_FILTER_CONFIG and POLICY_FILTER_URL)
+const (
+	DefaultPolicyFilterTimeout = 5 * time.Second // Per external filter call
+	DefaultPolicyRedaction     = "[REDACTED]"
+)
+
+// State backup constants
+const (
+	DefaultBackupInterval  = 24 * time.Hour
+	DefaultBackupRetention = 7 // Number of scheduled backups kept
+
+	DefaultAccountsSyncInterval = 5 * time.Second // How often the server checks accounts.json for CLI changes
+
+	DefaultRevalidateInterval    = 15 * time.Minute // How often invalid accounts are re-tested
+	DefaultRevalidateMaxAttempts = 8                // Re-tests per account before giving up until it recovers
 )
 
 // Image generation constants
@@ -55,6 +145,16 @@ const (
 	DefaultImageModel = "gemini-3-pro-image"
 	MaxImageCount     = 4
 	DefaultImageCount = 1
+
+	DefaultImageToolName = "generate_image" // Tool whose calls in /v1/messages the proxy runs itself
+	MaxImageToolRounds   = 4                // Model calls per request before image tool calls are no longer run
+)
+
+// Copilot token refresh constants
+const (
+	CopilotTokenRefreshInterval  = 30 * time.Second // How often cached Copilot tokens are checked for refresh
+	CopilotTokenRefreshLead      = 5 * time.Minute  // Tokens are refreshed this long before they expire
+	CopilotTokenAuthFailureLimit = 3                // Consecutive 401s from the token endpoint before an account is marked invalid
 )
 
 // OAuth configuration
@@ -108,9 +208,42 @@ const (
 	ZAITimeout    = 10 * time.Minute // Client-side timeout for Z.AI message requests
 )
 
-// Health/Status endpoint timeouts
+// Anthropic API configuration
+const (
+	AnthropicBaseURL    = "https://api.anthropic.com"
+	AnthropicModelsPath = "/v1/models?limit=1000"
+	AnthropicVersion    = "2023-06-01"     // Sent as the anthropic-version header
+	AnthropicTimeout    = 10 * time.Minute // Client-side timeout for Anthropic message requests
+)
+
+// Vertex AI configuration
+const (
+	VertexDefaultRegion    = "us-east5"
+	VertexAnthropicVersion = "vertex-2023-10-16" // anthropic_version sent in Vertex Claude request bodies
+	VertexTokenScope       = "https://www.googleapis.com/auth/cloud-platform"
+	VertexTimeout          = 10 * time.Minute // Client-side timeout for Vertex AI message requests
+)
+
+// DefaultVertexModels are served when VERTEX_MODELS is unset.
+// Vertex has no per-project model listing, so availability depends on the project and region.
+var DefaultVertexModels = []string{
+	"claude-opus-4-1@20250805",
+	"claude-sonnet-4-5@20250929",
+	"claude-haiku-4-5@20251001",
+	"gemini-2.5-pro",
+	"gemini-2.5-flash",
+}
+
+// Health/Status endpoint and startup timeouts
 const (
 	QuotaFetchTimeout = 15 * time.Second // Timeout for quota/status fetch operations
+
+	// QuotaCacheTTL is how long an account's fetched Antigravity quotas are reused by the
+	// health endpoint and provider status before they are fetched again.
+	QuotaCacheTTL = 30 * time.Second
+
+	// ModelPrefetchTimeout bounds each per-account model fetch during provider startup.
+	ModelPrefetchTimeout = 10 * time.Second
 )
 
 // Antigravity API configuration
@@ -128,6 +261,20 @@ var (
 	AntigravitySystemInstruction = `You are Antigravity, a powerful agentic AI coding assistant designed by the Google Deepmind team working on Advanced Agentic Coding.You are pair programming with a USER to solve their coding task. The task may require creating a new codebase, modifying or debugging an existing codebase, or simply answering a question.**Absolute paths only****Proactiveness**`
 )
 
+// Antigravity endpoint circuit breaker: an endpoint failing this many requests in a row
+// (5xx or connection errors) is skipped for the cooldown, then probed with one request.
+const (
+	AntigravityBreakerFailureThreshold = 5
+	AntigravityBreakerCooldown         = 30 * time.Second
+)
+
+// Antigravity endpoint routing: endpoints are tried fastest first by a moving average of
+// their response times, after those whose recent error rate reaches the threshold.
+const (
+	AntigravityEndpointStatsDecay      = 0.2 // EWMA weight of the newest response
+	AntigravityEndpointUnhealthyErrors = 0.5 // Error rate at which an endpoint is tried last
+)
+
 // ModelFallbackMap maps primary models to fallback models when quota is exhausted.
 var ModelFallbackMap = map[string]string{
 	"gemini-3-pro-high":          "claude-opus-4-5-thinking",
@@ -158,8 +305,35 @@ func GetAccountConfigPath() string {
 	if envPath := os.Getenv("ACCOUNTS_CONFIG_PATH"); envPath != "" {
 		return envPath
 	}
+	return filepath.Join(GetDataDir(), "accounts.json")
+}
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// GetProfile returns the active profile from MCP_PROFILE (set by --profile), or "" for the
+// default profile.
+func GetProfile() string {
+	return strings.TrimSpace(os.Getenv("MCP_PROFILE"))
+}
+
+// ValidateProfile checks that name can be used as a profile (and directory) name.
+func ValidateProfile(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use letters, digits, '-' or '_')", name)
+	}
+	return nil
+}
+
+// GetDataDir returns the directory holding the config file, accounts and the data derived
+// from them (backups, audit logs): ~/.config/multi-claude-proxy, or
+// ~/.config/multi-claude-proxy/profiles/<name> for a named profile, so profiles share nothing.
+func GetDataDir() string {
 	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".config/multi-claude-proxy/accounts.json")
+	dir := filepath.Join(home, ".config/multi-claude-proxy")
+	if profile := GetProfile(); profile != "" {
+		dir = filepath.Join(dir, "profiles", profile)
+	}
+	return dir
 }
 
 // ModelFamily represents the family of a model.
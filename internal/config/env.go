@@ -3,7 +3,10 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -97,6 +100,207 @@ func ValidateRequiredEnvVars() error {
 	return nil
 }
 
+// BodyLimitConfig holds the maximum request body size, in bytes, per endpoint.
+type BodyLimitConfig struct {
+	Default  int64 // Endpoints without their own limit
+	Messages int64 // /v1/messages
+	Images   int64 // /v1/images/generate and /v1/images/edit
+}
+
+// ForPath returns the body limit that applies to a request path.
+func (c BodyLimitConfig) ForPath(path string) int64 {
+	switch path {
+	case "/v1/messages":
+		return c.Messages
+	case "/v1/images/generate", "/v1/images/edit":
+		return c.Images
+	default:
+		return c.Default
+	}
+}
+
+// GetBodyLimitConfig returns the request body limits from environment variables.
+// Uses REQUEST_BODY_LIMIT_MB, MESSAGES_BODY_LIMIT_MB, IMAGES_BODY_LIMIT_MB; endpoint limits
+// default to REQUEST_BODY_LIMIT_MB.
+func GetBodyLimitConfig() BodyLimitConfig {
+	defaultLimit := envMegabytes("REQUEST_BODY_LIMIT_MB", RequestBodyLimit)
+	return BodyLimitConfig{
+		Default:  defaultLimit,
+		Messages: envMegabytes("MESSAGES_BODY_LIMIT_MB", defaultLimit),
+		Images:   envMegabytes("IMAGES_BODY_LIMIT_MB", defaultLimit),
+	}
+}
+
+// GetMaxUpstreamRequestBytes returns the largest /v1/messages request body, in bytes, that
+// is sent on to a provider; 0 means no limit beyond MESSAGES_BODY_LIMIT_MB.
+// Uses MAX_UPSTREAM_REQUEST_KB, overridable per provider (e.g. COPILOT_MAX_UPSTREAM_REQUEST_KB).
+func GetMaxUpstreamRequestBytes(provider string) int64 {
+	kb := GetEnvInt("MAX_UPSTREAM_REQUEST_KB", 0)
+	if provider != "" {
+		kb = GetEnvInt(EnvName(provider)+"_MAX_UPSTREAM_REQUEST_KB", kb)
+	}
+	if kb <= 0 {
+		return 0
+	}
+	return int64(kb) * 1024
+}
+
+// RequestTimeouts bounds a /v1/messages request and its phases; 0 sets no deadline.
+type RequestTimeouts struct {
+	Request    time.Duration // The whole request, from routing to the last byte of the response
+	TokenFetch time.Duration // Getting an account's access token (OAuth refresh, token exchange)
+	Connect    time.Duration // Each upstream attempt, until the upstream's response headers arrive
+	FirstToken time.Duration // A stream, from its start until its first event
+	Stream     time.Duration // A stream, from its start until its last event
+}
+
+// GetRequestTimeouts returns the request timeouts of a provider. Uses REQUEST_TIMEOUT,
+// TOKEN_FETCH_TIMEOUT, UPSTREAM_CONNECT_TIMEOUT, FIRST_TOKEN_TIMEOUT and STREAM_TIMEOUT
+// (all 0 by default), each overridable per provider (e.g. ZAI_FIRST_TOKEN_TIMEOUT).
+func GetRequestTimeouts(provider string) RequestTimeouts {
+	get := func(name string) time.Duration {
+		d := GetEnvDuration(name, 0)
+		if provider != "" {
+			d = GetEnvDuration(EnvName(provider)+"_"+name, d)
+		}
+		return max(d, 0)
+	}
+	return RequestTimeouts{
+		Request:    get("REQUEST_TIMEOUT"),
+		TokenFetch: get("TOKEN_FETCH_TIMEOUT"),
+		Connect:    get("UPSTREAM_CONNECT_TIMEOUT"),
+		FirstToken: get("FIRST_TOKEN_TIMEOUT"),
+		Stream:     get("STREAM_TIMEOUT"),
+	}
+}
+
+// GetStreamRecoveryAttempts returns how many times a stream that fails after it has started
+// is resumed on another account; 0 disables recovery. Uses STREAM_RECOVERY (default false),
+// overridable per provider (e.g. ZAI_STREAM_RECOVERY), and STREAM_RECOVERY_MAX_ATTEMPTS
+// (default 1).
+func GetStreamRecoveryAttempts(provider string) int {
+	enabled := GetEnvBool("STREAM_RECOVERY", false)
+	if provider != "" {
+		enabled = GetEnvBool(EnvName(provider)+"_STREAM_RECOVERY", enabled)
+	}
+	if !enabled {
+		return 0
+	}
+	return max(GetEnvInt("STREAM_RECOVERY_MAX_ATTEMPTS", 1), 0)
+}
+
+// SystemPromptConfig is the operator's system prompt for one provider's requests.
+type SystemPromptConfig struct {
+	Prefix      string // Added before the client's system prompt
+	Suffix      string // Added after the client's system prompt
+	StripClient bool   // Drop the client's system prompt
+}
+
+// Enabled reports whether the system prompt of requests is changed.
+func (c SystemPromptConfig) Enabled() bool {
+	return c.Prefix != "" || c.Suffix != "" || c.StripClient
+}
+
+// GetSystemPromptConfig returns the system prompt configuration for provider.
+// Uses SYSTEM_PROMPT_PREFIX, SYSTEM_PROMPT_SUFFIX and SYSTEM_PROMPT_STRIP_CLIENT, each
+// overridable per provider (e.g. ZAI_SYSTEM_PROMPT_PREFIX). A per-provider setting that is
+// set but empty clears the global one for that provider.
+func GetSystemPromptConfig(provider string) SystemPromptConfig {
+	cfg := SystemPromptConfig{
+		Prefix:      os.Getenv("SYSTEM_PROMPT_PREFIX"),
+		Suffix:      os.Getenv("SYSTEM_PROMPT_SUFFIX"),
+		StripClient: GetEnvBool("SYSTEM_PROMPT_STRIP_CLIENT", false),
+	}
+	if provider == "" {
+		return cfg
+	}
+	name := EnvName(provider)
+	if v, ok := os.LookupEnv(name + "_SYSTEM_PROMPT_PREFIX"); ok {
+		cfg.Prefix = v
+	}
+	if v, ok := os.LookupEnv(name + "_SYSTEM_PROMPT_SUFFIX"); ok {
+		cfg.Suffix = v
+	}
+	cfg.StripClient = GetEnvBool(name+"_SYSTEM_PROMPT_STRIP_CLIENT", cfg.StripClient)
+	return cfg
+}
+
+// RequestGuard limits the shape of a /v1/messages request; zero fields are unlimited.
+type RequestGuard struct {
+	MaxMessages  int // Entries in messages
+	MaxTextChars int // Characters of text in the system prompt, messages and tool results
+	MaxImages    int // Image blocks, including those inside tool results
+}
+
+// RequestGuardConfig holds the per-model request guards, keyed by model pattern.
+type RequestGuardConfig struct {
+	messages  map[string]int
+	textChars map[string]int
+	images    map[string]int
+}
+
+// GetRequestGuardConfig returns the request guards from MAX_REQUEST_MESSAGES,
+// MAX_REQUEST_TEXT_CHARS and MAX_REQUEST_IMAGES. Each is a comma-separated list of
+// pattern=limit pairs, where the pattern is a model ("glm-4.7" or "zai/glm-4.7"), all models
+// of a provider ("zai/*") or every model ("*"), e.g. "*=400,glm-4.7=150".
+func GetRequestGuardConfig() RequestGuardConfig {
+	return RequestGuardConfig{
+		messages:  envLimitPairs("MAX_REQUEST_MESSAGES"),
+		textChars: envLimitPairs("MAX_REQUEST_TEXT_CHARS"),
+		images:    envLimitPairs("MAX_REQUEST_IMAGES"),
+	}
+}
+
+// Enabled reports whether any guard is configured.
+func (c RequestGuardConfig) Enabled() bool {
+	return len(c.messages) > 0 || len(c.textChars) > 0 || len(c.images) > 0
+}
+
+// ForModel returns the guard for a provider's model. The most specific pattern wins:
+// provider/model, then model, then provider/*, then *.
+func (c RequestGuardConfig) ForModel(provider, model string) RequestGuard {
+	return RequestGuard{
+		MaxMessages:  limitForModel(c.messages, provider, model),
+		MaxTextChars: limitForModel(c.textChars, provider, model),
+		MaxImages:    limitForModel(c.images, provider, model),
+	}
+}
+
+func limitForModel(limits map[string]int, provider, model string) int {
+	for _, key := range []string{provider + "/" + model, model, provider + "/*", "*"} {
+		if n, ok := limits[key]; ok {
+			return n
+		}
+	}
+	return 0
+}
+
+// envLimitPairs parses pattern=limit pairs; pairs with a non-positive or invalid limit are ignored.
+func envLimitPairs(key string) map[string]int {
+	var limits map[string]int
+	for _, pair := range GetEnvStringSlice(key, nil) {
+		pattern, value, ok := strings.Cut(pair, "=")
+		pattern = strings.TrimSpace(pattern)
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || pattern == "" || err != nil || n <= 0 {
+			continue
+		}
+		if limits == nil {
+			limits = make(map[string]int)
+		}
+		limits[pattern] = n
+	}
+	return limits
+}
+
+// envMegabytes returns a positive size in megabytes from an environment variable as bytes, or the default.
+func envMegabytes(key string, defaultBytes int64) int64 {
+	if mb := GetEnvInt(key, 0); mb > 0 {
+		return int64(mb) * 1024 * 1024
+	}
+	return defaultBytes
+}
+
 // CORSConfig holds CORS configuration.
 type CORSConfig struct {
 	Enabled      bool
@@ -143,7 +347,745 @@ func GetSoftLimitThreshold() float64 {
 	return GetEnvFloat("SOFT_LIMIT_THRESHOLD", DefaultSoftLimitThreshold)
 }
 
+// Soft limit scheduler modes (SOFT_LIMIT_SCHEDULER).
+const (
+	SoftLimitSchedulerProvider = "provider" // Soft limits only order accounts within a provider
+	SoftLimitSchedulerGlobal   = "global"   // Equivalent models on other providers come before soft-limited accounts
+)
+
+// GetSoftLimitScheduler returns how soft limits steer requests: SoftLimitSchedulerProvider
+// (default) or SoftLimitSchedulerGlobal. Uses SOFT_LIMIT_SCHEDULER.
+func GetSoftLimitScheduler() string {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("SOFT_LIMIT_SCHEDULER")), SoftLimitSchedulerGlobal) {
+		return SoftLimitSchedulerGlobal
+	}
+	return SoftLimitSchedulerProvider
+}
+
+// GetModelEquivalents returns, for each "provider/model", the models on other providers
+// that can serve its requests instead, in order of preference. Uses MODEL_EQUIVALENTS
+// (comma-separated model=equivalent pairs, several equivalents separated by "|", e.g.
+// "antigravity/claude-sonnet-4-5=anthropic/claude-sonnet-4-5|vertex/claude-sonnet-4-5").
+func GetModelEquivalents() map[string][]string {
+	var equivalents map[string][]string
+	for _, pair := range GetEnvStringSlice("MODEL_EQUIVALENTS", nil) {
+		model, targets, ok := strings.Cut(pair, "=")
+		model = strings.TrimSpace(model)
+		if !ok || model == "" {
+			continue
+		}
+		for _, target := range strings.Split(targets, "|") {
+			if target = strings.TrimSpace(target); target != "" && target != model {
+				if equivalents == nil {
+					equivalents = make(map[string][]string)
+				}
+				equivalents[model] = append(equivalents[model], target)
+			}
+		}
+	}
+	return equivalents
+}
+
+// Startup preflight modes (STARTUP_PREFLIGHT).
+const (
+	PreflightOff    = "off"    // No preflight
+	PreflightReport = "report" // Check every account and print a report
+	PreflightStrict = "strict" // Like report, but refuse to start when no account is usable
+)
+
+// GetStartupPreflight returns the startup preflight mode: PreflightOff (default),
+// PreflightReport or PreflightStrict. Uses STARTUP_PREFLIGHT.
+func GetStartupPreflight() string {
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv("STARTUP_PREFLIGHT"))); mode {
+	case PreflightReport, PreflightStrict:
+		return mode
+	}
+	return PreflightOff
+}
+
 // GetDebugEnabled returns whether debug mode is enabled.
 func GetDebugEnabled() bool {
 	return GetEnvBool("DEBUG", false)
 }
+
+// AuditConfig holds request/response audit log configuration.
+type AuditConfig struct {
+	Enabled    bool
+	Dir        string
+	LogBodies  bool
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+// GetAuditConfig returns the audit log configuration from environment variables.
+// Uses AUDIT_LOG_ENABLED, AUDIT_LOG_DIR, AUDIT_LOG_BODIES, AUDIT_LOG_MAX_SIZE_MB, AUDIT_LOG_MAX_BACKUPS.
+func GetAuditConfig() AuditConfig {
+	return AuditConfig{
+		Enabled:    GetEnvBool("AUDIT_LOG_ENABLED", false),
+		Dir:        getEnvOrDefault("AUDIT_LOG_DIR", filepath.Join(filepath.Dir(GetAccountConfigPath()), "audit")),
+		LogBodies:  GetEnvBool("AUDIT_LOG_BODIES", false),
+		MaxSizeMB:  GetEnvInt("AUDIT_LOG_MAX_SIZE_MB", 50),
+		MaxBackups: GetEnvInt("AUDIT_LOG_MAX_BACKUPS", 5),
+	}
+}
+
+// BackupConfig holds scheduled state backup settings.
+type BackupConfig struct {
+	Enabled   bool
+	Dir       string
+	Interval  time.Duration
+	Retention int
+}
+
+// GetBackupConfig returns the state backup configuration from environment variables.
+// Uses BACKUP_ENABLED, BACKUP_DIR, BACKUP_INTERVAL, BACKUP_RETENTION.
+func GetBackupConfig() BackupConfig {
+	return BackupConfig{
+		Enabled:   GetEnvBool("BACKUP_ENABLED", true),
+		Dir:       getEnvOrDefault("BACKUP_DIR", filepath.Join(filepath.Dir(GetAccountConfigPath()), "backups")),
+		Interval:  GetEnvDuration("BACKUP_INTERVAL", DefaultBackupInterval),
+		Retention: GetEnvInt("BACKUP_RETENTION", DefaultBackupRetention),
+	}
+}
+
+// GetHealthRefreshInterval returns how often the background refresher rebuilds the /health report.
+// Uses HEALTH_REFRESH_INTERVAL; 0 disables the refresher so every /health call fetches quotas live.
+func GetHealthRefreshInterval() time.Duration {
+	return GetEnvDuration("HEALTH_REFRESH_INTERVAL", DefaultHealthRefreshInterval)
+}
+
+// GetAccountsSyncInterval returns how often a running server checks accounts.json for
+// changes saved by another process, such as the accounts CLI.
+// Uses ACCOUNTS_SYNC_INTERVAL; 0 disables the check, so changes apply on the next save.
+func GetAccountsSyncInterval() time.Duration {
+	return GetEnvDuration("ACCOUNTS_SYNC_INTERVAL", DefaultAccountsSyncInterval)
+}
+
+// AccountRevalidationConfig holds the settings of the background job that re-tests invalid
+// accounts, so those invalidated by a transient error recover without manual re-authentication.
+type AccountRevalidationConfig struct {
+	Interval    time.Duration // 0 disables the job
+	MaxAttempts int           // Re-tests per account while it stays invalid; 0 = no limit
+}
+
+// GetAccountRevalidationConfig returns the re-validation configuration from environment variables.
+// Uses ACCOUNT_REVALIDATE_INTERVAL and ACCOUNT_REVALIDATE_MAX_ATTEMPTS.
+func GetAccountRevalidationConfig() AccountRevalidationConfig {
+	return AccountRevalidationConfig{
+		Interval:    GetEnvDuration("ACCOUNT_REVALIDATE_INTERVAL", DefaultRevalidateInterval),
+		MaxAttempts: max(GetEnvInt("ACCOUNT_REVALIDATE_MAX_ATTEMPTS", DefaultRevalidateMaxAttempts), 0),
+	}
+}
+
+// GetAccountsEncryptionKey returns the passphrase credentials in accounts.json are
+// encrypted with, or "" when they are stored in plaintext. Uses ACCOUNTS_ENCRYPTION_KEY,
+// or the content of the file named by ACCOUNTS_ENCRYPTION_KEY_FILE.
+func GetAccountsEncryptionKey() (string, error) {
+	if key := os.Getenv("ACCOUNTS_ENCRYPTION_KEY"); key != "" {
+		return key, nil
+	}
+	path := os.Getenv("ACCOUNTS_ENCRYPTION_KEY_FILE")
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read ACCOUNTS_ENCRYPTION_KEY_FILE: %w", err)
+	}
+	key := strings.TrimRight(string(data), "\r\n")
+	if key == "" {
+		return "", fmt.Errorf("ACCOUNTS_ENCRYPTION_KEY_FILE %s is empty", path)
+	}
+	return key, nil
+}
+
+// GetStreamPingInterval returns how long a stream may be idle before a ping event is sent.
+// Uses STREAM_PING_INTERVAL; 0 disables idle pings (the ping after message_start is always sent).
+func GetStreamPingInterval() time.Duration {
+	return max(0, GetEnvDuration("STREAM_PING_INTERVAL", DefaultStreamPingInterval))
+}
+
+// GetStreamTraceComment reports whether /v1/messages streams end with an SSE comment naming
+// the provider and account that served them. Uses STREAM_TRACE_COMMENT (default false).
+func GetStreamTraceComment() bool {
+	return GetEnvBool("STREAM_TRACE_COMMENT", false)
+}
+
+// GetStreamFastPathEnabled reports whether native Anthropic streams are forwarded without
+// decoding each event, rewriting only the fields the proxy changes. Uses
+// STREAM_FAST_PATH_ENABLED (default false).
+func GetStreamFastPathEnabled() bool {
+	return GetEnvBool("STREAM_FAST_PATH_ENABLED", false)
+}
+
+// ImageToolConfig controls the image generation tool whose calls the proxy runs itself.
+type ImageToolConfig struct {
+	Enabled bool
+	Name    string // Tool name the proxy intercepts
+	Inject  bool   // Add the tool to requests that carry tools but don't list it
+	Model   string // Image model the tool generates with
+}
+
+// GetImageToolConfig returns the image tool configuration from environment variables.
+// Uses IMAGE_TOOL_ENABLED, IMAGE_TOOL_NAME, IMAGE_TOOL_INJECT, IMAGE_TOOL_MODEL.
+func GetImageToolConfig() ImageToolConfig {
+	return ImageToolConfig{
+		Enabled: GetEnvBool("IMAGE_TOOL_ENABLED", true),
+		Name:    getEnvOrDefault("IMAGE_TOOL_NAME", DefaultImageToolName),
+		Inject:  GetEnvBool("IMAGE_TOOL_INJECT", false),
+		Model:   getEnvOrDefault("IMAGE_TOOL_MODEL", DefaultImageModel),
+	}
+}
+
+// ProbeConfig controls the liveness/readiness probe paths and graceful shutdown behavior.
+type ProbeConfig struct {
+	LivePath        string        // Liveness probe path, served in addition to /health/live
+	ReadyPath       string        // Readiness probe path, served in addition to /health/ready
+	PreStopPath     string        // preStop hook path that marks the server not ready and waits for in-flight requests
+	DrainDelay      time.Duration // How long to keep serving after readiness turns 503, so load balancers stop routing
+	ShutdownTimeout time.Duration // How long in-flight requests get to finish once the listener is closed
+}
+
+// GetProbeConfig returns the probe and shutdown configuration from environment variables.
+// Uses PROBE_LIVE_PATH, PROBE_READY_PATH, PRESTOP_PATH, SHUTDOWN_DRAIN_DELAY, SHUTDOWN_TIMEOUT.
+func GetProbeConfig() ProbeConfig {
+	cfg := ProbeConfig{
+		LivePath:        getEnvOrDefault("PROBE_LIVE_PATH", DefaultProbeLivePath),
+		ReadyPath:       getEnvOrDefault("PROBE_READY_PATH", DefaultProbeReadyPath),
+		PreStopPath:     getEnvOrDefault("PRESTOP_PATH", DefaultPreStopPath),
+		DrainDelay:      max(0, GetEnvDuration("SHUTDOWN_DRAIN_DELAY", 0)),
+		ShutdownTimeout: GetEnvDuration("SHUTDOWN_TIMEOUT", DefaultShutdownTimeout),
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	return cfg
+}
+
+// Thinking signature recovery modes for GetThinkingSignatureRecovery.
+const (
+	ThinkingRecoveryDrop    = "drop"    // Drop thinking blocks whose signature origin is unknown (default)
+	ThinkingRecoveryText    = "text"    // Keep their reasoning as plain text context
+	ThinkingRecoveryRefresh = "refresh" // Drop them and have the model think afresh when it mattered
+)
+
+// GetThinkingSignatureRecovery returns how thinking blocks with unknown signatures are handled
+// (e.g. after a restart cleared the signature cache) and, with ThinkingRecoveryRefresh, those
+// of another model family. Uses THINKING_SIGNATURE_RECOVERY.
+func GetThinkingSignatureRecovery() string {
+	switch strings.ToLower(os.Getenv("THINKING_SIGNATURE_RECOVERY")) {
+	case ThinkingRecoveryText:
+		return ThinkingRecoveryText
+	case ThinkingRecoveryRefresh:
+		return ThinkingRecoveryRefresh
+	default:
+		return ThinkingRecoveryDrop
+	}
+}
+
+// ThinkingBudget bounds the thinking budget_tokens of a model; a zero Max is unlimited.
+type ThinkingBudget struct {
+	Min     int
+	Max     int
+	Default int // Used when thinking is enabled without a budget, or auto-enabled
+}
+
+// Clamp returns budget within b, or b's default for a zero budget.
+func (b ThinkingBudget) Clamp(budget int) int {
+	if budget <= 0 {
+		budget = b.Default
+	}
+	if b.Max > 0 && budget > b.Max {
+		budget = b.Max
+	}
+	return max(budget, b.Min)
+}
+
+// ThinkingPolicyConfig holds the providers the thinking policy applies to, the per-model
+// thinking budgets, keyed by model pattern, and the models thinking is stripped from.
+type ThinkingPolicyConfig struct {
+	providers   map[string]bool
+	min         map[string]int
+	max         map[string]int
+	def         map[string]int
+	unsupported map[string]bool
+}
+
+// GetThinkingPolicyConfig returns the thinking policy from THINKING_POLICY_PROVIDERS, a
+// comma-separated list of providers (none by default), THINKING_BUDGET_MIN,
+// THINKING_BUDGET_MAX and THINKING_BUDGET_DEFAULT, pattern=budget pairs like
+// MAX_REQUEST_MESSAGES, and THINKING_UNSUPPORTED_MODELS, a comma-separated list of patterns.
+func GetThinkingPolicyConfig() ThinkingPolicyConfig {
+	cfg := ThinkingPolicyConfig{
+		min: envLimitPairs("THINKING_BUDGET_MIN"),
+		max: envLimitPairs("THINKING_BUDGET_MAX"),
+		def: envLimitPairs("THINKING_BUDGET_DEFAULT"),
+	}
+	for _, name := range GetEnvStringSlice("THINKING_POLICY_PROVIDERS", nil) {
+		if cfg.providers == nil {
+			cfg.providers = make(map[string]bool)
+		}
+		cfg.providers[name] = true
+	}
+	for _, pattern := range GetEnvStringSlice("THINKING_UNSUPPORTED_MODELS", nil) {
+		if cfg.unsupported == nil {
+			cfg.unsupported = make(map[string]bool)
+		}
+		cfg.unsupported[pattern] = true
+	}
+	return cfg
+}
+
+// Enabled reports whether THINKING_POLICY_PROVIDERS lists provider.
+func (c ThinkingPolicyConfig) Enabled(provider string) bool {
+	return c.providers[provider]
+}
+
+// ForModel returns the thinking budget bounds of a provider's model. The most specific
+// pattern wins, as in RequestGuardConfig.ForModel; unset bounds default to
+// DefaultThinkingBudgetMin and DefaultThinkingBudget.
+func (c ThinkingPolicyConfig) ForModel(provider, model string) ThinkingBudget {
+	b := ThinkingBudget{
+		Min:     limitForModel(c.min, provider, model),
+		Max:     limitForModel(c.max, provider, model),
+		Default: limitForModel(c.def, provider, model),
+	}
+	if b.Min == 0 {
+		b.Min = DefaultThinkingBudgetMin
+	}
+	if b.Default == 0 {
+		b.Default = DefaultThinkingBudget
+	}
+	return b
+}
+
+// Unsupported reports whether THINKING_UNSUPPORTED_MODELS lists a provider's model.
+func (c ThinkingPolicyConfig) Unsupported(provider, model string) bool {
+	for _, key := range []string{provider + "/" + model, model, provider + "/*", "*"} {
+		if c.unsupported[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// Copilot refusal modes for GetCopilotRefusalMode.
+const (
+	RefusalModeStopReason = "stop_reason" // Refusal text as a plain text block with stop_reason "refusal" (default)
+	RefusalModeText       = "text"        // Refusal text prefixed with "[Refusal]" and stop_reason "end_turn"
+)
+
+// GetCopilotRefusalMode returns how content-policy refusals from Copilot are represented.
+// Uses COPILOT_REFUSAL_MODE.
+func GetCopilotRefusalMode() string {
+	switch strings.ToLower(os.Getenv("COPILOT_REFUSAL_MODE")) {
+	case RefusalModeText:
+		return RefusalModeText
+	default:
+		return RefusalModeStopReason
+	}
+}
+
+// SignatureCacheConfig holds antigravity signature cache settings.
+type SignatureCacheConfig struct {
+	Path         string // Snapshot file; empty disables persistence
+	TTL          time.Duration
+	MaxEntries   int
+	MaxSessions  int // Conversations whose signatures are kept
+	SaveInterval time.Duration
+}
+
+// GetSignatureCacheConfig returns the signature cache configuration from environment variables.
+// Uses SIGNATURE_CACHE_PATH, SIGNATURE_CACHE_TTL, SIGNATURE_CACHE_MAX_ENTRIES,
+// SIGNATURE_CACHE_MAX_SESSIONS, SIGNATURE_CACHE_SAVE_INTERVAL.
+func GetSignatureCacheConfig() SignatureCacheConfig {
+	return SignatureCacheConfig{
+		Path:         os.Getenv("SIGNATURE_CACHE_PATH"),
+		TTL:          GetEnvDuration("SIGNATURE_CACHE_TTL", GeminiSignatureCacheTTL),
+		MaxEntries:   GetEnvInt("SIGNATURE_CACHE_MAX_ENTRIES", DefaultSignatureCacheMaxEntries),
+		MaxSessions:  GetEnvInt("SIGNATURE_CACHE_MAX_SESSIONS", DefaultSignatureCacheMaxSessions),
+		SaveInterval: GetEnvDuration("SIGNATURE_CACHE_SAVE_INTERVAL", DefaultSignatureCacheSaveInterval),
+	}
+}
+
+// GetLoadBalancer returns the account selection strategy from LOAD_BALANCER
+// ("round-robin", "least-loaded" or "quota-weighted").
+func GetLoadBalancer() string {
+	return getEnvOrDefault("LOAD_BALANCER", "round-robin")
+}
+
+// AccountHealthConfig controls how request outcomes deprioritize flaky accounts.
+type AccountHealthConfig struct {
+	Enabled          bool
+	FailureThreshold float64       // EWMA failure score (0-1) that triggers deprioritization
+	Cooldown         time.Duration // How long a flaky account stays deprioritized
+	SlowThreshold    time.Duration // Requests slower than this count as failures; 0 disables
+}
+
+// GetAccountHealthConfig returns the account health configuration from environment variables.
+// Uses ACCOUNT_HEALTH_ENABLED, ACCOUNT_HEALTH_FAILURE_THRESHOLD, ACCOUNT_HEALTH_COOLDOWN, ACCOUNT_HEALTH_SLOW_THRESHOLD.
+func GetAccountHealthConfig() AccountHealthConfig {
+	return AccountHealthConfig{
+		Enabled:          GetEnvBool("ACCOUNT_HEALTH_ENABLED", true),
+		FailureThreshold: GetEnvFloat("ACCOUNT_HEALTH_FAILURE_THRESHOLD", DefaultHealthFailureThreshold),
+		Cooldown:         GetEnvDuration("ACCOUNT_HEALTH_COOLDOWN", DefaultHealthCooldown),
+		SlowThreshold:    GetEnvDuration("ACCOUNT_HEALTH_SLOW_THRESHOLD", 0),
+	}
+}
+
+// StickyErrorConfig controls how repeated deterministic failures (e.g. 403 permission
+// denied for a model) take an account out of selection for that model.
+type StickyErrorConfig struct {
+	TTL       time.Duration // How long a failing account/model pair is skipped; 0 disables
+	Threshold int           // Identical consecutive failures before the pair is skipped
+}
+
+// GetStickyErrorConfig returns the sticky error configuration from environment variables.
+// Uses STICKY_ERROR_TTL, STICKY_ERROR_THRESHOLD.
+func GetStickyErrorConfig() StickyErrorConfig {
+	return StickyErrorConfig{
+		TTL:       GetEnvDuration("STICKY_ERROR_TTL", DefaultStickyErrorTTL),
+		Threshold: max(1, GetEnvInt("STICKY_ERROR_THRESHOLD", DefaultStickyErrorThreshold)),
+	}
+}
+
+// ResponseCacheConfig holds response cache configuration.
+type ResponseCacheConfig struct {
+	Enabled    bool
+	TTL        time.Duration
+	MaxEntries int
+}
+
+// GetResponseCacheConfig returns the response cache configuration from environment variables.
+// Uses RESPONSE_CACHE_ENABLED, RESPONSE_CACHE_TTL, RESPONSE_CACHE_MAX_ENTRIES.
+func GetResponseCacheConfig() ResponseCacheConfig {
+	return ResponseCacheConfig{
+		Enabled:    GetEnvBool("RESPONSE_CACHE_ENABLED", false),
+		TTL:        GetEnvDuration("RESPONSE_CACHE_TTL", 5*time.Minute),
+		MaxEntries: GetEnvInt("RESPONSE_CACHE_MAX_ENTRIES", 500),
+	}
+}
+
+// GetRequestCoalescingEnabled reports whether identical concurrent non-streaming
+// /v1/messages requests share one upstream call. Uses REQUEST_COALESCING_ENABLED.
+func GetRequestCoalescingEnabled() bool {
+	return GetEnvBool("REQUEST_COALESCING_ENABLED", false)
+}
+
+// SessionAffinityConfig holds the conversation store settings.
+type SessionAffinityConfig struct {
+	Enabled      bool
+	TTL          time.Duration // Since the session's last turn
+	MaxEntries   int
+	Path         string // Snapshot file; empty keeps sessions in memory only
+	SaveInterval time.Duration
+}
+
+// GetSessionAffinityConfig returns the conversation store configuration from environment
+// variables. Uses SESSION_AFFINITY_ENABLED, SESSION_AFFINITY_TTL, SESSION_AFFINITY_MAX_ENTRIES,
+// SESSION_AFFINITY_PATH and SESSION_AFFINITY_SAVE_INTERVAL.
+func GetSessionAffinityConfig() SessionAffinityConfig {
+	return SessionAffinityConfig{
+		Enabled:      GetEnvBool("SESSION_AFFINITY_ENABLED", false),
+		TTL:          GetEnvDuration("SESSION_AFFINITY_TTL", time.Hour),
+		MaxEntries:   GetEnvInt("SESSION_AFFINITY_MAX_ENTRIES", 10000),
+		Path:         os.Getenv("SESSION_AFFINITY_PATH"),
+		SaveInterval: GetEnvDuration("SESSION_AFFINITY_SAVE_INTERVAL", time.Minute),
+	}
+}
+
+// ConcurrencyConfig caps simultaneous in-flight /v1/messages requests. A zero limit is disabled.
+type ConcurrencyConfig struct {
+	PerProvider  int
+	PerModel     int // Per provider/model pair
+	PerAccount   int
+	QueueTimeout time.Duration // How long a request waits for a free slot before a 429; 0 rejects immediately
+}
+
+// Enabled reports whether any concurrency limit is set.
+func (c ConcurrencyConfig) Enabled() bool {
+	return c.PerProvider > 0 || c.PerModel > 0 || c.PerAccount > 0
+}
+
+// GetConcurrencyConfig returns the concurrency limits from environment variables.
+// Uses MAX_CONCURRENT_PER_PROVIDER, MAX_CONCURRENT_PER_MODEL, MAX_CONCURRENT_PER_ACCOUNT, CONCURRENCY_QUEUE_TIMEOUT.
+func GetConcurrencyConfig() ConcurrencyConfig {
+	return ConcurrencyConfig{
+		PerProvider:  GetEnvInt("MAX_CONCURRENT_PER_PROVIDER", 0),
+		PerModel:     GetEnvInt("MAX_CONCURRENT_PER_MODEL", 0),
+		PerAccount:   GetEnvInt("MAX_CONCURRENT_PER_ACCOUNT", 0),
+		QueueTimeout: GetEnvDuration("CONCURRENCY_QUEUE_TIMEOUT", 30*time.Second),
+	}
+}
+
+// ClientRateLimitConfig caps the request and token rates of downstream clients, per API key
+// and across all clients. A zero rate is disabled.
+type ClientRateLimitConfig struct {
+	RPS   float64 // Requests per second per API key
+	Burst int     // Requests per API key allowed at once above RPS
+	TPM   int     // Input and output tokens per minute per API key
+
+	GlobalRPS   float64
+	GlobalBurst int
+	GlobalTPM   int
+}
+
+// Enabled reports whether any client rate limit is set.
+func (c ClientRateLimitConfig) Enabled() bool {
+	return c.RPS > 0 || c.TPM > 0 || c.GlobalRPS > 0 || c.GlobalTPM > 0
+}
+
+// GetClientRateLimitConfig returns the client rate limits from environment variables.
+// Uses RATE_LIMIT_RPS, RATE_LIMIT_BURST, RATE_LIMIT_TPM, RATE_LIMIT_GLOBAL_RPS,
+// RATE_LIMIT_GLOBAL_BURST and RATE_LIMIT_GLOBAL_TPM. A burst defaults to one second of requests.
+func GetClientRateLimitConfig() ClientRateLimitConfig {
+	cfg := ClientRateLimitConfig{
+		RPS:       max(0, GetEnvFloat("RATE_LIMIT_RPS", 0)),
+		TPM:       max(0, GetEnvInt("RATE_LIMIT_TPM", 0)),
+		GlobalRPS: max(0, GetEnvFloat("RATE_LIMIT_GLOBAL_RPS", 0)),
+		GlobalTPM: max(0, GetEnvInt("RATE_LIMIT_GLOBAL_TPM", 0)),
+	}
+	cfg.Burst = GetEnvInt("RATE_LIMIT_BURST", max(1, int(math.Ceil(cfg.RPS))))
+	cfg.GlobalBurst = GetEnvInt("RATE_LIMIT_GLOBAL_BURST", max(1, int(math.Ceil(cfg.GlobalRPS))))
+	return cfg
+}
+
+// GetVirtualKeys returns the virtual API keys by name. Uses VIRTUAL_KEYS (comma-separated
+// name=key pairs). Virtual keys are accepted in place of PROXY_API_KEY on /v1 endpoints and
+// /usage, and can be given budgets (see GetBudgetConfig).
+func GetVirtualKeys() map[string]string {
+	var keys map[string]string
+	for _, pair := range GetEnvStringSlice("VIRTUAL_KEYS", nil) {
+		if name, key, ok := strings.Cut(pair, "="); ok && strings.TrimSpace(name) != "" && strings.TrimSpace(key) != "" {
+			if keys == nil {
+				keys = make(map[string]string)
+			}
+			keys[strings.TrimSpace(name)] = strings.TrimSpace(key)
+		}
+	}
+	return keys
+}
+
+// BudgetLimits caps the usage of one virtual key or account per UTC day and month; 0 is unlimited.
+type BudgetLimits struct {
+	DailyTokens     int `json:"dailyTokens,omitempty"`
+	MonthlyTokens   int `json:"monthlyTokens,omitempty"`
+	DailyRequests   int `json:"dailyRequests,omitempty"`
+	MonthlyRequests int `json:"monthlyRequests,omitempty"`
+}
+
+// Limited reports whether any cap is set.
+func (l BudgetLimits) Limited() bool {
+	return l.DailyTokens > 0 || l.MonthlyTokens > 0 || l.DailyRequests > 0 || l.MonthlyRequests > 0
+}
+
+// BudgetConfig holds the budgets of virtual keys and accounts.
+type BudgetConfig struct {
+	Path string // Usage file, so budgets survive restarts
+
+	keys     [4]map[string]int // Daily tokens, monthly tokens, daily requests, monthly requests
+	accounts [4]map[string]int
+}
+
+// GetBudgetConfig returns the budgets from KEY_BUDGET_DAILY_TOKENS, KEY_BUDGET_MONTHLY_TOKENS,
+// KEY_BUDGET_DAILY_REQUESTS and KEY_BUDGET_MONTHLY_REQUESTS (name=limit pairs, "*" for every
+// virtual key), the ACCOUNT_BUDGET_* equivalents (email=limit pairs, "*" for every account)
+// and BUDGET_USAGE_PATH.
+func GetBudgetConfig() BudgetConfig {
+	path := os.Getenv("BUDGET_USAGE_PATH")
+	if path == "" {
+		path = filepath.Join(GetDataDir(), "budget-usage.json")
+	}
+	cfg := BudgetConfig{Path: path}
+	for i, suffix := range []string{"DAILY_TOKENS", "MONTHLY_TOKENS", "DAILY_REQUESTS", "MONTHLY_REQUESTS"} {
+		cfg.keys[i] = envLimitPairs("KEY_BUDGET_" + suffix)
+		cfg.accounts[i] = envLimitPairs("ACCOUNT_BUDGET_" + suffix)
+	}
+	return cfg
+}
+
+// Enabled reports whether any budget is configured.
+func (c BudgetConfig) Enabled() bool {
+	for i := range c.keys {
+		if len(c.keys[i]) > 0 || len(c.accounts[i]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ForKey returns the budget of a virtual key: its own limits, else those for "*".
+func (c BudgetConfig) ForKey(name string) BudgetLimits {
+	return budgetLimits(c.keys, name)
+}
+
+// ForAccount returns the budget of an account: its own limits, else those for "*".
+func (c BudgetConfig) ForAccount(email string) BudgetLimits {
+	return budgetLimits(c.accounts, email)
+}
+
+func budgetLimits(limits [4]map[string]int, name string) BudgetLimits {
+	get := func(m map[string]int) int {
+		if n, ok := m[name]; ok {
+			return n
+		}
+		return m["*"]
+	}
+	return BudgetLimits{
+		DailyTokens:     get(limits[0]),
+		MonthlyTokens:   get(limits[1]),
+		DailyRequests:   get(limits[2]),
+		MonthlyRequests: get(limits[3]),
+	}
+}
+
+// QuotaConfig controls quota history tracking, exhaustion estimates and low-quota alerts.
+type QuotaConfig struct {
+	HistoryWindow   time.Duration // How far back samples are used to estimate the burn rate
+	AlertThresholds []float64     // Remaining fractions (0-1) that trigger an alert, highest first; empty disables alerts
+	AlertWebhook    string        // URL that receives alerts as JSON POSTs; alerts are always logged
+
+	// ReconcileTolerance is how far (as a factor) the tokens sent through an account may be
+	// from what its quota change implies before the difference is flagged; values below 1 (e.g. 0) disable it.
+	ReconcileTolerance float64
+}
+
+// GetQuotaConfig returns the quota tracking configuration from environment variables.
+// Uses QUOTA_HISTORY_WINDOW, QUOTA_ALERT_THRESHOLDS (comma-separated, e.g. "0.2,0.05"), QUOTA_ALERT_WEBHOOK,
+// QUOTA_RECONCILE_TOLERANCE.
+func GetQuotaConfig() QuotaConfig {
+	var thresholds []float64
+	for _, v := range GetEnvStringSlice("QUOTA_ALERT_THRESHOLDS", nil) {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+			thresholds = append(thresholds, f)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(thresholds)))
+
+	return QuotaConfig{
+		HistoryWindow:   GetEnvDuration("QUOTA_HISTORY_WINDOW", DefaultQuotaHistoryWindow),
+		AlertThresholds: thresholds,
+		AlertWebhook:    os.Getenv("QUOTA_ALERT_WEBHOOK"),
+
+		ReconcileTolerance: GetEnvFloat("QUOTA_RECONCILE_TOLERANCE", DefaultQuotaReconcileTolerance),
+	}
+}
+
+// NotifyConfig controls webhook notifications for account state changes.
+type NotifyConfig struct {
+	Webhooks   []string      // Target URLs; Slack and Discord webhook URLs get their native payload shape
+	Events     []string      // Event types to send; empty sends all
+	Template   string        // text/template for the message text; empty uses the built-in message
+	MaxRetries int           // Extra delivery attempts after a failure
+	RetryDelay time.Duration // Delay before the first retry, doubled after each attempt
+}
+
+// GetNotifyConfig returns the account state notification configuration from environment variables.
+// Uses NOTIFY_WEBHOOKS, NOTIFY_EVENTS (comma-separated), NOTIFY_TEMPLATE, NOTIFY_MAX_RETRIES, NOTIFY_RETRY_DELAY.
+func GetNotifyConfig() NotifyConfig {
+	return NotifyConfig{
+		Webhooks:   GetEnvStringSlice("NOTIFY_WEBHOOKS", nil),
+		Events:     GetEnvStringSlice("NOTIFY_EVENTS", nil),
+		Template:   os.Getenv("NOTIFY_TEMPLATE"),
+		MaxRetries: max(0, GetEnvInt("NOTIFY_MAX_RETRIES", DefaultNotifyMaxRetries)),
+		RetryDelay: GetEnvDuration("NOTIFY_RETRY_DELAY", DefaultNotifyRetryDelay),
+	}
+}
+
+// TelemetryConfig controls the opt-in anonymized usage report.
+type TelemetryConfig struct {
+	Enabled  bool          // Nothing is sent unless set
+	Endpoint string        // URL the report is POSTed to; required when enabled
+	Interval time.Duration // Time between reports
+}
+
+// GetTelemetryConfig returns the telemetry configuration from environment variables.
+// Uses TELEMETRY_ENABLED (default false), TELEMETRY_ENDPOINT, TELEMETRY_INTERVAL.
+func GetTelemetryConfig() TelemetryConfig {
+	return TelemetryConfig{
+		Enabled:  GetEnvBool("TELEMETRY_ENABLED", false),
+		Endpoint: os.Getenv("TELEMETRY_ENDPOINT"),
+		Interval: GetEnvDuration("TELEMETRY_INTERVAL", DefaultTelemetryInterval),
+	}
+}
+
+// TracingConfig controls OpenTelemetry trace export over OTLP/HTTP.
+type TracingConfig struct {
+	Endpoint    string            // Full OTLP traces URL; empty disables tracing
+	Headers     map[string]string // Extra headers sent with every export, e.g. for collector auth
+	ServiceName string            // Reported as the service.name resource attribute
+	SampleRatio float64           // Fraction of new traces recorded; incoming traceparent flags take precedence
+}
+
+// Enabled reports whether traces are exported.
+func (c TracingConfig) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+// GetTracingConfig returns the tracing configuration from the standard OpenTelemetry variables.
+// Uses OTEL_EXPORTER_OTLP_TRACES_ENDPOINT (or OTEL_EXPORTER_OTLP_ENDPOINT + "/v1/traces"),
+// OTEL_EXPORTER_OTLP_HEADERS (comma-separated Key=Value pairs), OTEL_SERVICE_NAME, OTEL_TRACES_SAMPLER_ARG.
+func GetTracingConfig() TracingConfig {
+	cfg := TracingConfig{
+		Endpoint:    os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+		ServiceName: getEnvOrDefault("OTEL_SERVICE_NAME", DefaultTracingServiceName),
+		SampleRatio: min(1, max(0, GetEnvFloat("OTEL_TRACES_SAMPLER_ARG", 1))),
+	}
+	if cfg.Endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			cfg.Endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+		}
+	}
+	for _, pair := range GetEnvStringSlice("OTEL_EXPORTER_OTLP_HEADERS", nil) {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			if cfg.Headers == nil {
+				cfg.Headers = make(map[string]string)
+			}
+			cfg.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return cfg
+}
+
+// GetAnthropicBaseURL returns the Anthropic API base URL from ANTHROPIC_BASE_URL.
+func GetAnthropicBaseURL() string {
+	return strings.TrimRight(getEnvOrDefault("ANTHROPIC_BASE_URL", AnthropicBaseURL), "/")
+}
+
+// VertexConfig holds Vertex AI provider configuration.
+type VertexConfig struct {
+	Region  string   // Default region for accounts without one ("global" uses the global endpoint)
+	Models  []string // Model IDs to serve
+	BaseURL string   // Overrides the regional endpoint host, e.g. for Private Service Connect
+}
+
+// GetVertexConfig returns the Vertex AI configuration from environment variables.
+// Uses VERTEX_REGION, VERTEX_MODELS, VERTEX_BASE_URL.
+func GetVertexConfig() VertexConfig {
+	return VertexConfig{
+		Region:  getEnvOrDefault("VERTEX_REGION", VertexDefaultRegion),
+		Models:  GetEnvStringSlice("VERTEX_MODELS", DefaultVertexModels),
+		BaseURL: strings.TrimRight(os.Getenv("VERTEX_BASE_URL"), "/"),
+	}
+}
+
+// IsProviderEnabled reports whether the named provider should be registered.
+// Uses <NAME>_ENABLED (default true), e.g. COPILOT_ENABLED=false.
+func IsProviderEnabled(name string) bool {
+	return GetEnvBool(EnvName(name)+"_ENABLED", true)
+}
+
+// GetModelAliases returns model aliases mapped to the model they resolve to.
+// Uses MODEL_ALIASES (comma-separated alias=model pairs, e.g. "fast=claude-haiku-4-5").
+func GetModelAliases() map[string]string {
+	var aliases map[string]string
+	for _, pair := range GetEnvStringSlice("MODEL_ALIASES", nil) {
+		if alias, model, ok := strings.Cut(pair, "="); ok && strings.TrimSpace(alias) != "" && strings.TrimSpace(model) != "" {
+			if aliases == nil {
+				aliases = make(map[string]string)
+			}
+			aliases[strings.TrimSpace(alias)] = strings.TrimSpace(model)
+		}
+	}
+	return aliases
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -349,6 +351,44 @@ func TestGetAccountConfigPath(t *testing.T) {
 	})
 }
 
+func TestProfileNamespacesPaths(t *testing.T) {
+	t.Setenv("HOME", "/home/u")
+	t.Setenv("ACCOUNTS_CONFIG_PATH", "")
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("AUDIT_LOG_DIR", "")
+	t.Setenv("BACKUP_DIR", "")
+
+	t.Setenv("MCP_PROFILE", "")
+	if got := GetAccountConfigPath(); got != "/home/u/.config/multi-claude-proxy/accounts.json" {
+		t.Errorf("default accounts path = %q", got)
+	}
+
+	t.Setenv("MCP_PROFILE", "work")
+	base := "/home/u/.config/multi-claude-proxy/profiles/work"
+	for name, got := range map[string]string{
+		"data dir":    GetDataDir(),
+		"accounts":    GetAccountConfigPath(),
+		"config file": GetConfigFilePath(),
+		"audit dir":   GetAuditConfig().Dir,
+		"backup dir":  GetBackupConfig().Dir,
+	} {
+		if !strings.HasPrefix(got, base) {
+			t.Errorf("%s = %q, want it under %s", name, got, base)
+		}
+	}
+
+	for _, name := range []string{"work", "team_2", "Personal-1"} {
+		if err := ValidateProfile(name); err != nil {
+			t.Errorf("ValidateProfile(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "../x", "a/b", "-x", "with space"} {
+		if err := ValidateProfile(name); err == nil {
+			t.Errorf("ValidateProfile(%q) accepted an invalid name", name)
+		}
+	}
+}
+
 func TestGetPort(t *testing.T) {
 	t.Run("returns default port when env not set", func(t *testing.T) {
 		os.Unsetenv("PORT")
@@ -497,3 +537,326 @@ func TestGetServerTimeouts(t *testing.T) {
 		}
 	})
 }
+
+func TestGetBodyLimitConfig(t *testing.T) {
+	const mb = 1024 * 1024
+
+	t.Run("defaults to the global limit", func(t *testing.T) {
+		t.Setenv("REQUEST_BODY_LIMIT_MB", "")
+		t.Setenv("MESSAGES_BODY_LIMIT_MB", "")
+		t.Setenv("IMAGES_BODY_LIMIT_MB", "")
+
+		cfg := GetBodyLimitConfig()
+		for _, path := range []string{"/v1/messages", "/v1/images/generate", "/other"} {
+			if got := cfg.ForPath(path); got != RequestBodyLimit {
+				t.Errorf("ForPath(%q) = %d, want %d", path, got, RequestBodyLimit)
+			}
+		}
+	})
+
+	t.Run("per-endpoint overrides", func(t *testing.T) {
+		t.Setenv("REQUEST_BODY_LIMIT_MB", "20")
+		t.Setenv("MESSAGES_BODY_LIMIT_MB", "100")
+		t.Setenv("IMAGES_BODY_LIMIT_MB", "0") // invalid, falls back to the global limit
+
+		cfg := GetBodyLimitConfig()
+		if cfg.ForPath("/v1/messages") != 100*mb {
+			t.Errorf("messages limit = %d, want %d", cfg.ForPath("/v1/messages"), 100*mb)
+		}
+		if cfg.ForPath("/v1/images/generate") != 20*mb {
+			t.Errorf("images limit = %d, want %d", cfg.ForPath("/v1/images/generate"), 20*mb)
+		}
+		if cfg.ForPath("/v1/images/edit") != cfg.ForPath("/v1/images/generate") {
+			t.Errorf("image edit limit = %d, want the images limit", cfg.ForPath("/v1/images/edit"))
+		}
+	})
+}
+
+func TestGetProbeConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		for _, key := range []string{"PROBE_LIVE_PATH", "PROBE_READY_PATH", "PRESTOP_PATH", "SHUTDOWN_DRAIN_DELAY", "SHUTDOWN_TIMEOUT"} {
+			t.Setenv(key, "")
+		}
+		cfg := GetProbeConfig()
+		if cfg.LivePath != DefaultProbeLivePath || cfg.ReadyPath != DefaultProbeReadyPath || cfg.PreStopPath != DefaultPreStopPath {
+			t.Errorf("unexpected default paths: %+v", cfg)
+		}
+		if cfg.DrainDelay != 0 || cfg.ShutdownTimeout != DefaultShutdownTimeout {
+			t.Errorf("unexpected default durations: %+v", cfg)
+		}
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		t.Setenv("PROBE_LIVE_PATH", "/livez")
+		t.Setenv("PROBE_READY_PATH", "/readyz")
+		t.Setenv("PRESTOP_PATH", "/prestop")
+		t.Setenv("SHUTDOWN_DRAIN_DELAY", "5s")
+		t.Setenv("SHUTDOWN_TIMEOUT", "0s") // invalid, falls back to the default
+
+		cfg := GetProbeConfig()
+		if cfg.LivePath != "/livez" || cfg.ReadyPath != "/readyz" || cfg.PreStopPath != "/prestop" {
+			t.Errorf("unexpected paths: %+v", cfg)
+		}
+		if cfg.DrainDelay != 5*time.Second || cfg.ShutdownTimeout != DefaultShutdownTimeout {
+			t.Errorf("unexpected durations: %+v", cfg)
+		}
+	})
+}
+
+func TestGetTracingConfig(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+		t.Setenv("OTEL_SERVICE_NAME", "")
+		t.Setenv("OTEL_TRACES_SAMPLER_ARG", "")
+
+		cfg := GetTracingConfig()
+		if cfg.Enabled() || cfg.ServiceName != DefaultTracingServiceName || cfg.SampleRatio != 1 {
+			t.Errorf("unexpected defaults: %+v", cfg)
+		}
+	})
+
+	t.Run("base endpoint and headers", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+		t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer x, X-Tenant=proxy")
+		t.Setenv("OTEL_TRACES_SAMPLER_ARG", "1.5") // clamped to 1
+
+		cfg := GetTracingConfig()
+		if cfg.Endpoint != "http://collector:4318/v1/traces" {
+			t.Errorf("Endpoint = %q", cfg.Endpoint)
+		}
+		if cfg.Headers["Authorization"] != "Bearer x" || cfg.Headers["X-Tenant"] != "proxy" {
+			t.Errorf("Headers = %v", cfg.Headers)
+		}
+		if cfg.SampleRatio != 1 {
+			t.Errorf("SampleRatio = %v, want 1", cfg.SampleRatio)
+		}
+	})
+
+	t.Run("traces endpoint wins", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://traces:4318/custom")
+		t.Setenv("OTEL_TRACES_SAMPLER_ARG", "0.1")
+
+		cfg := GetTracingConfig()
+		if cfg.Endpoint != "http://traces:4318/custom" || cfg.SampleRatio != 0.1 {
+			t.Errorf("unexpected config: %+v", cfg)
+		}
+	})
+}
+
+func TestIsProviderEnabled(t *testing.T) {
+	t.Setenv("COPILOT_ENABLED", "false")
+	t.Setenv("MY_VLLM_ENABLED", "no")
+	t.Setenv("ZAI_ENABLED", "")
+
+	if IsProviderEnabled("copilot") || IsProviderEnabled("my-vllm") {
+		t.Error("expected copilot and my-vllm to be disabled")
+	}
+	if !IsProviderEnabled("zai") {
+		t.Error("expected providers to be enabled by default")
+	}
+}
+
+func TestGetMaxUpstreamRequestBytes(t *testing.T) {
+	t.Setenv("MAX_UPSTREAM_REQUEST_KB", "512")
+	t.Setenv("COPILOT_MAX_UPSTREAM_REQUEST_KB", "128")
+	t.Setenv("ZAI_MAX_UPSTREAM_REQUEST_KB", "0")
+
+	if got := GetMaxUpstreamRequestBytes("antigravity"); got != 512*1024 {
+		t.Errorf("expected global limit, got %d", got)
+	}
+	if got := GetMaxUpstreamRequestBytes("copilot"); got != 128*1024 {
+		t.Errorf("expected copilot override, got %d", got)
+	}
+	if got := GetMaxUpstreamRequestBytes("zai"); got != 0 {
+		t.Errorf("expected zai override to disable the limit, got %d", got)
+	}
+}
+
+func TestGetStreamRecoveryAttempts(t *testing.T) {
+	t.Setenv("STREAM_RECOVERY", "")
+	t.Setenv("ZAI_STREAM_RECOVERY", "true")
+	t.Setenv("STREAM_RECOVERY_MAX_ATTEMPTS", "2")
+
+	if got := GetStreamRecoveryAttempts("copilot"); got != 0 {
+		t.Errorf("expected recovery disabled by default, got %d", got)
+	}
+	if got := GetStreamRecoveryAttempts("zai"); got != 2 {
+		t.Errorf("expected zai override to enable recovery, got %d", got)
+	}
+
+	t.Setenv("STREAM_RECOVERY", "true")
+	t.Setenv("ZAI_STREAM_RECOVERY", "false")
+	if got := GetStreamRecoveryAttempts("copilot"); got != 2 {
+		t.Errorf("expected global setting, got %d", got)
+	}
+	if got := GetStreamRecoveryAttempts("zai"); got != 0 {
+		t.Errorf("expected zai override to disable recovery, got %d", got)
+	}
+}
+
+func TestGetRequestTimeouts(t *testing.T) {
+	t.Setenv("REQUEST_TIMEOUT", "5m")
+	t.Setenv("FIRST_TOKEN_TIMEOUT", "30s")
+	t.Setenv("ZAI_FIRST_TOKEN_TIMEOUT", "2m")
+	t.Setenv("ZAI_STREAM_TIMEOUT", "-1s")
+
+	want := RequestTimeouts{Request: 5 * time.Minute, FirstToken: 30 * time.Second}
+	if got := GetRequestTimeouts("copilot"); got != want {
+		t.Errorf("expected global timeouts %+v, got %+v", want, got)
+	}
+	want.FirstToken = 2 * time.Minute
+	if got := GetRequestTimeouts("zai"); got != want {
+		t.Errorf("expected zai overrides %+v, got %+v", want, got)
+	}
+}
+
+func TestGetSystemPromptConfig(t *testing.T) {
+	t.Setenv("SYSTEM_PROMPT_PREFIX", "Be brief.")
+	t.Setenv("SYSTEM_PROMPT_SUFFIX", "")
+	t.Setenv("ZAI_SYSTEM_PROMPT_PREFIX", "")
+	t.Setenv("ZAI_SYSTEM_PROMPT_STRIP_CLIENT", "true")
+
+	if cfg := GetSystemPromptConfig("copilot"); cfg != (SystemPromptConfig{Prefix: "Be brief."}) {
+		t.Errorf("expected the global prefix, got %+v", cfg)
+	}
+	if cfg := GetSystemPromptConfig("zai"); cfg != (SystemPromptConfig{StripClient: true}) || !cfg.Enabled() {
+		t.Errorf("expected zai to clear the prefix and strip, got %+v", cfg)
+	}
+}
+
+func TestGetModelAliases(t *testing.T) {
+	t.Setenv("MODEL_ALIASES", "fast=claude-haiku-4-5, smart = copilot/claude-opus-4-1, broken, =x")
+
+	got := GetModelAliases()
+	if len(got) != 2 || got["fast"] != "claude-haiku-4-5" || got["smart"] != "copilot/claude-opus-4-1" {
+		t.Errorf("GetModelAliases() = %v", got)
+	}
+}
+
+func TestGetModelEquivalents(t *testing.T) {
+	t.Setenv("MODEL_EQUIVALENTS", "antigravity/claude-sonnet-4-5=anthropic/claude-sonnet-4-5 | vertex/claude-sonnet-4-5, zai/glm-4.7=zai/glm-4.7, broken, =x")
+
+	got := GetModelEquivalents()
+	want := []string{"anthropic/claude-sonnet-4-5", "vertex/claude-sonnet-4-5"}
+	if len(got) != 1 || !reflect.DeepEqual(got["antigravity/claude-sonnet-4-5"], want) {
+		t.Errorf("GetModelEquivalents() = %v", got)
+	}
+
+	t.Setenv("SOFT_LIMIT_SCHEDULER", "")
+	if got := GetSoftLimitScheduler(); got != SoftLimitSchedulerProvider {
+		t.Errorf("GetSoftLimitScheduler() = %q, want provider by default", got)
+	}
+	t.Setenv("SOFT_LIMIT_SCHEDULER", "Global")
+	if got := GetSoftLimitScheduler(); got != SoftLimitSchedulerGlobal {
+		t.Errorf("GetSoftLimitScheduler() = %q, want global", got)
+	}
+}
+
+func TestGetStartupPreflight(t *testing.T) {
+	for value, want := range map[string]string{"": PreflightOff, "report": PreflightReport, " Strict ": PreflightStrict, "yes": PreflightOff} {
+		t.Setenv("STARTUP_PREFLIGHT", value)
+		if got := GetStartupPreflight(); got != want {
+			t.Errorf("STARTUP_PREFLIGHT=%q: got %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestGetStickyErrorConfig(t *testing.T) {
+	t.Setenv("STICKY_ERROR_TTL", "")
+	t.Setenv("STICKY_ERROR_THRESHOLD", "")
+	if cfg := GetStickyErrorConfig(); cfg.TTL != DefaultStickyErrorTTL || cfg.Threshold != DefaultStickyErrorThreshold {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+
+	t.Setenv("STICKY_ERROR_TTL", "0")
+	t.Setenv("STICKY_ERROR_THRESHOLD", "0") // clamped to 1
+	if cfg := GetStickyErrorConfig(); cfg.TTL != 0 || cfg.Threshold != 1 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestGetRequestGuardConfig(t *testing.T) {
+	t.Setenv("MAX_REQUEST_MESSAGES", "*=400, glm-4.7=150, zai/*=300, zai/glm-4.6=100, bad, x=0")
+	t.Setenv("MAX_REQUEST_TEXT_CHARS", "")
+	t.Setenv("MAX_REQUEST_IMAGES", "antigravity/*=20")
+
+	cfg := GetRequestGuardConfig()
+	if !cfg.Enabled() {
+		t.Fatal("expected guards to be enabled")
+	}
+	tests := []struct {
+		provider, model string
+		want            RequestGuard
+	}{
+		{"zai", "glm-4.6", RequestGuard{MaxMessages: 100}},
+		{"zai", "glm-4.7", RequestGuard{MaxMessages: 150}},
+		{"zai", "glm-4.5", RequestGuard{MaxMessages: 300}},
+		{"antigravity", "gemini-3-pro", RequestGuard{MaxMessages: 400, MaxImages: 20}},
+		{"copilot", "x", RequestGuard{MaxMessages: 400}},
+	}
+	for _, tt := range tests {
+		if got := cfg.ForModel(tt.provider, tt.model); got != tt.want {
+			t.Errorf("ForModel(%q, %q) = %+v, want %+v", tt.provider, tt.model, got, tt.want)
+		}
+	}
+
+	t.Setenv("MAX_REQUEST_MESSAGES", "")
+	t.Setenv("MAX_REQUEST_IMAGES", "")
+	if GetRequestGuardConfig().Enabled() {
+		t.Error("expected no guards without settings")
+	}
+}
+
+func TestGetThinkingPolicyConfig(t *testing.T) {
+	t.Setenv("THINKING_BUDGET_MIN", "zai/*=2048")
+	t.Setenv("THINKING_BUDGET_MAX", "*=32000, glm-4.7=8000")
+	t.Setenv("THINKING_BUDGET_DEFAULT", "glm-4.7=4000")
+	t.Setenv("THINKING_UNSUPPORTED_MODELS", "copilot/*, gpt-4o")
+	t.Setenv("THINKING_POLICY_PROVIDERS", "zai, antigravity")
+
+	cfg := GetThinkingPolicyConfig()
+	if !cfg.Enabled("zai") || !cfg.Enabled("antigravity") || cfg.Enabled("copilot") {
+		t.Errorf("expected the policy for zai and antigravity only")
+	}
+	tests := []struct {
+		provider, model string
+		want            ThinkingBudget
+	}{
+		{"zai", "glm-4.7", ThinkingBudget{Min: 2048, Max: 8000, Default: 4000}},
+		{"zai", "glm-4.6", ThinkingBudget{Min: 2048, Max: 32000, Default: DefaultThinkingBudget}},
+		{"antigravity", "claude-opus-4-5-thinking", ThinkingBudget{Min: DefaultThinkingBudgetMin, Max: 32000, Default: DefaultThinkingBudget}},
+	}
+	for _, tt := range tests {
+		if got := cfg.ForModel(tt.provider, tt.model); got != tt.want {
+			t.Errorf("ForModel(%q, %q) = %+v, want %+v", tt.provider, tt.model, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		provider, model string
+		want            bool
+	}{
+		{"copilot", "claude-sonnet-4", true},
+		{"openai", "gpt-4o", true},
+		{"zai", "glm-4.7", false},
+	} {
+		if got := cfg.Unsupported(tt.provider, tt.model); got != tt.want {
+			t.Errorf("Unsupported(%q, %q) = %v, want %v", tt.provider, tt.model, got, tt.want)
+		}
+	}
+
+	t.Setenv("THINKING_POLICY_PROVIDERS", "")
+	if GetThinkingPolicyConfig().Enabled("zai") {
+		t.Error("expected the policy to be off by default")
+	}
+
+	budget := ThinkingBudget{Min: 1024, Max: 8000, Default: 4000}
+	for in, want := range map[int]int{0: 4000, 100: 1024, 5000: 5000, 20000: 8000} {
+		if got := budget.Clamp(in); got != want {
+			t.Errorf("Clamp(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,498 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settingKind is the value type of a configuration setting, used for validation.
+type settingKind int
+
+const (
+	kindString   settingKind = iota
+	kindInt                  // Whole number
+	kindFloat                // Decimal number
+	kindBool                 // true/false, yes/no, 1/0
+	kindDuration             // Go duration such as 30s or 5m
+	kindList                 // Comma-separated list (a YAML list in the file)
+	kindIntList              // Comma-separated whole numbers
+	kindPairs                // Comma-separated Key=Value pairs (a YAML mapping in the file)
+)
+
+// settings lists every environment variable the config file can set, by value type.
+// Per-provider settings are matched by settingKindFor.
+var settings = map[string]settingKind{
+	"PORT":                               kindInt,
+	"BIND_ADDRESS":                       kindString,
+	"PROXY_API_KEY":                      kindString,
+	"DEBUG":                              kindBool,
+	"ENABLE_FALLBACK":                    kindBool,
+	"ACCOUNTS_CONFIG_PATH":               kindString,
+	"ACCOUNTS_ENCRYPTION_KEY":            kindString,
+	"ACCOUNTS_ENCRYPTION_KEY_FILE":       kindString,
+	"READ_TIMEOUT_SEC":                   kindInt,
+	"WRITE_TIMEOUT_SEC":                  kindInt,
+	"IDLE_TIMEOUT_SEC":                   kindInt,
+	"STREAM_PING_INTERVAL":               kindDuration,
+	"STREAM_RECOVERY":                    kindBool,
+	"STREAM_RECOVERY_MAX_ATTEMPTS":       kindInt,
+	"STREAM_TRACE_COMMENT":               kindBool,
+	"STREAM_FAST_PATH_ENABLED":           kindBool,
+	"IMAGE_TOOL_ENABLED":                 kindBool,
+	"IMAGE_TOOL_NAME":                    kindString,
+	"IMAGE_TOOL_INJECT":                  kindBool,
+	"IMAGE_TOOL_MODEL":                   kindString,
+	"REQUEST_BODY_LIMIT_MB":              kindInt,
+	"MESSAGES_BODY_LIMIT_MB":             kindInt,
+	"IMAGES_BODY_LIMIT_MB":               kindInt,
+	"MAX_UPSTREAM_REQUEST_KB":            kindInt,
+	"MAX_REQUEST_MESSAGES":               kindPairs,
+	"MAX_REQUEST_TEXT_CHARS":             kindPairs,
+	"MAX_REQUEST_IMAGES":                 kindPairs,
+	"CORS_ENABLED":                       kindBool,
+	"CORS_ALLOW_ORIGIN":                  kindString,
+	"CORS_ALLOW_METHODS":                 kindString,
+	"CORS_ALLOW_HEADERS":                 kindString,
+	"CORS_MAX_AGE":                       kindInt,
+	"SOFT_LIMIT_THRESHOLD":               kindFloat,
+	"LOAD_BALANCER":                      kindString,
+	"MODEL_ALIASES":                      kindPairs,
+	"SOFT_LIMIT_SCHEDULER":               kindString,
+	"MODEL_EQUIVALENTS":                  kindPairs,
+	"RETRY_MAX_ATTEMPTS":                 kindInt,
+	"RETRY_BASE_DELAY":                   kindDuration,
+	"RETRY_MAX_DELAY":                    kindDuration,
+	"RETRY_JITTER":                       kindFloat,
+	"RETRY_STATUS_CODES":                 kindIntList,
+	"ACCOUNT_HEALTH_ENABLED":             kindBool,
+	"ACCOUNT_HEALTH_COOLDOWN":            kindDuration,
+	"ACCOUNT_HEALTH_FAILURE_THRESHOLD":   kindFloat,
+	"ACCOUNT_HEALTH_SLOW_THRESHOLD":      kindDuration,
+	"STICKY_ERROR_TTL":                   kindDuration,
+	"STICKY_ERROR_THRESHOLD":             kindInt,
+	"MAX_CONCURRENT_PER_PROVIDER":        kindInt,
+	"MAX_CONCURRENT_PER_MODEL":           kindInt,
+	"MAX_CONCURRENT_PER_ACCOUNT":         kindInt,
+	"CONCURRENCY_QUEUE_TIMEOUT":          kindDuration,
+	"RESPONSE_CACHE_ENABLED":             kindBool,
+	"RESPONSE_CACHE_TTL":                 kindDuration,
+	"RESPONSE_CACHE_MAX_ENTRIES":         kindInt,
+	"REQUEST_COALESCING_ENABLED":         kindBool,
+	"RATE_LIMIT_RPS":                     kindFloat,
+	"RATE_LIMIT_BURST":                   kindInt,
+	"RATE_LIMIT_TPM":                     kindInt,
+	"RATE_LIMIT_GLOBAL_RPS":              kindFloat,
+	"RATE_LIMIT_GLOBAL_BURST":            kindInt,
+	"RATE_LIMIT_GLOBAL_TPM":              kindInt,
+	"VIRTUAL_KEYS":                       kindPairs,
+	"KEY_BUDGET_DAILY_TOKENS":            kindPairs,
+	"KEY_BUDGET_MONTHLY_TOKENS":          kindPairs,
+	"KEY_BUDGET_DAILY_REQUESTS":          kindPairs,
+	"KEY_BUDGET_MONTHLY_REQUESTS":        kindPairs,
+	"ACCOUNT_BUDGET_DAILY_TOKENS":        kindPairs,
+	"ACCOUNT_BUDGET_MONTHLY_TOKENS":      kindPairs,
+	"ACCOUNT_BUDGET_DAILY_REQUESTS":      kindPairs,
+	"ACCOUNT_BUDGET_MONTHLY_REQUESTS":    kindPairs,
+	"BUDGET_USAGE_PATH":                  kindString,
+	"SESSION_AFFINITY_ENABLED":           kindBool,
+	"SESSION_AFFINITY_TTL":               kindDuration,
+	"SESSION_AFFINITY_MAX_ENTRIES":       kindInt,
+	"SESSION_AFFINITY_PATH":              kindString,
+	"SESSION_AFFINITY_SAVE_INTERVAL":     kindDuration,
+	"ANTIGRAVITY_USER_AGENT":             kindString,
+	"ANTIGRAVITY_REQUEST_TYPE":           kindString,
+	"ANTIGRAVITY_REQUEST_ID_PREFIX":      kindString,
+	"SIGNATURE_CACHE_PATH":               kindString,
+	"SIGNATURE_CACHE_TTL":                kindDuration,
+	"SIGNATURE_CACHE_MAX_ENTRIES":        kindInt,
+	"SIGNATURE_CACHE_MAX_SESSIONS":       kindInt,
+	"SIGNATURE_CACHE_SAVE_INTERVAL":      kindDuration,
+	"THINKING_SIGNATURE_RECOVERY":        kindString,
+	"THINKING_BUDGET_MIN":                kindPairs,
+	"THINKING_BUDGET_MAX":                kindPairs,
+	"THINKING_BUDGET_DEFAULT":            kindPairs,
+	"THINKING_UNSUPPORTED_MODELS":        kindList,
+	"THINKING_POLICY_PROVIDERS":          kindList,
+	"COPILOT_REFUSAL_MODE":               kindString,
+	"AUDIT_LOG_ENABLED":                  kindBool,
+	"AUDIT_LOG_DIR":                      kindString,
+	"AUDIT_LOG_BODIES":                   kindBool,
+	"AUDIT_LOG_MAX_SIZE_MB":              kindInt,
+	"AUDIT_LOG_MAX_BACKUPS":              kindInt,
+	"HEALTH_REFRESH_INTERVAL":            kindDuration,
+	"PROBE_LIVE_PATH":                    kindString,
+	"PROBE_READY_PATH":                   kindString,
+	"PRESTOP_PATH":                       kindString,
+	"SHUTDOWN_DRAIN_DELAY":               kindDuration,
+	"SHUTDOWN_TIMEOUT":                   kindDuration,
+	"QUOTA_HISTORY_WINDOW":               kindDuration,
+	"QUOTA_ALERT_THRESHOLDS":             kindList,
+	"QUOTA_ALERT_WEBHOOK":                kindString,
+	"QUOTA_RECONCILE_TOLERANCE":          kindFloat,
+	"NOTIFY_WEBHOOKS":                    kindList,
+	"NOTIFY_EVENTS":                      kindList,
+	"NOTIFY_TEMPLATE":                    kindString,
+	"NOTIFY_MAX_RETRIES":                 kindInt,
+	"NOTIFY_RETRY_DELAY":                 kindDuration,
+	"BACKUP_ENABLED":                     kindBool,
+	"BACKUP_DIR":                         kindString,
+	"BACKUP_INTERVAL":                    kindDuration,
+	"BACKUP_RETENTION":                   kindInt,
+	"ACCOUNTS_SYNC_INTERVAL":             kindDuration,
+	"ACCOUNT_REVALIDATE_INTERVAL":        kindDuration,
+	"ACCOUNT_REVALIDATE_MAX_ATTEMPTS":    kindInt,
+	"STARTUP_PREFLIGHT":                  kindString,
+	"OTEL_EXPORTER_OTLP_ENDPOINT":        kindString,
+	"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": kindString,
+	"OTEL_EXPORTER_OTLP_HEADERS":         kindPairs,
+	"OTEL_SERVICE_NAME":                  kindString,
+	"OTEL_TRACES_SAMPLER_ARG":            kindFloat,
+	"GOOGLE_CLIENT_ID":                   kindString,
+	"GOOGLE_CLIENT_SECRET":               kindString,
+	"ANTHROPIC_BASE_URL":                 kindString,
+	"VERTEX_REGION":                      kindString,
+	"VERTEX_MODELS":                      kindList,
+	"VERTEX_BASE_URL":                    kindString,
+	"OPENAI_COMPATIBLE_CONFIG":           kindString,
+	"POLICY_FILTER_CONFIG":               kindString,
+	"POLICY_FILTER_URL":                  kindString,
+	"POLICY_FILTER_TIMEOUT":              kindDuration,
+	"POLICY_FILTER_FAIL_OPEN":            kindBool,
+	"REQUEST_POLICY_CONFIG":              kindString,
+	"MODEL_PRICING_CONFIG":               kindString,
+	"SYSTEM_PROMPT_PREFIX":               kindString,
+	"SYSTEM_PROMPT_SUFFIX":               kindString,
+	"SYSTEM_PROMPT_STRIP_CLIENT":         kindBool,
+	"ANTIGRAVITY_IDENTITY_OVERRIDE":      kindBool,
+	"OPENAI_COMPATIBLE_PROVIDERS":        kindList,
+}
+
+// BuiltinProviders are the provider names that can be switched off with <NAME>_ENABLED.
+var BuiltinProviders = []string{"antigravity", "zai", "anthropic", "vertex", "copilot"}
+
+// retrySuffixes are the per-provider retry settings, e.g. COPILOT_RETRY_MAX_ATTEMPTS.
+var retrySuffixes = map[string]settingKind{
+	"RETRY_MAX_ATTEMPTS": kindInt,
+	"RETRY_BASE_DELAY":   kindDuration,
+	"RETRY_MAX_DELAY":    kindDuration,
+	"RETRY_JITTER":       kindFloat,
+	"RETRY_STATUS_CODES": kindIntList,
+}
+
+// timeoutSuffixes are the request timeout settings, global and per provider (e.g.
+// ZAI_STREAM_TIMEOUT).
+var timeoutSuffixes = map[string]settingKind{
+	"REQUEST_TIMEOUT":          kindDuration,
+	"TOKEN_FETCH_TIMEOUT":      kindDuration,
+	"UPSTREAM_CONNECT_TIMEOUT": kindDuration,
+	"FIRST_TOKEN_TIMEOUT":      kindDuration,
+	"STREAM_TIMEOUT":           kindDuration,
+}
+
+// systemPromptSuffixes are the per-provider system prompt settings, e.g. ZAI_SYSTEM_PROMPT_PREFIX.
+var systemPromptSuffixes = map[string]settingKind{
+	"SYSTEM_PROMPT_PREFIX":       kindString,
+	"SYSTEM_PROMPT_SUFFIX":       kindString,
+	"SYSTEM_PROMPT_STRIP_CLIENT": kindBool,
+}
+
+// openAICompatibleSuffixes are the per-instance OpenAI-compatible settings.
+var openAICompatibleSuffixes = map[string]settingKind{
+	"BASE_URL": kindString,
+	"API_KEY":  kindString,
+	"MODELS":   kindList,
+	"HEADERS":  kindPairs,
+}
+
+// settingKindFor returns the kind of the named setting. providers are the provider
+// names (built-in and OpenAI-compatible) that per-provider settings may refer to.
+func settingKindFor(name string, providers []string) (settingKind, bool) {
+	if kind, ok := settings[name]; ok {
+		return kind, true
+	}
+	if kind, ok := timeoutSuffixes[name]; ok {
+		return kind, true
+	}
+	for _, p := range providers {
+		prefix := EnvName(p) + "_"
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		if rest == "ENABLED" {
+			return kindBool, true
+		}
+		if rest == "HEADERS" && slices.Contains(BuiltinProviders, p) {
+			return kindPairs, true
+		}
+		if rest == "MAX_UPSTREAM_REQUEST_KB" {
+			return kindInt, true
+		}
+		if rest == "STREAM_RECOVERY" {
+			return kindBool, true
+		}
+		if kind, ok := timeoutSuffixes[rest]; ok {
+			return kind, true
+		}
+		if kind, ok := retrySuffixes[rest]; ok {
+			return kind, true
+		}
+		if kind, ok := systemPromptSuffixes[rest]; ok {
+			return kind, true
+		}
+	}
+	if rest, ok := strings.CutPrefix(name, "OPENAI_COMPATIBLE_"); ok {
+		for _, p := range providers {
+			if suffix, ok := strings.CutPrefix(rest, EnvName(p)+"_"); ok {
+				if kind, ok := openAICompatibleSuffixes[suffix]; ok {
+					return kind, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// GetConfigFilePath returns the config file path from CONFIG_FILE, or config.yaml in the
+// profile's data directory (by default ~/.config/multi-claude-proxy/config.yaml).
+func GetConfigFilePath() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
+	}
+	return filepath.Join(GetDataDir(), "config.yaml")
+}
+
+// LoadConfigFile reads a YAML config file and returns its settings keyed by environment
+// variable name. Nested keys are joined with underscores and upper-cased, so
+//
+//	cors:
+//	  allow_origin: https://example.com
+//
+// sets CORS_ALLOW_ORIGIN. Lists become comma-separated values and mappings of settings
+// that take Key=Value pairs (model_aliases, *_headers) become pairs. A top-level env:
+// mapping sets variables by their exact name.
+func LoadConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	for key, v := range doc {
+		if key != "env" {
+			if err := flattenSetting(values, settingName("", key), v); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+		raw, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: env must be a mapping of variable names to values", path)
+		}
+		for name, rv := range raw {
+			if err := flattenSetting(values, name, rv); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+	return values, nil
+}
+
+func settingName(prefix, key string) string {
+	name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(strings.TrimSpace(key)))
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+func flattenSetting(values map[string]string, name string, v interface{}) error {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if kind, ok := settings[name]; (ok && kind == kindPairs) || strings.HasSuffix(name, "_HEADERS") {
+			pairs := make([]string, 0, len(val))
+			for k, pv := range val {
+				s, ok := pv.(string)
+				if !ok {
+					return fmt.Errorf("%s.%s must be a single value", strings.ToLower(name), k)
+				}
+				pairs = append(pairs, k+"="+s)
+			}
+			sort.Strings(pairs)
+			values[name] = strings.Join(pairs, ",")
+			return nil
+		}
+		for k, child := range val {
+			if err := flattenSetting(values, settingName(name, k), child); err != nil {
+				return err
+			}
+		}
+	case []string:
+		values[name] = strings.Join(val, ",")
+	case string:
+		values[name] = val
+	}
+	return nil
+}
+
+// ValidateSettings checks that every setting is known and its value parses. It returns
+// one error per problem, sorted by setting name.
+func ValidateSettings(values map[string]string) []error {
+	providers := append([]string(nil), BuiltinProviders...)
+	providers = append(providers, GetEnvStringSlice("OPENAI_COMPATIBLE_PROVIDERS", nil)...)
+	for _, p := range strings.Split(values["OPENAI_COMPATIBLE_PROVIDERS"], ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			providers = append(providers, p)
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		kind, ok := settingKindFor(name, providers)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting", name))
+			continue
+		}
+		if err := validateSettingValue(kind, values[name]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+func validateSettingValue(kind settingKind, value string) error {
+	if value == "" {
+		return nil
+	}
+	switch kind {
+	case kindInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+	case kindFloat:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+	case kindBool:
+		switch strings.ToLower(value) {
+		case "true", "1", "yes", "false", "0", "no":
+		default:
+			return fmt.Errorf("%q is not true or false", value)
+		}
+	case kindDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%q is not a duration (e.g. 30s, 5m)", value)
+		}
+	case kindIntList:
+		for _, part := range strings.Split(value, ",") {
+			if _, err := strconv.Atoi(strings.TrimSpace(part)); err != nil {
+				return fmt.Errorf("%q is not a list of whole numbers", value)
+			}
+		}
+	case kindPairs:
+		for _, part := range strings.Split(value, ",") {
+			if k, _, ok := strings.Cut(part, "="); !ok || strings.TrimSpace(k) == "" {
+				return fmt.Errorf("%q is not a list of Key=Value pairs", part)
+			}
+		}
+	}
+	return nil
+}
+
+// loadedFile remembers the config file applied by ApplyConfigFile and which variables it
+// set, so ReloadConfigFile can replace them without touching the real environment.
+var loadedFile struct {
+	mu       sync.Mutex
+	path     string
+	required bool
+	applied  map[string]string
+}
+
+// ApplyConfigFile loads the config file at path into the environment. Variables that are
+// already set take precedence over the file. A missing file is an error only when
+// required (i.e. the path was given explicitly).
+func ApplyConfigFile(path string, required bool) error {
+	loadedFile.mu.Lock()
+	defer loadedFile.mu.Unlock()
+	values, err := readConfigFile(path, required)
+	if err != nil {
+		return err
+	}
+	loadedFile.path = path
+	loadedFile.required = required
+	loadedFile.applied = nil
+	return applyConfigValues(values)
+}
+
+// ReloadConfigFile re-reads the file last passed to ApplyConfigFile. Variables the file set
+// previously are replaced by its current contents; variables set outside the file still
+// take precedence. An invalid file leaves the environment unchanged.
+func ReloadConfigFile() error {
+	loadedFile.mu.Lock()
+	defer loadedFile.mu.Unlock()
+	if loadedFile.path == "" {
+		return errors.New("no config file loaded")
+	}
+	values, err := readConfigFile(loadedFile.path, loadedFile.required)
+	if err != nil {
+		return err
+	}
+	for name, value := range loadedFile.applied {
+		// Leave variables that were changed since, e.g. by an operator, alone.
+		if os.Getenv(name) == value {
+			os.Unsetenv(name)
+		}
+	}
+	loadedFile.applied = nil
+	return applyConfigValues(values)
+}
+
+// readConfigFile loads and validates a config file. A missing optional file yields no values.
+func readConfigFile(path string, required bool) (map[string]string, error) {
+	values, err := LoadConfigFile(path)
+	if err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if errs := ValidateSettings(values); len(errs) > 0 {
+		return nil, fmt.Errorf("%s: %w", path, errors.Join(errs...))
+	}
+	return values, nil
+}
+
+// applyConfigValues sets the variables that are not already set and records them.
+// Callers must hold loadedFile.mu.
+func applyConfigValues(values map[string]string) error {
+	applied := make(map[string]string)
+	defer func() { loadedFile.applied = applied }()
+	for name, value := range values {
+		if _, set := os.LookupEnv(name); set {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+		applied[name] = value
+	}
+	return nil
+}
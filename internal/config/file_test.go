@@ -0,0 +1,234 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseYAML(t *testing.T) {
+	data := `---
+# proxy settings
+port: 9000
+cors:
+  allow_origin: "https://example.com"   # trailing comment
+  enabled: yes
+providers:
+  - zai
+  - 'it''s'
+models: [a, "b, c"]
+empty: ~
+nested:
+  deeper:
+    key: value # with # inside
+`
+	got, err := parseYAML([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{
+		"port":      "9000",
+		"cors":      map[string]interface{}{"allow_origin": "https://example.com", "enabled": "yes"},
+		"providers": []string{"zai", "it's"},
+		"models":    []string{"a", "b, c"},
+		"empty":     "",
+		"nested":    map[string]interface{}{"deeper": map[string]interface{}{"key": "value"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseYAML_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"tab indentation", "cors:\n\tenabled: true", "tabs"},
+		{"bad indentation", "port: 1\n  extra: 2", "line 2: unexpected indentation"},
+		{"duplicate key", "port: 1\nport: 2", "duplicate key"},
+		{"missing colon", "port", "expected 'key: value'"},
+		{"flow mapping", "cors: {enabled: true}", "flow mappings"},
+		{"block string", "key: |", "multi-line"},
+		{"mapping in list", "list:\n  - a: b", "scalars"},
+		{"top-level list", "- a", "mapping"},
+		{"bad quotes", `key: "unterminated`, "double-quoted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAML([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `
+port: 9000
+proxy-api-key: secret
+cors:
+  allow_origin: https://example.com
+soft_limit_threshold: 0.2
+zai:
+  enabled: false
+copilot:
+  retry:
+    max_attempts: 5
+    status_codes: [429, 503]
+model_aliases:
+  fast: claude-haiku-4-5
+  smart: claude-opus-4-1
+openai_compatible:
+  providers: [vllm]
+  vllm:
+    base_url: http://gpu:8000/v1
+    headers:
+      X-Team: ml
+env:
+  NOTIFY_EVENTS: [account_invalid]
+`)
+	got, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"PORT":                            "9000",
+		"PROXY_API_KEY":                   "secret",
+		"CORS_ALLOW_ORIGIN":               "https://example.com",
+		"SOFT_LIMIT_THRESHOLD":            "0.2",
+		"ZAI_ENABLED":                     "false",
+		"COPILOT_RETRY_MAX_ATTEMPTS":      "5",
+		"COPILOT_RETRY_STATUS_CODES":      "429,503",
+		"MODEL_ALIASES":                   "fast=claude-haiku-4-5,smart=claude-opus-4-1",
+		"OPENAI_COMPATIBLE_PROVIDERS":     "vllm",
+		"OPENAI_COMPATIBLE_VLLM_BASE_URL": "http://gpu:8000/v1",
+		"OPENAI_COMPATIBLE_VLLM_HEADERS":  "X-Team=ml",
+		"NOTIFY_EVENTS":                   "account_invalid",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadConfigFile() = %v, want %v", got, want)
+	}
+	if errs := ValidateSettings(got); len(errs) != 0 {
+		t.Errorf("expected valid settings, got %v", errs)
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	errs := ValidateSettings(map[string]string{
+		"PORT":                       "eighty",
+		"CORS_ENABLED":               "maybe",
+		"RETRY_BASE_DELAY":           "5",
+		"RETRY_STATUS_CODES":         "429,x",
+		"MODEL_ALIASES":              "fast",
+		"CORS_ALLOW_ORGIN":           "*",
+		"UNKNOWN_RETRY_MAX_ATTEMPTS": "3",
+		"VERTEX_ENABLED":             "false",
+		"SOFT_LIMIT_THRESHOLD":       "",
+		"COPILOT_HEADERS":            "User-Agent=GitHubCopilotChat/0.30.0",
+		"ZAI_HEADERS":                "User-Agent",
+	})
+	var got []string
+	for _, err := range errs {
+		name, _, _ := strings.Cut(err.Error(), ":")
+		got = append(got, name)
+	}
+	want := []string{"CORS_ALLOW_ORGIN", "CORS_ENABLED", "MODEL_ALIASES", "PORT", "RETRY_BASE_DELAY", "RETRY_STATUS_CODES", "UNKNOWN_RETRY_MAX_ATTEMPTS", "ZAI_HEADERS"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected errors for %v, got %v", want, errs)
+	}
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	path := writeConfigFile(t, "port: 9000\nload_balancer: least-used\n")
+	t.Setenv("PORT", "7000")
+	t.Setenv("LOAD_BALANCER", "")
+	os.Unsetenv("LOAD_BALANCER")
+
+	if err := ApplyConfigFile(path, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := GetPort(); got != 7000 {
+		t.Errorf("expected env PORT to win, got %d", got)
+	}
+	if got := os.Getenv("LOAD_BALANCER"); got != "least-used" {
+		t.Errorf("expected LOAD_BALANCER from file, got %q", got)
+	}
+}
+
+func TestApplyConfigFile_Missing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.yaml")
+	if err := ApplyConfigFile(path, false); err != nil {
+		t.Errorf("expected optional missing file to be ignored, got %v", err)
+	}
+	if err := ApplyConfigFile(path, true); err == nil {
+		t.Error("expected error for a missing explicit config file")
+	}
+
+	invalid := writeConfigFile(t, "port: abc\n")
+	if err := ApplyConfigFile(invalid, false); err == nil || !strings.Contains(err.Error(), "PORT") {
+		t.Errorf("expected validation error for PORT, got %v", err)
+	}
+}
+
+func TestReloadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, "soft_limit_threshold: 0.2\nmodel_aliases:\n  fast: zai/glm-4.5\n")
+	t.Setenv("PORT", "7000")
+	for _, name := range []string{"SOFT_LIMIT_THRESHOLD", "MODEL_ALIASES"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	if err := ApplyConfigFile(path, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Removed keys are unset, changed keys replaced, and the real environment still wins.
+	if err := os.WriteFile(path, []byte("soft_limit_threshold: 0.3\nport: 9000\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConfigFile(); err != nil {
+		t.Fatalf("unexpected reload error: %v", err)
+	}
+	if got := GetSoftLimitThreshold(); got != 0.3 {
+		t.Errorf("expected reloaded threshold 0.3, got %v", got)
+	}
+	if _, set := os.LookupEnv("MODEL_ALIASES"); set {
+		t.Error("expected MODEL_ALIASES to be unset after removal from the file")
+	}
+	if got := GetPort(); got != 7000 {
+		t.Errorf("expected env PORT to win, got %d", got)
+	}
+
+	// An invalid file leaves the current values in place.
+	if err := os.WriteFile(path, []byte("soft_limit_threshold: abc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConfigFile(); err == nil {
+		t.Fatal("expected validation error")
+	}
+	if got := GetSoftLimitThreshold(); got != 0.3 {
+		t.Errorf("expected threshold to stay 0.3, got %v", got)
+	}
+}
+
+func TestValidateSettings_FractionalThreshold(t *testing.T) {
+	if errs := ValidateSettings(map[string]string{"ACCOUNT_HEALTH_FAILURE_THRESHOLD": "0.6"}); len(errs) != 0 {
+		t.Errorf("expected a fractional failure threshold to be valid, got %v", errs)
+	}
+	if errs := ValidateSettings(map[string]string{"ACCOUNT_HEALTH_FAILURE_THRESHOLD": "most"}); len(errs) != 1 {
+		t.Errorf("expected an error for a non-numeric failure threshold, got %v", errs)
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// Defaults for the client fields Antigravity expects in every request payload.
+const (
+	DefaultAntigravityUserAgent       = "antigravity"
+	DefaultAntigravityRequestType     = "agent"
+	DefaultAntigravityRequestIDPrefix = "agent-"
+)
+
+// GetProviderHeaders returns the headers to set on every upstream request of a built-in
+// provider, replacing the client's own values. An empty value removes the header.
+// Uses <NAME>_HEADERS (comma-separated Key=Value pairs), e.g.
+// ANTIGRAVITY_HEADERS="User-Agent=antigravity/1.16.0 linux/amd64".
+func GetProviderHeaders(provider string) map[string]string {
+	var headers map[string]string
+	for _, pair := range GetEnvStringSlice(EnvName(provider)+"_HEADERS", nil) {
+		if k, v, ok := strings.Cut(pair, "="); ok && strings.TrimSpace(k) != "" {
+			if headers == nil {
+				headers = make(map[string]string)
+			}
+			headers[textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	return headers
+}
+
+type headersKey struct{}
+
+// WithHeaders returns ctx carrying headers that SetProviderHeaders applies after the
+// provider's, e.g. an account's own User-Agent.
+func WithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, headersKey{}, headers)
+}
+
+// SetProviderHeaders applies the provider's configured headers (see GetProviderHeaders)
+// and then those carried by the request context (see WithHeaders) to req. Call it after
+// the client has set its own headers so the configured ones win.
+func SetProviderHeaders(req *http.Request, provider string) {
+	setHeaders(req.Header, GetProviderHeaders(provider))
+	if headers, ok := req.Context().Value(headersKey{}).(map[string]string); ok {
+		setHeaders(req.Header, headers)
+	}
+}
+
+func setHeaders(h http.Header, headers map[string]string) {
+	for k, v := range headers {
+		if v == "" {
+			h.Del(k)
+		} else {
+			h.Set(k, v)
+		}
+	}
+}
+
+// AntigravityClientInfo is how Antigravity request payloads identify the client.
+type AntigravityClientInfo struct {
+	UserAgent       string // userAgent field
+	RequestType     string // requestType field
+	RequestIDPrefix string // Prepended to the random requestId
+}
+
+// GetAntigravityClientInfo returns the client fields sent in Antigravity request payloads.
+// Uses ANTIGRAVITY_USER_AGENT, ANTIGRAVITY_REQUEST_TYPE and ANTIGRAVITY_REQUEST_ID_PREFIX.
+func GetAntigravityClientInfo() AntigravityClientInfo {
+	return AntigravityClientInfo{
+		UserAgent:       getEnvOrDefault("ANTIGRAVITY_USER_AGENT", DefaultAntigravityUserAgent),
+		RequestType:     getEnvOrDefault("ANTIGRAVITY_REQUEST_TYPE", DefaultAntigravityRequestType),
+		RequestIDPrefix: getEnvOrDefault("ANTIGRAVITY_REQUEST_ID_PREFIX", DefaultAntigravityRequestIDPrefix),
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestSetProviderHeaders(t *testing.T) {
+	t.Setenv("ANTIGRAVITY_HEADERS", "user-agent=antigravity/1.16.0 linux/amd64, X-Extra = 1, Client-Metadata=")
+
+	req, _ := http.NewRequest("POST", "https://example.com", nil)
+	req.Header.Set("User-Agent", "antigravity/1.15.8 linux/amd64")
+	req.Header.Set("Client-Metadata", "{}")
+	req.Header.Set("Authorization", "Bearer token")
+	SetProviderHeaders(req, "antigravity")
+
+	if got := req.Header.Get("User-Agent"); got != "antigravity/1.16.0 linux/amd64" {
+		t.Errorf("expected User-Agent to be replaced, got %q", got)
+	}
+	if got := req.Header.Get("X-Extra"); got != "1" {
+		t.Errorf("expected X-Extra to be added, got %q", got)
+	}
+	if _, ok := req.Header["Client-Metadata"]; ok {
+		t.Error("expected empty value to remove Client-Metadata")
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("expected Authorization to be kept, got %q", got)
+	}
+
+	other, _ := http.NewRequest("POST", "https://example.com", nil)
+	other.Header.Set("User-Agent", "zai")
+	SetProviderHeaders(other, "zai")
+	if got := other.Header.Get("User-Agent"); got != "zai" {
+		t.Errorf("expected other providers to be unaffected, got %q", got)
+	}
+}
+
+func TestSetProviderHeaders_ContextOverridesProvider(t *testing.T) {
+	t.Setenv("ANTIGRAVITY_HEADERS", "User-Agent=provider, X-Extra=1")
+
+	ctx := WithHeaders(context.Background(), map[string]string{"User-Agent": "account", "X-Extra": ""})
+	req, _ := http.NewRequestWithContext(ctx, "POST", "https://example.com", nil)
+	SetProviderHeaders(req, "antigravity")
+
+	if got := req.Header.Get("User-Agent"); got != "account" {
+		t.Errorf("expected context User-Agent to win, got %q", got)
+	}
+	if _, ok := req.Header["X-Extra"]; ok {
+		t.Error("expected context to remove X-Extra")
+	}
+}
+
+func TestGetAntigravityClientInfo(t *testing.T) {
+	t.Setenv("ANTIGRAVITY_USER_AGENT", "")
+	t.Setenv("ANTIGRAVITY_REQUEST_TYPE", "")
+	t.Setenv("ANTIGRAVITY_REQUEST_ID_PREFIX", "")
+	want := AntigravityClientInfo{
+		UserAgent:       DefaultAntigravityUserAgent,
+		RequestType:     DefaultAntigravityRequestType,
+		RequestIDPrefix: DefaultAntigravityRequestIDPrefix,
+	}
+	if got := GetAntigravityClientInfo(); got != want {
+		t.Errorf("expected defaults %+v, got %+v", want, got)
+	}
+
+	t.Setenv("ANTIGRAVITY_USER_AGENT", "antigravity-next")
+	t.Setenv("ANTIGRAVITY_REQUEST_TYPE", "chat")
+	t.Setenv("ANTIGRAVITY_REQUEST_ID_PREFIX", "req-")
+	want = AntigravityClientInfo{UserAgent: "antigravity-next", RequestType: "chat", RequestIDPrefix: "req-"}
+	if got := GetAntigravityClientInfo(); got != want {
+		t.Errorf("expected env overrides %+v, got %+v", want, got)
+	}
+}
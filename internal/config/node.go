@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// NodeConfigDir returns the Node proxy's default config directory (~/.config/antigravity-proxy).
+func NodeConfigDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".config/antigravity-proxy"
+	}
+	return filepath.Join(home, ".config/antigravity-proxy")
+}
+
+// nodeConfigKeys maps Node config.json keys whose names differ from ours.
+var nodeConfigKeys = map[string]string{
+	"apiKey":     "PROXY_API_KEY",
+	"host":       "BIND_ADDRESS",
+	"fallback":   "ENABLE_FALLBACK",
+	"maxRetries": "RETRY_MAX_ATTEMPTS",
+}
+
+// nodeMillisecondKeys maps Node config.json keys holding milliseconds to duration settings.
+var nodeMillisecondKeys = map[string]string{
+	"retryBaseMs": "RETRY_BASE_DELAY",
+	"retryMaxMs":  "RETRY_MAX_DELAY",
+}
+
+// ConvertNodeConfig converts the Node proxy's config.json into settings by environment
+// variable name. Keys are matched by name (camelCase to UPPER_SNAKE) or through a small
+// rename table. It also returns the keys that have no valid equivalent, sorted.
+func ConvertNodeConfig(data []byte) (map[string]string, []string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, nil, fmt.Errorf("invalid Node config: %w", err)
+	}
+
+	values := make(map[string]string)
+	var skipped []string
+	for key, v := range raw {
+		value, ok := nodeScalar(v)
+		if !ok {
+			skipped = append(skipped, key)
+			continue
+		}
+		if value == "" {
+			continue // Node writes unset options as empty strings
+		}
+
+		name, isMillis := nodeMillisecondKeys[key]
+		if isMillis {
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				skipped = append(skipped, key)
+				continue
+			}
+			value = fmt.Sprintf("%dms", ms)
+		} else if name = nodeConfigKeys[key]; name == "" {
+			name = camelToEnvName(key)
+		}
+		if _, known := settings[name]; !known || len(ValidateSettings(map[string]string{name: value})) > 0 {
+			skipped = append(skipped, key)
+			continue
+		}
+		values[name] = value
+	}
+	sort.Strings(skipped)
+	return values, skipped, nil
+}
+
+// nodeScalar formats a JSON scalar, or a list of scalars, as a setting value.
+func nodeScalar(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := nodeScalar(item)
+			if !ok || strings.Contains(s, ",") {
+				return "", false
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), true
+	}
+	return "", false
+}
+
+// camelToEnvName converts a camelCase key to an environment variable name (softLimit -> SOFT_LIMIT).
+func camelToEnvName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// FormatConfigFile renders settings as a config file that LoadConfigFile reads back
+// unchanged, one quoted top-level key per setting.
+func FormatConfigFile(values map[string]string) []byte {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", strings.ToLower(name), strconv.Quote(values[name]))
+	}
+	return b.Bytes()
+}
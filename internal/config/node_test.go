@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConvertNodeConfig(t *testing.T) {
+	data := `{
+  "apiKey": "secret",
+  "webuiPassword": "",
+  "debug": true,
+  "port": 9000,
+  "maxRetries": 4,
+  "retryBaseMs": 1500,
+  "softLimitThreshold": 0.15,
+  "quotaAlertThresholds": [0.5, 0.1],
+  "logLevel": "info",
+  "modelMapping": {"a": "b"},
+  "corsMaxAge": "soon"
+}`
+	values, skipped, err := ConvertNodeConfig([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"PROXY_API_KEY":          "secret",
+		"DEBUG":                  "true",
+		"PORT":                   "9000",
+		"RETRY_MAX_ATTEMPTS":     "4",
+		"RETRY_BASE_DELAY":       "1500ms",
+		"SOFT_LIMIT_THRESHOLD":   "0.15",
+		"QUOTA_ALERT_THRESHOLDS": "0.5,0.1",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("ConvertNodeConfig() = %v, want %v", values, want)
+	}
+	if wantSkipped := []string{"corsMaxAge", "logLevel", "modelMapping"}; !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Errorf("expected skipped %v, got %v", wantSkipped, skipped)
+	}
+
+	if _, _, err := ConvertNodeConfig([]byte("[]")); err == nil {
+		t.Error("expected error for a non-object config")
+	}
+}
+
+func TestFormatConfigFile_RoundTrip(t *testing.T) {
+	values := map[string]string{
+		"PROXY_API_KEY":          `se"cret: #1`,
+		"PORT":                   "9000",
+		"QUOTA_ALERT_THRESHOLDS": "0.5,0.1",
+		"MODEL_ALIASES":          "fast=claude-haiku-4-5",
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, FormatConfigFile(values), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("round trip = %v, want %v", got, values)
+	}
+}
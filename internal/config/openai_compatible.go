@@ -0,0 +1,101 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// OpenAICompatibleConfig describes one OpenAI-compatible upstream (vLLM, Ollama, OpenRouter, ...).
+// Each instance registers as its own provider, so models are addressed as "<name>/<model>".
+type OpenAICompatibleConfig struct {
+	Name    string            `json:"name"`
+	BaseURL string            `json:"baseUrl"`          // e.g. http://localhost:8000/v1
+	APIKey  string            `json:"apiKey,omitempty"` // Sent as a Bearer token when set
+	Models  []string          `json:"models,omitempty"` // Empty means fetch GET <baseUrl>/models at startup
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// reservedProviderNames can't be used for OpenAI-compatible instances.
+var reservedProviderNames = map[string]bool{"antigravity": true, "zai": true, "anthropic": true, "copilot": true, "vertex": true}
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// GetOpenAICompatibleConfigs returns the configured OpenAI-compatible upstreams.
+//
+// Instances come from the JSON file named by OPENAI_COMPATIBLE_CONFIG (an array of
+// OpenAICompatibleConfig; JSON is also valid YAML) and from OPENAI_COMPATIBLE_PROVIDERS,
+// a comma-separated list of names each configured with OPENAI_COMPATIBLE_<NAME>_BASE_URL,
+// OPENAI_COMPATIBLE_<NAME>_API_KEY, OPENAI_COMPATIBLE_<NAME>_MODELS and
+// OPENAI_COMPATIBLE_<NAME>_HEADERS (comma-separated Key=Value pairs).
+// Env instances override file instances with the same name.
+func GetOpenAICompatibleConfigs() ([]OpenAICompatibleConfig, error) {
+	var configs []OpenAICompatibleConfig
+
+	if path := os.Getenv("OPENAI_COMPATIBLE_CONFIG"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OPENAI_COMPATIBLE_CONFIG: %w", err)
+		}
+		if err := json.Unmarshal(data, &configs); err != nil {
+			return nil, fmt.Errorf("failed to parse OPENAI_COMPATIBLE_CONFIG: %w", err)
+		}
+	}
+
+	for _, name := range GetEnvStringSlice("OPENAI_COMPATIBLE_PROVIDERS", nil) {
+		prefix := "OPENAI_COMPATIBLE_" + EnvName(name) + "_"
+		cfg := OpenAICompatibleConfig{
+			Name:    name,
+			BaseURL: os.Getenv(prefix + "BASE_URL"),
+			APIKey:  os.Getenv(prefix + "API_KEY"),
+			Models:  GetEnvStringSlice(prefix+"MODELS", nil),
+		}
+		for _, pair := range GetEnvStringSlice(prefix+"HEADERS", nil) {
+			if k, v, ok := strings.Cut(pair, "="); ok {
+				if cfg.Headers == nil {
+					cfg.Headers = make(map[string]string)
+				}
+				cfg.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+
+		replaced := false
+		for i := range configs {
+			if configs[i].Name == name {
+				configs[i] = cfg
+				replaced = true
+			}
+		}
+		if !replaced {
+			configs = append(configs, cfg)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := range configs {
+		cfg := &configs[i]
+		cfg.Name = strings.ToLower(strings.TrimSpace(cfg.Name))
+		cfg.BaseURL = strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+		if !providerNamePattern.MatchString(cfg.Name) {
+			return nil, fmt.Errorf("invalid OpenAI-compatible provider name %q (use lowercase letters, digits, '-' or '_')", cfg.Name)
+		}
+		if reservedProviderNames[cfg.Name] {
+			return nil, fmt.Errorf("OpenAI-compatible provider name %q is reserved", cfg.Name)
+		}
+		if seen[cfg.Name] {
+			return nil, fmt.Errorf("duplicate OpenAI-compatible provider name %q", cfg.Name)
+		}
+		seen[cfg.Name] = true
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("OpenAI-compatible provider %q has no base URL", cfg.Name)
+		}
+	}
+	return configs, nil
+}
+
+// EnvName converts a provider name into its environment variable form ("my-vllm" -> "MY_VLLM").
+func EnvName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
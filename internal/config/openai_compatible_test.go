@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetOpenAICompatibleConfigs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.json")
+	data := `[
+		{"name": "vllm", "baseUrl": "http://localhost:8000/v1/", "models": ["llama"]},
+		{"name": "Ollama", "baseUrl": "http://localhost:11434/v1"}
+	]`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENAI_COMPATIBLE_CONFIG", path)
+	t.Setenv("OPENAI_COMPATIBLE_PROVIDERS", "vllm,open-router")
+	t.Setenv("OPENAI_COMPATIBLE_VLLM_BASE_URL", "http://gpu:8000/v1")
+	t.Setenv("OPENAI_COMPATIBLE_VLLM_MODELS", "qwen, llama")
+	t.Setenv("OPENAI_COMPATIBLE_OPEN_ROUTER_BASE_URL", "https://openrouter.ai/api/v1")
+	t.Setenv("OPENAI_COMPATIBLE_OPEN_ROUTER_API_KEY", "sk-test")
+	t.Setenv("OPENAI_COMPATIBLE_OPEN_ROUTER_HEADERS", "HTTP-Referer=https://example.com")
+
+	configs, err := GetOpenAICompatibleConfigs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(configs) != 3 {
+		t.Fatalf("expected 3 configs, got %d: %+v", len(configs), configs)
+	}
+
+	byName := make(map[string]OpenAICompatibleConfig)
+	for _, c := range configs {
+		byName[c.Name] = c
+	}
+	if c := byName["vllm"]; c.BaseURL != "http://gpu:8000/v1" || len(c.Models) != 2 {
+		t.Errorf("expected env to override file for vllm, got %+v", c)
+	}
+	if c := byName["ollama"]; c.BaseURL != "http://localhost:11434/v1" {
+		t.Errorf("expected lowercased file instance ollama, got %+v", c)
+	}
+	if c := byName["open-router"]; c.APIKey != "sk-test" || c.Headers["HTTP-Referer"] != "https://example.com" {
+		t.Errorf("unexpected open-router config: %+v", c)
+	}
+}
+
+func TestGetOpenAICompatibleConfigs_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers string
+		baseURL   string
+	}{
+		{"reserved name", "copilot", "http://x"},
+		{"missing base URL", "vllm", ""},
+		{"invalid name", "bad/name", "http://x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OPENAI_COMPATIBLE_PROVIDERS", tt.providers)
+			t.Setenv("OPENAI_COMPATIBLE_"+EnvName(tt.providers)+"_BASE_URL", tt.baseURL)
+			if _, err := GetOpenAICompatibleConfigs(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Policy rule actions.
+const (
+	PolicyActionReject   = "reject"   // Refuse the request (or response) with the rule's reason
+	PolicyActionRedact   = "redact"   // Replace matches with the rule's replacement
+	PolicyActionAnnotate = "annotate" // Add the rule's name to the request's audit record
+)
+
+// PolicyRule is a regular-expression rule of the policy filter.
+type PolicyRule struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`               // Go regular expression, e.g. (?i)sk-[a-z0-9]{20,}
+	Action      string `json:"action"`                // reject, redact or annotate
+	Stage       string `json:"stage,omitempty"`       // request, response or empty for both
+	Reason      string `json:"reason,omitempty"`      // Error message of reject rules
+	Replacement string `json:"replacement,omitempty"` // Text redact rules substitute, default [REDACTED]
+}
+
+// PolicyFilterConfig configures the content policy filters run on /v1/messages.
+type PolicyFilterConfig struct {
+	Rules    []PolicyRule
+	URL      string        // External filter called with each request and response; empty disables it
+	Timeout  time.Duration // Per external filter call
+	FailOpen bool          // Let requests through when the external filter fails, instead of rejecting them
+}
+
+// Enabled reports whether any filter is configured.
+func (c PolicyFilterConfig) Enabled() bool {
+	return len(c.Rules) > 0 || c.URL != ""
+}
+
+// GetPolicyFilterConfig returns the policy filter configuration.
+// Rules come from the JSON file named by POLICY_FILTER_CONFIG (an array of PolicyRule; JSON
+// is also valid YAML). Uses POLICY_FILTER_URL, POLICY_FILTER_TIMEOUT and POLICY_FILTER_FAIL_OPEN
+// (default false) for the external filter.
+func GetPolicyFilterConfig() (PolicyFilterConfig, error) {
+	cfg := PolicyFilterConfig{
+		URL:      strings.TrimSpace(os.Getenv("POLICY_FILTER_URL")),
+		Timeout:  GetEnvDuration("POLICY_FILTER_TIMEOUT", DefaultPolicyFilterTimeout),
+		FailOpen: GetEnvBool("POLICY_FILTER_FAIL_OPEN", false),
+	}
+
+	if path := os.Getenv("POLICY_FILTER_CONFIG"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read POLICY_FILTER_CONFIG: %w", err)
+		}
+		if err := json.Unmarshal(data, &cfg.Rules); err != nil {
+			return cfg, fmt.Errorf("failed to parse POLICY_FILTER_CONFIG: %w", err)
+		}
+	}
+
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if rule.Pattern == "" {
+			return cfg, fmt.Errorf("policy rule %q has no pattern", rule.Name)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return cfg, fmt.Errorf("policy rule %q has an invalid pattern: %w", rule.Name, err)
+		}
+		switch rule.Action {
+		case PolicyActionReject, PolicyActionRedact, PolicyActionAnnotate:
+		default:
+			return cfg, fmt.Errorf("policy rule %q has unknown action %q (use reject, redact or annotate)", rule.Name, rule.Action)
+		}
+		switch rule.Stage {
+		case "", "request", "response":
+		default:
+			return cfg, fmt.Errorf("policy rule %q has unknown stage %q (use request or response)", rule.Name, rule.Stage)
+		}
+		if rule.Action == PolicyActionRedact && rule.Replacement == "" {
+			rule.Replacement = DefaultPolicyRedaction
+		}
+		if rule.Action == PolicyActionReject && rule.Reason == "" {
+			rule.Reason = "Content violates the proxy's usage policy (" + rule.Name + ")"
+		}
+	}
+	return cfg, nil
+}
+
+// Request rule actions.
+const (
+	RequestRuleAllow     = "allow"     // Let the request through and stop evaluating rules
+	RequestRuleDeny      = "deny"      // Refuse the request with the rule's reason
+	RequestRuleTransform = "transform" // Apply the rule's changes and keep evaluating
+)
+
+// RequestRule is a declarative request policy: when its expression holds for a request,
+// its action applies. Expressions use a CEL-like language, see internal/expr.
+type RequestRule struct {
+	Name   string             `json:"name"`
+	When   string             `json:"when"`             // e.g. estimated_tokens > 50000 && model.contains("opus")
+	Action string             `json:"action"`           // allow, deny or transform
+	Reason string             `json:"reason,omitempty"` // Error message of deny rules
+	Set    RequestRuleChanges `json:"set,omitempty"`    // Changes transform rules make
+}
+
+// RequestRuleChanges are the changes a transform rule makes to a request.
+type RequestRuleChanges struct {
+	Model     string `json:"model,omitempty"`      // Route to this model instead
+	MaxTokens int    `json:"max_tokens,omitempty"` // Cap max_tokens at this value
+}
+
+// GetRequestRules returns the request policy rules from the JSON file named by
+// REQUEST_POLICY_CONFIG (an array of RequestRule), or nil when it is unset. Expressions are
+// checked when the rules are compiled (policy.NewRules).
+func GetRequestRules() ([]RequestRule, error) {
+	path := os.Getenv("REQUEST_POLICY_CONFIG")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read REQUEST_POLICY_CONFIG: %w", err)
+	}
+	var rules []RequestRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse REQUEST_POLICY_CONFIG: %w", err)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if strings.TrimSpace(rule.When) == "" {
+			return nil, fmt.Errorf("request rule %q has no when expression", rule.Name)
+		}
+		switch rule.Action {
+		case RequestRuleAllow, RequestRuleDeny:
+		case RequestRuleTransform:
+			if rule.Set == (RequestRuleChanges{}) {
+				return nil, fmt.Errorf("request rule %q transforms nothing (set model or max_tokens)", rule.Name)
+			}
+			if rule.Set.MaxTokens < 0 {
+				return nil, fmt.Errorf("request rule %q has a negative max_tokens", rule.Name)
+			}
+		default:
+			return nil, fmt.Errorf("request rule %q has unknown action %q (use allow, deny or transform)", rule.Name, rule.Action)
+		}
+		if rule.Action == RequestRuleDeny && rule.Reason == "" {
+			rule.Reason = "Request denied by the proxy's request policy (" + rule.Name + ")"
+		}
+	}
+	return rules, nil
+}
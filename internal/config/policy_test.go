@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetPolicyFilterConfig(t *testing.T) {
+	write := func(t *testing.T, data string) string {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		cfg, err := GetPolicyFilterConfig()
+		if err != nil || cfg.Enabled() {
+			t.Fatalf("expected no filters, got %+v, %v", cfg, err)
+		}
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		t.Setenv("POLICY_FILTER_CONFIG", write(t, `[
+			{"pattern": "sk-[a-z0-9]+", "action": "redact"},
+			{"name": "secret", "pattern": "(?i)top secret", "action": "reject", "stage": "request"}
+		]`))
+		t.Setenv("POLICY_FILTER_URL", "http://filter:8080/check")
+
+		cfg, err := GetPolicyFilterConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !cfg.Enabled() || cfg.URL != "http://filter:8080/check" || cfg.Timeout != DefaultPolicyFilterTimeout || cfg.FailOpen {
+			t.Errorf("unexpected config: %+v", cfg)
+		}
+		if r := cfg.Rules[0]; r.Name != "rule-1" || r.Replacement != DefaultPolicyRedaction {
+			t.Errorf("expected redact defaults, got %+v", r)
+		}
+		if r := cfg.Rules[1]; !strings.Contains(r.Reason, "secret") {
+			t.Errorf("expected a default reason naming the rule, got %+v", r)
+		}
+	})
+
+	for name, rules := range map[string]string{
+		"bad pattern": `[{"pattern": "(", "action": "reject"}]`,
+		"no pattern":  `[{"action": "reject"}]`,
+		"bad action":  `[{"pattern": "x", "action": "block"}]`,
+		"bad stage":   `[{"pattern": "x", "action": "annotate", "stage": "both"}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("POLICY_FILTER_CONFIG", write(t, rules))
+			if _, err := GetPolicyFilterConfig(); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestGetRequestRules(t *testing.T) {
+	write := func(t *testing.T, data string) string {
+		path := filepath.Join(t.TempDir(), "rules.json")
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if rules, err := GetRequestRules(); err != nil || rules != nil {
+		t.Fatalf("expected no rules by default, got %+v, %v", rules, err)
+	}
+
+	t.Setenv("REQUEST_POLICY_CONFIG", write(t, `[
+		{"when": "estimated_tokens > 100000", "action": "deny"},
+		{"name": "cap", "when": "true", "action": "transform", "set": {"max_tokens": 4096}}
+	]`))
+	rules, err := GetRequestRules()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Name != "rule-1" || !strings.Contains(rules[0].Reason, "rule-1") {
+		t.Errorf("expected deny defaults, got %+v", rules)
+	}
+	if rules[1].Set.MaxTokens != 4096 {
+		t.Errorf("expected the transform's changes, got %+v", rules[1])
+	}
+
+	for name, data := range map[string]string{
+		"no when":         `[{"action": "deny"}]`,
+		"bad action":      `[{"when": "true", "action": "block"}]`,
+		"empty transform": `[{"when": "true", "action": "transform"}]`,
+		"not an array":    `{"when": "true"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("REQUEST_POLICY_CONFIG", write(t, data))
+			if _, err := GetRequestRules(); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ModelPrice is what a model would cost, in US dollars per 1,000 tokens.
+type ModelPrice struct {
+	Input  float64 `json:"input"` // Including cache reads and writes
+	Output float64 `json:"output"`
+}
+
+// ModelPricing maps model patterns to prices: "provider/model", "model", "provider/*" or
+// "*", most specific first.
+type ModelPricing map[string]ModelPrice
+
+// ForModel returns the price of a model, if the table has one.
+func (p ModelPricing) ForModel(provider, model string) (ModelPrice, bool) {
+	for _, key := range []string{provider + "/" + model, model, provider + "/*", "*"} {
+		if price, ok := p[key]; ok {
+			return price, true
+		}
+	}
+	return ModelPrice{}, false
+}
+
+// Cost returns the estimated cost in US dollars of a request's tokens; 0 when the model
+// has no price.
+func (p ModelPricing) Cost(provider, model string, inputTokens, outputTokens int) float64 {
+	price, ok := p.ForModel(provider, model)
+	if !ok {
+		return 0
+	}
+	return (float64(inputTokens)*price.Input + float64(outputTokens)*price.Output) / 1000
+}
+
+// GetModelPricing returns the pricing table from the JSON file named by MODEL_PRICING_CONFIG
+// (an object of model patterns to ModelPrice), or nil when it is unset. Upstream quota is
+// free, so these are list prices used to attribute cost, not what anyone is billed.
+func GetModelPricing() (ModelPricing, error) {
+	path := os.Getenv("MODEL_PRICING_CONFIG")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MODEL_PRICING_CONFIG: %w", err)
+	}
+	var pricing ModelPricing
+	if err := json.Unmarshal(data, &pricing); err != nil {
+		return nil, fmt.Errorf("failed to parse MODEL_PRICING_CONFIG: %w", err)
+	}
+	for pattern, price := range pricing {
+		if pattern == "" {
+			return nil, fmt.Errorf("model pricing has an empty model pattern")
+		}
+		if price.Input < 0 || price.Output < 0 {
+			return nil, fmt.Errorf("model pricing for %q has a negative price", pattern)
+		}
+	}
+	return pricing, nil
+}
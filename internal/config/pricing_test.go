@@ -0,0 +1,62 @@
+package config
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetModelPricing(t *testing.T) {
+	write := func(t *testing.T, data string) string {
+		path := filepath.Join(t.TempDir(), "pricing.json")
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("unset", func(t *testing.T) {
+		pricing, err := GetModelPricing()
+		if err != nil || pricing != nil {
+			t.Fatalf("expected no pricing, got %v, %v", pricing, err)
+		}
+	})
+
+	t.Run("most specific pattern wins", func(t *testing.T) {
+		t.Setenv("MODEL_PRICING_CONFIG", write(t, `{
+			"claude-sonnet-4-5": {"input": 0.003, "output": 0.015},
+			"copilot/claude-sonnet-4-5": {"input": 0, "output": 0},
+			"zai/*": {"input": 0.0006, "output": 0.0022}
+		}`))
+		pricing, err := GetModelPricing()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := pricing.Cost("antigravity", "claude-sonnet-4-5", 1000, 2000); math.Abs(got-0.033) > 1e-9 {
+			t.Errorf("antigravity cost = %g, want 0.033", got)
+		}
+		if got := pricing.Cost("copilot", "claude-sonnet-4-5", 1000, 2000); got != 0 {
+			t.Errorf("copilot cost = %g, want 0", got)
+		}
+		if got := pricing.Cost("zai", "glm-4.7", 1000, 1000); math.Abs(got-0.0028) > 1e-9 {
+			t.Errorf("zai cost = %g, want 0.0028", got)
+		}
+		if _, ok := pricing.ForModel("vertex", "gemini-3-pro"); ok {
+			t.Error("expected no price for an unlisted model")
+		}
+	})
+
+	for name, data := range map[string]string{
+		"invalid JSON":   `{"claude-sonnet-4-5": 3}`,
+		"negative price": `{"claude-sonnet-4-5": {"input": -1, "output": 0.015}}`,
+		"empty pattern":  `{"": {"input": 0.003, "output": 0.015}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("MODEL_PRICING_CONFIG", write(t, data))
+			if _, err := GetModelPricing(); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
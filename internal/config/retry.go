@@ -0,0 +1,130 @@
+package config
+
+import (
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how a provider retries failed upstream requests across accounts.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts per request, raised to account count + 1 unless Capped
+	Capped      bool          // MaxAttempts was set explicitly and bounds attempts across all accounts
+	BaseDelay   time.Duration // Delay before the first network retry
+	MaxDelay    time.Duration // Upper bound for the exponential delay
+	Jitter      float64       // Random +/- fraction applied to each delay (0.0-1.0)
+
+	// RetryableStatusCodes lists HTTP statuses that fail over to the next account.
+	// Empty means any 5xx status.
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy returns the built-in retry policy.
+// BaseDelay == MaxDelay keeps the historical fixed one-second network retry delay.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: MaxRetries,
+		BaseDelay:   NetworkRetryDelay,
+		MaxDelay:    NetworkRetryDelay,
+	}
+}
+
+// GetRetryPolicy returns the retry policy for a provider.
+// Global values come from RETRY_MAX_ATTEMPTS, RETRY_BASE_DELAY, RETRY_MAX_DELAY, RETRY_JITTER
+// and RETRY_STATUS_CODES; each can be overridden per provider with a prefix,
+// e.g. COPILOT_RETRY_MAX_ATTEMPTS or ZAI_RETRY_STATUS_CODES.
+func GetRetryPolicy(provider string) RetryPolicy {
+	p := DefaultRetryPolicy()
+	p = p.withEnv("RETRY_")
+	if provider != "" {
+		p = p.withEnv(EnvName(provider) + "_RETRY_")
+	}
+	return p.normalized()
+}
+
+func (p RetryPolicy) withEnv(prefix string) RetryPolicy {
+	if _, set := os.LookupEnv(prefix + "MAX_ATTEMPTS"); set {
+		p.MaxAttempts = GetEnvInt(prefix+"MAX_ATTEMPTS", p.MaxAttempts)
+		p.Capped = true
+	}
+	p.BaseDelay = GetEnvDuration(prefix+"BASE_DELAY", p.BaseDelay)
+	p.MaxDelay = GetEnvDuration(prefix+"MAX_DELAY", p.MaxDelay)
+	p.Jitter = GetEnvFloat(prefix+"JITTER", p.Jitter)
+	if codes := GetEnvStringSlice(prefix+"STATUS_CODES", nil); codes != nil {
+		p.RetryableStatusCodes = parseStatusCodes(codes)
+	}
+	return p
+}
+
+func (p RetryPolicy) normalized() RetryPolicy {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	if p.BaseDelay < 0 {
+		p.BaseDelay = 0
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	if p.Jitter < 0 || math.IsNaN(p.Jitter) {
+		p.Jitter = 0
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	return p
+}
+
+func parseStatusCodes(values []string) []int {
+	codes := make([]int, 0, len(values))
+	for _, v := range values {
+		if code, err := strconv.Atoi(v); err == nil && code >= 100 && code <= 599 {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// Attempts returns the number of attempts for a request. By default every account gets a
+// try; an explicit MaxAttempts is a hard bound.
+func (p RetryPolicy) Attempts(accountCount int) int {
+	if !p.Capped && accountCount+1 > p.MaxAttempts {
+		return accountCount + 1
+	}
+	return p.MaxAttempts
+}
+
+// Delay returns the wait before retry number attempt (0-based): BaseDelay doubled per attempt,
+// capped at MaxDelay, with optional jitter.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if attempt < 0 {
+		attempt = 0
+	}
+	d := p.BaseDelay
+	for i := 0; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		spread := float64(d) * p.Jitter
+		d = time.Duration(float64(d) - spread + rand.Float64()*2*spread)
+	}
+	return d
+}
+
+// IsRetryableStatus reports whether an HTTP status should fail over to another account.
+func (p RetryPolicy) IsRetryableStatus(status int) bool {
+	if len(p.RetryableStatusCodes) == 0 {
+		return status >= 500
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
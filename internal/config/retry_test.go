@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Attempts(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5}
+	if got := p.Attempts(2); got != 5 {
+		t.Errorf("Attempts(2) = %d, want 5", got)
+	}
+	if got := p.Attempts(7); got != 8 {
+		t.Errorf("Attempts(7) = %d, want 8", got)
+	}
+	p.Capped = true
+	if got := p.Attempts(7); got != 5 {
+		t.Errorf("capped Attempts(7) = %d, want 5", got)
+	}
+}
+
+func TestGetRetryPolicy(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		got := GetRetryPolicy("antigravity")
+		want := DefaultRetryPolicy()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetRetryPolicy() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("global and provider overrides", func(t *testing.T) {
+		os.Setenv("RETRY_MAX_ATTEMPTS", "3")
+		os.Setenv("RETRY_BASE_DELAY", "200ms")
+		os.Setenv("RETRY_MAX_DELAY", "2s")
+		os.Setenv("COPILOT_RETRY_MAX_ATTEMPTS", "8")
+		os.Setenv("COPILOT_RETRY_STATUS_CODES", "502, 503,abc,999")
+		defer func() {
+			for _, k := range []string{"RETRY_MAX_ATTEMPTS", "RETRY_BASE_DELAY", "RETRY_MAX_DELAY", "COPILOT_RETRY_MAX_ATTEMPTS", "COPILOT_RETRY_STATUS_CODES"} {
+				os.Unsetenv(k)
+			}
+		}()
+
+		zai := GetRetryPolicy("zai")
+		if zai.MaxAttempts != 3 || !zai.Capped || zai.BaseDelay != 200*time.Millisecond || zai.MaxDelay != 2*time.Second {
+			t.Errorf("zai policy = %+v", zai)
+		}
+		if zai.RetryableStatusCodes != nil {
+			t.Errorf("zai status codes = %v, want nil", zai.RetryableStatusCodes)
+		}
+
+		copilot := GetRetryPolicy("copilot")
+		if copilot.MaxAttempts != 8 {
+			t.Errorf("copilot MaxAttempts = %d, want 8", copilot.MaxAttempts)
+		}
+		if !reflect.DeepEqual(copilot.RetryableStatusCodes, []int{502, 503}) {
+			t.Errorf("copilot status codes = %v, want [502 503]", copilot.RetryableStatusCodes)
+		}
+	})
+
+	t.Run("invalid values are normalized", func(t *testing.T) {
+		os.Setenv("ZAI_RETRY_MAX_ATTEMPTS", "0")
+		os.Setenv("ZAI_RETRY_MAX_DELAY", "10ms")
+		os.Setenv("ZAI_RETRY_JITTER", "5")
+		defer func() {
+			os.Unsetenv("ZAI_RETRY_MAX_ATTEMPTS")
+			os.Unsetenv("ZAI_RETRY_MAX_DELAY")
+			os.Unsetenv("ZAI_RETRY_JITTER")
+		}()
+
+		got := GetRetryPolicy("zai")
+		if got.MaxAttempts != 1 {
+			t.Errorf("MaxAttempts = %d, want 1", got.MaxAttempts)
+		}
+		if got.MaxDelay != got.BaseDelay {
+			t.Errorf("MaxDelay = %v, want raised to BaseDelay %v", got.MaxDelay, got.BaseDelay)
+		}
+		if got.Jitter != 1 {
+			t.Errorf("Jitter = %v, want 1", got.Jitter)
+		}
+	})
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for attempt, w := range want {
+		if got := p.Delay(attempt); got != w {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, w)
+		}
+	}
+
+	if got := DefaultRetryPolicy().Delay(4); got != NetworkRetryDelay {
+		t.Errorf("default Delay(4) = %v, want fixed %v", got, NetworkRetryDelay)
+	}
+
+	jittered := RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Second, Jitter: 0.5}
+	for i := 0; i < 20; i++ {
+		if got := jittered.Delay(0); got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("jittered Delay(0) = %v, want within [500ms, 1.5s]", got)
+		}
+	}
+}
+
+func TestRetryPolicy_IsRetryableStatus(t *testing.T) {
+	def := RetryPolicy{}
+	if !def.IsRetryableStatus(500) || !def.IsRetryableStatus(503) || def.IsRetryableStatus(429) || def.IsRetryableStatus(400) {
+		t.Error("default policy should retry on any 5xx only")
+	}
+
+	custom := RetryPolicy{RetryableStatusCodes: []int{408, 503}}
+	if !custom.IsRetryableStatus(408) || !custom.IsRetryableStatus(503) || custom.IsRetryableStatus(500) {
+		t.Error("custom policy should retry only on listed statuses")
+	}
+}
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML parses the YAML subset used by the config file: nested block mappings,
+// block sequences of scalars, flow sequences ([a, b]), plain and quoted scalars and
+// comments. Scalars are returned as strings; nulls as "".
+func parseYAML(data []byte) (map[string]interface{}, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		text := strings.TrimRight(stripYAMLComment(raw), " \r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (i == 0 || len(lines) == 0) && trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") || strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	if strings.HasPrefix(lines[0].text, "-") {
+		return nil, fmt.Errorf("line %d: the top level must be a mapping", lines[0].num)
+	}
+	m, err := p.mapping(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return m, nil
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		if strings.HasPrefix(line.text, "- ") || line.text == "-" {
+			return nil, fmt.Errorf("line %d: expected a key, found a list item", line.num)
+		}
+
+		key, rest, err := splitYAMLKey(line)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			v, err := yamlValue(rest, line.num)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+
+		// Nested block: deeper mapping or sequence, or a sequence at the same indent.
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			isItem := strings.HasPrefix(next.text, "- ") || next.text == "-"
+			switch {
+			case isItem && next.indent >= indent:
+				seq, err := p.sequence(next.indent)
+				if err != nil {
+					return nil, err
+				}
+				m[key] = seq
+				continue
+			case next.indent > indent:
+				child, err := p.mapping(next.indent)
+				if err != nil {
+					return nil, err
+				}
+				m[key] = child
+				continue
+			}
+		}
+		m[key] = ""
+	}
+	return m, nil
+}
+
+func (p *yamlParser) sequence(indent int) ([]string, error) {
+	var items []string
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !(strings.HasPrefix(line.text, "- ") || line.text == "-") {
+			if line.indent > indent {
+				return nil, fmt.Errorf("line %d: nested lists and mappings in lists are not supported", line.num)
+			}
+			break
+		}
+		item := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if item == "" || strings.HasPrefix(item, "[") || isYAMLKeyLine(item) {
+			return nil, fmt.Errorf("line %d: list items must be scalars", line.num)
+		}
+		v, err := yamlScalar(item, line.num)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		p.pos++
+	}
+	return items, nil
+}
+
+// splitYAMLKey splits "key: rest" (the key may be quoted).
+func splitYAMLKey(line yamlLine) (string, string, error) {
+	text := line.text
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 {
+			return "", "", fmt.Errorf("line %d: unterminated quoted key", line.num)
+		}
+		key, err := yamlScalar(text[:end+1], line.num)
+		if err != nil {
+			return "", "", err
+		}
+		rest := text[end+1:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("line %d: expected ':' after key", line.num)
+		}
+		return key, strings.TrimSpace(rest[1:]), nil
+	}
+
+	idx := strings.Index(text, ": ")
+	if idx < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", fmt.Errorf("line %d: expected 'key: value'", line.num)
+		}
+		idx = len(text) - 1
+	}
+	key := strings.TrimSpace(text[:idx])
+	if key == "" {
+		return "", "", fmt.Errorf("line %d: empty key", line.num)
+	}
+	return key, strings.TrimSpace(text[idx+1:]), nil
+}
+
+func isYAMLKeyLine(text string) bool {
+	return strings.Contains(text, ": ") || strings.HasSuffix(text, ":") && !strings.HasPrefix(text, "\"") && !strings.HasPrefix(text, "'")
+}
+
+// yamlValue parses an inline value: a flow sequence or a scalar.
+func yamlValue(text string, num int) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("line %d: unterminated flow list", num)
+		}
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		items := []string{}
+		if inner == "" {
+			return items, nil
+		}
+		for _, part := range splitFlowItems(inner) {
+			v, err := yamlScalar(strings.TrimSpace(part), num)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case strings.HasPrefix(text, "{"):
+		return nil, fmt.Errorf("line %d: flow mappings are not supported; use an indented block", num)
+	case text == "|" || text == ">" || strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+		return nil, fmt.Errorf("line %d: multi-line strings are not supported", num)
+	case strings.HasPrefix(text, "&") || strings.HasPrefix(text, "*") || strings.HasPrefix(text, "!"):
+		return nil, fmt.Errorf("line %d: anchors, aliases and tags are not supported", num)
+	}
+	return yamlScalar(text, num)
+}
+
+// yamlScalar unquotes a scalar. Plain scalars are returned as written; null and ~ are "".
+func yamlScalar(text string, num int) (string, error) {
+	if text == "" || text == "~" || text == "null" || text == "Null" || text == "NULL" {
+		return "", nil
+	}
+	switch text[0] {
+	case '"':
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return "", fmt.Errorf("line %d: invalid double-quoted string %s", num, text)
+		}
+		return s, nil
+	case '\'':
+		if len(text) < 2 || text[len(text)-1] != '\'' {
+			return "", fmt.Errorf("line %d: invalid single-quoted string %s", num, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+	return text, nil
+}
+
+// splitFlowItems splits a flow list body on commas outside quotes.
+func splitFlowItems(s string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// closingQuote returns the index of the quote closing the string that starts at s[0].
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q:
+			if q == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a trailing "# comment" that is outside quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '[' || line[i-1] == ',' || line[i-1] == '-' || line[i-1] == ':' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,156 @@
+// Package egress routes upstream requests through a per-account outbound proxy and
+// local bind address, so accounts can leave from different IPs.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Config is where an account's upstream connections leave from. The zero value uses
+// the default transport (including HTTP_PROXY/HTTPS_PROXY from the environment).
+type Config struct {
+	Proxy       string // http://, https://, socks5:// or socks5h:// URL, with optional user:password
+	BindAddress string // local IP address to dial from
+}
+
+// IsZero reports whether c changes nothing.
+func (c Config) IsZero() bool {
+	return c.Proxy == "" && c.BindAddress == ""
+}
+
+// Validate checks the proxy URL and bind address.
+func (c Config) Validate() error {
+	if c.Proxy != "" {
+		u, err := url.Parse(c.Proxy)
+		if err != nil {
+			// url.Error repeats the URL, which may contain a password.
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("invalid proxy URL %q: scheme must be http, https, socks5 or socks5h", u.Redacted())
+		}
+		if u.Host == "" {
+			return fmt.Errorf("invalid proxy URL %q: missing host", u.Redacted())
+		}
+	}
+	if c.BindAddress != "" && net.ParseIP(c.BindAddress) == nil {
+		return fmt.Errorf("invalid bind address %q: must be an IP address", c.BindAddress)
+	}
+	return nil
+}
+
+// String describes the config without proxy credentials.
+func (c Config) String() string {
+	var proxy string
+	if c.Proxy != "" {
+		if u, err := url.Parse(c.Proxy); err == nil {
+			proxy = u.Redacted()
+		}
+	}
+	switch {
+	case proxy != "" && c.BindAddress != "":
+		return fmt.Sprintf("%s from %s", proxy, c.BindAddress)
+	case proxy != "":
+		return proxy
+	case c.BindAddress != "":
+		return "from " + c.BindAddress
+	}
+	return "direct"
+}
+
+type contextKey struct{}
+
+// WithConfig returns ctx carrying cfg. Requests made with the returned context through
+// Transport leave through cfg.
+func WithConfig(ctx context.Context, cfg Config) context.Context {
+	if cfg.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, cfg)
+}
+
+// FromContext returns the config carried by ctx, or the zero Config.
+func FromContext(ctx context.Context) Config {
+	cfg, _ := ctx.Value(contextKey{}).(Config)
+	return cfg
+}
+
+// Transport returns a RoundTripper that sends each request through the transport for
+// the egress config in its context, creating one transport per distinct config so
+// connections are pooled per account rather than shared across accounts.
+func Transport() http.RoundTripper {
+	return defaultRouter
+}
+
+var defaultRouter = &router{transports: make(map[Config]*http.Transport)}
+
+type router struct {
+	mu         sync.Mutex
+	transports map[Config]*http.Transport
+}
+
+func (r *router) RoundTrip(req *http.Request) (*http.Response, error) {
+	cfg := FromContext(req.Context())
+	if cfg.IsZero() {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	t, err := r.transportFor(cfg)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("egress: %w", err)
+	}
+	return t.RoundTrip(req)
+}
+
+func (r *router) transportFor(cfg Config) (*http.Transport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.transports[cfg]; ok {
+		return t, nil
+	}
+	t, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.transports[cfg] = t
+	return t, nil
+}
+
+// newTransport builds a transport like http.DefaultTransport that dials from the bind
+// address and through the proxy of cfg.
+func newTransport(cfg Config) (*http.Transport, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if cfg.BindAddress != "" {
+		dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(cfg.BindAddress)}
+	}
+	t.DialContext = dialer.DialContext
+
+	if cfg.Proxy == "" {
+		return t, nil
+	}
+	u, _ := url.Parse(cfg.Proxy) // validated above
+	// net/http speaks SOCKS5 itself, connecting to the proxy with DialContext, so the bind
+	// address applies to proxied connections too.
+	t.Proxy = http.ProxyURL(u)
+	return t, nil
+}
@@ -3,6 +3,7 @@ package errors
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -13,15 +14,26 @@ import (
 type ErrorType string
 
 const (
-	ErrorTypeInvalidRequest ErrorType = "invalid_request_error"
-	ErrorTypeAuthentication ErrorType = "authentication_error"
-	ErrorTypePermission     ErrorType = "permission_error"
-	ErrorTypeNotFound       ErrorType = "not_found_error"
-	ErrorTypeRateLimit      ErrorType = "rate_limit_error"
-	ErrorTypeAPI            ErrorType = "api_error"
-	ErrorTypeOverloaded     ErrorType = "overloaded_error"
+	ErrorTypeInvalidRequest  ErrorType = "invalid_request_error"
+	ErrorTypeAuthentication  ErrorType = "authentication_error"
+	ErrorTypePermission      ErrorType = "permission_error"
+	ErrorTypeNotFound        ErrorType = "not_found_error"
+	ErrorTypeRequestTooLarge ErrorType = "request_too_large"
+	ErrorTypeRateLimit       ErrorType = "rate_limit_error"
+	ErrorTypeAPI             ErrorType = "api_error"
+	ErrorTypeOverloaded      ErrorType = "overloaded_error"
 )
 
+// IsDocumented reports whether t is one of the error types Anthropic documents.
+func (t ErrorType) IsDocumented() bool {
+	switch t {
+	case ErrorTypeInvalidRequest, ErrorTypeAuthentication, ErrorTypePermission, ErrorTypeNotFound,
+		ErrorTypeRequestTooLarge, ErrorTypeRateLimit, ErrorTypeAPI, ErrorTypeOverloaded:
+		return true
+	}
+	return false
+}
+
 // AnthropicError represents an error response in Anthropic format.
 type AnthropicError struct {
 	Type   string      `json:"type"` // Always "error"
@@ -62,6 +74,8 @@ func (e *AnthropicError) StatusCode() int {
 		return http.StatusForbidden
 	case ErrorTypeNotFound:
 		return http.StatusNotFound
+	case ErrorTypeRequestTooLarge:
+		return http.StatusRequestEntityTooLarge
 	case ErrorTypeRateLimit:
 		return http.StatusTooManyRequests
 	case ErrorTypeOverloaded:
@@ -118,6 +132,14 @@ func FromError(err error) *AnthropicError {
 		return ae
 	}
 
+	// Upstream HTTP errors map from their payload; rate limits keep the quota message below.
+	var ue upstreamError
+	if stderrors.As(err, &ue) {
+		if ae := ue.AnthropicError(); ae != nil && ae.Detail.Type != ErrorTypeRateLimit {
+			return ae
+		}
+	}
+
 	errStr := err.Error()
 
 	// Node parity: match src/server.js parseError() ordering.
@@ -191,6 +213,22 @@ func FromError(err error) *AnthropicError {
 	return APIError(errStr)
 }
 
+// StreamError returns the error event for an error that interrupts a stream. Documented
+// error types are kept; anything else becomes an overloaded_error when the message says
+// the upstream is overloaded or unavailable (clients retry those), and an api_error otherwise.
+func StreamError(errType, message string) *AnthropicError {
+	if t := ErrorType(errType); t.IsDocumented() {
+		return NewError(t, message)
+	}
+	lowerMsg := strings.ToLower(message)
+	for _, marker := range []string{"overloaded", "503", "529", "unavailable"} {
+		if strings.Contains(lowerMsg, marker) {
+			return OverloadedError(message)
+		}
+	}
+	return APIError(message)
+}
+
 func formatQuotaExhaustedMessage(errStr string) string {
 	resetRe := regexp.MustCompile(`(?i)quota will reset after ([0-9hms]+)`)
 	modelRe := regexp.MustCompile(`Rate limited on ([^.]+)\.`)
@@ -0,0 +1,55 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Request phases with their own deadline (see config.RequestTimeouts).
+const (
+	PhaseRequest    = "request"
+	PhaseTokenFetch = "token fetch"
+	PhaseConnect    = "upstream connect"
+	PhaseFirstToken = "first token"
+	PhaseStream     = "stream"
+)
+
+// TimeoutError reports that a phase of a request ran past its deadline. Clients get it as an
+// api_error (504 before a stream starts, an error event after).
+type TimeoutError struct {
+	Phase   string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("Request timed out: %s deadline of %s exceeded", e.Phase, e.Timeout)
+}
+
+// AnthropicError maps the timeout to an api_error with status 504.
+func (e *TimeoutError) AnthropicError() *AnthropicError {
+	ae := APIError(e.Error())
+	ae.HTTPStatus = http.StatusGatewayTimeout
+	return ae
+}
+
+// WithTimeout returns ctx with a deadline d from now, whose cause is a TimeoutError for
+// phase. d <= 0 sets no deadline.
+func WithTimeout(ctx context.Context, phase string, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, d, &TimeoutError{Phase: phase, Timeout: d})
+}
+
+// TimeoutCause returns the TimeoutError that ended ctx, or nil if ctx is live or ended
+// for another reason, such as the client going away.
+func TimeoutCause(ctx context.Context) *TimeoutError {
+	var te *TimeoutError
+	if ctx.Err() != nil && stderrors.As(context.Cause(ctx), &te) {
+		return te
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// upstreamError is implemented by provider errors that carry an upstream HTTP error
+// response, so FromError maps the upstream's structured error instead of guessing from text.
+type upstreamError interface {
+	AnthropicError() *AnthropicError
+}
+
+// upstreamPayload is an error response body: Anthropic's {"type":"error","error":{...}},
+// OpenAI's {"error":{...}} and Google's {"error":{"code":..,"status":..}} share this shape.
+type upstreamPayload struct {
+	Error   json.RawMessage `json:"error"`
+	Message string          `json:"message"`
+}
+
+// upstreamDetail is the error object of an upstreamPayload.
+type upstreamDetail struct {
+	Type    string          `json:"type"`   // Anthropic and OpenAI
+	Code    json.RawMessage `json:"code"`   // OpenAI: a string such as "context_length_exceeded"; Google: the HTTP status
+	Status  string          `json:"status"` // Google: the canonical code, such as "INVALID_ARGUMENT"
+	Message string          `json:"message"`
+}
+
+// googleStatusTypes maps Google API canonical error codes to Anthropic error types.
+var googleStatusTypes = map[string]ErrorType{
+	"INVALID_ARGUMENT":    ErrorTypeInvalidRequest,
+	"FAILED_PRECONDITION": ErrorTypeInvalidRequest,
+	"OUT_OF_RANGE":        ErrorTypeInvalidRequest,
+	"UNAUTHENTICATED":     ErrorTypeAuthentication,
+	"PERMISSION_DENIED":   ErrorTypePermission,
+	"NOT_FOUND":           ErrorTypeNotFound,
+	"RESOURCE_EXHAUSTED":  ErrorTypeRateLimit,
+	"UNAVAILABLE":         ErrorTypeOverloaded,
+}
+
+// openAICodeTypes maps OpenAI error codes to Anthropic error types.
+var openAICodeTypes = map[string]ErrorType{
+	"context_length_exceeded": ErrorTypeInvalidRequest,
+	"string_above_max_length": ErrorTypeInvalidRequest,
+	"invalid_value":           ErrorTypeInvalidRequest,
+	"model_not_supported":     ErrorTypeInvalidRequest,
+	"model_not_found":         ErrorTypeNotFound,
+	"insufficient_quota":      ErrorTypeRateLimit,
+	"rate_limit_exceeded":     ErrorTypeRateLimit,
+}
+
+// FromUpstream returns the Anthropic error for an upstream HTTP error response, keeping the
+// upstream's message. The type comes from the payload (Anthropic error types, Google
+// canonical codes, OpenAI error codes) and otherwise from the HTTP status.
+func FromUpstream(status int, body []byte) *AnthropicError {
+	body = bytes.TrimSpace(body)
+	var payload upstreamPayload
+	if bytes.HasPrefix(body, []byte("[")) {
+		// Cloud Code sometimes wraps the error in an array.
+		var payloads []upstreamPayload
+		if json.Unmarshal(body, &payloads) == nil && len(payloads) > 0 {
+			payload = payloads[0]
+		}
+	} else {
+		_ = json.Unmarshal(body, &payload)
+	}
+
+	var detail upstreamDetail
+	if json.Unmarshal(payload.Error, &detail) != nil {
+		// Some OpenAI-compatible upstreams send the message as a plain string.
+		_ = json.Unmarshal(payload.Error, &detail.Message)
+	}
+	var code string
+	_ = json.Unmarshal(detail.Code, &code)
+
+	message := detail.Message
+	if message == "" {
+		message = payload.Message
+	}
+	if message == "" {
+		message = string(body)
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	errType, ok := ErrorType(detail.Type), ErrorType(detail.Type).IsDocumented()
+	if !ok {
+		errType, ok = googleStatusTypes[strings.ToUpper(detail.Status)]
+	}
+	if !ok {
+		errType, ok = openAICodeTypes[code]
+	}
+	if !ok {
+		errType = statusErrorType(status)
+	}
+	return NewError(errType, message)
+}
+
+// statusErrorType returns the Anthropic error type for an HTTP status.
+func statusErrorType(status int) ErrorType {
+	switch status {
+	case http.StatusUnauthorized:
+		return ErrorTypeAuthentication
+	case http.StatusForbidden:
+		return ErrorTypePermission
+	case http.StatusNotFound:
+		return ErrorTypeNotFound
+	case http.StatusRequestEntityTooLarge:
+		return ErrorTypeRequestTooLarge
+	case http.StatusTooManyRequests:
+		return ErrorTypeRateLimit
+	case http.StatusServiceUnavailable, 529:
+		return ErrorTypeOverloaded
+	}
+	if status >= 400 && status < 500 {
+		return ErrorTypeInvalidRequest
+	}
+	return ErrorTypeAPI
+}
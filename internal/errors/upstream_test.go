@@ -0,0 +1,115 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestFromUpstream(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantType    ErrorType
+		wantMessage string
+	}{
+		{
+			name:        "Google context too long",
+			status:      400,
+			body:        `{"error":{"code":400,"message":"The input token count (1200000) exceeds the maximum number of tokens allowed (1048576).","status":"INVALID_ARGUMENT"}}`,
+			wantType:    ErrorTypeInvalidRequest,
+			wantMessage: "The input token count (1200000) exceeds the maximum number of tokens allowed (1048576).",
+		},
+		{
+			name:        "Google error in an array",
+			status:      403,
+			body:        `[{"error":{"code":403,"message":"The caller does not have permission","status":"PERMISSION_DENIED"}}]`,
+			wantType:    ErrorTypePermission,
+			wantMessage: "The caller does not have permission",
+		},
+		{
+			name:        "Google unavailable",
+			status:      503,
+			body:        `{"error":{"code":503,"message":"The model is overloaded. Please try again later.","status":"UNAVAILABLE"}}`,
+			wantType:    ErrorTypeOverloaded,
+			wantMessage: "The model is overloaded. Please try again later.",
+		},
+		{
+			name:        "OpenAI context length",
+			status:      400,
+			body:        `{"error":{"message":"This model's maximum context length is 128000 tokens.","type":"invalid_request_error","code":"context_length_exceeded"}}`,
+			wantType:    ErrorTypeInvalidRequest,
+			wantMessage: "This model's maximum context length is 128000 tokens.",
+		},
+		{
+			name:        "OpenAI code without a documented type",
+			status:      404,
+			body:        `{"error":{"message":"The model gpt-9 does not exist","type":"invalid_request","code":"model_not_found"}}`,
+			wantType:    ErrorTypeNotFound,
+			wantMessage: "The model gpt-9 does not exist",
+		},
+		{
+			name:        "Anthropic",
+			status:      400,
+			body:        `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`,
+			wantType:    ErrorTypeInvalidRequest,
+			wantMessage: "prompt is too long: 210000 tokens > 200000 maximum",
+		},
+		{
+			name:        "plain string error",
+			status:      422,
+			body:        `{"error":"unsupported parameter: logit_bias"}`,
+			wantType:    ErrorTypeInvalidRequest,
+			wantMessage: "unsupported parameter: logit_bias",
+		},
+		{
+			name:        "not JSON",
+			status:      413,
+			body:        "Request Entity Too Large",
+			wantType:    ErrorTypeRequestTooLarge,
+			wantMessage: "Request Entity Too Large",
+		},
+		{
+			name:        "empty body",
+			status:      500,
+			wantType:    ErrorTypeAPI,
+			wantMessage: "Internal Server Error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ae := FromUpstream(tt.status, []byte(tt.body))
+			if ae.Detail.Type != tt.wantType || ae.Detail.Message != tt.wantMessage {
+				t.Errorf("FromUpstream = %s %q, want %s %q", ae.Detail.Type, ae.Detail.Message, tt.wantType, tt.wantMessage)
+			}
+		})
+	}
+}
+
+// statusErr is an upstream HTTP error as providers return it.
+type statusErr struct {
+	status int
+	body   string
+}
+
+func (e *statusErr) Error() string { return fmt.Sprintf("API error %d: %s", e.status, e.body) }
+
+func (e *statusErr) AnthropicError() *AnthropicError {
+	return FromUpstream(e.status, []byte(e.body))
+}
+
+func TestFromError_UpstreamError(t *testing.T) {
+	// The message mentions tokens, which the text heuristics would take for an auth error.
+	err := fmt.Errorf("request failed: %w", &statusErr{status: 400, body: `{"error":{"code":400,"message":"The input token count exceeds the maximum","status":"INVALID_ARGUMENT"}}`})
+	ae := FromError(err)
+	if ae.Detail.Type != ErrorTypeInvalidRequest || ae.Detail.Message != "The input token count exceeds the maximum" || ae.StatusCode() != http.StatusBadRequest {
+		t.Errorf("unexpected error: %s %q (%d)", ae.Detail.Type, ae.Detail.Message, ae.StatusCode())
+	}
+
+	// Rate limits keep the quota message.
+	ae = FromError(&statusErr{status: 429, body: `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`})
+	if ae.Detail.Type != ErrorTypeInvalidRequest {
+		t.Errorf("expected the quota exhausted error, got %s %q", ae.Detail.Type, ae.Detail.Message)
+	}
+}
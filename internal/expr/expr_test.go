@@ -0,0 +1,125 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]any{
+		"model":  "anthropic/claude-opus-4",
+		"tokens": 12000,
+		"stream": true,
+		"flags":  []string{"pii", "secret"},
+		"meta":   map[string]string{"team": "search"},
+	}
+	names := []string{"model", "tokens", "stream", "flags", "meta"}
+
+	tests := []struct {
+		src  string
+		want any
+	}{
+		{`1 + 2 * 3`, int64(7)},
+		{`(1 + 2) * 3`, int64(9)},
+		{`7 / 2`, int64(3)},
+		{`7 % 4`, int64(3)},
+		{`7.0 / 2`, 3.5},
+		{`-tokens`, int64(-12000)},
+		{`tokens > 10000 && stream`, true},
+		{`tokens > 10000 && !stream`, false},
+		{`tokens >= 12000.0`, true},
+		{`model.startsWith("anthropic/") || false`, true},
+		{`model.endsWith('opus-4')`, true},
+		{`model.contains("sonnet")`, false},
+		{`model.matches("^[a-z]+/claude-(opus|sonnet)")`, true},
+		{`"pii" in flags`, true},
+		{`"toxic" in flags`, false},
+		{`"team" in meta`, true},
+		{`meta.team == "search"`, true},
+		{`meta["team"]`, "search"},
+		{`flags[1]`, "secret"},
+		{`size(flags)`, int64(2)},
+		{`flags.size() == 2`, true},
+		{`size("héllo")`, int64(5)},
+		{`model in ["a", "anthropic/claude-opus-4"]`, true},
+		{`stream ? "s" : "n"`, "s"},
+		{`"a" + "b" == "ab"`, true},
+		{`"ABC".lowerAscii()`, "abc"},
+		{`int("42") + 1`, int64(43)},
+		{`string(tokens)`, "12000"},
+		{`null == null`, true},
+		{`1 == 1.0`, true},
+		{`[1, 2] == [1, 2]`, true},
+		{`"a" < "b"`, true},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.src, names)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.src, err)
+			continue
+		}
+		got, err := p.Eval(vars)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.src, err)
+			continue
+		}
+		if !equal(got, tt.want) || typeName(got) != typeName(tt.want) {
+			t.Errorf("Eval(%q) = %v (%s), want %v", tt.src, got, typeName(got), tt.want)
+		}
+	}
+}
+
+func TestEval_ShortCircuit(t *testing.T) {
+	// The right-hand side would fail (no such key) if it were evaluated.
+	p, err := Compile(`"x" in meta && meta.x == 1`, []string{"meta"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := p.EvalBool(map[string]any{"meta": map[string]any{}})
+	if err != nil || ok {
+		t.Fatalf("EvalBool = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`modle == "x"`, `unknown variable "modle"`},
+		{`model ==`, `unexpected "end of expression"`},
+		{`model == "x`, `unterminated string`},
+		{`model.reverse()`, `unknown method "reverse"`},
+		{`len(model)`, `unknown function "len"`},
+		{`(model == "x"`, `expected ")"`},
+		{`model # 1`, `unexpected character`},
+		{`model "x"`, `unexpected "\"x\""`},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src, []string{"model"})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Compile(%q) error = %v, want it to contain %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	vars := map[string]any{"model": "m", "n": 1}
+	tests := []string{
+		`model > 1`,
+		`n / 0`,
+		`model && true`,
+		`n`, // not a bool
+		`model.matches("(")`,
+	}
+	for _, src := range tests {
+		p, err := Compile(src, []string{"model", "n"})
+		if err != nil {
+			t.Errorf("Compile(%q): %v", src, err)
+			continue
+		}
+		if _, err := p.EvalBool(vars); err == nil {
+			t.Errorf("EvalBool(%q) succeeded, want an error", src)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+// Package metrics keeps in-process request metrics and renders them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TokensPerSecondBuckets are the upper bounds used for output throughput histograms.
+var TokensPerSecondBuckets = []float64{5, 10, 20, 35, 50, 75, 100, 150, 200, 300, 500}
+
+// OutputTokensPerSecond tracks output tokens per second of /v1/messages requests by provider,
+// model and account.
+var OutputTokensPerSecond = NewHistogram(
+	"proxy_output_tokens_per_second",
+	"Output tokens per second of /v1/messages responses, by provider, model and account.",
+	TokensPerSecondBuckets,
+)
+
+// LatencyBuckets are the upper bounds, in seconds, used for latency histograms.
+var LatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60}
+
+// TimeToFirstEvent tracks the time from sending a streaming /v1/messages request upstream to
+// its first SSE event, by provider, model and account.
+var TimeToFirstEvent = NewHistogram(
+	"proxy_time_to_first_event_seconds",
+	"Seconds from sending a streaming /v1/messages request upstream to its first event, by provider, model and account.",
+	LatencyBuckets,
+)
+
+// ByteBuckets are the upper bounds used for payload size histograms (1 KiB to 64 MiB).
+var ByteBuckets = []float64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20, 64 << 20}
+
+// RequestBytes tracks the size of /v1/messages requests sent on to a provider, by provider and model.
+var RequestBytes = NewHistogram(
+	"proxy_request_bytes",
+	"Size in bytes of /v1/messages request bodies sent to a provider, by provider and model.",
+	ByteBuckets,
+)
+
+// ResponseBytes tracks the size of /v1/messages responses returned by a provider, by provider and model.
+var ResponseBytes = NewHistogram(
+	"proxy_response_bytes",
+	"Size in bytes of /v1/messages response bodies (JSON or SSE) from a provider, by provider and model.",
+	ByteBuckets,
+)
+
+// RequestsTooLarge counts /v1/messages requests rejected by MAX_UPSTREAM_REQUEST_KB, by provider and model.
+var RequestsTooLarge = NewCounter(
+	"proxy_requests_too_large_total",
+	"Requests rejected for exceeding the provider's upstream request size limit, by provider and model.",
+)
+
+// RequestGuardRejections counts /v1/messages requests rejected by MAX_REQUEST_MESSAGES,
+// MAX_REQUEST_TEXT_CHARS or MAX_REQUEST_IMAGES, by provider and model.
+var RequestGuardRejections = NewCounter(
+	"proxy_request_guard_rejections_total",
+	"Requests rejected for exceeding the model's message, text or image limits, by provider and model.",
+)
+
+// Refusals counts responses an upstream refused (content policy), by provider and model.
+var Refusals = NewCounter(
+	"proxy_refusals_total",
+	"Responses refused by the upstream content policy, by provider and model.",
+)
+
+// PolicyRejections counts requests and responses rejected by the proxy's content policy
+// filters, by provider and model.
+var PolicyRejections = NewCounter(
+	"proxy_policy_rejections_total",
+	"Requests and responses rejected by the proxy's content policy filters, by provider and model.",
+)
+
+// QuotaUsageDiscrepancies counts quota samples whose change was out of line with the tokens
+// sent through the account, by provider and model.
+var QuotaUsageDiscrepancies = NewCounter(
+	"proxy_quota_usage_discrepancies_total",
+	"Quota changes that don't match the tokens the proxy sent through the account, by provider and model.",
+)
+
+// ClientCancellations counts /v1/messages requests whose client disconnected before the
+// response was complete, by provider and model.
+var ClientCancellations = NewCounter(
+	"proxy_client_cancellations_total",
+	"Requests cancelled because the client disconnected before the response was complete, by provider and model.",
+)
+
+// StreamRecoveries counts /v1/messages streams resumed after failing mid-stream
+// (STREAM_RECOVERY), by provider and model.
+var StreamRecoveries = NewCounter(
+	"proxy_stream_recoveries_total",
+	"Streams resumed after the upstream stream failed mid-response, by provider and model.",
+)
+
+// CoalescedRequests counts /v1/messages requests answered with the response of an identical
+// request in flight (REQUEST_COALESCING_ENABLED), by provider and model.
+var CoalescedRequests = NewCounter(
+	"proxy_coalesced_requests_total",
+	"Requests answered with the response of an identical concurrent request, by provider and model.",
+)
+
+// StopSequencesEnforced counts /v1/messages responses the proxy cut at a stop sequence the
+// upstream ignored, by provider and model.
+var StopSequencesEnforced = NewCounter(
+	"proxy_stop_sequences_enforced_total",
+	"Responses cut at a stop sequence the upstream ignored, by provider and model.",
+)
+
+// SoftLimitReroutes counts /v1/messages requests moved to an equivalent model on another
+// provider because every account was soft-limited (SOFT_LIMIT_SCHEDULER=global), by the
+// provider and model they were moved off.
+var SoftLimitReroutes = NewCounter(
+	"proxy_soft_limit_reroutes_total",
+	"Requests routed to an equivalent model on another provider because every account was soft-limited, by original provider and model.",
+)
+
+// SignatureCacheEntries, SignatureCacheSessions, SignatureCacheHits, SignatureCacheMisses and
+// SignatureCacheEvictions report the Antigravity signature cache.
+var (
+	SignatureCacheEntries = NewSampled(
+		"proxy_signature_cache_entries", "gauge",
+		"Tool and thinking signatures in the signature cache.",
+	)
+	SignatureCacheSessions = NewSampled(
+		"proxy_signature_cache_sessions", "gauge",
+		"Conversations with signatures in the signature cache.",
+	)
+	SignatureCacheHits = NewSampled(
+		"proxy_signature_cache_hits_total", "counter",
+		"Signature cache lookups that found an unexpired signature.",
+	)
+	SignatureCacheMisses = NewSampled(
+		"proxy_signature_cache_misses_total", "counter",
+		"Signature cache lookups of unknown or expired signatures.",
+	)
+	SignatureCacheEvictions = NewSampled(
+		"proxy_signature_cache_evictions_total", "counter",
+		"Signatures evicted from the signature cache by its size limits.",
+	)
+)
+
+// Histogram is a cumulative histogram partitioned by provider/model labels and, optionally,
+// an account label. It is safe for concurrent use.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[seriesKey]*series
+}
+
+type seriesKey struct {
+	provider string
+	model    string
+	account  string // Only labelled when set
+}
+
+type series struct {
+	counts []uint64 // per bucket, non-cumulative; the last entry is +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates a histogram with the given (ascending) bucket upper bounds.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		series:  make(map[seriesKey]*series),
+	}
+}
+
+// Observe records a value for a provider/model pair.
+func (h *Histogram) Observe(provider, model string, value float64) {
+	h.ObserveAccount(provider, model, "", value)
+}
+
+// ObserveAccount records a value for a provider/model pair served by account. An empty
+// account records it without the account label.
+func (h *Histogram) ObserveAccount(provider, model, account string, value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := seriesKey{provider: provider, model: model, account: account}
+	s := h.series[key]
+	if s == nil {
+		s = &series{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	s.counts[sort.SearchFloat64s(h.buckets, value)]++
+	s.sum += value
+	s.count++
+}
+
+// Count returns the number of observations for a provider/model pair, across accounts.
+func (h *Histogram) Count(provider, model string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var count uint64
+	for k, s := range h.series {
+		if k.provider == provider && k.model == model {
+			count += s.count
+		}
+	}
+	return count
+}
+
+// Reset drops all observations.
+func (h *Histogram) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.series = make(map[seriesKey]*series)
+}
+
+// WriteTo writes the histogram in the Prometheus text exposition format.
+func (h *Histogram) WriteTo(w io.Writer) (int64, error) {
+	h.mu.Lock()
+	keys := make([]seriesKey, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sortSeriesKeys(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, k := range keys {
+		s := h.series[k]
+		labels := k.labels()
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"%s\"} %d\n", h.name, labels, formatFloat(upper), cumulative)
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", h.name, labels, s.count)
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", h.name, labels, formatFloat(s.sum))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", h.name, labels, s.count)
+	}
+	h.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Counter is a monotonically increasing count partitioned by provider/model labels.
+// It is safe for concurrent use.
+type Counter struct {
+	name string
+	help string
+
+	mu     sync.Mutex
+	counts map[seriesKey]uint64
+}
+
+// NewCounter creates a counter.
+func NewCounter(name, help string) *Counter {
+	return &Counter{name: name, help: help, counts: make(map[seriesKey]uint64)}
+}
+
+// Inc increments the count for a provider/model pair.
+func (c *Counter) Inc(provider, model string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[seriesKey{provider: provider, model: model}]++
+}
+
+// Count returns the count for a provider/model pair.
+func (c *Counter) Count(provider, model string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[seriesKey{provider: provider, model: model}]
+}
+
+// Reset drops all counts.
+func (c *Counter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = make(map[seriesKey]uint64)
+}
+
+// WriteTo writes the counter in the Prometheus text exposition format.
+func (c *Counter) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	keys := make([]seriesKey, 0, len(c.counts))
+	for k := range c.counts {
+		keys = append(keys, k)
+	}
+	sortSeriesKeys(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s{%s} %d\n", c.name, k.labels(), c.counts[k])
+	}
+	c.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Sampled is an unlabelled metric whose value is read from a function when the metrics are
+// written, for state kept elsewhere such as the size of a cache. It is only written once the
+// function is set. It is safe for concurrent use.
+type Sampled struct {
+	name string
+	kind string // "gauge" or "counter"
+	help string
+
+	mu     sync.Mutex
+	sample func() float64
+}
+
+// NewSampled creates a sampled metric of kind "gauge" or "counter".
+func NewSampled(name, kind, help string) *Sampled {
+	return &Sampled{name: name, kind: kind, help: help}
+}
+
+// Set sets the function the value is read from; nil stops the metric from being written.
+func (s *Sampled) Set(sample func() float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sample = sample
+}
+
+// WriteTo writes the metric in the Prometheus text exposition format.
+func (s *Sampled) WriteTo(w io.Writer) (int64, error) {
+	s.mu.Lock()
+	sample := s.sample
+	s.mu.Unlock()
+	if sample == nil {
+		return 0, nil
+	}
+	n, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", s.name, s.help, s.name, s.kind, s.name, formatFloat(sample()))
+	return int64(n), err
+}
+
+// WriteAll writes every registered metric in the Prometheus text exposition format.
+func WriteAll(w io.Writer) error {
+	for _, m := range []io.WriterTo{OutputTokensPerSecond, TimeToFirstEvent, RequestBytes, ResponseBytes, RequestsTooLarge, RequestGuardRejections, Refusals, PolicyRejections, QuotaUsageDiscrepancies, ClientCancellations, StreamRecoveries, CoalescedRequests, StopSequencesEnforced, SoftLimitReroutes, SignatureCacheEntries, SignatureCacheSessions, SignatureCacheHits, SignatureCacheMisses, SignatureCacheEvictions} {
+		if _, err := m.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortSeriesKeys(keys []seriesKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].provider != keys[j].provider {
+			return keys[i].provider < keys[j].provider
+		}
+		if keys[i].model != keys[j].model {
+			return keys[i].model < keys[j].model
+		}
+		return keys[i].account < keys[j].account
+	})
+}
+
+func (k seriesKey) labels() string {
+	labels := fmt.Sprintf(`provider="%s",model="%s"`, EscapeLabel(k.provider), EscapeLabel(k.model))
+	if k.account != "" {
+		labels += fmt.Sprintf(`,account="%s"`, EscapeLabel(k.account))
+	}
+	return labels
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// EscapeLabel escapes s for use as a label value in the Prometheus text format.
+func EscapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
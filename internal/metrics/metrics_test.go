@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHistogram_WriteTo(t *testing.T) {
+	h := NewHistogram("test_tps", "Test throughput.", []float64{10, 50})
+	h.Observe("zai", "glm-4.7", 5)
+	h.Observe("zai", "glm-4.7", 10)
+	h.Observe("zai", "glm-4.7", 80)
+	h.Observe("copilot", `gpt"4`, 20)
+
+	var b strings.Builder
+	if _, err := h.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP test_tps Test throughput.
+# TYPE test_tps histogram
+test_tps_bucket{provider="copilot",model="gpt\"4",le="10"} 0
+test_tps_bucket{provider="copilot",model="gpt\"4",le="50"} 1
+test_tps_bucket{provider="copilot",model="gpt\"4",le="+Inf"} 1
+test_tps_sum{provider="copilot",model="gpt\"4"} 20
+test_tps_count{provider="copilot",model="gpt\"4"} 1
+test_tps_bucket{provider="zai",model="glm-4.7",le="10"} 2
+test_tps_bucket{provider="zai",model="glm-4.7",le="50"} 2
+test_tps_bucket{provider="zai",model="glm-4.7",le="+Inf"} 3
+test_tps_sum{provider="zai",model="glm-4.7"} 95
+test_tps_count{provider="zai",model="glm-4.7"} 3
+`
+	if b.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestHistogram_IgnoresInvalidValues(t *testing.T) {
+	h := NewHistogram("test_tps", "Test throughput.", []float64{10})
+	var zero float64
+	h.Observe("zai", "glm", zero/zero)
+	h.Observe("zai", "glm", 1/zero)
+	if n := h.Count("zai", "glm"); n != 0 {
+		t.Errorf("expected invalid values to be dropped, got %d observations", n)
+	}
+}
+
+func TestCounter_WriteTo(t *testing.T) {
+	c := NewCounter("test_total", "Test count.")
+	c.Inc("copilot", "gpt-4o")
+	c.Inc("copilot", "gpt-4o")
+	c.Inc("anthropic", "claude")
+
+	var b strings.Builder
+	if _, err := c.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_total Test count.
+# TYPE test_total counter
+test_total{provider="anthropic",model="claude"} 1
+test_total{provider="copilot",model="gpt-4o"} 2
+`
+	if b.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestSampled_WriteTo(t *testing.T) {
+	s := NewSampled("test_entries", "gauge", "Test entries.")
+
+	var b strings.Builder
+	if _, err := s.WriteTo(&b); err != nil || b.Len() != 0 {
+		t.Fatalf("expected nothing before the sample function is set, got %q (%v)", b.String(), err)
+	}
+	s.Set(func() float64 { return 42 })
+	if _, err := s.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_entries Test entries.
+# TYPE test_entries gauge
+test_entries 42
+`
+	if b.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestHistogram_AccountLabel(t *testing.T) {
+	h := NewHistogram("test_ttfe", "Test latency.", []float64{1})
+	h.ObserveAccount("zai", "glm-4.7", "b@example.com", 2)
+	h.ObserveAccount("zai", "glm-4.7", "a@example.com", 0.5)
+
+	var b strings.Builder
+	if _, err := h.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_ttfe Test latency.
+# TYPE test_ttfe histogram
+test_ttfe_bucket{provider="zai",model="glm-4.7",account="a@example.com",le="1"} 1
+test_ttfe_bucket{provider="zai",model="glm-4.7",account="a@example.com",le="+Inf"} 1
+test_ttfe_sum{provider="zai",model="glm-4.7",account="a@example.com"} 0.5
+test_ttfe_count{provider="zai",model="glm-4.7",account="a@example.com"} 1
+test_ttfe_bucket{provider="zai",model="glm-4.7",account="b@example.com",le="1"} 0
+test_ttfe_bucket{provider="zai",model="glm-4.7",account="b@example.com",le="+Inf"} 1
+test_ttfe_sum{provider="zai",model="glm-4.7",account="b@example.com"} 2
+test_ttfe_count{provider="zai",model="glm-4.7",account="b@example.com"} 1
+`
+	if b.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", b.String(), want)
+	}
+	if n := h.Count("zai", "glm-4.7"); n != 2 {
+		t.Errorf("expected 2 observations across accounts, got %d", n)
+	}
+}
@@ -0,0 +1,210 @@
+// Package notify delivers account state change events to Slack, Discord or generic HTTP webhooks.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// EventType identifies an account state change.
+type EventType string
+
+const (
+	EventAccountInvalid     EventType = "account_invalid"
+	EventAccountRateLimited EventType = "account_rate_limited"
+	EventAccountSoftLimited EventType = "account_soft_limited"
+	EventAccountRecovered   EventType = "account_recovered"
+	EventProviderExhausted  EventType = "provider_exhausted"
+)
+
+// Event describes an account state change. Email is empty for provider-wide events.
+type Event struct {
+	Type     EventType `json:"event"`
+	Email    string    `json:"email,omitempty"`
+	Provider string    `json:"provider,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	At       time.Time `json:"timestamp"`
+}
+
+// Message returns the built-in human-readable description of the event.
+func (e Event) Message() string {
+	var msg string
+	switch e.Type {
+	case EventAccountInvalid:
+		msg = fmt.Sprintf("Account %s is invalid and needs re-authentication", e.Email)
+	case EventAccountRateLimited:
+		msg = fmt.Sprintf("Account %s is rate-limited for %s", e.Email, e.Model)
+	case EventAccountSoftLimited:
+		msg = fmt.Sprintf("Account %s is soft-limited for %s", e.Email, e.Model)
+	case EventAccountRecovered:
+		if e.Model != "" {
+			msg = fmt.Sprintf("Account %s recovered for %s", e.Email, e.Model)
+		} else {
+			msg = fmt.Sprintf("Account %s recovered", e.Email)
+		}
+	case EventProviderExhausted:
+		if e.Model != "" {
+			msg = fmt.Sprintf("All %s accounts are exhausted for %s", e.Provider, e.Model)
+		} else {
+			msg = fmt.Sprintf("All %s accounts are exhausted", e.Provider)
+		}
+	default:
+		msg = fmt.Sprintf("%s: %s", e.Type, e.Email)
+	}
+	if e.Reason != "" {
+		msg += " (" + e.Reason + ")"
+	}
+	return msg
+}
+
+type format int
+
+const (
+	formatGeneric format = iota
+	formatSlack
+	formatDiscord
+)
+
+// detectFormat picks the payload shape from the webhook URL.
+func detectFormat(webhookURL string) format {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return formatGeneric
+	}
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "hooks.slack.com":
+		return formatSlack
+	case (host == "discord.com" || host == "discordapp.com") && strings.HasPrefix(u.Path, "/api/webhooks/"):
+		return formatDiscord
+	default:
+		return formatGeneric
+	}
+}
+
+// Notifier sends events to the configured webhooks. A nil Notifier ignores events.
+type Notifier struct {
+	webhooks   []string
+	events     map[EventType]bool // nil sends all events
+	tmpl       *template.Template
+	maxRetries int
+	retryDelay time.Duration
+	client     *http.Client
+}
+
+// New creates a notifier from cfg. It returns nil when no webhooks are configured,
+// and an error when the message template does not parse.
+func New(cfg config.NotifyConfig) (*Notifier, error) {
+	if len(cfg.Webhooks) == 0 {
+		return nil, nil
+	}
+
+	n := &Notifier{
+		webhooks:   cfg.Webhooks,
+		maxRetries: cfg.MaxRetries,
+		retryDelay: cfg.RetryDelay,
+		client:     &http.Client{Timeout: config.NotifyWebhookTimeout},
+	}
+	if len(cfg.Events) > 0 {
+		n.events = make(map[EventType]bool, len(cfg.Events))
+		for _, e := range cfg.Events {
+			n.events[EventType(strings.ToLower(e))] = true
+		}
+	}
+	if cfg.Template != "" {
+		tmpl, err := template.New("notify").Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NOTIFY_TEMPLATE: %w", err)
+		}
+		n.tmpl = tmpl
+	}
+	return n, nil
+}
+
+// Notify delivers an event to every webhook in the background.
+func (n *Notifier) Notify(e Event) {
+	if n == nil || (n.events != nil && !n.events[e.Type]) {
+		return
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+
+	text := n.render(e)
+	for _, webhookURL := range n.webhooks {
+		body, err := payload(detectFormat(webhookURL), e, text)
+		if err != nil {
+			utils.Warn("[Notify] Failed to encode %s event: %v", e.Type, err)
+			return
+		}
+		go n.deliver(webhookURL, e.Type, body)
+	}
+}
+
+// render applies the configured template, falling back to the built-in message on error.
+func (n *Notifier) render(e Event) string {
+	if n.tmpl == nil {
+		return e.Message()
+	}
+	var buf bytes.Buffer
+	if err := n.tmpl.Execute(&buf, e); err != nil {
+		utils.Warn("[Notify] Template failed for %s event: %v", e.Type, err)
+		return e.Message()
+	}
+	return buf.String()
+}
+
+func payload(f format, e Event, text string) ([]byte, error) {
+	switch f {
+	case formatSlack:
+		return json.Marshal(map[string]string{"text": text})
+	case formatDiscord:
+		return json.Marshal(map[string]string{"content": text})
+	default:
+		return json.Marshal(struct {
+			Event
+			Text string `json:"text"`
+		}{e, text})
+	}
+}
+
+// deliver POSTs body, retrying with exponential backoff on network errors, 429 and 5xx.
+func (n *Notifier) deliver(webhookURL string, eventType EventType, body []byte) {
+	delay := n.retryDelay
+	for attempt := 0; ; attempt++ {
+		retryable, err := n.post(webhookURL, body)
+		if err == nil {
+			return
+		}
+		if !retryable || attempt >= n.maxRetries {
+			utils.Warn("[Notify] Failed to deliver %s event after %d attempt(s): %v", eventType, attempt+1, err)
+			return
+		}
+		utils.Debug("[Notify] Delivery of %s event failed (%v), retrying in %s", eventType, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (n *Notifier) post(webhookURL string, body []byte) (retryable bool, err error) {
+	resp, err := n.client.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retryable, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return false, nil
+}
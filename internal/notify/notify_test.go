@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		url  string
+		want format
+	}{
+		{"https://hooks.slack.com/services/T/B/X", formatSlack},
+		{"https://discord.com/api/webhooks/1/abc", formatDiscord},
+		{"https://discordapp.com/api/webhooks/1/abc", formatDiscord},
+		{"https://discord.com/channels/1", formatGeneric},
+		{"https://example.com/hook", formatGeneric},
+	}
+	for _, tt := range tests {
+		if got := detectFormat(tt.url); got != tt.want {
+			t.Errorf("detectFormat(%q) = %d, want %d", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if n, err := New(config.NotifyConfig{}); n != nil || err != nil {
+		t.Errorf("expected nil notifier without webhooks, got %v, %v", n, err)
+	}
+	if _, err := New(config.NotifyConfig{Webhooks: []string{"http://x"}, Template: "{{.Email"}); err == nil {
+		t.Error("expected error for invalid template")
+	}
+
+	var n *Notifier
+	n.Notify(Event{Type: EventAccountInvalid}) // nil notifier must not panic
+}
+
+func TestNotify_GenericPayloadWithTemplate(t *testing.T) {
+	received := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer srv.Close()
+
+	n, err := New(config.NotifyConfig{
+		Webhooks: []string{srv.URL},
+		Template: "{{.Type}} {{.Email}}/{{.Model}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(Event{Type: EventAccountRateLimited, Email: "a@example.com", Provider: "zai", Model: "glm-4.6"})
+
+	select {
+	case body := <-received:
+		var got map[string]string
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("invalid JSON %s: %v", body, err)
+		}
+		if got["event"] != "account_rate_limited" || got["provider"] != "zai" || got["text"] != "account_rate_limited a@example.com/glm-4.6" {
+			t.Errorf("unexpected payload: %s", body)
+		}
+		if got["timestamp"] == "" {
+			t.Errorf("expected timestamp in payload: %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestNotify_EventFilter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	n, _ := New(config.NotifyConfig{Webhooks: []string{srv.URL}, Events: []string{"PROVIDER_EXHAUSTED"}})
+	n.Notify(Event{Type: EventAccountRateLimited, Email: "a@example.com"})
+	if n.events[EventProviderExhausted] != true {
+		t.Fatal("expected event names to be case-insensitive")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if calls.Load() != 0 {
+		t.Errorf("filtered event was delivered")
+	}
+}
+
+func TestDeliver_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	n, _ := New(config.NotifyConfig{Webhooks: []string{srv.URL}, MaxRetries: 3, RetryDelay: time.Millisecond})
+	n.deliver(srv.URL, EventAccountInvalid, []byte(`{}`))
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestDeliver_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	n, _ := New(config.NotifyConfig{Webhooks: []string{srv.URL}, MaxRetries: 3, RetryDelay: time.Millisecond})
+	n.deliver(srv.URL, EventAccountInvalid, []byte(`{}`))
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestPayload_SlackAndDiscord(t *testing.T) {
+	e := Event{Type: EventAccountInvalid, Email: "a@example.com", Reason: "invalid_grant"}
+	slack, _ := payload(formatSlack, e, e.Message())
+	if string(slack) != `{"text":"Account a@example.com is invalid and needs re-authentication (invalid_grant)"}` {
+		t.Errorf("unexpected Slack payload: %s", slack)
+	}
+	discord, _ := payload(formatDiscord, e, "hi")
+	if string(discord) != `{"content":"hi"}` {
+		t.Errorf("unexpected Discord payload: %s", discord)
+	}
+}
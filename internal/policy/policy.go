@@ -0,0 +1,218 @@
+// Package policy runs content policy filters on /v1/messages requests: regular-expression
+// rules and an optional external HTTP filter. Filters run before a request is dispatched and
+// after its response completes, and can reject it, redact matched text or annotate its
+// audit record.
+//
+// It also evaluates declarative request rules (see Rules): expressions over a request's
+// API key, model, size and filter annotations that allow, deny or transform it.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// Stage is the point at which filters run.
+type Stage string
+
+const (
+	StageRequest  Stage = "request"  // Before the request is sent upstream
+	StageResponse Stage = "response" // After the response completed
+)
+
+// Input is what a filter checks. Texts are the request's system prompt, message and tool
+// result text, or the response's text blocks, in order.
+type Input struct {
+	Stage    Stage    `json:"stage"`
+	Provider string   `json:"provider"`
+	Model    string   `json:"model"`
+	User     string   `json:"user,omitempty"` // metadata.user_id, without the session
+	Texts    []string `json:"texts"`
+}
+
+// Decision is a filter's verdict on an Input.
+type Decision struct {
+	Reject      bool     `json:"reject,omitempty"`
+	Reason      string   `json:"reason,omitempty"`      // Returned to the client when rejecting
+	Texts       []string `json:"texts,omitempty"`       // Redacted texts, one per input text; nil keeps them
+	Annotations []string `json:"annotations,omitempty"` // Recorded in the request's audit entry
+}
+
+// Filter checks the text of a request or response.
+type Filter interface {
+	Check(ctx context.Context, in Input) (Decision, error)
+}
+
+// Chain runs filters in order, each seeing the texts as redacted by those before it.
+// A nil Chain allows everything.
+type Chain struct {
+	filters  []Filter
+	failOpen bool
+}
+
+// NewChain creates a chain of filters. With failOpen, a filter that fails is skipped
+// instead of failing the check.
+func NewChain(failOpen bool, filters ...Filter) *Chain {
+	return &Chain{filters: filters, failOpen: failOpen}
+}
+
+// New creates the chain configured by cfg: the regular-expression rules, then the external
+// filter. Returns nil when no filter is configured.
+func New(cfg config.PolicyFilterConfig) (*Chain, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	var filters []Filter
+	if len(cfg.Rules) > 0 {
+		rules, err := NewRegexFilter(cfg.Rules)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, rules)
+	}
+	if cfg.URL != "" {
+		filters = append(filters, NewHTTPFilter(cfg.URL, cfg.Timeout))
+	}
+	return NewChain(cfg.FailOpen, filters...), nil
+}
+
+// Check runs the filters on in and returns the combined decision. Decision.Texts is nil
+// unless a filter changed the text. An error means a filter failed and the chain isn't
+// fail-open; the request should then be refused.
+func (c *Chain) Check(ctx context.Context, in Input) (Decision, error) {
+	var result Decision
+	if c == nil {
+		return result, nil
+	}
+	texts := in.Texts
+	for _, f := range c.filters {
+		in.Texts = texts
+		d, err := f.Check(ctx, in)
+		if err == nil && d.Texts != nil && len(d.Texts) != len(texts) {
+			err = fmt.Errorf("filter returned %d texts for %d", len(d.Texts), len(texts))
+		}
+		if err != nil {
+			if c.failOpen {
+				utils.Warn("[Policy] Filter failed, letting the %s through: %v", in.Stage, err)
+				continue
+			}
+			return result, err
+		}
+		result.Annotations = append(result.Annotations, d.Annotations...)
+		if d.Reject {
+			result.Reject = true
+			result.Reason = d.Reason
+			return result, nil
+		}
+		if d.Texts != nil {
+			texts = d.Texts
+			result.Texts = texts
+		}
+	}
+	return result, nil
+}
+
+// RegexFilter applies regular-expression rules in order.
+type RegexFilter struct {
+	rules []regexRule
+}
+
+type regexRule struct {
+	config.PolicyRule
+	re *regexp.Regexp
+}
+
+// NewRegexFilter compiles rules.
+func NewRegexFilter(rules []config.PolicyRule) (*RegexFilter, error) {
+	f := &RegexFilter{rules: make([]regexRule, 0, len(rules))}
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %q: %w", rule.Name, err)
+		}
+		f.rules = append(f.rules, regexRule{PolicyRule: rule, re: re})
+	}
+	return f, nil
+}
+
+// Check implements Filter. A matching reject rule stops at once; redact rules replace
+// matches (the replacement may refer to groups as $1) and annotate rules add their name.
+func (f *RegexFilter) Check(_ context.Context, in Input) (Decision, error) {
+	var d Decision
+	texts := in.Texts
+	for _, rule := range f.rules {
+		if rule.Stage != "" && Stage(rule.Stage) != in.Stage {
+			continue
+		}
+		matched := false
+		for i, text := range texts {
+			if !rule.re.MatchString(text) {
+				continue
+			}
+			matched = true
+			if rule.Action != config.PolicyActionRedact {
+				break
+			}
+			if d.Texts == nil {
+				texts = append([]string(nil), texts...)
+				d.Texts = texts
+			}
+			texts[i] = rule.re.ReplaceAllString(text, rule.Replacement)
+		}
+		if !matched {
+			continue
+		}
+		d.Annotations = append(d.Annotations, rule.Name)
+		if rule.Action == config.PolicyActionReject {
+			d.Reject = true
+			d.Reason = rule.Reason
+			return d, nil
+		}
+	}
+	return d, nil
+}
+
+// HTTPFilter posts each Input as JSON to an external service, which answers with a Decision.
+type HTTPFilter struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPFilter creates a filter calling url with the given per-call timeout.
+func NewHTTPFilter(url string, timeout time.Duration) *HTTPFilter {
+	return &HTTPFilter{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Check implements Filter.
+func (f *HTTPFilter) Check(ctx context.Context, in Input) (Decision, error) {
+	var d Decision
+	body, err := json.Marshal(in)
+	if err != nil {
+		return d, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(body))
+	if err != nil {
+		return d, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return d, fmt.Errorf("policy filter request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return d, fmt.Errorf("policy filter returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return d, fmt.Errorf("invalid policy filter response: %w", err)
+	}
+	return d, nil
+}
@@ -0,0 +1,178 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func newRegexFilter(t *testing.T, rules ...config.PolicyRule) *RegexFilter {
+	t.Helper()
+	f, err := NewRegexFilter(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestRegexFilter(t *testing.T) {
+	f := newRegexFilter(t,
+		config.PolicyRule{Name: "key", Pattern: `sk-[a-z0-9]+`, Action: config.PolicyActionRedact, Replacement: "[KEY]"},
+		config.PolicyRule{Name: "pii", Pattern: `\d{3}-\d{2}-\d{4}`, Action: config.PolicyActionAnnotate},
+		config.PolicyRule{Name: "secret", Pattern: `(?i)top secret`, Action: config.PolicyActionReject, Stage: "request", Reason: "no secrets"},
+	)
+	ctx := context.Background()
+
+	d, err := f.Check(ctx, Input{Stage: StageRequest, Texts: []string{"use sk-abc123", "ssn 123-45-6789"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Reject || !reflect.DeepEqual(d.Texts, []string{"use [KEY]", "ssn 123-45-6789"}) {
+		t.Errorf("expected the key to be redacted, got %+v", d)
+	}
+	if !reflect.DeepEqual(d.Annotations, []string{"key", "pii"}) {
+		t.Errorf("annotations = %v", d.Annotations)
+	}
+
+	d, _ = f.Check(ctx, Input{Stage: StageRequest, Texts: []string{"this is TOP SECRET"}})
+	if !d.Reject || d.Reason != "no secrets" {
+		t.Errorf("expected a rejection, got %+v", d)
+	}
+	d, _ = f.Check(ctx, Input{Stage: StageResponse, Texts: []string{"this is TOP SECRET"}})
+	if d.Reject || d.Texts != nil {
+		t.Errorf("request-only rule applied to a response: %+v", d)
+	}
+}
+
+type funcFilter func(Input) (Decision, error)
+
+func (f funcFilter) Check(_ context.Context, in Input) (Decision, error) { return f(in) }
+
+func TestChain(t *testing.T) {
+	redact := newRegexFilter(t, config.PolicyRule{Name: "key", Pattern: `sk-\w+`, Action: config.PolicyActionRedact, Replacement: "***"})
+	var seen []string
+	record := funcFilter(func(in Input) (Decision, error) {
+		seen = in.Texts
+		return Decision{Annotations: []string{"seen"}}, nil
+	})
+	failing := funcFilter(func(Input) (Decision, error) { return Decision{}, errors.New("down") })
+
+	d, err := NewChain(false, redact, record).Check(context.Background(), Input{Texts: []string{"sk-abc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(seen, []string{"***"}) {
+		t.Errorf("later filter saw %v, want the redacted text", seen)
+	}
+	if !reflect.DeepEqual(d.Texts, []string{"***"}) || !reflect.DeepEqual(d.Annotations, []string{"key", "seen"}) {
+		t.Errorf("unexpected decision: %+v", d)
+	}
+
+	if _, err := NewChain(false, failing).Check(context.Background(), Input{}); err == nil {
+		t.Error("expected a failing filter to fail a closed chain")
+	}
+	if _, err := NewChain(true, failing, record).Check(context.Background(), Input{Stage: StageRequest}); err != nil {
+		t.Errorf("expected a fail-open chain to skip the failing filter, got %v", err)
+	}
+
+	wrongLength := funcFilter(func(Input) (Decision, error) { return Decision{Texts: []string{"a", "b"}}, nil })
+	if _, err := NewChain(false, wrongLength).Check(context.Background(), Input{Texts: []string{"a"}}); err == nil {
+		t.Error("expected an error for a mismatched number of texts")
+	}
+
+	var nilChain *Chain
+	if d, err := nilChain.Check(context.Background(), Input{Texts: []string{"x"}}); err != nil || d.Reject {
+		t.Errorf("nil chain should allow everything, got %+v, %v", d, err)
+	}
+}
+
+func TestHTTPFilter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in Input
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if in.Stage != StageRequest || in.Model != "glm-4.7" {
+			http.Error(w, "unexpected input", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Decision{Reject: true, Reason: "blocked", Annotations: []string{"external"}})
+	}))
+	defer srv.Close()
+
+	d, err := NewHTTPFilter(srv.URL, time.Second).Check(context.Background(), Input{Stage: StageRequest, Model: "glm-4.7", Texts: []string{"hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Reject || d.Reason != "blocked" || !reflect.DeepEqual(d.Annotations, []string{"external"}) {
+		t.Errorf("unexpected decision: %+v", d)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if _, err := NewHTTPFilter(failing.URL, time.Second).Check(context.Background(), Input{}); err == nil {
+		t.Error("expected an error for a failing filter service")
+	}
+}
+
+func TestRequestTexts(t *testing.T) {
+	req := &types.AnthropicRequest{
+		System: json.RawMessage(`[{"type":"text","text":"sys sk-1","cache_control":{"type":"ephemeral"}}]`),
+		Messages: []types.Message{
+			{Role: "user", Content: json.RawMessage(`"hello sk-2"`)},
+			{Role: "assistant", Content: json.RawMessage(`[{"type":"thinking","thinking":"sk-x","signature":"s"},{"type":"tool_use","id":"t1","name":"read","input":{}}]`)},
+			{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"text","text":"file sk-3"}]}]`)},
+		},
+	}
+	original := req.Messages
+
+	texts := RequestTexts(req)
+	if !reflect.DeepEqual(texts, []string{"sys sk-1", "hello sk-2", "file sk-3"}) {
+		t.Fatalf("texts = %q", texts)
+	}
+
+	SetRequestTexts(req, []string{"sys ***", "hello sk-2", "file ***"})
+	if got := RequestTexts(req); !reflect.DeepEqual(got, []string{"sys ***", "hello sk-2", "file ***"}) {
+		t.Errorf("after SetRequestTexts: %q", got)
+	}
+	var system []map[string]interface{}
+	if err := json.Unmarshal(req.System, &system); err != nil || system[0]["cache_control"] == nil {
+		t.Errorf("system block lost its fields: %s", req.System)
+	}
+	if string(req.Messages[1].Content) != string(original[1].Content) {
+		t.Errorf("unchanged message was re-encoded: %s", req.Messages[1].Content)
+	}
+	if string(original[2].Content) == string(req.Messages[2].Content) {
+		t.Error("expected the redacted message to change")
+	}
+	if RequestTexts(&types.AnthropicRequest{Messages: original})[1] != "file sk-3" {
+		t.Error("SetRequestTexts modified the caller's messages")
+	}
+}
+
+func TestResponseTexts(t *testing.T) {
+	resp := &types.AnthropicResponse{Content: []types.ContentBlock{
+		{Type: "thinking", Thinking: "hmm"},
+		{Type: "text", Text: "key sk-1"},
+		{Type: "tool_use", Name: "read"},
+		{Type: "text", Text: "done"},
+	}}
+	if texts := ResponseTexts(resp); !reflect.DeepEqual(texts, []string{"key sk-1", "done"}) {
+		t.Fatalf("texts = %q", texts)
+	}
+	SetResponseTexts(resp, []string{"key ***", "done"})
+	if resp.Content[1].Text != "key ***" || resp.Content[0].Thinking != "hmm" {
+		t.Errorf("unexpected content: %+v", resp.Content)
+	}
+}
@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/expr"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// RuleInput is what request rule expressions can see, one variable per field.
+type RuleInput struct {
+	APIKey          string   // api_key: fingerprint of the client's proxy API key, empty without one
+	Model           string   // model: as requested, e.g. anthropic/claude-sonnet-4-5
+	Provider        string   // provider
+	User            string   // user: metadata.user_id, without the session
+	EstimatedTokens int      // estimated_tokens: input text characters / 4
+	MaxTokens       int      // max_tokens
+	Stream          bool     // stream
+	Messages        int      // messages: number of messages
+	Images          int      // images: number of image blocks
+	Tools           int      // tools: number of tool definitions
+	Flags           []string // flags: annotations of the content policy filters
+}
+
+// ruleVars are the variable names of RuleInput.
+var ruleVars = []string{"api_key", "model", "provider", "user", "estimated_tokens", "max_tokens", "stream", "messages", "images", "tools", "flags"}
+
+func (in RuleInput) vars() map[string]any {
+	flags := in.Flags
+	if flags == nil {
+		flags = []string{}
+	}
+	return map[string]any{
+		"api_key":          in.APIKey,
+		"model":            in.Model,
+		"provider":         in.Provider,
+		"user":             in.User,
+		"estimated_tokens": in.EstimatedTokens,
+		"max_tokens":       in.MaxTokens,
+		"stream":           in.Stream,
+		"messages":         in.Messages,
+		"images":           in.Images,
+		"tools":            in.Tools,
+		"flags":            flags,
+	}
+}
+
+// RuleDecision is the outcome of the request rules.
+type RuleDecision struct {
+	Deny      bool
+	Reason    string   // Returned to the client when denying
+	Rule      string   // Name of the rule that allowed or denied the request
+	Model     string   // Model to route to instead; empty keeps it
+	MaxTokens int      // max_tokens to use instead; 0 keeps it
+	Matched   []string // Names of the rules that matched, in order
+}
+
+// Rules evaluates declarative request rules. A nil Rules allows everything.
+type Rules struct {
+	rules []rule
+}
+
+type rule struct {
+	config.RequestRule
+	when *expr.Program
+}
+
+// NewRules compiles rules. Returns nil when there are none.
+func NewRules(rules []config.RequestRule) (*Rules, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	r := &Rules{}
+	for _, cfg := range rules {
+		when, err := expr.Compile(cfg.When, ruleVars)
+		if err != nil {
+			return nil, fmt.Errorf("request rule %q: %w", cfg.Name, err)
+		}
+		r.rules = append(r.rules, rule{RequestRule: cfg, when: when})
+	}
+	return r, nil
+}
+
+// Len returns the number of rules.
+func (r *Rules) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+// Evaluate runs the rules in order. The first matching allow or deny rule decides; matching
+// transform rules apply their changes, each seeing the request as changed by those before
+// it, and evaluation continues. A rule whose expression fails is logged and skipped.
+func (r *Rules) Evaluate(in RuleInput) RuleDecision {
+	var d RuleDecision
+	if r == nil {
+		return d
+	}
+	for _, rule := range r.rules {
+		match, err := rule.when.EvalBool(in.vars())
+		if err != nil {
+			utils.Warn("[Policy] Request rule %q failed, skipping it: %v", rule.Name, err)
+			continue
+		}
+		if !match {
+			continue
+		}
+		d.Matched = append(d.Matched, rule.Name)
+		switch rule.Action {
+		case config.RequestRuleAllow:
+			d.Rule = rule.Name
+			return d
+		case config.RequestRuleDeny:
+			d.Deny, d.Reason, d.Rule = true, rule.Reason, rule.Name
+			return d
+		case config.RequestRuleTransform:
+			if rule.Set.Model != "" {
+				d.Model, in.Model = rule.Set.Model, rule.Set.Model
+			}
+			if rule.Set.MaxTokens > 0 && in.MaxTokens > rule.Set.MaxTokens {
+				d.MaxTokens, in.MaxTokens = rule.Set.MaxTokens, rule.Set.MaxTokens
+			}
+		}
+	}
+	return d
+}
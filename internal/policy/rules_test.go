@@ -0,0 +1,53 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+func TestRules(t *testing.T) {
+	rules, err := NewRules([]config.RequestRule{
+		{Name: "cap", When: `max_tokens > 8000`, Action: config.RequestRuleTransform, Set: config.RequestRuleChanges{MaxTokens: 8000}},
+		{Name: "broken", When: `flags[5] == "x"`, Action: config.RequestRuleDeny},
+		{Name: "trusted", When: `api_key.startsWith("ab")`, Action: config.RequestRuleAllow},
+		{Name: "opus", When: `model.contains("opus") && estimated_tokens > 50000`, Action: config.RequestRuleTransform,
+			Set: config.RequestRuleChanges{Model: "anthropic/claude-sonnet-4-5"}},
+		{Name: "sonnet-stream", When: `model.contains("sonnet") && stream && max_tokens >= 8000`, Action: config.RequestRuleDeny, Reason: "no"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Len() != 5 {
+		t.Fatalf("Len = %d", rules.Len())
+	}
+
+	in := RuleInput{APIKey: "ff00", Model: "anthropic/claude-opus-4", EstimatedTokens: 60000, MaxTokens: 32000, Stream: true}
+	d := rules.Evaluate(in)
+	// Later rules see the changes of earlier transforms.
+	want := RuleDecision{Deny: true, Reason: "no", Rule: "sonnet-stream", Model: "anthropic/claude-sonnet-4-5", MaxTokens: 8000,
+		Matched: []string{"cap", "opus", "sonnet-stream"}}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("Evaluate = %+v, want %+v", d, want)
+	}
+
+	in.APIKey = "ab12"
+	d = rules.Evaluate(in)
+	want = RuleDecision{Rule: "trusted", MaxTokens: 8000, Matched: []string{"cap", "trusted"}}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("Evaluate = %+v, want %+v", d, want)
+	}
+
+	var none *Rules
+	if d := none.Evaluate(in); d.Deny || d.Model != "" {
+		t.Errorf("nil rules should allow, got %+v", d)
+	}
+}
+
+func TestNewRules_InvalidExpression(t *testing.T) {
+	_, err := NewRules([]config.RequestRule{{Name: "typo", When: `modl == "x"`, Action: config.RequestRuleDeny}})
+	if err == nil {
+		t.Fatal("expected an error for an unknown variable")
+	}
+}
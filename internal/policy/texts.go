@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// RequestTexts returns the text a filter checks in req: the system prompt, then the text
+// of each message and its tool results, in order.
+func RequestTexts(req *types.AnthropicRequest) []string {
+	var texts []string
+	walkRequest(req, func(s string) string {
+		texts = append(texts, s)
+		return s
+	})
+	return texts
+}
+
+// SetRequestTexts replaces the texts RequestTexts returned with texts, e.g. after redaction.
+// Messages are copied before they change, so other copies of req are left alone.
+func SetRequestTexts(req *types.AnthropicRequest, texts []string) {
+	i := 0
+	walkRequest(req, func(s string) string {
+		if i >= len(texts) {
+			return s
+		}
+		i++
+		return texts[i-1]
+	})
+}
+
+// ResponseTexts returns the text blocks of resp.
+func ResponseTexts(resp *types.AnthropicResponse) []string {
+	var texts []string
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			texts = append(texts, block.Text)
+		}
+	}
+	return texts
+}
+
+// SetResponseTexts replaces the texts ResponseTexts returned with texts.
+func SetResponseTexts(resp *types.AnthropicResponse, texts []string) {
+	i := 0
+	for j := range resp.Content {
+		if resp.Content[j].Type == "text" && i < len(texts) {
+			resp.Content[j].Text = texts[i]
+			i++
+		}
+	}
+}
+
+// walkRequest calls fn with each text of req and stores what it returns. Content is only
+// re-encoded where a text changed.
+func walkRequest(req *types.AnthropicRequest, fn func(string) string) {
+	if system, changed := walkContent(req.System, fn); changed {
+		req.System = system
+	}
+	copied := false
+	for i, msg := range req.Messages {
+		content, changed := walkContent(msg.Content, fn)
+		if !changed {
+			continue
+		}
+		if !copied {
+			req.Messages = slices.Clone(req.Messages)
+			copied = true
+		}
+		req.Messages[i].Content = content
+	}
+}
+
+// walkContent walks a content value that is either a string or an array of blocks, visiting
+// text blocks and the content of tool_result blocks. Blocks are decoded generically so fields
+// the proxy doesn't model (e.g. cache_control) survive re-encoding.
+func walkContent(raw json.RawMessage, fn func(string) string) (json.RawMessage, bool) {
+	if len(raw) == 0 {
+		return raw, false
+	}
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		out := fn(str)
+		if out == str {
+			return raw, false
+		}
+		data, err := json.Marshal(out)
+		return data, err == nil
+	}
+
+	var blocks []map[string]json.RawMessage
+	if json.Unmarshal(raw, &blocks) != nil {
+		return raw, false
+	}
+	changed := false
+	for _, block := range blocks {
+		var blockType string
+		_ = json.Unmarshal(block["type"], &blockType)
+		switch blockType {
+		case "text":
+			var text string
+			if json.Unmarshal(block["text"], &text) != nil {
+				continue
+			}
+			if out := fn(text); out != text {
+				if data, err := json.Marshal(out); err == nil {
+					block["text"] = data
+					changed = true
+				}
+			}
+		case "tool_result":
+			if content, ok := walkContent(block["content"], fn); ok {
+				block["content"] = content
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return raw, false
+	}
+	data, err := json.Marshal(blocks)
+	return data, err == nil
+}
@@ -0,0 +1,264 @@
+// Package anthropic implements a provider that forwards requests to the Anthropic API
+// using per-account API keys.
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/capture"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/egress"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/tracing"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// Client handles HTTP communication with the Anthropic API.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewClient creates a new Anthropic API client.
+func NewClient() *Client {
+	return &Client{
+		httpClient: &http.Client{
+			Timeout:   config.AnthropicTimeout,
+			Transport: provider.ConnectTimeoutTransport(providerName, tracing.Transport(capture.Transport(egress.Transport()))),
+		},
+		baseURL: config.GetAnthropicBaseURL(),
+	}
+}
+
+// ModelsResponse represents the response from the /v1/models endpoint.
+type ModelsResponse struct {
+	Data    []ModelEntry `json:"data"`
+	HasMore bool         `json:"has_more"`
+}
+
+// ModelEntry represents a single model in the models response.
+type ModelEntry struct {
+	ID          string  `json:"id"`
+	DisplayName string  `json:"display_name"`
+	CreatedAt   *string `json:"created_at"`
+	Type        string  `json:"type"`
+}
+
+// FetchModels fetches the models available to an API key.
+func (c *Client) FetchModels(ctx context.Context, apiKey string) ([]ModelEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+config.AnthropicModelsPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	setHeaders(req, apiKey)
+
+	config.SetProviderHeaders(req, "anthropic")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleErrorResponse(resp)
+	}
+
+	var modelsResp ModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	utils.Debug("[Anthropic] Fetched %d models", len(modelsResp.Data))
+	return modelsResp.Data, nil
+}
+
+// SendMessage sends a non-streaming message request.
+func (c *Client) SendMessage(ctx context.Context, apiKey string, anthropicReq *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	reqCopy := *anthropicReq
+	reqCopy.Stream = false
+
+	req, err := c.newMessagesRequest(ctx, apiKey, &reqCopy)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	config.SetProviderHeaders(req, "anthropic")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	provider.TraceFromContext(ctx).RecordRateLimit(resp.Header)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleErrorResponse(resp)
+	}
+
+	var anthropicResp types.AnthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&anthropicResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &anthropicResp, nil
+}
+
+// SendMessageStream sends a streaming message request and returns the SSE body.
+// The caller must close the returned reader.
+func (c *Client) SendMessageStream(ctx context.Context, apiKey string, anthropicReq *types.AnthropicRequest) (io.ReadCloser, error) {
+	reqCopy := *anthropicReq
+	reqCopy.Stream = true
+
+	req, err := c.newMessagesRequest(ctx, apiKey, &reqCopy)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// Use a client without timeout for streaming
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+
+	config.SetProviderHeaders(req, "anthropic")
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	provider.TraceFromContext(ctx).RecordRateLimit(resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, c.handleErrorResponse(resp)
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) newMessagesRequest(ctx context.Context, apiKey string, anthropicReq *types.AnthropicRequest) (*http.Request, error) {
+	body, err := json.Marshal(struct {
+		*types.AnthropicRequest
+		ServiceTier string `json:"service_tier,omitempty"`
+	}{anthropicReq, upstreamServiceTier(anthropicReq.ServiceTier)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := c.baseURL + "/v1/messages"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	setHeaders(req, apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	utils.Debug("[Anthropic] Sending request to %s (stream=%v)", url, anthropicReq.Stream)
+	return req, nil
+}
+
+// upstreamServiceTier maps a request's service_tier to the Messages API's. The API has no
+// "priority" value: "auto" is what lets it use an account's priority capacity.
+func upstreamServiceTier(tier string) string {
+	if tier == "priority" {
+		return "auto"
+	}
+	return tier
+}
+
+func setHeaders(req *http.Request, apiKey string) {
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("anthropic-version", config.AnthropicVersion)
+}
+
+// handleErrorResponse processes an error response from the API.
+func (c *Client) handleErrorResponse(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("authentication_error: %s", string(body)),
+		}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return &RateLimitError{
+			ResetMs: parseRetryAfter(resp.Header, time.Now()),
+			Message: fmt.Sprintf("rate_limit_error: %s", string(body)),
+		}
+	case resp.StatusCode >= 500:
+		// Includes 529 overloaded_error.
+		return &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("server_error: status %d, body: %s", resp.StatusCode, string(body)),
+		}
+	default:
+		return &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("api_error: status %d, body: %s", resp.StatusCode, string(body)),
+		}
+	}
+}
+
+// parseRetryAfter returns the cooldown in milliseconds from a 429 response.
+// It prefers retry-after (seconds), then the earliest anthropic-ratelimit-*-reset timestamp,
+// falling back to the default rate limit reset.
+func parseRetryAfter(h http.Header, now time.Time) int64 {
+	if v := h.Get("retry-after"); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+			return int64(secs * 1000)
+		}
+	}
+
+	var resetMs int64
+	for _, name := range []string{
+		"anthropic-ratelimit-requests-reset",
+		"anthropic-ratelimit-input-tokens-reset",
+		"anthropic-ratelimit-output-tokens-reset",
+		"anthropic-ratelimit-tokens-reset",
+	} {
+		t, err := time.Parse(time.RFC3339, h.Get(name))
+		if err != nil {
+			continue
+		}
+		if ms := t.Sub(now).Milliseconds(); ms > 0 && (resetMs == 0 || ms < resetMs) {
+			resetMs = ms
+		}
+	}
+	if resetMs > 0 {
+		return resetMs
+	}
+	return config.DefaultRateLimitResetMs
+}
+
+// HTTPStatusError represents an HTTP error with status code.
+type HTTPStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return e.Message
+}
+
+// RateLimitError represents a rate limit error.
+type RateLimitError struct {
+	ResetMs int64
+	Message string
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Message
+}
+
+// VerifyAPIKey verifies that an API key is valid by calling the models endpoint.
+func (c *Client) VerifyAPIKey(ctx context.Context, apiKey string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	_, err := c.FetchModels(ctx, apiKey)
+	return err
+}
@@ -0,0 +1,137 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestClient_SendMessage_Headers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("x-api-key"); got != "sk-ant-test" {
+			t.Errorf("x-api-key = %q", got)
+		}
+		if got := r.Header.Get("anthropic-version"); got != config.AnthropicVersion {
+			t.Errorf("anthropic-version = %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient()
+	c.baseURL = server.URL
+
+	resp, err := c.SendMessage(context.Background(), "sk-ant-test", &types.AnthropicRequest{Model: "claude-test", Stream: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ID != "msg_1" || len(resp.Content) != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestClient_SendMessage_ServiceTier(t *testing.T) {
+	tests := []struct{ tier, want string }{
+		{"", ""},
+		{"auto", "auto"},
+		{"standard_only", "standard_only"},
+		{"priority", "auto"},
+	}
+	for _, tt := range tests {
+		var got map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Error(err)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[],"stop_reason":"end_turn"}`))
+		}))
+		c := NewClient()
+		c.baseURL = server.URL
+		_, err := c.SendMessage(context.Background(), "sk-ant-test", &types.AnthropicRequest{Model: "claude-test", MaxTokens: 16, ServiceTier: tt.tier})
+		server.Close()
+		if err != nil {
+			t.Fatalf("tier %q: unexpected error: %v", tt.tier, err)
+		}
+		tier, sent := got["service_tier"]
+		if tt.want == "" && sent || tt.want != "" && tier != tt.want {
+			t.Errorf("tier %q: sent service_tier %v, want %q", tt.tier, tier, tt.want)
+		}
+		if got["model"] != "claude-test" || got["max_tokens"] != float64(16) {
+			t.Errorf("tier %q: expected the rest of the request sent as is, got %v", tt.tier, got)
+		}
+	}
+}
+
+func TestClient_ErrorMapping(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		headers    map[string]string
+		wantStatus int
+		wantReset  int64
+	}{
+		{name: "unauthorized", status: 401, wantStatus: 401},
+		{name: "overloaded", status: 529, wantStatus: 529},
+		{name: "bad request", status: 400, wantStatus: 400},
+		{name: "rate limit retry-after", status: 429, headers: map[string]string{"retry-after": "7"}, wantReset: 7000},
+		{name: "rate limit default", status: 429, wantReset: config.DefaultRateLimitResetMs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"type":"error","error":{"type":"x","message":"y"}}`))
+			}))
+			defer server.Close()
+
+			c := NewClient()
+			c.baseURL = server.URL
+			_, err := c.SendMessage(context.Background(), "key", &types.AnthropicRequest{Model: "m"})
+
+			if tt.wantReset > 0 {
+				var rlErr *RateLimitError
+				if !errors.As(err, &rlErr) {
+					t.Fatalf("expected RateLimitError, got %v", err)
+				}
+				if rlErr.ResetMs != tt.wantReset {
+					t.Errorf("ResetMs = %d, want %d", rlErr.ResetMs, tt.wantReset)
+				}
+				return
+			}
+			var httpErr *HTTPStatusError
+			if !errors.As(err, &httpErr) {
+				t.Fatalf("expected HTTPStatusError, got %v", err)
+			}
+			if httpErr.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", httpErr.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter_ResetHeaders(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set("anthropic-ratelimit-requests-reset", now.Add(30*time.Second).Format(time.RFC3339))
+	h.Set("anthropic-ratelimit-tokens-reset", now.Add(10*time.Second).Format(time.RFC3339))
+
+	if got := parseRetryAfter(h, now); got != 10000 {
+		t.Errorf("parseRetryAfter = %d, want 10000", got)
+	}
+}
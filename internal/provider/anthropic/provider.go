@@ -0,0 +1,322 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+const providerName = "anthropic"
+
+// Provider forwards requests to the Anthropic API, rotating across API key accounts.
+type Provider struct {
+	accountManager *account.Manager
+	client         *Client
+	rotation       *provider.Rotation
+	passthrough    bool         // Forward stream events without decoding them (STREAM_FAST_PATH_ENABLED)
+	models         []string     // Model IDs for backwards compatibility
+	modelEntries   []ModelEntry // Full model entries with display_name and created_at
+	modelSet       map[string]bool
+	initFailures   map[string]string // account email -> model fetch error from Initialize
+	modelsMu       sync.RWMutex
+}
+
+// NewProvider creates a new Anthropic provider.
+func NewProvider(accountManager *account.Manager) *Provider {
+	p := &Provider{
+		accountManager: accountManager,
+		client:         NewClient(),
+		passthrough:    config.GetStreamFastPathEnabled(),
+		models:         []string{},
+		modelEntries:   []ModelEntry{},
+		modelSet:       make(map[string]bool),
+	}
+	p.rotation = &provider.Rotation{
+		Provider: providerName,
+		Name:     "Anthropic",
+		Accounts: accountManager,
+		Policy:   config.GetRetryPolicy(providerName),
+		Classify: classifyError,
+		Reject:   p.rejectAccount,
+	}
+	return p
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return providerName
+}
+
+// Models returns the list of model IDs this provider supports.
+func (p *Provider) Models() []string {
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	result := make([]string, len(p.models))
+	copy(result, p.models)
+	return result
+}
+
+// SupportsModel returns true if this provider handles the given model.
+func (p *Provider) SupportsModel(model string) bool {
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	return p.modelSet[model]
+}
+
+// Initialize performs any setup required by the provider.
+// Models are fetched from all valid accounts in parallel; the first success wins.
+func (p *Provider) Initialize(ctx context.Context) error {
+	accounts := p.accountManager.GetAllAccountsByProvider(providerName)
+	if len(accounts) == 0 {
+		utils.Debug("[Anthropic] No Anthropic accounts configured, skipping initialization")
+		return nil
+	}
+
+	byEmail := make(map[string]account.Account, len(accounts))
+	emails := make([]string, 0, len(accounts))
+	for _, acc := range accounts {
+		if acc.IsInvalid {
+			continue
+		}
+		if acc.APIKey == "" {
+			continue
+		}
+		byEmail[acc.Email] = acc
+		emails = append(emails, acc.Email)
+	}
+
+	modelEntries, _, failures, err := provider.FetchFirst(ctx, config.ModelPrefetchTimeout, emails,
+		func(ctx context.Context, email string) ([]ModelEntry, error) {
+			acc := byEmail[email]
+			return p.client.FetchModels(acc.RequestContext(ctx), acc.APIKey)
+		})
+
+	for email, failErr := range failures {
+		utils.Warn("[Anthropic] Failed to fetch models using account %s: %v", email, failErr)
+	}
+	p.modelsMu.Lock()
+	p.initFailures = provider.FailureMessages(failures)
+	p.modelsMu.Unlock()
+
+	if err != nil {
+		utils.Warn("[Anthropic] No valid Anthropic accounts available to fetch models")
+		return nil
+	}
+
+	p.modelsMu.Lock()
+	p.modelEntries = modelEntries
+	p.models = make([]string, len(modelEntries))
+	p.modelSet = make(map[string]bool, len(modelEntries))
+	for i, m := range modelEntries {
+		p.models[i] = m.ID
+		p.modelSet[m.ID] = true
+	}
+	p.modelsMu.Unlock()
+
+	utils.Success("[Anthropic] Provider initialized with %d models", len(modelEntries))
+	return nil
+}
+
+// InitFailures returns the accounts that failed to fetch models during Initialize.
+func (p *Provider) InitFailures() map[string]string {
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	result := make(map[string]string, len(p.initFailures))
+	for k, v := range p.initFailures {
+		result[k] = v
+	}
+	return result
+}
+
+// Shutdown performs cleanup when the provider is being stopped.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	utils.Debug("[Anthropic] Provider shutting down")
+	return nil
+}
+
+// SendMessage handles non-streaming requests.
+func (p *Provider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	var resp *types.AnthropicResponse
+	err := p.rotation.Run(ctx, req.Model, func(at *provider.Attempt) error {
+		acc := at.Account
+		if acc.APIKey == "" {
+			return provider.ErrNoAPIKey
+		}
+		at.Begin(ctx)
+		r, err := p.client.SendMessage(acc.RequestContext(ctx), acc.APIKey, req)
+		if err != nil {
+			return err
+		}
+		at.End(nil, len(r.Content) == 0)
+		resp = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SendMessageStream handles streaming requests.
+func (p *Provider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
+	var stream <-chan types.StreamEvent
+	err := p.rotation.Run(ctx, req.Model, func(at *provider.Attempt) error {
+		acc := at.Account
+		if acc.APIKey == "" {
+			return provider.ErrNoAPIKey
+		}
+		at.Begin(ctx)
+		reader, err := p.client.SendMessageStream(acc.RequestContext(ctx), acc.APIKey, req)
+		if err != nil {
+			return err
+		}
+
+		// On the fast path (STREAM_FAST_PATH_ENABLED) events are forwarded as sent, without
+		// decoding them.
+		parser := provider.NewSSEParser(reader)
+		if p.passthrough {
+			parser = provider.NewPassthroughParser(reader)
+		}
+		events, done := parser.StreamEvents()
+		relay := provider.StreamRelay{
+			Name:    p.rotation.Name,
+			Tracker: account.NewStreamTracker(acc.Email, providerName, req.Model, at.Start()),
+			Report:  p.accountManager.ReportResult,
+		}
+		at.Detach()
+		stream = relay.Start(ctx, events, done)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// classifyError describes an Anthropic API error for account selection.
+func classifyError(err error) provider.Failure {
+	var rateLimitErr *RateLimitError
+	var httpErr *HTTPStatusError
+	switch {
+	case errors.As(err, &rateLimitErr):
+		return provider.Failure{StatusCode: 429, RateLimited: true, ResetMs: rateLimitErr.ResetMs}
+	case errors.As(err, &httpErr):
+		return provider.Failure{StatusCode: httpErr.StatusCode}
+	}
+	return provider.Failure{}
+}
+
+// rejectAccount marks an account invalid when the API rejects its key.
+func (p *Provider) rejectAccount(acc *account.Account, status int) bool {
+	// 403 is a permission_error (e.g. model access), not a bad key.
+	if status != 401 {
+		return false
+	}
+	p.accountManager.MarkInvalid(acc.Email, "invalid API key")
+	utils.Warn("[Anthropic] Account %s has invalid API key, trying next...", acc.Email)
+	return true
+}
+
+// ListModels returns available models with metadata.
+func (p *Provider) ListModels(ctx context.Context) (*types.ModelsResponse, error) {
+	p.modelsMu.RLock()
+	models := make([]types.Model, len(p.modelEntries))
+	for i, m := range p.modelEntries {
+		models[i] = types.Model{
+			ID:          m.ID,
+			DisplayName: m.DisplayName,
+			Type:        m.Type,
+			CreatedAt:   m.CreatedAt,
+		}
+		// Fallback if display_name is empty
+		if models[i].DisplayName == "" {
+			models[i].DisplayName = m.ID
+		}
+		// Fallback if type is empty
+		if models[i].Type == "" {
+			models[i].Type = "model"
+		}
+	}
+	p.modelsMu.RUnlock()
+
+	return &types.ModelsResponse{
+		Data: models,
+	}, nil
+}
+
+// GetStatus returns provider health and rate limit information.
+// The Anthropic API has no quota endpoint, so limits reflect the proxy's own rate limit tracking.
+func (p *Provider) GetStatus(ctx context.Context) (*types.ProviderStatus, error) {
+	accounts := p.accountManager.GetAllAccountsByProvider(providerName)
+	accountStatuses := make([]types.AccountStatus, len(accounts))
+
+	overallStatus := "ok"
+
+	for i, acc := range accounts {
+		status := types.AccountStatus{
+			Email:    acc.Email,
+			Status:   "ok",
+			LastUsed: acc.LastUsed,
+			Limits:   make(map[string]types.ModelQuota),
+		}
+
+		if acc.IsInvalid {
+			status.Status = "invalid"
+			status.Error = string(acc.InvalidReason)
+			overallStatus = "degraded"
+			accountStatuses[i] = status
+			continue
+		}
+
+		if acc.APIKey == "" {
+			status.Status = "error"
+			status.Error = "no API key"
+			overallStatus = "degraded"
+			accountStatuses[i] = status
+			continue
+		}
+
+		p.modelsMu.RLock()
+		for _, modelID := range p.models {
+			if limit, ok := acc.ModelRateLimits[modelID]; ok && limit.IsRateLimited {
+				status.Limits[modelID] = types.ModelQuota{
+					RemainingFraction:   0,
+					RemainingPercentage: 0,
+				}
+				status.Status = "rate-limited"
+			} else {
+				status.Limits[modelID] = types.ModelQuota{
+					RemainingFraction:   1.0,
+					RemainingPercentage: 100,
+				}
+			}
+		}
+		p.modelsMu.RUnlock()
+
+		if status.Status != "ok" {
+			overallStatus = "degraded"
+		}
+
+		accountStatuses[i] = status
+	}
+
+	return &types.ProviderStatus{
+		Name:      providerName,
+		Status:    overallStatus,
+		Accounts:  accountStatuses,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// GenerateImage is not supported by the Anthropic provider.
+func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
+	return nil, fmt.Errorf("image generation is not supported by the Anthropic provider")
+}
@@ -0,0 +1,139 @@
+package anthropic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func setupTestAccountManager(t *testing.T, accounts []account.Account) *account.Manager {
+	tmpDir, err := os.MkdirTemp("", "mcp-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	mgr := account.NewManager(filepath.Join(tmpDir, "accounts.json"))
+	for _, acc := range accounts {
+		if err := mgr.AddAccount(acc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mgr.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	return mgr
+}
+
+func TestProvider_Initialize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"id":"claude-a","display_name":"Claude A","type":"model"},{"id":"claude-b","type":"model"}],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	mgr := setupTestAccountManager(t, []account.Account{
+		{Email: "anthropic-1", Provider: "anthropic", Source: "manual", APIKey: "key-1"},
+	})
+	p := NewProvider(mgr)
+	p.client.baseURL = server.URL
+
+	if err := p.Initialize(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(p.Models()) != 2 || !p.SupportsModel("claude-a") {
+		t.Errorf("unexpected models: %v", p.Models())
+	}
+}
+
+func TestProvider_SendMessage_Failover(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantMarked func(account.Account) bool
+	}{
+		{
+			name:   "rate limited",
+			status: http.StatusTooManyRequests,
+			wantMarked: func(acc account.Account) bool {
+				return acc.ModelRateLimits["claude-a"].IsRateLimited
+			},
+		},
+		{
+			name:       "invalid key",
+			status:     http.StatusUnauthorized,
+			wantMarked: func(acc account.Account) bool { return acc.IsInvalid },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("x-api-key") == "key-bad" {
+					w.Header().Set("retry-after", "30")
+					w.WriteHeader(tt.status)
+					w.Write([]byte(`{"type":"error","error":{"type":"error","message":"nope"}}`))
+					return
+				}
+				w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}]}`))
+			}))
+			defer server.Close()
+
+			mgr := setupTestAccountManager(t, []account.Account{
+				{Email: "anthropic-bad", Provider: "anthropic", Source: "manual", APIKey: "key-bad"},
+				{Email: "anthropic-ok", Provider: "anthropic", Source: "manual", APIKey: "key-ok"},
+			})
+			p := NewProvider(mgr)
+			p.client.baseURL = server.URL
+
+			// Two requests guarantee the bad account is picked at least once.
+			for i := 0; i < 2; i++ {
+				resp, err := p.SendMessage(context.Background(), &types.AnthropicRequest{Model: "claude-a"})
+				if err != nil {
+					t.Fatalf("request %d: unexpected error: %v", i, err)
+				}
+				if resp.ID != "msg_1" {
+					t.Errorf("request %d: unexpected response %+v", i, resp)
+				}
+			}
+
+			for _, acc := range mgr.GetAllAccountsByProvider(providerName) {
+				if marked := tt.wantMarked(acc); marked != (acc.Email == "anthropic-bad") {
+					t.Errorf("account %s: marked = %v", acc.Email, marked)
+				}
+			}
+		})
+	}
+}
+
+func TestProvider_SendMessageStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+	}))
+	defer server.Close()
+
+	mgr := setupTestAccountManager(t, []account.Account{
+		{Email: "anthropic-1", Provider: "anthropic", Source: "manual", APIKey: "key-1"},
+	})
+	p := NewProvider(mgr)
+	p.client.baseURL = server.URL
+
+	events, err := p.SendMessageStream(context.Background(), &types.AnthropicRequest{Model: "claude-a"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for evt := range events {
+		got = append(got, evt.Type)
+	}
+	if len(got) != 2 || got[0] != "message_start" || got[1] != "message_stop" {
+		t.Errorf("unexpected events: %v", got)
+	}
+}
@@ -0,0 +1,133 @@
+package antigravity
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // requests flow normally
+	breakerOpen                         // requests skip the endpoint until the cooldown ends
+	breakerHalfOpen                     // one probe request is in flight
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// endpointBreakers is a circuit breaker per Cloud Code endpoint, so an endpoint that keeps
+// failing is skipped instead of costing every request a failed attempt and backoff.
+//
+// An endpoint's breaker opens after AntigravityBreakerFailureThreshold consecutive failures.
+// Once AntigravityBreakerCooldown has passed, a single request probes it (half-open): success
+// closes the breaker, failure opens it for another cooldown.
+type endpointBreakers struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	endpoints map[string]*endpointBreaker
+}
+
+type endpointBreaker struct {
+	state    breakerState
+	failures int // consecutive
+	openedAt time.Time
+}
+
+func newEndpointBreakers() *endpointBreakers {
+	return &endpointBreakers{
+		threshold: config.AntigravityBreakerFailureThreshold,
+		cooldown:  config.AntigravityBreakerCooldown,
+		now:       time.Now,
+		endpoints: make(map[string]*endpointBreaker),
+	}
+}
+
+func (b *endpointBreakers) get(endpoint string) *endpointBreaker {
+	e, ok := b.endpoints[endpoint]
+	if !ok {
+		e = &endpointBreaker{}
+		b.endpoints[endpoint] = e
+	}
+	return e
+}
+
+// allow reports whether a request may be sent to the endpoint. An open breaker whose
+// cooldown has passed lets this request through as the probe.
+func (b *endpointBreakers) allow(endpoint string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e := b.get(endpoint)
+	switch e.state {
+	case breakerOpen:
+		if b.now().Sub(e.openedAt) < b.cooldown {
+			return false
+		}
+		e.state = breakerHalfOpen
+		utils.Debug("[CloudCode] Probing %s after %s", endpoint, utils.FormatDuration(b.cooldown))
+		return true
+	case breakerHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// success records a response from the endpoint, closing its breaker.
+func (b *endpointBreakers) success(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e := b.get(endpoint)
+	if e.state != breakerClosed {
+		utils.Info("[CloudCode] %s recovered, resuming requests", endpoint)
+	}
+	e.state = breakerClosed
+	e.failures = 0
+}
+
+// failure records a failed request, opening the breaker at the threshold or when a probe fails.
+func (b *endpointBreakers) failure(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e := b.get(endpoint)
+	e.failures++
+	if e.state == breakerHalfOpen || (e.state == breakerClosed && e.failures >= b.threshold) {
+		if e.state == breakerClosed {
+			utils.Warn("[CloudCode] %s failed %d requests in a row, skipping it for %s",
+				endpoint, e.failures, utils.FormatDuration(b.cooldown))
+		}
+		e.state = breakerOpen
+		e.openedAt = b.now()
+	}
+}
+
+// release ends a request that says nothing about the endpoint's health (e.g. the client
+// went away), so an unfinished probe doesn't keep the breaker half-open.
+func (b *endpointBreakers) release(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if e := b.get(endpoint); e.state == breakerHalfOpen {
+		e.state = breakerOpen
+		e.openedAt = b.now().Add(-b.cooldown) // probe again on the next request
+	}
+}
+
+// state returns the breaker state of the endpoint.
+func (b *endpointBreakers) state(endpoint string) breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.get(endpoint).state
+}
@@ -0,0 +1,111 @@
+package antigravity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEndpointBreakers_StateTransitions(t *testing.T) {
+	now := time.Now()
+	b := newEndpointBreakers()
+	b.threshold = 3
+	b.cooldown = time.Minute
+	b.now = func() time.Time { return now }
+	const ep = "https://example.com"
+
+	for i := 0; i < 2; i++ {
+		b.failure(ep)
+	}
+	b.success(ep) // resets the consecutive count
+	for i := 0; i < 2; i++ {
+		b.failure(ep)
+	}
+	if got := b.state(ep); got != breakerClosed {
+		t.Fatalf("after non-consecutive failures state = %s, want closed", got)
+	}
+
+	b.failure(ep)
+	if got := b.state(ep); got != breakerOpen {
+		t.Fatalf("after %d consecutive failures state = %s, want open", b.threshold, got)
+	}
+	if b.allow(ep) {
+		t.Fatal("open breaker allowed a request before the cooldown")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.allow(ep) {
+		t.Fatal("breaker did not allow a probe after the cooldown")
+	}
+	if b.allow(ep) {
+		t.Fatal("half-open breaker allowed a second request while probing")
+	}
+	b.failure(ep)
+	if got := b.state(ep); got != breakerOpen {
+		t.Fatalf("after a failed probe state = %s, want open", got)
+	}
+
+	now = now.Add(time.Minute)
+	if !b.allow(ep) {
+		t.Fatal("breaker did not allow a probe after the second cooldown")
+	}
+	b.release(ep) // probe cancelled: the next request probes again
+	if !b.allow(ep) {
+		t.Fatal("breaker did not allow a probe after a released one")
+	}
+	b.success(ep)
+	if got := b.state(ep); got != breakerClosed || !b.allow(ep) {
+		t.Fatalf("after a successful probe state = %s, want closed", got)
+	}
+}
+
+func TestClient_DoRequest_SkipsFailingEndpoint(t *testing.T) {
+	var failingHits, healthyHits atomic.Int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failingHits.Add(1)
+		http.Error(w, "backend unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyHits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"response":{}}`))
+	}))
+	defer healthy.Close()
+
+	now := time.Now()
+	c := NewClient()
+	c.endpoints = []string{failing.URL, healthy.URL}
+	c.breakers = newEndpointBreakers()
+	c.stats = newEndpointStats()
+	c.breakers.threshold = 2
+	c.breakers.now = func() time.Time { return now }
+
+	send := func() {
+		t.Helper()
+		if _, err := c.DoRequest(context.Background(), RequestOptions{Token: "t", Model: "gemini-3-flash", Payload: map[string]interface{}{}}); err != nil {
+			t.Fatalf("DoRequest: %v", err)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		send()
+	}
+	if got := failingHits.Load(); got != 2 {
+		t.Errorf("failing endpoint hit %d times, want 2 (until its breaker opened)", got)
+	}
+	if got := healthyHits.Load(); got != 4 {
+		t.Errorf("healthy endpoint hit %d times, want 4", got)
+	}
+
+	// After the cooldown one request probes the failing endpoint again.
+	now = now.Add(c.breakers.cooldown)
+	send()
+	send()
+	if got := failingHits.Load(); got != 3 {
+		t.Errorf("failing endpoint hit %d times after the cooldown, want 3 (one probe)", got)
+	}
+}
@@ -13,7 +13,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/kuzerno1/multi-claude-proxy/internal/capture"
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/egress"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/tracing"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 )
 
@@ -21,15 +26,20 @@ import (
 type Client struct {
 	httpClient *http.Client
 	endpoints  []string
+	breakers   *endpointBreakers
+	stats      *endpointStats
 }
 
 // NewClient creates a new Cloud Code API client.
 func NewClient() *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: 10 * time.Minute,
+			Timeout:   10 * time.Minute,
+			Transport: provider.ConnectTimeoutTransport("antigravity", tracing.Transport(capture.Transport(egress.Transport()))),
 		},
 		endpoints: config.AntigravityEndpointFallbacks,
+		breakers:  sharedBreakers,
+		stats:     sharedStats,
 	}
 }
 
@@ -62,13 +72,33 @@ func (e *HTTPStatusError) Error() string {
 	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Body)
 }
 
-// DoRequest sends a request to the Cloud Code API with endpoint fallback.
+// AnthropicError maps the Google error response to the Anthropic error type.
+func (e *HTTPStatusError) AnthropicError() *merrors.AnthropicError {
+	return merrors.FromUpstream(e.StatusCode, []byte(e.Body))
+}
+
+// DoRequest sends a request to the Cloud Code API with endpoint fallback, trying the
+// fastest healthy endpoint first.
 func (c *Client) DoRequest(ctx context.Context, opts RequestOptions) (*Response, error) {
 	var lastErr error
 	var lastRateLimitErr *RateLimitError
 
-	for _, endpoint := range c.endpoints {
+	endpoints := c.stats.order(c.endpoints)
+	attempted := false
+	for i, endpoint := range endpoints {
+		if !c.breakers.allow(endpoint) {
+			// With every endpoint's breaker open, still try the last one rather than
+			// failing without a request.
+			if attempted || i < len(endpoints)-1 {
+				utils.Debug("[CloudCode] Skipping %s: circuit breaker %s", endpoint, c.breakers.state(endpoint))
+				continue
+			}
+		}
+		attempted = true
+
+		start := time.Now()
 		resp, err := c.doSingleRequest(ctx, endpoint, opts)
+		c.recordEndpointResult(endpoint, time.Since(start), err)
 		if err == nil {
 			return resp, nil
 		}
@@ -125,6 +155,30 @@ func (c *Client) DoRequest(ctx context.Context, opts RequestOptions) (*Response,
 	return nil, fmt.Errorf("All endpoints failed: %w", lastErr)
 }
 
+// recordEndpointResult updates the endpoint's circuit breaker and routing stats. Only 5xx
+// responses and connection errors count as failures: 429s and other 4xx come from a
+// working endpoint.
+func (c *Client) recordEndpointResult(endpoint string, latency time.Duration, err error) {
+	failed := false
+	switch e := err.(type) {
+	case nil, *RateLimitError:
+	case *HTTPStatusError:
+		failed = e.StatusCode >= 500
+	default:
+		if !isRetryableError(err) {
+			c.breakers.release(endpoint) // e.g. cancelled: says nothing about the endpoint
+			return
+		}
+		failed = true
+	}
+	c.stats.record(endpoint, latency, failed)
+	if failed {
+		c.breakers.failure(endpoint)
+	} else {
+		c.breakers.success(endpoint)
+	}
+}
+
 func (c *Client) doSingleRequest(ctx context.Context, endpoint string, opts RequestOptions) (*Response, error) {
 	// Node parity:
 	// - Streaming always uses the SSE endpoint.
@@ -156,10 +210,12 @@ func (c *Client) doSingleRequest(ctx context.Context, endpoint string, opts Requ
 		req.Header.Set(k, v)
 	}
 
+	config.SetProviderHeaders(req, "antigravity")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
+	provider.TraceFromContext(ctx).RecordRateLimit(resp.Header)
 
 	// Check for error status
 	if resp.StatusCode >= 400 {
@@ -390,7 +446,7 @@ func (c *Client) FetchAvailableModels(ctx context.Context, token string) (*Avail
 	headers["Authorization"] = "Bearer " + token
 	headers["Content-Type"] = "application/json"
 
-	for _, endpoint := range c.endpoints {
+	for _, endpoint := range c.stats.order(c.endpoints) {
 		url := endpoint + "/v1internal:fetchAvailableModels"
 
 		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader([]byte("{}")))
@@ -402,17 +458,21 @@ func (c *Client) FetchAvailableModels(ctx context.Context, token string) (*Avail
 			req.Header.Set(k, v)
 		}
 
+		config.SetProviderHeaders(req, "antigravity")
+		start := time.Now()
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
 			// Don't warn on context cancellation/deadline - expected on client disconnect, timeout, or shutdown
 			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 				return nil, err
 			}
+			c.stats.record(endpoint, 0, true)
 			utils.Warn("[CloudCode] fetchAvailableModels failed at %s: %v", endpoint, err)
 			continue
 		}
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		c.stats.record(endpoint, time.Since(start), resp.StatusCode >= 500)
 
 		if resp.StatusCode != http.StatusOK {
 			utils.Warn("[CloudCode] fetchAvailableModels error at %s: %d - %s", endpoint, resp.StatusCode, string(body))
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
@@ -19,6 +20,7 @@ func ConvertAnthropicToGoogle(req *types.AnthropicRequest) map[string]interface{
 	isClaudeModel := modelFamily == "claude"
 	isGeminiModel := modelFamily == "gemini"
 	isThinking := config.IsThinkingModel(modelName)
+	session := SignatureSession(req)
 
 	googleReq := map[string]interface{}{
 		"contents":         []interface{}{},
@@ -46,17 +48,19 @@ func ConvertAnthropicToGoogle(req *types.AnthropicRequest) map[string]interface{
 	// Apply thinking recovery for thinking models when needed (Node parity)
 	processedMessages := req.Messages
 
-	if isThinking && needsThinkingRecovery(req.Messages) {
+	// THINKING_SIGNATURE_RECOVERY=refresh also recovers turns whose thinking the target can't take.
+	refresh := isThinking && needsThinkingRefresh(req.Messages, session, string(modelFamily))
+	if isThinking && (refresh || needsThinkingRecovery(req.Messages)) {
 		targetFamily := "gemini"
 		if isClaudeModel {
 			// For Claude: apply recovery only for cross-model (Gemini→Claude) switch
-			if hasGeminiHistory(req.Messages) {
+			if refresh || hasGeminiHistory(req.Messages) {
 				utils.Debug("[RequestConverter] Applying thinking recovery for Claude (cross-model from Gemini)")
-				processedMessages = closeToolLoopForThinking(req.Messages, "claude")
+				processedMessages = closeToolLoopForThinking(req.Messages, session, "claude")
 			}
 		} else if isGeminiModel {
 			utils.Debug("[RequestConverter] Applying thinking recovery for Gemini")
-			processedMessages = closeToolLoopForThinking(req.Messages, targetFamily)
+			processedMessages = closeToolLoopForThinking(req.Messages, session, targetFamily)
 		}
 	}
 
@@ -71,16 +75,16 @@ func ConvertAnthropicToGoogle(req *types.AnthropicRequest) map[string]interface{
 				// Convert processed blocks to parts
 				parts = make([]interface{}, 0, len(blocks))
 				for _, block := range blocks {
-					part := convertBlockToPart(block, isClaudeModel, isGeminiModel)
+					part := convertBlockToPart(block, session, isClaudeModel, isGeminiModel)
 					if part != nil {
 						parts = append(parts, part)
 					}
 				}
 			} else {
-				parts = convertContentToParts(msg.Content, isClaudeModel, isGeminiModel)
+				parts = convertContentToParts(msg.Content, session, isClaudeModel, isGeminiModel)
 			}
 		} else {
-			parts = convertContentToParts(msg.Content, isClaudeModel, isGeminiModel)
+			parts = convertContentToParts(msg.Content, session, isClaudeModel, isGeminiModel)
 		}
 
 		// Ensure at least one part per message
@@ -155,9 +159,17 @@ func ConvertAnthropicToGoogle(req *types.AnthropicRequest) map[string]interface{
 	// Convert tools
 	if len(req.Tools) > 0 {
 		functionDeclarations := make([]interface{}, 0, len(req.Tools))
+		googleSearch := false
 		for i, tool := range req.Tools {
+			if tool.IsServerTool() {
+				// web_search runs as Google Search grounding; other server tools are
+				// rejected by ValidateServerTools before conversion.
+				googleSearch = googleSearch || tool.IsWebSearch()
+				continue
+			}
+
 			name := tool.Name
-			if name == "" {
+			if name == "" && tool.Function != nil {
 				name = tool.Function.Name
 			}
 			if name == "" {
@@ -165,12 +177,12 @@ func ConvertAnthropicToGoogle(req *types.AnthropicRequest) map[string]interface{
 			}
 
 			description := tool.Description
-			if description == "" {
+			if description == "" && tool.Function != nil {
 				description = tool.Function.Description
 			}
 
 			schema := tool.InputSchema
-			if schema == nil {
+			if schema == nil && tool.Function != nil {
 				schema = tool.Function.Parameters
 			}
 			if schema == nil {
@@ -187,8 +199,18 @@ func ConvertAnthropicToGoogle(req *types.AnthropicRequest) map[string]interface{
 				"parameters":  cleaned,
 			})
 		}
-		googleReq["tools"] = []interface{}{
-			map[string]interface{}{"functionDeclarations": functionDeclarations},
+		var tools []interface{}
+		if len(functionDeclarations) > 0 {
+			tools = append(tools, map[string]interface{}{"functionDeclarations": functionDeclarations})
+			if toolConfig := convertToolChoice(req.ToolChoice); toolConfig != nil {
+				googleReq["toolConfig"] = toolConfig
+			}
+		}
+		if googleSearch {
+			tools = append(tools, map[string]interface{}{"googleSearch": map[string]interface{}{}})
+		}
+		if len(tools) > 0 {
+			googleReq["tools"] = tools
 		}
 	}
 
@@ -203,8 +225,27 @@ func ConvertAnthropicToGoogle(req *types.AnthropicRequest) map[string]interface{
 	return googleReq
 }
 
-// ConvertGoogleToAnthropic converts a Google Generative AI response to Anthropic format.
-func ConvertGoogleToAnthropic(googleResp map[string]interface{}, model string) *types.AnthropicResponse {
+// ValidateServerTools rejects Anthropic server tools that have no Google equivalent for
+// the request's model. Gemini models run web_search as Google Search grounding; any other
+// server tool, and server tools on non-Gemini models, get an invalid_request_error
+// instead of being converted into a function the model can't call.
+func ValidateServerTools(req *types.AnthropicRequest) error {
+	isGeminiModel := config.GetModelFamily(req.Model) == "gemini"
+	for _, tool := range req.Tools {
+		if !tool.IsServerTool() || (isGeminiModel && tool.IsWebSearch()) {
+			continue
+		}
+		if tool.IsWebSearch() {
+			return merrors.InvalidRequest(fmt.Sprintf("Server tool %s (%s) is not supported for model %s; web search is only available with Gemini models", tool.Name, tool.Type, req.Model))
+		}
+		return merrors.InvalidRequest(fmt.Sprintf("Server tool %s (%s) is not supported for model %s", tool.Name, tool.Type, req.Model))
+	}
+	return nil
+}
+
+// ConvertGoogleToAnthropic converts a Google Generative AI response to Anthropic format,
+// caching its signatures for session.
+func ConvertGoogleToAnthropic(googleResp map[string]interface{}, model, session string) *types.AnthropicResponse {
 	response := googleResp
 	if inner, ok := googleResp["response"].(map[string]interface{}); ok {
 		response = inner
@@ -225,6 +266,7 @@ func ConvertGoogleToAnthropic(googleResp map[string]interface{}, model string) *
 	// Convert parts to Anthropic content blocks
 	anthropicContent := make([]types.ContentBlock, 0)
 	hasToolCalls := false
+	toolIDs := make(map[string]bool)
 	sigCache := GetGlobalSignatureCache()
 
 	for _, p := range parts {
@@ -241,7 +283,7 @@ func ConvertGoogleToAnthropic(googleResp map[string]interface{}, model string) *
 				// Cache thinking signature with model family
 				if len(signature) >= config.MinSignatureLength {
 					modelFamily := config.GetModelFamily(model)
-					sigCache.CacheThinkingSignature(signature, string(modelFamily))
+					sigCache.CacheThinkingSignature(session, signature, string(modelFamily))
 				}
 
 				anthropicContent = append(anthropicContent, types.ContentBlock{
@@ -258,13 +300,7 @@ func ConvertGoogleToAnthropic(googleResp map[string]interface{}, model string) *
 			}
 		} else if fc, ok := part["functionCall"].(map[string]interface{}); ok {
 			// Tool use block
-			toolID := ""
-			if id, ok := fc["id"].(string); ok {
-				toolID = id
-			} else {
-				toolID = generateToolID()
-			}
-
+			toolID := toolUseID(fc, toolIDs)
 			name, _ := fc["name"].(string)
 			args, _ := fc["args"].(map[string]interface{})
 			if args == nil {
@@ -281,7 +317,7 @@ func ConvertGoogleToAnthropic(googleResp map[string]interface{}, model string) *
 			// For Gemini, cache thoughtSignature from the part level
 			if sig, ok := part["thoughtSignature"].(string); ok && len(sig) >= config.MinSignatureLength {
 				block.ThoughtSignature = sig
-				sigCache.CacheToolSignature(toolID, sig)
+				sigCache.CacheToolSignature(session, toolID, sig)
 			}
 
 			anthropicContent = append(anthropicContent, block)
@@ -289,29 +325,21 @@ func ConvertGoogleToAnthropic(googleResp map[string]interface{}, model string) *
 		}
 	}
 
-	// Determine stop reason
-	// Priority: max_tokens > tool_use > end_turn
-	// MAX_TOKENS always takes precedence (even over tool_use).
-	// STOP should not overwrite tool_use.
-	finishReason, _ := firstCandidate["finishReason"].(string)
-	stopReason := "end_turn"
-	switch finishReason {
-	case "MAX_TOKENS":
-		stopReason = "max_tokens"
-	case "TOOL_USE":
-		stopReason = "tool_use"
-	case "STOP":
-		if hasToolCalls {
-			stopReason = "tool_use"
-		} else {
-			stopReason = "end_turn"
-		}
-	default:
-		if hasToolCalls {
-			stopReason = "tool_use"
+	// Google Search grounding comes first, like Anthropic's web_search blocks.
+	if blocks := webSearchBlocks(firstCandidate); len(blocks) > 0 {
+		searchContent := make([]types.ContentBlock, 0, len(blocks)+len(anthropicContent))
+		for _, b := range blocks {
+			var block types.ContentBlock
+			if data, err := json.Marshal(b); err == nil && json.Unmarshal(data, &block) == nil {
+				searchContent = append(searchContent, block)
+			}
 		}
+		anthropicContent = append(searchContent, anthropicContent...)
 	}
 
+	finishReason, _ := firstCandidate["finishReason"].(string)
+	stopReason := stopReasonFor(finishReason, hasToolCalls)
+
 	// Extract usage metadata
 	usageMetadata, _ := response["usageMetadata"].(map[string]interface{})
 	promptTokens := getInt(usageMetadata, "promptTokenCount")
@@ -340,6 +368,97 @@ func ConvertGoogleToAnthropic(googleResp map[string]interface{}, model string) *
 	}
 }
 
+// stopReasonFor maps a Google finishReason to an Anthropic stop_reason.
+// Priority: max_tokens > tool_use > end_turn. MAX_TOKENS takes precedence even over
+// tool calls, and tool_use is only reported when the response contains a tool call.
+func stopReasonFor(finishReason string, hasToolCalls bool) string {
+	switch {
+	case finishReason == "MAX_TOKENS":
+		return "max_tokens"
+	case hasToolCalls:
+		return "tool_use"
+	default:
+		return "end_turn"
+	}
+}
+
+// webSearchBlocks returns a candidate's Google Search grounding as the server_tool_use
+// and web_search_tool_result blocks of Anthropic's web_search tool, or nil if the
+// candidate wasn't grounded with a search.
+func webSearchBlocks(candidate map[string]interface{}) []map[string]interface{} {
+	grounding, _ := candidate["groundingMetadata"].(map[string]interface{})
+	queries, _ := grounding["webSearchQueries"].([]interface{})
+	if len(queries) == 0 {
+		return nil
+	}
+	query, _ := queries[0].(string)
+
+	results := make([]interface{}, 0)
+	chunks, _ := grounding["groundingChunks"].([]interface{})
+	for _, c := range chunks {
+		chunk, _ := c.(map[string]interface{})
+		web, _ := chunk["web"].(map[string]interface{})
+		url, _ := web["uri"].(string)
+		if url == "" {
+			continue
+		}
+		title, _ := web["title"].(string)
+		results = append(results, map[string]interface{}{
+			"type":  "web_search_result",
+			"title": title,
+			"url":   url,
+		})
+	}
+
+	id := generateServerToolID()
+	return []map[string]interface{}{
+		{
+			"type":  "server_tool_use",
+			"id":    id,
+			"name":  "web_search",
+			"input": map[string]interface{}{"query": query},
+		},
+		{
+			"type":        "web_search_tool_result",
+			"tool_use_id": id,
+			"content":     results,
+		},
+	}
+}
+
+// toolUseID returns the id for a functionCall's tool_use block: the upstream id, or a
+// generated one when it is missing or was already used in this response, so parallel
+// calls always get distinct ids for their tool_result blocks to refer to.
+func toolUseID(fc map[string]interface{}, seen map[string]bool) string {
+	id, _ := fc["id"].(string)
+	if id == "" || seen[id] {
+		id = generateToolID()
+	}
+	seen[id] = true
+	return id
+}
+
+// LimitToolUse drops every tool_use block after the first when the request's tool_choice
+// sets disable_parallel_tool_use. Google models have no equivalent setting.
+func LimitToolUse(resp *types.AnthropicResponse, tc *types.ToolChoice) {
+	if resp == nil || tc.AllowsParallelToolUse() {
+		return
+	}
+	content := resp.Content[:0]
+	seenToolUse := false
+	for _, block := range resp.Content {
+		if block.Type == "tool_use" {
+			if seenToolUse {
+				utils.Debug("[ResponseConverter] Dropping parallel tool call %s (disable_parallel_tool_use)", block.Name)
+				continue
+			}
+			seenToolUse = true
+		}
+		content = append(content, block)
+	}
+	resp.Content = content
+}
+
 // Helper functions
 
 func convertRole(role string) string {
@@ -349,8 +468,9 @@ func convertRole(role string) string {
 	return "user"
 }
 
-// convertBlockToPart converts a single types.ContentBlock to a Google part.
-func convertBlockToPart(block types.ContentBlock, isClaudeModel, isGeminiModel bool) interface{} {
+// convertBlockToPart converts a single types.ContentBlock to a Google part, restoring the
+// signatures cached for session.
+func convertBlockToPart(block types.ContentBlock, session string, isClaudeModel, isGeminiModel bool) interface{} {
 	sigCache := GetGlobalSignatureCache()
 
 	switch block.Type {
@@ -428,7 +548,7 @@ func convertBlockToPart(block types.ContentBlock, isClaudeModel, isGeminiModel b
 			if block.ThoughtSignature != "" {
 				signature = block.ThoughtSignature
 			} else if block.ID != "" {
-				signature = sigCache.GetToolSignature(block.ID)
+				signature = sigCache.GetToolSignature(session, block.ID)
 				if signature != "" {
 					utils.Debug("[ContentConverter] Restored signature from cache for: %s", block.ID)
 				}
@@ -465,12 +585,15 @@ func convertBlockToPart(block types.ContentBlock, isClaudeModel, isGeminiModel b
 	case "thinking":
 		if len(block.Signature) >= config.MinSignatureLength {
 			if isGeminiModel {
-				sigFamily := sigCache.GetSignatureFamily(block.Signature)
+				sigFamily := sigCache.GetSignatureFamily(session, block.Signature)
 				if sigFamily != "" && sigFamily != "gemini" {
 					utils.Debug("[ContentConverter] Dropping incompatible %s thinking for gemini model", sigFamily)
 					return nil
 				}
 				if sigFamily == "" {
+					if part := recoverUnknownSignaturePart(&block); part != nil {
+						return part
+					}
 					utils.Debug("[ContentConverter] Dropping thinking with unknown signature origin")
 					return nil
 				}
@@ -482,6 +605,13 @@ func convertBlockToPart(block types.ContentBlock, isClaudeModel, isGeminiModel b
 				"thoughtSignature": block.Signature,
 			}
 		}
+
+	default:
+		// Extended blocks (search_result, mcp_tool_use, ...) have no Google equivalent; degrade to text.
+		if text, ok := block.ExtendedText(); ok {
+			return map[string]interface{}{"text": text}
+		}
+		utils.Debug("[ContentConverter] Dropping unsupported %s block", block.Type)
 	}
 
 	return nil
@@ -539,7 +669,7 @@ func appendSystemHint(googleReq map[string]interface{}, hint string) {
 	}
 }
 
-func convertContentToParts(content json.RawMessage, isClaudeModel, isGeminiModel bool) []interface{} {
+func convertContentToParts(content json.RawMessage, session string, isClaudeModel, isGeminiModel bool) []interface{} {
 	parts := make([]interface{}, 0)
 	sigCache := GetGlobalSignatureCache()
 
@@ -641,7 +771,7 @@ func convertContentToParts(content json.RawMessage, isClaudeModel, isGeminiModel
 				if block.ThoughtSignature != "" {
 					signature = block.ThoughtSignature
 				} else if block.ID != "" {
-					signature = sigCache.GetToolSignature(block.ID)
+					signature = sigCache.GetToolSignature(session, block.ID)
 					if signature != "" {
 						utils.Debug("[ContentConverter] Restored signature from cache for: %s", block.ID)
 					}
@@ -680,12 +810,16 @@ func convertContentToParts(content json.RawMessage, isClaudeModel, isGeminiModel
 			if len(block.Signature) >= config.MinSignatureLength {
 				// Check signature compatibility for Gemini
 				if isGeminiModel {
-					sigFamily := sigCache.GetSignatureFamily(block.Signature)
+					sigFamily := sigCache.GetSignatureFamily(session, block.Signature)
 					if sigFamily != "" && sigFamily != "gemini" {
 						utils.Debug("[ContentConverter] Dropping incompatible %s thinking for gemini model", sigFamily)
 						continue
 					}
 					if sigFamily == "" {
+						if part := recoverUnknownSignaturePart(&block); part != nil {
+							parts = append(parts, part)
+							continue
+						}
 						utils.Debug("[ContentConverter] Dropping thinking with unknown signature origin")
 						continue
 					}
@@ -697,6 +831,13 @@ func convertContentToParts(content json.RawMessage, isClaudeModel, isGeminiModel
 					"thoughtSignature": block.Signature,
 				})
 			}
+
+		default:
+			if text, ok := block.ExtendedText(); ok {
+				parts = append(parts, map[string]interface{}{"text": text})
+			} else {
+				utils.Debug("[ContentConverter] Dropping unsupported %s block", block.Type)
+			}
 		}
 	}
 
@@ -736,6 +877,9 @@ func processToolResultContentTyped(content json.RawMessage) (map[string]interfac
 			})
 		} else if block.Type == "text" && block.Text != "" {
 			texts = append(texts, block.Text)
+		} else if text, ok := block.ExtendedText(); ok {
+			// e.g. search_result blocks returned by a tool
+			texts = append(texts, text)
 		}
 	}
 
@@ -750,6 +894,31 @@ func processToolResultContentTyped(content json.RawMessage) (map[string]interfac
 	return map[string]interface{}{"result": result}, imageParts
 }
 
+// convertToolChoice converts an Anthropic tool_choice to Google's toolConfig.
+// Returns nil for auto (the default) and unknown types.
+func convertToolChoice(tc *types.ToolChoice) map[string]interface{} {
+	if tc == nil {
+		return nil
+	}
+
+	callingConfig := map[string]interface{}{}
+	switch tc.Type {
+	case "any":
+		callingConfig["mode"] = "ANY"
+	case "tool":
+		if tc.Name == "" {
+			return nil
+		}
+		callingConfig["mode"] = "ANY"
+		callingConfig["allowedFunctionNames"] = []string{sanitizeFunctionName(tc.Name)}
+	case "none":
+		callingConfig["mode"] = "NONE"
+	default:
+		return nil
+	}
+	return map[string]interface{}{"functionCallingConfig": callingConfig}
+}
+
 func sanitizeFunctionName(name string) string {
 	result := make([]byte, 0, len(name))
 	for i := 0; i < len(name) && len(result) < 64; i++ {
@@ -775,6 +944,12 @@ func generateToolID() string {
 	return "toolu_" + hex.EncodeToString(bytes)
 }
 
+func generateServerToolID() string {
+	bytes := make([]byte, 12)
+	rand.Read(bytes)
+	return "srvtoolu_" + hex.EncodeToString(bytes)
+}
+
 func generateMessageID() string {
 	bytes := make([]byte, 16)
 	rand.Read(bytes)
@@ -821,10 +996,14 @@ func ConvertImageRequestToGoogle(req *types.ImageGenerationRequest, projectID st
 
 	// Add input image for editing if provided
 	if req.InputImage != "" {
+		mimeType := req.InputImageMediaType
+		if mimeType == "" {
+			mimeType = "image/png"
+		}
 		parts := contents[0].(map[string]interface{})["parts"].([]interface{})
 		parts = append(parts, map[string]interface{}{
 			"inlineData": map[string]interface{}{
-				"mimeType": "image/png",
+				"mimeType": mimeType,
 				"data":     req.InputImage,
 			},
 		})
@@ -854,13 +1033,14 @@ func ConvertImageRequestToGoogle(req *types.ImageGenerationRequest, projectID st
 		"generationConfig": generationConfig,
 	}
 
+	info := config.GetAntigravityClientInfo()
 	payload := map[string]interface{}{
 		"project":     projectID,
 		"model":       req.Model,
 		"request":     googleReq,
-		"userAgent":   "antigravity",
-		"requestType": "agent",
-		"requestId":   "agent-" + generateMessageID()[4:], // Reuse generateMessageID but strip "msg_" prefix
+		"userAgent":   info.UserAgent,
+		"requestType": info.RequestType,
+		"requestId":   info.RequestIDPrefix + generateMessageID()[4:], // Reuse generateMessageID but strip "msg_" prefix
 	}
 
 	// Add session ID for character consistency if provided
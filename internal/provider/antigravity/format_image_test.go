@@ -147,6 +147,25 @@ func TestConvertImageRequestToGoogle(t *testing.T) {
 			t.Errorf("expected image data, got %v", inlineData["data"])
 		}
 	})
+
+	t.Run("with input image media type", func(t *testing.T) {
+		req := &types.ImageGenerationRequest{
+			Prompt:              "make it black and white",
+			Model:               "gemini-3-pro-image",
+			InputImage:          "base64encodedimage==",
+			InputImageMediaType: "image/jpeg",
+			Count:               1,
+		}
+
+		result := ConvertImageRequestToGoogle(req, "test-project")
+
+		googleReq := result["request"].(map[string]interface{})
+		parts := googleReq["contents"].([]interface{})[0].(map[string]interface{})["parts"].([]interface{})
+		inlineData := parts[1].(map[string]interface{})["inlineData"].(map[string]interface{})
+		if inlineData["mimeType"] != "image/jpeg" {
+			t.Errorf("expected mimeType 'image/jpeg', got %v", inlineData["mimeType"])
+		}
+	})
 }
 
 func TestConvertGoogleImageResponse(t *testing.T) {
@@ -2,6 +2,9 @@ package antigravity
 
 import (
 	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
@@ -235,7 +238,7 @@ func TestConvertGoogleToAnthropic(t *testing.T) {
 		},
 	}
 
-	result := ConvertGoogleToAnthropic(googleResp, "claude-sonnet-4-5")
+	result := ConvertGoogleToAnthropic(googleResp, "claude-sonnet-4-5", "")
 
 	if result.Role != "assistant" {
 		t.Errorf("expected role assistant, got %s", result.Role)
@@ -287,7 +290,7 @@ func TestConvertGoogleToAnthropic_ToolUse(t *testing.T) {
 		},
 	}
 
-	result := ConvertGoogleToAnthropic(googleResp, "claude-sonnet-4-5")
+	result := ConvertGoogleToAnthropic(googleResp, "claude-sonnet-4-5", "")
 
 	if result.StopReason != "tool_use" {
 		t.Errorf("expected stop_reason tool_use, got %s", result.StopReason)
@@ -325,7 +328,7 @@ func TestConvertGoogleToAnthropic_Thinking(t *testing.T) {
 		},
 	}
 
-	result := ConvertGoogleToAnthropic(googleResp, "claude-sonnet-4-5-thinking")
+	result := ConvertGoogleToAnthropic(googleResp, "claude-sonnet-4-5-thinking", "")
 
 	if len(result.Content) != 2 {
 		t.Errorf("expected 2 content blocks, got %d", len(result.Content))
@@ -352,29 +355,29 @@ func TestSignatureCache(t *testing.T) {
 	cache := NewSignatureCache()
 
 	// Test tool signature caching
-	cache.CacheToolSignature("tool_123", "sig_abc")
-	sig := cache.GetToolSignature("tool_123")
+	cache.CacheToolSignature("", "tool_123", "sig_abc")
+	sig := cache.GetToolSignature("", "tool_123")
 	if sig != "sig_abc" {
 		t.Errorf("expected sig_abc, got %s", sig)
 	}
 
 	// Test missing signature
-	sig = cache.GetToolSignature("nonexistent")
+	sig = cache.GetToolSignature("", "nonexistent")
 	if sig != "" {
 		t.Errorf("expected empty string for missing key, got %s", sig)
 	}
 
 	// Test thinking signature caching (needs min length)
 	longSig := "abc123def456abc123def456abc123def456abc123def456abc123"
-	cache.CacheThinkingSignature(longSig, "claude")
-	family := cache.GetSignatureFamily(longSig)
+	cache.CacheThinkingSignature("", longSig, "claude")
+	family := cache.GetSignatureFamily("", longSig)
 	if family != "claude" {
 		t.Errorf("expected claude, got %s", family)
 	}
 
 	// Test too short signature (should not be cached)
-	cache.CacheThinkingSignature("short", "gemini")
-	family = cache.GetSignatureFamily("short")
+	cache.CacheThinkingSignature("", "short", "gemini")
+	family = cache.GetSignatureFamily("", "short")
 	if family != "" {
 		t.Errorf("expected empty string for short signature, got %s", family)
 	}
@@ -516,7 +519,7 @@ func TestConvertContentToParts_ToolResultUsesToolUseID(t *testing.T) {
 		"content": "Tool execution result"
 	}]`)
 
-	parts := convertContentToParts(content, true, false) // isClaudeModel=true
+	parts := convertContentToParts(content, "", true, false) // isClaudeModel=true
 
 	if len(parts) != 1 {
 		t.Fatalf("expected 1 part, got %d", len(parts))
@@ -551,7 +554,7 @@ func TestConvertContentToParts_ToolResultWithMissingID(t *testing.T) {
 		"content": "Tool execution result"
 	}]`)
 
-	parts := convertContentToParts(content, false, false)
+	parts := convertContentToParts(content, "", false, false)
 
 	if len(parts) != 1 {
 		t.Fatalf("expected 1 part, got %d", len(parts))
@@ -577,7 +580,7 @@ func TestConvertContentToParts_DocumentBlock(t *testing.T) {
 		}
 	}]`)
 
-	parts := convertContentToParts(content, false, false)
+	parts := convertContentToParts(content, "", false, false)
 
 	if len(parts) != 1 {
 		t.Fatalf("expected 1 part, got %d", len(parts))
@@ -766,7 +769,7 @@ func TestCloseToolLoopForThinking_MultiDigitCount(t *testing.T) {
 		})
 	}
 
-	result := closeToolLoopForThinking(messages, "gemini")
+	result := closeToolLoopForThinking(messages, "", "gemini")
 
 	// Should have synthetic assistant + user messages at the end
 	if len(result) != len(messages)+2 {
@@ -789,6 +792,44 @@ func TestCloseToolLoopForThinking_MultiDigitCount(t *testing.T) {
 	}
 }
 
+func TestConvertAnthropicToGoogle_RefreshesIncompatibleThinking(t *testing.T) {
+	claudeSig := strings.Repeat("c", 64)
+
+	// A Claude tool turn continued on Gemini: Gemini can't take the Claude thinking.
+	req := &types.AnthropicRequest{
+		Model: "gemini-3-flash",
+		Messages: []types.Message{
+			{Role: "user", Content: json.RawMessage(`"Start"`)},
+			{Role: "assistant", Content: json.RawMessage(`[{"type":"thinking","thinking":"plan","signature":"` + claudeSig + `"},{"type":"tool_use","id":"t1","name":"test","input":{}}]`)},
+			{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]`)},
+		},
+	}
+	GetGlobalSignatureCache().CacheThinkingSignature(SignatureSession(req), claudeSig, "claude")
+	lastText := func() string {
+		contents := ConvertAnthropicToGoogle(req)["contents"].([]interface{})
+		parts := contents[len(contents)-1].(map[string]interface{})["parts"].([]interface{})
+		text, _ := parts[0].(map[string]interface{})["text"].(string)
+		return text
+	}
+
+	t.Setenv("THINKING_SIGNATURE_RECOVERY", "drop")
+	if got := lastText(); got == "[Continue]" {
+		t.Error("expected the tool loop to be kept open without refresh recovery")
+	}
+	t.Setenv("THINKING_SIGNATURE_RECOVERY", "refresh")
+	if got := lastText(); got != "[Continue]" {
+		t.Errorf("expected the tool loop to be closed for fresh thinking, last part %q", got)
+	}
+
+	// Compatible thinking is kept either way.
+	geminiSig := strings.Repeat("g", 64)
+	GetGlobalSignatureCache().CacheThinkingSignature(SignatureSession(req), geminiSig, "gemini")
+	req.Messages[1].Content = json.RawMessage(`[{"type":"thinking","thinking":"plan","signature":"` + geminiSig + `"},{"type":"tool_use","id":"t1","name":"test","input":{}}]`)
+	if got := lastText(); got == "[Continue]" {
+		t.Error("expected no refresh for Gemini thinking")
+	}
+}
+
 // Test: redacted_thinking.data is preserved (parity fix #4)
 func TestReorderAssistantContent_PreservesRedactedThinkingData(t *testing.T) {
 	blocks := []types.ContentBlock{
@@ -887,14 +928,14 @@ func TestReorderAssistantContent_FiltersWhitespaceText(t *testing.T) {
 func TestConvertBlockToPart_FiltersWhitespaceText(t *testing.T) {
 	// Whitespace-only text should return nil
 	block := types.ContentBlock{Type: "text", Text: "   "}
-	result := convertBlockToPart(block, false, false)
+	result := convertBlockToPart(block, "", false, false)
 	if result != nil {
 		t.Error("expected nil for whitespace-only text block")
 	}
 
 	// Non-whitespace text should return a part
 	block = types.ContentBlock{Type: "text", Text: "Hello"}
-	result = convertBlockToPart(block, false, false)
+	result = convertBlockToPart(block, "", false, false)
 	if result == nil {
 		t.Error("expected non-nil for text block with content")
 	}
@@ -919,3 +960,319 @@ func TestAnalyzeConversationState_CountsMessages(t *testing.T) {
 		t.Errorf("expected ToolResultCount=1 (messages), got %d", state.ToolResultCount)
 	}
 }
+
+// Test: thinking blocks with unknown signatures are dropped by default and kept as text in recovery mode
+func TestConvertBlockToPart_UnknownSignatureRecovery(t *testing.T) {
+	block := types.ContentBlock{
+		Type:      "thinking",
+		Thinking:  "Let me check the config first.",
+		Signature: strings.Repeat("u", 64), // not in the signature cache
+	}
+
+	if result := convertBlockToPart(block, "", false, true); result != nil {
+		t.Fatalf("expected unknown signature to be dropped by default, got %v", result)
+	}
+
+	t.Setenv("THINKING_SIGNATURE_RECOVERY", "text")
+
+	part, ok := convertBlockToPart(block, "", false, true).(map[string]interface{})
+	if !ok {
+		t.Fatal("expected text part in recovery mode")
+	}
+	text, _ := part["text"].(string)
+	if !strings.HasSuffix(text, block.Thinking) || part["thought"] != nil || part["thoughtSignature"] != nil {
+		t.Errorf("unexpected recovered part: %v", part)
+	}
+
+	parts := convertContentToParts(json.RawMessage(`[{"type":"thinking","thinking":"Plan","signature":"`+strings.Repeat("v", 64)+`"}]`), "", false, true)
+	if len(parts) != 1 {
+		t.Fatalf("expected 1 recovered part, got %d", len(parts))
+	}
+
+	messages := []types.Message{{Role: "assistant", Content: json.RawMessage(`[{"type":"thinking","thinking":"Plan","signature":"` + strings.Repeat("w", 64) + `"}]`)}}
+	stripped := stripInvalidThinkingBlocks(messages, "", "gemini")
+	var blocks []types.ContentBlock
+	json.Unmarshal(stripped[0].Content, &blocks)
+	if len(blocks) != 1 || blocks[0].Type != "text" || !strings.HasSuffix(blocks[0].Text, "Plan") {
+		t.Errorf("expected thinking converted to text, got %+v", blocks)
+	}
+}
+
+// Test: extended blocks (search_result, mcp_tool_use, ...) degrade to text parts
+func TestConvertAnthropicToGoogle_ExtendedBlocks(t *testing.T) {
+	data, err := os.ReadFile("../../../pkg/types/testdata/extended_blocks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req types.AnthropicRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+
+	out, _ := json.Marshal(ConvertAnthropicToGoogle(&req)["contents"])
+	got := string(out)
+	for _, want := range []string{
+		`Search result: Proxy guide\nSource: https://docs.example.com/proxy\nAccounts rotate on rate limits.`,
+		`MCP tool call docs/search_docs: {\"query\":\"rotation\"}`,
+		`MCP tool result (mcptoolu_01): Rotation is round-robin.`,
+		`Search result: Limits\nSource: https://docs.example.com/limits\nLimits are per model.`,
+		`Unregistered block with text.`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("converted contents missing %q\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "opaque_block") || strings.Contains(got, "payload") {
+		t.Errorf("opaque block should be dropped: %s", got)
+	}
+}
+
+func TestConvertAnthropicToGoogle_ToolChoice(t *testing.T) {
+	tests := []struct {
+		name       string
+		toolChoice *types.ToolChoice
+		wantMode   string // empty means no toolConfig
+		wantNames  []string
+	}{
+		{"unset", nil, "", nil},
+		{"auto", &types.ToolChoice{Type: "auto"}, "", nil},
+		{"any", &types.ToolChoice{Type: "any"}, "ANY", nil},
+		{"tool", &types.ToolChoice{Type: "tool", Name: "get.weather"}, "ANY", []string{"get_weather"}},
+		{"tool without name", &types.ToolChoice{Type: "tool"}, "", nil},
+		{"none", &types.ToolChoice{Type: "none"}, "NONE", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &types.AnthropicRequest{
+				Model:     "gemini-3-flash",
+				MaxTokens: 1024,
+				Messages:  []types.Message{{Role: "user", Content: json.RawMessage(`"What's the weather?"`)}},
+				Tools: []types.Tool{{
+					Name:        "get.weather",
+					Description: "Get the weather",
+					InputSchema: map[string]interface{}{"type": "object"},
+				}},
+				ToolChoice: tt.toolChoice,
+			}
+
+			result := ConvertAnthropicToGoogle(req)
+
+			toolConfig, ok := result["toolConfig"].(map[string]interface{})
+			if tt.wantMode == "" {
+				if ok {
+					t.Fatalf("expected no toolConfig, got %v", toolConfig)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("expected toolConfig")
+			}
+			callingConfig := toolConfig["functionCallingConfig"].(map[string]interface{})
+			if callingConfig["mode"] != tt.wantMode {
+				t.Errorf("expected mode %s, got %v", tt.wantMode, callingConfig["mode"])
+			}
+			names, _ := callingConfig["allowedFunctionNames"].([]string)
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("expected allowedFunctionNames %v, got %v", tt.wantNames, names)
+			}
+		})
+	}
+}
+
+func TestConvertAnthropicToGoogle_ToolChoiceWithoutTools(t *testing.T) {
+	req := &types.AnthropicRequest{
+		Model:      "gemini-3-flash",
+		MaxTokens:  1024,
+		Messages:   []types.Message{{Role: "user", Content: json.RawMessage(`"Hi"`)}},
+		ToolChoice: &types.ToolChoice{Type: "any"},
+	}
+
+	if _, ok := ConvertAnthropicToGoogle(req)["toolConfig"]; ok {
+		t.Error("expected no toolConfig without tools")
+	}
+}
+
+func TestConvertGoogleToAnthropic_ParallelToolCalls(t *testing.T) {
+	googleResp := map[string]interface{}{
+		"candidates": []interface{}{
+			map[string]interface{}{
+				"content": map[string]interface{}{
+					"parts": []interface{}{
+						map[string]interface{}{"text": "Reading both files."},
+						map[string]interface{}{"functionCall": map[string]interface{}{"id": "call_1", "name": "read_file", "args": map[string]interface{}{"path": "/a"}}},
+						map[string]interface{}{"functionCall": map[string]interface{}{"id": "call_1", "name": "read_file", "args": map[string]interface{}{"path": "/b"}}},
+						map[string]interface{}{"functionCall": map[string]interface{}{"name": "list_dir"}},
+					},
+				},
+				"finishReason": "STOP",
+			},
+		},
+	}
+
+	result := ConvertGoogleToAnthropic(googleResp, "gemini-3-flash", "")
+
+	if result.StopReason != "tool_use" {
+		t.Errorf("expected stop_reason tool_use, got %s", result.StopReason)
+	}
+	if len(result.Content) != 4 {
+		t.Fatalf("expected 4 content blocks, got %d", len(result.Content))
+	}
+	ids := make(map[string]bool)
+	for _, block := range result.Content[1:] {
+		if block.Type != "tool_use" {
+			t.Fatalf("expected tool_use block, got %s", block.Type)
+		}
+		if block.ID == "" || ids[block.ID] {
+			t.Errorf("expected distinct tool ids, got %q", block.ID)
+		}
+		ids[block.ID] = true
+	}
+	if result.Content[1].ID != "call_1" {
+		t.Errorf("expected upstream id to be kept, got %q", result.Content[1].ID)
+	}
+
+	LimitToolUse(result, &types.ToolChoice{Type: "auto", DisableParallelToolUse: true})
+	if len(result.Content) != 2 || result.Content[1].ID != "call_1" {
+		t.Fatalf("expected text and first tool call only, got %+v", result.Content)
+	}
+	if result.StopReason != "tool_use" {
+		t.Errorf("expected stop_reason tool_use, got %s", result.StopReason)
+	}
+}
+
+func TestStopReasonFor(t *testing.T) {
+	tests := []struct {
+		finishReason string
+		hasToolCalls bool
+		want         string
+	}{
+		{"STOP", false, "end_turn"},
+		{"STOP", true, "tool_use"},
+		{"MAX_TOKENS", false, "max_tokens"},
+		{"MAX_TOKENS", true, "max_tokens"},
+		{"TOOL_USE", false, "end_turn"},
+		{"SAFETY", true, "tool_use"},
+		{"", false, "end_turn"},
+	}
+	for _, tt := range tests {
+		if got := stopReasonFor(tt.finishReason, tt.hasToolCalls); got != tt.want {
+			t.Errorf("stopReasonFor(%q, %v) = %q, want %q", tt.finishReason, tt.hasToolCalls, got, tt.want)
+		}
+	}
+}
+
+func TestConvertAnthropicToGoogle_WebSearch(t *testing.T) {
+	var req types.AnthropicRequest
+	if err := json.Unmarshal([]byte(`{
+		"model": "gemini-3-flash",
+		"max_tokens": 1024,
+		"messages": [{"role": "user", "content": "Latest Go release?"}],
+		"tools": [
+			{"type": "web_search_20250305", "name": "web_search", "max_uses": 5},
+			{"name": "read_file", "input_schema": {"type": "object"}}
+		],
+		"tool_choice": {"type": "any"}
+	}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateServerTools(&req); err != nil {
+		t.Fatalf("expected web_search to be supported on Gemini, got %v", err)
+	}
+
+	result := ConvertAnthropicToGoogle(&req)
+	tools := result["tools"].([]interface{})
+	if len(tools) != 2 {
+		t.Fatalf("expected function declarations and googleSearch, got %v", tools)
+	}
+	decls := tools[0].(map[string]interface{})["functionDeclarations"].([]interface{})
+	if len(decls) != 1 || decls[0].(map[string]interface{})["name"] != "read_file" {
+		t.Errorf("expected only read_file as a function, got %v", decls)
+	}
+	if _, ok := tools[1].(map[string]interface{})["googleSearch"]; !ok {
+		t.Errorf("expected googleSearch tool, got %v", tools[1])
+	}
+	if _, ok := result["toolConfig"]; !ok {
+		t.Error("expected toolConfig for the function declarations")
+	}
+
+	// web_search alone: no function declarations and no toolConfig.
+	req.Tools = req.Tools[:1]
+	result = ConvertAnthropicToGoogle(&req)
+	if tools := result["tools"].([]interface{}); len(tools) != 1 {
+		t.Errorf("expected only googleSearch, got %v", tools)
+	}
+	if _, ok := result["toolConfig"]; ok {
+		t.Error("expected no toolConfig without function declarations")
+	}
+}
+
+func TestValidateServerTools(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   string
+		tool    types.Tool
+		wantErr bool
+	}{
+		{"web search on gemini", "gemini-3-flash", types.Tool{Type: "web_search_20250305", Name: "web_search"}, false},
+		{"web search on claude", "claude-sonnet-4-5", types.Tool{Type: "web_search_20250305", Name: "web_search"}, true},
+		{"code execution on gemini", "gemini-3-flash", types.Tool{Type: "code_execution_20250825", Name: "code_execution"}, true},
+		{"custom tool on claude", "claude-sonnet-4-5", types.Tool{Name: "read_file"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateServerTools(&types.AnthropicRequest{Model: tt.model, Tools: []types.Tool{tt.tool}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.tool.Type) {
+				t.Errorf("expected error to name the tool type, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConvertGoogleToAnthropic_GroundingMetadata(t *testing.T) {
+	googleResp := map[string]interface{}{
+		"candidates": []interface{}{
+			map[string]interface{}{
+				"content": map[string]interface{}{
+					"parts": []interface{}{map[string]interface{}{"text": "Go 1.24 is out."}},
+				},
+				"finishReason": "STOP",
+				"groundingMetadata": map[string]interface{}{
+					"webSearchQueries": []interface{}{"latest go release"},
+					"groundingChunks": []interface{}{
+						map[string]interface{}{"web": map[string]interface{}{"uri": "https://go.dev/doc/devel/release", "title": "go.dev"}},
+					},
+				},
+			},
+		},
+	}
+
+	resp := ConvertGoogleToAnthropic(googleResp, "gemini-3-flash", "")
+	if len(resp.Content) != 3 {
+		t.Fatalf("expected server_tool_use, web_search_tool_result and text, got %+v", resp.Content)
+	}
+
+	data, err := json.Marshal(resp.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blocks []map[string]interface{}
+	json.Unmarshal(data, &blocks)
+	use, result := blocks[0], blocks[1]
+	if use["type"] != "server_tool_use" || use["name"] != "web_search" || use["input"].(map[string]interface{})["query"] != "latest go release" {
+		t.Errorf("unexpected server_tool_use block: %v", use)
+	}
+	if result["type"] != "web_search_tool_result" || result["tool_use_id"] != use["id"] {
+		t.Errorf("unexpected web_search_tool_result block: %v", result)
+	}
+	results := result["content"].([]interface{})
+	if len(results) != 1 || results[0].(map[string]interface{})["url"] != "https://go.dev/doc/devel/release" {
+		t.Errorf("unexpected search results: %v", results)
+	}
+	if blocks[2]["type"] != "text" || resp.StopReason != "end_turn" {
+		t.Errorf("expected text block and end_turn, got %v / %s", blocks[2], resp.StopReason)
+	}
+}
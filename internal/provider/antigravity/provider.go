@@ -16,6 +16,9 @@ import (
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/account"
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/tracing"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
@@ -32,10 +35,16 @@ type Provider struct {
 	client         *Client
 	sigCache       *SignatureCache
 	fallback       bool
+	rotation       *provider.Rotation // Result reporting; the retry loops are Antigravity's own
 	models         []string
 	modelData      map[string]ModelData // Model ID -> ModelData with display name
 	modelSet       map[string]bool
+	initFailures   map[string]string // account email -> model fetch error from Initialize
 	modelsMu       sync.RWMutex
+
+	sigCachePath string          // Persistent signature cache file (empty = in-memory only)
+	sigCacheStop chan struct{}   // Stops the periodic snapshot goroutine
+	sigCacheDone <-chan struct{} // Closed once the snapshot goroutine has stopped
 }
 
 // NewProvider creates a new Antigravity provider.
@@ -45,9 +54,16 @@ func NewProvider(accountManager *account.Manager, fallback bool) *Provider {
 		client:         NewClient(),
 		sigCache:       GetGlobalSignatureCache(),
 		fallback:       fallback,
-		models:         []string{},
-		modelData:      make(map[string]ModelData),
-		modelSet:       make(map[string]bool),
+		rotation: &provider.Rotation{
+			Provider: "antigravity",
+			Name:     "Antigravity",
+			Accounts: accountManager,
+			Policy:   config.GetRetryPolicy("antigravity"),
+			Classify: classifyError,
+		},
+		models:    []string{},
+		modelData: make(map[string]ModelData),
+		modelSet:  make(map[string]bool),
 	}
 }
 
@@ -73,65 +89,125 @@ func (p *Provider) SupportsModel(model string) bool {
 }
 
 // Initialize performs any setup required by the provider.
+// Models are fetched from all valid accounts in parallel; the first success wins.
 func (p *Provider) Initialize(ctx context.Context) error {
+	p.initSignatureCache()
+
 	accounts := p.accountManager.GetAllAccountsByProvider("antigravity")
 	if len(accounts) == 0 {
 		utils.Debug("[Antigravity] No antigravity accounts configured, skipping initialization")
 		return nil
 	}
 
-	// Use the first available account to fetch models
+	byEmail := make(map[string]account.Account, len(accounts))
+	emails := make([]string, 0, len(accounts))
 	for _, acc := range accounts {
 		if acc.IsInvalid {
 			continue
 		}
+		byEmail[acc.Email] = acc
+		emails = append(emails, acc.Email)
+	}
 
-		token, err := p.accountManager.GetTokenForAccount(&acc)
-		if err != nil {
-			utils.Warn("[Antigravity] Failed to get token for account %s: %v", acc.Email, err)
-			continue
-		}
-
-		modelsResp, err := p.client.FetchAvailableModels(ctx, token)
-		if err != nil {
-			utils.Warn("[Antigravity] Failed to fetch models using account %s: %v", acc.Email, err)
-			continue
-		}
-
-		// Include all models from the API response
-		var models []string
-		modelSet := make(map[string]bool)
-		modelData := make(map[string]ModelData)
-		for modelID, modelInfo := range modelsResp.Models {
-			models = append(models, modelID)
-			modelSet[modelID] = true
-			displayName := modelInfo.DisplayName
-			if displayName == "" {
-				displayName = modelID
-			}
-			modelData[modelID] = ModelData{
-				ID:          modelID,
-				DisplayName: displayName,
+	modelsResp, winner, failures, err := provider.FetchFirst(ctx, config.ModelPrefetchTimeout, emails,
+		func(ctx context.Context, email string) (*AvailableModelsResponse, error) {
+			acc := byEmail[email]
+			token, err := p.accountManager.GetTokenForAccount(&acc)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get token: %w", err)
 			}
-		}
+			return p.client.FetchAvailableModels(acc.RequestContext(ctx), token)
+		})
 
-		p.modelsMu.Lock()
-		p.models = models
-		p.modelSet = modelSet
-		p.modelData = modelData
-		p.modelsMu.Unlock()
+	for email, failErr := range failures {
+		utils.Warn("[Antigravity] Failed to fetch models using account %s: %v", email, failErr)
+	}
+	p.modelsMu.Lock()
+	p.initFailures = provider.FailureMessages(failures)
+	p.modelsMu.Unlock()
 
-		utils.Success("[Antigravity] Provider initialized with %d models (fallback=%v)", len(models), p.fallback)
+	if err != nil {
+		utils.Warn("[Antigravity] No valid antigravity accounts available to fetch models")
 		return nil
 	}
 
-	utils.Warn("[Antigravity] No valid antigravity accounts available to fetch models")
+	// Include all models from the API response
+	var models []string
+	modelSet := make(map[string]bool)
+	modelData := make(map[string]ModelData)
+	for modelID, modelInfo := range modelsResp.Models {
+		models = append(models, modelID)
+		modelSet[modelID] = true
+		displayName := modelInfo.DisplayName
+		if displayName == "" {
+			displayName = modelID
+		}
+		modelData[modelID] = ModelData{
+			ID:          modelID,
+			DisplayName: displayName,
+		}
+	}
+
+	p.modelsMu.Lock()
+	p.models = models
+	p.modelSet = modelSet
+	p.modelData = modelData
+	p.modelsMu.Unlock()
+
+	utils.Success("[Antigravity] Provider initialized with %d models via %s (fallback=%v)", len(models), winner, p.fallback)
 	return nil
 }
 
+// InitFailures returns the accounts that failed to fetch models during Initialize.
+func (p *Provider) InitFailures() map[string]string {
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	result := make(map[string]string, len(p.initFailures))
+	for k, v := range p.initFailures {
+		result[k] = v
+	}
+	return result
+}
+
+// initSignatureCache applies cache limits and, if SIGNATURE_CACHE_PATH is set,
+// restores the previous snapshot and starts periodic persistence.
+func (p *Provider) initSignatureCache() {
+	cfg := config.GetSignatureCacheConfig()
+	p.sigCache.SetLimits(cfg.TTL, cfg.MaxEntries, cfg.MaxSessions)
+
+	if cfg.Path == "" || p.sigCacheStop != nil {
+		return
+	}
+
+	loaded, err := p.sigCache.LoadFromFile(cfg.Path)
+	if err != nil {
+		utils.Warn("[Antigravity] Could not restore signature cache: %v", err)
+	} else if loaded > 0 {
+		utils.Info("[Antigravity] Restored %d cached signature(s) from %s", loaded, cfg.Path)
+	}
+
+	interval := cfg.SaveInterval
+	if interval <= 0 {
+		interval = config.DefaultSignatureCacheSaveInterval
+	}
+	p.sigCachePath = cfg.Path
+	p.sigCacheStop = make(chan struct{})
+	p.sigCacheDone = p.sigCache.StartPersistence(cfg.Path, interval, p.sigCacheStop)
+}
+
 // Shutdown performs cleanup when the provider is being stopped.
 func (p *Provider) Shutdown(ctx context.Context) error {
 	utils.Debug("[Antigravity] Provider shutting down")
+
+	if p.sigCacheStop != nil {
+		close(p.sigCacheStop)
+		p.sigCacheStop = nil
+		// Let a periodic save in progress finish first, so it can't replace the final one.
+		<-p.sigCacheDone
+		if err := p.sigCache.SaveToFile(p.sigCachePath); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -142,15 +218,19 @@ func (p *Provider) SendMessage(ctx context.Context, req *types.AnthropicRequest)
 
 // sendMessageWithFallback is the internal implementation that supports fallback.
 func (p *Provider) sendMessageWithFallback(ctx context.Context, req *types.AnthropicRequest, isFallback bool) (*types.AnthropicResponse, error) {
-	// Retry loop with account failover (Node parity).
-	maxAttempts := config.MaxRetries
-	if count := p.accountManager.GetAccountCountByProvider("antigravity") + 1; count > maxAttempts {
-		maxAttempts = count
+	if err := ValidateServerTools(req); err != nil {
+		return nil, err
 	}
 
+	// Retry loop with account failover (Node parity).
+	maxAttempts := p.rotation.Policy.Attempts(p.accountManager.GetAccountCountByProvider("antigravity"))
+	var current *provider.Attempt
+	defer func() { current.Abandon(ctx) }()
+
 	for attempt := 0; attempt < maxAttempts; attempt++ {
+		pickStart := time.Now()
 		// Pick next available account using round-robin selection
-		acc := p.accountManager.PickNextByProvider("antigravity", req.Model)
+		acc := p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 
 		// Handle all accounts rate-limited
 		if acc == nil && p.accountManager.IsAllRateLimitedByProvider("antigravity", req.Model) {
@@ -175,26 +255,26 @@ func (p *Provider) sendMessageWithFallback(ctx context.Context, req *types.Anthr
 			)
 
 			// Wait for reset and add a small buffer (Node parity).
-			if err := sleepWithContext(ctx, waitDur); err != nil {
+			if err := provider.Sleep(ctx, waitDur); err != nil {
 				return nil, err
 			}
-			if err := sleepWithContext(ctx, config.PostRateLimitBuffer); err != nil {
+			if err := provider.Sleep(ctx, config.PostRateLimitBuffer); err != nil {
 				return nil, err
 			}
 			p.accountManager.ClearExpiredLimits()
-			acc = p.accountManager.PickNextByProvider("antigravity", req.Model)
+			acc = p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 
 			// If still no account after waiting, try optimistic reset (Node parity).
 			if acc == nil {
 				utils.Warn("[Antigravity] No account available after wait, attempting optimistic reset...")
 				p.accountManager.ResetAllRateLimitsByProvider("antigravity")
-				acc = p.accountManager.PickNextByProvider("antigravity", req.Model)
+				acc = p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 			}
 		}
 
 		if acc == nil {
 			// Check if fallback is enabled and available (Node parity).
-			if p.fallback && !isFallback {
+			if p.fallback && !isFallback && provider.FallbackAllowed(ctx) {
 				fallbackModel := config.GetFallbackModel(req.Model)
 				if fallbackModel != "" {
 					utils.Warn("[Antigravity] All accounts exhausted for %s. Attempting fallback to %s",
@@ -221,17 +301,17 @@ func (p *Provider) sendMessageWithFallback(ctx context.Context, req *types.Anthr
 			// Treat transient network errors as soft failures and try the next account.
 			if isNetworkError(err) {
 				utils.Warn("[Antigravity] Network error for %s, trying next... (%v)", acc.Email, err)
-				if err := sleepWithContext(ctx, config.NetworkRetryDelay); err != nil {
+				if err := provider.Sleep(ctx, p.rotation.Policy.Delay(attempt)); err != nil {
 					return nil, err
 				}
-				p.accountManager.PickNextByProvider("antigravity", req.Model)
+				p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 				continue
 			}
 			return nil, fmt.Errorf("failed to get token: %w", err)
 		}
 
 		// Get project ID
-		projectID, err := p.accountManager.GetProjectForAccount(acc, token)
+		projectID, err := p.accountManager.GetProjectForModel(acc, token, req.Model)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get project: %w", err)
 		}
@@ -240,7 +320,10 @@ func (p *Provider) sendMessageWithFallback(ctx context.Context, req *types.Anthr
 		payload := p.buildPayload(req, projectID)
 
 		// Send request
-		resp, err := p.client.DoRequest(ctx, RequestOptions{
+		current = p.rotation.Attempt(acc, req.Model)
+		current.Begin(ctx)
+		tracing.RecordSpan(ctx, "account.pick", pickStart, tracing.String("account", acc.Email), tracing.Int("attempt", attempt+1))
+		resp, err := p.client.DoRequest(acc.RequestContext(ctx), RequestOptions{
 			Token:     token,
 			ProjectID: projectID,
 			Model:     req.Model,
@@ -249,10 +332,22 @@ func (p *Provider) sendMessageWithFallback(ctx context.Context, req *types.Anthr
 		})
 
 		if err != nil {
+			current.End(err, false)
+
+			// The client went away; don't move on to another account.
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
 			// Rate limited - mark and continue to next account (Node parity).
 			var rateLimitErr *RateLimitError
 			if errors.As(err, &rateLimitErr) {
-				p.accountManager.MarkRateLimited(acc.Email, rateLimitErr.ResetMs, req.Model)
+				if p.markRateLimited(ctx, acc, projectID, rateLimitErr.ResetMs, req.Model) {
+					// Retry on the account's next project without using up an attempt.
+					ctx = account.WithPreferredAccount(ctx, acc.Email)
+					attempt--
+					continue
+				}
 				utils.Info("[Antigravity] Account %s rate-limited, trying next...", acc.Email)
 				continue
 			}
@@ -266,47 +361,61 @@ func (p *Provider) sendMessageWithFallback(ctx context.Context, req *types.Anthr
 			}
 
 			// 5xx errors are treated as soft failures for this account; try the next one (Node parity).
-			if status, ok := getHTTPStatus(err); ok && status >= 500 {
+			if status, ok := getHTTPStatus(err); ok && p.rotation.Policy.IsRetryableStatus(status) {
 				utils.Warn("[Antigravity] Account %s failed with %d error, trying next...", acc.Email, status)
-				p.accountManager.PickNextByProvider("antigravity", req.Model)
+				p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 				continue
 			}
 
 			// Network error - try next account (Node parity).
 			if isNetworkError(err) {
 				utils.Warn("[Antigravity] Network error for %s, trying next... (%v)", acc.Email, err)
-				if err := sleepWithContext(ctx, config.NetworkRetryDelay); err != nil {
+				if err := provider.Sleep(ctx, p.rotation.Policy.Delay(attempt)); err != nil {
 					return nil, err
 				}
-				p.accountManager.PickNextByProvider("antigravity", req.Model)
+				p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 				continue
 			}
 
 			return nil, err
 		}
 
-		// Parse SSE response (thinking models return SSE even for non-streaming)
-		if config.IsThinkingModel(req.Model) && resp.RawReader != nil {
-			return ParseThinkingResponse(resp.RawReader, req.Model)
-		}
-
-		// Parse JSON response
-		if resp.Data != nil {
-			return ConvertGoogleToAnthropic(resp.Data, req.Model), nil
-		}
-
-		// Try parsing body as SSE
-		if resp.Body != nil {
+		var out *types.AnthropicResponse
+		switch {
+		case config.IsThinkingModel(req.Model) && resp.RawReader != nil:
+			// Parse SSE response (thinking models return SSE even for non-streaming)
+			out, err = ParseThinkingResponse(resp.RawReader, req.Model, SignatureSession(req))
+		case resp.Data != nil:
+			// Parse JSON response
+			out = ConvertGoogleToAnthropic(resp.Data, req.Model, SignatureSession(req))
+		case resp.Body != nil:
 			// This shouldn't happen normally, but handle it
-			return nil, fmt.Errorf("unexpected response format")
+			err = fmt.Errorf("unexpected response format")
+		default:
+			err = fmt.Errorf("empty response from API")
 		}
-
-		return nil, fmt.Errorf("empty response from API")
+		current.End(err, err == nil && (out == nil || len(out.Content) == 0))
+		if err != nil {
+			return nil, err
+		}
+		LimitToolUse(out, req.ToolChoice)
+		return out, nil
 	}
 
 	return nil, fmt.Errorf("Max retries exceeded")
 }
 
+// markRateLimited records a rate limit on a project of acc and reports whether acc has
+// another project to retry on. Otherwise acc is marked rate-limited and the caller should
+// fail over to the next account.
+func (p *Provider) markRateLimited(ctx context.Context, acc *account.Account, projectID string, resetMs int64, model string) bool {
+	if p.accountManager.MarkProjectRateLimited(acc.Email, projectID, resetMs, model) {
+		return true
+	}
+	provider.TraceFromContext(ctx).RateLimited(acc.Email)
+	return false
+}
+
 // SendMessageStream handles streaming requests.
 // Returns a channel that yields Anthropic-format SSE events.
 func (p *Provider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
@@ -315,16 +424,20 @@ func (p *Provider) SendMessageStream(ctx context.Context, req *types.AnthropicRe
 
 // sendMessageStreamWithFallback is the internal implementation that supports fallback.
 func (p *Provider) sendMessageStreamWithFallback(ctx context.Context, req *types.AnthropicRequest, isFallback bool) (<-chan types.StreamEvent, error) {
-	// Retry loop with account failover (Node parity).
-	maxAttempts := config.MaxRetries
-	if count := p.accountManager.GetAccountCountByProvider("antigravity") + 1; count > maxAttempts {
-		maxAttempts = count
+	if err := ValidateServerTools(req); err != nil {
+		return nil, err
 	}
 
+	// Retry loop with account failover (Node parity).
+	maxAttempts := p.rotation.Policy.Attempts(p.accountManager.GetAccountCountByProvider("antigravity"))
+	var current *provider.Attempt
+	defer func() { current.Abandon(ctx) }()
+
 AttemptLoop:
 	for attempt := 0; attempt < maxAttempts; attempt++ {
+		pickStart := time.Now()
 		// Pick next available account using round-robin selection
-		acc := p.accountManager.PickNextByProvider("antigravity", req.Model)
+		acc := p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 
 		// Handle all accounts rate-limited
 		if acc == nil && p.accountManager.IsAllRateLimitedByProvider("antigravity", req.Model) {
@@ -349,26 +462,26 @@ AttemptLoop:
 			)
 
 			// Wait for reset and add a small buffer (Node parity).
-			if err := sleepWithContext(ctx, waitDur); err != nil {
+			if err := provider.Sleep(ctx, waitDur); err != nil {
 				return nil, err
 			}
-			if err := sleepWithContext(ctx, config.PostRateLimitBuffer); err != nil {
+			if err := provider.Sleep(ctx, config.PostRateLimitBuffer); err != nil {
 				return nil, err
 			}
 			p.accountManager.ClearExpiredLimits()
-			acc = p.accountManager.PickNextByProvider("antigravity", req.Model)
+			acc = p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 
 			// If still no account after waiting, try optimistic reset (Node parity).
 			if acc == nil {
 				utils.Warn("[Antigravity] No account available after wait, attempting optimistic reset...")
 				p.accountManager.ResetAllRateLimitsByProvider("antigravity")
-				acc = p.accountManager.PickNextByProvider("antigravity", req.Model)
+				acc = p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 			}
 		}
 
 		if acc == nil {
 			// Check if fallback is enabled and available (Node parity).
-			if p.fallback && !isFallback {
+			if p.fallback && !isFallback && provider.FallbackAllowed(ctx) {
 				fallbackModel := config.GetFallbackModel(req.Model)
 				if fallbackModel != "" {
 					utils.Warn("[Antigravity] All accounts exhausted for %s. Attempting fallback to %s (streaming)",
@@ -395,17 +508,17 @@ AttemptLoop:
 			// Treat transient network errors as soft failures and try the next account.
 			if isNetworkError(err) {
 				utils.Warn("[Antigravity] Network error for %s, trying next... (%v)", acc.Email, err)
-				if err := sleepWithContext(ctx, config.NetworkRetryDelay); err != nil {
+				if err := provider.Sleep(ctx, p.rotation.Policy.Delay(attempt)); err != nil {
 					return nil, err
 				}
-				p.accountManager.PickNextByProvider("antigravity", req.Model)
+				p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 				continue
 			}
 			return nil, fmt.Errorf("failed to get token: %w", err)
 		}
 
 		// Get project ID
-		projectID, err := p.accountManager.GetProjectForAccount(acc, token)
+		projectID, err := p.accountManager.GetProjectForModel(acc, token, req.Model)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get project: %w", err)
 		}
@@ -423,15 +536,30 @@ AttemptLoop:
 
 		var (
 			lastErr       error
+			lastAuthErr   error
 			lastRateLimit *RateLimitError
 		)
+		current = p.rotation.Attempt(acc, req.Model)
+		current.Begin(ctx)
+		tracing.RecordSpan(ctx, "account.pick", pickStart, tracing.String("account", acc.Email), tracing.Int("attempt", attempt+1))
+		// cancelled ends the attempt when the client went away, without trying other
+		// endpoints or accounts.
+		cancelled := func(err error) (<-chan types.StreamEvent, error) {
+			current.End(err, false)
+			return nil, err
+		}
 
 		// Try each endpoint for streaming (Node parity).
 		for _, endpoint := range p.client.endpoints {
-			resp, err := p.client.doSingleRequest(ctx, endpoint, opts)
+			resp, err := p.client.doSingleRequest(acc.RequestContext(ctx), endpoint, opts)
 			if err != nil {
+				if ctx.Err() != nil {
+					return cancelled(ctx.Err())
+				}
+
 				// Auth error - clear caches and try next endpoint (Node parity).
 				if isHTTPStatus(err, http.StatusUnauthorized) {
+					lastAuthErr = err
 					p.accountManager.ClearTokenCache(acc.Email)
 					p.accountManager.ClearProjectCache(acc.Email)
 					continue
@@ -450,9 +578,9 @@ AttemptLoop:
 				lastErr = err
 
 				// For 5xx errors, wait briefly before trying the next endpoint (Node parity).
-				if status, ok := getHTTPStatus(err); ok && status >= 500 {
-					if sleepErr := sleepWithContext(ctx, config.NetworkRetryDelay); sleepErr != nil {
-						return nil, sleepErr
+				if status, ok := getHTTPStatus(err); ok && p.rotation.Policy.IsRetryableStatus(status) {
+					if sleepErr := provider.Sleep(ctx, p.rotation.Policy.Delay(attempt)); sleepErr != nil {
+						return cancelled(sleepErr)
 					}
 				}
 				continue
@@ -467,38 +595,56 @@ AttemptLoop:
 			currentResp := resp
 			for emptyRetries := 0; emptyRetries <= config.MaxEmptyResponseRetries; emptyRetries++ {
 				parser := NewStreamingParser(currentResp.RawReader, req.Model)
+				parser.SetToolChoice(req.ToolChoice)
+				parser.SetSession(SignatureSession(req))
 				internalEvents, internalErrs := parser.StreamEvents()
 
 				// Wait for first event. If the stream is empty, the channel will close without emitting.
-				var first StreamEvent
+				var first types.StreamEvent
 				var ok bool
 				select {
 				case first, ok = <-internalEvents:
 				case <-ctx.Done():
-					return nil, ctx.Err()
+					return cancelled(ctx.Err())
 				}
 
 				if ok {
 					outCh := make(chan types.StreamEvent, 100)
-					go func(firstEvt StreamEvent, rest <-chan StreamEvent, done <-chan error) {
+					tracker := account.NewStreamTracker(acc.Email, "antigravity", req.Model, current.Start())
+					// The stream reports its result through the tracker when it ends.
+					current.Detach()
+					go func(first types.StreamEvent, rest <-chan types.StreamEvent, done <-chan error) {
 						defer close(outCh)
 
+						tracker.Observe(first)
 						select {
-						case outCh <- convertToTypesStreamEvent(firstEvt):
+						case outCh <- first:
 						case <-ctx.Done():
+							p.accountManager.ReportResult(tracker.Result(ctx.Err()))
 							return
 						}
 
 						for evt := range rest {
+							tracker.Observe(evt)
 							select {
-							case outCh <- convertToTypesStreamEvent(evt):
+							case outCh <- evt:
 							case <-ctx.Done():
+								p.accountManager.ReportResult(tracker.Result(ctx.Err()))
 								return
 							}
 						}
 
-						// Ensure parser goroutine can complete.
-						_ = <-done
+						// Ensure parser goroutine can complete. A parse error truncated the
+						// message, so the caller gets an error event instead of message_stop.
+						err := <-done
+						p.accountManager.ReportResult(tracker.Result(err))
+						if err != nil && ctx.Err() == nil {
+							utils.Error("[Antigravity] SSE stream parsing error: %v", err)
+							select {
+							case outCh <- streamErrorEvent(err):
+							case <-ctx.Done():
+							}
+						}
 					}(first, internalEvents, internalErrs)
 
 					return outCh, nil
@@ -510,12 +656,13 @@ AttemptLoop:
 				if errors.As(streamErr, &emptyErr) {
 					// Check if we have retries left.
 					if emptyRetries >= config.MaxEmptyResponseRetries {
+						current.End(nil, true)
 						outCh := make(chan types.StreamEvent, 100)
 						go func() {
 							defer close(outCh)
 							for _, evt := range emitEmptyResponseFallback(req.Model) {
 								select {
-								case outCh <- convertToTypesStreamEvent(evt):
+								case outCh <- evt:
 								case <-ctx.Done():
 									return
 								}
@@ -526,34 +673,39 @@ AttemptLoop:
 
 					// Exponential backoff: 500ms, 1000ms, 2000ms (Node parity).
 					backoff := time.Duration(500*(1<<emptyRetries)) * time.Millisecond
-					if sleepErr := sleepWithContext(ctx, backoff); sleepErr != nil {
-						return nil, sleepErr
+					if sleepErr := provider.Sleep(ctx, backoff); sleepErr != nil {
+						return cancelled(sleepErr)
 					}
 
 					// Refetch the response from the SAME endpoint (Node parity).
-					retryResp, retryErr := p.client.doSingleRequest(ctx, endpoint, opts)
+					retryResp, retryErr := p.client.doSingleRequest(acc.RequestContext(ctx), endpoint, opts)
 					if retryErr != nil {
 						// Rate limit on retry - mark and switch accounts.
 						var rateLimitErr *RateLimitError
 						if errors.As(retryErr, &rateLimitErr) {
-							p.accountManager.MarkRateLimited(acc.Email, rateLimitErr.ResetMs, req.Model)
+							current.End(retryErr, false)
+							if p.markRateLimited(ctx, acc, projectID, rateLimitErr.ResetMs, req.Model) {
+								ctx = account.WithPreferredAccount(ctx, acc.Email)
+								attempt--
+							}
 							continue AttemptLoop
 						}
 
 						// Auth error on retry - clear caches and switch accounts.
 						if isHTTPStatus(retryErr, http.StatusUnauthorized) {
+							current.End(retryErr, false)
 							p.accountManager.ClearTokenCache(acc.Email)
 							p.accountManager.ClearProjectCache(acc.Email)
 							continue AttemptLoop
 						}
 
 						// For 5xx errors, don't pass to streamer - retry without consuming an empty retry.
-						if status, ok := getHTTPStatus(retryErr); ok && status >= 500 {
+						if status, ok := getHTTPStatus(retryErr); ok && p.rotation.Policy.IsRetryableStatus(status) {
 							emptyRetries-- // Compensate for loop increment (Node parity).
-							if sleepErr := sleepWithContext(ctx, config.NetworkRetryDelay); sleepErr != nil {
-								return nil, sleepErr
+							if sleepErr := provider.Sleep(ctx, p.rotation.Policy.Delay(attempt)); sleepErr != nil {
+								return cancelled(sleepErr)
 							}
-							retryResp2, retryErr2 := p.client.doSingleRequest(ctx, endpoint, opts)
+							retryResp2, retryErr2 := p.client.doSingleRequest(acc.RequestContext(ctx), endpoint, opts)
 							if retryErr2 == nil && retryResp2 != nil && retryResp2.RawReader != nil {
 								currentResp = retryResp2
 								continue
@@ -584,25 +736,33 @@ AttemptLoop:
 
 		// If all endpoints failed for this account.
 		if lastRateLimit != nil && lastErr == nil {
-			p.accountManager.MarkRateLimited(acc.Email, lastRateLimit.ResetMs, req.Model)
+			current.End(lastRateLimit, false)
+			if p.markRateLimited(ctx, acc, projectID, lastRateLimit.ResetMs, req.Model) {
+				// Retry on the account's next project without using up an attempt.
+				ctx = account.WithPreferredAccount(ctx, acc.Email)
+				attempt--
+			}
 			continue
 		}
 		if lastErr != nil {
-			// Treat 5xx as a soft failure for this account and try the next.
-			if status, ok := getHTTPStatus(lastErr); ok && status >= 500 {
-				p.accountManager.PickNextByProvider("antigravity", req.Model)
+			current.End(lastErr, false)
+			// Treat retryable statuses (default: 5xx) as a soft failure for this account and try the next.
+			if status, ok := getHTTPStatus(lastErr); ok && p.rotation.Policy.IsRetryableStatus(status) {
+				p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 				continue
 			}
 			// Treat transient network errors as soft failures and try the next.
 			if isNetworkError(lastErr) {
-				if sleepErr := sleepWithContext(ctx, 1*time.Second); sleepErr != nil {
+				if sleepErr := provider.Sleep(ctx, p.rotation.Policy.Delay(attempt)); sleepErr != nil {
 					return nil, sleepErr
 				}
-				p.accountManager.PickNextByProvider("antigravity", req.Model)
+				p.accountManager.PickNextByProviderContext(ctx, "antigravity", req.Model)
 				continue
 			}
 			return nil, lastErr
 		}
+		// Every endpoint rejected the token; the caches are cleared for the next attempt.
+		current.End(lastAuthErr, false)
 	}
 
 	return nil, fmt.Errorf("Max retries exceeded")
@@ -613,13 +773,12 @@ func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGeneration
 	model := req.Model
 
 	// Retry loop with account failover (same pattern as SendMessage)
-	maxAttempts := config.MaxRetries
-	if accountCount := p.accountManager.GetAccountCountByProvider("antigravity") + 1; accountCount > maxAttempts {
-		maxAttempts = accountCount
-	}
+	maxAttempts := p.rotation.Policy.Attempts(p.accountManager.GetAccountCountByProvider("antigravity"))
+	var current *provider.Attempt
+	defer func() { current.Abandon(ctx) }()
 
 	for attempt := 0; attempt < maxAttempts; attempt++ {
-		acc := p.accountManager.PickNextByProvider("antigravity", model)
+		acc := p.accountManager.PickNextByProviderContext(ctx, "antigravity", model)
 
 		// Handle all accounts rate-limited
 		if acc == nil && p.accountManager.IsAllRateLimitedByProvider("antigravity", model) {
@@ -642,19 +801,19 @@ func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGeneration
 				utils.FormatDuration(waitDur),
 			)
 
-			if err := sleepWithContext(ctx, waitDur); err != nil {
+			if err := provider.Sleep(ctx, waitDur); err != nil {
 				return nil, err
 			}
-			if err := sleepWithContext(ctx, config.PostRateLimitBuffer); err != nil {
+			if err := provider.Sleep(ctx, config.PostRateLimitBuffer); err != nil {
 				return nil, err
 			}
 			p.accountManager.ClearExpiredLimits()
-			acc = p.accountManager.PickNextByProvider("antigravity", model)
+			acc = p.accountManager.PickNextByProviderContext(ctx, "antigravity", model)
 
 			if acc == nil {
 				utils.Warn("[Antigravity] No account available after wait, attempting optimistic reset...")
 				p.accountManager.ResetAllRateLimitsByProvider("antigravity")
-				acc = p.accountManager.PickNextByProvider("antigravity", model)
+				acc = p.accountManager.PickNextByProviderContext(ctx, "antigravity", model)
 			}
 		}
 
@@ -671,17 +830,17 @@ func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGeneration
 			}
 			if isNetworkError(err) {
 				utils.Warn("[Antigravity] Network error for %s, trying next... (%v)", acc.Email, err)
-				if err := sleepWithContext(ctx, config.NetworkRetryDelay); err != nil {
+				if err := provider.Sleep(ctx, p.rotation.Policy.Delay(attempt)); err != nil {
 					return nil, err
 				}
-				p.accountManager.PickNextByProvider("antigravity", model)
+				p.accountManager.PickNextByProviderContext(ctx, "antigravity", model)
 				continue
 			}
 			return nil, fmt.Errorf("failed to get token: %w", err)
 		}
 
 		// Get project ID
-		projectID, err := p.accountManager.GetProjectForAccount(acc, token)
+		projectID, err := p.accountManager.GetProjectForModel(acc, token, model)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get project: %w", err)
 		}
@@ -690,7 +849,9 @@ func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGeneration
 		payload := ConvertImageRequestToGoogle(req, projectID)
 
 		// Send request (non-streaming for image generation)
-		resp, err := p.client.DoRequest(ctx, RequestOptions{
+		current = p.rotation.Attempt(acc, model)
+		current.Begin(ctx)
+		resp, err := p.client.DoRequest(acc.RequestContext(ctx), RequestOptions{
 			Token:     token,
 			ProjectID: projectID,
 			Model:     model,
@@ -699,9 +860,20 @@ func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGeneration
 		})
 
 		if err != nil {
+			current.End(err, false)
+
+			// The client went away; don't move on to another account.
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
 			var rateLimitErr *RateLimitError
 			if errors.As(err, &rateLimitErr) {
-				p.accountManager.MarkRateLimited(acc.Email, rateLimitErr.ResetMs, model)
+				if p.markRateLimited(ctx, acc, projectID, rateLimitErr.ResetMs, model) {
+					ctx = account.WithPreferredAccount(ctx, acc.Email)
+					attempt--
+					continue
+				}
 				utils.Info("[Antigravity] Account %s rate-limited for image generation, trying next...", acc.Email)
 				continue
 			}
@@ -713,18 +885,18 @@ func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGeneration
 				continue
 			}
 
-			if status, ok := getHTTPStatus(err); ok && status >= 500 {
+			if status, ok := getHTTPStatus(err); ok && p.rotation.Policy.IsRetryableStatus(status) {
 				utils.Warn("[Antigravity] Account %s failed with %d error, trying next...", acc.Email, status)
-				p.accountManager.PickNextByProvider("antigravity", model)
+				p.accountManager.PickNextByProviderContext(ctx, "antigravity", model)
 				continue
 			}
 
 			if isNetworkError(err) {
 				utils.Warn("[Antigravity] Network error for %s, trying next... (%v)", acc.Email, err)
-				if err := sleepWithContext(ctx, config.NetworkRetryDelay); err != nil {
+				if err := provider.Sleep(ctx, p.rotation.Policy.Delay(attempt)); err != nil {
 					return nil, err
 				}
-				p.accountManager.PickNextByProvider("antigravity", model)
+				p.accountManager.PickNextByProviderContext(ctx, "antigravity", model)
 				continue
 			}
 
@@ -732,26 +904,27 @@ func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGeneration
 		}
 
 		// Parse JSON response
-		if resp.Data != nil {
-			return ConvertGoogleImageResponse(resp.Data, model)
+		if resp.Data == nil {
+			err = fmt.Errorf("empty response from image generation API")
+			current.End(err, true)
+			return nil, err
 		}
-
-		return nil, fmt.Errorf("empty response from image generation API")
+		out, err := ConvertGoogleImageResponse(resp.Data, model)
+		current.End(err, err == nil && len(out.Images) == 0)
+		return out, err
 	}
 
 	return nil, fmt.Errorf("Max retries exceeded for image generation")
 }
 
-func sleepWithContext(ctx context.Context, d time.Duration) error {
-	if d <= 0 {
-		return nil
-	}
-	select {
-	case <-time.After(d):
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
+// classifyError describes an Antigravity API error for account selection.
+func classifyError(err error) provider.Failure {
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return provider.Failure{StatusCode: http.StatusTooManyRequests, RateLimited: true, ResetMs: rateLimitErr.ResetMs}
 	}
+	status, _ := getHTTPStatus(err)
+	return provider.Failure{StatusCode: status}
 }
 
 func getHTTPStatus(err error) (int, bool) {
@@ -798,11 +971,16 @@ func isNetworkError(err error) bool {
 		strings.Contains(msg, "temporary failure")
 }
 
-// convertToTypesStreamEvent converts internal SSE events to types.StreamEvent.
-func convertToTypesStreamEvent(evt StreamEvent) types.StreamEvent {
+// streamErrorEvent returns the error event for an error that cut a stream short.
+func streamErrorEvent(err error) types.StreamEvent {
+	ae := merrors.StreamError("", err.Error())
+	var upstream *upstreamStreamError
+	if errors.As(err, &upstream) {
+		ae = upstream.AnthropicError()
+	}
 	return types.StreamEvent{
-		Type: evt.Type,
-		Raw:  evt.Data,
+		Type:  "error",
+		Error: &types.ErrorDetail{Type: string(ae.Detail.Type), Message: ae.Detail.Message},
 	}
 }
 
@@ -812,10 +990,14 @@ func (p *Provider) buildPayload(req *types.AnthropicRequest, projectID string) m
 	// Use stable session ID derived from first user message for cache continuity
 	googleReq["sessionId"] = deriveSessionID(req)
 
-	// Build system instruction with Antigravity identity override
-	systemParts := []interface{}{
-		map[string]interface{}{"text": config.AntigravitySystemInstruction},
-		map[string]interface{}{"text": fmt.Sprintf("Please ignore the following [ignore]%s[/ignore]", config.AntigravitySystemInstruction)},
+	// Build system instruction with the Antigravity identity override (ANTIGRAVITY_IDENTITY_OVERRIDE).
+	// Operator prompts (SYSTEM_PROMPT_*) are already part of the request's system prompt.
+	var systemParts []interface{}
+	if config.GetEnvBool("ANTIGRAVITY_IDENTITY_OVERRIDE", true) {
+		systemParts = append(systemParts,
+			map[string]interface{}{"text": config.AntigravitySystemInstruction},
+			map[string]interface{}{"text": fmt.Sprintf("Please ignore the following [ignore]%s[/ignore]", config.AntigravitySystemInstruction)},
+		)
 	}
 
 	// Append any existing system instructions
@@ -831,18 +1013,23 @@ func (p *Provider) buildPayload(req *types.AnthropicRequest, projectID string) m
 		}
 	}
 
-	googleReq["systemInstruction"] = map[string]interface{}{
-		"role":  "user",
-		"parts": systemParts,
+	if len(systemParts) > 0 {
+		googleReq["systemInstruction"] = map[string]interface{}{
+			"role":  "user",
+			"parts": systemParts,
+		}
+	} else {
+		delete(googleReq, "systemInstruction")
 	}
 
+	info := config.GetAntigravityClientInfo()
 	return map[string]interface{}{
 		"project":     projectID,
 		"model":       req.Model,
 		"request":     googleReq,
-		"userAgent":   "antigravity",
-		"requestType": "agent",
-		"requestId":   fmt.Sprintf("agent-%s", uuid.NewString()), // Node parity
+		"userAgent":   info.UserAgent,
+		"requestType": info.RequestType,
+		"requestId":   info.RequestIDPrefix + uuid.NewString(), // Node parity
 	}
 }
 
@@ -936,95 +1123,105 @@ func (p *Provider) ListModels(ctx context.Context) (*types.ModelsResponse, error
 }
 
 // GetStatus returns provider health and quota information (implements Provider interface).
-// Also updates soft limit status based on current quota levels.
+// Also updates soft limit status based on current quota levels. Accounts are queried in
+// parallel, and quotas fetched within config.QuotaCacheTTL (by this or the health endpoint)
+// are reused.
 func (p *Provider) GetStatus(ctx context.Context) (*types.ProviderStatus, error) {
 	allAccounts := p.accountManager.GetAllAccountsByProvider("antigravity")
 	accounts := make([]types.AccountStatus, len(allAccounts))
 
-	overallStatus := "ok"
-
-	for i, acc := range allAccounts {
-		status := types.AccountStatus{
-			Email:    acc.Email,
-			Status:   "ok",
-			LastUsed: acc.LastUsed,
-			Limits:   make(map[string]types.ModelQuota),
-		}
+	var wg sync.WaitGroup
+	for i := range allAccounts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			accounts[i] = p.accountStatus(ctx, &allAccounts[i])
+		}(i)
+	}
+	wg.Wait()
 
-		if acc.IsInvalid {
-			status.Status = "invalid"
-			status.Error = string(acc.InvalidReason)
+	overallStatus := "ok"
+	for _, status := range accounts {
+		if status.Status != "ok" {
 			overallStatus = "degraded"
-			accounts[i] = status
-			continue
 		}
+	}
 
-		// Fetch real quotas from API
-		token, err := p.accountManager.GetTokenForAccount(&acc)
-		if err != nil {
-			status.Status = "error"
-			status.Error = err.Error()
-			overallStatus = "degraded"
-			accounts[i] = status
-			continue
-		}
+	return &types.ProviderStatus{
+		Name:      "antigravity",
+		Status:    overallStatus,
+		Accounts:  accounts,
+		Timestamp: time.Now(),
+	}, nil
+}
 
-		modelsResp, err := p.client.FetchAvailableModels(ctx, token)
-		if err != nil {
-			utils.Warn("[Antigravity] Failed to fetch quotas for %s: %v", acc.Email, err)
-			// Fall back to locally tracked rate limits
-			status.Limits = p.getLocalQuotas(&acc)
-		} else {
-			// Parse real quotas from API response
-			for modelID, modelData := range modelsResp.Models {
-				// Only include Claude and Gemini models
-				family := config.GetModelFamily(modelID)
-				if family != config.ModelFamilyClaude && family != config.ModelFamilyGemini {
-					continue
-				}
+// accountStatus returns the status and quotas of one account for GetStatus.
+func (p *Provider) accountStatus(ctx context.Context, acc *account.Account) types.AccountStatus {
+	status := types.AccountStatus{
+		Email:    acc.Email,
+		Status:   "ok",
+		LastUsed: acc.LastUsed,
+		Limits:   make(map[string]types.ModelQuota),
+	}
 
-				quota := types.ModelQuota{
-					RemainingFraction:   1.0, // Default to 100%
-					RemainingPercentage: 100,
-				}
+	if acc.IsInvalid {
+		status.Status = "invalid"
+		status.Error = string(acc.InvalidReason)
+		return status
+	}
 
-				if modelData.QuotaInfo != nil {
-					if modelData.QuotaInfo.RemainingFraction != nil {
-						quota.RemainingFraction = *modelData.QuotaInfo.RemainingFraction
-						quota.RemainingPercentage = int(quota.RemainingFraction * 100)
+	// Fetch real quotas from API
+	token, err := p.accountManager.GetTokenForAccount(acc)
+	if err != nil {
+		status.Status = "error"
+		status.Error = err.Error()
+		return status
+	}
 
-						// Update soft limit status for this account/model (no persist for status checks)
-						p.accountManager.UpdateSoftLimitStatusNoPersist(acc.Email, modelID, quota.RemainingFraction)
-					}
-					if modelData.QuotaInfo.ResetTime != nil {
-						if t, err := time.Parse(time.RFC3339, *modelData.QuotaInfo.ResetTime); err == nil {
-							quota.ResetTime = &t
-						}
-					}
-				}
+	modelsResp, err := p.client.FetchAccountQuotas(acc.RequestContext(ctx), acc.Email, token)
+	if err != nil {
+		utils.Warn("[Antigravity] Failed to fetch quotas for %s: %v", acc.Email, err)
+		// Fall back to locally tracked rate limits
+		status.Limits = p.getLocalQuotas(acc)
+		return status
+	}
+
+	// Parse real quotas from API response
+	for modelID, modelData := range modelsResp.Models {
+		// Only include Claude and Gemini models
+		family := config.GetModelFamily(modelID)
+		if family != config.ModelFamilyClaude && family != config.ModelFamilyGemini {
+			continue
+		}
+
+		quota := types.ModelQuota{
+			RemainingFraction:   1.0, // Default to 100%
+			RemainingPercentage: 100,
+		}
 
-				status.Limits[modelID] = quota
+		if modelData.QuotaInfo != nil {
+			if modelData.QuotaInfo.RemainingFraction != nil {
+				quota.RemainingFraction = *modelData.QuotaInfo.RemainingFraction
+				quota.RemainingPercentage = int(quota.RemainingFraction * 100)
 
-				// Check if rate-limited
-				if quota.RemainingFraction == 0 {
-					status.Status = "rate-limited"
+				// Update soft limit status for this account/model (no persist for status checks)
+				p.accountManager.UpdateSoftLimitStatusNoPersist(acc.Email, modelID, quota.RemainingFraction)
+			}
+			if modelData.QuotaInfo.ResetTime != nil {
+				if t, err := time.Parse(time.RFC3339, *modelData.QuotaInfo.ResetTime); err == nil {
+					quota.ResetTime = &t
 				}
 			}
 		}
 
-		if status.Status != "ok" {
-			overallStatus = "degraded"
-		}
+		status.Limits[modelID] = quota
 
-		accounts[i] = status
+		// Check if rate-limited
+		if quota.RemainingFraction == 0 {
+			status.Status = "rate-limited"
+		}
 	}
-
-	return &types.ProviderStatus{
-		Name:      "antigravity",
-		Status:    overallStatus,
-		Accounts:  accounts,
-		Timestamp: time.Now(),
-	}, nil
+	return status
 }
 
 // getLocalQuotas returns quotas based on locally tracked rate limits.
@@ -1064,12 +1261,3 @@ func (p *Provider) getLocalQuotas(acc *account.Account) map[string]types.ModelQu
 func (p *Provider) GetAccountLimits() map[string]interface{} {
 	return p.accountManager.GetStatus()
 }
-
-// FormatSSEEvent formats a StreamEvent as an SSE message.
-func FormatSSEEvent(evt StreamEvent) ([]byte, error) {
-	data, err := json.Marshal(evt.Data)
-	if err != nil {
-		return nil, err
-	}
-	return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", evt.Type, string(data))), nil
-}
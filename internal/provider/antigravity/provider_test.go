@@ -3,13 +3,18 @@ package antigravity
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
 
 func setupTestAccountManager(t *testing.T, accounts []account.Account) *account.Manager {
@@ -222,3 +227,199 @@ func TestProvider_Models_ThreadSafe(t *testing.T) {
 		<-done
 	}
 }
+
+func TestProvider_Initialize_ParallelRecordsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer bad-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"forbidden"}`))
+			return
+		}
+		// Respond slowly so the failing account is observed first.
+		time.Sleep(50 * time.Millisecond)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"models": map[string]interface{}{
+				"claude-sonnet-4-5": map[string]interface{}{"displayName": "Claude Sonnet 4.5"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	accounts := []account.Account{
+		{Email: "bad@example.com", Provider: "antigravity", Source: "manual", APIKey: "bad-token"},
+		{Email: "good@example.com", Provider: "antigravity", Source: "manual", APIKey: "good-token"},
+	}
+	mgr := setupTestAccountManager(t, accounts)
+
+	p := NewProvider(mgr, false)
+	p.client.endpoints = []string{server.URL}
+
+	if err := p.Initialize(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !p.SupportsModel("claude-sonnet-4-5") {
+		t.Error("expected models from the working account")
+	}
+
+	failures := p.InitFailures()
+	if _, ok := failures["bad@example.com"]; !ok {
+		t.Errorf("expected failure recorded for bad@example.com, got %v", failures)
+	}
+	if _, ok := failures["good@example.com"]; ok {
+		t.Errorf("did not expect failure for good@example.com")
+	}
+}
+
+func TestProvider_BuildPayload_IdentityOverride(t *testing.T) {
+	p := NewProvider(nil, false)
+	req := &types.AnthropicRequest{
+		Model:    "claude-sonnet-4-5",
+		System:   json.RawMessage(`"operator prompt"`),
+		Messages: []types.Message{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+	}
+	parts := func() []interface{} {
+		googleReq := p.buildPayload(req, "project")["request"].(map[string]interface{})
+		si, ok := googleReq["systemInstruction"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		return si["parts"].([]interface{})
+	}
+
+	if got := parts(); len(got) != 3 || got[0].(map[string]interface{})["text"] != config.AntigravitySystemInstruction {
+		t.Errorf("expected the identity before the system prompt by default, got %v", got)
+	}
+
+	t.Setenv("ANTIGRAVITY_IDENTITY_OVERRIDE", "false")
+	if got := parts(); len(got) != 1 || got[0].(map[string]interface{})["text"] != "operator prompt" {
+		t.Errorf("expected only the system prompt without the override, got %v", got)
+	}
+}
+
+func TestProvider_SendMessageStream_ClientCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// An empty stream is retried after a backoff; the client disconnects meanwhile.
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.AfterFunc(50*time.Millisecond, cancel)
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer server.Close()
+
+	mgr := setupTestAccountManager(t, []account.Account{
+		{Email: "a@example.com", Provider: "antigravity", Source: "manual", APIKey: "t", ProjectID: "p"},
+		{Email: "b@example.com", Provider: "antigravity", Source: "manual", APIKey: "t", ProjectID: "p"},
+	})
+	p := NewProvider(mgr, false)
+	p.client.endpoints = []string{server.URL, server.URL}
+
+	req := &types.AnthropicRequest{
+		Model:    "gemini-3-flash",
+		Messages: []types.Message{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+	}
+	if _, err := p.SendMessageStream(ctx, req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SendMessageStream error = %v, want context.Canceled", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("upstream hit %d times, want 1 (no other endpoints or accounts after the cancel)", got)
+	}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if st, _ := mgr.GetDrainStatus(email); st.InFlight != 0 {
+			t.Errorf("%s has %d requests in flight, want 0", email, st.InFlight)
+		}
+	}
+}
+
+func TestProvider_EndsRequests(t *testing.T) {
+	var mgr *account.Manager
+	var busy atomic.Int32 // Requests the upstream saw while the account had one in flight
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if st, _ := mgr.GetDrainStatus("a@example.com"); st.InFlight == 1 {
+			busy.Add(1)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":{"status":"UNAUTHENTICATED","message":"bad token"}}`))
+	}))
+	defer server.Close()
+
+	mgr = setupTestAccountManager(t, []account.Account{
+		{Email: "a@example.com", Provider: "antigravity", Source: "manual", APIKey: "t", ProjectID: "p"},
+	})
+	p := NewProvider(mgr, false)
+	p.client.endpoints = []string{server.URL, server.URL}
+
+	req := &types.AnthropicRequest{
+		Model:    "gemini-3-flash",
+		Messages: []types.Message{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+	}
+	calls := map[string]func() error{
+		"stream": func() error { _, err := p.SendMessageStream(context.Background(), req); return err },
+		"image": func() error {
+			_, err := p.GenerateImage(context.Background(), &types.ImageGenerationRequest{Model: "gemini-3-pro-image", Prompt: "a cat"})
+			return err
+		},
+	}
+	for name, call := range calls {
+		busy.Store(0)
+		if err := call(); err == nil {
+			t.Errorf("%s: expected an error when every endpoint rejects the token", name)
+		}
+		if busy.Load() == 0 {
+			t.Errorf("%s: expected the upstream requests to be counted in flight", name)
+		}
+		if st, _ := mgr.GetDrainStatus("a@example.com"); st.InFlight != 0 {
+			t.Errorf("%s: %d requests still in flight, want 0", name, st.InFlight)
+		}
+	}
+}
+
+func TestProvider_SendMessage_RotatesProjects(t *testing.T) {
+	// p1 is exhausted; p2 of the same account has quota.
+	var projects []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Project string `json:"project"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		projects = append(projects, body.Project)
+		if body.Project == "p1" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"status":"RESOURCE_EXHAUSTED","message":"quota exhausted"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"response":{"candidates":[{"content":{"parts":[{"text":"pong"}]},"finishReason":"STOP"}]}}`))
+	}))
+	defer server.Close()
+
+	mgr := setupTestAccountManager(t, []account.Account{
+		{Email: "a@example.com", Provider: "antigravity", Source: "manual", APIKey: "t", ProjectID: "p1", ProjectIDs: []string{"p2"}},
+		{Email: "b@example.com", Provider: "antigravity", Source: "manual", APIKey: "t", ProjectID: "p3"},
+	})
+	p := NewProvider(mgr, false)
+	p.client.endpoints = []string{server.URL}
+
+	req := &types.AnthropicRequest{
+		Model:    "gemini-2.5-flash",
+		Messages: []types.Message{{Role: "user", Content: json.RawMessage(`"ping"`)}},
+	}
+	ctx := account.WithAccount(context.Background(), "a@example.com")
+	if _, err := p.SendMessage(ctx, req); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if len(projects) != 2 || projects[0] != "p1" || projects[1] != "p2" {
+		t.Fatalf("projects tried = %v, want [p1 p2]", projects)
+	}
+	if acc, _ := mgr.GetAccount("a@example.com"); acc.ModelRateLimits["gemini-2.5-flash"].IsRateLimited {
+		t.Error("the account must not be rate-limited while it has a project with quota")
+	}
+
+	// The next request goes straight to p2.
+	projects = nil
+	if _, err := p.SendMessage(ctx, req); err != nil || len(projects) != 1 || projects[0] != "p2" {
+		t.Fatalf("second request: projects = %v, err = %v; want [p2]", projects, err)
+	}
+}
@@ -0,0 +1,86 @@
+package antigravity
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// quotas caches fetchAvailableModels responses per account. It is shared by every Client,
+// so the health endpoint and Provider.GetStatus polling together cost one upstream call per
+// account per TTL.
+var quotas = newQuotaCache(config.QuotaCacheTTL)
+
+// quotaCache holds the last successful quota fetch of each account. Concurrent fetches for
+// an account whose entry is stale share one upstream call; errors are not cached.
+type quotaCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	now      func() time.Time
+	entries  map[string]quotaEntry
+	inflight map[string]*quotaFetch
+}
+
+type quotaEntry struct {
+	resp      *AvailableModelsResponse
+	fetchedAt time.Time
+}
+
+type quotaFetch struct {
+	done chan struct{}
+	resp *AvailableModelsResponse
+	err  error
+}
+
+func newQuotaCache(ttl time.Duration) *quotaCache {
+	return &quotaCache{
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]quotaEntry),
+		inflight: make(map[string]*quotaFetch),
+	}
+}
+
+// get returns the cached response for the account, calling fetch if there is none younger
+// than the TTL. Waiting for another caller's fetch stops when ctx is done.
+func (q *quotaCache) get(ctx context.Context, email string, fetch func() (*AvailableModelsResponse, error)) (*AvailableModelsResponse, error) {
+	q.mu.Lock()
+	if e, ok := q.entries[email]; ok && q.now().Sub(e.fetchedAt) < q.ttl {
+		q.mu.Unlock()
+		return e.resp, nil
+	}
+	if f, ok := q.inflight[email]; ok {
+		q.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.resp, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f := &quotaFetch{done: make(chan struct{})}
+	q.inflight[email] = f
+	q.mu.Unlock()
+
+	f.resp, f.err = fetch()
+
+	q.mu.Lock()
+	delete(q.inflight, email)
+	if f.err == nil {
+		q.entries[email] = quotaEntry{resp: f.resp, fetchedAt: q.now()}
+	}
+	q.mu.Unlock()
+	close(f.done)
+	return f.resp, f.err
+}
+
+// FetchAccountQuotas is FetchAvailableModels for the given account, reusing a response
+// fetched within config.QuotaCacheTTL. Use it for status reporting; callers that need
+// fresh data (e.g. model discovery) call FetchAvailableModels directly.
+func (c *Client) FetchAccountQuotas(ctx context.Context, email, token string) (*AvailableModelsResponse, error) {
+	return quotas.get(ctx, email, func() (*AvailableModelsResponse, error) {
+		return c.FetchAvailableModels(ctx, token)
+	})
+}
@@ -0,0 +1,96 @@
+package antigravity
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQuotaCache_ReusesFreshResponses(t *testing.T) {
+	now := time.Now()
+	q := newQuotaCache(30 * time.Second)
+	q.now = func() time.Time { return now }
+
+	var fetches atomic.Int32
+	fetch := func() (*AvailableModelsResponse, error) {
+		fetches.Add(1)
+		return &AvailableModelsResponse{}, nil
+	}
+	get := func(email string) {
+		t.Helper()
+		if _, err := q.get(context.Background(), email, fetch); err != nil {
+			t.Fatalf("get(%s): %v", email, err)
+		}
+	}
+
+	get("a@example.com")
+	get("a@example.com")
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("fetches within the TTL = %d, want 1", got)
+	}
+	get("b@example.com") // accounts are cached separately
+	if got := fetches.Load(); got != 2 {
+		t.Fatalf("fetches for a second account = %d, want 2", got)
+	}
+
+	now = now.Add(30 * time.Second)
+	get("a@example.com")
+	if got := fetches.Load(); got != 3 {
+		t.Fatalf("fetches after the TTL = %d, want 3", got)
+	}
+}
+
+func TestQuotaCache_ErrorsAreNotCached(t *testing.T) {
+	q := newQuotaCache(time.Minute)
+	calls := 0
+	fetch := func() (*AvailableModelsResponse, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("upstream unavailable")
+		}
+		return &AvailableModelsResponse{}, nil
+	}
+
+	if _, err := q.get(context.Background(), "a@example.com", fetch); err == nil {
+		t.Fatal("expected the first fetch's error")
+	}
+	if _, err := q.get(context.Background(), "a@example.com", fetch); err != nil {
+		t.Fatalf("second get: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("fetches = %d, want 2", calls)
+	}
+}
+
+func TestQuotaCache_ConcurrentFetchesShareOneCall(t *testing.T) {
+	q := newQuotaCache(time.Minute)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	fetch := func() (*AvailableModelsResponse, error) {
+		fetches.Add(1)
+		<-release
+		return &AvailableModelsResponse{}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := q.get(context.Background(), "a@example.com", fetch); err != nil {
+				t.Errorf("get: %v", err)
+			}
+		}()
+	}
+	// Let the callers reach the cache before the fetch completes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+}
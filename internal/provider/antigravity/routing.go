@@ -0,0 +1,130 @@
+package antigravity
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// Endpoint health is shared by every Client, so message requests, quota fetches and health
+// checks all feed, and benefit from, the same view of each Cloud Code endpoint.
+var (
+	sharedBreakers = newEndpointBreakers()
+	sharedStats    = newEndpointStats()
+)
+
+// endpointStats tracks the rolling latency and error rate of each endpoint to order them
+// per request, so users far from the default region reach the fastest healthy one first.
+type endpointStats struct {
+	mu        sync.Mutex
+	decay     float64
+	unhealthy float64
+	endpoints map[string]*endpointStat
+}
+
+type endpointStat struct {
+	latency   float64 // EWMA of successful response times, in milliseconds; 0 until measured
+	errorRate float64 // EWMA of failures (5xx and connection errors)
+	requests  int64
+	errors    int64
+	lastUsed  time.Time
+}
+
+func newEndpointStats() *endpointStats {
+	return &endpointStats{
+		decay:     config.AntigravityEndpointStatsDecay,
+		unhealthy: config.AntigravityEndpointUnhealthyErrors,
+		endpoints: make(map[string]*endpointStat),
+	}
+}
+
+func (s *endpointStats) get(endpoint string) *endpointStat {
+	st, ok := s.endpoints[endpoint]
+	if !ok {
+		st = &endpointStat{}
+		s.endpoints[endpoint] = st
+	}
+	return st
+}
+
+// record adds a response (or failure) of the endpoint. Latency only counts for responses,
+// as failures can be arbitrarily fast or slow.
+func (s *endpointStats) record(endpoint string, latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.get(endpoint)
+	st.requests++
+	st.lastUsed = time.Now()
+	failure := 0.0
+	if failed {
+		st.errors++
+		failure = 1
+	} else {
+		ms := float64(latency) / float64(time.Millisecond)
+		if st.latency == 0 {
+			st.latency = ms
+		} else {
+			st.latency += s.decay * (ms - st.latency)
+		}
+	}
+	st.errorRate += s.decay * (failure - st.errorRate)
+}
+
+// order returns endpoints in the order to try them: healthy before unhealthy, then
+// unmeasured ones (in configured order, so each gets measured) and then by latency.
+func (s *endpointStats) order(endpoints []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ordered := append([]string(nil), endpoints...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := s.get(ordered[i]), s.get(ordered[j])
+		if ua, ub := a.errorRate >= s.unhealthy, b.errorRate >= s.unhealthy; ua != ub {
+			return ub
+		}
+		return a.latency < b.latency
+	})
+	return ordered
+}
+
+// EndpointStat describes a Cloud Code endpoint for /debug/endpoints.
+type EndpointStat struct {
+	Endpoint  string     `json:"endpoint"`
+	LatencyMs int64      `json:"latencyMs"` // moving average of response times; 0 until measured
+	ErrorRate float64    `json:"errorRate"` // moving average, 0..1
+	Healthy   bool       `json:"healthy"`
+	Breaker   string     `json:"breaker"` // closed, open or half-open
+	Requests  int64      `json:"requests"`
+	Errors    int64      `json:"errors"`
+	LastUsed  *time.Time `json:"lastUsed,omitempty"`
+}
+
+// EndpointStats returns the Cloud Code endpoints in the order the next request tries them,
+// with their latency, error rate and circuit breaker state.
+func EndpointStats() []EndpointStat {
+	ordered := sharedStats.order(config.AntigravityEndpointFallbacks)
+	out := make([]EndpointStat, 0, len(ordered))
+	for _, endpoint := range ordered {
+		sharedStats.mu.Lock()
+		st := *sharedStats.get(endpoint)
+		healthy := st.errorRate < sharedStats.unhealthy
+		sharedStats.mu.Unlock()
+
+		stat := EndpointStat{
+			Endpoint:  endpoint,
+			LatencyMs: int64(st.latency + 0.5),
+			ErrorRate: st.errorRate,
+			Healthy:   healthy,
+			Breaker:   sharedBreakers.state(endpoint).String(),
+			Requests:  st.requests,
+			Errors:    st.errors,
+		}
+		if !st.lastUsed.IsZero() {
+			lastUsed := st.lastUsed.UTC()
+			stat.LastUsed = &lastUsed
+		}
+		out = append(out, stat)
+	}
+	return out
+}
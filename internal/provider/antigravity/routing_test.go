@@ -0,0 +1,51 @@
+package antigravity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEndpointStats_Order(t *testing.T) {
+	s := newEndpointStats()
+	endpoints := []string{"https://daily", "https://prod", "https://eu"}
+
+	if got := s.order(endpoints); !reflect.DeepEqual(got, endpoints) {
+		t.Fatalf("unmeasured order = %v, want configured order %v", got, endpoints)
+	}
+
+	s.record("https://daily", 900*time.Millisecond, false)
+	s.record("https://prod", 300*time.Millisecond, false)
+	// Unmeasured endpoints go first so they get measured.
+	if got, want := s.order(endpoints), []string{"https://eu", "https://prod", "https://daily"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+
+	s.record("https://eu", 100*time.Millisecond, false)
+	if got, want := s.order(endpoints), []string{"https://eu", "https://prod", "https://daily"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("order by latency = %v, want %v", got, want)
+	}
+
+	// A fast endpoint that keeps failing is tried last.
+	for i := 0; i < 4; i++ {
+		s.record("https://eu", 0, true)
+	}
+	if got, want := s.order(endpoints), []string{"https://prod", "https://daily", "https://eu"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("order with an unhealthy endpoint = %v, want %v", got, want)
+	}
+	if st := s.get("https://eu"); st.requests != 5 || st.errors != 4 {
+		t.Errorf("eu requests/errors = %d/%d, want 5/4", st.requests, st.errors)
+	}
+}
+
+func TestEndpointStats_LatencyIsMovingAverage(t *testing.T) {
+	s := newEndpointStats()
+	s.record("https://daily", 100*time.Millisecond, false)
+	s.record("https://daily", 600*time.Millisecond, false)
+	s.record("https://daily", 0, true) // failures don't move the latency
+
+	want := 100 + s.decay*(600-100)
+	if got := s.get("https://daily").latency; got != want {
+		t.Errorf("latency = %v ms, want %v ms", got, want)
+	}
+}
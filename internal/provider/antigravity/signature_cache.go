@@ -2,108 +2,284 @@
 package antigravity
 
 import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
 
+// signatureKey identifies a cached signature: the conversation (session ID) it was issued
+// in, "" if unknown, and its tool_use_id or thinking signature.
+type signatureKey struct {
+	session string
+	id      string
+}
+
 // signatureEntry stores a cached signature with timestamp.
 type signatureEntry struct {
 	signature string
-	timestamp time.Time
+	timestamp time.Time // When it was cached; it expires ttl later
+	lastUsed  time.Time // Last lookup, for LRU eviction
 }
 
 // thinkingSignatureEntry stores a thinking signature with model family.
 type thinkingSignatureEntry struct {
 	modelFamily string
 	timestamp   time.Time
+	lastUsed    time.Time
+}
+
+// used returns when an entry was last cached or looked up.
+func used(timestamp, lastUsed time.Time) time.Time {
+	if lastUsed.After(timestamp) {
+		return lastUsed
+	}
+	return timestamp
+}
+
+// signatureSession indexes the entries of one conversation, so it can be evicted as a whole.
+type signatureSession struct {
+	id       string
+	tools    map[string]struct{}
+	thinking map[string]struct{}
+}
+
+// SignatureStats are the sizes and counters of a SignatureCache.
+type SignatureStats struct {
+	ToolEntries     int
+	ThinkingEntries int
+	Sessions        int
+	Hits            uint64
+	Misses          uint64 // Lookups of unknown or expired signatures
+	Evictions       uint64 // Entries dropped by the size limits, not by expiry
 }
 
 // SignatureCache caches Gemini thoughtSignatures for tool_use blocks.
 // Claude Code strips non-standard fields, so we cache them for restoration.
+//
+// Entries are scoped to the conversation (session ID) they were issued in and are only found
+// by lookups of that conversation. Both the entries and the conversations are bounded, least
+// recently used first, so a long-running server keeps the signatures of active conversations
+// and drops idle ones whole.
 type SignatureCache struct {
-	mu              sync.RWMutex
-	toolSignatures  map[string]signatureEntry         // tool_use_id -> signature
-	thinkingCache   map[string]thinkingSignatureEntry // signature -> model family
+	mu              sync.Mutex
+	toolSignatures  map[signatureKey]signatureEntry         // (session, tool_use_id) -> signature
+	thinkingCache   map[signatureKey]thinkingSignatureEntry // (session, signature) -> model family
+	sessions        map[string]*list.Element                // session ID -> its *signatureSession in lru
+	lru             *list.List                              // Sessions, most recently used first
 	ttl             time.Duration
+	maxEntries      int // per map; 0 means unbounded
+	maxSessions     int // 0 means unbounded
 	minSignatureLen int
+
+	hits, misses, evictions uint64
 }
 
 // NewSignatureCache creates a new SignatureCache with default settings.
 func NewSignatureCache() *SignatureCache {
 	return &SignatureCache{
-		toolSignatures:  make(map[string]signatureEntry),
-		thinkingCache:   make(map[string]thinkingSignatureEntry),
+		toolSignatures:  make(map[signatureKey]signatureEntry),
+		thinkingCache:   make(map[signatureKey]thinkingSignatureEntry),
+		sessions:        make(map[string]*list.Element),
+		lru:             list.New(),
 		ttl:             config.GeminiSignatureCacheTTL,
+		maxEntries:      config.DefaultSignatureCacheMaxEntries,
+		maxSessions:     config.DefaultSignatureCacheMaxSessions,
 		minSignatureLen: config.MinSignatureLength,
 	}
 }
 
-// CacheToolSignature stores a signature for a tool_use_id.
-func (c *SignatureCache) CacheToolSignature(toolUseID, signature string) {
+// SignatureSession returns the conversation the signatures of req are scoped to, or "" when
+// it has no user message. It hashes what stays the same across the turns of a conversation:
+// metadata.user_id (Claude Code puts its session in it), the system prompt and the whole
+// first user message, so conversations that merely open with the same text ("hi") of
+// different clients or prompts don't share signatures.
+func SignatureSession(req *types.AnthropicRequest) string {
+	for _, msg := range req.Messages {
+		if msg.Role != "user" || len(msg.Content) == 0 {
+			continue
+		}
+		var userID string
+		if req.Metadata != nil {
+			userID = req.Metadata.UserID
+		}
+		hash := sha256.New()
+		for _, field := range [][]byte{[]byte(userID), req.System, msg.Content} {
+			// Length-prefixed, so the fields can't run into each other.
+			fmt.Fprintf(hash, "%d:", len(field))
+			hash.Write(field)
+		}
+		return hex.EncodeToString(hash.Sum(nil)[:16])
+	}
+	return ""
+}
+
+// CacheToolSignature stores a signature for a tool_use_id issued in session.
+func (c *SignatureCache) CacheToolSignature(session, toolUseID, signature string) {
 	if toolUseID == "" || signature == "" {
 		return
 	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.toolSignatures[toolUseID] = signatureEntry{
+	c.toolSignatures[signatureKey{session, toolUseID}] = signatureEntry{
 		signature: signature,
 		timestamp: time.Now(),
 	}
+	if s := c.sessionLocked(session); s != nil {
+		s.tools[toolUseID] = struct{}{}
+	}
+	c.enforceLimitsLocked()
 }
 
-// GetToolSignature retrieves a cached signature for a tool_use_id.
+// GetToolSignature retrieves the signature cached for a tool_use_id issued in session.
 // Returns empty string if not found or expired.
-func (c *SignatureCache) GetToolSignature(toolUseID string) string {
+func (c *SignatureCache) GetToolSignature(session, toolUseID string) string {
 	if toolUseID == "" {
 		return ""
 	}
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	entry, ok := c.toolSignatures[toolUseID]
-	if !ok {
-		return ""
-	}
-	if time.Since(entry.timestamp) > c.ttl {
+	key := signatureKey{session, toolUseID}
+	entry, ok := c.toolSignatures[key]
+	if !ok || time.Since(entry.timestamp) > c.ttl {
+		c.misses++
 		return ""
 	}
+	c.hits++
+	entry.lastUsed = time.Now()
+	c.toolSignatures[key] = entry
+	c.sessionLocked(session)
 	return entry.signature
 }
 
-// CacheThinkingSignature stores a thinking signature with its model family.
-func (c *SignatureCache) CacheThinkingSignature(signature, modelFamily string) {
+// CacheThinkingSignature stores a thinking signature issued in session with its model family.
+func (c *SignatureCache) CacheThinkingSignature(session, signature, modelFamily string) {
 	if signature == "" || len(signature) < c.minSignatureLen {
 		return
 	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.thinkingCache[signature] = thinkingSignatureEntry{
+	c.thinkingCache[signatureKey{session, signature}] = thinkingSignatureEntry{
 		modelFamily: modelFamily,
 		timestamp:   time.Now(),
 	}
+	if s := c.sessionLocked(session); s != nil {
+		s.thinking[signature] = struct{}{}
+	}
+	c.enforceLimitsLocked()
 }
 
-// GetSignatureFamily retrieves the model family for a cached thinking signature.
+// GetSignatureFamily retrieves the model family of a thinking signature issued in session.
 // Returns empty string if not found or expired.
-func (c *SignatureCache) GetSignatureFamily(signature string) string {
+func (c *SignatureCache) GetSignatureFamily(session, signature string) string {
+	return c.signatureFamily(session, signature, true)
+}
+
+// PeekSignatureFamily is GetSignatureFamily without counting a hit or miss, for the passes
+// over a request ahead of its conversion, so each block is counted at most once.
+func (c *SignatureCache) PeekSignatureFamily(session, signature string) string {
+	return c.signatureFamily(session, signature, false)
+}
+
+func (c *SignatureCache) signatureFamily(session, signature string, count bool) string {
 	if signature == "" {
 		return ""
 	}
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	entry, ok := c.thinkingCache[signature]
-	if !ok {
+	key := signatureKey{session, signature}
+	entry, ok := c.thinkingCache[key]
+	if !ok || time.Since(entry.timestamp) > c.ttl {
+		if count {
+			c.misses++
+		}
 		return ""
 	}
-	if time.Since(entry.timestamp) > c.ttl {
-		return ""
+	if count {
+		c.hits++
 	}
+	entry.lastUsed = time.Now()
+	c.thinkingCache[key] = entry
+	c.sessionLocked(session)
 	return entry.modelFamily
 }
 
+// sessionLocked returns the index of a session, creating it, and marks it most recently
+// used. Returns nil for the unknown session "".
+func (c *SignatureCache) sessionLocked(id string) *signatureSession {
+	if id == "" {
+		return nil
+	}
+	if elem, ok := c.sessions[id]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*signatureSession)
+	}
+	s := &signatureSession{id: id, tools: make(map[string]struct{}), thinking: make(map[string]struct{})}
+	c.sessions[id] = c.lru.PushFront(s)
+	return s
+}
+
+// unindexLocked removes a key from the index of its session, dropping the session once empty.
+func (c *SignatureCache) unindexLocked(session, key string, thinking bool) {
+	elem, ok := c.sessions[session]
+	if !ok {
+		return
+	}
+	s := elem.Value.(*signatureSession)
+	if thinking {
+		delete(s.thinking, key)
+	} else {
+		delete(s.tools, key)
+	}
+	if len(s.tools) == 0 && len(s.thinking) == 0 {
+		c.lru.Remove(elem)
+		delete(c.sessions, session)
+	}
+}
+
+// enforceLimitsLocked evicts the least recently used sessions beyond maxSessions, then the
+// least recently used entries beyond maxEntries.
+func (c *SignatureCache) enforceLimitsLocked() {
+	for c.maxSessions > 0 && c.lru.Len() > c.maxSessions {
+		s := c.lru.Remove(c.lru.Back()).(*signatureSession)
+		delete(c.sessions, s.id)
+		for id := range s.tools {
+			delete(c.toolSignatures, signatureKey{s.id, id})
+			c.evictions++
+		}
+		for sig := range s.thinking {
+			delete(c.thinkingCache, signatureKey{s.id, sig})
+			c.evictions++
+		}
+		utils.Debug("[SignatureCache] Evicted the signatures of idle session %s", s.id)
+	}
+	if c.maxEntries > 0 && len(c.toolSignatures) > c.maxEntries {
+		for key := range evictOldest(c.toolSignatures, c.maxEntries, func(e signatureEntry) time.Time { return used(e.timestamp, e.lastUsed) }) {
+			c.evictions++
+			c.unindexLocked(key.session, key.id, false)
+		}
+	}
+	if c.maxEntries > 0 && len(c.thinkingCache) > c.maxEntries {
+		for key := range evictOldest(c.thinkingCache, c.maxEntries, func(e thinkingSignatureEntry) time.Time { return used(e.timestamp, e.lastUsed) }) {
+			c.evictions++
+			c.unindexLocked(key.session, key.id, true)
+		}
+	}
+}
+
 // Cleanup removes expired entries from both caches.
 func (c *SignatureCache) Cleanup() {
 	c.mu.Lock()
@@ -111,36 +287,268 @@ func (c *SignatureCache) Cleanup() {
 
 	now := time.Now()
 
-	for id, entry := range c.toolSignatures {
+	for key, entry := range c.toolSignatures {
 		if now.Sub(entry.timestamp) > c.ttl {
-			delete(c.toolSignatures, id)
+			delete(c.toolSignatures, key)
+			c.unindexLocked(key.session, key.id, false)
 		}
 	}
 
-	for sig, entry := range c.thinkingCache {
+	for key, entry := range c.thinkingCache {
 		if now.Sub(entry.timestamp) > c.ttl {
-			delete(c.thinkingCache, sig)
+			delete(c.thinkingCache, key)
+			c.unindexLocked(key.session, key.id, true)
 		}
 	}
 }
 
 // Size returns the current number of entries in the tool signature cache.
 func (c *SignatureCache) Size() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return len(c.toolSignatures)
 }
 
 // ThinkingCacheSize returns the current number of entries in the thinking cache.
 func (c *SignatureCache) ThinkingCacheSize() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return len(c.thinkingCache)
 }
 
+// Stats returns the cache's sizes and its lookup and eviction counts so far.
+func (c *SignatureCache) Stats() SignatureStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return SignatureStats{
+		ToolEntries:     len(c.toolSignatures),
+		ThinkingEntries: len(c.thinkingCache),
+		Sessions:        c.lru.Len(),
+		Hits:            c.hits,
+		Misses:          c.misses,
+		Evictions:       c.evictions,
+	}
+}
+
+// SetLimits updates the TTL, the per-map size limit and the session limit. Non-positive
+// values keep the current setting.
+func (c *SignatureCache) SetLimits(ttl time.Duration, maxEntries, maxSessions int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ttl > 0 {
+		c.ttl = ttl
+	}
+	if maxEntries > 0 {
+		c.maxEntries = maxEntries
+	}
+	if maxSessions > 0 {
+		c.maxSessions = maxSessions
+	}
+	c.enforceLimitsLocked()
+}
+
+// evictOldest trims m to roughly 90% of limit by removing the entries used longest ago,
+// so a full cache doesn't pay for a scan on every insert. Returns the removed entries.
+func evictOldest[K comparable, T any](m map[K]T, limit int, ts func(T) time.Time) map[K]T {
+	target := limit - limit/10
+	if len(m) <= target {
+		return nil
+	}
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return ts(m[keys[i]]).Before(ts(m[keys[j]])) })
+	removed := make(map[K]T, len(m)-target)
+	for _, k := range keys[:len(m)-target] {
+		removed[k] = m[k]
+		delete(m, k)
+	}
+	return removed
+}
+
+// signatureSnapshotVersion is the version of the on-disk format. Snapshots of other versions
+// are ignored; their signatures would have to be sent again anyway.
+const signatureSnapshotVersion = 2
+
+// signatureSnapshot is the on-disk JSON format of the cache.
+type signatureSnapshot struct {
+	Version           int                      `json:"version"`
+	ToolSignatures    []signatureSnapshotEntry `json:"toolSignatures"`
+	ThinkingSignature []signatureSnapshotEntry `json:"thinkingSignatures"`
+}
+
+type signatureSnapshotEntry struct {
+	Key       string    `json:"key"`   // tool_use_id for tool entries, signature for thinking entries
+	Value     string    `json:"value"` // signature for tool entries, model family for thinking entries
+	Session   string    `json:"session,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// byTimestamp sorts entries oldest first.
+func byTimestamp(entries []signatureSnapshotEntry) []signatureSnapshotEntry {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+	return entries
+}
+
+// SaveToFile writes unexpired entries to path atomically (temp file + rename). Each save
+// has its own temp file, so concurrent saves don't clobber each other's.
+func (c *SignatureCache) SaveToFile(path string) error {
+	snap := signatureSnapshot{
+		Version:           signatureSnapshotVersion,
+		ToolSignatures:    []signatureSnapshotEntry{},
+		ThinkingSignature: []signatureSnapshotEntry{},
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	for key, e := range c.toolSignatures {
+		if now.Sub(e.timestamp) <= c.ttl {
+			snap.ToolSignatures = append(snap.ToolSignatures, signatureSnapshotEntry{Key: key.id, Value: e.signature, Session: key.session, Timestamp: e.timestamp})
+		}
+	}
+	for key, e := range c.thinkingCache {
+		if now.Sub(e.timestamp) <= c.ttl {
+			snap.ThinkingSignature = append(snap.ThinkingSignature, signatureSnapshotEntry{Key: key.id, Value: e.modelFamily, Session: key.session, Timestamp: e.timestamp})
+		}
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signature cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create signature cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write signature cache: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write signature cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save signature cache: %w", err)
+	}
+	return nil
+}
+
+// LoadFromFile merges entries from a snapshot written by SaveToFile, skipping expired ones.
+// A missing file, or a snapshot of another version, is not an error. Returns the number of
+// entries loaded.
+func (c *SignatureCache) LoadFromFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read signature cache: %w", err)
+	}
+
+	var version struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return 0, fmt.Errorf("failed to parse signature cache: %w", err)
+	}
+	if version.Version != signatureSnapshotVersion {
+		utils.Debug("[SignatureCache] Ignoring a version %d snapshot", version.Version)
+		return 0, nil
+	}
+	var snap signatureSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("failed to parse signature cache: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	loaded := 0
+	// Oldest first, so the sessions used last end up most recently used.
+	for _, e := range byTimestamp(snap.ToolSignatures) {
+		if now.Sub(e.Timestamp) > c.ttl || e.Key == "" || e.Value == "" {
+			continue
+		}
+		key := signatureKey{e.Session, e.Key}
+		if existing, ok := c.toolSignatures[key]; ok && existing.timestamp.After(e.Timestamp) {
+			continue
+		}
+		c.toolSignatures[key] = signatureEntry{signature: e.Value, timestamp: e.Timestamp}
+		if s := c.sessionLocked(e.Session); s != nil {
+			s.tools[e.Key] = struct{}{}
+		}
+		loaded++
+	}
+	for _, e := range byTimestamp(snap.ThinkingSignature) {
+		if now.Sub(e.Timestamp) > c.ttl || len(e.Key) < c.minSignatureLen {
+			continue
+		}
+		key := signatureKey{e.Session, e.Key}
+		if existing, ok := c.thinkingCache[key]; ok && existing.timestamp.After(e.Timestamp) {
+			continue
+		}
+		c.thinkingCache[key] = thinkingSignatureEntry{modelFamily: e.Value, timestamp: e.Timestamp}
+		if s := c.sessionLocked(e.Session); s != nil {
+			s.thinking[e.Key] = struct{}{}
+		}
+		loaded++
+	}
+
+	c.enforceLimitsLocked()
+	return loaded, nil
+}
+
+// StartPersistence periodically cleans up and snapshots the cache to path until stop is closed.
+// The returned channel is closed once it has stopped; callers should wait for it before
+// writing a final snapshot with SaveToFile, so a periodic save can't overwrite it.
+func (c *SignatureCache) StartPersistence(path string, interval time.Duration, stop <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.Cleanup()
+				if err := c.SaveToFile(path); err != nil {
+					utils.Warn("[SignatureCache] %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// registerSignatureCacheMetrics reports c in the signature cache metrics of /metrics.
+func registerSignatureCacheMetrics(c *SignatureCache) {
+	metrics.SignatureCacheEntries.Set(func() float64 {
+		stats := c.Stats()
+		return float64(stats.ToolEntries + stats.ThinkingEntries)
+	})
+	metrics.SignatureCacheSessions.Set(func() float64 { return float64(c.Stats().Sessions) })
+	metrics.SignatureCacheHits.Set(func() float64 { return float64(c.Stats().Hits) })
+	metrics.SignatureCacheMisses.Set(func() float64 { return float64(c.Stats().Misses) })
+	metrics.SignatureCacheEvictions.Set(func() float64 { return float64(c.Stats().Evictions) })
+}
+
 // Global signature cache instance
 var globalSignatureCache = NewSignatureCache()
 
+func init() {
+	registerSignatureCacheMetrics(globalSignatureCache)
+}
+
 // GetGlobalSignatureCache returns the global signature cache instance.
 func GetGlobalSignatureCache() *SignatureCache {
 	return globalSignatureCache
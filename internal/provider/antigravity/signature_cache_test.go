@@ -0,0 +1,271 @@
+package antigravity
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestSignatureCache_PersistRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signatures.json")
+	thinkingSig := strings.Repeat("s", 64)
+
+	cache := NewSignatureCache()
+	cache.CacheToolSignature("s1", "toolu_1", "tool-sig")
+	cache.CacheThinkingSignature("s1", thinkingSig, "gemini")
+	if err := cache.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	restored := NewSignatureCache()
+	loaded, err := restored.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if loaded != 2 {
+		t.Errorf("loaded = %d, want 2", loaded)
+	}
+	if got := restored.GetToolSignature("s1", "toolu_1"); got != "tool-sig" {
+		t.Errorf("GetToolSignature() = %q, want tool-sig", got)
+	}
+	if got := restored.GetSignatureFamily("s1", thinkingSig); got != "gemini" {
+		t.Errorf("GetSignatureFamily() = %q, want gemini", got)
+	}
+	if got := restored.Stats().Sessions; got != 1 {
+		t.Errorf("Sessions = %d, want the saved session restored", got)
+	}
+}
+
+func TestSignatureCache_LoadSkipsExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signatures.json")
+
+	cache := NewSignatureCache()
+	cache.CacheToolSignature("", "toolu_old", "sig")
+	cache.toolSignatures[signatureKey{"", "toolu_old"}] = signatureEntry{signature: "sig", timestamp: time.Now().Add(-30 * time.Minute)}
+	if err := cache.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	restored := NewSignatureCache()
+	restored.SetLimits(10*time.Minute, 0, 0)
+	if loaded, _ := restored.LoadFromFile(path); loaded != 0 {
+		t.Errorf("loaded = %d, want 0 for expired entries", loaded)
+	}
+}
+
+func TestSignatureCache_PersistenceStopsBeforeFinalSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "signatures.json")
+
+	cache := NewSignatureCache()
+	stop := make(chan struct{})
+	done := cache.StartPersistence(path, time.Millisecond, stop)
+	time.Sleep(10 * time.Millisecond)
+
+	cache.CacheToolSignature("s1", "toolu_last", "sig")
+	close(stop)
+	<-done
+	if err := cache.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	restored := NewSignatureCache()
+	if _, err := restored.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if got := restored.GetToolSignature("s1", "toolu_last"); got != "sig" {
+		t.Errorf("GetToolSignature() = %q, want the final snapshot's entry", got)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(tmps) != 0 {
+		t.Errorf("temp files left behind: %v", tmps)
+	}
+}
+
+func TestSignatureCache_ConcurrentSaves(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "signatures.json")
+
+	cache := NewSignatureCache()
+	cache.CacheToolSignature("s1", "toolu_1", "sig")
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		go func() { errs <- cache.SaveToFile(path) }()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("SaveToFile() error = %v", err)
+		}
+	}
+
+	if loaded, err := NewSignatureCache().LoadFromFile(path); err != nil || loaded != 1 {
+		t.Errorf("LoadFromFile() = %d, %v; want 1, nil", loaded, err)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(tmps) != 0 {
+		t.Errorf("temp files left behind: %v", tmps)
+	}
+}
+
+func TestSignatureCache_LoadMissingFile(t *testing.T) {
+	cache := NewSignatureCache()
+	loaded, err := cache.LoadFromFile(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || loaded != 0 {
+		t.Errorf("LoadFromFile(missing) = %d, %v; want 0, nil", loaded, err)
+	}
+}
+
+func TestSignatureCache_EvictsOldest(t *testing.T) {
+	cache := NewSignatureCache()
+	cache.SetLimits(0, 10, 0)
+
+	for i := 0; i < 11; i++ {
+		cache.CacheToolSignature("", fmt.Sprintf("toolu_%d", i), "sig")
+		// Keep timestamps strictly ordered.
+		cache.toolSignatures[signatureKey{"", fmt.Sprintf("toolu_%d", i)}] = signatureEntry{signature: "sig", timestamp: time.Now().Add(time.Duration(i) * time.Millisecond)}
+	}
+
+	if size := cache.Size(); size > 10 {
+		t.Errorf("Size() = %d, want <= 10", size)
+	}
+	if cache.GetToolSignature("", "toolu_0") != "" {
+		t.Error("expected oldest entry to be evicted")
+	}
+	if cache.GetToolSignature("", "toolu_10") == "" {
+		t.Error("expected newest entry to be kept")
+	}
+}
+
+func TestSignatureCache_EvictsIdleSessions(t *testing.T) {
+	cache := NewSignatureCache()
+	cache.SetLimits(0, 0, 2)
+
+	cache.CacheToolSignature("a", "toolu_a", "sig")
+	cache.CacheThinkingSignature("a", strings.Repeat("a", 64), "claude")
+	cache.CacheToolSignature("b", "toolu_b", "sig")
+	// Using session a makes b the least recently used.
+	cache.GetToolSignature("a", "toolu_a")
+	cache.CacheToolSignature("c", "toolu_c", "sig")
+
+	if cache.GetToolSignature("b", "toolu_b") != "" {
+		t.Error("expected the idle session's signatures to be evicted")
+	}
+	if cache.GetToolSignature("a", "toolu_a") == "" || cache.GetSignatureFamily("a", strings.Repeat("a", 64)) == "" || cache.GetToolSignature("c", "toolu_c") == "" {
+		t.Error("expected the active sessions' signatures to be kept")
+	}
+	stats := cache.Stats()
+	if stats.Sessions != 2 || stats.Evictions != 1 || stats.Hits != 4 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v, want 2 sessions, 1 eviction, 4 hits and 1 miss", stats)
+	}
+
+	// Signatures of unknown sessions are only bounded by the entry limit.
+	cache.CacheToolSignature("", "toolu_x", "sig")
+	if got := cache.Stats().Sessions; got != 2 {
+		t.Errorf("Sessions = %d, want 2", got)
+	}
+}
+
+func TestSignatureCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewSignatureCache()
+	cache.SetLimits(0, 10, 0)
+
+	start := time.Now().Add(-time.Minute)
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprintf("toolu_%d", i)
+		cache.CacheToolSignature("s", id, "sig")
+		cache.toolSignatures[signatureKey{"s", id}] = signatureEntry{signature: "sig", timestamp: start.Add(time.Duration(i) * time.Second)}
+	}
+	// The oldest entry is still in use.
+	cache.GetToolSignature("s", "toolu_0")
+	cache.CacheToolSignature("s", "toolu_10", "sig")
+
+	if cache.GetToolSignature("s", "toolu_0") == "" {
+		t.Error("expected the recently used entry to be kept")
+	}
+	if cache.GetToolSignature("s", "toolu_1") != "" {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+}
+
+func TestSignatureCache_ScopedToSession(t *testing.T) {
+	cache := NewSignatureCache()
+	thinkingSig := strings.Repeat("s", 64)
+	cache.CacheToolSignature("a", "toolu_1", "sig-a")
+	cache.CacheToolSignature("b", "toolu_1", "sig-b")
+	cache.CacheThinkingSignature("a", thinkingSig, "gemini")
+
+	if got := cache.GetToolSignature("a", "toolu_1"); got != "sig-a" {
+		t.Errorf("GetToolSignature(a) = %q, want sig-a", got)
+	}
+	if got := cache.GetToolSignature("b", "toolu_1"); got != "sig-b" {
+		t.Errorf("GetToolSignature(b) = %q, want sig-b", got)
+	}
+	if got := cache.GetToolSignature("c", "toolu_1"); got != "" {
+		t.Errorf("GetToolSignature(c) = %q, want no signature of another session", got)
+	}
+	if got := cache.GetSignatureFamily("b", thinkingSig); got != "" {
+		t.Errorf("GetSignatureFamily(b) = %q, want no family of another session", got)
+	}
+
+	// Peeking doesn't count.
+	if got := cache.PeekSignatureFamily("a", thinkingSig); got != "gemini" {
+		t.Errorf("PeekSignatureFamily(a) = %q, want gemini", got)
+	}
+	cache.PeekSignatureFamily("b", thinkingSig)
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Stats() = %+v, want 2 hits and 2 misses", stats)
+	}
+}
+
+func TestSignatureSession(t *testing.T) {
+	request := func(system, userID, content string) *types.AnthropicRequest {
+		req := &types.AnthropicRequest{Messages: []types.Message{
+			{Role: "user", Content: json.RawMessage(content)},
+			{Role: "assistant", Content: json.RawMessage(`"Hello"`)},
+		}}
+		if system != "" {
+			req.System = json.RawMessage(system)
+		}
+		if userID != "" {
+			req.Metadata = &types.Metadata{UserID: userID}
+		}
+		return req
+	}
+
+	base := SignatureSession(request("", "", `"hi"`))
+	if base == "" {
+		t.Fatal("expected a session")
+	}
+	later := request("", "", `"hi"`)
+	later.Messages = append(later.Messages, types.Message{Role: "user", Content: json.RawMessage(`"next"`)})
+	if got := SignatureSession(later); got != base {
+		t.Error("expected the session to stay the same across turns")
+	}
+	for name, req := range map[string]*types.AnthropicRequest{
+		"user id":       request("", "user_1_session_a", `"hi"`),
+		"system prompt": request(`"You are terse."`, "", `"hi"`),
+		"content":       request("", "", `[{"type":"text","text":"hi"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AA=="}}]`),
+	} {
+		if SignatureSession(req) == base {
+			t.Errorf("expected a different %s to give another session", name)
+		}
+	}
+	if got := SignatureSession(&types.AnthropicRequest{}); got != "" {
+		t.Errorf("SignatureSession(no messages) = %q, want none", got)
+	}
+}
+
+func TestSignatureCache_LoadIgnoresOtherVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signatures.json")
+	v1 := `{"version":1,"toolSignatures":{"toolu_1":{"value":"sig","timestamp":"` + time.Now().Format(time.RFC3339) + `"}},"thinkingSignatures":{}}`
+	if err := os.WriteFile(path, []byte(v1), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := NewSignatureCache().LoadFromFile(path); err != nil || loaded != 0 {
+		t.Errorf("LoadFromFile(v1) = %d, %v; want 0, nil", loaded, err)
+	}
+}
@@ -3,10 +3,12 @@ package antigravity
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"io"
 	"strings"
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
@@ -22,14 +24,31 @@ type SSEParser struct {
 	reader *bufio.Reader
 }
 
+// upstreamStreamError is an error object Cloud Code sent after the stream started.
+type upstreamStreamError struct {
+	code            int
+	status, message interface{}
+	body            []byte
+}
+
+func (e *upstreamStreamError) Error() string {
+	return fmt.Sprintf("upstream stream error %d %v: %v", e.code, e.status, e.message)
+}
+
+// AnthropicError maps the Google error object to the Anthropic error type.
+func (e *upstreamStreamError) AnthropicError() *merrors.AnthropicError {
+	return merrors.FromUpstream(e.code, e.body)
+}
+
 // NewSSEParser creates a new SSE parser.
 func NewSSEParser(r io.Reader) *SSEParser {
 	return &SSEParser{reader: bufio.NewReader(r)}
 }
 
 // ParseThinkingResponse parses an SSE response for thinking models.
-// Accumulates all parts and returns a single Anthropic response.
-func ParseThinkingResponse(reader io.ReadCloser, originalModel string) (*types.AnthropicResponse, error) {
+// Accumulates all parts and returns a single Anthropic response; its signatures are cached
+// for session.
+func ParseThinkingResponse(reader io.ReadCloser, originalModel, session string) (*types.AnthropicResponse, error) {
 	defer reader.Close()
 
 	var accumulatedThinkingText string
@@ -37,6 +56,7 @@ func ParseThinkingResponse(reader io.ReadCloser, originalModel string) (*types.A
 	var accumulatedText string
 	var finalParts []map[string]interface{}
 	var usageMetadata map[string]interface{}
+	var groundingMetadata interface{}
 	finishReason := "STOP"
 
 	flushThinking := func() {
@@ -100,9 +120,12 @@ func ParseThinkingResponse(reader io.ReadCloser, originalModel string) (*types.A
 			continue
 		}
 
-		if fr, ok := firstCandidate["finishReason"].(string); ok {
+		if fr, ok := firstCandidate["finishReason"].(string); ok && fr != "" && finishReason != "MAX_TOKENS" {
 			finishReason = fr
 		}
+		if gm, ok := firstCandidate["groundingMetadata"]; ok {
+			groundingMetadata = gm
+		}
 
 		content, _ := firstCandidate["content"].(map[string]interface{})
 		parts, _ := content["parts"].([]interface{})
@@ -142,8 +165,9 @@ func ParseThinkingResponse(reader io.ReadCloser, originalModel string) (*types.A
 	accumulatedResponse := map[string]interface{}{
 		"candidates": []interface{}{
 			map[string]interface{}{
-				"content":      map[string]interface{}{"parts": toInterfaceSlice(finalParts)},
-				"finishReason": finishReason,
+				"content":           map[string]interface{}{"parts": toInterfaceSlice(finalParts)},
+				"finishReason":      finishReason,
+				"groundingMetadata": groundingMetadata,
 			},
 		},
 		"usageMetadata": usageMetadata,
@@ -172,13 +196,7 @@ func ParseThinkingResponse(reader io.ReadCloser, originalModel string) (*types.A
 		}
 	}
 
-	return ConvertGoogleToAnthropic(accumulatedResponse, originalModel), nil
-}
-
-// StreamEvent represents an event to send in SSE streaming.
-type StreamEvent struct {
-	Type string
-	Data interface{}
+	return ConvertGoogleToAnthropic(accumulatedResponse, originalModel, session), nil
 }
 
 // EmptyResponseError is returned when the SSE stream contains no content parts.
@@ -207,13 +225,18 @@ type StreamingParser struct {
 	blockIndex               int
 	currentBlockType         string // "", "thinking", "text", "tool_use"
 	currentThinkingSignature string
-	stopReason               string
+	finishReason             string
+	toolCalls                int
+	toolIDs                  map[string]bool
+	singleToolUse            bool                   // disable_parallel_tool_use: drop tool calls after the first
+	grounded                 map[string]interface{} // Last candidate with Google Search grounding
 
 	inputTokens     int
 	outputTokens    int
 	cacheReadTokens int
 
 	sigCache *SignatureCache
+	session  string // Conversation the stream's signatures are cached for
 }
 
 // NewStreamingParser creates a new streaming parser.
@@ -222,15 +245,26 @@ func NewStreamingParser(reader io.ReadCloser, originalModel string) *StreamingPa
 		reader:        reader,
 		originalModel: originalModel,
 		messageID:     generateMessageID(),
-		stopReason:    "end_turn",
+		toolIDs:       make(map[string]bool),
 		sigCache:      GetGlobalSignatureCache(),
 	}
 }
 
+// SetToolChoice applies the request's tool_choice to the stream: with
+// disable_parallel_tool_use, tool calls after the first are dropped.
+func (p *StreamingParser) SetToolChoice(tc *types.ToolChoice) {
+	p.singleToolUse = !tc.AllowsParallelToolUse()
+}
+
+// SetSession scopes the signatures of the stream to a conversation (see SignatureSession).
+func (p *StreamingParser) SetSession(session string) {
+	p.session = session
+}
+
 // StreamEvents yields streaming events to be sent to the client.
 // Returns a channel of StreamEvent and a channel for the final error (nil on success).
-func (p *StreamingParser) StreamEvents() (<-chan StreamEvent, <-chan error) {
-	eventsCh := make(chan StreamEvent, 100)
+func (p *StreamingParser) StreamEvents() (<-chan types.StreamEvent, <-chan error) {
+	eventsCh := make(chan types.StreamEvent, 100)
 	errCh := make(chan error, 1)
 
 	go func() {
@@ -260,6 +294,12 @@ func (p *StreamingParser) StreamEvents() (<-chan StreamEvent, <-chan error) {
 				continue
 			}
 
+			// Errors after the stream has started arrive as an error object.
+			if upstreamErr, ok := data["error"].(map[string]interface{}); ok {
+				errCh <- &upstreamStreamError{code: getInt(upstreamErr, "code"), status: upstreamErr["status"], message: upstreamErr["message"], body: []byte(jsonText)}
+				return
+			}
+
 			innerResponse := data
 			if resp, ok := data["response"].(map[string]interface{}); ok {
 				innerResponse = resp
@@ -294,27 +334,10 @@ func (p *StreamingParser) StreamEvents() (<-chan StreamEvent, <-chan error) {
 			// Emit message_start on first data that includes parts.
 			if !p.hasEmittedStart && len(parts) > 0 {
 				p.hasEmittedStart = true
-				eventsCh <- StreamEvent{
-					Type: "message_start",
-					Data: map[string]interface{}{
-						"type": "message_start",
-						"message": map[string]interface{}{
-							"id":            p.messageID,
-							"type":          "message",
-							"role":          "assistant",
-							"content":       []interface{}{},
-							"model":         p.originalModel,
-							"stop_reason":   nil,
-							"stop_sequence": nil,
-							"usage": map[string]interface{}{
-								"input_tokens":                p.inputTokens - p.cacheReadTokens,
-								"output_tokens":               0,
-								"cache_read_input_tokens":     p.cacheReadTokens,
-								"cache_creation_input_tokens": 0,
-							},
-						},
-					},
-				}
+				eventsCh <- messageStartEvent(p.messageID, p.originalModel, types.Usage{
+					InputTokens:          p.inputTokens - p.cacheReadTokens,
+					CacheReadInputTokens: p.cacheReadTokens,
+				})
 			}
 
 			// Process each part.
@@ -329,19 +352,12 @@ func (p *StreamingParser) StreamEvents() (<-chan StreamEvent, <-chan error) {
 				}
 			}
 
-			// Check finish reason.
-			// Priority: max_tokens > tool_use > end_turn
-			// MAX_TOKENS always takes precedence (even over tool_use).
-			// STOP should not overwrite tool_use.
-			if fr, ok := firstCandidate["finishReason"].(string); ok && fr != "" {
-				switch fr {
-				case "MAX_TOKENS":
-					p.stopReason = "max_tokens"
-				case "STOP":
-					if p.stopReason != "tool_use" {
-						p.stopReason = "end_turn"
-					}
-				}
+			// A later STOP must not hide an earlier MAX_TOKENS (see stopReasonFor).
+			if fr, ok := firstCandidate["finishReason"].(string); ok && fr != "" && p.finishReason != "MAX_TOKENS" {
+				p.finishReason = fr
+			}
+			if _, ok := firstCandidate["groundingMetadata"]; ok {
+				p.grounded = firstCandidate
 			}
 		}
 
@@ -363,38 +379,21 @@ func (p *StreamingParser) StreamEvents() (<-chan StreamEvent, <-chan error) {
 				p.currentThinkingSignature = ""
 			}
 
-			eventsCh <- StreamEvent{
-				Type: "content_block_stop",
-				Data: map[string]interface{}{
-					"type":  "content_block_stop",
-					"index": p.blockIndex,
-				},
-			}
+			eventsCh <- p.blockStopEvent()
 		}
 
-		// Emit message_delta and message_stop.
-		eventsCh <- StreamEvent{
-			Type: "message_delta",
-			Data: map[string]interface{}{
-				"type": "message_delta",
-				"delta": map[string]interface{}{
-					"stop_reason":   p.stopReason,
-					"stop_sequence": nil,
-				},
-				"usage": map[string]interface{}{
-					"output_tokens":               p.outputTokens,
-					"cache_read_input_tokens":     p.cacheReadTokens,
-					"cache_creation_input_tokens": 0,
-				},
-			},
+		// Grounding metadata arrives with the last chunks, so web_search blocks follow the text.
+		for _, evt := range p.webSearchEvents() {
+			eventsCh <- evt
 		}
 
-		eventsCh <- StreamEvent{
-			Type: "message_stop",
-			Data: map[string]interface{}{
-				"type": "message_stop",
-			},
+		// Emit message_delta and message_stop.
+		eventsCh <- types.StreamEvent{
+			Type:  "message_delta",
+			Delta: &types.Delta{StopReason: stopReasonFor(p.finishReason, p.toolCalls > 0)},
+			Usage: &types.Usage{OutputTokens: p.outputTokens, CacheReadInputTokens: p.cacheReadTokens},
 		}
+		eventsCh <- types.StreamEvent{Type: "message_stop"}
 
 		errCh <- nil
 	}()
@@ -402,8 +401,8 @@ func (p *StreamingParser) StreamEvents() (<-chan StreamEvent, <-chan error) {
 	return eventsCh, errCh
 }
 
-func (p *StreamingParser) processPart(part map[string]interface{}) []StreamEvent {
-	events := make([]StreamEvent, 0, 2)
+func (p *StreamingParser) processPart(part map[string]interface{}) []types.StreamEvent {
+	events := make([]types.StreamEvent, 0, 2)
 
 	// Thinking block
 	if thought, ok := part["thought"].(bool); ok && thought {
@@ -419,48 +418,22 @@ func (p *StreamingParser) processPart(part map[string]interface{}) []StreamEvent
 
 		if p.currentBlockType != "thinking" {
 			if p.currentBlockType != "" {
-				events = append(events, StreamEvent{
-					Type: "content_block_stop",
-					Data: map[string]interface{}{
-						"type":  "content_block_stop",
-						"index": p.blockIndex,
-					},
-				})
+				events = append(events, p.blockStopEvent())
 				p.blockIndex++
 			}
 
 			p.currentBlockType = "thinking"
 			p.currentThinkingSignature = ""
-			events = append(events, StreamEvent{
-				Type: "content_block_start",
-				Data: map[string]interface{}{
-					"type":  "content_block_start",
-					"index": p.blockIndex,
-					"content_block": map[string]interface{}{
-						"type":     "thinking",
-						"thinking": "",
-					},
-				},
-			})
+			events = append(events, p.blockStartEvent(types.ContentBlock{Type: "thinking"}))
 		}
 
 		if signature != "" && len(signature) >= config.MinSignatureLength {
 			p.currentThinkingSignature = signature
 			modelFamily := config.GetModelFamily(p.originalModel)
-			p.sigCache.CacheThinkingSignature(signature, string(modelFamily))
-		}
-
-		events = append(events, StreamEvent{
-			Type: "content_block_delta",
-			Data: map[string]interface{}{
-				"type":  "content_block_delta",
-				"index": p.blockIndex,
-				"delta": map[string]interface{}{
-					"type":     "thinking_delta",
-					"thinking": text,
-				},
-			},
-		})
+			p.sigCache.CacheThinkingSignature(p.session, signature, string(modelFamily))
+		}
+
+		events = append(events, p.blockDeltaEvent(types.Delta{Type: "thinking_delta", Thinking: text}))
 		return events
 	}
 
@@ -477,46 +450,26 @@ func (p *StreamingParser) processPart(part map[string]interface{}) []StreamEvent
 				p.currentThinkingSignature = ""
 			}
 			if p.currentBlockType != "" {
-				events = append(events, StreamEvent{
-					Type: "content_block_stop",
-					Data: map[string]interface{}{
-						"type":  "content_block_stop",
-						"index": p.blockIndex,
-					},
-				})
+				events = append(events, p.blockStopEvent())
 				p.blockIndex++
 			}
 
 			p.currentBlockType = "text"
-			events = append(events, StreamEvent{
-				Type: "content_block_start",
-				Data: map[string]interface{}{
-					"type":  "content_block_start",
-					"index": p.blockIndex,
-					"content_block": map[string]interface{}{
-						"type": "text",
-						"text": "",
-					},
-				},
-			})
+			events = append(events, p.blockStartEvent(types.ContentBlock{Type: "text"}))
 		}
 
-		events = append(events, StreamEvent{
-			Type: "content_block_delta",
-			Data: map[string]interface{}{
-				"type":  "content_block_delta",
-				"index": p.blockIndex,
-				"delta": map[string]interface{}{
-					"type": "text_delta",
-					"text": text,
-				},
-			},
-		})
+		events = append(events, p.blockDeltaEvent(types.Delta{Type: "text_delta", Text: text}))
 		return events
 	}
 
 	// Tool use (functionCall)
 	if fc, ok := part["functionCall"].(map[string]interface{}); ok {
+		if p.singleToolUse && p.toolCalls > 0 {
+			name, _ := fc["name"].(string)
+			utils.Debug("[CloudCode] Dropping parallel tool call %s (disable_parallel_tool_use)", name)
+			return events
+		}
+
 		functionCallSignature := ""
 		if sig, ok := part["thoughtSignature"].(string); ok {
 			functionCallSignature = sig
@@ -527,25 +480,14 @@ func (p *StreamingParser) processPart(part map[string]interface{}) []StreamEvent
 			p.currentThinkingSignature = ""
 		}
 		if p.currentBlockType != "" {
-			events = append(events, StreamEvent{
-				Type: "content_block_stop",
-				Data: map[string]interface{}{
-					"type":  "content_block_stop",
-					"index": p.blockIndex,
-				},
-			})
+			events = append(events, p.blockStopEvent())
 			p.blockIndex++
 		}
 
 		p.currentBlockType = "tool_use"
-		p.stopReason = "tool_use"
+		p.toolCalls++
 
-		toolID := ""
-		if id, ok := fc["id"].(string); ok && id != "" {
-			toolID = id
-		} else {
-			toolID = generateToolID()
-		}
+		toolID := toolUseID(fc, p.toolIDs)
 
 		name, _ := fc["name"].(string)
 		args, _ := fc["args"].(map[string]interface{})
@@ -556,131 +498,98 @@ func (p *StreamingParser) processPart(part map[string]interface{}) []StreamEvent
 			}
 		}
 
-		toolUseBlock := map[string]interface{}{
-			"type":  "tool_use",
-			"id":    toolID,
-			"name":  name,
-			"input": map[string]interface{}{},
-		}
-
+		toolUseBlock := types.ContentBlock{Type: "tool_use", ID: toolID, Name: name}
 		if functionCallSignature != "" && len(functionCallSignature) >= config.MinSignatureLength {
-			toolUseBlock["thoughtSignature"] = functionCallSignature
-			p.sigCache.CacheToolSignature(toolID, functionCallSignature)
+			toolUseBlock.ThoughtSignature = functionCallSignature
+			p.sigCache.CacheToolSignature(p.session, toolID, functionCallSignature)
 		}
 
-		events = append(events, StreamEvent{
-			Type: "content_block_start",
-			Data: map[string]interface{}{
-				"type":          "content_block_start",
-				"index":         p.blockIndex,
-				"content_block": toolUseBlock,
-			},
-		})
-
-		events = append(events, StreamEvent{
-			Type: "content_block_delta",
-			Data: map[string]interface{}{
-				"type":  "content_block_delta",
-				"index": p.blockIndex,
-				"delta": map[string]interface{}{
-					"type":         "input_json_delta",
-					"partial_json": argsJSON,
-				},
-			},
-		})
+		events = append(events, p.blockStartEvent(toolUseBlock))
+		events = append(events, p.blockDeltaEvent(types.Delta{Type: "input_json_delta", PartialJSON: argsJSON}))
 		return events
 	}
 
 	return events
 }
 
-func (p *StreamingParser) signatureDeltaEvent(signature string) StreamEvent {
-	return StreamEvent{
-		Type: "content_block_delta",
-		Data: map[string]interface{}{
-			"type":  "content_block_delta",
-			"index": p.blockIndex,
-			"delta": map[string]interface{}{
-				"type":      "signature_delta",
-				"signature": signature,
-			},
-		},
+// webSearchEvents streams the web_search blocks for the response's Google Search
+// grounding (see webSearchBlocks). Any open block must already be stopped.
+func (p *StreamingParser) webSearchEvents() []types.StreamEvent {
+	blocks := webSearchBlocks(p.grounded)
+	events := make([]types.StreamEvent, 0, 4*len(blocks))
+	for _, block := range blocks {
+		if p.currentBlockType != "" {
+			p.blockIndex++
+		}
+		p.currentBlockType, _ = block["type"].(string)
+
+		var inputJSON []byte
+		if input, ok := block["input"]; ok {
+			// Like tool_use, the input follows as an input_json_delta.
+			inputJSON, _ = json.Marshal(input)
+			start := make(map[string]interface{}, len(block))
+			for k, v := range block {
+				start[k] = v
+			}
+			start["input"] = map[string]interface{}{}
+			block = start
+		}
+
+		// web_search blocks aren't core blocks, so they are streamed as their JSON.
+		raw, err := json.Marshal(block)
+		if err != nil {
+			continue
+		}
+		events = append(events, p.blockStartEvent(types.ContentBlock{Type: p.currentBlockType, Raw: raw}))
+		if inputJSON != nil {
+			events = append(events, p.blockDeltaEvent(types.Delta{Type: "input_json_delta", PartialJSON: string(inputJSON)}))
+		}
+		events = append(events, p.blockStopEvent())
 	}
+	return events
 }
 
-func emitEmptyResponseFallback(model string) []StreamEvent {
-	messageID := generateMessageID()
-	return []StreamEvent{
-		{
-			Type: "message_start",
-			Data: map[string]interface{}{
-				"type": "message_start",
-				"message": map[string]interface{}{
-					"id":            messageID,
-					"type":          "message",
-					"role":          "assistant",
-					"content":       []interface{}{},
-					"model":         model,
-					"stop_reason":   nil,
-					"stop_sequence": nil,
-					"usage": map[string]interface{}{
-						"input_tokens":  0,
-						"output_tokens": 0,
-					},
-				},
-			},
-		},
-		{
-			Type: "content_block_start",
-			Data: map[string]interface{}{
-				"type":  "content_block_start",
-				"index": 0,
-				"content_block": map[string]interface{}{
-					"type": "text",
-					"text": "",
-				},
-			},
-		},
-		{
-			Type: "content_block_delta",
-			Data: map[string]interface{}{
-				"type":  "content_block_delta",
-				"index": 0,
-				"delta": map[string]interface{}{
-					"type": "text_delta",
-					"text": "[No response after retries - please try again]",
-				},
-			},
-		},
-		{
-			Type: "content_block_stop",
-			Data: map[string]interface{}{
-				"type":  "content_block_stop",
-				"index": 0,
-			},
-		},
-		{
-			Type: "message_delta",
-			Data: map[string]interface{}{
-				"type": "message_delta",
-				"delta": map[string]interface{}{
-					"stop_reason":   "end_turn",
-					"stop_sequence": nil,
-				},
-				"usage": map[string]interface{}{
-					"output_tokens": 0,
-				},
-			},
-		},
-		{
-			Type: "message_stop",
-			Data: map[string]interface{}{
-				"type": "message_stop",
-			},
+func (p *StreamingParser) blockStartEvent(block types.ContentBlock) types.StreamEvent {
+	return types.StreamEvent{Type: "content_block_start", Index: p.blockIndex, ContentBlock: &block}
+}
+
+func (p *StreamingParser) blockDeltaEvent(delta types.Delta) types.StreamEvent {
+	return types.StreamEvent{Type: "content_block_delta", Index: p.blockIndex, Delta: &delta}
+}
+
+func (p *StreamingParser) blockStopEvent() types.StreamEvent {
+	return types.StreamEvent{Type: "content_block_stop", Index: p.blockIndex}
+}
+
+func (p *StreamingParser) signatureDeltaEvent(signature string) types.StreamEvent {
+	return p.blockDeltaEvent(types.Delta{Type: "signature_delta", Signature: signature})
+}
+
+// messageStartEvent returns the message_start event of a response.
+func messageStartEvent(messageID, model string, usage types.Usage) types.StreamEvent {
+	return types.StreamEvent{
+		Type: "message_start",
+		Message: &types.AnthropicResponse{
+			ID:    messageID,
+			Type:  "message",
+			Role:  "assistant",
+			Model: model,
+			Usage: usage,
 		},
 	}
 }
 
+func emitEmptyResponseFallback(model string) []types.StreamEvent {
+	return []types.StreamEvent{
+		messageStartEvent(generateMessageID(), model, types.Usage{}),
+		{Type: "content_block_start", ContentBlock: &types.ContentBlock{Type: "text"}},
+		{Type: "content_block_delta", Delta: &types.Delta{Type: "text_delta", Text: "[No response after retries - please try again]"}},
+		{Type: "content_block_stop"},
+		{Type: "message_delta", Delta: &types.Delta{StopReason: "end_turn"}, Usage: &types.Usage{}},
+		{Type: "message_stop"},
+	}
+}
+
 func toInterfaceSlice(maps []map[string]interface{}) []interface{} {
 	result := make([]interface{}, len(maps))
 	for i, m := range maps {
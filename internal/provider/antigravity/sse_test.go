@@ -1,9 +1,14 @@
 package antigravity
 
 import (
+	"encoding/json"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/streamcheck"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
 
 func TestStreamingParser_EmitsNodeParityEvents(t *testing.T) {
@@ -23,7 +28,7 @@ func TestStreamingParser_EmitsNodeParityEvents(t *testing.T) {
 	parser := NewStreamingParser(io.NopCloser(strings.NewReader(input)), "claude-sonnet-4-5-thinking")
 	eventsCh, errCh := parser.StreamEvents()
 
-	events := make([]StreamEvent, 0)
+	events := make([]types.StreamEvent, 0)
 	for evt := range eventsCh {
 		events = append(events, evt)
 	}
@@ -41,31 +46,18 @@ func TestStreamingParser_EmitsNodeParityEvents(t *testing.T) {
 		t.Fatalf("expected first event message_start, got %q", events[0].Type)
 	}
 
-	msgStart, _ := events[0].Data.(map[string]interface{})
-	message, _ := msgStart["message"].(map[string]interface{})
-	usage, _ := message["usage"].(map[string]interface{})
-	if got := asInt(usage["input_tokens"]); got != 7 {
-		t.Fatalf("expected message_start usage.input_tokens=7, got %#v", usage["input_tokens"])
-	}
-	if got := asInt(usage["output_tokens"]); got != 0 {
-		t.Fatalf("expected message_start usage.output_tokens=0, got %#v", usage["output_tokens"])
-	}
-	if got := asInt(usage["cache_read_input_tokens"]); got != 3 {
-		t.Fatalf("expected message_start usage.cache_read_input_tokens=3, got %#v", usage["cache_read_input_tokens"])
+	if events[0].Message == nil {
+		t.Fatalf("expected message_start to carry the message")
 	}
-	if got := asInt(usage["cache_creation_input_tokens"]); got != 0 {
-		t.Fatalf("expected message_start usage.cache_creation_input_tokens=0, got %#v", usage["cache_creation_input_tokens"])
+	want := types.Usage{InputTokens: 7, CacheReadInputTokens: 3}
+	if usage := events[0].Message.Usage; usage != want {
+		t.Fatalf("expected message_start usage %+v, got %+v", want, usage)
 	}
 
 	// Ensure signature_delta is emitted when leaving thinking.
 	foundSignatureDelta := false
 	for _, evt := range events {
-		if evt.Type != "content_block_delta" {
-			continue
-		}
-		data, _ := evt.Data.(map[string]interface{})
-		delta, _ := data["delta"].(map[string]interface{})
-		if delta["type"] == "signature_delta" {
+		if evt.Type == "content_block_delta" && evt.Delta.Type == "signature_delta" {
 			foundSignatureDelta = true
 			break
 		}
@@ -81,8 +73,17 @@ func TestStreamingParser_EmitsNodeParityEvents(t *testing.T) {
 			continue
 		}
 		foundMessageDelta = true
-		data, _ := evt.Data.(map[string]interface{})
-		usage, _ := data["usage"].(map[string]interface{})
+		encoded, err := json.Marshal(evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data struct {
+			Usage map[string]interface{} `json:"usage"`
+		}
+		if err := json.Unmarshal(encoded, &data); err != nil {
+			t.Fatal(err)
+		}
+		usage := data.Usage
 		if _, ok := usage["input_tokens"]; ok {
 			t.Fatalf("expected message_delta usage to omit input_tokens, got %#v", usage)
 		}
@@ -130,3 +131,225 @@ func TestStreamingParser_EmptyResponseErrors(t *testing.T) {
 		t.Fatalf("expected EmptyResponseError, got %T (%v)", err, err)
 	}
 }
+
+func TestStreamingParser_ParallelToolCalls(t *testing.T) {
+	// Two chunks: two calls sharing an upstream id, then a call without one and STOP.
+	input := strings.Join([]string{
+		`data: {"response":{"candidates":[{"content":{"parts":[` +
+			`{"functionCall":{"id":"call_1","name":"read_file","args":{"path":"/a"}}},` +
+			`{"functionCall":{"id":"call_1","name":"read_file","args":{"path":"/b"}}}` +
+			`]}}]}}`,
+		`data: {"response":{"candidates":[{"content":{"parts":[{"functionCall":{"name":"list_dir"}}]},"finishReason":"STOP"}]}}`,
+		"",
+	}, "\n")
+
+	tests := []struct {
+		name       string
+		toolChoice *types.ToolChoice
+		wantCalls  int
+	}{
+		{"parallel", nil, 3},
+		{"disable_parallel_tool_use", &types.ToolChoice{Type: "auto", DisableParallelToolUse: true}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewStreamingParser(io.NopCloser(strings.NewReader(input)), "gemini-3-flash")
+			parser.SetToolChoice(tt.toolChoice)
+			eventsCh, errCh := parser.StreamEvents()
+
+			indices := make(map[int]bool)
+			ids := make(map[string]bool)
+			stopReason := ""
+			for evt := range eventsCh {
+				switch evt.Type {
+				case "content_block_start":
+					if evt.ContentBlock.Type != "tool_use" {
+						t.Fatalf("expected tool_use block, got %v", evt.ContentBlock.Type)
+					}
+					index, id := evt.Index, evt.ContentBlock.ID
+					if indices[index] || id == "" || ids[id] {
+						t.Fatalf("expected distinct index and id, got index %d id %q", index, id)
+					}
+					indices[index], ids[id] = true, true
+				case "message_delta":
+					stopReason = evt.Delta.StopReason
+				}
+			}
+			if err := <-errCh; err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+
+			if len(indices) != tt.wantCalls {
+				t.Errorf("expected %d tool_use blocks, got %d", tt.wantCalls, len(indices))
+			}
+			if stopReason != "tool_use" {
+				t.Errorf("expected stop_reason tool_use, got %q", stopReason)
+			}
+		})
+	}
+}
+
+func TestStreamingParser_MaxTokensWinsOverLaterStop(t *testing.T) {
+	input := strings.Join([]string{
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":"partial"}]},"finishReason":"MAX_TOKENS"}]}}`,
+		`data: {"response":{"candidates":[{"content":{"parts":[{"functionCall":{"name":"do"}}]},"finishReason":"STOP"}]}}`,
+		"",
+	}, "\n")
+
+	parser := NewStreamingParser(io.NopCloser(strings.NewReader(input)), "gemini-3-flash")
+	eventsCh, errCh := parser.StreamEvents()
+	stopReason := ""
+	for evt := range eventsCh {
+		if evt.Type == "message_delta" {
+			stopReason = evt.Delta.StopReason
+		}
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if stopReason != "max_tokens" {
+		t.Errorf("expected stop_reason max_tokens, got %q", stopReason)
+	}
+}
+
+func TestStreamingParser_WebSearchGrounding(t *testing.T) {
+	input := strings.Join([]string{
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":"Go 1.24 "}]}}]}}`,
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":"is out."}]},"finishReason":"STOP",` +
+			`"groundingMetadata":{"webSearchQueries":["latest go release"],"groundingChunks":[{"web":{"uri":"https://go.dev","title":"go.dev"}}]}}]}}`,
+		"",
+	}, "\n")
+
+	parser := NewStreamingParser(io.NopCloser(strings.NewReader(input)), "gemini-3-flash")
+	eventsCh, errCh := parser.StreamEvents()
+
+	var starts []string
+	var toolUseID, resultFor, query string
+	open := map[int]bool{}
+	for evt := range eventsCh {
+		switch evt.Type {
+		case "content_block_start":
+			if open[evt.Index] || len(open) > 0 {
+				t.Fatalf("block %d started while %v still open", evt.Index, open)
+			}
+			open[evt.Index] = true
+			encoded, err := json.Marshal(evt.ContentBlock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var block map[string]interface{}
+			if err := json.Unmarshal(encoded, &block); err != nil {
+				t.Fatal(err)
+			}
+			blockType, _ := block["type"].(string)
+			starts = append(starts, blockType)
+			switch blockType {
+			case "server_tool_use":
+				toolUseID, _ = block["id"].(string)
+			case "web_search_tool_result":
+				resultFor, _ = block["tool_use_id"].(string)
+			}
+		case "content_block_delta":
+			if evt.Delta.Type == "input_json_delta" {
+				var input map[string]string
+				json.Unmarshal([]byte(evt.Delta.PartialJSON), &input)
+				query = input["query"]
+			}
+		case "content_block_stop":
+			delete(open, evt.Index)
+		}
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	want := []string{"text", "server_tool_use", "web_search_tool_result"}
+	if !reflect.DeepEqual(starts, want) {
+		t.Fatalf("expected blocks %v, got %v", want, starts)
+	}
+	if toolUseID == "" || resultFor != toolUseID {
+		t.Errorf("expected result for %q, got %q", toolUseID, resultFor)
+	}
+	if query != "latest go release" {
+		t.Errorf("expected query in input_json_delta, got %q", query)
+	}
+}
+
+func TestStreamingParser_Conformance(t *testing.T) {
+	sig := strings.Repeat("s", 60) // >= MinSignatureLength
+	tests := []struct {
+		name  string
+		model string
+		input []string
+	}{
+		{"thinking, text and tool use", "claude-sonnet-4-5-thinking", []string{
+			`data: {"response":{"candidates":[{"content":{"parts":[{"thought":true,"text":"hmm","thoughtSignature":"` + sig + `"}]}}]}}`,
+			`data: {"response":{"candidates":[{"content":{"parts":[{"text":"hello"}]}}]}}`,
+			`data: {"response":{"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":7},"candidates":[{"content":{"parts":[` +
+				`{"functionCall":{"name":"do","args":{"a":1}},"thoughtSignature":"` + sig + `"}]},"finishReason":"STOP"}]}}`,
+		}},
+		{"parallel tool calls", "gemini-3-flash", []string{
+			`data: {"response":{"candidates":[{"content":{"parts":[` +
+				`{"functionCall":{"name":"a","args":{}}},{"functionCall":{"name":"b","args":{}}}]},"finishReason":"STOP"}]}}`,
+		}},
+		{"text only", "gemini-3-flash", []string{
+			`data: {"response":{"candidates":[{"content":{"parts":[{"text":"hel"}]}}]}}`,
+			`data: {"response":{"candidates":[{"content":{"parts":[{"text":"lo"}]},"finishReason":"MAX_TOKENS"}]}}`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.Join(append(tt.input, ""), "\n")
+			parser := NewStreamingParser(io.NopCloser(strings.NewReader(input)), tt.model)
+			eventsCh, errCh := parser.StreamEvents()
+
+			var events []types.StreamEvent
+			for evt := range eventsCh {
+				events = append(events, evt)
+			}
+			if err := <-errCh; err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+
+			serialized, err := streamcheck.FromStreamEvents(events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := streamcheck.Check(serialized); err != nil {
+				t.Errorf("stream violates the protocol:\n%v", err)
+			}
+		})
+	}
+}
+
+func TestStreamingParser_MidStreamError(t *testing.T) {
+	input := strings.Join([]string{
+		`data: {"response":{"candidates":[{"content":{"parts":[{"text":"hel"}]}}]}}`,
+		`data: {"error":{"code":503,"message":"The model is overloaded.","status":"UNAVAILABLE"}}`,
+		"",
+	}, "\n")
+
+	parser := NewStreamingParser(io.NopCloser(strings.NewReader(input)), "gemini-3-flash")
+	eventsCh, errCh := parser.StreamEvents()
+	var events []types.StreamEvent
+	for evt := range eventsCh {
+		events = append(events, evt)
+	}
+	err := <-errCh
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected the upstream error, got %v", err)
+	}
+
+	// The provider ends the truncated stream with an overloaded error event.
+	events = append(events, streamErrorEvent(err))
+	serialized, err := streamcheck.FromStreamEvents(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := streamcheck.Check(serialized); err != nil {
+		t.Errorf("stream violates the protocol:\n%v", err)
+	}
+	if last := string(serialized[len(serialized)-1].Data); !strings.Contains(last, `"overloaded_error"`) {
+		t.Errorf("expected an overloaded_error event, got %s", last)
+	}
+}
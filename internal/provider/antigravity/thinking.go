@@ -21,6 +21,28 @@ func hasValidSignature(block *types.ContentBlock) bool {
 	return len(block.Signature) >= config.MinSignatureLength
 }
 
+// unknownSignatureTextPrefix marks reasoning recovered from a thinking block whose signature is no longer known.
+const unknownSignatureTextPrefix = "[Earlier reasoning, carried over as plain text]\n"
+
+// recoverUnknownSignatureBlock converts a thinking block with an unknown signature origin into a
+// plain text block when THINKING_SIGNATURE_RECOVERY=text. Returns false if the block should be dropped.
+func recoverUnknownSignatureBlock(block *types.ContentBlock) (types.ContentBlock, bool) {
+	if config.GetThinkingSignatureRecovery() != config.ThinkingRecoveryText || strings.TrimSpace(block.Thinking) == "" {
+		return types.ContentBlock{}, false
+	}
+	return types.ContentBlock{Type: "text", Text: unknownSignatureTextPrefix + block.Thinking}, true
+}
+
+// recoverUnknownSignaturePart is the Google-part form of recoverUnknownSignatureBlock.
+func recoverUnknownSignaturePart(block *types.ContentBlock) map[string]interface{} {
+	recovered, ok := recoverUnknownSignatureBlock(block)
+	if !ok {
+		return nil
+	}
+	utils.Debug("[ContentConverter] Converting thinking with unknown signature origin to text")
+	return map[string]interface{}{"text": recovered.Text}
+}
+
 // hasGeminiHistory checks if conversation history contains Gemini-style messages.
 // Gemini puts thoughtSignature on tool_use blocks, Claude puts signature on thinking blocks.
 func hasGeminiHistory(messages []types.Message) bool {
@@ -138,6 +160,56 @@ func needsThinkingRecovery(messages []types.Message) bool {
 	return !state.TurnHasThinking
 }
 
+// needsThinkingRefresh reports whether, with THINKING_SIGNATURE_RECOVERY=refresh, the
+// conversation should be recovered like one without thinking because the signed thinking of
+// the current tool turn came from another model family (or, for Gemini, an unknown one) and
+// is dropped for targetFamily. Closing the tool loop has the model think afresh instead of
+// carrying on without its reasoning.
+func needsThinkingRefresh(messages []types.Message, session, targetFamily string) bool {
+	if config.GetThinkingSignatureRecovery() != config.ThinkingRecoveryRefresh {
+		return false
+	}
+	state := analyzeConversationState(messages)
+	if (!state.InToolLoop && !state.InterruptedTool) || !state.TurnHasThinking {
+		return false
+	}
+
+	var blocks []types.ContentBlock
+	if err := json.Unmarshal(messages[state.LastAssistantIdx].Content, &blocks); err != nil {
+		return false
+	}
+	sigCache := GetGlobalSignatureCache()
+	dropped := ""
+	for _, block := range blocks {
+		if !isThinkingBlock(&block) || !hasValidSignature(&block) {
+			continue
+		}
+		family := sigCache.PeekSignatureFamily(session, block.Signature)
+		if !incompatibleSignatureFamily(family, targetFamily) {
+			return false
+		}
+		dropped = family
+	}
+	if dropped == "" {
+		dropped = "unknown"
+	}
+	utils.Info("[ThinkingUtils] Thinking of the current turn was signed by a %s model and can't be sent to a %s model; requesting fresh thinking", dropped, targetFamily)
+	return true
+}
+
+// incompatibleSignatureFamily reports whether thinking signed by a signatureFamily model is
+// dropped for a targetFamily model. Gemini only takes its own signatures; Claude is still sent
+// Gemini's outside refresh recovery.
+func incompatibleSignatureFamily(signatureFamily, targetFamily string) bool {
+	switch targetFamily {
+	case "gemini":
+		return signatureFamily != "gemini"
+	case "claude":
+		return signatureFamily == "gemini"
+	}
+	return false
+}
+
 // removeTrailingThinkingBlocks removes trailing unsigned thinking blocks from content.
 func removeTrailingThinkingBlocks(blocks []types.ContentBlock) []types.ContentBlock {
 	if len(blocks) == 0 {
@@ -263,10 +335,13 @@ func reorderAssistantContent(blocks []types.ContentBlock) []types.ContentBlock {
 	return result
 }
 
-// stripInvalidThinkingBlocks removes invalid or incompatible thinking blocks.
-func stripInvalidThinkingBlocks(messages []types.Message, targetFamily string) []types.Message {
+// stripInvalidThinkingBlocks removes invalid or incompatible thinking blocks, looking up the
+// signatures cached for session.
+func stripInvalidThinkingBlocks(messages []types.Message, session, targetFamily string) []types.Message {
 	sigCache := GetGlobalSignatureCache()
+	refresh := config.GetThinkingSignatureRecovery() == config.ThinkingRecoveryRefresh
 	strippedCount := 0
+	recoveredCount := 0
 
 	result := make([]types.Message, len(messages))
 	for i, msg := range messages {
@@ -288,13 +363,24 @@ func stripInvalidThinkingBlocks(messages []types.Message, targetFamily string) [
 				continue
 			}
 
-			// Check family compatibility only for Gemini targets
+			// Check family compatibility only for Gemini targets, and for Claude targets when
+			// refreshing thinking (Gemini signatures are of no use to Claude).
 			if targetFamily == "gemini" {
-				signatureFamily := sigCache.GetSignatureFamily(block.Signature)
+				signatureFamily := sigCache.PeekSignatureFamily(session, block.Signature)
+				if signatureFamily == "" {
+					if recovered, ok := recoverUnknownSignatureBlock(&block); ok {
+						filtered = append(filtered, recovered)
+						recoveredCount++
+						continue
+					}
+				}
 				if signatureFamily == "" || signatureFamily != targetFamily {
 					strippedCount++
 					continue
 				}
+			} else if refresh && incompatibleSignatureFamily(sigCache.PeekSignatureFamily(session, block.Signature), targetFamily) {
+				strippedCount++
+				continue
 			}
 
 			filtered = append(filtered, block)
@@ -315,13 +401,16 @@ func stripInvalidThinkingBlocks(messages []types.Message, targetFamily string) [
 	if strippedCount > 0 {
 		utils.Debug("[ThinkingUtils] Stripped %d invalid/incompatible thinking block(s)", strippedCount)
 	}
+	if recoveredCount > 0 {
+		utils.Debug("[ThinkingUtils] Converted %d thinking block(s) with unknown signatures to text", recoveredCount)
+	}
 
 	return result
 }
 
 // closeToolLoopForThinking closes tool loop by injecting synthetic messages.
 // This allows the model to start a fresh turn when thinking is corrupted.
-func closeToolLoopForThinking(messages []types.Message, targetFamily string) []types.Message {
+func closeToolLoopForThinking(messages []types.Message, session, targetFamily string) []types.Message {
 	state := analyzeConversationState(messages)
 
 	if !state.InToolLoop && !state.InterruptedTool {
@@ -329,7 +418,7 @@ func closeToolLoopForThinking(messages []types.Message, targetFamily string) []t
 	}
 
 	// Strip invalid/incompatible thinking blocks
-	modified := stripInvalidThinkingBlocks(messages, targetFamily)
+	modified := stripInvalidThinkingBlocks(messages, session, targetFamily)
 
 	if state.InterruptedTool {
 		// For interrupted tools: add synthetic assistant message before user's new message
@@ -9,6 +9,9 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/egress"
 )
 
 const (
@@ -29,9 +32,11 @@ const (
 	authHTTPTimeout = 30 * time.Second
 )
 
-// authClient is a shared HTTP client with timeout for auth requests.
+// authClient is a shared HTTP client with timeout for auth requests. Token exchanges
+// for an account leave through its egress config (see egress.WithConfig).
 var authClient = &http.Client{
-	Timeout: authHTTPTimeout,
+	Timeout:   authHTTPTimeout,
+	Transport: egress.Transport(),
 }
 
 // GetDeviceCode initiates the GitHub device authorization flow.
@@ -48,6 +53,7 @@ func GetDeviceCode(ctx context.Context) (*DeviceCodeResponse, error) {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 
+	config.SetProviderHeaders(req, "copilot")
 	resp, err := authClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to request device code: %w", err)
@@ -114,6 +120,7 @@ func tryGetAccessToken(ctx context.Context, deviceCode string) (string, error) {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 
+	config.SetProviderHeaders(req, "copilot")
 	resp, err := authClient.Do(req)
 	if err != nil {
 		return "", err
@@ -158,6 +165,7 @@ func GetCopilotToken(ctx context.Context, githubToken string, accountType Accoun
 	req.Header.Set("X-GitHub-Api-Version", GitHubAPIVersion)
 	req.Header.Set("User-Agent", "GitHubCopilotChat/0.26.7")
 
+	config.SetProviderHeaders(req, "copilot")
 	resp, err := authClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get copilot token: %w", err)
@@ -170,7 +178,7 @@ func GetCopilotToken(ctx context.Context, githubToken string, accountType Accoun
 	}
 
 	if resp.StatusCode == http.StatusUnauthorized {
-		return nil, fmt.Errorf("invalid or expired GitHub token")
+		return nil, &AuthError{Message: "invalid or expired GitHub token", StatusCode: resp.StatusCode}
 	}
 
 	if resp.StatusCode == http.StatusForbidden {
@@ -204,6 +212,7 @@ func GetGitHubUser(ctx context.Context, githubToken string) (*GitHubUser, error)
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("X-GitHub-Api-Version", GitHubAPIVersion)
 
+	config.SetProviderHeaders(req, "copilot")
 	resp, err := authClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user info: %w", err)
@@ -246,6 +255,7 @@ func GetCopilotUsage(ctx context.Context, githubToken string) (*CopilotUsageResp
 	req.Header.Set("X-GitHub-Api-Version", GitHubAPIVersion)
 	req.Header.Set("User-Agent", "GitHubCopilotChat/0.26.7")
 
+	config.SetProviderHeaders(req, "copilot")
 	resp, err := authClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get copilot usage: %w", err)
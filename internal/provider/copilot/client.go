@@ -11,6 +11,13 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/capture"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/egress"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/tracing"
 )
 
 const (
@@ -34,7 +41,8 @@ type Client struct {
 func NewClient(accountType AccountType) *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: DefaultTimeout,
+			Timeout:   DefaultTimeout,
+			Transport: provider.ConnectTimeoutTransport(providerName, tracing.Transport(capture.Transport(egress.Transport()))),
 		},
 		baseURL: BaseURLForAccountType(accountType),
 	}
@@ -44,7 +52,8 @@ func NewClient(accountType AccountType) *Client {
 func NewClientWithBaseURL(baseURL string) *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: DefaultTimeout,
+			Timeout:   DefaultTimeout,
+			Transport: provider.ConnectTimeoutTransport(providerName, tracing.Transport(capture.Transport(egress.Transport()))),
 		},
 		baseURL: baseURL,
 	}
@@ -109,10 +118,12 @@ func (c *Client) SendMessage(ctx context.Context, copilotToken string, payload i
 	// Set X-Initiator header based on message roles
 	req.Header.Set("X-Initiator", getInitiator(payload))
 
+	config.SetProviderHeaders(req, "copilot")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
+	provider.TraceFromContext(ctx).RecordRateLimit(resp.Header)
 	defer resp.Body.Close()
 
 	if err := c.handleErrorResponse(resp); err != nil {
@@ -196,10 +207,12 @@ func (c *Client) SendMessageStream(ctx context.Context, copilotToken string, pay
 	// Set X-Initiator header based on message roles
 	req.Header.Set("X-Initiator", getInitiator(payload))
 
+	config.SetProviderHeaders(req, "copilot")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
+	provider.TraceFromContext(ctx).RecordRateLimit(resp.Header)
 
 	if err := c.handleErrorResponse(resp); err != nil {
 		resp.Body.Close()
@@ -220,6 +233,7 @@ func (c *Client) GetModels(ctx context.Context, copilotToken string) (*ModelsRes
 		req.Header.Set(k, v)
 	}
 
+	config.SetProviderHeaders(req, "copilot")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -295,6 +309,7 @@ func (c *Client) handleErrorResponse(resp *http.Response) error {
 		return &HTTPError{
 			Message:    msg,
 			StatusCode: resp.StatusCode,
+			Body:       body,
 		}
 	}
 }
@@ -389,12 +404,18 @@ func getInitiator(payload interface{}) string {
 type HTTPError struct {
 	Message    string
 	StatusCode int
+	Body       []byte // The upstream's error response
 }
 
 func (e *HTTPError) Error() string {
 	return e.Message
 }
 
+// AnthropicError maps the OpenAI error response to the Anthropic error type.
+func (e *HTTPError) AnthropicError() *merrors.AnthropicError {
+	return merrors.FromUpstream(e.StatusCode, e.Body)
+}
+
 // AuthError represents an authentication error.
 type AuthError struct {
 	Message    string
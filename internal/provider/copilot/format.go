@@ -59,6 +59,10 @@ func TranslateToOpenAI(req *types.AnthropicRequest) (*ChatCompletionsPayload, er
 	// Convert tool choice
 	if req.ToolChoice != nil {
 		payload.ToolChoice = translateToolChoice(req.ToolChoice)
+		payload.ParallelToolCalls = parallelToolCalls(req.ToolChoice)
+	}
+	if req.Metadata != nil {
+		payload.User = req.Metadata.UserID
 	}
 
 	return payload, nil
@@ -111,6 +115,10 @@ func TranslateToOpenAIResponses(req *types.AnthropicRequest) (*ResponsesPayload,
 	// Convert tool choice
 	if req.ToolChoice != nil {
 		payload.ToolChoice = translateToolChoice(req.ToolChoice)
+		payload.ParallelToolCalls = parallelToolCalls(req.ToolChoice)
+	}
+	if req.Metadata != nil {
+		payload.User = req.Metadata.UserID
 	}
 
 	return payload, nil
@@ -161,6 +169,14 @@ func translateUserMessageToInput(blocks []types.ContentBlock) ([]ResponseInput,
 				Content:    extractToolResultContent(block),
 				ToolCallID: block.ToolUseID,
 			})
+		default:
+			// Extended blocks (search_result, mcp_tool_result, ...) have no OpenAI equivalent; degrade to text.
+			if text, ok := block.ExtendedText(); ok {
+				contentParts = append(contentParts, map[string]interface{}{
+					"type": "text",
+					"text": text,
+				})
+			}
 		}
 	}
 
@@ -223,6 +239,12 @@ func translateAssistantMessageToInput(blocks []types.ContentBlock) ([]ResponseIn
 					Arguments: string(inputJSON),
 				},
 			})
+		default:
+			// Server-side tool calls (mcp_tool_use, server_tool_use, ...) were already executed
+			// upstream, so they are kept as text rather than replayed as function calls.
+			if text, ok := block.ExtendedText(); ok {
+				textParts = append(textParts, text)
+			}
 		}
 	}
 
@@ -297,6 +319,14 @@ func translateUserMessage(blocks []types.ContentBlock) ([]Message, error) {
 				Content:    extractToolResultContent(block),
 				ToolCallID: block.ToolUseID,
 			})
+		default:
+			// Extended blocks (search_result, mcp_tool_result, ...) have no OpenAI equivalent; degrade to text.
+			if text, ok := block.ExtendedText(); ok {
+				contentParts = append(contentParts, map[string]interface{}{
+					"type": "text",
+					"text": text,
+				})
+			}
 		}
 	}
 
@@ -360,6 +390,12 @@ func translateAssistantMessage(blocks []types.ContentBlock) ([]Message, error) {
 					Arguments: string(inputJSON),
 				},
 			})
+		default:
+			// Server-side tool calls (mcp_tool_use, server_tool_use, ...) were already executed
+			// upstream, so they are kept as text rather than replayed as function calls.
+			if text, ok := block.ExtendedText(); ok {
+				textParts = append(textParts, text)
+			}
 		}
 	}
 
@@ -416,6 +452,8 @@ func extractToolResultContent(block types.ContentBlock) string {
 		for _, cb := range contentBlocks {
 			if cb.Type == "text" {
 				parts = append(parts, cb.Text)
+			} else if text, ok := cb.ExtendedText(); ok {
+				parts = append(parts, text)
 			}
 		}
 		return strings.Join(parts, "\n")
@@ -450,6 +488,16 @@ func translateToolChoice(tc *types.ToolChoice) interface{} {
 	}
 }
 
+// parallelToolCalls returns the parallel_tool_calls value for a tool_choice: false when it
+// sets disable_parallel_tool_use, nil (the upstream default) otherwise.
+func parallelToolCalls(tc *types.ToolChoice) *bool {
+	if tc.AllowsParallelToolUse() {
+		return nil
+	}
+	disabled := false
+	return &disabled
+}
+
 // TranslateToAnthropic converts an OpenAI response to Anthropic format.
 func TranslateToAnthropic(resp *ChatCompletionResponse, model string) *types.AnthropicResponse {
 	if len(resp.Choices) == 0 {
@@ -466,6 +514,11 @@ func TranslateToAnthropic(resp *ChatCompletionResponse, model string) *types.Ant
 	choice := resp.Choices[0]
 	content := translateResponseContent(choice.Message)
 
+	refused := choice.Message.Refusal != "" || choice.FinishReason == "content_filter"
+	if refused {
+		recordRefusal(model)
+	}
+
 	var usage types.Usage
 	if resp.Usage != nil {
 		usage = types.Usage{
@@ -477,15 +530,19 @@ func TranslateToAnthropic(resp *ChatCompletionResponse, model string) *types.Ant
 		}
 	}
 
-	return &types.AnthropicResponse{
+	out := &types.AnthropicResponse{
 		ID:         resp.ID,
 		Type:       "message",
 		Role:       "assistant",
 		Content:    content,
 		Model:      model,
-		StopReason: translateStopReason(choice.FinishReason),
+		StopReason: refusalStopReason(translateStopReason(choice.FinishReason), refused),
 		Usage:      usage,
 	}
+	if seq := matchedStopSequence(choice.FinishReason, choice.StopReason); seq != "" && out.StopReason == "end_turn" {
+		out.StopReason, out.StopSequence = "stop_sequence", &seq
+	}
+	return out
 }
 
 // translateResponseContent converts OpenAI message content to Anthropic content blocks.
@@ -505,6 +562,14 @@ func translateResponseContent(msg Message) []types.ContentBlock {
 		}
 	}
 
+	// Handle content-policy refusals
+	if msg.Refusal != "" {
+		blocks = append(blocks, types.ContentBlock{
+			Type: "text",
+			Text: refusalText(msg.Refusal, true),
+		})
+	}
+
 	// Handle tool calls
 	for _, tc := range msg.ToolCalls {
 		var input map[string]interface{}
@@ -524,6 +589,17 @@ func translateResponseContent(msg Message) []types.ContentBlock {
 	return blocks
 }
 
+// matchedStopSequence returns the stop sequence a choice finished at. OpenAI reports
+// finish_reason "stop" for stop sequences and natural ends alike; some OpenAI-compatible
+// upstreams add the matched string as stop_reason (a token ID or null otherwise).
+func matchedStopSequence(finishReason string, stopReason interface{}) string {
+	if finishReason != "stop" {
+		return ""
+	}
+	seq, _ := stopReason.(string)
+	return seq
+}
+
 // translateStopReason converts OpenAI finish_reason to Anthropic stop_reason.
 func translateStopReason(reason string) string {
 	switch reason {
@@ -564,6 +640,10 @@ func TranslateResponsesAPIToAnthropic(resp *ResponsesAPIResponse, model string)
 
 	// Determine stop reason from status
 	stopReason := translateResponsesStatus(resp.Status)
+	if responsesRefused(resp.Output) {
+		recordRefusal(model)
+		stopReason = refusalStopReason(stopReason, true)
+	}
 
 	// Generate a proper message ID (the API's ID may be a token/signature)
 	messageID := GenerateMessageID()
@@ -667,7 +747,7 @@ func extractContentFromPart(partMap map[string]interface{}) []types.ContentBlock
 		if refusal, ok := partMap["refusal"].(string); ok && refusal != "" {
 			blocks = append(blocks, types.ContentBlock{
 				Type: "text",
-				Text: "[Refusal] " + refusal,
+				Text: refusalText(refusal, true),
 			})
 		}
 	}
@@ -2,6 +2,8 @@ package copilot
 
 import (
 	"encoding/json"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
@@ -193,6 +195,28 @@ func TestTranslateToAnthropic_WithToolCalls(t *testing.T) {
 	}
 }
 
+func TestTranslateToAnthropic_MatchedStopSequence(t *testing.T) {
+	resp := &ChatCompletionResponse{
+		ID: "chatcmpl-789",
+		Choices: []Choice{
+			{
+				Message:      Message{Role: "assistant", Content: "1, 2, 3"},
+				FinishReason: "stop",
+				StopReason:   "4",
+			},
+		},
+	}
+
+	anthropicResp := TranslateToAnthropic(resp, "gpt-4")
+
+	if anthropicResp.StopReason != "stop_sequence" {
+		t.Errorf("expected stop reason stop_sequence, got %s", anthropicResp.StopReason)
+	}
+	if anthropicResp.StopSequence == nil || *anthropicResp.StopSequence != "4" {
+		t.Errorf("expected stop sequence 4, got %v", anthropicResp.StopSequence)
+	}
+}
+
 func TestTranslateStopReason(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -241,6 +265,62 @@ func TestTranslateToolChoice(t *testing.T) {
 	}
 }
 
+func TestTranslateToOpenAI_DisableParallelToolUse(t *testing.T) {
+	req := &types.AnthropicRequest{
+		Model:     "gpt-4",
+		MaxTokens: 1000,
+		Messages: []types.Message{
+			{Role: "user", Content: json.RawMessage(`"What's the weather?"`)},
+		},
+		Tools:      []types.Tool{{Name: "get_weather", InputSchema: map[string]interface{}{"type": "object"}}},
+		ToolChoice: &types.ToolChoice{Type: "auto"},
+	}
+
+	payload, err := TranslateToOpenAI(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payload.ParallelToolCalls != nil {
+		t.Errorf("expected parallel_tool_calls to be omitted, got %v", *payload.ParallelToolCalls)
+	}
+
+	req.ToolChoice.DisableParallelToolUse = true
+	payload, err = TranslateToOpenAI(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payload.ParallelToolCalls == nil || *payload.ParallelToolCalls {
+		t.Errorf("expected parallel_tool_calls false, got %v", payload.ParallelToolCalls)
+	}
+}
+
+func TestTranslateToOpenAI_MetadataUserID(t *testing.T) {
+	req := &types.AnthropicRequest{
+		Model:     "gpt-4",
+		MaxTokens: 1000,
+		Messages: []types.Message{
+			{Role: "user", Content: json.RawMessage(`"Hello"`)},
+		},
+	}
+
+	payload, err := TranslateToOpenAI(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payload.User != "" {
+		t.Errorf("expected user to be omitted, got %q", payload.User)
+	}
+
+	req.Metadata = &types.Metadata{UserID: "abc123"}
+	payload, err = TranslateToOpenAI(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payload.User != "abc123" {
+		t.Errorf("expected user abc123, got %q", payload.User)
+	}
+}
+
 func TestParseBase64Image_Valid(t *testing.T) {
 	// Small valid PNG header in base64
 	dataURL := "data:image/png;base64,iVBORw0KGgo="
@@ -315,3 +395,40 @@ func TestIsBase64Image(t *testing.T) {
 		}
 	}
 }
+
+func TestTranslateToOpenAI_ExtendedBlocks(t *testing.T) {
+	data, err := os.ReadFile("../../../pkg/types/testdata/extended_blocks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req types.AnthropicRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := TranslateToOpenAI(&req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out, _ := json.Marshal(payload.Messages)
+	got := string(out)
+	for _, want := range []string{
+		`Search result: Proxy guide\nSource: https://docs.example.com/proxy\nAccounts rotate on rate limits.`,
+		`Let me check the docs server.MCP tool call docs/search_docs: {\"query\":\"rotation\"}`,
+		`MCP tool result (mcptoolu_01): Rotation is round-robin.`,
+		`Search result: Limits\nSource: https://docs.example.com/limits\nLimits are per model.`,
+		`Unregistered block with text.`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("translated messages missing %q\n%s", want, got)
+		}
+	}
+
+	// Server-side tool calls must not be replayed as function calls.
+	for _, msg := range payload.Messages {
+		if len(msg.ToolCalls) > 0 {
+			t.Errorf("unexpected tool calls: %+v", msg.ToolCalls)
+		}
+	}
+}
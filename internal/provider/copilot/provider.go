@@ -9,6 +9,7 @@ import (
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/account"
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
@@ -22,29 +23,46 @@ type Provider struct {
 	modelIDs       []string
 	modelSet       map[string]bool
 	modelEndpoints map[string]string // model ID -> preferred endpoint
+	initFailures   map[string]string // account email -> model fetch error from Initialize
 	modelsMu       sync.RWMutex
+	rotation       *provider.Rotation
 
 	// Token cache: account email -> cached copilot token
 	tokenCache   map[string]*cachedToken
+	authFailures map[string]int // account email -> consecutive 401s from the token endpoint
 	tokenCacheMu sync.RWMutex
+	fetchToken   func(ctx context.Context, githubToken string, accountType AccountType) (*CopilotTokenResponse, error)
+	refreshStop  chan struct{} // Stops the background token refresh goroutine
 }
 
-// cachedToken stores a Copilot token with its expiry.
+// cachedToken stores a Copilot token with its expiry and when it should be refreshed.
 type cachedToken struct {
 	token     string
 	expiresAt time.Time
+	refreshAt time.Time
 }
 
 // NewProvider creates a new Copilot provider.
 func NewProvider(accountManager *account.Manager) *Provider {
-	return &Provider{
+	p := &Provider{
 		accountManager: accountManager,
 		models:         []Model{},
 		modelIDs:       []string{},
 		modelSet:       make(map[string]bool),
 		modelEndpoints: make(map[string]string),
 		tokenCache:     make(map[string]*cachedToken),
+		authFailures:   make(map[string]int),
+		fetchToken:     GetCopilotToken,
 	}
+	p.rotation = &provider.Rotation{
+		Provider: providerName,
+		Name:     "Copilot",
+		Accounts: accountManager,
+		Policy:   config.GetRetryPolicy(providerName),
+		Classify: classifyError,
+		Reject:   p.rejectAccount,
+	}
+	return p
 }
 
 // Name returns the provider identifier.
@@ -80,14 +98,18 @@ func (p *Provider) GetModelEndpoint(model string) string {
 }
 
 // Initialize performs any setup required by the provider.
+// Models are fetched from all valid accounts in parallel; the first success wins.
 func (p *Provider) Initialize(ctx context.Context) error {
+	p.startTokenRefresh()
+
 	accounts := p.accountManager.GetAllAccountsByProvider(providerName)
 	if len(accounts) == 0 {
 		utils.Debug("[Copilot] No Copilot accounts configured, skipping initialization")
 		return nil
 	}
 
-	// Use the first available account to fetch models
+	byEmail := make(map[string]account.Account, len(accounts))
+	emails := make([]string, 0, len(accounts))
 	for _, acc := range accounts {
 		if acc.IsInvalid {
 			continue
@@ -95,186 +117,153 @@ func (p *Provider) Initialize(ctx context.Context) error {
 		if acc.RefreshToken == "" {
 			continue
 		}
+		byEmail[acc.Email] = acc
+		emails = append(emails, acc.Email)
+	}
 
-		// Get Copilot token
-		copilotToken, err := p.getCopilotToken(ctx, &acc)
-		if err != nil {
-			utils.Warn("[Copilot] Failed to get token for account %s: %v", acc.Email, err)
-			continue
-		}
+	modelsResp, _, failures, err := provider.FetchFirst(ctx, config.ModelPrefetchTimeout, emails,
+		func(ctx context.Context, email string) (*ModelsResponse, error) {
+			acc := byEmail[email]
 
-		// Get account type
-		accountType := getAccountType(&acc)
-		client := NewClient(accountType)
+			// Get Copilot token
+			copilotToken, err := p.getCopilotToken(ctx, &acc)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get token: %w", err)
+			}
 
-		// Fetch models
-		modelsResp, err := client.GetModels(ctx, copilotToken)
-		if err != nil {
-			utils.Warn("[Copilot] Failed to fetch models using account %s: %v", acc.Email, err)
-			continue
-		}
+			// Fetch models with the client matching the account type
+			client := NewClient(getAccountType(&acc))
+			return client.GetModels(acc.RequestContext(ctx), copilotToken)
+		})
 
-		// Filter to model_picker_enabled models
-		p.modelsMu.Lock()
-		p.models = []Model{}
-		p.modelIDs = []string{}
-		p.modelSet = make(map[string]bool)
-		p.modelEndpoints = make(map[string]string)
-
-		for _, m := range modelsResp.Data {
-			if m.ModelPickerEnabled {
-				p.models = append(p.models, m)
-				p.modelIDs = append(p.modelIDs, m.ID)
-				p.modelSet[m.ID] = true
-				p.modelEndpoints[m.ID] = m.PreferredEndpoint()
-			}
-		}
-		p.modelsMu.Unlock()
+	for email, failErr := range failures {
+		utils.Warn("[Copilot] Failed to fetch models using account %s: %v", email, failErr)
+	}
+	p.modelsMu.Lock()
+	p.initFailures = provider.FailureMessages(failures)
+	p.modelsMu.Unlock()
 
-		utils.Success("[Copilot] Provider initialized with %d models", len(p.modelIDs))
+	if err != nil {
+		utils.Warn("[Copilot] No valid Copilot accounts available to fetch models")
 		return nil
 	}
 
-	utils.Warn("[Copilot] No valid Copilot accounts available to fetch models")
+	// Filter to model_picker_enabled models
+	p.modelsMu.Lock()
+	p.models = []Model{}
+	p.modelIDs = []string{}
+	p.modelSet = make(map[string]bool)
+	p.modelEndpoints = make(map[string]string)
+
+	for _, m := range modelsResp.Data {
+		if m.ModelPickerEnabled {
+			p.models = append(p.models, m)
+			p.modelIDs = append(p.modelIDs, m.ID)
+			p.modelSet[m.ID] = true
+			p.modelEndpoints[m.ID] = m.PreferredEndpoint()
+		}
+	}
+	p.modelsMu.Unlock()
+
+	utils.Success("[Copilot] Provider initialized with %d models", len(p.modelIDs))
 	return nil
 }
 
+// InitFailures returns the accounts that failed to fetch models during Initialize.
+func (p *Provider) InitFailures() map[string]string {
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	result := make(map[string]string, len(p.initFailures))
+	for k, v := range p.initFailures {
+		result[k] = v
+	}
+	return result
+}
+
 // Shutdown performs cleanup when the provider is being stopped.
 func (p *Provider) Shutdown(ctx context.Context) error {
 	utils.Debug("[Copilot] Provider shutting down")
+	p.tokenCacheMu.Lock()
+	if p.refreshStop != nil {
+		close(p.refreshStop)
+		p.refreshStop = nil
+	}
+	p.tokenCacheMu.Unlock()
 	return nil
 }
 
 // SendMessage handles non-streaming requests.
 func (p *Provider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
-	maxAttempts := config.MaxRetries
-	if count := p.accountManager.GetAccountCountByProvider(providerName) + 1; count > maxAttempts {
-		maxAttempts = count
+	req, err := p.prepareImages(req)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, payload, err := p.translateRequest(req)
+	if err != nil {
+		return nil, err
 	}
 
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		acc := p.accountManager.PickNextByProvider(providerName, req.Model)
-
-		// Handle all accounts rate-limited
-		if acc == nil && p.accountManager.IsAllRateLimitedByProvider(providerName, req.Model) {
-			var err error
-			acc, err = p.waitForRateLimitReset(ctx, req.Model)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		if acc == nil {
-			return nil, fmt.Errorf("no Copilot accounts available")
-		}
-
-		// Get Copilot token
+	var resp *types.AnthropicResponse
+	err = p.rotation.Run(ctx, req.Model, func(at *provider.Attempt) error {
+		acc := at.Account
 		copilotToken, err := p.getCopilotToken(ctx, acc)
 		if err != nil {
-			utils.Warn("[Copilot] Failed to get token for %s: %v, trying next...", acc.Email, err)
-			p.accountManager.MarkInvalid(acc.Email, err.Error())
-			continue
-		}
-
-		// Get endpoint for this model
-		endpoint := p.GetModelEndpoint(req.Model)
-
-		// Convert request to correct OpenAI format based on endpoint
-		var payload interface{}
-		if endpoint == "/responses" {
-			payload, err = TranslateToOpenAIResponses(req)
-		} else {
-			payload, err = TranslateToOpenAI(req)
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert request: %w", err)
+			return err
 		}
 
 		// Create client with correct account type
-		accountType := getAccountType(acc)
-		client := NewClient(accountType)
+		client := NewClient(getAccountType(acc))
 
-		// Send request
-		openAIResp, err := client.SendMessage(ctx, copilotToken, payload, endpoint)
+		at.Begin(ctx)
+		openAIResp, err := client.SendMessage(acc.RequestContext(ctx), copilotToken, payload, endpoint)
 		if err != nil {
-			if p.handleRequestError(err, acc, req.Model) == retryActionContinue {
-				continue
-			}
-			return nil, err
+			return err
 		}
 
 		// Convert response to Anthropic format based on response type
-		var resp *types.AnthropicResponse
 		switch r := openAIResp.(type) {
 		case *ChatCompletionResponse:
 			resp = TranslateToAnthropic(r, req.Model)
 		case *ResponsesAPIResponse:
 			resp = TranslateResponsesAPIToAnthropic(r, req.Model)
 		default:
-			return nil, fmt.Errorf("unexpected response type: %T", openAIResp)
+			return fmt.Errorf("unexpected response type: %T", openAIResp)
 		}
-		return resp, nil
+		at.End(nil, len(resp.Content) == 0)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, fmt.Errorf("max retries exceeded")
+	return resp, nil
 }
 
 // SendMessageStream handles streaming requests.
 func (p *Provider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
-	maxAttempts := config.MaxRetries
-	if count := p.accountManager.GetAccountCountByProvider(providerName) + 1; count > maxAttempts {
-		maxAttempts = count
+	req, err := p.prepareImages(req)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, payload, err := p.translateRequest(req)
+	if err != nil {
+		return nil, err
 	}
 
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		acc := p.accountManager.PickNextByProvider(providerName, req.Model)
-
-		// Handle all accounts rate-limited
-		if acc == nil && p.accountManager.IsAllRateLimitedByProvider(providerName, req.Model) {
-			var err error
-			acc, err = p.waitForRateLimitReset(ctx, req.Model)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		if acc == nil {
-			return nil, fmt.Errorf("no Copilot accounts available")
-		}
-
-		// Get Copilot token
+	var stream <-chan types.StreamEvent
+	err = p.rotation.Run(ctx, req.Model, func(at *provider.Attempt) error {
+		acc := at.Account
 		copilotToken, err := p.getCopilotToken(ctx, acc)
 		if err != nil {
-			utils.Warn("[Copilot] Failed to get token for %s: %v, trying next...", acc.Email, err)
-			p.accountManager.MarkInvalid(acc.Email, err.Error())
-			continue
-		}
-
-		// Get endpoint for this model
-		endpoint := p.GetModelEndpoint(req.Model)
-
-		// Convert request to correct OpenAI format based on endpoint
-		var payload interface{}
-		if endpoint == "/responses" {
-			payload, err = TranslateToOpenAIResponses(req)
-		} else {
-			payload, err = TranslateToOpenAI(req)
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert request: %w", err)
+			return err
 		}
 
 		// Create client with correct account type
-		accountType := getAccountType(acc)
-		client := NewClient(accountType)
+		client := NewClient(getAccountType(acc))
 
-		// Send streaming request
-		reader, err := client.SendMessageStream(ctx, copilotToken, payload, endpoint)
+		at.Begin(ctx)
+		reader, err := client.SendMessageStream(acc.RequestContext(ctx), copilotToken, payload, endpoint)
 		if err != nil {
-			if p.handleRequestError(err, acc, req.Model) == retryActionContinue {
-				continue
-			}
-			return nil, err
+			return err
 		}
 
 		// Parse SSE stream and convert to Anthropic format
@@ -288,23 +277,48 @@ func (p *Provider) SendMessageStream(ctx context.Context, req *types.AnthropicRe
 
 		// Create output channel that will close reader when done
 		outCh := make(chan types.StreamEvent, 100)
+		tracker := account.NewStreamTracker(acc.Email, providerName, req.Model, at.Start())
+		at.Detach()
 		go func() {
 			defer close(outCh)
 			defer reader.Close()
 
 			for evt := range events {
+				tracker.Observe(evt)
 				select {
 				case outCh <- evt:
 				case <-ctx.Done():
+					p.accountManager.ReportResult(tracker.Result(ctx.Err()))
 					return
 				}
 			}
+			p.accountManager.ReportResult(tracker.Result(ctx.Err()))
 		}()
 
-		return outCh, nil
+		stream = outCh
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return stream, nil
+}
+
+// translateRequest converts req to the OpenAI format of the endpoint that serves its model.
+func (p *Provider) translateRequest(req *types.AnthropicRequest) (string, interface{}, error) {
+	endpoint := p.GetModelEndpoint(req.Model)
 
-	return nil, fmt.Errorf("max retries exceeded")
+	var payload interface{}
+	var err error
+	if endpoint == "/responses" {
+		payload, err = TranslateToOpenAIResponses(req)
+	} else {
+		payload, err = TranslateToOpenAI(req)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to convert request: %w", err)
+	}
+	return endpoint, payload, nil
 }
 
 // ListModels returns available models with metadata.
@@ -408,7 +422,8 @@ func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGeneration
 }
 
 // getCopilotToken gets a valid Copilot token for the account.
-// Uses caching to avoid unnecessary token exchanges.
+// Tokens are normally kept fresh by the background refresher (see startTokenRefresh);
+// a token that is missing or about to expire is fetched here.
 func (p *Provider) getCopilotToken(ctx context.Context, acc *account.Account) (string, error) {
 	// Check cache first
 	p.tokenCacheMu.RLock()
@@ -418,29 +433,7 @@ func (p *Provider) getCopilotToken(ctx context.Context, acc *account.Account) (s
 	if ok && time.Now().Before(cached.expiresAt.Add(-60*time.Second)) {
 		return cached.token, nil
 	}
-
-	// Get GitHub token (stored as RefreshToken)
-	githubToken := acc.RefreshToken
-	if githubToken == "" {
-		return "", fmt.Errorf("no GitHub token for account %s", acc.Email)
-	}
-
-	// Exchange for Copilot token
-	accountType := getAccountType(acc)
-	tokenResp, err := GetCopilotToken(ctx, githubToken, accountType)
-	if err != nil {
-		return "", err
-	}
-
-	// Cache the token
-	p.tokenCacheMu.Lock()
-	p.tokenCache[acc.Email] = &cachedToken{
-		token:     tokenResp.Token,
-		expiresAt: time.Unix(tokenResp.ExpiresAt, 0),
-	}
-	p.tokenCacheMu.Unlock()
-
-	return tokenResp.Token, nil
+	return p.refreshCopilotToken(ctx, acc)
 }
 
 // invalidateToken removes a cached token.
@@ -464,85 +457,29 @@ func getAccountType(acc *account.Account) AccountType {
 	}
 }
 
-func sleepWithContext(ctx context.Context, d time.Duration) error {
-	if d <= 0 {
-		return nil
-	}
-	select {
-	case <-time.After(d):
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
-	}
-}
-
-// waitForRateLimitReset waits for rate limit to reset or returns an error if wait is too long.
-func (p *Provider) waitForRateLimitReset(ctx context.Context, modelID string) (*account.Account, error) {
-	allWaitMs := p.accountManager.GetMinWaitTimeMsByProvider(providerName, modelID)
-	waitDur := time.Duration(allWaitMs) * time.Millisecond
-	resetTime := time.Now().Add(waitDur).UTC().Format("2006-01-02T15:04:05.000Z")
-
-	if waitDur > config.MaxWaitBeforeError {
-		return nil, fmt.Errorf(
-			"RESOURCE_EXHAUSTED: Rate limited on %s. Quota will reset after %s. Next available: %s",
-			modelID,
-			utils.FormatDuration(waitDur),
-			resetTime,
-		)
-	}
-
-	accountCount := p.accountManager.GetAccountCountByProvider(providerName)
-	utils.Warn("[Copilot] All %d account(s) rate-limited. Waiting %s...",
-		accountCount,
-		utils.FormatDuration(waitDur),
-	)
-
-	if err := sleepWithContext(ctx, waitDur); err != nil {
-		return nil, err
-	}
-	if err := sleepWithContext(ctx, config.PostRateLimitBuffer); err != nil {
-		return nil, err
-	}
-	p.accountManager.ResetAllRateLimitsByProvider(providerName)
-
-	return p.accountManager.PickNextByProvider(providerName, modelID), nil
-}
-
-// retryAction indicates what action to take after handling an error.
-type retryAction int
-
-const (
-	retryActionContinue retryAction = iota // Retry with next account
-	retryActionFail                        // Return the error
-)
-
-// handleRequestError processes an error and returns whether to retry.
-func (p *Provider) handleRequestError(err error, acc *account.Account, modelID string) retryAction {
-	// Rate limited
+// classifyError describes a Copilot API error for account selection.
+func classifyError(err error) provider.Failure {
 	var rateLimitErr *RateLimitError
-	if errors.As(err, &rateLimitErr) {
-		p.accountManager.MarkRateLimited(acc.Email, rateLimitErr.RetryAfterMs(), modelID)
-		utils.Info("[Copilot] Account %s rate-limited, trying next...", acc.Email)
-		return retryActionContinue
-	}
-
-	// Auth error
 	var authErr *AuthError
-	if errors.As(err, &authErr) {
-		p.invalidateToken(acc.Email)
-		p.accountManager.MarkInvalid(acc.Email, "authentication failed")
-		utils.Warn("[Copilot] Account %s auth failed, trying next...", acc.Email)
-		return retryActionContinue
-	}
-
-	// HTTP error - retry on 5xx
 	var httpErr *HTTPError
-	if errors.As(err, &httpErr) {
-		if httpErr.StatusCode >= 500 {
-			utils.Warn("[Copilot] Account %s failed with %d error, trying next...", acc.Email, httpErr.StatusCode)
-			return retryActionContinue
-		}
+	switch {
+	case errors.As(err, &rateLimitErr):
+		return provider.Failure{StatusCode: 429, RateLimited: true, ResetMs: rateLimitErr.RetryAfterMs()}
+	case errors.As(err, &authErr):
+		return provider.Failure{StatusCode: 401}
+	case errors.As(err, &httpErr):
+		return provider.Failure{StatusCode: httpErr.StatusCode}
 	}
+	return provider.Failure{}
+}
 
-	return retryActionFail
+// rejectAccount marks an account invalid when Copilot rejects its token.
+func (p *Provider) rejectAccount(acc *account.Account, status int) bool {
+	if status != 401 {
+		return false
+	}
+	p.invalidateToken(acc.Email)
+	p.accountManager.MarkInvalid(acc.Email, "authentication failed")
+	utils.Warn("[Copilot] Account %s auth failed, trying next...", acc.Email)
+	return true
 }
@@ -0,0 +1,64 @@
+package copilot
+
+import (
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+)
+
+// refusalPrefix marks refusal text in config.RefusalModeText.
+const refusalPrefix = "[Refusal] "
+
+// refusalStopReason returns the Anthropic stop_reason for a response. Refused responses
+// (a refusal part or a content_filter finish) stop with "refusal" unless COPILOT_REFUSAL_MODE=text.
+func refusalStopReason(stopReason string, refused bool) string {
+	if refused && config.GetCopilotRefusalMode() == config.RefusalModeStopReason {
+		return "refusal"
+	}
+	return stopReason
+}
+
+// refusalText returns refusal text for a text block. In text mode the first chunk of a
+// refusal is prefixed so clients can still spot it.
+func refusalText(text string, first bool) string {
+	if first && config.GetCopilotRefusalMode() == config.RefusalModeText {
+		return refusalPrefix + text
+	}
+	return text
+}
+
+// recordRefusal counts a refused response in the refusals metric.
+func recordRefusal(model string) {
+	metrics.Refusals.Inc(providerName, model)
+}
+
+// responsesRefused reports whether a Responses API output contains a refusal part.
+func responsesRefused(output []ResponseOutputItem) bool {
+	for _, item := range output {
+		if item.Type != "message" {
+			continue
+		}
+		switch content := item.Content.(type) {
+		case []interface{}:
+			for _, part := range content {
+				if partMap, ok := part.(map[string]interface{}); ok && partMap["type"] == "refusal" {
+					return true
+				}
+			}
+		case map[string]interface{}:
+			if content["type"] == "refusal" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// refusalStop returns the stop_reason for the end of a stream and counts a refusal once per stream.
+func (state *StreamState) refusalStop(stopReason string, contentFiltered bool) string {
+	refused := state.refused || contentFiltered
+	if refused && !state.refusalRecorded {
+		state.refusalRecorded = true
+		recordRefusal(state.Model)
+	}
+	return refusalStopReason(stopReason, refused)
+}
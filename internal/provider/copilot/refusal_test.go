@@ -0,0 +1,147 @@
+package copilot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestTranslateToAnthropic_Refusal(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		message    Message
+		finish     string
+		wantText   string
+		wantReason string
+	}{
+		{"refusal part", "", Message{Role: "assistant", Refusal: "I can't help with that."}, "stop", "I can't help with that.", "refusal"},
+		{"content filter", "", Message{Role: "assistant"}, "content_filter", "", "refusal"},
+		{"text mode", "text", Message{Role: "assistant", Refusal: "I can't help with that."}, "stop", "[Refusal] I can't help with that.", "end_turn"},
+		{"no refusal", "", Message{Role: "assistant", Content: "Sure."}, "stop", "Sure.", "end_turn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COPILOT_REFUSAL_MODE", tt.mode)
+			metrics.Refusals.Reset()
+
+			resp := TranslateToAnthropic(&ChatCompletionResponse{
+				ID:      "chatcmpl-1",
+				Choices: []Choice{{Message: tt.message, FinishReason: tt.finish}},
+			}, "gpt-4o")
+
+			if resp.StopReason != tt.wantReason {
+				t.Errorf("stop_reason = %q, want %q", resp.StopReason, tt.wantReason)
+			}
+			var text string
+			for _, b := range resp.Content {
+				text += b.Text
+			}
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+
+			wantCount := uint64(1)
+			if tt.name == "no refusal" {
+				wantCount = 0
+			}
+			if n := metrics.Refusals.Count("copilot", "gpt-4o"); n != wantCount {
+				t.Errorf("refusal count = %d, want %d", n, wantCount)
+			}
+		})
+	}
+}
+
+func TestTranslateResponsesAPIToAnthropic_Refusal(t *testing.T) {
+	t.Setenv("COPILOT_REFUSAL_MODE", "")
+	metrics.Refusals.Reset()
+
+	resp := TranslateResponsesAPIToAnthropic(&ResponsesAPIResponse{
+		Status: "completed",
+		Output: []ResponseOutputItem{{
+			Type:    "message",
+			Content: []interface{}{map[string]interface{}{"type": "refusal", "refusal": "No."}},
+		}},
+	}, "gpt-5")
+
+	if resp.StopReason != "refusal" || len(resp.Content) != 1 || resp.Content[0].Text != "No." {
+		t.Errorf("unexpected response: stop_reason=%q content=%+v", resp.StopReason, resp.Content)
+	}
+	if n := metrics.Refusals.Count("copilot", "gpt-5"); n != 1 {
+		t.Errorf("refusal count = %d, want 1", n)
+	}
+}
+
+func streamText(events []types.StreamEvent) (text, stopReason string) {
+	for _, e := range events {
+		if e.Type == "content_block_delta" && e.Delta != nil {
+			text += e.Delta.Text
+		}
+		if e.Type == "message_delta" && e.Delta != nil {
+			stopReason = e.Delta.StopReason
+		}
+	}
+	return text, stopReason
+}
+
+func TestParseSSEStream_Refusal(t *testing.T) {
+	sseData := `data: {"id":"1","choices":[{"index":0,"delta":{"role":"assistant","refusal":"I can't "}}]}
+
+data: {"id":"1","choices":[{"index":0,"delta":{"refusal":"help with that."}}]}
+
+data: {"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: [DONE]
+`
+	for _, tt := range []struct {
+		mode, wantText, wantReason string
+	}{
+		{"", "I can't help with that.", "refusal"},
+		{"text", "[Refusal] I can't help with that.", "end_turn"},
+	} {
+		t.Run("mode="+tt.mode, func(t *testing.T) {
+			t.Setenv("COPILOT_REFUSAL_MODE", tt.mode)
+			metrics.Refusals.Reset()
+
+			var events []types.StreamEvent
+			for e := range ParseSSEStream(context.Background(), strings.NewReader(sseData), "gpt-4o") {
+				events = append(events, e)
+			}
+
+			text, stopReason := streamText(events)
+			if text != tt.wantText || stopReason != tt.wantReason {
+				t.Errorf("got text %q stop_reason %q, want %q %q", text, stopReason, tt.wantText, tt.wantReason)
+			}
+			if n := metrics.Refusals.Count("copilot", "gpt-4o"); n != 1 {
+				t.Errorf("refusal count = %d, want 1", n)
+			}
+		})
+	}
+}
+
+func TestParseSSEStreamResponses_Refusal(t *testing.T) {
+	t.Setenv("COPILOT_REFUSAL_MODE", "")
+	metrics.Refusals.Reset()
+
+	sseData := `data: {"type":"response.created"}
+
+data: {"type":"response.refusal.delta","delta":"No."}
+
+data: {"type":"response.completed"}
+`
+	var events []types.StreamEvent
+	for e := range ParseSSEStreamResponses(context.Background(), strings.NewReader(sseData), "gpt-5") {
+		events = append(events, e)
+	}
+
+	text, stopReason := streamText(events)
+	if text != "No." || stopReason != "refusal" {
+		t.Errorf("got text %q stop_reason %q", text, stopReason)
+	}
+	if n := metrics.Refusals.Count("copilot", "gpt-5"); n != 1 {
+		t.Errorf("refusal count = %d, want 1", n)
+	}
+}
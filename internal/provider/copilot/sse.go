@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"strings"
 
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
 
@@ -15,9 +17,18 @@ type StreamState struct {
 	MessageStartSent  bool
 	ContentBlockIndex int
 	ContentBlockOpen  bool
-	ToolCalls         map[int]*ToolCallState // OpenAI tool index -> state
+	ToolCalls         map[int]*ToolCallState // OpenAI tool index -> state, for calls whose block has started
 	Model             string
 	MessageID         string
+
+	// Parallel tool calls that arrive while another tool block is still receiving
+	// arguments wait here, in arrival order, until that block completes.
+	pendingTools map[int]*ToolCallState
+	toolQueue    []int
+
+	refused         bool // a refusal delta was streamed
+	refusalRecorded bool
+	messageStopSent bool
 }
 
 // ToolCallState tracks the state of a tool call being streamed.
@@ -25,14 +36,16 @@ type ToolCallState struct {
 	ID                  string
 	Name                string
 	AnthropicBlockIndex int
+	Arguments           string // Argument JSON received so far
 }
 
 // NewStreamState creates a new stream state.
 func NewStreamState(model string) *StreamState {
 	return &StreamState{
-		ToolCalls: make(map[int]*ToolCallState),
-		Model:     model,
-		MessageID: GenerateMessageID(),
+		ToolCalls:    make(map[int]*ToolCallState),
+		Model:        model,
+		MessageID:    GenerateMessageID(),
+		pendingTools: make(map[int]*ToolCallState),
 	}
 }
 
@@ -56,6 +69,8 @@ func parseSSEStreamWithFormat(ctx context.Context, reader io.Reader, model strin
 
 		state := NewStreamState(model)
 		scanner := bufio.NewScanner(reader)
+		// Tool call arguments can arrive in large chunks; allow lines up to 1MB.
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 
 		for scanner.Scan() {
 			// Check for context cancellation
@@ -84,6 +99,15 @@ func parseSSEStreamWithFormat(ctx context.Context, reader io.Reader, model strin
 				break
 			}
 
+			// An upstream error ends the stream.
+			if event, ok := upstreamErrorEvent(data); ok {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+				}
+				return
+			}
+
 			// Try to parse based on format
 			var anthropicEvents []types.StreamEvent
 			if isResponses {
@@ -106,14 +130,28 @@ func parseSSEStreamWithFormat(ctx context.Context, reader io.Reader, model strin
 			}
 		}
 
-		// Ensure we close any open content block
-		if state.ContentBlockOpen {
+		// A read error truncates the stream: report it rather than closing the message.
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
 			select {
-			case events <- types.StreamEvent{
+			case events <- CreateErrorEvent(string(merrors.ErrorTypeAPI), fmt.Sprintf("stream read error: %v", err)):
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		// Ensure queued tool calls are emitted and any open content block is closed
+		final := flushPendingTools(state)
+		if state.ContentBlockOpen {
+			final = append(final, types.StreamEvent{
 				Type:  "content_block_stop",
 				Index: state.ContentBlockIndex,
-			}:
+			})
+		}
+		for _, event := range final {
+			select {
+			case events <- event:
 			case <-ctx.Done():
+				return
 			}
 		}
 	}()
@@ -164,9 +202,15 @@ func handleResponsesStreamEvent(event *ResponsesStreamEvent, state *StreamState)
 			state.MessageStartSent = true
 		}
 
-	case "response.output_text.delta":
-		// Handle text delta
+	case "response.output_text.delta", "response.refusal.delta":
+		// Handle text delta; refusals are streamed as text
 		if event.Delta != "" {
+			text := event.Delta
+			if event.Type == "response.refusal.delta" {
+				text = refusalText(text, !state.refused)
+				state.refused = true
+			}
+
 			// Ensure content block is open
 			if !state.ContentBlockOpen {
 				events = append(events, types.StreamEvent{
@@ -185,33 +229,20 @@ func handleResponsesStreamEvent(event *ResponsesStreamEvent, state *StreamState)
 				Index: state.ContentBlockIndex,
 				Delta: &types.Delta{
 					Type: "text_delta",
-					Text: event.Delta,
+					Text: text,
 				},
 			})
 		}
 
-	case "response.output_text.done", "response.done", "response.completed":
-		// Close content block if open
-		if state.ContentBlockOpen {
-			events = append(events, types.StreamEvent{
-				Type:  "content_block_stop",
-				Index: state.ContentBlockIndex,
-			})
-			state.ContentBlockOpen = false
-		}
+	case "response.output_text.done":
+		events = append(events, closeResponsesTextBlock(state)...)
 
-		// Send message_delta with stop_reason
-		events = append(events, types.StreamEvent{
-			Type: "message_delta",
-			Delta: &types.Delta{
-				StopReason: "end_turn",
-			},
-		})
-
-		// Send message_stop
-		events = append(events, types.StreamEvent{
-			Type: "message_stop",
-		})
+	case "response.done", "response.completed":
+		var usage *ResponsesUsage
+		if event.Response != nil {
+			usage = event.Response.Usage
+		}
+		events = append(events, finishResponsesMessage(usage, state)...)
 	}
 
 	return events
@@ -237,12 +268,17 @@ func handleResponsesEventByType(eventType string, event map[string]interface{},
 			state.MessageStartSent = true
 		}
 
-	case "response.output_text.delta":
+	case "response.output_text.delta", "response.refusal.delta":
 		delta := ""
 		if d, ok := event["delta"].(string); ok {
 			delta = d
 		}
 		if delta != "" {
+			if eventType == "response.refusal.delta" {
+				delta = refusalText(delta, !state.refused)
+				state.refused = true
+			}
+
 			if !state.ContentBlockOpen {
 				events = append(events, types.StreamEvent{
 					Type:  "content_block_start",
@@ -265,30 +301,103 @@ func handleResponsesEventByType(eventType string, event map[string]interface{},
 			})
 		}
 
-	case "response.output_text.done", "response.done", "response.completed":
-		if state.ContentBlockOpen {
-			events = append(events, types.StreamEvent{
-				Type:  "content_block_stop",
-				Index: state.ContentBlockIndex,
-			})
-			state.ContentBlockOpen = false
-		}
-
-		events = append(events, types.StreamEvent{
-			Type: "message_delta",
-			Delta: &types.Delta{
-				StopReason: "end_turn",
-			},
-		})
+	case "response.output_text.done":
+		events = append(events, closeResponsesTextBlock(state)...)
 
-		events = append(events, types.StreamEvent{
-			Type: "message_stop",
-		})
+	case "response.done", "response.completed":
+		events = append(events, finishResponsesMessage(nil, state)...)
 	}
 
 	return events
 }
 
+// closeResponsesTextBlock closes the open text block; the next output text starts a new one.
+func closeResponsesTextBlock(state *StreamState) []types.StreamEvent {
+	if !state.ContentBlockOpen {
+		return nil
+	}
+	state.ContentBlockOpen = false
+	state.ContentBlockIndex++
+	return []types.StreamEvent{{
+		Type:  "content_block_stop",
+		Index: state.ContentBlockIndex - 1,
+	}}
+}
+
+// finishResponsesMessage ends a Responses API message with message_delta and message_stop,
+// once: both response.done and response.completed may arrive.
+func finishResponsesMessage(usage *ResponsesUsage, state *StreamState) []types.StreamEvent {
+	if state.messageStopSent {
+		return nil
+	}
+	state.messageStopSent = true
+
+	events := closeResponsesTextBlock(state)
+	delta := types.StreamEvent{
+		Type: "message_delta",
+		Delta: &types.Delta{
+			StopReason: state.refusalStop("end_turn", false),
+		},
+		Usage: &types.Usage{},
+	}
+	if usage != nil {
+		delta.Usage.OutputTokens = usage.OutputTokens
+	}
+	return append(events, delta, types.StreamEvent{Type: "message_stop"})
+}
+
+// upstreamError is an error object sent mid-stream by the chat completions endpoint
+// ({"error": {...}}) or the Responses API ("error" and "response.failed" events).
+type upstreamError struct {
+	Type    string          `json:"type"`
+	Message string          `json:"message"`
+	Code    json.RawMessage `json:"code"`
+}
+
+// upstreamErrorEvent returns the error event for an upstream error sent mid-stream; ok is
+// false when data isn't one. Overloads (503/529) become overloaded_error so clients retry.
+func upstreamErrorEvent(data string) (event types.StreamEvent, ok bool) {
+	if !strings.Contains(data, "error") && !strings.Contains(data, "response.failed") {
+		return types.StreamEvent{}, false
+	}
+	var payload struct {
+		upstreamError
+		Error    *upstreamError `json:"error"`
+		Response *struct {
+			Error *upstreamError `json:"error"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		return types.StreamEvent{}, false
+	}
+
+	var upstream *upstreamError
+	switch {
+	case payload.Error != nil:
+		upstream = payload.Error
+	case payload.Type == "error":
+		upstream = &payload.upstreamError
+	case payload.Type == "response.failed" && payload.Response != nil && payload.Response.Error != nil:
+		upstream = payload.Response.Error
+	default:
+		return types.StreamEvent{}, false
+	}
+
+	message := upstream.Message
+	if message == "" {
+		message = "upstream stream error"
+	}
+	if code := strings.Trim(string(upstream.Code), `"`); code != "" && code != "null" {
+		message = fmt.Sprintf("%s (%s)", message, code)
+	}
+	errType := upstream.Type
+	if errType == "error" {
+		errType = ""
+	}
+	ae := merrors.StreamError(errType, message)
+	return CreateErrorEvent(string(ae.Detail.Type), ae.Detail.Message), true
+}
+
 // translateChunkToAnthropicEvents converts an OpenAI chunk to Anthropic stream events.
 func translateChunkToAnthropicEvents(chunk *ChatCompletionChunk, state *StreamState) []types.StreamEvent {
 	var events []types.StreamEvent
@@ -311,6 +420,12 @@ func translateChunkToAnthropicEvents(chunk *ChatCompletionChunk, state *StreamSt
 		events = append(events, handleTextDelta(delta.Content, state)...)
 	}
 
+	// Content-policy refusals are streamed as text
+	if delta.Refusal != "" {
+		events = append(events, handleTextDelta(refusalText(delta.Refusal, !state.refused), state)...)
+		state.refused = true
+	}
+
 	// Handle tool calls
 	for _, toolCall := range delta.ToolCalls {
 		events = append(events, handleToolCall(toolCall, state)...)
@@ -318,7 +433,7 @@ func translateChunkToAnthropicEvents(chunk *ChatCompletionChunk, state *StreamSt
 
 	// Handle finish reason
 	if choice.FinishReason != nil {
-		events = append(events, handleFinishReason(chunk, *choice.FinishReason, state)...)
+		events = append(events, handleFinishReason(chunk, *choice.FinishReason, matchedStopSequence(*choice.FinishReason, choice.StopReason), state)...)
 	}
 
 	return events
@@ -355,7 +470,7 @@ func createMessageStartEvent(chunk *ChatCompletionChunk, state *StreamState) typ
 
 // handleTextDelta processes text content delta and returns events.
 func handleTextDelta(content string, state *StreamState) []types.StreamEvent {
-	var events []types.StreamEvent
+	events := flushPendingTools(state)
 
 	// Close tool block if open
 	if isToolBlockOpen(state) {
@@ -394,65 +509,140 @@ func handleTextDelta(content string, state *StreamState) []types.StreamEvent {
 }
 
 // handleToolCall processes a tool call delta and returns events.
+//
+// Argument deltas are forwarded as input_json_delta events as soon as they arrive. Anthropic
+// blocks can't interleave, so when OpenAI streams parallel tool calls (distinguished by index)
+// a later call is queued until the open call's arguments form complete JSON, then started with
+// everything it buffered in the meantime.
 func handleToolCall(toolCall ToolCallDelta, state *StreamState) []types.StreamEvent {
 	var events []types.StreamEvent
 
+	tc := state.ToolCalls[toolCall.Index]
+	if tc == nil {
+		tc = state.pendingTools[toolCall.Index]
+	}
+
 	// New tool call starting
-	if toolCall.ID != "" && toolCall.Function != nil && toolCall.Function.Name != "" {
-		// Close any previously open block
-		if state.ContentBlockOpen {
-			events = append(events, types.StreamEvent{
-				Type:  "content_block_stop",
-				Index: state.ContentBlockIndex,
-			})
-			state.ContentBlockIndex++
-			state.ContentBlockOpen = false
+	if tc == nil && toolCall.ID != "" && toolCall.Function != nil && toolCall.Function.Name != "" {
+		tc = &ToolCallState{
+			ID:   toolCall.ID,
+			Name: toolCall.Function.Name,
 		}
-
-		// Track this tool call
-		anthropicBlockIndex := state.ContentBlockIndex
-		state.ToolCalls[toolCall.Index] = &ToolCallState{
-			ID:                  toolCall.ID,
-			Name:                toolCall.Function.Name,
-			AnthropicBlockIndex: anthropicBlockIndex,
+		if active := activeToolCall(state); active != nil && (len(state.toolQueue) > 0 || !json.Valid([]byte(active.Arguments))) {
+			state.pendingTools[toolCall.Index] = tc
+			state.toolQueue = append(state.toolQueue, toolCall.Index)
+		} else {
+			events = append(events, startToolBlock(toolCall.Index, tc, state)...)
 		}
-
-		// Start tool_use block
-		events = append(events, types.StreamEvent{
-			Type:  "content_block_start",
-			Index: anthropicBlockIndex,
-			ContentBlock: &types.ContentBlock{
-				Type:  "tool_use",
-				ID:    toolCall.ID,
-				Name:  toolCall.Function.Name,
-				Input: map[string]interface{}{},
-			},
-		})
-		state.ContentBlockOpen = true
 	}
 
 	// Tool call arguments delta
-	if toolCall.Function != nil && toolCall.Function.Arguments != "" {
-		toolCallInfo := state.ToolCalls[toolCall.Index]
-		if toolCallInfo != nil {
-			events = append(events, types.StreamEvent{
-				Type:  "content_block_delta",
-				Index: toolCallInfo.AnthropicBlockIndex,
-				Delta: &types.Delta{
-					Type:        "input_json_delta",
-					PartialJSON: toolCall.Function.Arguments,
-				},
-			})
+	if tc != nil && toolCall.Function != nil && toolCall.Function.Arguments != "" {
+		tc.Arguments += toolCall.Function.Arguments
+		if tc == activeToolCall(state) {
+			events = append(events, inputJSONDelta(tc.AnthropicBlockIndex, toolCall.Function.Arguments))
 		}
 	}
 
+	return append(events, advanceToolQueue(state)...)
+}
+
+// advanceToolQueue starts queued tool calls while the open tool call has complete arguments.
+func advanceToolQueue(state *StreamState) []types.StreamEvent {
+	var events []types.StreamEvent
+	for len(state.toolQueue) > 0 {
+		if active := activeToolCall(state); active != nil && !json.Valid([]byte(active.Arguments)) {
+			break
+		}
+		events = append(events, startNextQueuedTool(state)...)
+	}
 	return events
 }
 
-// handleFinishReason processes the finish reason and returns events.
-func handleFinishReason(chunk *ChatCompletionChunk, finishReason string, state *StreamState) []types.StreamEvent {
+// flushPendingTools starts every queued tool call, in order, regardless of whether earlier
+// arguments look complete. Used before text and at the end of the stream.
+func flushPendingTools(state *StreamState) []types.StreamEvent {
+	var events []types.StreamEvent
+	for len(state.toolQueue) > 0 {
+		events = append(events, startNextQueuedTool(state)...)
+	}
+	return events
+}
+
+func startNextQueuedTool(state *StreamState) []types.StreamEvent {
+	idx := state.toolQueue[0]
+	state.toolQueue = state.toolQueue[1:]
+	tc := state.pendingTools[idx]
+	delete(state.pendingTools, idx)
+	return startToolBlock(idx, tc, state)
+}
+
+// startToolBlock closes any open block, opens a tool_use block for tc and replays its
+// buffered arguments.
+func startToolBlock(openAIIndex int, tc *ToolCallState, state *StreamState) []types.StreamEvent {
 	var events []types.StreamEvent
 
+	// Close any previously open block
+	if state.ContentBlockOpen {
+		events = append(events, types.StreamEvent{
+			Type:  "content_block_stop",
+			Index: state.ContentBlockIndex,
+		})
+		state.ContentBlockIndex++
+		state.ContentBlockOpen = false
+	}
+
+	tc.AnthropicBlockIndex = state.ContentBlockIndex
+	state.ToolCalls[openAIIndex] = tc
+
+	// Start tool_use block
+	events = append(events, types.StreamEvent{
+		Type:  "content_block_start",
+		Index: tc.AnthropicBlockIndex,
+		ContentBlock: &types.ContentBlock{
+			Type:  "tool_use",
+			ID:    tc.ID,
+			Name:  tc.Name,
+			Input: map[string]interface{}{},
+		},
+	})
+	state.ContentBlockOpen = true
+
+	if tc.Arguments != "" {
+		events = append(events, inputJSONDelta(tc.AnthropicBlockIndex, tc.Arguments))
+	}
+	return events
+}
+
+func inputJSONDelta(blockIndex int, partialJSON string) types.StreamEvent {
+	return types.StreamEvent{
+		Type:  "content_block_delta",
+		Index: blockIndex,
+		Delta: &types.Delta{
+			Type:        "input_json_delta",
+			PartialJSON: partialJSON,
+		},
+	}
+}
+
+// activeToolCall returns the tool call whose block is currently open, if any.
+func activeToolCall(state *StreamState) *ToolCallState {
+	if !state.ContentBlockOpen {
+		return nil
+	}
+	for _, tc := range state.ToolCalls {
+		if tc.AnthropicBlockIndex == state.ContentBlockIndex {
+			return tc
+		}
+	}
+	return nil
+}
+
+// handleFinishReason processes the finish reason and returns events. stopSequence is the
+// stop sequence the upstream reported it stopped at, if any.
+func handleFinishReason(chunk *ChatCompletionChunk, finishReason, stopSequence string, state *StreamState) []types.StreamEvent {
+	events := flushPendingTools(state)
+
 	// Close any open content block
 	if state.ContentBlockOpen {
 		events = append(events, types.StreamEvent{
@@ -476,10 +666,17 @@ func handleFinishReason(chunk *ChatCompletionChunk, finishReason string, state *
 	}
 
 	// Send message_delta with stop reason
+	stopReason := state.refusalStop(translateStopReason(finishReason), finishReason == "content_filter")
+	if stopSequence == "" || stopReason != "end_turn" {
+		stopSequence = ""
+	} else {
+		stopReason = "stop_sequence"
+	}
 	events = append(events, types.StreamEvent{
 		Type: "message_delta",
 		Delta: &types.Delta{
-			StopReason: translateStopReason(finishReason),
+			StopReason:   stopReason,
+			StopSequence: stopSequence,
 		},
 		Usage: &types.Usage{
 			InputTokens:         inputTokens,
@@ -498,15 +695,7 @@ func handleFinishReason(chunk *ChatCompletionChunk, finishReason string, state *
 
 // isToolBlockOpen checks if the current open block is a tool block.
 func isToolBlockOpen(state *StreamState) bool {
-	if !state.ContentBlockOpen {
-		return false
-	}
-	for _, tc := range state.ToolCalls {
-		if tc.AnthropicBlockIndex == state.ContentBlockIndex {
-			return true
-		}
-	}
-	return false
+	return activeToolCall(state) != nil
 }
 
 // CreateErrorEvent creates an Anthropic error stream event.
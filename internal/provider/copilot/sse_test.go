@@ -2,9 +2,11 @@ package copilot
 
 import (
 	"context"
+	"encoding/json"
 	"strings"
 	"testing"
 
+	"github.com/kuzerno1/multi-claude-proxy/internal/streamcheck"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
 
@@ -73,6 +75,38 @@ data: [DONE]
 	}
 }
 
+func TestParseSSEStream_MatchedStopSequence(t *testing.T) {
+	tests := []struct {
+		name             string
+		stopReason       string
+		wantStopReason   string
+		wantStopSequence string
+	}{
+		{name: "matched string", stopReason: `"\n\nHuman:"`, wantStopReason: "stop_sequence", wantStopSequence: "\n\nHuman:"},
+		{name: "stop token", stopReason: `128009`, wantStopReason: "end_turn"},
+		{name: "absent", stopReason: `null`, wantStopReason: "end_turn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sseData := `data: {"id":"c","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"},"finish_reason":null}]}
+
+data: {"id":"c","choices":[{"index":0,"delta":{},"finish_reason":"stop","stop_reason":` + tt.stopReason + `}]}
+
+data: [DONE]
+`
+			var delta *types.Delta
+			for event := range ParseSSEStream(context.Background(), strings.NewReader(sseData), "gpt-4") {
+				if event.Type == "message_delta" {
+					delta = event.Delta
+				}
+			}
+			if delta == nil || delta.StopReason != tt.wantStopReason || delta.StopSequence != tt.wantStopSequence {
+				t.Errorf("unexpected message_delta: %+v", delta)
+			}
+		})
+	}
+}
+
 func TestParseSSEStream_ContextCancellation(t *testing.T) {
 	// Create a stream that would normally take a while
 	sseData := `data: {"id":"chatcmpl-123","object":"chat.completion.chunk","created":1234567890,"model":"gpt-4","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"},"finish_reason":null}]}
@@ -208,3 +242,206 @@ func TestIsToolBlockOpen(t *testing.T) {
 		t.Error("expected tool block to be open")
 	}
 }
+
+// toolChunk builds an SSE line carrying a single tool call delta.
+func toolChunk(index int, id, name, args string) string {
+	call := ToolCallDelta{Index: index, ID: id, Function: &FunctionCallDelta{Name: name, Arguments: args}}
+	chunk := map[string]interface{}{
+		"id":    "c",
+		"model": "gpt-4",
+		"choices": []interface{}{
+			map[string]interface{}{"index": 0, "delta": map[string]interface{}{"tool_calls": []ToolCallDelta{call}}},
+		},
+	}
+	data, _ := json.Marshal(chunk)
+	return "data: " + string(data) + "\n\n"
+}
+
+// collectToolStream runs sseData through ParseSSEStream, checks that every delta targets the
+// open block, and returns the streamed argument JSON per tool name plus the block order.
+func collectToolStream(t *testing.T, sseData string) (map[string]string, []string, []types.StreamEvent) {
+	t.Helper()
+	var all []types.StreamEvent
+	for evt := range ParseSSEStream(context.Background(), strings.NewReader(sseData), "gpt-4") {
+		all = append(all, evt)
+	}
+
+	args := make(map[string]string)
+	var order []string
+	open := -1
+	names := make(map[int]string)
+	for _, evt := range all {
+		switch evt.Type {
+		case "content_block_start":
+			if open != -1 {
+				t.Fatalf("block %d started while block %d open", evt.Index, open)
+			}
+			open = evt.Index
+			if evt.ContentBlock.Type == "tool_use" {
+				names[evt.Index] = evt.ContentBlock.Name
+				order = append(order, evt.ContentBlock.Name)
+			}
+		case "content_block_delta":
+			if evt.Index != open {
+				t.Fatalf("delta for block %d while block %d open", evt.Index, open)
+			}
+			if evt.Delta.Type == "input_json_delta" {
+				args[names[evt.Index]] += evt.Delta.PartialJSON
+			}
+		case "content_block_stop":
+			if evt.Index != open {
+				t.Fatalf("stop for block %d while block %d open", evt.Index, open)
+			}
+			open = -1
+		}
+	}
+	if open != -1 {
+		t.Fatalf("block %d never stopped", open)
+	}
+	return args, order, all
+}
+
+func TestParseSSEStream_ToolCallArgumentsStreamIncrementally(t *testing.T) {
+	sseData := toolChunk(0, "call_a", "read", `{"pa`) +
+		toolChunk(0, "", "", `th":"a.go"}`) +
+		toolChunk(1, "call_b", "grep", `{"q":`) +
+		toolChunk(1, "", "", `"x"}`) +
+		`data: {"id":"c","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}` + "\n\n"
+
+	args, order, all := collectToolStream(t, sseData)
+
+	if len(order) != 2 || order[0] != "read" || order[1] != "grep" {
+		t.Fatalf("unexpected block order: %v", order)
+	}
+	if args["read"] != `{"path":"a.go"}` || args["grep"] != `{"q":"x"}` {
+		t.Errorf("unexpected arguments: %v", args)
+	}
+
+	// Each argument fragment is forwarded as its own delta rather than buffered.
+	deltas := 0
+	for _, evt := range all {
+		if evt.Type == "content_block_delta" {
+			deltas++
+		}
+	}
+	if deltas != 4 {
+		t.Errorf("expected 4 input_json_delta events, got %d", deltas)
+	}
+}
+
+func TestParseSSEStream_InterleavedParallelToolCalls(t *testing.T) {
+	sseData := toolChunk(0, "call_a", "read", `{"path":`) +
+		toolChunk(1, "call_b", "grep", `{"q":`) +
+		toolChunk(0, "", "", `"a.go"}`) +
+		toolChunk(2, "call_c", "ls", `{}`) +
+		toolChunk(1, "", "", `"x"}`) +
+		`data: {"id":"c","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}` + "\n\n"
+
+	args, order, all := collectToolStream(t, sseData)
+
+	if len(order) != 3 || order[0] != "read" || order[1] != "grep" || order[2] != "ls" {
+		t.Fatalf("unexpected block order: %v", order)
+	}
+	want := map[string]string{"read": `{"path":"a.go"}`, "grep": `{"q":"x"}`, "ls": `{}`}
+	for name, w := range want {
+		if args[name] != w {
+			t.Errorf("%s arguments = %q, want %q", name, args[name], w)
+		}
+	}
+
+	// Blocks are numbered in the order they are emitted.
+	idx := 0
+	for _, evt := range all {
+		if evt.Type == "content_block_start" {
+			if evt.Index != idx {
+				t.Errorf("block index = %d, want %d", evt.Index, idx)
+			}
+			idx++
+		}
+	}
+}
+
+func TestParseSSEStream_QueuedToolCallFlushedAtStreamEnd(t *testing.T) {
+	// No finish_reason: the queued call must still be emitted before the stream closes.
+	sseData := toolChunk(0, "call_a", "read", `{"path":`) +
+		toolChunk(1, "call_b", "grep", `{"q":"x"}`) +
+		"data: [DONE]\n\n"
+
+	args, order, _ := collectToolStream(t, sseData)
+	if len(order) != 2 || args["grep"] != `{"q":"x"}` || args["read"] != `{"path":` {
+		t.Errorf("unexpected result: order=%v args=%v", order, args)
+	}
+}
+
+func TestParseSSEStream_Conformance(t *testing.T) {
+	textChunk := func(content string) string {
+		return `data: {"id":"c","choices":[{"index":0,"delta":{"content":"` + content + `"}}]}` + "\n\n"
+	}
+	tests := []struct {
+		name      string
+		sseData   string
+		responses bool
+		wantError string // error type of the final event
+	}{
+		{
+			name:    "text",
+			sseData: textChunk("Hel") + textChunk("lo") + `data: {"id":"c","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2}}` + "\n\ndata: [DONE]\n\n",
+		},
+		{
+			name: "text then parallel tool calls",
+			sseData: textChunk("Checking") + toolChunk(0, "call_a", "read", `{"path":`) + toolChunk(1, "call_b", "grep", `{"q":"x"}`) +
+				toolChunk(0, "", "", `"a.go"}`) + `data: {"id":"c","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}` + "\n\n",
+		},
+		{
+			name:      "overloaded error chunk",
+			sseData:   textChunk("Hel") + `data: {"error":{"message":"Service Unavailable","type":"server_error","code":"503"}}` + "\n\n" + textChunk("lo"),
+			wantError: "overloaded_error",
+		},
+		{
+			name:      "line over the buffer limit",
+			sseData:   textChunk("Hel") + "data: " + strings.Repeat("x", 2*1024*1024) + "\n\n",
+			wantError: "api_error",
+		},
+		{
+			name:      "responses text",
+			responses: true,
+			sseData: `data: {"type":"response.created"}` + "\n\n" +
+				`data: {"type":"response.output_text.delta","delta":"Hi"}` + "\n\n" +
+				`data: {"type":"response.output_text.done"}` + "\n\n" +
+				`data: {"type":"response.completed","response":{"usage":{"input_tokens":3,"output_tokens":1}}}` + "\n\n",
+		},
+		{
+			name:      "responses failed",
+			responses: true,
+			sseData: `data: {"type":"response.created"}` + "\n\n" +
+				`data: {"type":"response.failed","response":{"error":{"code":"server_error","message":"The model is overloaded"}}}` + "\n\n",
+			wantError: "overloaded_error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parse := ParseSSEStream
+			if tt.responses {
+				parse = ParseSSEStreamResponses
+			}
+			var events []types.StreamEvent
+			for evt := range parse(context.Background(), strings.NewReader(tt.sseData), "gpt-4") {
+				events = append(events, evt)
+			}
+
+			serialized, err := streamcheck.FromStreamEvents(events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := streamcheck.Check(serialized); err != nil {
+				t.Errorf("stream violates the protocol:\n%v", err)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			if last := events[len(events)-1]; last.Error == nil || last.Error.Type != tt.wantError {
+				t.Errorf("expected a final %s event, got %+v", tt.wantError, last)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// startTokenRefresh starts the background goroutine that refreshes Copilot tokens before
+// they expire, so requests don't pay for the token exchange. It runs until Shutdown.
+func (p *Provider) startTokenRefresh() {
+	p.tokenCacheMu.Lock()
+	defer p.tokenCacheMu.Unlock()
+	if p.refreshStop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	p.refreshStop = stop
+
+	go func() {
+		ticker := time.NewTicker(config.CopilotTokenRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.refreshDueTokens(context.Background())
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// refreshDueTokens refreshes the tokens of valid Copilot accounts that have no cached
+// token or whose cached token is due for refresh.
+func (p *Provider) refreshDueTokens(ctx context.Context) {
+	now := time.Now()
+	for _, acc := range p.accountManager.GetAllAccountsByProvider(providerName) {
+		if acc.IsInvalid || acc.RefreshToken == "" {
+			continue
+		}
+		p.tokenCacheMu.RLock()
+		cached, ok := p.tokenCache[acc.Email]
+		p.tokenCacheMu.RUnlock()
+		if ok && now.Before(cached.refreshAt) {
+			continue
+		}
+
+		refreshCtx, cancel := context.WithTimeout(ctx, authHTTPTimeout)
+		_, err := p.refreshCopilotToken(refreshCtx, &acc)
+		cancel()
+		if err != nil {
+			utils.Warn("[Copilot] Background token refresh failed for %s: %v", acc.Email, err)
+			continue
+		}
+		utils.Debug("[Copilot] Refreshed token for %s", acc.Email)
+	}
+}
+
+// refreshCopilotToken exchanges the account's GitHub token for a new Copilot token and
+// caches it. After config.CopilotTokenAuthFailureLimit consecutive 401s from the token
+// endpoint the GitHub token is taken as revoked and the account is marked invalid.
+func (p *Provider) refreshCopilotToken(ctx context.Context, acc *account.Account) (string, error) {
+	// Get GitHub token (stored as RefreshToken)
+	githubToken := acc.RefreshToken
+	if githubToken == "" {
+		return "", fmt.Errorf("no GitHub token for account %s", acc.Email)
+	}
+
+	// Exchange for Copilot token
+	ctx, cancel := merrors.WithTimeout(acc.RequestContext(ctx), merrors.PhaseTokenFetch, config.GetRequestTimeouts(providerName).TokenFetch)
+	defer cancel()
+	tokenResp, err := p.fetchToken(ctx, githubToken, getAccountType(acc))
+	if err != nil {
+		if te := merrors.TimeoutCause(ctx); te != nil {
+			return "", te
+		}
+		var authErr *AuthError
+		if errors.As(err, &authErr) && authErr.StatusCode == http.StatusUnauthorized {
+			p.tokenCacheMu.Lock()
+			p.authFailures[acc.Email]++
+			failures := p.authFailures[acc.Email]
+			if failures >= config.CopilotTokenAuthFailureLimit {
+				delete(p.authFailures, acc.Email)
+				delete(p.tokenCache, acc.Email)
+			}
+			p.tokenCacheMu.Unlock()
+			if failures >= config.CopilotTokenAuthFailureLimit {
+				p.accountManager.MarkInvalid(acc.Email, "GitHub token rejected by the Copilot token endpoint")
+				utils.Warn("[Copilot] Account %s marked invalid after %d rejected token refreshes", acc.Email, failures)
+			}
+		}
+		return "", err
+	}
+
+	// Cache the token
+	now := time.Now()
+	expiresAt := time.Unix(tokenResp.ExpiresAt, 0)
+	p.tokenCacheMu.Lock()
+	delete(p.authFailures, acc.Email)
+	p.tokenCache[acc.Email] = &cachedToken{
+		token:     tokenResp.Token,
+		expiresAt: expiresAt,
+		refreshAt: tokenRefreshAt(now, expiresAt, tokenResp.RefreshIn),
+	}
+	p.tokenCacheMu.Unlock()
+
+	return tokenResp.Token, nil
+}
+
+// tokenRefreshAt returns when a token fetched at now should be refreshed: after the
+// refresh_in seconds the token endpoint suggests, but no later than
+// config.CopilotTokenRefreshLead before it expires.
+func tokenRefreshAt(now, expiresAt time.Time, refreshIn int) time.Time {
+	refreshAt := expiresAt.Add(-config.CopilotTokenRefreshLead)
+	if refreshIn > 0 {
+		if suggested := now.Add(time.Duration(refreshIn) * time.Second); suggested.Before(refreshAt) {
+			refreshAt = suggested
+		}
+	}
+	return refreshAt
+}
@@ -0,0 +1,101 @@
+package copilot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+func newRefreshTestProvider(t *testing.T) *Provider {
+	t.Helper()
+	mgr := account.NewManager(filepath.Join(t.TempDir(), "accounts.json"))
+	if err := mgr.AddAccount(account.Account{
+		Email:        "dev@example.com",
+		Provider:     providerName,
+		Source:       "oauth",
+		RefreshToken: "gho_test",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	return NewProvider(mgr)
+}
+
+func TestTokenRefreshAt(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	expiresAt := now.Add(30 * time.Minute)
+
+	if got := tokenRefreshAt(now, expiresAt, 1500); !got.Equal(now.Add(25 * time.Minute)) {
+		t.Errorf("refresh_in 1500: got %v, want 25m after fetch", got.Sub(now))
+	}
+	if got := tokenRefreshAt(now, expiresAt, 0); !got.Equal(expiresAt.Add(-config.CopilotTokenRefreshLead)) {
+		t.Errorf("no refresh_in: got %v before expiry", expiresAt.Sub(got))
+	}
+	if got := tokenRefreshAt(now, expiresAt, 3600); !got.Equal(expiresAt.Add(-config.CopilotTokenRefreshLead)) {
+		t.Errorf("refresh_in past expiry: got %v before expiry", expiresAt.Sub(got))
+	}
+}
+
+func TestRefreshDueTokens(t *testing.T) {
+	p := newRefreshTestProvider(t)
+	calls := 0
+	p.fetchToken = func(ctx context.Context, githubToken string, accountType AccountType) (*CopilotTokenResponse, error) {
+		calls++
+		return &CopilotTokenResponse{
+			Token:     fmt.Sprintf("tid_%d", calls),
+			ExpiresAt: time.Now().Add(30 * time.Minute).Unix(),
+			RefreshIn: 1500,
+		}, nil
+	}
+
+	p.refreshDueTokens(context.Background())
+	if calls != 1 {
+		t.Fatalf("expected a token fetch for the uncached account, got %d", calls)
+	}
+	p.refreshDueTokens(context.Background())
+	if calls != 1 {
+		t.Fatalf("expected a fresh token to be kept, got %d fetches", calls)
+	}
+
+	p.tokenCache["dev@example.com"].refreshAt = time.Now().Add(-time.Second)
+	p.refreshDueTokens(context.Background())
+	if calls != 2 || p.tokenCache["dev@example.com"].token != "tid_2" {
+		t.Errorf("expected the due token to be replaced, got %d fetches and %q", calls, p.tokenCache["dev@example.com"].token)
+	}
+}
+
+func TestRefreshDueTokens_MarksInvalidAfterRepeated401(t *testing.T) {
+	p := newRefreshTestProvider(t)
+	p.fetchToken = func(ctx context.Context, githubToken string, accountType AccountType) (*CopilotTokenResponse, error) {
+		return nil, &AuthError{Message: "invalid or expired GitHub token", StatusCode: 401}
+	}
+
+	for i := 1; i <= config.CopilotTokenAuthFailureLimit; i++ {
+		p.refreshDueTokens(context.Background())
+		invalid := p.accountManager.GetAllAccountsByProvider(providerName)[0].IsInvalid
+		if want := i == config.CopilotTokenAuthFailureLimit; invalid != want {
+			t.Fatalf("after %d rejected refreshes: invalid = %v, want %v", i, invalid, want)
+		}
+	}
+}
+
+func TestRefreshDueTokens_OtherErrorsKeepAccountValid(t *testing.T) {
+	p := newRefreshTestProvider(t)
+	p.fetchToken = func(ctx context.Context, githubToken string, accountType AccountType) (*CopilotTokenResponse, error) {
+		return nil, fmt.Errorf("copilot token request failed: 502 Bad Gateway")
+	}
+
+	for i := 0; i < config.CopilotTokenAuthFailureLimit+1; i++ {
+		p.refreshDueTokens(context.Background())
+	}
+	if p.accountManager.GetAllAccountsByProvider(providerName)[0].IsInvalid {
+		t.Error("expected transient token endpoint errors to keep the account valid")
+	}
+}
@@ -3,33 +3,36 @@ package copilot
 
 // ChatCompletionsPayload represents an OpenAI-compatible chat completions request.
 type ChatCompletionsPayload struct {
-	Model            string      `json:"model"`
-	Messages         []Message   `json:"messages"`
-	MaxTokens        int         `json:"max_tokens,omitempty"`
-	Temperature      *float64    `json:"temperature,omitempty"`
-	TopP             *float64    `json:"top_p,omitempty"`
-	Stream           bool        `json:"stream,omitempty"`
-	Stop             []string    `json:"stop,omitempty"`
-	Tools            []Tool      `json:"tools,omitempty"`
-	ToolChoice       interface{} `json:"tool_choice,omitempty"` // "auto", "none", "required", or ToolChoiceFunction
-	FrequencyPenalty *float64    `json:"frequency_penalty,omitempty"`
-	PresencePenalty  *float64    `json:"presence_penalty,omitempty"`
-	User             string      `json:"user,omitempty"`
+	Model             string      `json:"model"`
+	Messages          []Message   `json:"messages"`
+	MaxTokens         int         `json:"max_tokens,omitempty"`
+	Temperature       *float64    `json:"temperature,omitempty"`
+	TopP              *float64    `json:"top_p,omitempty"`
+	Stream            bool        `json:"stream,omitempty"`
+	Stop              []string    `json:"stop,omitempty"`
+	Tools             []Tool      `json:"tools,omitempty"`
+	ToolChoice        interface{} `json:"tool_choice,omitempty"` // "auto", "none", "required", or ToolChoiceFunction
+	ParallelToolCalls *bool       `json:"parallel_tool_calls,omitempty"`
+	FrequencyPenalty  *float64    `json:"frequency_penalty,omitempty"`
+	PresencePenalty   *float64    `json:"presence_penalty,omitempty"`
+	User              string      `json:"user,omitempty"`
 }
 
 // ResponsesPayload represents an OpenAI Responses API request.
 // This format is used for models that support the /responses endpoint.
 type ResponsesPayload struct {
-	Model           string          `json:"model"`
-	Input           []ResponseInput `json:"input"`
-	Instructions    string          `json:"instructions,omitempty"`
-	MaxOutputTokens int             `json:"max_output_tokens,omitempty"`
-	Temperature     *float64        `json:"temperature,omitempty"`
-	TopP            *float64        `json:"top_p,omitempty"`
-	Stream          bool            `json:"stream,omitempty"`
-	Stop            []string        `json:"stop,omitempty"`
-	Tools           []Tool          `json:"tools,omitempty"`
-	ToolChoice      interface{}     `json:"tool_choice,omitempty"`
+	Model             string          `json:"model"`
+	Input             []ResponseInput `json:"input"`
+	Instructions      string          `json:"instructions,omitempty"`
+	MaxOutputTokens   int             `json:"max_output_tokens,omitempty"`
+	Temperature       *float64        `json:"temperature,omitempty"`
+	TopP              *float64        `json:"top_p,omitempty"`
+	Stream            bool            `json:"stream,omitempty"`
+	Stop              []string        `json:"stop,omitempty"`
+	Tools             []Tool          `json:"tools,omitempty"`
+	ToolChoice        interface{}     `json:"tool_choice,omitempty"`
+	ParallelToolCalls *bool           `json:"parallel_tool_calls,omitempty"`
+	User              string          `json:"user,omitempty"`
 }
 
 // ResponseInput represents a single input item in the Responses API.
@@ -106,6 +109,7 @@ type Message struct {
 	Name       string        `json:"name,omitempty"`
 	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
 	ToolCallID string        `json:"tool_call_id,omitempty"`
+	Refusal    string        `json:"refusal,omitempty"` // Content-policy refusal (responses only)
 }
 
 // ContentPart represents a part of multimodal content.
@@ -177,6 +181,7 @@ type Choice struct {
 	Index        int     `json:"index"`
 	Message      Message `json:"message"`
 	FinishReason string  `json:"finish_reason"` // "stop", "length", "tool_calls", "content_filter"
+	StopReason   interface{} `json:"stop_reason,omitempty"` // Matched stop sequence (or stop token ID), from some OpenAI-compatible upstreams
 	Logprobs     interface{} `json:"logprobs"`
 }
 
@@ -216,6 +221,7 @@ type StreamChoice struct {
 	Index        int     `json:"index"`
 	Delta        Delta   `json:"delta"`
 	FinishReason *string `json:"finish_reason"`
+	StopReason   interface{} `json:"stop_reason,omitempty"` // Matched stop sequence (or stop token ID), from some OpenAI-compatible upstreams
 	Logprobs     interface{} `json:"logprobs"`
 }
 
@@ -224,6 +230,7 @@ type Delta struct {
 	Role      string           `json:"role,omitempty"`
 	Content   string           `json:"content,omitempty"`
 	ToolCalls []ToolCallDelta  `json:"tool_calls,omitempty"`
+	Refusal   string           `json:"refusal,omitempty"`
 }
 
 // ToolCallDelta represents incremental tool call data.
@@ -285,10 +292,18 @@ type ModelCapabilities struct {
 
 // ModelLimits defines token limits for a model.
 type ModelLimits struct {
-	MaxContextWindowTokens int `json:"max_context_window_tokens,omitempty"`
-	MaxOutputTokens        int `json:"max_output_tokens,omitempty"`
-	MaxPromptTokens        int `json:"max_prompt_tokens,omitempty"`
-	MaxInputs              int `json:"max_inputs,omitempty"`
+	MaxContextWindowTokens int           `json:"max_context_window_tokens,omitempty"`
+	MaxOutputTokens        int           `json:"max_output_tokens,omitempty"`
+	MaxPromptTokens        int           `json:"max_prompt_tokens,omitempty"`
+	MaxInputs              int           `json:"max_inputs,omitempty"`
+	Vision                 *VisionLimits `json:"vision,omitempty"`
+}
+
+// VisionLimits defines the image input limits of a vision model.
+type VisionLimits struct {
+	MaxPromptImageSize  int      `json:"max_prompt_image_size,omitempty"` // bytes per image
+	MaxPromptImages     int      `json:"max_prompt_images,omitempty"`
+	SupportedMediaTypes []string `json:"supported_media_types,omitempty"`
 }
 
 // ModelSupports describes supported features.
@@ -296,6 +311,7 @@ type ModelSupports struct {
 	ToolCalls         bool `json:"tool_calls,omitempty"`
 	ParallelToolCalls bool `json:"parallel_tool_calls,omitempty"`
 	Dimensions        bool `json:"dimensions,omitempty"`
+	Vision            bool `json:"vision,omitempty"`
 }
 
 // ModelPolicy contains policy information.
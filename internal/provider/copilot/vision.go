@@ -0,0 +1,232 @@
+package copilot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // register decoder for re-encoding
+	"image/jpeg"
+	_ "image/png" // register decoder for re-encoding
+	"math"
+	"slices"
+	"strings"
+
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// Image limits for models whose capabilities don't list them.
+const (
+	defaultMaxImageSize = 3 * 1024 * 1024
+	jpegQuality         = 85
+	maxResizeAttempts   = 8
+)
+
+var defaultImageMediaTypes = []string{"image/jpeg", "image/png", "image/webp", "image/gif"}
+
+// visionLimits returns the image limits for a model. Models missing from the models list
+// are assumed to accept images with the default limits.
+func (p *Provider) visionLimits(modelID string) (supported bool, limits VisionLimits) {
+	limits = VisionLimits{MaxPromptImageSize: defaultMaxImageSize, SupportedMediaTypes: defaultImageMediaTypes}
+
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	for _, m := range p.models {
+		if m.ID != modelID {
+			continue
+		}
+		vision := m.Capabilities.Limits.Vision
+		if vision == nil {
+			return m.Capabilities.Supports.Vision, limits
+		}
+		if vision.MaxPromptImageSize > 0 {
+			limits.MaxPromptImageSize = vision.MaxPromptImageSize
+		}
+		if len(vision.SupportedMediaTypes) > 0 {
+			limits.SupportedMediaTypes = vision.SupportedMediaTypes
+		}
+		limits.MaxPromptImages = vision.MaxPromptImages
+		return true, limits
+	}
+	return true, limits
+}
+
+// prepareImages checks the request's images against the model's vision capability and
+// limits. Images over the size limit or in an unsupported format are re-encoded as
+// (downscaled) JPEGs. The request is returned unchanged when it has no images; otherwise
+// a copy is returned and req is not modified.
+func (p *Provider) prepareImages(req *types.AnthropicRequest) (*types.AnthropicRequest, error) {
+	if !hasImages(req.Messages) {
+		return req, nil
+	}
+	supported, limits := p.visionLimits(req.Model)
+	if !supported {
+		return nil, merrors.InvalidRequest(fmt.Sprintf("model %s does not support image input", req.Model))
+	}
+	return prepareRequestImages(req, limits)
+}
+
+// hasImages reports whether any message may contain an image block.
+func hasImages(messages []types.Message) bool {
+	for _, msg := range messages {
+		if bytes.Contains(msg.Content, []byte(`"image"`)) {
+			return true
+		}
+	}
+	return false
+}
+
+func prepareRequestImages(req *types.AnthropicRequest, limits VisionLimits) (*types.AnthropicRequest, error) {
+	out := *req
+	out.Messages = make([]types.Message, len(req.Messages))
+	count := 0
+	for i, msg := range req.Messages {
+		out.Messages[i] = msg
+		if !bytes.Contains(msg.Content, []byte(`"image"`)) {
+			continue
+		}
+		blocks, err := types.ParseMessageContent(msg.Content)
+		if err != nil {
+			continue // left for the translator to report
+		}
+
+		changed := false
+		for j, block := range blocks {
+			if block.Type != "image" || block.Source == nil {
+				continue
+			}
+			count++
+			if limits.MaxPromptImages > 0 && count > limits.MaxPromptImages {
+				return nil, merrors.InvalidRequest(fmt.Sprintf("model %s accepts at most %d image(s) per request", req.Model, limits.MaxPromptImages))
+			}
+			source, err := prepareImage(block.Source, limits)
+			if err != nil {
+				return nil, merrors.InvalidRequest(fmt.Sprintf("image %d: %v", count, err))
+			}
+			if source != block.Source {
+				blocks[j].Source = source
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		content, err := json.Marshal(blocks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode message content: %w", err)
+		}
+		out.Messages[i].Content = content
+	}
+	return &out, nil
+}
+
+// prepareImage returns source, or a JPEG re-encoding of it when it exceeds the size limit
+// or its media type isn't supported.
+func prepareImage(source *types.ImageSource, limits VisionLimits) (*types.ImageSource, error) {
+	if source.Type != "base64" {
+		return source, nil // URLs are fetched upstream
+	}
+	data, err := base64.StdEncoding.DecodeString(source.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 data: %v", err)
+	}
+
+	mediaTypeOK := slices.Contains(limits.SupportedMediaTypes, source.MediaType)
+	if mediaTypeOK && len(data) <= limits.MaxPromptImageSize {
+		return source, nil
+	}
+
+	reason := fmt.Sprintf("%s exceeds the %s limit", formatBytes(len(data)), formatBytes(limits.MaxPromptImageSize))
+	if !mediaTypeOK {
+		reason = fmt.Sprintf("media type %s is not supported (supported: %s)", source.MediaType, strings.Join(limits.SupportedMediaTypes, ", "))
+	}
+	if !slices.Contains(limits.SupportedMediaTypes, "image/jpeg") {
+		return nil, fmt.Errorf("%s", reason)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s and the image can't be re-encoded: %v", reason, err)
+	}
+	encoded, err := encodeJPEGWithin(img, limits.MaxPromptImageSize)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", reason, err)
+	}
+	utils.Debug("[Copilot] Re-encoded %s image (%s) as JPEG (%s)", source.MediaType, formatBytes(len(data)), formatBytes(len(encoded)))
+	return &types.ImageSource{
+		Type:      "base64",
+		MediaType: "image/jpeg",
+		Data:      base64.StdEncoding.EncodeToString(encoded),
+	}, nil
+}
+
+// encodeJPEGWithin encodes img as a JPEG of at most maxSize bytes, downscaling it as needed.
+func encodeJPEGWithin(img image.Image, maxSize int) ([]byte, error) {
+	// JPEG has no alpha channel: flatten onto white so transparent areas don't turn black.
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Over)
+
+	current := rgba
+	for attempt := 0; attempt < maxResizeAttempts; attempt++ {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, current, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		if buf.Len() <= maxSize {
+			return buf.Bytes(), nil
+		}
+
+		// JPEG size grows roughly with the pixel count.
+		scale := math.Min(0.9*math.Sqrt(float64(maxSize)/float64(buf.Len())), 0.9)
+		w := int(float64(current.Bounds().Dx()) * scale)
+		h := int(float64(current.Bounds().Dy()) * scale)
+		if w < 1 || h < 1 {
+			break
+		}
+		current = downscale(current, w, h)
+	}
+	return nil, fmt.Errorf("could not shrink the image below %s", formatBytes(maxSize))
+}
+
+// downscale resizes src to w x h by averaging the source pixels covered by each target pixel.
+func downscale(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				off := sy*src.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[off])
+					g += int(src.Pix[off+1])
+					b += int(src.Pix[off+2])
+					a += int(src.Pix[off+3])
+					off += 4
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+func formatBytes(n int) string {
+	if n >= 1024*1024 {
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	}
+	return fmt.Sprintf("%d KB", (n+1023)/1024)
+}
@@ -0,0 +1,136 @@
+package copilot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// noisePNG returns a w x h PNG of random pixels, which compresses badly.
+func noisePNG(t *testing.T, w, h int) string {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func imageRequest(model string, sources ...types.ImageSource) *types.AnthropicRequest {
+	blocks := []types.ContentBlock{{Type: "text", Text: "What is this?"}}
+	for i := range sources {
+		blocks = append(blocks, types.ContentBlock{Type: "image", Source: &sources[i]})
+	}
+	content, _ := json.Marshal(blocks)
+	return &types.AnthropicRequest{
+		Model:     model,
+		MaxTokens: 100,
+		Messages:  []types.Message{{Role: "user", Content: content}},
+	}
+}
+
+func visionProvider(models ...Model) *Provider {
+	p := NewProvider(nil)
+	p.models = models
+	return p
+}
+
+func TestPrepareImages_ResizesOversizedImage(t *testing.T) {
+	p := visionProvider(Model{ID: "gpt-4o", Capabilities: ModelCapabilities{
+		Supports: ModelSupports{Vision: true},
+		Limits:   ModelLimits{Vision: &VisionLimits{MaxPromptImageSize: 30 * 1024, SupportedMediaTypes: []string{"image/jpeg", "image/png"}}},
+	}})
+	original := noisePNG(t, 300, 300)
+	req := imageRequest("gpt-4o", types.ImageSource{Type: "base64", MediaType: "image/png", Data: original})
+	content := string(req.Messages[0].Content)
+
+	got, err := p.prepareImages(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(req.Messages[0].Content) != content {
+		t.Error("expected the original request to be left unchanged")
+	}
+
+	blocks, err := types.ParseMessageContent(got.Messages[0].Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := blocks[1].Source
+	if source.MediaType != "image/jpeg" {
+		t.Fatalf("expected a JPEG, got %s", source.MediaType)
+	}
+	data, err := base64.StdEncoding.DecodeString(source.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > 30*1024 {
+		t.Errorf("expected at most 30 KB, got %d bytes", len(data))
+	}
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("re-encoded image does not decode: %v", err)
+	}
+}
+
+func TestPrepareImages_KeepsSmallImage(t *testing.T) {
+	p := visionProvider(Model{ID: "gpt-4o", Capabilities: ModelCapabilities{Supports: ModelSupports{Vision: true}}})
+	req := imageRequest("gpt-4o", types.ImageSource{Type: "base64", MediaType: "image/png", Data: noisePNG(t, 8, 8)})
+
+	got, err := p.prepareImages(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got.Messages[0].Content) != string(req.Messages[0].Content) {
+		t.Error("expected a small supported image to be sent unchanged")
+	}
+}
+
+func TestPrepareImages_Errors(t *testing.T) {
+	p := visionProvider(
+		Model{ID: "text-only"},
+		Model{ID: "one-image", Capabilities: ModelCapabilities{
+			Limits: ModelLimits{Vision: &VisionLimits{MaxPromptImages: 1, SupportedMediaTypes: []string{"image/png"}}},
+		}},
+	)
+	small := types.ImageSource{Type: "base64", MediaType: "image/png", Data: noisePNG(t, 8, 8)}
+
+	tests := []struct {
+		name    string
+		req     *types.AnthropicRequest
+		wantErr string
+	}{
+		{"no vision support", imageRequest("text-only", small), "does not support image input"},
+		{"too many images", imageRequest("one-image", small, small), "at most 1 image"},
+		{"invalid base64", imageRequest("unknown", types.ImageSource{Type: "base64", MediaType: "image/png", Data: "not base64!"}), "invalid base64"},
+		{"unsupported media type", imageRequest("one-image", types.ImageSource{Type: "base64", MediaType: "image/webp", Data: small.Data}), "media type image/webp is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.prepareImages(tt.req)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// Requests without images skip the capability check.
+	req := &types.AnthropicRequest{Model: "text-only", Messages: []types.Message{{Role: "user", Content: json.RawMessage(`"Hi"`)}}}
+	if got, err := p.prepareImages(req); err != nil || got != req {
+		t.Errorf("expected text request to pass through, got %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+package provider
+
+import "context"
+
+type noFallbackKey struct{}
+
+// WithoutFallback returns ctx asking the provider to serve the request with the requested
+// model only, without falling back to another model when it is exhausted.
+func WithoutFallback(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noFallbackKey{}, true)
+}
+
+// FallbackAllowed reports whether the request of ctx may fall back to another model.
+func FallbackAllowed(ctx context.Context) bool {
+	noFallback, _ := ctx.Value(noFallbackKey{}).(bool)
+	return !noFallback
+}
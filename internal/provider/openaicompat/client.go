@@ -0,0 +1,185 @@
+// Package openaicompat implements a generic provider for OpenAI-compatible upstreams
+// (vLLM, Ollama, OpenRouter, ...). Request and response translation is shared with the
+// Copilot provider, which speaks the same Chat Completions dialect.
+package openaicompat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/capture"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/copilot"
+	"github.com/kuzerno1/multi-claude-proxy/internal/tracing"
+)
+
+// Client sends Chat Completions requests to a single OpenAI-compatible base URL.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	headers    map[string]string
+}
+
+// NewClient creates a client for an upstream configuration.
+// No client timeout is set so long streams aren't cut off; callers bound requests with ctx.
+func NewClient(cfg config.OpenAICompatibleConfig) *Client {
+	return &Client{
+		httpClient: &http.Client{Transport: provider.ConnectTimeoutTransport(cfg.Name, tracing.Transport(capture.Transport(nil)))},
+		baseURL:    cfg.BaseURL,
+		apiKey:     cfg.APIKey,
+		headers:    cfg.Headers,
+	}
+}
+
+// modelsResponse is the OpenAI GET /models response.
+type modelsResponse struct {
+	Data []struct {
+		ID      string `json:"id"`
+		Created int64  `json:"created,omitempty"`
+		OwnedBy string `json:"owned_by,omitempty"`
+	} `json:"data"`
+}
+
+// HTTPStatusError is a non-2xx response from the upstream.
+type HTTPStatusError struct {
+	StatusCode int
+	Message    string
+	Body       []byte // The upstream's error response
+}
+
+func (e *HTTPStatusError) Error() string {
+	return e.Message
+}
+
+// AnthropicError maps the upstream's error response to the Anthropic error type.
+func (e *HTTPStatusError) AnthropicError() *merrors.AnthropicError {
+	return merrors.FromUpstream(e.StatusCode, e.Body)
+}
+
+// FetchModels returns the model IDs advertised by GET <baseURL>/models.
+func (c *Client) FetchModels(ctx context.Context) ([]string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed modelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse models response: %w", err)
+	}
+	models := make([]string, 0, len(parsed.Data))
+	for _, m := range parsed.Data {
+		if m.ID != "" {
+			models = append(models, m.ID)
+		}
+	}
+	return models, nil
+}
+
+// SendMessage sends a non-streaming chat completion request.
+func (c *Client) SendMessage(ctx context.Context, payload *copilot.ChatCompletionsPayload) (*copilot.ChatCompletionResponse, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/chat/completions", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed copilot.ChatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse chat completion response: %w", err)
+	}
+	return &parsed, nil
+}
+
+// SendMessageStream sends a streaming chat completion request and returns the SSE body.
+// The caller must close the returned reader.
+func (c *Client) SendMessageStream(ctx context.Context, payload *copilot.ChatCompletionsPayload) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/chat/completions", payload)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, payload interface{}) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	provider.TraceFromContext(ctx).RecordRateLimit(resp.Header)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, statusError(resp.StatusCode, data)
+	}
+	return resp, nil
+}
+
+// statusError builds the error for a non-2xx response; merrors.FromError maps its body to the
+// Anthropic error type, and its message says what happened in logs.
+func statusError(status int, body []byte) error {
+	detail := strings.TrimSpace(string(body))
+	var parsed struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		if parsed.Error.Message != "" {
+			detail = parsed.Error.Message
+		} else if parsed.Message != "" {
+			detail = parsed.Message
+		}
+	}
+
+	var kind string
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		kind = "authentication_error"
+	case status == http.StatusTooManyRequests:
+		kind = "rate limit exceeded"
+	case status >= 500:
+		kind = "server_error"
+	default:
+		kind = "api_error"
+	}
+	return &HTTPStatusError{
+		StatusCode: status,
+		Message:    fmt.Sprintf("%s: status %d: %s", kind, status, detail),
+		Body:       body,
+	}
+}
@@ -0,0 +1,200 @@
+package openaicompat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/copilot"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// Provider implements provider.Provider for one configured OpenAI-compatible upstream.
+// Unlike the account-based providers it has a single credential, so retries reuse it.
+type Provider struct {
+	cfg         config.OpenAICompatibleConfig
+	client      *Client
+	retryPolicy config.RetryPolicy
+	models      []string
+	modelSet    map[string]bool
+	modelsMu    sync.RWMutex
+}
+
+// NewProvider creates a provider for an upstream configuration.
+func NewProvider(cfg config.OpenAICompatibleConfig) *Provider {
+	return &Provider{
+		cfg:         cfg,
+		client:      NewClient(cfg),
+		retryPolicy: config.GetRetryPolicy(cfg.Name),
+		modelSet:    make(map[string]bool),
+	}
+}
+
+// Name returns the configured instance name.
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// Models returns the list of supported model IDs.
+func (p *Provider) Models() []string {
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	result := make([]string, len(p.models))
+	copy(result, p.models)
+	return result
+}
+
+// SupportsModel returns true if this provider handles the given model.
+func (p *Provider) SupportsModel(model string) bool {
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	return p.modelSet[model]
+}
+
+// Initialize uses the configured model list, or fetches it from the upstream when none is configured.
+func (p *Provider) Initialize(ctx context.Context) error {
+	models := p.cfg.Models
+	if len(models) == 0 {
+		fetchCtx, cancel := context.WithTimeout(ctx, config.ModelPrefetchTimeout)
+		defer cancel()
+		fetched, err := p.client.FetchModels(fetchCtx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch models from %s: %w", p.cfg.BaseURL, err)
+		}
+		models = fetched
+	}
+
+	sorted := append([]string(nil), models...)
+	sort.Strings(sorted)
+
+	p.modelsMu.Lock()
+	defer p.modelsMu.Unlock()
+	p.models = sorted
+	p.modelSet = make(map[string]bool, len(sorted))
+	for _, m := range sorted {
+		p.modelSet[m] = true
+	}
+	return nil
+}
+
+// Shutdown performs cleanup.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// SendMessage handles non-streaming requests.
+func (p *Provider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	payload, err := copilot.TranslateToOpenAI(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request: %w", err)
+	}
+	payload.Stream = false
+
+	var resp *copilot.ChatCompletionResponse
+	err = p.withRetry(ctx, func() error {
+		var sendErr error
+		resp, sendErr = p.client.SendMessage(ctx, payload)
+		return sendErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copilot.TranslateToAnthropic(resp, req.Model), nil
+}
+
+// SendMessageStream handles streaming requests.
+func (p *Provider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
+	payload, err := copilot.TranslateToOpenAI(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request: %w", err)
+	}
+	payload.Stream = true
+
+	var body io.ReadCloser
+	err = p.withRetry(ctx, func() error {
+		var sendErr error
+		body, sendErr = p.client.SendMessageStream(ctx, payload)
+		return sendErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := copilot.ParseSSEStream(ctx, body, req.Model)
+	outCh := make(chan types.StreamEvent, 100)
+	go func() {
+		defer close(outCh)
+		defer body.Close()
+
+		for evt := range events {
+			select {
+			case outCh <- evt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return outCh, nil
+}
+
+// withRetry runs send, retrying retryable statuses and network errors per the retry policy.
+func (p *Provider) withRetry(ctx context.Context, send func() error) error {
+	var err error
+	for attempt := 0; attempt < p.retryPolicy.MaxAttempts; attempt++ {
+		provider.TraceFromContext(ctx).Attempt("")
+		if err = send(); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var httpErr *HTTPStatusError
+		if errors.As(err, &httpErr) && !p.retryPolicy.IsRetryableStatus(httpErr.StatusCode) {
+			return err
+		}
+		utils.Warn("[%s] Request failed (attempt %d/%d): %v", p.cfg.Name, attempt+1, p.retryPolicy.MaxAttempts, err)
+		if attempt+1 < p.retryPolicy.MaxAttempts {
+			if sleepErr := provider.Sleep(ctx, p.retryPolicy.Delay(attempt)); sleepErr != nil {
+				return sleepErr
+			}
+		}
+	}
+	return err
+}
+
+// ListModels returns available models with metadata.
+func (p *Provider) ListModels(ctx context.Context) (*types.ModelsResponse, error) {
+	models := p.Models()
+	data := make([]types.Model, len(models))
+	for i, m := range models {
+		data[i] = types.Model{ID: m, DisplayName: m, Type: "model"}
+	}
+	return &types.ModelsResponse{Data: data}, nil
+}
+
+// GetStatus reports the upstream as ok when it has models. There are no accounts or quotas to report.
+func (p *Provider) GetStatus(ctx context.Context) (*types.ProviderStatus, error) {
+	status := "ok"
+	if len(p.Models()) == 0 {
+		status = "degraded"
+	}
+	return &types.ProviderStatus{
+		Name:      p.cfg.Name,
+		Status:    status,
+		Accounts:  []types.AccountStatus{},
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// GenerateImage is not supported for OpenAI-compatible upstreams.
+func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
+	return nil, fmt.Errorf("image generation is not supported by the %s provider", p.cfg.Name)
+}
@@ -0,0 +1,135 @@
+package openaicompat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func testRequest(stream bool) *types.AnthropicRequest {
+	return &types.AnthropicRequest{
+		Model:     "llama",
+		MaxTokens: 100,
+		Stream:    stream,
+		Messages:  []types.Message{{Role: "user", Content: json.RawMessage(`"hello"`)}},
+	}
+}
+
+func TestProvider_Initialize_FetchesModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"data":[{"id":"qwen"},{"id":"llama"}]}`))
+	}))
+	defer server.Close()
+
+	p := NewProvider(config.OpenAICompatibleConfig{Name: "vllm", BaseURL: server.URL + "/v1"})
+	if err := p.Initialize(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := p.Models(); len(got) != 2 || got[0] != "llama" {
+		t.Errorf("expected sorted fetched models, got %v", got)
+	}
+	if !p.SupportsModel("qwen") {
+		t.Error("expected to support qwen")
+	}
+}
+
+func TestProvider_SendMessage(t *testing.T) {
+	var gotAuth, gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotHeader = r.Header.Get("X-Extra")
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload["model"] != "llama" {
+			t.Errorf("expected raw model id, got %v", payload["model"])
+		}
+		w.Write([]byte(`{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"hi there"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	p := NewProvider(config.OpenAICompatibleConfig{
+		Name:    "vllm",
+		BaseURL: server.URL,
+		APIKey:  "secret",
+		Models:  []string{"llama"},
+		Headers: map[string]string{"X-Extra": "1"},
+	})
+	if err := p.Initialize(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.SendMessage(context.Background(), testRequest(false))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Text != "hi there" {
+		t.Errorf("unexpected content: %+v", resp.Content)
+	}
+	if gotAuth != "Bearer secret" || gotHeader != "1" {
+		t.Errorf("expected auth and extra headers, got %q %q", gotAuth, gotHeader)
+	}
+}
+
+func TestProvider_SendMessageStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"hi\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	p := NewProvider(config.OpenAICompatibleConfig{Name: "vllm", BaseURL: server.URL, Models: []string{"llama"}})
+	events, err := p.SendMessageStream(context.Background(), testRequest(true))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var text string
+	var sawStop bool
+	for evt := range events {
+		if evt.Delta != nil {
+			text += evt.Delta.Text
+		}
+		sawStop = sawStop || evt.Type == "message_stop"
+	}
+	if text != "hi" || !sawStop {
+		t.Errorf("expected streamed text and message_stop, got %q stop=%v", text, sawStop)
+	}
+}
+
+func TestProvider_RetriesServerErrors(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if calls == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"bad"}}`))
+			return
+		}
+		t.Error("did not expect a retry after a 400")
+	}))
+	defer server.Close()
+
+	p := NewProvider(config.OpenAICompatibleConfig{Name: "vllm", BaseURL: server.URL, Models: []string{"llama"}})
+	p.retryPolicy = config.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	_, err := p.SendMessage(context.Background(), testRequest(false))
+	if err == nil || calls != 2 {
+		t.Fatalf("expected 400 after one retry, got calls=%d err=%v", calls, err)
+	}
+}
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errNoCandidates is returned by FetchFirst when there is nothing to try.
+var errNoCandidates = errors.New("no candidates to fetch from")
+
+// InitReporter is implemented by providers that record per-account failures during Initialize.
+// The map is keyed by account email; values are the error messages.
+type InitReporter interface {
+	InitFailures() map[string]string
+}
+
+// FetchFirst calls fetch for every key in parallel, each bounded by timeout, and returns the
+// first successful result together with the key that produced it. Failures observed before
+// the winner are returned keyed by key; remaining in-flight calls are cancelled.
+// If every call fails, the last error is returned along with all failures.
+func FetchFirst[T any](ctx context.Context, timeout time.Duration, keys []string, fetch func(ctx context.Context, key string) (T, error)) (T, string, map[string]error, error) {
+	var zero T
+	failures := make(map[string]error)
+	if len(keys) == 0 {
+		return zero, "", failures, errNoCandidates
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		key   string
+		value T
+		err   error
+	}
+	results := make(chan result, len(keys))
+
+	for _, key := range keys {
+		go func(key string) {
+			fetchCtx, fetchCancel := context.WithTimeout(ctx, timeout)
+			defer fetchCancel()
+			value, err := fetch(fetchCtx, key)
+			results <- result{key: key, value: value, err: err}
+		}(key)
+	}
+
+	var lastErr error
+	for range keys {
+		r := <-results
+		if r.err == nil {
+			return r.value, r.key, failures, nil
+		}
+		failures[r.key] = r.err
+		lastErr = r.err
+	}
+	return zero, "", failures, lastErr
+}
+
+// FailureMessages converts FetchFirst failures into a map of error strings.
+func FailureMessages(failures map[string]error) map[string]string {
+	out := make(map[string]string, len(failures))
+	for key, err := range failures {
+		out[key] = err.Error()
+	}
+	return out
+}
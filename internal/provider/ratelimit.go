@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AnthropicRateLimitHeaders are the anthropic-ratelimit-* response headers Anthropic
+// documents, in canonical form.
+var AnthropicRateLimitHeaders = []string{
+	"Anthropic-Ratelimit-Requests-Limit", "Anthropic-Ratelimit-Requests-Remaining", "Anthropic-Ratelimit-Requests-Reset",
+	"Anthropic-Ratelimit-Tokens-Limit", "Anthropic-Ratelimit-Tokens-Remaining", "Anthropic-Ratelimit-Tokens-Reset",
+	"Anthropic-Ratelimit-Input-Tokens-Limit", "Anthropic-Ratelimit-Input-Tokens-Remaining", "Anthropic-Ratelimit-Input-Tokens-Reset",
+	"Anthropic-Ratelimit-Output-Tokens-Limit", "Anthropic-Ratelimit-Output-Tokens-Remaining", "Anthropic-Ratelimit-Output-Tokens-Reset",
+}
+
+// openAIRateLimitHeaders maps OpenAI-style x-ratelimit-* headers (Copilot, OpenAI-compatible
+// upstreams) to their anthropic-ratelimit-* equivalents.
+var openAIRateLimitHeaders = map[string]string{
+	"X-Ratelimit-Limit-Requests":     "Anthropic-Ratelimit-Requests-Limit",
+	"X-Ratelimit-Remaining-Requests": "Anthropic-Ratelimit-Requests-Remaining",
+	"X-Ratelimit-Reset-Requests":     "Anthropic-Ratelimit-Requests-Reset",
+	"X-Ratelimit-Limit-Tokens":       "Anthropic-Ratelimit-Tokens-Limit",
+	"X-Ratelimit-Remaining-Tokens":   "Anthropic-Ratelimit-Tokens-Remaining",
+	"X-Ratelimit-Reset-Tokens":       "Anthropic-Ratelimit-Tokens-Reset",
+}
+
+// RateLimitHeaders extracts the rate-limit information of an upstream response as
+// anthropic-ratelimit-* headers plus Retry-After. Anthropic-compatible upstreams send them
+// as they are; OpenAI-style x-ratelimit-* headers are renamed, with their reset durations
+// (e.g. "6m0s") converted to RFC 3339 times relative to now. Returns nil if there are none.
+func RateLimitHeaders(h http.Header, now time.Time) http.Header {
+	var out http.Header
+	set := func(name, value string) {
+		if out == nil {
+			out = make(http.Header)
+		}
+		out.Set(name, value)
+	}
+
+	for name, values := range h {
+		if len(values) == 0 {
+			continue
+		}
+		canonical := http.CanonicalHeaderKey(name)
+		if strings.HasPrefix(canonical, "Anthropic-Ratelimit-") || canonical == "Retry-After" {
+			set(canonical, values[0])
+		}
+	}
+	for from, to := range openAIRateLimitHeaders {
+		value := h.Get(from)
+		if value == "" || out.Get(to) != "" {
+			continue
+		}
+		if strings.HasSuffix(to, "-Reset") {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				continue
+			}
+			value = now.Add(d).UTC().Format(time.RFC3339)
+		}
+		set(to, value)
+	}
+	return out
+}
@@ -50,6 +50,24 @@ func (r *Registry) Register(p Provider) error {
 	return nil
 }
 
+// Unregister removes a provider and its models from the registry. Requests already
+// holding the provider are unaffected. It reports whether the provider was registered.
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.providers[name]; !exists {
+		return false
+	}
+	delete(r.providers, name)
+	for key, p := range r.modelMap {
+		if p.Name() == name {
+			delete(r.modelMap, key)
+		}
+	}
+	return true
+}
+
 // GetByName returns a provider by its name.
 func (r *Registry) GetByName(name string) (Provider, bool) {
 	r.mu.RLock()
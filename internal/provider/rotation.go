@@ -0,0 +1,256 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/tracing"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// ErrNoAPIKey is returned by a Rotation attempt with an account that has no API key.
+var ErrNoAPIKey = errors.New("no API key")
+
+// Failure describes a failed upstream attempt for account selection.
+type Failure struct {
+	StatusCode  int   // HTTP status of the upstream response, 0 if there was none
+	RateLimited bool  // The account hit a rate limit
+	ResetMs     int64 // Time until a rate limit resets, when RateLimited
+}
+
+// Rotation sends the requests of a provider with its accounts in turn, moving on to the
+// next account when one is rate-limited, rejected or fails with a retryable status.
+type Rotation struct {
+	Provider string // Provider ID of the accounts, e.g. "zai"
+	Name     string // Provider name in logs and errors, e.g. "Z.AI"
+	Accounts *account.Manager
+	Policy   config.RetryPolicy
+
+	// Classify describes an error returned by the provider's client; errors without an
+	// upstream response (e.g. network errors) get the zero Failure.
+	Classify func(err error) Failure
+
+	// Reject handles a status that may reject the account rather than the request, e.g.
+	// 401 for a revoked key, and reports whether to try the next account. Nil if none do.
+	Reject func(acc *account.Account, status int) bool
+}
+
+// Run sends a request for model, trying accounts until one succeeds, an error isn't
+// worth retrying with another account or the policy's attempts run out.
+//
+// send makes one attempt with at.Account. It calls at.Begin right before the upstream
+// request and, on success, ends the attempt (Attempt.End) or hands it to a stream
+// (Attempt.Detach). An error returned before Begin means the account can't be used, e.g.
+// it has no key: the account is marked invalid and the next one is tried.
+func (r *Rotation) Run(ctx context.Context, model string, send func(at *Attempt) error) error {
+	maxAttempts := r.Policy.Attempts(r.Accounts.GetAccountCountByProvider(r.Provider))
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		pickStart := time.Now()
+		acc, err := r.Pick(ctx, model)
+		if err != nil {
+			return err
+		}
+		tracing.RecordSpan(ctx, "account.pick", pickStart, tracing.String("account", acc.Email), tracing.Int("attempt", attempt+1))
+
+		at := r.Attempt(acc, model)
+		err = send(at)
+		if !at.begun {
+			if err == nil {
+				return nil
+			}
+			// Credentials cut short by the client say nothing about the account.
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.Accounts.MarkInvalid(acc.Email, err.Error())
+			utils.Warn("[%s] Account %s can't be used (%v), trying next...", r.Name, acc.Email, err)
+			continue
+		}
+		at.End(err, false)
+		if err == nil {
+			return nil
+		}
+		if !r.Retry(ctx, acc, model, err) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("max retries exceeded")
+}
+
+// Pick selects the next usable account for model. When all accounts are rate-limited it
+// waits for the first to reset, unless that is more than config.MaxWaitBeforeError away.
+func (r *Rotation) Pick(ctx context.Context, model string) (*account.Account, error) {
+	acc := r.Accounts.PickNextByProviderContext(ctx, r.Provider, model)
+
+	if acc == nil && r.Accounts.IsAllRateLimitedByProvider(r.Provider, model) {
+		allWaitMs := r.Accounts.GetMinWaitTimeMsByProvider(r.Provider, model)
+		waitDur := time.Duration(allWaitMs) * time.Millisecond
+		resetTime := time.Now().Add(waitDur).UTC().Format("2006-01-02T15:04:05.000Z")
+
+		if waitDur > config.MaxWaitBeforeError {
+			return nil, fmt.Errorf(
+				"RESOURCE_EXHAUSTED: Rate limited on %s. Quota will reset after %s. Next available: %s",
+				model,
+				utils.FormatDuration(waitDur),
+				resetTime,
+			)
+		}
+
+		accountCount := r.Accounts.GetAccountCountByProvider(r.Provider)
+		utils.Warn("[%s] All %d account(s) rate-limited. Waiting %s...",
+			r.Name,
+			accountCount,
+			utils.FormatDuration(waitDur),
+		)
+
+		if err := Sleep(ctx, waitDur); err != nil {
+			return nil, err
+		}
+		if err := Sleep(ctx, config.PostRateLimitBuffer); err != nil {
+			return nil, err
+		}
+		r.Accounts.ResetAllRateLimitsByProvider(r.Provider)
+		acc = r.Accounts.PickNextByProviderContext(ctx, r.Provider, model)
+	}
+
+	if acc == nil {
+		return nil, fmt.Errorf("no %s accounts available", r.Name)
+	}
+	return acc, nil
+}
+
+// Retry updates account state after a failed attempt with acc and reports whether the
+// request should move on to the next account.
+func (r *Rotation) Retry(ctx context.Context, acc *account.Account, model string, err error) bool {
+	// The client went away; don't move on to another account.
+	if ctx.Err() != nil {
+		return false
+	}
+
+	failure := r.Classify(err)
+	if failure.RateLimited {
+		r.Accounts.MarkRateLimited(acc.Email, failure.ResetMs, model)
+		TraceFromContext(ctx).RateLimited(acc.Email)
+		utils.Info("[%s] Account %s rate-limited, trying next...", r.Name, acc.Email)
+		return true
+	}
+	if failure.StatusCode == 0 {
+		return false
+	}
+	if r.Reject != nil && r.Reject(acc, failure.StatusCode) {
+		return true
+	}
+	// Retryable (default: 5xx) errors - try next account
+	if r.Policy.IsRetryableStatus(failure.StatusCode) {
+		utils.Warn("[%s] Account %s failed with %d error, trying next...", r.Name, acc.Email, failure.StatusCode)
+		return true
+	}
+	return false
+}
+
+// Report feeds the outcome of an upstream attempt with acc, sent at start, back into
+// account selection.
+func (r *Rotation) Report(acc *account.Account, model string, start time.Time, err error, empty bool) {
+	result := account.Result{
+		Email:    acc.Email,
+		Provider: r.Provider,
+		ModelID:  model,
+		Latency:  time.Since(start),
+		Empty:    empty,
+		Err:      err,
+	}
+	if err != nil {
+		failure := r.Classify(err)
+		result.RateLimited = failure.RateLimited
+		result.StatusCode = failure.StatusCode
+	}
+	r.Accounts.ReportResult(result)
+}
+
+// Attempt is an upstream request on an account. Once begun it counts as in flight for
+// draining and concurrency limits until it ends; only the first end reports a result.
+// All methods are safe on a nil Attempt.
+type Attempt struct {
+	Account *account.Account
+
+	r     *Rotation
+	model string
+	start time.Time
+	begun bool
+	ended bool
+}
+
+// Attempt prepares an attempt with acc for model. It isn't in flight until Begin.
+func (r *Rotation) Attempt(acc *account.Account, model string) *Attempt {
+	return &Attempt{Account: acc, r: r, model: model}
+}
+
+// Begin marks the attempt in flight and records it in the request's trace.
+func (a *Attempt) Begin(ctx context.Context) {
+	if a == nil || a.begun {
+		return
+	}
+	a.begun = true
+	a.start = time.Now()
+	a.r.Accounts.BeginRequest(a.Account.Email)
+	TraceFromContext(ctx).Attempt(a.Account.Email)
+}
+
+// Start returns when the attempt began.
+func (a *Attempt) Start() time.Time {
+	if a == nil {
+		return time.Time{}
+	}
+	return a.start
+}
+
+// End reports the attempt's result, unless it wasn't begun or was already ended.
+func (a *Attempt) End(err error, empty bool) {
+	if a == nil || !a.begun || a.ended {
+		return
+	}
+	a.ended = true
+	a.r.Report(a.Account, a.model, a.start, err, empty)
+}
+
+// Abandon ends an attempt left without a result. It counts as cancelled, so it doesn't
+// weigh on the account's health.
+func (a *Attempt) Abandon(ctx context.Context) {
+	err := ctx.Err()
+	if err == nil {
+		err = fmt.Errorf("request abandoned: %w", context.Canceled)
+	}
+	a.End(err, false)
+}
+
+// Detach hands the attempt over to code that reports its result itself, e.g. a
+// StreamRelay.
+func (a *Attempt) Detach() {
+	if a != nil {
+		a.ended = true
+	}
+}
+
+// Sleep waits for d, recording the wait in the request's trace. It returns early with
+// the context's error if ctx is done first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	TraceFromContext(ctx).Wait(d)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
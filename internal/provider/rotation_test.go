@@ -0,0 +1,159 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// statusError is an upstream error response in rotation tests.
+type statusError int
+
+func (e statusError) Error() string { return fmt.Sprintf("status %d", int(e)) }
+
+func newTestRotation(t *testing.T, emails ...string) *Rotation {
+	t.Helper()
+	mgr := account.NewManager(filepath.Join(t.TempDir(), "accounts.json"))
+	t.Cleanup(mgr.Flush)
+	for _, email := range emails {
+		if err := mgr.AddAccount(account.Account{Email: email, Provider: "test", Source: "manual", APIKey: "key"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mgr.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	return &Rotation{
+		Provider: "test",
+		Name:     "Test",
+		Accounts: mgr,
+		Policy:   config.DefaultRetryPolicy(),
+		Classify: func(err error) Failure {
+			var status statusError
+			if !errors.As(err, &status) {
+				return Failure{}
+			}
+			return Failure{StatusCode: int(status), RateLimited: status == 429, ResetMs: 60000}
+		},
+		Reject: func(acc *account.Account, status int) bool {
+			if status != 401 {
+				return false
+			}
+			mgr.MarkInvalid(acc.Email, "rejected")
+			return true
+		},
+	}
+}
+
+func TestRotation_Run(t *testing.T) {
+	tests := []struct {
+		name        string
+		first       error // Result of the first attempt; the second succeeds
+		unusable    bool  // The first account fails before Begin
+		wantErr     bool
+		wantSends   int
+		wantInvalid bool // The first account is marked invalid
+		wantLimited bool // The first account is marked rate-limited
+	}{
+		{name: "rate limited", first: statusError(429), wantSends: 2, wantLimited: true},
+		{name: "rejected", first: statusError(401), wantSends: 2, wantInvalid: true},
+		{name: "retryable", first: statusError(503), wantSends: 2},
+		{name: "not retryable", first: statusError(400), wantErr: true, wantSends: 1},
+		{name: "network error", first: errors.New("connection reset"), wantErr: true, wantSends: 1},
+		{name: "unusable account", first: ErrNoAPIKey, unusable: true, wantSends: 2, wantInvalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRotation(t, "a@example.com", "b@example.com")
+
+			var firstEmail string
+			sends := 0
+			err := r.Run(context.Background(), "model", func(at *Attempt) error {
+				sends++
+				if sends == 1 {
+					firstEmail = at.Account.Email
+					if !tt.unusable {
+						at.Begin(context.Background())
+					}
+					return tt.first
+				}
+				at.Begin(context.Background())
+				at.End(nil, false)
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if sends != tt.wantSends {
+				t.Errorf("sends = %d, want %d", sends, tt.wantSends)
+			}
+
+			for _, acc := range r.Accounts.GetAllAccountsByProvider("test") {
+				if acc.Email != firstEmail {
+					continue
+				}
+				if acc.IsInvalid != tt.wantInvalid {
+					t.Errorf("first account invalid = %v, want %v", acc.IsInvalid, tt.wantInvalid)
+				}
+				limited := acc.ModelRateLimits["model"].IsRateLimited
+				if limited != tt.wantLimited {
+					t.Errorf("first account rate-limited = %v, want %v", limited, tt.wantLimited)
+				}
+			}
+		})
+	}
+}
+
+func TestRotation_Run_ClientGone(t *testing.T) {
+	r := newTestRotation(t, "a@example.com", "b@example.com")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sends := 0
+	err := r.Run(ctx, "model", func(at *Attempt) error {
+		sends++
+		at.Begin(ctx)
+		cancel()
+		return statusError(503)
+	})
+	if err == nil || sends != 1 {
+		t.Errorf("Run = %v after %d sends, want the error after 1", err, sends)
+	}
+}
+
+func TestAttempt_InFlight(t *testing.T) {
+	r := newTestRotation(t, "a@example.com")
+	acc := r.Accounts.PickNextByProviderContext(context.Background(), "test", "model")
+	inFlight := func() int {
+		t.Helper()
+		status, err := r.Accounts.GetDrainStatus(acc.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return status.InFlight
+	}
+
+	at := r.Attempt(acc, "model")
+	at.Begin(context.Background())
+	if got := inFlight(); got != 1 {
+		t.Fatalf("in flight after Begin = %d, want 1", got)
+	}
+
+	// Ending an attempt that never began, or a nil one, reports nothing.
+	r.Attempt(acc, "model").Abandon(context.Background())
+	var none *Attempt
+	none.End(nil, false)
+	if got := inFlight(); got != 1 {
+		t.Fatalf("in flight after unbegun ends = %d, want 1", got)
+	}
+
+	at.End(statusError(503), false)
+	at.Abandon(context.Background())
+	if got := inFlight(); got != 0 {
+		t.Errorf("in flight after End = %d, want 0", got)
+	}
+}
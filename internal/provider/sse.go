@@ -0,0 +1,220 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// SSEParser parses a stream in the Anthropic Messages SSE format, which the Anthropic API,
+// Vertex AI (for Claude) and Z.AI all send.
+type SSEParser struct {
+	reader      io.ReadCloser
+	passthrough bool // Keep event data as json.RawMessage instead of decoding it
+}
+
+// NewSSEParser creates an SSE parser whose events' Raw is their decoded data.
+func NewSSEParser(reader io.ReadCloser) *SSEParser {
+	return &SSEParser{reader: reader}
+}
+
+// NewPassthroughParser creates an SSE parser for streams that are forwarded as sent: each
+// event's Raw is its data as a json.RawMessage, which is checked but not decoded into a map.
+func NewPassthroughParser(reader io.ReadCloser) *SSEParser {
+	return &SSEParser{reader: reader, passthrough: true}
+}
+
+// StreamEvents parses SSE events and returns them on a channel.
+// Returns two channels: events and a done channel that receives any error.
+func (p *SSEParser) StreamEvents() (<-chan types.StreamEvent, <-chan error) {
+	events := make(chan types.StreamEvent, 100)
+	done := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		defer close(done)
+		defer p.reader.Close()
+
+		scanner := bufio.NewScanner(p.reader)
+		// Increase buffer size for large events
+		buf := make([]byte, 0, 64*1024)
+		scanner.Buffer(buf, 1024*1024) // 1MB max
+
+		var currentEvent string
+		var currentData strings.Builder
+
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			if line == "" {
+				// Empty line signals end of event
+				if currentData.Len() > 0 {
+					evt := p.parseEvent(currentEvent, currentData.String())
+					if evt != nil {
+						events <- *evt
+					}
+				}
+				currentEvent = ""
+				currentData.Reset()
+				continue
+			}
+
+			if strings.HasPrefix(line, "event:") {
+				currentEvent = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			} else if strings.HasPrefix(line, "data:") {
+				data := strings.TrimPrefix(line, "data:")
+				data = strings.TrimSpace(data)
+				if currentData.Len() > 0 {
+					currentData.WriteString("\n")
+				}
+				currentData.WriteString(data)
+			}
+		}
+
+		// Handle any remaining event
+		if currentData.Len() > 0 {
+			evt := p.parseEvent(currentEvent, currentData.String())
+			if evt != nil {
+				events <- *evt
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			utils.Debug("[SSE] Scanner error: %v", err)
+			done <- err
+			return
+		}
+
+		done <- nil
+	}()
+
+	return events, done
+}
+
+// parseEvent parses a single SSE event. Events without an event: line take their type
+// from the data.
+func (p *SSEParser) parseEvent(eventType, data string) *types.StreamEvent {
+	if data == "" || data == "[DONE]" {
+		return nil
+	}
+
+	if p.passthrough {
+		return parsePassthroughEvent(eventType, data)
+	}
+
+	var rawData map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &rawData); err != nil {
+		utils.Debug("[SSE] Failed to parse event data: %v", err)
+		return nil
+	}
+	if eventType == "" {
+		eventType, _ = rawData["type"].(string)
+		if eventType == "" {
+			return nil
+		}
+	}
+
+	return &types.StreamEvent{
+		Type: eventType,
+		Raw:  rawData,
+	}
+}
+
+// parsePassthroughEvent returns an event whose Raw is data, unchanged. Decoding only the
+// type still rejects malformed JSON, without allocating the rest of the event.
+func parsePassthroughEvent(eventType, data string) *types.StreamEvent {
+	var head struct {
+		Type string `json:"type"`
+	}
+	raw := json.RawMessage(data)
+	if err := json.Unmarshal(raw, &head); err != nil {
+		utils.Debug("[SSE] Failed to parse event data: %v", err)
+		return nil
+	}
+	if eventType == "" {
+		if eventType = head.Type; eventType == "" {
+			return nil
+		}
+	}
+	return &types.StreamEvent{Type: eventType, Raw: raw}
+}
+
+// StreamTranslator rewrites the events of an upstream stream for Anthropic clients.
+type StreamTranslator interface {
+	// Translate returns the events to send for an upstream event.
+	Translate(evt types.StreamEvent) []types.StreamEvent
+	// Finish returns the events to send after a stream that ended without error.
+	Finish() []types.StreamEvent
+}
+
+// StreamRelay forwards a parsed upstream stream to the client of a streaming request.
+type StreamRelay struct {
+	Name       string                 // Provider name in logs, e.g. "Z.AI"
+	Tracker    *account.StreamTracker // Sees every upstream event
+	Report     func(account.Result)   // Receives the tracker's result once the stream is over
+	Translator StreamTranslator       // Rewrites the events on the way; nil forwards them as sent
+}
+
+// Start forwards the stream of events and done (see SSEParser.StreamEvents) to the returned
+// channel until it ends or ctx is done. A stream that fails ends with an error event, so the
+// client knows it was cut short.
+func (r StreamRelay) Start(ctx context.Context, events <-chan types.StreamEvent, done <-chan error) <-chan types.StreamEvent {
+	out := make(chan types.StreamEvent, 100)
+
+	go func() {
+		defer close(out)
+
+		send := func(evts ...types.StreamEvent) bool {
+			for _, evt := range evts {
+				select {
+				case out <- evt:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		}
+
+		for evt := range events {
+			r.Tracker.Observe(evt)
+			forward := []types.StreamEvent{evt}
+			if r.Translator != nil {
+				forward = r.Translator.Translate(evt)
+			}
+			if !send(forward...) {
+				r.Report(r.Tracker.Result(ctx.Err()))
+				return
+			}
+		}
+
+		// Wait for the parser to finish and report any error.
+		err := <-done
+		r.Report(r.Tracker.Result(err))
+		if err == nil {
+			if r.Translator != nil {
+				send(r.Translator.Finish()...)
+			}
+			return
+		}
+		utils.Error("[%s] SSE stream parsing error: %v", r.Name, err)
+		send(types.StreamEvent{
+			Type: "error",
+			Raw: map[string]interface{}{
+				"type": "error",
+				"error": map[string]interface{}{
+					"type":    string(merrors.StreamError("", err.Error()).Detail.Type),
+					"message": err.Error(),
+				},
+			},
+		})
+	}()
+
+	return out
+}
@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestSSEParser(t *testing.T) {
+	t.Run("parse simple events", func(t *testing.T) {
+		input := "event: message_start\ndata: {\"type\": \"message_start\", \"message\": {\"id\": \"msg_123\"}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\": \"content_block_delta\", \"delta\": {\"type\": \"text_delta\", \"text\": \"Hello\"}}\n\n" +
+			"event: message_stop\ndata: {\"type\": \"message_stop\"}\n\n"
+
+		reader := io.NopCloser(bytes.NewReader([]byte(input)))
+		parser := NewSSEParser(reader)
+		events, done := parser.StreamEvents()
+
+		// Receive message_start
+		evt := <-events
+		if evt.Type != "message_start" {
+			t.Errorf("expected event type message_start, got %s", evt.Type)
+		}
+		raw := evt.Raw.(map[string]interface{})
+		if raw["type"] != "message_start" {
+			t.Errorf("expected raw type message_start, got %v", raw["type"])
+		}
+
+		// Receive content_block_delta
+		evt = <-events
+		if evt.Type != "content_block_delta" {
+			t.Errorf("expected event type content_block_delta, got %s", evt.Type)
+		}
+		raw = evt.Raw.(map[string]interface{})
+		if raw["type"] != "content_block_delta" {
+			t.Errorf("expected raw type content_block_delta, got %v", raw["type"])
+		}
+		delta := raw["delta"].(map[string]interface{})
+		if delta["text"] != "Hello" {
+			t.Errorf("expected text Hello, got %v", delta["text"])
+		}
+
+		// Receive message_stop
+		evt = <-events
+		if evt.Type != "message_stop" {
+			t.Errorf("expected event type message_stop, got %s", evt.Type)
+		}
+
+		err := <-done
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		// Channel should be closed
+		_, ok := <-events
+		if ok {
+			t.Error("expected events channel to be closed")
+		}
+	})
+
+	t.Run("handles multiline data", func(t *testing.T) {
+		input := "event: message_start\n" +
+			"data: {\"type\": \"message_start\",\n" +
+			"data:  \"message\": {\"id\": \"msg_123\"}}\n\n"
+
+		reader := io.NopCloser(bytes.NewReader([]byte(input)))
+		parser := NewSSEParser(reader)
+		events, _ := parser.StreamEvents()
+
+		evt := <-events
+		if evt.Type != "message_start" {
+			t.Errorf("expected event type message_start, got %s", evt.Type)
+		}
+		raw := evt.Raw.(map[string]interface{})
+		msg := raw["message"].(map[string]interface{})
+		if msg["id"] != "msg_123" {
+			t.Errorf("expected id msg_123, got %v", msg["id"])
+		}
+	})
+
+	t.Run("handles [DONE] marker", func(t *testing.T) {
+		input := "event: message_stop\ndata: {\"type\": \"message_stop\"}\n\ndata: [DONE]\n\n"
+
+		reader := io.NopCloser(bytes.NewReader([]byte(input)))
+		parser := NewSSEParser(reader)
+		events, done := parser.StreamEvents()
+
+		evt := <-events
+		if evt.Type != "message_stop" {
+			t.Errorf("expected event type message_stop, got %s", evt.Type)
+		}
+
+		err := <-done
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		_, ok := <-events
+		if ok {
+			t.Error("expected events channel to be closed")
+		}
+	})
+	t.Run("takes the type of data-only events from the data", func(t *testing.T) {
+		input := "data: {\"type\": \"message_stop\"}\n\n: keep-alive\n\ndata: [DONE]\n\n"
+
+		parser := NewSSEParser(io.NopCloser(strings.NewReader(input)))
+		events, done := parser.StreamEvents()
+
+		evt := <-events
+		if evt.Type != "message_stop" {
+			t.Errorf("expected event type message_stop, got %s", evt.Type)
+		}
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, ok := <-events; ok {
+			t.Error("expected no further events")
+		}
+	})
+	t.Run("passthrough keeps the event data", func(t *testing.T) {
+		data := `{"type": "message_start", "message": {"id": "msg_123"}}`
+		input := "data: " + data + "\n\nevent: ping\ndata: {not json}\n\n"
+
+		events, done := NewPassthroughParser(io.NopCloser(strings.NewReader(input))).StreamEvents()
+
+		evt := <-events
+		if evt.Type != "message_start" {
+			t.Errorf("expected event type message_start, got %s", evt.Type)
+		}
+		if raw, ok := evt.Raw.(json.RawMessage); !ok || string(raw) != data {
+			t.Errorf("expected raw data %s, got %#v", data, evt.Raw)
+		}
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, ok := <-events; ok {
+			t.Error("expected the malformed event to be dropped")
+		}
+	})
+}
+
+// upperTranslator renames events, and ends a clean stream with a marker event.
+type upperTranslator struct{}
+
+func (upperTranslator) Translate(evt types.StreamEvent) []types.StreamEvent {
+	return []types.StreamEvent{{Type: strings.ToUpper(evt.Type)}}
+}
+
+func (upperTranslator) Finish() []types.StreamEvent {
+	return []types.StreamEvent{{Type: "finished"}}
+}
+
+func TestStreamRelay(t *testing.T) {
+	tests := []struct {
+		name       string
+		translator StreamTranslator
+		err        error
+		want       []string
+	}{
+		{name: "forwards events as sent", want: []string{"message_start", "message_stop"}},
+		{name: "translates events", translator: upperTranslator{}, want: []string{"MESSAGE_START", "MESSAGE_STOP", "finished"}},
+		{name: "ends a failed stream with an error", translator: upperTranslator{}, err: errors.New("connection reset"),
+			want: []string{"MESSAGE_START", "MESSAGE_STOP", "error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan types.StreamEvent, 2)
+			events <- types.StreamEvent{Type: "message_start"}
+			events <- types.StreamEvent{Type: "message_stop"}
+			close(events)
+			done := make(chan error, 1)
+			done <- tt.err
+
+			var results []account.Result
+			relay := StreamRelay{
+				Name:       "Test",
+				Tracker:    account.NewStreamTracker("a@example.com", "test", "model", time.Now()),
+				Report:     func(r account.Result) { results = append(results, r) },
+				Translator: tt.translator,
+			}
+			var got []string
+			for evt := range relay.Start(t.Context(), events, done) {
+				got = append(got, evt.Type)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got events %v, want %v", got, tt.want)
+			}
+			if len(results) != 1 || results[0].Email != "a@example.com" || !errors.Is(results[0].Err, tt.err) {
+				t.Errorf("expected the stream's result reported once, got %+v", results)
+			}
+		})
+	}
+}
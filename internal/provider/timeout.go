@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+)
+
+// ConnectTimeoutTransport wraps base so each upstream request of a provider must get its
+// response headers within the provider's UPSTREAM_CONNECT_TIMEOUT, or fails with a
+// TimeoutError. The rest of the response, such as a stream, is not bounded by it.
+func ConnectTimeoutTransport(providerName string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &connectTimeoutTransport{provider: providerName, base: base}
+}
+
+type connectTimeoutTransport struct {
+	provider string
+	base     http.RoundTripper
+}
+
+func (t *connectTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timeout := config.GetRequestTimeouts(t.provider).Connect
+	if timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancelCause(req.Context())
+	te := &merrors.TimeoutError{Phase: merrors.PhaseConnect, Timeout: timeout}
+	timer := time.AfterFunc(timeout, func() { cancel(te) })
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel(nil)
+		return nil, te
+	}
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the context of a response once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelCauseFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel(nil)
+	return err
+}
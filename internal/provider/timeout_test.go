@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+)
+
+func TestConnectTimeoutTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		// The body may take longer than the connect timeout.
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	}))
+	defer server.Close()
+
+	t.Setenv("TEST_UPSTREAM_CONNECT_TIMEOUT", "50ms")
+	client := &http.Client{Transport: ConnectTimeoutTransport("test", nil)}
+
+	resp, err := client.Get(server.URL + "/fast")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "done" {
+		t.Fatalf("expected the whole body, got %q (%v)", body, err)
+	}
+
+	_, err = client.Get(server.URL + "/slow")
+	var te *merrors.TimeoutError
+	if !errors.As(err, &te) || te.Phase != merrors.PhaseConnect {
+		t.Fatalf("expected a connect TimeoutError, got %v", err)
+	}
+}
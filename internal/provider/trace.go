@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Trace records what the proxy did on behalf of a single request: upstream attempts,
+// time spent waiting (queueing, rate-limit cooldowns, backoff) and which accounts were
+// rate-limited along the way. All methods are safe on a nil Trace.
+type Trace struct {
+	mu          sync.Mutex
+	attempts    int
+	account     string
+	waited      time.Duration
+	rateLimited map[string]struct{}
+	rateLimit   http.Header // anthropic-ratelimit-* and Retry-After of the last upstream response
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context that carries t to the provider.
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// TraceFromContext returns the request's trace, or nil when it isn't traced.
+func TraceFromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// Attempt records one upstream request attempt with the account of email ("" for
+// providers without accounts).
+func (t *Trace) Attempt(email string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.attempts++
+	t.account = email
+	t.mu.Unlock()
+}
+
+// Wait records time the request spent waiting inside the proxy.
+func (t *Trace) Wait(d time.Duration) {
+	if t == nil || d <= 0 {
+		return
+	}
+	t.mu.Lock()
+	t.waited += d
+	t.mu.Unlock()
+}
+
+// RateLimited records that an account was rate-limited while serving the request.
+func (t *Trace) RateLimited(email string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.rateLimited == nil {
+		t.rateLimited = make(map[string]struct{})
+	}
+	t.rateLimited[email] = struct{}{}
+	t.mu.Unlock()
+}
+
+// Attempts returns the number of upstream attempts.
+func (t *Trace) Attempts() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.attempts
+}
+
+// Account returns the account of the last attempt, which served the request if it
+// succeeded.
+func (t *Trace) Account() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.account
+}
+
+// Waited returns the total time spent waiting.
+func (t *Trace) Waited() time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.waited
+}
+
+// RateLimitedAccounts returns how many distinct accounts were rate-limited.
+func (t *Trace) RateLimitedAccounts() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.rateLimited)
+}
+
+// RecordRateLimit records the rate-limit headers of an upstream response (see
+// RateLimitHeaders), replacing those of earlier attempts so they describe the last response.
+func (t *Trace) RecordRateLimit(h http.Header) {
+	if t == nil {
+		return
+	}
+	rl := RateLimitHeaders(h, time.Now())
+	t.mu.Lock()
+	t.rateLimit = rl
+	t.mu.Unlock()
+}
+
+// RateLimit returns the rate-limit headers recorded by RecordRateLimit, or nil.
+func (t *Trace) RateLimit() http.Header {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rateLimit.Clone()
+}
+
+// Merge adds what from recorded to t, for a request served by an upstream call traced
+// separately: its attempts, waits and rate-limited accounts, and its account and rate-limit
+// headers when it has them.
+func (t *Trace) Merge(from *Trace) {
+	if t == nil || from == nil || t == from {
+		return
+	}
+	from.mu.Lock()
+	attempts, account, waited, rateLimit := from.attempts, from.account, from.waited, from.rateLimit.Clone()
+	rateLimited := make([]string, 0, len(from.rateLimited))
+	for email := range from.rateLimited {
+		rateLimited = append(rateLimited, email)
+	}
+	from.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attempts += attempts
+	t.waited += waited
+	if account != "" {
+		t.account = account
+	}
+	if rateLimit != nil {
+		t.rateLimit = rateLimit
+	}
+	for _, email := range rateLimited {
+		if t.rateLimited == nil {
+			t.rateLimited = make(map[string]struct{})
+		}
+		t.rateLimited[email] = struct{}{}
+	}
+}
@@ -0,0 +1,227 @@
+// Package vertex implements a provider for Claude and Gemini models on Google Vertex AI,
+// authenticated with service-account JSON keys.
+package vertex
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/antigravity"
+)
+
+// ServiceAccountKey is the subset of a Google service-account JSON key used for auth.
+type ServiceAccountKey struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+
+	signer *rsa.PrivateKey
+}
+
+// ParseServiceAccountKey parses and validates a service-account JSON key.
+func ParseServiceAccountKey(data []byte) (*ServiceAccountKey, error) {
+	var key ServiceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("invalid service account key: %w", err)
+	}
+	if key.Type != "service_account" {
+		return nil, fmt.Errorf("invalid service account key: type is %q, expected \"service_account\"", key.Type)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("invalid service account key: missing client_email or private_key")
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = config.OAuthConfig.TokenURL
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid service account key: private_key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		// Older keys use PKCS#1.
+		if rsaKey, pkcs1Err := x509.ParsePKCS1PrivateKey(block.Bytes); pkcs1Err == nil {
+			parsed = rsaKey
+		} else {
+			return nil, fmt.Errorf("invalid service account key: %w", err)
+		}
+	}
+	rsaKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid service account key: private_key is not an RSA key")
+	}
+	key.signer = rsaKey
+	return &key, nil
+}
+
+// signedJWT builds the RS256-signed assertion for the OAuth 2.0 JWT bearer grant.
+func (k *ServiceAccountKey) signedJWT(now time.Time) (string, error) {
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if k.PrivateKeyID != "" {
+		header["kid"] = k.PrivateKeyID
+	}
+	claims := map[string]interface{}{
+		"iss":   k.ClientEmail,
+		"scope": config.VertexTokenScope,
+		"aud":   k.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(nil, k.signer, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// tokenSource exchanges service-account keys for access tokens and caches them per client email.
+type tokenSource struct {
+	httpClient *http.Client
+	mu         sync.Mutex
+	tokens     map[string]cachedToken
+}
+
+func newTokenSource(httpClient *http.Client) *tokenSource {
+	return &tokenSource{
+		httpClient: httpClient,
+		tokens:     make(map[string]cachedToken),
+	}
+}
+
+// Token returns a cached access token, fetching a new one when it is within
+// config.TokenRefreshInterval of expiring.
+func (s *tokenSource) Token(ctx context.Context, key *ServiceAccountKey) (string, error) {
+	s.mu.Lock()
+	cached, ok := s.tokens[key.ClientEmail]
+	s.mu.Unlock()
+	if ok && time.Until(cached.expiresAt) > config.TokenRefreshInterval {
+		return cached.token, nil
+	}
+
+	ctx, cancel := merrors.WithTimeout(ctx, merrors.PhaseTokenFetch, config.GetRequestTimeouts(providerName).TokenFetch)
+	defer cancel()
+	token, expiresIn, err := s.fetch(ctx, key)
+	if err != nil {
+		if te := merrors.TimeoutCause(ctx); te != nil {
+			return "", te
+		}
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.tokens[key.ClientEmail] = cachedToken{token: token, expiresAt: time.Now().Add(expiresIn)}
+	s.mu.Unlock()
+	return token, nil
+}
+
+// Forget drops the cached token for an account, e.g. after the API rejects it.
+func (s *tokenSource) Forget(email string) {
+	s.mu.Lock()
+	delete(s.tokens, email)
+	s.mu.Unlock()
+}
+
+func (s *tokenSource) fetch(ctx context.Context, key *ServiceAccountKey) (string, time.Duration, error) {
+	assertion, err := key.signedJWT(time.Now())
+	if err != nil {
+		return "", 0, err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", key.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, tokenExchangeError(resp, body)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil || tokenResp.AccessToken == "" {
+		return "", 0, fmt.Errorf("invalid token response: %s", string(body))
+	}
+	return tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn) * time.Second, nil
+}
+
+// tokenExchangeError maps a failed token exchange to the error of the API call it was for.
+// Only a rejected key (401, or 400 invalid_grant) is reported as a 401, which invalidates the
+// account; rate limits and other statuses keep theirs, so an OAuth outage is retried.
+func tokenExchangeError(resp *http.Response, body []byte) error {
+	var oauthErr struct {
+		Error string `json:"error"`
+	}
+	_ = json.Unmarshal(body, &oauthErr)
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized,
+		resp.StatusCode == http.StatusBadRequest && oauthErr.Error == "invalid_grant":
+		return &HTTPStatusError{
+			StatusCode: http.StatusUnauthorized,
+			Message:    fmt.Sprintf("authentication_error: token exchange rejected the key (status %d): %s", resp.StatusCode, string(body)),
+		}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		resetMs := antigravity.ParseResetTime(resp, string(body))
+		if resetMs <= 0 {
+			resetMs = config.DefaultRateLimitResetMs
+		}
+		return &RateLimitError{
+			ResetMs: resetMs,
+			Message: fmt.Sprintf("rate_limit_error: token exchange: %s", string(body)),
+		}
+	default:
+		return &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("api_error: token exchange failed (status %d): %s", resp.StatusCode, string(body)),
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package vertex
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testKeyJSON returns a service-account key JSON whose token_uri points at tokenURI.
+func testKeyJSON(t *testing.T, tokenURI string) ([]byte, *rsa.PrivateKey) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "test-project",
+		"private_key_id": "kid-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "sa@test-project.iam.gserviceaccount.com",
+		"token_uri":      tokenURI,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, priv
+}
+
+// newTokenServer returns a token endpoint that counts exchanges.
+func newTokenServer(t *testing.T, calls *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"ya29.test","expires_in":3600,"token_type":"Bearer"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParseServiceAccountKey(t *testing.T) {
+	valid, _ := testKeyJSON(t, "")
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "valid", data: string(valid)},
+		{name: "not json", data: "nope", wantErr: "invalid service account key"},
+		{name: "wrong type", data: `{"type":"authorized_user"}`, wantErr: "expected \"service_account\""},
+		{name: "missing key", data: `{"type":"service_account","client_email":"a@b"}`, wantErr: "missing client_email or private_key"},
+		{name: "bad pem", data: `{"type":"service_account","client_email":"a@b","private_key":"xyz"}`, wantErr: "not PEM encoded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseServiceAccountKey([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// An empty token_uri falls back to Google's token endpoint.
+			if key.TokenURI == "" || key.ProjectID != "test-project" {
+				t.Errorf("unexpected key: %+v", key)
+			}
+		})
+	}
+}
+
+func TestSignedJWT(t *testing.T) {
+	data, priv := testKeyJSON(t, "https://oauth2.example.com/token")
+	key, err := ParseServiceAccountKey(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwt, err := key.signedJWT(time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 JWT parts, got %d", len(parts))
+	}
+
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]interface{}
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims["iss"] != key.ClientEmail || claims["aud"] != key.TokenURI {
+		t.Errorf("unexpected claims: %v", claims)
+	}
+
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&priv.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+func TestTokenSource_CachesAndForgets(t *testing.T) {
+	var calls int32
+	server := newTokenServer(t, &calls)
+	data, _ := testKeyJSON(t, server.URL)
+	key, err := ParseServiceAccountKey(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := newTokenSource(http.DefaultClient)
+	for i := 0; i < 2; i++ {
+		token, err := src.Token(context.Background(), key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if token != "ya29.test" {
+			t.Errorf("unexpected token %q", token)
+		}
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected 1 token exchange, got %d", calls)
+	}
+
+	src.Forget(key.ClientEmail)
+	if _, err := src.Token(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("expected a new exchange after Forget, got %d", calls)
+	}
+}
+
+func TestTokenSource_ExchangeFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int // HTTPStatusError status; 0 for a RateLimitError
+	}{
+		{"invalid grant", http.StatusBadRequest, `{"error":"invalid_grant"}`, http.StatusUnauthorized},
+		{"unauthorized", http.StatusUnauthorized, `{"error":"invalid_client"}`, http.StatusUnauthorized},
+		{"other bad request", http.StatusBadRequest, `{"error":"invalid_scope"}`, http.StatusBadRequest},
+		{"rate limited", http.StatusTooManyRequests, `{"error":"rate_limit_exceeded"}`, 0},
+		{"outage", http.StatusServiceUnavailable, `unavailable`, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			data, _ := testKeyJSON(t, server.URL)
+			key, err := ParseServiceAccountKey(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = newTokenSource(http.DefaultClient).Token(context.Background(), key)
+			if tt.wantStatus == 0 {
+				if _, ok := err.(*RateLimitError); !ok {
+					t.Errorf("expected RateLimitError, got %v", err)
+				}
+				return
+			}
+			httpErr, ok := err.(*HTTPStatusError)
+			if !ok || httpErr.StatusCode != tt.wantStatus {
+				t.Errorf("expected %d HTTPStatusError, got %v", tt.wantStatus, err)
+			}
+		})
+	}
+}
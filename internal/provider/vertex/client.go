@@ -0,0 +1,230 @@
+package vertex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/capture"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/egress"
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/antigravity"
+	"github.com/kuzerno1/multi-claude-proxy/internal/tracing"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// Client handles HTTP communication with Vertex AI.
+type Client struct {
+	httpClient *http.Client
+	tokens     *tokenSource
+	baseURL    string // Empty means the regional aiplatform.googleapis.com host
+}
+
+// NewClient creates a new Vertex AI client.
+func NewClient(baseURL string) *Client {
+	httpClient := &http.Client{Timeout: config.VertexTimeout, Transport: provider.ConnectTimeoutTransport(providerName, tracing.Transport(capture.Transport(egress.Transport())))}
+	return &Client{
+		httpClient: httpClient,
+		tokens:     newTokenSource(httpClient),
+		baseURL:    baseURL,
+	}
+}
+
+// endpoint returns the URL for a publisher model method, e.g. ":rawPredict".
+// The "global" region uses the non-regional host.
+func (c *Client) endpoint(key *ServiceAccountKey, region, publisher, model, method string) string {
+	base := c.baseURL
+	if base == "" {
+		if region == "global" {
+			base = "https://aiplatform.googleapis.com"
+		} else {
+			base = fmt.Sprintf("https://%s-aiplatform.googleapis.com", region)
+		}
+	}
+	return fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/%s/models/%s:%s",
+		base, key.ProjectID, region, publisher, model, method)
+}
+
+// SendClaude sends a non-streaming Anthropic Messages request via rawPredict.
+func (c *Client) SendClaude(ctx context.Context, key *ServiceAccountKey, region string, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	resp, err := c.post(ctx, key, c.endpoint(key, region, "anthropic", req.Model, "rawPredict"), claudeBody(req, false), false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var anthropicResp types.AnthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&anthropicResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &anthropicResp, nil
+}
+
+// StreamClaude sends a streaming Anthropic Messages request via streamRawPredict and
+// returns the Anthropic-format SSE body. The caller must close the returned reader.
+func (c *Client) StreamClaude(ctx context.Context, key *ServiceAccountKey, region string, req *types.AnthropicRequest) (io.ReadCloser, error) {
+	resp, err := c.post(ctx, key, c.endpoint(key, region, "anthropic", req.Model, "streamRawPredict"), claudeBody(req, true), true)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// SendGemini sends a non-streaming generateContent request.
+func (c *Client) SendGemini(ctx context.Context, key *ServiceAccountKey, region string, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	resp, err := c.post(ctx, key, c.endpoint(key, region, "google", req.Model, "generateContent"), antigravity.ConvertAnthropicToGoogle(req), false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var googleResp map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&googleResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	out := antigravity.ConvertGoogleToAnthropic(googleResp, req.Model, antigravity.SignatureSession(req))
+	antigravity.LimitToolUse(out, req.ToolChoice)
+	return out, nil
+}
+
+// StreamGemini sends a streamGenerateContent request and returns the Gemini SSE body.
+// The caller must close the returned reader.
+func (c *Client) StreamGemini(ctx context.Context, key *ServiceAccountKey, region string, req *types.AnthropicRequest) (io.ReadCloser, error) {
+	resp, err := c.post(ctx, key, c.endpoint(key, region, "google", req.Model, "streamGenerateContent")+"?alt=sse", antigravity.ConvertAnthropicToGoogle(req), true)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// VerifyKey checks that a service-account key can obtain an access token.
+func (c *Client) VerifyKey(ctx context.Context, key *ServiceAccountKey) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	_, err := c.tokens.Token(ctx, key)
+	return err
+}
+
+// claudeBody converts a request to the Vertex rawPredict body: the model moves to the URL
+// and anthropic_version is required in the body.
+func claudeBody(req *types.AnthropicRequest, stream bool) map[string]interface{} {
+	reqCopy := *req
+	reqCopy.Stream = stream
+
+	var body map[string]interface{}
+	data, _ := json.Marshal(reqCopy)
+	_ = json.Unmarshal(data, &body)
+	delete(body, "model")
+	body["anthropic_version"] = config.VertexAnthropicVersion
+	return body
+}
+
+func (c *Client) post(ctx context.Context, key *ServiceAccountKey, url string, payload interface{}, stream bool) (*http.Response, error) {
+	token, err := c.tokens.Token(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	utils.Debug("[Vertex] Sending request to %s", url)
+
+	// Streaming responses can outlive the client timeout, so only bound non-streaming calls.
+	httpClient := c.httpClient
+	if stream {
+		httpClient = &http.Client{Transport: c.httpClient.Transport}
+	}
+	config.SetProviderHeaders(req, "vertex")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	provider.TraceFromContext(ctx).RecordRateLimit(resp.Header)
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			c.tokens.Forget(key.ClientEmail)
+		}
+		return nil, handleErrorResponse(resp)
+	}
+	return resp, nil
+}
+
+// handleErrorResponse processes an error response from the API.
+func handleErrorResponse(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	errorText := string(body)
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("authentication_error: %s", errorText),
+			Body:       body,
+		}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		resetMs := antigravity.ParseResetTime(resp, errorText)
+		if resetMs <= 0 {
+			resetMs = config.DefaultRateLimitResetMs
+		}
+		return &RateLimitError{
+			ResetMs: resetMs,
+			Message: fmt.Sprintf("rate_limit_error: %s", errorText),
+		}
+	case resp.StatusCode >= 500:
+		return &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("server_error: status %d, body: %s", resp.StatusCode, errorText),
+			Body:       body,
+		}
+	default:
+		return &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("api_error: status %d, body: %s", resp.StatusCode, errorText),
+			Body:       body,
+		}
+	}
+}
+
+// HTTPStatusError represents an HTTP error with status code.
+type HTTPStatusError struct {
+	StatusCode int
+	Message    string
+	Body       []byte // The upstream's error response
+}
+
+func (e *HTTPStatusError) Error() string {
+	return e.Message
+}
+
+// AnthropicError maps the Vertex AI error response to the Anthropic error type.
+func (e *HTTPStatusError) AnthropicError() *merrors.AnthropicError {
+	return merrors.FromUpstream(e.StatusCode, e.Body)
+}
+
+// RateLimitError represents a rate limit error.
+type RateLimitError struct {
+	ResetMs int64
+	Message string
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,376 @@
+package vertex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider/antigravity"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+const providerName = "vertex"
+
+// Provider calls Claude and Gemini models on Vertex AI, rotating across service-account accounts.
+// Each account stores its service-account JSON key in Account.APIKey.
+type Provider struct {
+	accountManager *account.Manager
+	client         *Client
+	cfg            config.VertexConfig
+	rotation       *provider.Rotation
+	models         []string
+	modelSet       map[string]bool
+	initFailures   map[string]string // account email -> key parse error from Initialize
+	modelsMu       sync.RWMutex
+
+	keys   map[string]*ServiceAccountKey // account email -> parsed key
+	keysMu sync.Mutex
+}
+
+// NewProvider creates a new Vertex AI provider.
+func NewProvider(accountManager *account.Manager) *Provider {
+	cfg := config.GetVertexConfig()
+	p := &Provider{
+		accountManager: accountManager,
+		client:         NewClient(cfg.BaseURL),
+		cfg:            cfg,
+		models:         []string{},
+		modelSet:       make(map[string]bool),
+		keys:           make(map[string]*ServiceAccountKey),
+	}
+	p.rotation = &provider.Rotation{
+		Provider: providerName,
+		Name:     "Vertex",
+		Accounts: accountManager,
+		Policy:   config.GetRetryPolicy(providerName),
+		Classify: classifyError,
+		Reject:   p.rejectAccount,
+	}
+	return p
+}
+
+// Name returns the provider identifier.
+func (p *Provider) Name() string {
+	return providerName
+}
+
+// Models returns the list of model IDs this provider supports.
+func (p *Provider) Models() []string {
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	result := make([]string, len(p.models))
+	copy(result, p.models)
+	return result
+}
+
+// SupportsModel returns true if this provider handles the given model.
+func (p *Provider) SupportsModel(model string) bool {
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	return p.modelSet[model]
+}
+
+// Initialize validates account keys and loads the configured model list.
+// Vertex has no per-project model listing, so models come from VERTEX_MODELS.
+func (p *Provider) Initialize(ctx context.Context) error {
+	accounts := p.accountManager.GetAllAccountsByProvider(providerName)
+	if len(accounts) == 0 {
+		utils.Debug("[Vertex] No Vertex accounts configured, skipping initialization")
+		return nil
+	}
+
+	failures := make(map[string]string)
+	valid := 0
+	for i := range accounts {
+		if _, err := p.keyFor(&accounts[i]); err != nil {
+			utils.Warn("[Vertex] Account %s has an unusable service account key: %v", accounts[i].Email, err)
+			failures[accounts[i].Email] = err.Error()
+			continue
+		}
+		valid++
+	}
+
+	p.modelsMu.Lock()
+	defer p.modelsMu.Unlock()
+	p.initFailures = failures
+	if valid == 0 {
+		utils.Warn("[Vertex] No valid Vertex accounts available")
+		return nil
+	}
+
+	p.models = append([]string(nil), p.cfg.Models...)
+	p.modelSet = make(map[string]bool, len(p.models))
+	for _, m := range p.models {
+		p.modelSet[m] = true
+	}
+
+	utils.Success("[Vertex] Provider initialized with %d models (default region %s)", len(p.models), p.cfg.Region)
+	return nil
+}
+
+// InitFailures returns the accounts whose keys failed to parse during Initialize.
+func (p *Provider) InitFailures() map[string]string {
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	result := make(map[string]string, len(p.initFailures))
+	for k, v := range p.initFailures {
+		result[k] = v
+	}
+	return result
+}
+
+// Shutdown performs cleanup when the provider is being stopped.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	utils.Debug("[Vertex] Provider shutting down")
+	return nil
+}
+
+// keyFor returns the parsed service-account key for an account.
+func (p *Provider) keyFor(acc *account.Account) (*ServiceAccountKey, error) {
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	if key, ok := p.keys[acc.Email]; ok {
+		return key, nil
+	}
+	if acc.APIKey == "" {
+		return nil, fmt.Errorf("no service account key")
+	}
+	key, err := ParseServiceAccountKey([]byte(acc.APIKey))
+	if err != nil {
+		return nil, err
+	}
+	if acc.ProjectID != "" {
+		key.ProjectID = acc.ProjectID
+	}
+	if key.ProjectID == "" {
+		return nil, fmt.Errorf("service account key has no project_id")
+	}
+	p.keys[acc.Email] = key
+	return key, nil
+}
+
+// regionFor returns the account's region, falling back to VERTEX_REGION.
+func (p *Provider) regionFor(acc *account.Account) string {
+	if acc.Region != "" {
+		return acc.Region
+	}
+	return p.cfg.Region
+}
+
+// classifyError describes a Vertex AI error for account selection.
+func classifyError(err error) provider.Failure {
+	var rateLimitErr *RateLimitError
+	var httpErr *HTTPStatusError
+	switch {
+	case errors.As(err, &rateLimitErr):
+		return provider.Failure{StatusCode: 429, RateLimited: true, ResetMs: rateLimitErr.ResetMs}
+	case errors.As(err, &httpErr):
+		return provider.Failure{StatusCode: httpErr.StatusCode}
+	}
+	return provider.Failure{}
+}
+
+// rejectAccount handles statuses that say more about the account than the request.
+func (p *Provider) rejectAccount(acc *account.Account, status int) bool {
+	switch status {
+	case 401:
+		// 401 means the key itself was rejected.
+		p.accountManager.MarkInvalid(acc.Email, "service account rejected")
+		utils.Warn("[Vertex] Account %s was rejected (401), trying next...", acc.Email)
+		return true
+	case 403:
+		// 403 is usually a missing IAM role, or a model or region not enabled in the project:
+		// another account may serve the request, but this one's key is still good.
+		utils.Warn("[Vertex] Account %s was denied (403), trying next...", acc.Email)
+		return true
+	}
+	return false
+}
+
+// SendMessage handles non-streaming requests.
+func (p *Provider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
+	isGemini := config.GetModelFamily(req.Model) == config.ModelFamilyGemini
+	if isGemini {
+		// Claude models take server tools natively; Gemini models only have web search.
+		if err := antigravity.ValidateServerTools(req); err != nil {
+			return nil, err
+		}
+	}
+
+	var resp *types.AnthropicResponse
+	err := p.rotation.Run(ctx, req.Model, func(at *provider.Attempt) error {
+		acc := at.Account
+		key, err := p.keyFor(acc)
+		if err != nil {
+			return err
+		}
+		at.Begin(ctx)
+		var r *types.AnthropicResponse
+		if isGemini {
+			r, err = p.client.SendGemini(acc.RequestContext(ctx), key, p.regionFor(acc), req)
+		} else {
+			r, err = p.client.SendClaude(acc.RequestContext(ctx), key, p.regionFor(acc), req)
+		}
+		if err != nil {
+			return err
+		}
+		at.End(nil, len(r.Content) == 0)
+		resp = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SendMessageStream handles streaming requests.
+func (p *Provider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
+	isGemini := config.GetModelFamily(req.Model) == config.ModelFamilyGemini
+	if isGemini {
+		if err := antigravity.ValidateServerTools(req); err != nil {
+			return nil, err
+		}
+	}
+
+	var stream <-chan types.StreamEvent
+	err := p.rotation.Run(ctx, req.Model, func(at *provider.Attempt) error {
+		acc := at.Account
+		key, err := p.keyFor(acc)
+		if err != nil {
+			return err
+		}
+		at.Begin(ctx)
+		var reader io.ReadCloser
+		if isGemini {
+			reader, err = p.client.StreamGemini(acc.RequestContext(ctx), key, p.regionFor(acc), req)
+		} else {
+			reader, err = p.client.StreamClaude(acc.RequestContext(ctx), key, p.regionFor(acc), req)
+		}
+		if err != nil {
+			return err
+		}
+
+		var events <-chan types.StreamEvent
+		var done <-chan error
+		if isGemini {
+			events, done = geminiStreamEvents(reader, req)
+		} else {
+			// Vertex streams Claude responses in the native Anthropic SSE format.
+			events, done = provider.NewSSEParser(reader).StreamEvents()
+		}
+		relay := provider.StreamRelay{
+			Name:    p.rotation.Name,
+			Tracker: account.NewStreamTracker(acc.Email, providerName, req.Model, at.Start()),
+			Report:  p.accountManager.ReportResult,
+		}
+		at.Detach()
+		stream = relay.Start(ctx, events, done)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// geminiStreamEvents converts a Gemini SSE body into Anthropic stream events using the
+// Antigravity streaming parser.
+func geminiStreamEvents(reader io.ReadCloser, req *types.AnthropicRequest) (<-chan types.StreamEvent, <-chan error) {
+	parser := antigravity.NewStreamingParser(reader, req.Model)
+	parser.SetToolChoice(req.ToolChoice)
+	parser.SetSession(antigravity.SignatureSession(req))
+	return parser.StreamEvents()
+}
+
+// ListModels returns available models with metadata.
+func (p *Provider) ListModels(ctx context.Context) (*types.ModelsResponse, error) {
+	models := p.Models()
+	data := make([]types.Model, len(models))
+	for i, m := range models {
+		data[i] = types.Model{ID: m, DisplayName: m, Type: "model"}
+	}
+	return &types.ModelsResponse{Data: data}, nil
+}
+
+// GetStatus returns provider health and rate limit information.
+// Vertex quotas aren't queryable per request, so limits reflect the proxy's own rate limit tracking.
+func (p *Provider) GetStatus(ctx context.Context) (*types.ProviderStatus, error) {
+	accounts := p.accountManager.GetAllAccountsByProvider(providerName)
+	accountStatuses := make([]types.AccountStatus, len(accounts))
+
+	overallStatus := "ok"
+
+	for i, acc := range accounts {
+		status := types.AccountStatus{
+			Email:    acc.Email,
+			Status:   "ok",
+			LastUsed: acc.LastUsed,
+			Limits:   make(map[string]types.ModelQuota),
+		}
+
+		if acc.IsInvalid {
+			status.Status = "invalid"
+			status.Error = string(acc.InvalidReason)
+			overallStatus = "degraded"
+			accountStatuses[i] = status
+			continue
+		}
+
+		// Check that the key can still obtain an access token
+		key, err := p.keyFor(&acc)
+		if err == nil {
+			err = p.client.VerifyKey(acc.RequestContext(ctx), key)
+		}
+		if err != nil {
+			status.Status = "error"
+			status.Error = fmt.Sprintf("token fetch failed: %v", err)
+			overallStatus = "degraded"
+			accountStatuses[i] = status
+			continue
+		}
+
+		p.modelsMu.RLock()
+		for _, modelID := range p.models {
+			if limit, ok := acc.ModelRateLimits[modelID]; ok && limit.IsRateLimited {
+				status.Limits[modelID] = types.ModelQuota{
+					RemainingFraction:   0,
+					RemainingPercentage: 0,
+				}
+				status.Status = "rate-limited"
+			} else {
+				status.Limits[modelID] = types.ModelQuota{
+					RemainingFraction:   1.0,
+					RemainingPercentage: 100,
+				}
+			}
+		}
+		p.modelsMu.RUnlock()
+
+		if status.Status != "ok" {
+			overallStatus = "degraded"
+		}
+
+		accountStatuses[i] = status
+	}
+
+	return &types.ProviderStatus{
+		Name:      providerName,
+		Status:    overallStatus,
+		Accounts:  accountStatuses,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// GenerateImage is not supported by the Vertex provider.
+func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
+	return nil, fmt.Errorf("image generation is not supported by the Vertex provider")
+}
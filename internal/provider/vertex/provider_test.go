@@ -0,0 +1,263 @@
+package vertex
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func setupTestAccountManager(t *testing.T, accounts []account.Account) *account.Manager {
+	tmpDir, err := os.MkdirTemp("", "mcp-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	mgr := account.NewManager(filepath.Join(tmpDir, "accounts.json"))
+	for _, acc := range accounts {
+		if err := mgr.AddAccount(acc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mgr.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	return mgr
+}
+
+// newVertexServer serves both the token endpoint and publisher model methods, recording request paths.
+func newVertexServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, body map[string]interface{})) (*httptest.Server, *[]string) {
+	t.Helper()
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Write([]byte(`{"access_token":"ya29.test","expires_in":3600}`))
+			return
+		}
+		paths = append(paths, r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer ya29.test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		handler(w, r, body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &paths
+}
+
+func newTestProvider(t *testing.T, serverURL string, accounts ...account.Account) *Provider {
+	t.Helper()
+	mgr := setupTestAccountManager(t, accounts)
+	p := NewProvider(mgr)
+	p.client = NewClient(serverURL)
+	if err := p.Initialize(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func testAccount(t *testing.T, serverURL, email string) account.Account {
+	data, _ := testKeyJSON(t, serverURL+"/token")
+	return account.Account{Email: email, Provider: "vertex", Source: "manual", APIKey: string(data)}
+}
+
+func TestClient_Endpoint(t *testing.T) {
+	key := &ServiceAccountKey{ProjectID: "proj"}
+	tests := []struct {
+		name    string
+		baseURL string
+		region  string
+		want    string
+	}{
+		{
+			name:   "regional",
+			region: "europe-west1",
+			want:   "https://europe-west1-aiplatform.googleapis.com/v1/projects/proj/locations/europe-west1/publishers/anthropic/models/claude-x:rawPredict",
+		},
+		{
+			name:   "global",
+			region: "global",
+			want:   "https://aiplatform.googleapis.com/v1/projects/proj/locations/global/publishers/anthropic/models/claude-x:rawPredict",
+		},
+		{
+			name:    "base url override",
+			baseURL: "http://localhost:9999",
+			region:  "us-east5",
+			want:    "http://localhost:9999/v1/projects/proj/locations/us-east5/publishers/anthropic/models/claude-x:rawPredict",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewClient(tt.baseURL).endpoint(key, tt.region, "anthropic", "claude-x", "rawPredict")
+			if got != tt.want {
+				t.Errorf("endpoint = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvider_SendMessage_Claude(t *testing.T) {
+	server, paths := newVertexServer(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		if _, ok := body["model"]; ok || body["anthropic_version"] != "vertex-2023-10-16" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}]}`))
+	})
+
+	acc := testAccount(t, server.URL, "sa@test-project.iam.gserviceaccount.com")
+	acc.Region = "europe-west1"
+	p := newTestProvider(t, server.URL, acc)
+
+	resp, err := p.SendMessage(context.Background(), &types.AnthropicRequest{Model: "claude-sonnet-4-5@20250929"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ID != "msg_1" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	want := "/v1/projects/test-project/locations/europe-west1/publishers/anthropic/models/claude-sonnet-4-5@20250929:rawPredict"
+	if len(*paths) != 1 || (*paths)[0] != want {
+		t.Errorf("unexpected paths %v", *paths)
+	}
+}
+
+func TestProvider_SendMessage_Gemini(t *testing.T) {
+	server, paths := newVertexServer(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		if _, ok := body["contents"]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"hi"}]},"finishReason":"STOP"}]}`))
+	})
+
+	p := newTestProvider(t, server.URL, testAccount(t, server.URL, "sa@test-project.iam.gserviceaccount.com"))
+
+	resp, err := p.SendMessage(context.Background(), &types.AnthropicRequest{
+		Model:    "gemini-2.5-pro",
+		Messages: []types.Message{{Role: "user", Content: json.RawMessage(`"hello"`)}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Text != "hi" {
+		t.Errorf("unexpected content %+v", resp.Content)
+	}
+	if len(*paths) != 1 || !strings.HasSuffix((*paths)[0], "/locations/us-east5/publishers/google/models/gemini-2.5-pro:generateContent") {
+		t.Errorf("unexpected paths %v", *paths)
+	}
+}
+
+func TestProvider_SendMessage_RateLimitFailover(t *testing.T) {
+	server, _ := newVertexServer(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		if strings.Contains(r.URL.Path, "/locations/us-central1/") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"code":429,"status":"RESOURCE_EXHAUSTED"}}`))
+			return
+		}
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}]}`))
+	})
+
+	limited := testAccount(t, server.URL, "limited@test-project.iam.gserviceaccount.com")
+	limited.Region = "us-central1"
+	p := newTestProvider(t, server.URL, limited, testAccount(t, server.URL, "ok@test-project.iam.gserviceaccount.com"))
+
+	// Two requests guarantee the limited account is picked at least once.
+	for i := 0; i < 2; i++ {
+		if _, err := p.SendMessage(context.Background(), &types.AnthropicRequest{Model: "claude-sonnet-4-5@20250929"}); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+	}
+
+	for _, acc := range p.accountManager.GetAllAccountsByProvider(providerName) {
+		limitedNow := acc.ModelRateLimits["claude-sonnet-4-5@20250929"].IsRateLimited
+		if limitedNow != (acc.Email == limited.Email) {
+			t.Errorf("account %s: rate limited = %v", acc.Email, limitedNow)
+		}
+	}
+}
+
+func TestProvider_SendMessage_TransientErrorsKeepAccountsValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		tokenStatus   int // Status of the token exchange; 0 for a token
+		messageStatus int
+	}{
+		{"token exchange outage", http.StatusServiceUnavailable, 0},
+		{"permission denied", 0, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/token" {
+					if tt.tokenStatus != 0 {
+						w.WriteHeader(tt.tokenStatus)
+						return
+					}
+					w.Write([]byte(`{"access_token":"ya29.test","expires_in":3600}`))
+					return
+				}
+				w.WriteHeader(tt.messageStatus)
+				w.Write([]byte(`{"error":{"code":403,"status":"PERMISSION_DENIED"}}`))
+			}))
+			t.Cleanup(server.Close)
+			p := newTestProvider(t, server.URL, testAccount(t, server.URL, "sa@test-project.iam.gserviceaccount.com"))
+
+			if _, err := p.SendMessage(context.Background(), &types.AnthropicRequest{Model: "claude-sonnet-4-5@20250929"}); err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, acc := range p.accountManager.GetAllAccountsByProvider(providerName) {
+				if acc.IsInvalid {
+					t.Errorf("account %s was marked invalid: %s", acc.Email, acc.InvalidReason)
+				}
+			}
+		})
+	}
+}
+
+func TestProvider_SendMessageStream_Claude(t *testing.T) {
+	server, paths := newVertexServer(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+	})
+
+	p := newTestProvider(t, server.URL, testAccount(t, server.URL, "sa@test-project.iam.gserviceaccount.com"))
+
+	events, err := p.SendMessageStream(context.Background(), &types.AnthropicRequest{Model: "claude-sonnet-4-5@20250929"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for evt := range events {
+		got = append(got, evt.Type)
+	}
+	if len(got) != 2 || got[0] != "message_start" || got[1] != "message_stop" {
+		t.Errorf("unexpected events: %v", got)
+	}
+	if len(*paths) != 1 || !strings.HasSuffix((*paths)[0], ":streamRawPredict") {
+		t.Errorf("unexpected paths %v", *paths)
+	}
+}
+
+func TestProvider_Initialize_InvalidKey(t *testing.T) {
+	p := newTestProvider(t, "", account.Account{Email: "broken", Provider: "vertex", Source: "manual", APIKey: "{}"})
+
+	if len(p.Models()) != 0 {
+		t.Errorf("expected no models without a valid key, got %v", p.Models())
+	}
+	if _, ok := p.InitFailures()["broken"]; !ok {
+		t.Errorf("expected init failure for broken account, got %v", p.InitFailures())
+	}
+}
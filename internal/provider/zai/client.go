@@ -10,7 +10,11 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/kuzerno1/multi-claude-proxy/internal/capture"
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/egress"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/tracing"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
@@ -27,7 +31,8 @@ type Client struct {
 func NewClient() *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: config.ZAITimeout,
+			Timeout:   config.ZAITimeout,
+			Transport: provider.ConnectTimeoutTransport(providerName, tracing.Transport(capture.Transport(egress.Transport()))),
 		},
 		baseURL:    config.ZAIBaseURL,
 		modelsPath: config.ZAIModelsPath,
@@ -107,6 +112,7 @@ func (c *Client) FetchModels(ctx context.Context, apiKey string) ([]ModelEntry,
 	req.Header.Set(config.ZAIAuthHeader, "Bearer "+apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
+	config.SetProviderHeaders(req, "zai")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -155,6 +161,7 @@ func (c *Client) FetchQuota(ctx context.Context, apiKey string) (*QuotaInfo, err
 	req.Header.Set(config.ZAIAuthHeader, "Bearer "+apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
+	config.SetProviderHeaders(req, "zai")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -228,10 +235,12 @@ func (c *Client) SendMessage(ctx context.Context, apiKey string, anthropicReq *t
 
 	utils.Debug("[Z.AI] Sending non-streaming request to %s", url)
 
+	config.SetProviderHeaders(req, "zai")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
+	provider.TraceFromContext(ctx).RecordRateLimit(resp.Header)
 	defer resp.Body.Close()
 
 	return c.handleResponse(resp)
@@ -263,13 +272,16 @@ func (c *Client) SendMessageStream(ctx context.Context, apiKey string, anthropic
 
 	// Use a client without timeout for streaming
 	streamClient := &http.Client{
-		Timeout: 0, // No timeout for streaming
+		Timeout:   0, // No timeout for streaming
+		Transport: c.httpClient.Transport,
 	}
 
+	config.SetProviderHeaders(req, "zai")
 	resp, err := streamClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
+	provider.TraceFromContext(ctx).RecordRateLimit(resp.Header)
 
 	// Check for error responses
 	if resp.StatusCode != http.StatusOK {
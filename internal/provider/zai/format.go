@@ -0,0 +1,119 @@
+package zai
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// convertRequest adapts an Anthropic request to what Z.AI's Anthropic-compatible endpoint
+// accepts. Z.AI runs no server tools and verifies no thinking signatures, so:
+//   - server tools (web_search, code_execution, ...) are dropped from the tool list;
+//   - redacted_thinking blocks, which only the issuing vendor can read, are dropped;
+//   - server tool calls and results and other extended blocks in the history, including
+//     inside tool_result content, become text blocks.
+//
+// Thinking blocks pass through, so GLM reasoning models see their earlier reasoning.
+// req is not modified; messages that need no changes are shared with the result.
+func convertRequest(req *types.AnthropicRequest) *types.AnthropicRequest {
+	out := *req
+
+	if slices.ContainsFunc(req.Tools, func(t types.Tool) bool { return t.Raw != nil }) {
+		out.Tools = nil
+		for _, tool := range req.Tools {
+			if tool.Raw != nil {
+				utils.Debug("[Z.AI] Dropping unsupported tool %s (%s)", tool.Name, tool.Type)
+				continue
+			}
+			out.Tools = append(out.Tools, tool)
+		}
+		if tc := req.ToolChoice; tc != nil && (len(out.Tools) == 0 ||
+			tc.Type == "tool" && !slices.ContainsFunc(out.Tools, func(t types.Tool) bool { return t.Name == tc.Name })) {
+			out.ToolChoice = nil
+		}
+	}
+
+	out.Messages = make([]types.Message, len(req.Messages))
+	for i, msg := range req.Messages {
+		out.Messages[i] = msg
+		if len(msg.Content) == 0 || msg.Content[0] != '[' {
+			continue
+		}
+		blocks, err := types.ParseMessageContent(msg.Content)
+		if err != nil {
+			continue
+		}
+		converted, changed := convertBlocks(blocks)
+		if !changed {
+			continue
+		}
+		if len(converted) == 0 {
+			converted = []types.ContentBlock{{Type: "text", Text: "(empty)"}}
+		}
+		if content, err := json.Marshal(converted); err == nil {
+			out.Messages[i].Content = content
+		}
+	}
+	return &out
+}
+
+// convertBlocks applies convertRequest's block rules. changed is false when blocks can be
+// sent as they are.
+func convertBlocks(blocks []types.ContentBlock) (out []types.ContentBlock, changed bool) {
+	out = make([]types.ContentBlock, 0, len(blocks))
+	for _, block := range blocks {
+		switch {
+		case block.Type == "redacted_thinking":
+			changed = true
+		case block.Type == "tool_result":
+			if content, ok := convertToolResultContent(block.Content); ok {
+				block.Content = content
+				changed = true
+			}
+			out = append(out, block)
+		case block.Raw != nil:
+			changed = true
+			if text, ok := block.ExtendedText(); ok {
+				out = append(out, types.ContentBlock{Type: "text", Text: text})
+			}
+		default:
+			out = append(out, block)
+		}
+	}
+	return out, changed
+}
+
+// convertToolResultContent converts the extended blocks of an array tool_result content.
+// ok is false when the content needs no changes.
+func convertToolResultContent(content json.RawMessage) (json.RawMessage, bool) {
+	if len(content) == 0 || content[0] != '[' {
+		return nil, false
+	}
+	var blocks []types.ContentBlock
+	if err := json.Unmarshal(content, &blocks); err != nil {
+		return nil, false
+	}
+	converted, changed := convertBlocks(blocks)
+	if !changed {
+		return nil, false
+	}
+	out, err := json.Marshal(converted)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// convertResponse fixes up a Z.AI response for Anthropic clients: GLM models may end a turn
+// that calls tools with stop_reason end_turn, which clients take as the final answer.
+func convertResponse(resp *types.AnthropicResponse) *types.AnthropicResponse {
+	if resp == nil {
+		return nil
+	}
+	if resp.StopReason == "end_turn" && slices.ContainsFunc(resp.Content, func(b types.ContentBlock) bool { return b.Type == "tool_use" }) {
+		resp.StopReason = "tool_use"
+	}
+	return resp
+}
@@ -0,0 +1,101 @@
+package zai
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+func TestConvertRequest(t *testing.T) {
+	body := `{
+		"model": "glm-4.7",
+		"tools": [
+			{"name": "read_file", "input_schema": {"type": "object"}},
+			{"type": "web_search_20250305", "name": "web_search", "max_uses": 3}
+		],
+		"tool_choice": {"type": "tool", "name": "web_search"},
+		"messages": [
+			{"role": "user", "content": "find it"},
+			{"role": "assistant", "content": [
+				{"type": "thinking", "thinking": "search first", "signature": "sig"},
+				{"type": "redacted_thinking", "data": "opaque"},
+				{"type": "server_tool_use", "id": "srvtoolu_1", "name": "web_search", "input": {"query": "glm"}},
+				{"type": "web_search_tool_result", "tool_use_id": "srvtoolu_1", "content": [{"type": "web_search_result", "title": "GLM", "url": "https://example.com"}]},
+				{"type": "tool_use", "id": "toolu_1", "name": "read_file", "input": {"path": "a.txt"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": [
+					{"type": "text", "text": "contents"},
+					{"type": "search_result", "source": "https://example.com", "title": "Doc", "content": [{"type": "text", "text": "snippet"}]}
+				]}
+			]},
+			{"role": "assistant", "content": [{"type": "redacted_thinking", "data": "opaque"}]}
+		]
+	}`
+	var req types.AnthropicRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	original, _ := json.Marshal(req)
+
+	out := convertRequest(&req)
+
+	if len(out.Tools) != 1 || out.Tools[0].Name != "read_file" {
+		t.Errorf("expected only the client tool, got %+v", out.Tools)
+	}
+	if out.ToolChoice != nil {
+		t.Errorf("expected tool_choice for a dropped tool to be cleared, got %+v", out.ToolChoice)
+	}
+	if string(out.Messages[0].Content) != `"find it"` {
+		t.Errorf("expected string content unchanged, got %s", out.Messages[0].Content)
+	}
+
+	assistant, _ := types.ParseMessageContent(out.Messages[1].Content)
+	var blockTypes []string
+	for _, b := range assistant {
+		blockTypes = append(blockTypes, b.Type)
+	}
+	if got := strings.Join(blockTypes, " "); got != "thinking text text tool_use" {
+		t.Errorf("assistant blocks = %s", got)
+	}
+	if assistant[0].Signature != "sig" || !strings.Contains(assistant[2].Text, "GLM") {
+		t.Errorf("unexpected assistant blocks: %+v", assistant)
+	}
+
+	results, _ := types.ParseMessageContent(out.Messages[2].Content)
+	resultContent := string(results[0].Content)
+	if strings.Contains(resultContent, "search_result") || !strings.Contains(resultContent, "snippet") {
+		t.Errorf("expected the search result rendered as text, got %s", resultContent)
+	}
+
+	if got := string(out.Messages[3].Content); got != `[{"type":"text","text":"(empty)"}]` {
+		t.Errorf("expected a placeholder for an emptied message, got %s", got)
+	}
+
+	if after, _ := json.Marshal(req); string(after) != string(original) {
+		t.Error("convertRequest modified the original request")
+	}
+}
+
+func TestConvertResponse_ToolUseStopReason(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []types.ContentBlock
+		stop    string
+		want    string
+	}{
+		{"tool call ended as end_turn", []types.ContentBlock{{Type: "text", Text: "reading"}, {Type: "tool_use", ID: "toolu_1", Name: "read_file"}}, "end_turn", "tool_use"},
+		{"text only", []types.ContentBlock{{Type: "text", Text: "done"}}, "end_turn", "end_turn"},
+		{"max tokens kept", []types.ContentBlock{{Type: "tool_use", ID: "toolu_1", Name: "read_file"}}, "max_tokens", "max_tokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := convertResponse(&types.AnthropicResponse{Content: tt.content, StopReason: tt.stop})
+			if resp.StopReason != tt.want {
+				t.Errorf("stop_reason = %s, want %s", resp.StopReason, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/account"
 	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
@@ -19,21 +20,32 @@ const providerName = "zai"
 type Provider struct {
 	accountManager *account.Manager
 	client         *Client
-	models         []string           // Model IDs for backwards compatibility
-	modelEntries   []ModelEntry       // Full model entries with display_name and created_at
+	rotation       *provider.Rotation
+	models         []string     // Model IDs for backwards compatibility
+	modelEntries   []ModelEntry // Full model entries with display_name and created_at
 	modelSet       map[string]bool
+	initFailures   map[string]string // account email -> model fetch error from Initialize
 	modelsMu       sync.RWMutex
 }
 
 // NewProvider creates a new Z.AI provider.
 func NewProvider(accountManager *account.Manager) *Provider {
-	return &Provider{
+	p := &Provider{
 		accountManager: accountManager,
 		client:         NewClient(),
 		models:         []string{},
 		modelEntries:   []ModelEntry{},
 		modelSet:       make(map[string]bool),
 	}
+	p.rotation = &provider.Rotation{
+		Provider: providerName,
+		Name:     "Z.AI",
+		Accounts: accountManager,
+		Policy:   config.GetRetryPolicy(providerName),
+		Classify: classifyError,
+		Reject:   p.rejectAccount,
+	}
+	return p
 }
 
 // Name returns the provider identifier.
@@ -58,6 +70,7 @@ func (p *Provider) SupportsModel(model string) bool {
 }
 
 // Initialize performs any setup required by the provider.
+// Models are fetched from all valid accounts in parallel; the first success wins.
 func (p *Provider) Initialize(ctx context.Context) error {
 	accounts := p.accountManager.GetAllAccountsByProvider(providerName)
 	if len(accounts) == 0 {
@@ -65,7 +78,8 @@ func (p *Provider) Initialize(ctx context.Context) error {
 		return nil
 	}
 
-	// Use the first available account to fetch models
+	byEmail := make(map[string]account.Account, len(accounts))
+	emails := make([]string, 0, len(accounts))
 	for _, acc := range accounts {
 		if acc.IsInvalid {
 			continue
@@ -73,31 +87,53 @@ func (p *Provider) Initialize(ctx context.Context) error {
 		if acc.APIKey == "" {
 			continue
 		}
+		byEmail[acc.Email] = acc
+		emails = append(emails, acc.Email)
+	}
 
-		modelEntries, err := p.client.FetchModels(ctx, acc.APIKey)
-		if err != nil {
-			utils.Warn("[Z.AI] Failed to fetch models using account %s: %v", acc.Email, err)
-			continue
-		}
+	modelEntries, _, failures, err := provider.FetchFirst(ctx, config.ModelPrefetchTimeout, emails,
+		func(ctx context.Context, email string) ([]ModelEntry, error) {
+			acc := byEmail[email]
+			return p.client.FetchModels(acc.RequestContext(ctx), acc.APIKey)
+		})
 
-		p.modelsMu.Lock()
-		p.modelEntries = modelEntries
-		p.models = make([]string, len(modelEntries))
-		p.modelSet = make(map[string]bool, len(modelEntries))
-		for i, m := range modelEntries {
-			p.models[i] = m.ID
-			p.modelSet[m.ID] = true
-		}
-		p.modelsMu.Unlock()
+	for email, failErr := range failures {
+		utils.Warn("[Z.AI] Failed to fetch models using account %s: %v", email, failErr)
+	}
+	p.modelsMu.Lock()
+	p.initFailures = provider.FailureMessages(failures)
+	p.modelsMu.Unlock()
 
-		utils.Success("[Z.AI] Provider initialized with %d models", len(modelEntries))
+	if err != nil {
+		utils.Warn("[Z.AI] No valid Z.AI accounts available to fetch models")
 		return nil
 	}
 
-	utils.Warn("[Z.AI] No valid Z.AI accounts available to fetch models")
+	p.modelsMu.Lock()
+	p.modelEntries = modelEntries
+	p.models = make([]string, len(modelEntries))
+	p.modelSet = make(map[string]bool, len(modelEntries))
+	for i, m := range modelEntries {
+		p.models[i] = m.ID
+		p.modelSet[m.ID] = true
+	}
+	p.modelsMu.Unlock()
+
+	utils.Success("[Z.AI] Provider initialized with %d models", len(modelEntries))
 	return nil
 }
 
+// InitFailures returns the accounts that failed to fetch models during Initialize.
+func (p *Provider) InitFailures() map[string]string {
+	p.modelsMu.RLock()
+	defer p.modelsMu.RUnlock()
+	result := make(map[string]string, len(p.initFailures))
+	for k, v := range p.initFailures {
+		result[k] = v
+	}
+	return result
+}
+
 // Shutdown performs cleanup when the provider is being stopped.
 func (p *Provider) Shutdown(ctx context.Context) error {
 	utils.Debug("[Z.AI] Provider shutting down")
@@ -106,218 +142,82 @@ func (p *Provider) Shutdown(ctx context.Context) error {
 
 // SendMessage handles non-streaming requests.
 func (p *Provider) SendMessage(ctx context.Context, req *types.AnthropicRequest) (*types.AnthropicResponse, error) {
-	maxAttempts := config.MaxRetries
-	if count := p.accountManager.GetAccountCountByProvider(providerName) + 1; count > maxAttempts {
-		maxAttempts = count
-	}
-
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		// Pick next available account using round-robin selection
-		acc := p.accountManager.PickNextByProvider(providerName, req.Model)
-
-		// Handle all accounts rate-limited
-		if acc == nil && p.accountManager.IsAllRateLimitedByProvider(providerName, req.Model) {
-			allWaitMs := p.accountManager.GetMinWaitTimeMsByProvider(providerName, req.Model)
-			waitDur := time.Duration(allWaitMs) * time.Millisecond
-			resetTime := time.Now().Add(waitDur).UTC().Format("2006-01-02T15:04:05.000Z")
-
-			if waitDur > config.MaxWaitBeforeError {
-				return nil, fmt.Errorf(
-					"RESOURCE_EXHAUSTED: Rate limited on %s. Quota will reset after %s. Next available: %s",
-					req.Model,
-					utils.FormatDuration(waitDur),
-					resetTime,
-				)
-			}
-
-			accountCount := p.accountManager.GetAccountCountByProvider(providerName)
-			utils.Warn("[Z.AI] All %d account(s) rate-limited. Waiting %s...",
-				accountCount,
-				utils.FormatDuration(waitDur),
-			)
-
-			if err := sleepWithContext(ctx, waitDur); err != nil {
-				return nil, err
-			}
-			if err := sleepWithContext(ctx, config.PostRateLimitBuffer); err != nil {
-				return nil, err
-			}
-			p.accountManager.ResetAllRateLimitsByProvider(providerName)
-			acc = p.accountManager.PickNextByProvider(providerName, req.Model)
-		}
-
-		if acc == nil {
-			return nil, fmt.Errorf("no Z.AI accounts available")
-		}
-
-		// Get API key
-		apiKey := acc.APIKey
-		if apiKey == "" {
-			utils.Warn("[Z.AI] Account %s has no API key, trying next...", acc.Email)
-			continue
+	req = convertRequest(req)
+	var resp *types.AnthropicResponse
+	err := p.rotation.Run(ctx, req.Model, func(at *provider.Attempt) error {
+		acc := at.Account
+		if acc.APIKey == "" {
+			return provider.ErrNoAPIKey
 		}
-
-		// Send request
-		resp, err := p.client.SendMessage(ctx, apiKey, req)
+		at.Begin(ctx)
+		r, err := p.client.SendMessage(acc.RequestContext(ctx), acc.APIKey, req)
 		if err != nil {
-			// Rate limited - mark and continue
-			var rateLimitErr *RateLimitError
-			if errors.As(err, &rateLimitErr) {
-				p.accountManager.MarkRateLimited(acc.Email, rateLimitErr.ResetMs, req.Model)
-				utils.Info("[Z.AI] Account %s rate-limited, trying next...", acc.Email)
-				continue
-			}
-
-			// Auth error - mark invalid
-			var httpErr *HTTPStatusError
-			if errors.As(err, &httpErr) {
-				if httpErr.StatusCode == 401 || httpErr.StatusCode == 403 {
-					p.accountManager.MarkInvalid(acc.Email, "invalid API key")
-					utils.Warn("[Z.AI] Account %s has invalid API key, trying next...", acc.Email)
-					continue
-				}
-
-				// 5xx errors - try next account
-				if httpErr.StatusCode >= 500 {
-					utils.Warn("[Z.AI] Account %s failed with %d error, trying next...", acc.Email, httpErr.StatusCode)
-					continue
-				}
-			}
-
-			return nil, err
+			return err
 		}
-
-		return resp, nil
+		at.End(nil, len(r.Content) == 0)
+		resp = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, fmt.Errorf("max retries exceeded")
+	return convertResponse(resp), nil
 }
 
 // SendMessageStream handles streaming requests.
 func (p *Provider) SendMessageStream(ctx context.Context, req *types.AnthropicRequest) (<-chan types.StreamEvent, error) {
-	maxAttempts := config.MaxRetries
-	if count := p.accountManager.GetAccountCountByProvider(providerName) + 1; count > maxAttempts {
-		maxAttempts = count
-	}
-
-	for attempt := 0; attempt < maxAttempts; attempt++ {
-		// Pick next available account using round-robin selection
-		acc := p.accountManager.PickNextByProvider(providerName, req.Model)
-
-		// Handle all accounts rate-limited
-		if acc == nil && p.accountManager.IsAllRateLimitedByProvider(providerName, req.Model) {
-			allWaitMs := p.accountManager.GetMinWaitTimeMsByProvider(providerName, req.Model)
-			waitDur := time.Duration(allWaitMs) * time.Millisecond
-			resetTime := time.Now().Add(waitDur).UTC().Format("2006-01-02T15:04:05.000Z")
-
-			if waitDur > config.MaxWaitBeforeError {
-				return nil, fmt.Errorf(
-					"RESOURCE_EXHAUSTED: Rate limited on %s. Quota will reset after %s. Next available: %s",
-					req.Model,
-					utils.FormatDuration(waitDur),
-					resetTime,
-				)
-			}
-
-			accountCount := p.accountManager.GetAccountCountByProvider(providerName)
-			utils.Warn("[Z.AI] All %d account(s) rate-limited. Waiting %s...",
-				accountCount,
-				utils.FormatDuration(waitDur),
-			)
-
-			if err := sleepWithContext(ctx, waitDur); err != nil {
-				return nil, err
-			}
-			if err := sleepWithContext(ctx, config.PostRateLimitBuffer); err != nil {
-				return nil, err
-			}
-			p.accountManager.ResetAllRateLimitsByProvider(providerName)
-			acc = p.accountManager.PickNextByProvider(providerName, req.Model)
-		}
-
-		if acc == nil {
-			return nil, fmt.Errorf("no Z.AI accounts available")
-		}
-
-		// Get API key
-		apiKey := acc.APIKey
-		if apiKey == "" {
-			utils.Warn("[Z.AI] Account %s has no API key, trying next...", acc.Email)
-			continue
+	req = convertRequest(req)
+	var stream <-chan types.StreamEvent
+	err := p.rotation.Run(ctx, req.Model, func(at *provider.Attempt) error {
+		acc := at.Account
+		if acc.APIKey == "" {
+			return provider.ErrNoAPIKey
 		}
-
-		// Send streaming request
-		reader, err := p.client.SendMessageStream(ctx, apiKey, req)
+		at.Begin(ctx)
+		reader, err := p.client.SendMessageStream(acc.RequestContext(ctx), acc.APIKey, req)
 		if err != nil {
-			// Rate limited - mark and continue
-			var rateLimitErr *RateLimitError
-			if errors.As(err, &rateLimitErr) {
-				p.accountManager.MarkRateLimited(acc.Email, rateLimitErr.ResetMs, req.Model)
-				utils.Info("[Z.AI] Account %s rate-limited, trying next...", acc.Email)
-				continue
-			}
-
-			// Auth error - mark invalid
-			var httpErr *HTTPStatusError
-			if errors.As(err, &httpErr) {
-				if httpErr.StatusCode == 401 || httpErr.StatusCode == 403 {
-					p.accountManager.MarkInvalid(acc.Email, "invalid API key")
-					utils.Warn("[Z.AI] Account %s has invalid API key, trying next...", acc.Email)
-					continue
-				}
-
-				// 5xx errors - try next account
-				if httpErr.StatusCode >= 500 {
-					utils.Warn("[Z.AI] Account %s failed with %d error, trying next...", acc.Email, httpErr.StatusCode)
-					continue
-				}
-			}
-
-			return nil, err
+			return err
 		}
 
 		// Parse SSE stream
-		parser := NewStreamingParser(reader)
-		events, done := parser.StreamEvents()
-
-		// Create output channel
-		outCh := make(chan types.StreamEvent, 100)
-
-		go func() {
-			defer close(outCh)
-
-			for evt := range events {
-				select {
-				case outCh <- evt:
-				case <-ctx.Done():
-					return
-				}
-			}
-
-			// Wait for parser to finish and log any error.
-			if err := <-done; err != nil {
-				utils.Error("[Z.AI] SSE stream parsing error: %v", err)
-				// Emit an error event to the caller so they're aware of truncation.
-				select {
-				case outCh <- types.StreamEvent{
-					Type: "error",
-					Raw: map[string]interface{}{
-						"type": "error",
-						"error": map[string]interface{}{
-							"type":    "stream_error",
-							"message": err.Error(),
-						},
-					},
-				}:
-				case <-ctx.Done():
-				}
-			}
-		}()
+		events, done := provider.NewSSEParser(reader).StreamEvents()
+		relay := provider.StreamRelay{
+			Name:       p.rotation.Name,
+			Tracker:    account.NewStreamTracker(acc.Email, providerName, req.Model, at.Start()),
+			Report:     p.accountManager.ReportResult,
+			Translator: newStreamTranslator(),
+		}
+		at.Detach()
+		stream = relay.Start(ctx, events, done)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
 
-		return outCh, nil
+// classifyError describes a Z.AI API error for account selection.
+func classifyError(err error) provider.Failure {
+	var rateLimitErr *RateLimitError
+	var httpErr *HTTPStatusError
+	switch {
+	case errors.As(err, &rateLimitErr):
+		return provider.Failure{StatusCode: 429, RateLimited: true, ResetMs: rateLimitErr.ResetMs}
+	case errors.As(err, &httpErr):
+		return provider.Failure{StatusCode: httpErr.StatusCode}
 	}
+	return provider.Failure{}
+}
 
-	return nil, fmt.Errorf("max retries exceeded")
+// rejectAccount marks an account invalid when Z.AI rejects its key.
+func (p *Provider) rejectAccount(acc *account.Account, status int) bool {
+	if status != 401 && status != 403 {
+		return false
+	}
+	p.accountManager.MarkInvalid(acc.Email, "invalid API key")
+	utils.Warn("[Z.AI] Account %s has invalid API key, trying next...", acc.Email)
+	return true
 }
 
 // ListModels returns available models with metadata.
@@ -379,7 +279,7 @@ func (p *Provider) GetStatus(ctx context.Context) (*types.ProviderStatus, error)
 		}
 
 		// Fetch quota info
-		quotaInfo, err := p.client.FetchQuota(ctx, acc.APIKey)
+		quotaInfo, err := p.client.FetchQuota(acc.RequestContext(ctx), acc.APIKey)
 		if err != nil {
 			utils.Warn("[Z.AI] Failed to fetch quota for %s: %v", acc.Email, err)
 			// Mark status as degraded since we can't fetch actual quota.
@@ -432,15 +332,3 @@ func (p *Provider) GetStatus(ctx context.Context) (*types.ProviderStatus, error)
 func (p *Provider) GenerateImage(ctx context.Context, req *types.ImageGenerationRequest) (*types.ImageGenerationResponse, error) {
 	return nil, fmt.Errorf("image generation is not supported by Z.AI provider")
 }
-
-func sleepWithContext(ctx context.Context, d time.Duration) error {
-	if d <= 0 {
-		return nil
-	}
-	select {
-	case <-time.After(d):
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
-	}
-}
@@ -3,13 +3,16 @@ package zai
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/account"
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
 
@@ -144,6 +147,67 @@ func TestProvider_SendMessage_Failover(t *testing.T) {
 	}
 }
 
+func TestProvider_SendMessage_MaxAttempts(t *testing.T) {
+	tests := []struct {
+		name         string
+		accounts     int
+		policy       config.RetryPolicy
+		wantCalls    int
+		wantAccounts int
+	}{
+		// By default every account of the pool gets a try, however large it is.
+		{name: "defaults", accounts: 6, policy: config.DefaultRetryPolicy(), wantCalls: 7, wantAccounts: 6},
+		// An explicit RETRY_MAX_ATTEMPTS caps the failover.
+		{name: "capped", accounts: 7, policy: config.RetryPolicy{MaxAttempts: 3, Capped: true}, wantCalls: 3, wantAccounts: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			keys := map[string]bool{}
+			callCount := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				callCount++
+				keys[r.Header.Get("x-api-key")] = true
+				mu.Unlock()
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"error": {"type": "overloaded_error", "message": "overloaded"}}`))
+			}))
+			defer server.Close()
+
+			var accounts []account.Account
+			for i := 0; i < tt.accounts; i++ {
+				accounts = append(accounts, account.Account{
+					Email:    fmt.Sprintf("acc%d@example.com", i),
+					Provider: "zai",
+					Source:   "manual",
+					APIKey:   fmt.Sprintf("key%d", i),
+				})
+			}
+			mgr := setupTestAccountManager(t, accounts)
+
+			p := NewProvider(mgr)
+			p.client.baseURL = server.URL
+			p.client.modelsPath = ""
+			p.rotation.Policy = tt.policy
+
+			req := &types.AnthropicRequest{
+				Model: "zai/model-1",
+				Messages: []types.Message{
+					{Role: "user", Content: json.RawMessage(`"Hi"`)},
+				},
+			}
+
+			if _, err := p.SendMessage(context.Background(), req); err == nil {
+				t.Fatal("expected an error")
+			}
+			if callCount != tt.wantCalls || len(keys) != tt.wantAccounts {
+				t.Errorf("expected %d calls with %d accounts, got %d with %d", tt.wantCalls, tt.wantAccounts, callCount, len(keys))
+			}
+		})
+	}
+}
+
 func TestProvider_GetStatus(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := QuotaResponse{
@@ -1,107 +1,141 @@
 package zai
 
 import (
-	"bufio"
 	"encoding/json"
-	"io"
-	"strings"
 
 	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
 	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
 )
 
-// StreamingParser parses SSE events from the Z.AI API.
-// Z.AI uses Anthropic-compatible SSE format.
-type StreamingParser struct {
-	reader io.ReadCloser
+// streamTranslator fixes up Z.AI's Anthropic-compatible stream for Anthropic clients:
+// content blocks get sequential indexes and are closed before the next block starts,
+// tool_use input sent with the block start moves into an input_json_delta (clients build
+// the input from deltas), stop_reason is tool_use when the model called a tool, message_start
+// and message_delta always carry usage, and a finished message always gets its message_stop.
+// Events are the raw maps from provider.SSEParser; other events pass through unchanged.
+type streamTranslator struct {
+	indexes      map[int]int  // upstream index -> client index
+	open         map[int]bool // client indexes of open blocks
+	next         int
+	usedTool     bool
+	messageDelta bool
+	stopped      bool
 }
 
-// NewStreamingParser creates a new SSE parser.
-func NewStreamingParser(reader io.ReadCloser) *StreamingParser {
-	return &StreamingParser{reader: reader}
+func newStreamTranslator() *streamTranslator {
+	return &streamTranslator{indexes: make(map[int]int), open: make(map[int]bool)}
 }
 
-// StreamEvents parses SSE events and returns them on a channel.
-// Returns two channels: events and a done channel that receives any error.
-func (p *StreamingParser) StreamEvents() (<-chan types.StreamEvent, <-chan error) {
-	events := make(chan types.StreamEvent, 100)
-	done := make(chan error, 1)
-
-	go func() {
-		defer close(events)
-		defer close(done)
-		defer p.reader.Close()
-
-		scanner := bufio.NewScanner(p.reader)
-		// Increase buffer size for large events
-		buf := make([]byte, 0, 64*1024)
-		scanner.Buffer(buf, 1024*1024) // 1MB max
-
-		var currentEvent string
-		var currentData strings.Builder
-
-		for scanner.Scan() {
-			line := scanner.Text()
-
-			if line == "" {
-				// Empty line signals end of event
-				if currentEvent != "" && currentData.Len() > 0 {
-					evt := p.parseEvent(currentEvent, currentData.String())
-					if evt != nil {
-						events <- *evt
-					}
-				}
-				currentEvent = ""
-				currentData.Reset()
-				continue
-			}
+// Translate returns the events to send for an upstream event.
+func (t *streamTranslator) Translate(evt types.StreamEvent) []types.StreamEvent {
+	data, _ := evt.Raw.(map[string]interface{})
+	if data == nil {
+		return []types.StreamEvent{evt}
+	}
 
-			if strings.HasPrefix(line, "event:") {
-				currentEvent = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
-			} else if strings.HasPrefix(line, "data:") {
-				data := strings.TrimPrefix(line, "data:")
-				data = strings.TrimSpace(data)
-				if currentData.Len() > 0 {
-					currentData.WriteString("\n")
-				}
-				currentData.WriteString(data)
+	switch evt.Type {
+	case "message_start":
+		if msg, ok := data["message"].(map[string]interface{}); ok {
+			if _, ok := msg["content"].([]interface{}); !ok {
+				msg["content"] = []interface{}{}
 			}
+			ensureUsage(msg, "input_tokens", "output_tokens")
 		}
 
-		// Handle any remaining event
-		if currentEvent != "" && currentData.Len() > 0 {
-			evt := p.parseEvent(currentEvent, currentData.String())
-			if evt != nil {
-				events <- *evt
+	case "content_block_start":
+		events := t.closeBlocks()
+		index := t.next
+		t.next++
+		t.indexes[intField(data, "index")] = index
+		t.open[index] = true
+		data["index"] = index
+		events = append(events, evt)
+
+		block, _ := data["content_block"].(map[string]interface{})
+		if block["type"] == "tool_use" {
+			t.usedTool = true
+			input, _ := block["input"].(map[string]interface{})
+			block["input"] = map[string]interface{}{}
+			if len(input) > 0 {
+				partial, _ := json.Marshal(input)
+				events = append(events, rawEvent("content_block_delta", map[string]interface{}{
+					"index": index,
+					"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": string(partial)},
+				}))
 			}
 		}
+		return events
 
-		if err := scanner.Err(); err != nil {
-			utils.Debug("[Z.AI SSE] Scanner error: %v", err)
-			done <- err
-			return
+	case "content_block_delta", "content_block_stop":
+		index, ok := t.indexes[intField(data, "index")]
+		if !ok || !t.open[index] {
+			utils.Debug("[Z.AI SSE] Dropping %s for a block that isn't open", evt.Type)
+			return nil
+		}
+		data["index"] = index
+		if evt.Type == "content_block_stop" {
+			delete(t.open, index)
 		}
 
-		done <- nil
-	}()
+	case "message_delta":
+		events := t.closeBlocks()
+		t.messageDelta = true
+		if delta, ok := data["delta"].(map[string]interface{}); ok && t.usedTool && delta["stop_reason"] == "end_turn" {
+			delta["stop_reason"] = "tool_use"
+		}
+		ensureUsage(data, "output_tokens")
+		return append(events, evt)
 
-	return events, done
+	case "message_stop":
+		t.stopped = true
+	}
+	return []types.StreamEvent{evt}
 }
 
-// parseEvent parses a single SSE event.
-func (p *StreamingParser) parseEvent(eventType, data string) *types.StreamEvent {
-	if data == "" || data == "[DONE]" {
+// Finish returns the events that complete a stream that ended without error: message_stop,
+// if the upstream sent the final message_delta but not message_stop. A stream that ends
+// before message_delta was cut short and is left for the caller to report.
+func (t *streamTranslator) Finish() []types.StreamEvent {
+	if !t.messageDelta || t.stopped {
 		return nil
 	}
+	t.stopped = true
+	return []types.StreamEvent{rawEvent("message_stop", map[string]interface{}{})}
+}
 
-	var rawData map[string]interface{}
-	if err := json.Unmarshal([]byte(data), &rawData); err != nil {
-		utils.Debug("[Z.AI SSE] Failed to parse event data: %v", err)
-		return nil
+// closeBlocks returns content_block_stop events for the open blocks.
+func (t *streamTranslator) closeBlocks() []types.StreamEvent {
+	var events []types.StreamEvent
+	for index := range t.next {
+		if t.open[index] {
+			delete(t.open, index)
+			events = append(events, rawEvent("content_block_stop", map[string]interface{}{"index": index}))
+		}
 	}
+	return events
+}
 
-	return &types.StreamEvent{
-		Type: eventType,
-		Raw:  rawData,
+// ensureUsage sets the given usage fields of m to 0 when they're missing.
+func ensureUsage(m map[string]interface{}, fields ...string) {
+	usage, ok := m["usage"].(map[string]interface{})
+	if !ok {
+		usage = map[string]interface{}{}
+		m["usage"] = usage
+	}
+	for _, field := range fields {
+		if _, ok := usage[field].(float64); !ok {
+			usage[field] = 0
+		}
 	}
 }
+
+func rawEvent(eventType string, data map[string]interface{}) types.StreamEvent {
+	data["type"] = eventType
+	return types.StreamEvent{Type: eventType, Raw: data}
+}
+
+// intField returns a JSON number field as an int.
+func intField(m map[string]interface{}, key string) int {
+	f, _ := m[key].(float64)
+	return int(f)
+}
@@ -1,103 +1,130 @@
 package zai
 
 import (
-	"bytes"
+	"encoding/json"
 	"io"
+	"strings"
 	"testing"
-)
-
-func TestStreamingParser(t *testing.T) {
-	t.Run("parse simple events", func(t *testing.T) {
-		input := "event: message_start\ndata: {\"type\": \"message_start\", \"message\": {\"id\": \"msg_123\"}}\n\n" +
-			"event: content_block_delta\ndata: {\"type\": \"content_block_delta\", \"delta\": {\"type\": \"text_delta\", \"text\": \"Hello\"}}\n\n" +
-			"event: message_stop\ndata: {\"type\": \"message_stop\"}\n\n"
-
-		reader := io.NopCloser(bytes.NewReader([]byte(input)))
-		parser := NewStreamingParser(reader)
-		events, done := parser.StreamEvents()
-
-		// Receive message_start
-		evt := <-events
-		if evt.Type != "message_start" {
-			t.Errorf("expected event type message_start, got %s", evt.Type)
-		}
-		raw := evt.Raw.(map[string]interface{})
-		if raw["type"] != "message_start" {
-			t.Errorf("expected raw type message_start, got %v", raw["type"])
-		}
 
-		// Receive content_block_delta
-		evt = <-events
-		if evt.Type != "content_block_delta" {
-			t.Errorf("expected event type content_block_delta, got %s", evt.Type)
-		}
-		raw = evt.Raw.(map[string]interface{})
-		if raw["type"] != "content_block_delta" {
-			t.Errorf("expected raw type content_block_delta, got %v", raw["type"])
-		}
-		delta := raw["delta"].(map[string]interface{})
-		if delta["text"] != "Hello" {
-			t.Errorf("expected text Hello, got %v", delta["text"])
-		}
-
-		// Receive message_stop
-		evt = <-events
-		if evt.Type != "message_stop" {
-			t.Errorf("expected event type message_stop, got %s", evt.Type)
-		}
-
-		err := <-done
-		if err != nil {
-			t.Errorf("unexpected error: %v", err)
-		}
-
-		// Channel should be closed
-		_, ok := <-events
-		if ok {
-			t.Error("expected events channel to be closed")
-		}
-	})
-
-	t.Run("handles multiline data", func(t *testing.T) {
-		input := "event: message_start\n" +
-			"data: {\"type\": \"message_start\",\n" +
-			"data:  \"message\": {\"id\": \"msg_123\"}}\n\n"
-
-		reader := io.NopCloser(bytes.NewReader([]byte(input)))
-		parser := NewStreamingParser(reader)
-		events, _ := parser.StreamEvents()
-
-		evt := <-events
-		if evt.Type != "message_start" {
-			t.Errorf("expected event type message_start, got %s", evt.Type)
-		}
-		raw := evt.Raw.(map[string]interface{})
-		msg := raw["message"].(map[string]interface{})
-		if msg["id"] != "msg_123" {
-			t.Errorf("expected id msg_123, got %v", msg["id"])
-		}
-	})
-
-	t.Run("handles [DONE] marker", func(t *testing.T) {
-		input := "event: message_stop\ndata: {\"type\": \"message_stop\"}\n\ndata: [DONE]\n\n"
-
-		reader := io.NopCloser(bytes.NewReader([]byte(input)))
-		parser := NewStreamingParser(reader)
-		events, done := parser.StreamEvents()
-
-		evt := <-events
-		if evt.Type != "message_stop" {
-			t.Errorf("expected event type message_stop, got %s", evt.Type)
-		}
+	"github.com/kuzerno1/multi-claude-proxy/internal/provider"
+	"github.com/kuzerno1/multi-claude-proxy/internal/streamcheck"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
 
-		err := <-done
-		if err != nil {
-			t.Errorf("unexpected error: %v", err)
-		}
+// translateSSE runs sseData through the parser and translator like SendMessageStream.
+func translateSSE(t *testing.T, sseData string) []types.StreamEvent {
+	t.Helper()
+	events, done := provider.NewSSEParser(io.NopCloser(strings.NewReader(sseData))).StreamEvents()
+	translator := newStreamTranslator()
+	var out []types.StreamEvent
+	for evt := range events {
+		out = append(out, translator.Translate(evt)...)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	return append(out, translator.Finish()...)
+}
 
-		_, ok := <-events
-		if ok {
-			t.Error("expected events channel to be closed")
+func TestStreamTranslator(t *testing.T) {
+	const start = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"glm-4.7\"}}\n\n"
+	event := func(name, data string) string { return "event: " + name + "\ndata: " + data + "\n\n" }
+
+	tests := []struct {
+		name        string
+		sseData     string
+		wantTypes   string
+		wantStop    string // stop_reason of message_delta
+		wantPartial string // partial_json of the first input_json_delta
+	}{
+		{
+			name: "thinking and text",
+			sseData: start +
+				event("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`) +
+				event("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`) +
+				event("content_block_stop", `{"type":"content_block_stop","index":0}`) +
+				event("content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`) +
+				event("content_block_delta", `{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"hi"}}`) +
+				event("content_block_stop", `{"type":"content_block_stop","index":1}`) +
+				event("message_delta", `{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"input_tokens":12,"output_tokens":5}}`) +
+				event("message_stop", `{"type":"message_stop"}`),
+			wantTypes: "message_start content_block_start content_block_delta content_block_stop content_block_start content_block_delta content_block_stop message_delta message_stop",
+			wantStop:  "end_turn",
+		},
+		{
+			name: "tool input in the block start, unclosed block and end_turn",
+			sseData: start +
+				event("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`) +
+				event("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"reading"}}`) +
+				event("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"read_file","input":{"path":"a.txt"}}}`) +
+				event("content_block_stop", `{"type":"content_block_stop","index":0}`) +
+				event("message_delta", `{"type":"message_delta","delta":{"stop_reason":"end_turn"}}`),
+			wantTypes:   "message_start content_block_start content_block_delta content_block_stop content_block_start content_block_delta content_block_stop message_delta message_stop",
+			wantStop:    "tool_use",
+			wantPartial: `{"path":"a.txt"}`,
+		},
+		{
+			name: "streamed tool input",
+			sseData: start +
+				event("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"read_file","input":{}}}`) +
+				event("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`) +
+				event("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"a.txt\"}"}}`) +
+				event("content_block_stop", `{"type":"content_block_stop","index":0}`) +
+				event("message_delta", `{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`) +
+				event("message_stop", `{"type":"message_stop"}`),
+			wantTypes:   "message_start content_block_start content_block_delta content_block_delta content_block_stop message_delta message_stop",
+			wantStop:    "tool_use",
+			wantPartial: `{"path":`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := translateSSE(t, tt.sseData)
+
+			serialized, err := streamcheck.FromStreamEvents(events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := streamcheck.Check(serialized); err != nil {
+				t.Errorf("stream violates the protocol:\n%v", err)
+			}
+			var (
+				names   []string
+				stop    string
+				partial string
+			)
+			for _, ev := range serialized {
+				names = append(names, ev.Type)
+				var data struct {
+					Delta struct {
+						StopReason  string `json:"stop_reason"`
+						PartialJSON string `json:"partial_json"`
+					} `json:"delta"`
+				}
+				json.Unmarshal(ev.Data, &data)
+				if ev.Type == "message_delta" {
+					stop = data.Delta.StopReason
+				}
+				if partial == "" && data.Delta.PartialJSON != "" {
+					partial = data.Delta.PartialJSON
+				}
+			}
+			if got := strings.Join(names, " "); got != tt.wantTypes {
+				t.Errorf("events = %s\nwant     %s", got, tt.wantTypes)
+			}
+			if stop != tt.wantStop {
+				t.Errorf("stop_reason = %s, want %s", stop, tt.wantStop)
+			}
+			if partial != tt.wantPartial {
+				t.Errorf("partial_json = %s, want %s", partial, tt.wantPartial)
+			}
+		})
+	}
+
+	t.Run("truncated stream gets no message_stop", func(t *testing.T) {
+		events := translateSSE(t, start+event("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`))
+		if last := events[len(events)-1]; last.Type == "message_stop" {
+			t.Error("expected the truncation to be left to the caller")
 		}
 	})
 }
@@ -0,0 +1,52 @@
+package quota
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// webhookTimeout bounds a single alert delivery.
+const webhookTimeout = 10 * time.Second
+
+// Notifier returns an alert handler that logs every alert and, when webhookURL is set,
+// POSTs it there as JSON in the background.
+func Notifier(webhookURL string) func(Alert) {
+	client := &http.Client{Timeout: webhookTimeout}
+	return func(a Alert) {
+		msg := fmt.Sprintf("[Quota] %s %s at %.0f%% remaining (threshold %.0f%%)", a.Email, a.Model, a.Remaining*100, a.Threshold*100)
+		if a.ExhaustsAt != nil {
+			msg += ", exhausted around " + a.ExhaustsAt.UTC().Format(time.RFC3339)
+		}
+		utils.Warn("%s", msg)
+
+		if webhookURL == "" {
+			return
+		}
+		go func() {
+			if err := postAlert(client, webhookURL, a); err != nil {
+				utils.Warn("[Quota] Failed to deliver alert webhook: %v", err)
+			}
+		}()
+	}
+}
+
+func postAlert(client *http.Client, url string, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package quota
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/metrics"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// Discrepancy kinds.
+const (
+	// UntrackedUsage: the quota dropped more than the tokens the proxy sent explain, e.g.
+	// because the account is also used outside the proxy.
+	UntrackedUsage = "untracked_usage"
+	// UnreflectedUsage: the proxy sent more tokens than the quota change reflects, which
+	// points at a token accounting bug.
+	UnreflectedUsage = "unreflected_usage"
+)
+
+// Discrepancy is an interval between two quota samples in which the quota change and the
+// tokens recorded for the account/model disagree.
+type Discrepancy struct {
+	Email          string    `json:"email"`
+	Model          string    `json:"model"`
+	Kind           string    `json:"kind"`
+	QuotaUsed      float64   `json:"quotaUsed"`                // Drop in the remaining fraction
+	LocalTokens    int64     `json:"localTokens"`              // Tokens recorded in the interval
+	ExpectedTokens int64     `json:"expectedTokens,omitempty"` // Tokens the drop implies; 0 until learned
+	At             time.Time `json:"timestamp"`
+}
+
+// RecordUsage adds tokens sent through an account/model, to be reconciled with the quota
+// change at its next sample. Usage before the first sample is ignored.
+func (t *Tracker) RecordUsage(email, model string, tokens int) {
+	if t == nil || tokens <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h := t.series[seriesKey{email: email, model: model}]; h != nil && len(h.samples) > 0 {
+		h.pendingTokens += int64(tokens)
+	}
+}
+
+// Discrepancy returns the latest flagged interval of an account/model, if it is within the
+// history window.
+func (t *Tracker) Discrepancy(email, model string, now time.Time) (Discrepancy, bool) {
+	if t == nil {
+		return Discrepancy{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.series[seriesKey{email: email, model: model}]
+	if h == nil || h.discrepancy == nil || h.discrepancy.At.Before(now.Add(-t.window)) {
+		return Discrepancy{}, false
+	}
+	return *h.discrepancy, true
+}
+
+// reconcileLocked compares the quota drop since the previous sample with the tokens recorded
+// in between. The model's tokens per full quota is learned from intervals that agree; until
+// then only a drop without any recorded tokens is flagged.
+func (t *Tracker) reconcileLocked(key seriesKey, h *history, drop float64, now time.Time) *Discrepancy {
+	tokens := h.pendingTokens
+	h.pendingTokens = 0
+	if t.tolerance < 1 {
+		return nil
+	}
+	drop = max(drop, 0)
+	capacity := t.capacity[key.model]
+	expected := drop * capacity
+
+	var kind string
+	switch {
+	case drop >= config.QuotaReconcileMinDrop && tokens == 0:
+		kind = UntrackedUsage
+	case capacity > 0 && drop >= config.QuotaReconcileMinDrop && float64(tokens)*t.tolerance < expected:
+		kind = UntrackedUsage
+	case capacity > 0 && float64(tokens) > t.tolerance*max(drop, config.QuotaReconcileMinDrop)*capacity:
+		kind = UnreflectedUsage
+	}
+	if kind == "" {
+		if drop >= config.QuotaReconcileMinDrop {
+			observed := float64(tokens) / drop
+			if capacity == 0 {
+				t.capacity[key.model] = observed
+			} else {
+				t.capacity[key.model] = capacity*(1-config.QuotaCapacitySmoothing) + observed*config.QuotaCapacitySmoothing
+			}
+		}
+		return nil
+	}
+
+	h.discrepancy = &Discrepancy{
+		Email:          key.email,
+		Model:          key.model,
+		Kind:           kind,
+		QuotaUsed:      drop,
+		LocalTokens:    tokens,
+		ExpectedTokens: int64(expected),
+		At:             now,
+	}
+	return h.discrepancy
+}
+
+// reportDiscrepancy logs a flagged interval and counts it in the metrics.
+func reportDiscrepancy(d Discrepancy) {
+	providerName, model, ok := strings.Cut(d.Model, "/")
+	if !ok {
+		providerName, model = "", d.Model
+	}
+	metrics.QuotaUsageDiscrepancies.Inc(providerName, model)
+	utils.Warn("[Quota] %s %s: %s (quota used %.0f%%, %d tokens sent, %d expected)",
+		d.Email, d.Model, d.Kind, d.QuotaUsed*100, d.LocalTokens, d.ExpectedTokens)
+}
@@ -0,0 +1,206 @@
+// Package quota records remaining-quota samples per account and model, estimates when
+// quotas run out, raises alerts when they drop below configured thresholds, and reconciles
+// quota changes with the tokens the proxy sent.
+package quota
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// resetEpsilon is how much the remaining fraction must rise before it counts as a quota reset.
+const resetEpsilon = 0.01
+
+// Sample is a remaining-quota observation.
+type Sample struct {
+	At        time.Time
+	Remaining float64 // 0-1
+}
+
+// Prediction is the estimated burn rate and exhaustion time for an account/model.
+type Prediction struct {
+	Remaining       float64
+	BurnRatePerHour float64    // Fraction of the quota used per hour; 0 when not decreasing
+	ExhaustsAt      *time.Time // nil when the quota is not decreasing
+}
+
+// Alert is raised when an account/model drops to or below a threshold.
+type Alert struct {
+	Email      string     `json:"email"`
+	Model      string     `json:"model"`
+	Remaining  float64    `json:"remainingFraction"`
+	Threshold  float64    `json:"threshold"`
+	ExhaustsAt *time.Time `json:"exhaustsAt,omitempty"`
+	At         time.Time  `json:"timestamp"`
+}
+
+type seriesKey struct {
+	email string
+	model string
+}
+
+type history struct {
+	samples []Sample
+	alerted map[float64]bool // thresholds already alerted since the quota was last above them
+
+	pendingTokens int64        // Tokens recorded since the last sample
+	discrepancy   *Discrepancy // Latest flagged interval
+}
+
+// Tracker keeps recent quota samples. It is safe for concurrent use; a nil Tracker ignores calls.
+type Tracker struct {
+	window     time.Duration
+	thresholds []float64 // highest first
+	notify     func(Alert)
+	tolerance  float64
+
+	mu       sync.Mutex
+	series   map[seriesKey]*history
+	capacity map[string]float64 // model -> learned tokens per full quota
+}
+
+// NewTracker creates a tracker. notify is called (outside the tracker lock) for each alert;
+// it may be nil when cfg has no alert thresholds.
+func NewTracker(cfg config.QuotaConfig, notify func(Alert)) *Tracker {
+	window := cfg.HistoryWindow
+	if window <= 0 {
+		window = config.DefaultQuotaHistoryWindow
+	}
+	return &Tracker{
+		window:     window,
+		thresholds: cfg.AlertThresholds,
+		notify:     notify,
+		tolerance:  cfg.ReconcileTolerance,
+		series:     make(map[seriesKey]*history),
+		capacity:   make(map[string]float64),
+	}
+}
+
+// Record adds a sample for an account/model. A rise in the remaining fraction is treated as a
+// quota reset and starts a fresh history. The drop since the previous sample is reconciled
+// with the tokens recorded by RecordUsage in between.
+func (t *Tracker) Record(email, model string, remaining float64, now time.Time) {
+	if t == nil || math.IsNaN(remaining) || math.IsInf(remaining, 0) {
+		return
+	}
+	remaining = min(max(remaining, 0), 1)
+
+	t.mu.Lock()
+	key := seriesKey{email: email, model: model}
+	h := t.series[key]
+	if h == nil {
+		h = &history{alerted: make(map[float64]bool)}
+		t.series[key] = h
+	}
+	var discrepancy *Discrepancy
+	if n := len(h.samples); n > 0 && remaining > h.samples[n-1].Remaining+resetEpsilon {
+		h.samples = h.samples[:0]
+		h.pendingTokens = 0
+	} else if n > 0 {
+		discrepancy = t.reconcileLocked(key, h, h.samples[n-1].Remaining-remaining, now)
+	}
+	h.samples = append(h.samples, Sample{At: now, Remaining: remaining})
+	h.trim(now.Add(-t.window))
+
+	alert, fire := t.checkThresholdsLocked(h, remaining)
+	var prediction Prediction
+	if fire {
+		prediction = predict(h.samples)
+	}
+	t.mu.Unlock()
+
+	if discrepancy != nil {
+		reportDiscrepancy(*discrepancy)
+	}
+	if fire && t.notify != nil {
+		alert.Email, alert.Model, alert.At = email, model, now
+		alert.ExhaustsAt = prediction.ExhaustsAt
+		t.notify(alert)
+	}
+}
+
+// checkThresholdsLocked re-arms thresholds the quota is above again and returns an alert for
+// the lowest newly crossed one, so a large drop produces a single alert.
+func (t *Tracker) checkThresholdsLocked(h *history, remaining float64) (Alert, bool) {
+	var (
+		alert Alert
+		fire  bool
+	)
+	for _, threshold := range t.thresholds {
+		if remaining > threshold {
+			delete(h.alerted, threshold)
+			continue
+		}
+		if !h.alerted[threshold] {
+			h.alerted[threshold] = true
+			alert = Alert{Remaining: remaining, Threshold: threshold}
+			fire = true
+		}
+	}
+	return alert, fire
+}
+
+func (h *history) trim(cutoff time.Time) {
+	drop := 0
+	for drop < len(h.samples)-1 && h.samples[drop].At.Before(cutoff) {
+		drop++
+	}
+	if over := len(h.samples) - drop - config.QuotaMaxSamples; over > 0 {
+		drop += over
+	}
+	if drop > 0 {
+		h.samples = append(h.samples[:0], h.samples[drop:]...)
+	}
+}
+
+// Predict returns the burn rate and exhaustion estimate for an account/model.
+// ok is false when no sample has been recorded.
+func (t *Tracker) Predict(email, model string) (Prediction, bool) {
+	if t == nil {
+		return Prediction{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.series[seriesKey{email: email, model: model}]
+	if h == nil || len(h.samples) == 0 {
+		return Prediction{}, false
+	}
+	return predict(h.samples), true
+}
+
+// predict fits a least-squares line through the samples and extrapolates it to zero.
+func predict(samples []Sample) Prediction {
+	last := samples[len(samples)-1]
+	p := Prediction{Remaining: last.Remaining}
+	if len(samples) < 2 || last.Remaining <= 0 {
+		return p
+	}
+
+	origin := samples[0].At
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.At.Sub(origin).Hours()
+		sumX += x
+		sumY += s.Remaining
+		sumXY += x * s.Remaining
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return p
+	}
+	slope := (n*sumXY - sumX*sumY) / denom
+	if slope >= 0 {
+		return p
+	}
+
+	p.BurnRatePerHour = -slope
+	exhaustsAt := last.At.Add(time.Duration(last.Remaining / p.BurnRatePerHour * float64(time.Hour)))
+	p.ExhaustsAt = &exhaustsAt
+	return p
+}
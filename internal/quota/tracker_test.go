@@ -0,0 +1,170 @@
+package quota
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+var base = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestPredict(t *testing.T) {
+	tests := []struct {
+		name      string
+		samples   []float64 // one per hour
+		burn      float64
+		exhausted time.Duration // after base; 0 means no estimate
+	}{
+		{"single sample", []float64{0.8}, 0, 0},
+		{"steady burn", []float64{0.9, 0.8, 0.7}, 0.1, 9 * time.Hour},
+		{"flat", []float64{0.5, 0.5, 0.5}, 0, 0},
+		{"reset starts a fresh history", []float64{0.2, 0.1, 1.0, 0.8}, 0.2, 7 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewTracker(config.QuotaConfig{HistoryWindow: 24 * time.Hour}, nil)
+			for i, rf := range tt.samples {
+				tr.Record("a@x", "zai/glm", rf, base.Add(time.Duration(i)*time.Hour))
+			}
+
+			p, ok := tr.Predict("a@x", "zai/glm")
+			if !ok {
+				t.Fatal("expected a prediction")
+			}
+			if diff := p.BurnRatePerHour - tt.burn; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("burn rate = %v, want %v", p.BurnRatePerHour, tt.burn)
+			}
+			switch {
+			case tt.exhausted == 0 && p.ExhaustsAt != nil:
+				t.Errorf("expected no exhaustion estimate, got %v", p.ExhaustsAt)
+			case tt.exhausted != 0 && (p.ExhaustsAt == nil || p.ExhaustsAt.Sub(base.Add(tt.exhausted)).Abs() > time.Second):
+				t.Errorf("exhaustsAt = %v, want %v", p.ExhaustsAt, base.Add(tt.exhausted))
+			}
+		})
+	}
+
+	if _, ok := NewTracker(config.QuotaConfig{}, nil).Predict("a@x", "zai/glm"); ok {
+		t.Error("expected no prediction without samples")
+	}
+}
+
+func TestRecord_DropsSamplesOutsideWindow(t *testing.T) {
+	tr := NewTracker(config.QuotaConfig{HistoryWindow: 90 * time.Minute}, nil)
+	// A fast early burn followed by a slow one: only the recent, slow samples should count.
+	for i, rf := range []float64{0.9, 0.5, 0.49, 0.48} {
+		tr.Record("a@x", "m", rf, base.Add(time.Duration(i)*time.Hour))
+	}
+	p, _ := tr.Predict("a@x", "m")
+	if diff := p.BurnRatePerHour - 0.01; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("burn rate = %v, want 0.01", p.BurnRatePerHour)
+	}
+}
+
+func TestRecord_Alerts(t *testing.T) {
+	var alerts []Alert
+	tr := NewTracker(config.QuotaConfig{AlertThresholds: []float64{0.5, 0.2, 0.1}}, func(a Alert) {
+		alerts = append(alerts, a)
+	})
+
+	for i, rf := range []float64{0.8, 0.6, 0.15, 0.12, 0.6, 0.4} {
+		tr.Record("a@x", "m", rf, base.Add(time.Duration(i)*time.Hour))
+	}
+
+	// 0.15 crosses 0.5 and 0.2 in one step (one alert); 0.12 repeats nothing; 0.6 re-arms; 0.4 alerts again.
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %+v", alerts)
+	}
+	if alerts[0].Threshold != 0.2 || alerts[0].Remaining != 0.15 || alerts[0].Email != "a@x" || alerts[0].Model != "m" {
+		t.Errorf("unexpected first alert: %+v", alerts[0])
+	}
+	if alerts[0].ExhaustsAt == nil {
+		t.Error("expected first alert to carry an exhaustion estimate")
+	}
+	if alerts[1].Threshold != 0.5 || alerts[1].Remaining != 0.4 {
+		t.Errorf("unexpected second alert: %+v", alerts[1])
+	}
+}
+
+func TestRecord_ReconcilesUsage(t *testing.T) {
+	type step struct {
+		tokens    int // recorded before the sample
+		remaining float64
+	}
+	// The first intervals teach the tracker 100k tokens per full quota.
+	learn := []step{{0, 1.0}, {10000, 0.9}, {10000, 0.8}}
+
+	tests := []struct {
+		name     string
+		steps    []step
+		wantKind string // "" for no discrepancy
+	}{
+		{"consistent usage", append(learn, step{12000, 0.7}), ""},
+		{"drop without tokens", []step{{0, 1.0}, {0, 0.9}}, UntrackedUsage},
+		{"drop far beyond tokens", append(learn, step{1000, 0.5}), UntrackedUsage},
+		{"tokens without drop", append(learn, step{50000, 0.8}), UnreflectedUsage},
+		{"small drop within rounding", []step{{0, 1.0}, {0, 0.99}}, ""},
+		{"tokens before a reset are dropped", append(learn, step{50000, 1.0}, step{10000, 0.9}), ""},
+		{"usage before the first sample is ignored", []step{{50000, 1.0}, {10000, 0.9}, {10000, 0.8}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewTracker(config.QuotaConfig{ReconcileTolerance: 3}, nil)
+			var now time.Time
+			for i, st := range tt.steps {
+				tr.RecordUsage("a@x", "antigravity/m", st.tokens)
+				now = base.Add(time.Duration(i) * time.Minute)
+				tr.Record("a@x", "antigravity/m", st.remaining, now)
+			}
+
+			d, ok := tr.Discrepancy("a@x", "antigravity/m", now)
+			if tt.wantKind == "" {
+				if ok {
+					t.Errorf("expected no discrepancy, got %+v", d)
+				}
+				return
+			}
+			if !ok || d.Kind != tt.wantKind {
+				t.Fatalf("expected a %s discrepancy, got %+v (%v)", tt.wantKind, d, ok)
+			}
+			if !d.At.Equal(now) {
+				t.Errorf("expected the discrepancy at the last sample, got %v", d.At)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		tr := NewTracker(config.QuotaConfig{}, nil)
+		tr.Record("a@x", "m", 1.0, base)
+		tr.Record("a@x", "m", 0.5, base.Add(time.Minute))
+		if d, ok := tr.Discrepancy("a@x", "m", base.Add(time.Minute)); ok {
+			t.Errorf("expected no reconciliation without a tolerance, got %+v", d)
+		}
+	})
+}
+
+func TestNotifier_Webhook(t *testing.T) {
+	received := make(chan Alert, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		received <- a
+	}))
+	defer server.Close()
+
+	Notifier(server.URL)(Alert{Email: "a@x", Model: "m", Remaining: 0.1, Threshold: 0.2, At: base})
+
+	select {
+	case a := <-received:
+		if a.Email != "a@x" || a.Threshold != 0.2 {
+			t.Errorf("unexpected webhook payload: %+v", a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
@@ -0,0 +1,189 @@
+// Package session records which provider account served each conversation, so later turns
+// of a multi-turn agent are routed consistently, including across proxy restarts.
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// Entry is what served the last successful turn of a session.
+type Entry struct {
+	Provider        string    `json:"provider"`
+	Account         string    `json:"account,omitempty"` // Empty for providers without accounts
+	Model           string    `json:"model"`
+	SignatureFamily string    `json:"signatureFamily"` // Model family that signed the session's thinking blocks
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// Store is a TTL-bounded map of session IDs to entries. It is safe for concurrent use.
+type Store struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int // 0 means unbounded
+	entries    map[string]Entry
+}
+
+// NewStore creates a store whose entries expire ttl after the session's last turn.
+func NewStore(ttl time.Duration, maxEntries int) *Store {
+	return &Store{ttl: ttl, maxEntries: maxEntries, entries: make(map[string]Entry)}
+}
+
+// Get returns the entry of session id, if it hasn't expired.
+func (s *Store) Get(id string) (Entry, bool) {
+	if id == "" {
+		return Entry{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok || time.Since(e.UpdatedAt) > s.ttl {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// Put records e as the latest turn of session id, evicting the least recently updated
+// sessions beyond the entry limit.
+func (s *Store) Put(id string, e Entry) {
+	if id == "" {
+		return
+	}
+	e.UpdatedAt = time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[id] = e
+	if s.maxEntries > 0 && len(s.entries) > s.maxEntries {
+		s.evictOldestLocked(s.maxEntries)
+	}
+}
+
+// Len returns the number of stored sessions, including expired ones not yet cleaned up.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Cleanup removes expired sessions.
+func (s *Store) Cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, e := range s.entries {
+		if now.Sub(e.UpdatedAt) > s.ttl {
+			delete(s.entries, id)
+		}
+	}
+}
+
+func (s *Store) evictOldestLocked(limit int) {
+	ids := make([]string, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return s.entries[ids[i]].UpdatedAt.Before(s.entries[ids[j]].UpdatedAt) })
+	for _, id := range ids[:len(ids)-limit] {
+		delete(s.entries, id)
+	}
+}
+
+// snapshot is the on-disk format of a Store.
+type snapshot struct {
+	Version  int              `json:"version"`
+	Sessions map[string]Entry `json:"sessions"`
+}
+
+// SaveToFile writes unexpired sessions to path atomically (temp file + rename).
+func (s *Store) SaveToFile(path string) error {
+	snap := snapshot{Version: 1, Sessions: make(map[string]Entry)}
+	s.mu.Lock()
+	now := time.Now()
+	for id, e := range s.entries {
+		if now.Sub(e.UpdatedAt) <= s.ttl {
+			snap.Sessions[id] = e
+		}
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session store: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create session store directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session store: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save session store: %w", err)
+	}
+	return nil
+}
+
+// LoadFromFile merges sessions from a snapshot written by SaveToFile, skipping expired ones
+// and keeping newer entries already in the store. A missing file is not an error. Returns
+// the number of sessions loaded.
+func (s *Store) LoadFromFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read session store: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("failed to parse session store: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	loaded := 0
+	for id, e := range snap.Sessions {
+		if id == "" || now.Sub(e.UpdatedAt) > s.ttl {
+			continue
+		}
+		if existing, ok := s.entries[id]; ok && existing.UpdatedAt.After(e.UpdatedAt) {
+			continue
+		}
+		s.entries[id] = e
+		loaded++
+	}
+	if s.maxEntries > 0 && len(s.entries) > s.maxEntries {
+		s.evictOldestLocked(s.maxEntries)
+	}
+	return loaded, nil
+}
+
+// StartPersistence periodically cleans up and snapshots the store to path until stop is
+// closed. Callers should write a final snapshot with SaveToFile after closing stop.
+func (s *Store) StartPersistence(path string, interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Cleanup()
+				if err := s.SaveToFile(path); err != nil {
+					utils.Warn("[SessionStore] %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,60 @@
+package session
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_GetPut(t *testing.T) {
+	s := NewStore(time.Hour, 2)
+	s.Put("s1", Entry{Provider: "zai", Account: "a@example.com", Model: "glm-4.7"})
+	if e, ok := s.Get("s1"); !ok || e.Account != "a@example.com" || e.UpdatedAt.IsZero() {
+		t.Fatalf("Get(s1) = %+v, %v", e, ok)
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Error("expected no entry for an unknown session")
+	}
+
+	// Expired sessions are ignored.
+	s.entries["old"] = Entry{Provider: "zai", UpdatedAt: time.Now().Add(-2 * time.Hour)}
+	if _, ok := s.Get("old"); ok {
+		t.Error("expected the expired session to be ignored")
+	}
+	s.Cleanup()
+	if s.Len() != 1 {
+		t.Errorf("Len() = %d after cleanup, want 1", s.Len())
+	}
+
+	// The least recently updated session is evicted beyond the limit.
+	s.entries["s1"] = Entry{Provider: "zai", UpdatedAt: time.Now().Add(-time.Minute)}
+	s.Put("s2", Entry{Provider: "zai"})
+	s.Put("s3", Entry{Provider: "zai"})
+	if _, ok := s.Get("s1"); ok || s.Len() != 2 {
+		t.Errorf("expected s1 to be evicted, %d sessions left", s.Len())
+	}
+}
+
+func TestStore_PersistRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+
+	s := NewStore(time.Hour, 0)
+	s.Put("s1", Entry{Provider: "antigravity", Account: "a@example.com", Model: "claude-opus-4-5-thinking", SignatureFamily: "claude"})
+	s.entries["old"] = Entry{Provider: "zai", UpdatedAt: time.Now().Add(-2 * time.Hour)}
+	if err := s.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	restored := NewStore(time.Hour, 0)
+	loaded, err := restored.LoadFromFile(path)
+	if err != nil || loaded != 1 {
+		t.Fatalf("LoadFromFile() = %d, %v; want 1, nil", loaded, err)
+	}
+	if e, ok := restored.Get("s1"); !ok || e.Account != "a@example.com" || e.SignatureFamily != "claude" {
+		t.Errorf("restored session = %+v, %v", e, ok)
+	}
+
+	if loaded, err := NewStore(time.Hour, 0).LoadFromFile(filepath.Join(t.TempDir(), "missing.json")); err != nil || loaded != 0 {
+		t.Errorf("LoadFromFile(missing) = %d, %v; want 0, nil", loaded, err)
+	}
+}
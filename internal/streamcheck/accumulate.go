@@ -0,0 +1,170 @@
+package streamcheck
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// APIError is the error event that ended a stream.
+type APIError struct {
+	Type    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return e.Type + ": " + e.Message
+}
+
+// Accumulate builds the final message of a stream from the event protocol: message_start
+// gives the message, content_block_start appends a block, deltas extend the block at their
+// index, tool input JSON is parsed when its block stops and message_delta sets the stop
+// reason and usage. An error event is returned as *APIError. It is the proxy's own reading of
+// the protocol, not SDK code, and stricter than a client: a delta for a block of another type
+// or tool input that isn't JSON fails instead of building a wrong message.
+func Accumulate(events []Event) (*types.AnthropicResponse, error) {
+	var (
+		msg     *types.AnthropicResponse
+		inputs  = make(map[int]*strings.Builder) // partial_json of tool_use blocks by index
+		stopped bool
+	)
+	for i, ev := range events {
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("event %d (%s): %s", i, ev.Type, fmt.Sprintf(format, args...))
+		}
+		var data struct {
+			Type         string                   `json:"type"`
+			Message      *types.AnthropicResponse `json:"message"`
+			Index        int                      `json:"index"`
+			ContentBlock *types.ContentBlock      `json:"content_block"`
+			Delta        json.RawMessage          `json:"delta"`
+			Usage        *types.Usage             `json:"usage"`
+			Error        *types.ErrorDetail       `json:"error"`
+		}
+		if err := json.Unmarshal(ev.Data, &data); err != nil {
+			return nil, fail("invalid JSON: %v", err)
+		}
+		// Clients dispatch on the data's type, not the SSE event name.
+		if data.Type != ev.Type {
+			return nil, fail("data type %q doesn't match the event name", data.Type)
+		}
+		if stopped {
+			return nil, fail("event after message_stop")
+		}
+
+		switch data.Type {
+		case "ping":
+			continue
+		case "error":
+			if data.Error == nil {
+				return nil, fail("error object is missing")
+			}
+			return msg, &APIError{Type: data.Error.Type, Message: data.Error.Message}
+		case "message_start":
+			if data.Message == nil {
+				return nil, fail("message object is missing")
+			}
+			msg = data.Message
+			continue
+		}
+		if msg == nil {
+			return nil, fail("unexpected event order, got %s before message_start", data.Type)
+		}
+
+		switch data.Type {
+		case "content_block_start":
+			if data.ContentBlock == nil {
+				return nil, fail("content_block is missing")
+			}
+			if data.Index != len(msg.Content) {
+				return nil, fail("content block %d started, expected index %d", data.Index, len(msg.Content))
+			}
+			msg.Content = append(msg.Content, *data.ContentBlock)
+		case "content_block_delta":
+			if data.Index < 0 || data.Index >= len(msg.Content) {
+				return nil, fail("out-of-bounds index %d", data.Index)
+			}
+			if err := applyDelta(&msg.Content[data.Index], data.Delta, inputs, data.Index); err != nil {
+				return nil, fail("%v", err)
+			}
+		case "content_block_stop":
+			if data.Index < 0 || data.Index >= len(msg.Content) {
+				return nil, fail("out-of-bounds index %d", data.Index)
+			}
+			if partial, ok := inputs[data.Index]; ok && partial.Len() > 0 {
+				var input map[string]interface{}
+				if err := json.Unmarshal([]byte(partial.String()), &input); err != nil {
+					return nil, fail("tool input of block %d is not a JSON object: %v", data.Index, err)
+				}
+				msg.Content[data.Index].Input = input
+			}
+		case "message_delta":
+			var delta struct {
+				StopReason   string  `json:"stop_reason"`
+				StopSequence *string `json:"stop_sequence"`
+			}
+			if err := json.Unmarshal(data.Delta, &delta); err != nil {
+				return nil, fail("invalid delta: %v", err)
+			}
+			msg.StopReason, msg.StopSequence = delta.StopReason, delta.StopSequence
+			if data.Usage != nil {
+				msg.Usage.OutputTokens = data.Usage.OutputTokens
+				if data.Usage.InputTokens > 0 {
+					msg.Usage.InputTokens = data.Usage.InputTokens
+				}
+			}
+		case "message_stop":
+			stopped = true
+		}
+	}
+	if msg == nil {
+		return nil, errors.New("stream ended without a message")
+	}
+	if !stopped {
+		return msg, errors.New("stream ended without message_stop")
+	}
+	return msg, nil
+}
+
+// applyDelta extends block with a content_block_delta's delta.
+func applyDelta(block *types.ContentBlock, raw json.RawMessage, inputs map[int]*strings.Builder, index int) error {
+	var delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		Signature   string `json:"signature"`
+		PartialJSON string `json:"partial_json"`
+	}
+	if err := json.Unmarshal(raw, &delta); err != nil {
+		return fmt.Errorf("invalid delta: %v", err)
+	}
+	want := map[string]string{
+		"text_delta":       "text",
+		"thinking_delta":   "thinking",
+		"signature_delta":  "thinking",
+		"input_json_delta": "tool_use",
+	}[delta.Type]
+	if want == "" {
+		return nil // e.g. citations_delta; not accumulated here
+	}
+	if block.Type != want && !(want == "tool_use" && block.Type == "server_tool_use") {
+		return fmt.Errorf("%s for a %s block", delta.Type, block.Type)
+	}
+	switch delta.Type {
+	case "text_delta":
+		block.Text += delta.Text
+	case "thinking_delta":
+		block.Thinking += delta.Thinking
+	case "signature_delta":
+		block.Signature = delta.Signature
+	case "input_json_delta":
+		if inputs[index] == nil {
+			inputs[index] = &strings.Builder{}
+		}
+		inputs[index].WriteString(delta.PartialJSON)
+	}
+	return nil
+}
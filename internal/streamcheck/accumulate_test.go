@@ -0,0 +1,55 @@
+package streamcheck
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAccumulate(t *testing.T) {
+	toolStart := `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`
+	toolDelta := func(partial string) string {
+		return `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":` + partial + `}}`
+	}
+	toolStop := `{"type":"content_block_stop","index":1}`
+
+	msg, err := Accumulate(stream(messageStart, ping, textStart, textDelta, textDelta, blockStop,
+		toolStart, toolDelta(`"{\"city\": "`), toolDelta(`"\"Paris\"}"`), toolStop, messageDelta, messageStop))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(msg.Content) != 2 || msg.Content[0].Text != "hihi" {
+		t.Fatalf("unexpected content: %+v", msg.Content)
+	}
+	if want := map[string]interface{}{"city": "Paris"}; !reflect.DeepEqual(msg.Content[1].Input, want) {
+		t.Errorf("tool input = %v, want %v", msg.Content[1].Input, want)
+	}
+	if msg.StopReason != "end_turn" || msg.Usage.InputTokens != 1 || msg.Usage.OutputTokens != 1 {
+		t.Errorf("unexpected stop reason or usage: %+v", msg)
+	}
+
+	_, err = Accumulate(stream(messageStart, textStart, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Type != "overloaded_error" {
+		t.Errorf("expected an overloaded APIError, got %v", err)
+	}
+
+	for name, tt := range map[string]struct {
+		events  []Event
+		wantErr string
+	}{
+		"before message_start": {stream(textStart), "before message_start"},
+		"out-of-bounds delta":  {stream(messageStart, textDelta), "out-of-bounds index 0"},
+		"delta type mismatch":  {stream(messageStart, textStart, blockStop, toolStart, `{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"x"}}`), "text_delta for a tool_use block"},
+		"bad tool input":       {stream(messageStart, textStart, blockStop, toolStart, toolDelta(`"{\"city\""`), toolStop), "not a JSON object"},
+		"truncated":            {stream(messageStart, textStart, textDelta), "without message_stop"},
+		"empty":                {nil, "without a message"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Accumulate(tt.events); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
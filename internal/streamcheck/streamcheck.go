@@ -0,0 +1,283 @@
+// Package streamcheck checks streamed responses against Anthropic's streaming event
+// protocol: event ordering, content block indexes and the fields each event must carry.
+// The provider and API tests use it as a conformance suite for the streaming adapters.
+package streamcheck
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	merrors "github.com/kuzerno1/multi-claude-proxy/internal/errors"
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+// Event is a serialized stream event: the SSE event name and its JSON data.
+type Event struct {
+	Type string
+	Data []byte
+}
+
+// FromStreamEvents serializes provider events the way the messages handler writes them.
+func FromStreamEvents(events []types.StreamEvent) ([]Event, error) {
+	out := make([]Event, 0, len(events))
+	for _, ev := range events {
+		var payload any = ev
+		if ev.Raw != nil {
+			payload = ev.Raw
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("event %d (%s): %w", len(out), ev.Type, err)
+		}
+		out = append(out, Event{Type: ev.Type, Data: data})
+	}
+	return out, nil
+}
+
+// ParseSSE splits an SSE response body into its events.
+func ParseSSE(body string) ([]Event, error) {
+	var (
+		events []Event
+		cur    Event
+	)
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if cur.Data != nil {
+				events = append(events, cur)
+			}
+			cur = Event{}
+		case strings.HasPrefix(line, "event:"):
+			cur.Type = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			cur.Data = append(cur.Data, strings.TrimSpace(strings.TrimPrefix(line, "data:"))...)
+		}
+	}
+	if cur.Data != nil {
+		events = append(events, cur)
+	}
+	return events, scanner.Err()
+}
+
+// checker tracks the stream state while Check walks the events.
+type checker struct {
+	errs []error
+
+	started     bool
+	blocks      []string // content block types by index
+	open        map[int]bool
+	messageDone bool
+	stopped     bool
+	errored     bool
+}
+
+func (c *checker) failf(i int, ev Event, format string, args ...any) {
+	c.errs = append(c.errs, fmt.Errorf("event %d (%s): %s", i, ev.Type, fmt.Sprintf(format, args...)))
+}
+
+// Check reports every protocol violation in events. A stream must start with message_start
+// (pings aside), open and close content blocks in index order, end its message with
+// message_delta and message_stop, and may be cut short only by a final error event.
+func Check(events []Event) error {
+	c := &checker{open: make(map[int]bool)}
+	for i, ev := range events {
+		var data map[string]any
+		if err := json.Unmarshal(ev.Data, &data); err != nil {
+			c.failf(i, ev, "data is not a JSON object: %v", err)
+			continue
+		}
+		if t, _ := data["type"].(string); t != ev.Type {
+			c.failf(i, ev, "data type %q doesn't match the event name", t)
+		}
+		if c.errored || c.stopped {
+			c.failf(i, ev, "event after the end of the stream")
+			continue
+		}
+		if ev.Type != "message_start" && ev.Type != "ping" && ev.Type != "error" && !c.started {
+			c.failf(i, ev, "event before message_start")
+		}
+
+		switch ev.Type {
+		case "ping":
+			if len(data) != 1 {
+				c.failf(i, ev, "ping carries fields other than type")
+			}
+		case "error":
+			c.errored = true
+			c.checkError(i, ev, data)
+		case "message_start":
+			if c.started {
+				c.failf(i, ev, "duplicate message_start")
+			}
+			c.started = true
+			c.checkMessage(i, ev, data)
+		case "content_block_start":
+			c.checkBlockStart(i, ev, data)
+		case "content_block_delta":
+			c.checkBlockDelta(i, ev, data)
+		case "content_block_stop":
+			if index, ok := c.index(i, ev, data); ok {
+				if !c.open[index] {
+					c.failf(i, ev, "content block %d is not open", index)
+				}
+				delete(c.open, index)
+			}
+		case "message_delta":
+			if c.messageDone {
+				c.failf(i, ev, "duplicate message_delta")
+			}
+			c.messageDone = true
+			if len(c.open) > 0 {
+				c.failf(i, ev, "content blocks still open")
+			}
+			delta, _ := data["delta"].(map[string]any)
+			if _, ok := delta["stop_reason"]; !ok {
+				c.failf(i, ev, "delta.stop_reason is missing")
+			}
+			usage, _ := data["usage"].(map[string]any)
+			if _, ok := usage["output_tokens"].(float64); !ok {
+				c.failf(i, ev, "usage.output_tokens is missing")
+			}
+		case "message_stop":
+			if !c.messageDone {
+				c.failf(i, ev, "message_stop before message_delta")
+			}
+			c.stopped = true
+		default:
+			c.failf(i, ev, "unknown event type")
+		}
+	}
+	if !c.stopped && !c.errored {
+		c.errs = append(c.errs, errors.New("stream ended without message_stop or an error event"))
+	}
+	return errors.Join(c.errs...)
+}
+
+func (c *checker) checkError(i int, ev Event, data map[string]any) {
+	detail, ok := data["error"].(map[string]any)
+	if !ok {
+		c.failf(i, ev, "error object is missing")
+		return
+	}
+	errType, _ := detail["type"].(string)
+	if !merrors.ErrorType(errType).IsDocumented() {
+		c.failf(i, ev, "undocumented error type %q", errType)
+	}
+	if _, ok := detail["message"].(string); !ok {
+		c.failf(i, ev, "error.message is missing")
+	}
+}
+
+func (c *checker) checkMessage(i int, ev Event, data map[string]any) {
+	msg, ok := data["message"].(map[string]any)
+	if !ok {
+		c.failf(i, ev, "message object is missing")
+		return
+	}
+	for _, field := range []string{"id", "model"} {
+		if _, ok := msg[field].(string); !ok {
+			c.failf(i, ev, "message.%s is missing", field)
+		}
+	}
+	if msg["type"] != "message" || msg["role"] != "assistant" {
+		c.failf(i, ev, "message must have type message and role assistant, got %v/%v", msg["type"], msg["role"])
+	}
+	if _, ok := msg["content"].([]any); !ok {
+		c.failf(i, ev, "message.content is not an array")
+	}
+	usage, _ := msg["usage"].(map[string]any)
+	for _, field := range []string{"input_tokens", "output_tokens"} {
+		if _, ok := usage[field].(float64); !ok {
+			c.failf(i, ev, "message.usage.%s is missing", field)
+		}
+	}
+}
+
+func (c *checker) checkBlockStart(i int, ev Event, data map[string]any) {
+	index, ok := c.index(i, ev, data)
+	if !ok {
+		return
+	}
+	if index != len(c.blocks) {
+		c.failf(i, ev, "content block %d started, expected index %d", index, len(c.blocks))
+	}
+	if c.messageDone {
+		c.failf(i, ev, "content block started after message_delta")
+	}
+	block, _ := data["content_block"].(map[string]any)
+	blockType, _ := block["type"].(string)
+	c.blocks = append(c.blocks, blockType)
+	c.open[index] = true
+
+	var required []string
+	switch blockType {
+	case "":
+		c.failf(i, ev, "content_block.type is missing")
+	case "text":
+		required = []string{"text"}
+	case "thinking":
+		required = []string{"thinking"}
+	case "tool_use":
+		required = []string{"id", "name"}
+		if _, ok := block["input"].(map[string]any); !ok {
+			c.failf(i, ev, "content_block.input is not an object")
+		}
+	}
+	for _, field := range required {
+		if _, ok := block[field].(string); !ok {
+			c.failf(i, ev, "content_block.%s is missing", field)
+		}
+	}
+}
+
+// deltaFields maps delta types to the field carrying their payload and the block types
+// they may extend.
+var deltaFields = map[string]struct {
+	field  string
+	blocks []string
+}{
+	"text_delta":       {"text", []string{"text"}},
+	"thinking_delta":   {"thinking", []string{"thinking"}},
+	"signature_delta":  {"signature", []string{"thinking"}},
+	"input_json_delta": {"partial_json", []string{"tool_use", "server_tool_use"}},
+}
+
+func (c *checker) checkBlockDelta(i int, ev Event, data map[string]any) {
+	index, ok := c.index(i, ev, data)
+	if !ok {
+		return
+	}
+	if !c.open[index] {
+		c.failf(i, ev, "content block %d is not open", index)
+		return
+	}
+	delta, _ := data["delta"].(map[string]any)
+	deltaType, _ := delta["type"].(string)
+	spec, known := deltaFields[deltaType]
+	if !known {
+		return
+	}
+	if _, ok := delta[spec.field].(string); !ok {
+		c.failf(i, ev, "delta.%s is missing", spec.field)
+	}
+	if index < len(c.blocks) && !slices.Contains(spec.blocks, c.blocks[index]) {
+		c.failf(i, ev, "%s in a %s block", deltaType, c.blocks[index])
+	}
+}
+
+// index returns the event's content block index, which must be present.
+func (c *checker) index(i int, ev Event, data map[string]any) (int, bool) {
+	index, ok := data["index"].(float64)
+	if !ok {
+		c.failf(i, ev, "index is missing")
+		return 0, false
+	}
+	return int(index), true
+}
@@ -0,0 +1,94 @@
+package streamcheck
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/pkg/types"
+)
+
+const (
+	messageStart = `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"m","content":[],"usage":{"input_tokens":1,"output_tokens":0}}}`
+	textStart    = `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`
+	textDelta    = `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`
+	blockStop    = `{"type":"content_block_stop","index":0}`
+	messageDelta = `{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":1}}`
+	messageStop  = `{"type":"message_stop"}`
+	ping         = `{"type":"ping"}`
+)
+
+// stream builds events from their data, naming each after its type.
+func stream(data ...string) []Event {
+	events := make([]Event, len(data))
+	for i, d := range data {
+		name := d[len(`{"type":"`):]
+		events[i] = Event{Type: name[:strings.Index(name, `"`)], Data: []byte(d)}
+	}
+	return events
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  []Event
+		wantErr string
+	}{
+		{"complete stream", stream(messageStart, ping, textStart, textDelta, ping, blockStop, messageDelta, messageStop), ""},
+		{"error before message_start", stream(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`), ""},
+		{"error mid-stream", stream(messageStart, textStart, `{"type":"error","error":{"type":"api_error","message":"boom"}}`), ""},
+		{"truncated", stream(messageStart, textStart, textDelta), "ended without message_stop"},
+		{"event before message_start", stream(textStart, blockStop, messageDelta, messageStop), "before message_start"},
+		{"missing index", stream(messageStart, `{"type":"content_block_start","content_block":{"type":"text","text":""}}`), "index is missing"},
+		{"skipped index", stream(messageStart, `{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`), "expected index 0"},
+		{"missing text", stream(messageStart, `{"type":"content_block_start","index":0,"content_block":{"type":"text"}}`), "content_block.text is missing"},
+		{"delta to closed block", stream(messageStart, textStart, blockStop, textDelta), "not open"},
+		{"delta type mismatch", stream(messageStart, textStart, `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{}"}}`), "input_json_delta in a text block"},
+		{"block open at message_delta", stream(messageStart, textStart, messageDelta, messageStop), "still open"},
+		{"duplicate message_stop", stream(messageStart, messageDelta, messageStop, messageStop), "after the end of the stream"},
+		{"undocumented error type", stream(messageStart, `{"type":"error","error":{"type":"stream_error","message":"x"}}`), "undocumented error type"},
+		{"event after error", stream(`{"type":"error","error":{"type":"api_error","message":"x"}}`, messageStop), "after the end of the stream"},
+		{"ping with fields", stream(messageStart, `{"type":"ping","n":1}`, messageDelta, messageStop), "ping carries fields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.events)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected violations:\n%v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected a violation containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFromStreamEvents_RequiredFields(t *testing.T) {
+	events, err := FromStreamEvents([]types.StreamEvent{
+		{Type: "message_start", Message: &types.AnthropicResponse{ID: "msg_1", Type: "message", Role: "assistant", Model: "m"}},
+		{Type: "content_block_start", Index: 0, ContentBlock: &types.ContentBlock{Type: "tool_use", ID: "toolu_1", Name: "read"}},
+		{Type: "content_block_delta", Index: 0, Delta: &types.Delta{Type: "input_json_delta"}},
+		{Type: "content_block_stop", Index: 0},
+		{Type: "message_delta", Delta: &types.Delta{StopReason: "tool_use"}, Usage: &types.Usage{}},
+		{Type: "message_stop"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(events); err != nil {
+		t.Errorf("zero-valued fields must still be serialized:\n%v", err)
+	}
+	for i, want := range []string{
+		`"content":[]`,
+		`"index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"read","input":{}}`,
+		`"delta":{"type":"input_json_delta","partial_json":""}`,
+		`"index":0`,
+		`"delta":{"stop_reason":"tool_use","stop_sequence":null}`,
+	} {
+		if !strings.Contains(string(events[i].Data), want) {
+			t.Errorf("event %d: expected %s in %s", i, want, events[i].Data)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package telemetry builds an anonymized, aggregate usage report that helps the maintainers
+// prioritize work. Reporting is opt-in (TELEMETRY_ENABLED) and off by default; the payload
+// can always be previewed locally with GET /admin/telemetry.
+//
+// A report carries only the proxy version, platform, uptime and, per provider, a bucketed
+// account count, the number of /v1/messages requests since the last report and a bucketed
+// error rate. It never includes account emails, API keys, model names, users, prompts or
+// addresses.
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+	"github.com/kuzerno1/multi-claude-proxy/internal/utils"
+)
+
+// Report is the payload sent to the telemetry endpoint.
+type Report struct {
+	Version     string                    `json:"version"`
+	OS          string                    `json:"os"`
+	Arch        string                    `json:"arch"`
+	UptimeHours int                       `json:"uptimeHours"`
+	Providers   map[string]ProviderReport `json:"providers"`
+}
+
+// ProviderReport aggregates one provider's accounts and requests.
+type ProviderReport struct {
+	Accounts  string `json:"accounts"`  // bucketed, see accountBucket
+	Requests  int64  `json:"requests"`  // since the last report
+	ErrorRate string `json:"errorRate"` // bucketed, see errorRateBucket
+}
+
+type counts struct {
+	requests int64
+	errors   int64
+}
+
+// Reporter counts requests per provider and, when enabled, periodically sends a Report.
+// A nil Reporter ignores requests.
+type Reporter struct {
+	cfg      config.TelemetryConfig
+	version  string
+	started  time.Time
+	accounts func() map[string]int // account count per provider
+	client   *http.Client
+
+	mu     sync.Mutex
+	counts map[string]*counts
+}
+
+// New creates a reporter for the given proxy version. accounts returns the number of
+// accounts per provider when a report is built; it may be nil.
+func New(cfg config.TelemetryConfig, version string, accounts func() map[string]int) *Reporter {
+	return &Reporter{
+		cfg:      cfg,
+		version:  version,
+		started:  time.Now(),
+		accounts: accounts,
+		client:   &http.Client{Timeout: config.TelemetryTimeout},
+		counts:   make(map[string]*counts),
+	}
+}
+
+// Enabled reports whether reports are sent, which requires both the opt-in and an endpoint.
+func (r *Reporter) Enabled() bool {
+	return r != nil && r.cfg.Enabled && r.cfg.Endpoint != ""
+}
+
+// Endpoint returns the URL reports are sent to.
+func (r *Reporter) Endpoint() string {
+	if r == nil {
+		return ""
+	}
+	return r.cfg.Endpoint
+}
+
+// Record counts a completed request to provider; statuses of 400 and above are errors.
+func (r *Reporter) Record(provider string, status int) {
+	if r == nil || provider == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.counts[provider]
+	if !ok {
+		c = &counts{}
+		r.counts[provider] = c
+	}
+	c.requests++
+	if status >= 400 {
+		c.errors++
+	}
+}
+
+// Preview returns the report that would be sent now, without sending it or resetting
+// the request counts.
+func (r *Reporter) Preview() Report {
+	report, _ := r.build()
+	return report
+}
+
+// build returns the current report and the request counts it covers.
+func (r *Reporter) build() (Report, map[string]counts) {
+	report := Report{
+		Version:     r.version,
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		UptimeHours: int(time.Since(r.started).Hours()),
+		Providers:   make(map[string]ProviderReport),
+	}
+
+	var accounts map[string]int
+	if r.accounts != nil {
+		accounts = r.accounts()
+	}
+	for name, n := range accounts {
+		report.Providers[name] = ProviderReport{Accounts: accountBucket(n), ErrorRate: errorRateBucket(0, 0)}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reported := make(map[string]counts, len(r.counts))
+	for name, c := range r.counts {
+		reported[name] = *c
+		report.Providers[name] = ProviderReport{
+			Accounts:  accountBucket(accounts[name]),
+			Requests:  c.requests,
+			ErrorRate: errorRateBucket(c.errors, c.requests),
+		}
+	}
+	return report, reported
+}
+
+// Start sends a report every configured interval until stop is closed. It does nothing
+// unless telemetry is enabled.
+func (r *Reporter) Start(stop <-chan struct{}) {
+	if !r.Enabled() || r.cfg.Interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.send(); err != nil {
+					utils.Debug("[Telemetry] Failed to send report: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// send posts the current report and, once delivered, starts counting requests afresh.
+func (r *Reporter) send() error {
+	report, reported := r.build()
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Post(r.cfg.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned status %d", resp.StatusCode)
+	}
+
+	// Subtract what was reported rather than clearing, so requests completed while the
+	// report was in flight count toward the next one.
+	r.mu.Lock()
+	for name, sent := range reported {
+		c := r.counts[name]
+		c.requests -= sent.requests
+		c.errors -= sent.errors
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// accountBucket coarsens an account count so a report doesn't fingerprint a deployment.
+func accountBucket(n int) string {
+	switch {
+	case n <= 0:
+		return "0"
+	case n == 1:
+		return "1"
+	case n <= 5:
+		return "2-5"
+	case n <= 20:
+		return "6-20"
+	default:
+		return "21+"
+	}
+}
+
+// errorRateBucket coarsens an error rate into a few ranges.
+func errorRateBucket(errors, requests int64) string {
+	if requests == 0 || errors == 0 {
+		return "0%"
+	}
+	rate := float64(errors) / float64(requests)
+	switch {
+	case rate < 0.01:
+		return "<1%"
+	case rate < 0.05:
+		return "1-5%"
+	case rate < 0.2:
+		return "5-20%"
+	default:
+		return "20%+"
+	}
+}
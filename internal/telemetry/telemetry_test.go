@@ -0,0 +1,124 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+func TestReporter_Preview(t *testing.T) {
+	r := New(config.TelemetryConfig{}, "1.2.3", func() map[string]int {
+		return map[string]int{"antigravity": 3, "zai": 1}
+	})
+	for i := 0; i < 9; i++ {
+		r.Record("antigravity", http.StatusOK)
+	}
+	r.Record("antigravity", http.StatusTooManyRequests)
+	r.Record("openai", http.StatusOK) // a provider without accounts
+
+	report := r.Preview()
+	if report.Version != "1.2.3" || report.OS == "" || report.Arch == "" {
+		t.Errorf("unexpected report header: %+v", report)
+	}
+	want := map[string]ProviderReport{
+		"antigravity": {Accounts: "2-5", Requests: 10, ErrorRate: "5-20%"},
+		"zai":         {Accounts: "1", Requests: 0, ErrorRate: "0%"},
+		"openai":      {Accounts: "0", Requests: 1, ErrorRate: "0%"},
+	}
+	if len(report.Providers) != len(want) {
+		t.Fatalf("providers = %+v, want %+v", report.Providers, want)
+	}
+	for name, pr := range want {
+		if got := report.Providers[name]; got != pr {
+			t.Errorf("%s = %+v, want %+v", name, got, pr)
+		}
+	}
+
+	// Previewing doesn't reset the counts.
+	if got := r.Preview().Providers["antigravity"].Requests; got != 10 {
+		t.Errorf("requests after a second preview = %d, want 10", got)
+	}
+}
+
+func TestReporter_DisabledByDefault(t *testing.T) {
+	t.Setenv("TELEMETRY_ENABLED", "")
+	t.Setenv("TELEMETRY_ENDPOINT", "")
+	if New(config.GetTelemetryConfig(), "dev", nil).Enabled() {
+		t.Error("telemetry is enabled without TELEMETRY_ENABLED")
+	}
+	t.Setenv("TELEMETRY_ENABLED", "true")
+	if New(config.GetTelemetryConfig(), "dev", nil).Enabled() {
+		t.Error("telemetry is enabled without TELEMETRY_ENDPOINT")
+	}
+	t.Setenv("TELEMETRY_ENDPOINT", "http://127.0.0.1:1/report")
+	if !New(config.GetTelemetryConfig(), "dev", nil).Enabled() {
+		t.Error("telemetry is disabled with TELEMETRY_ENABLED and TELEMETRY_ENDPOINT")
+	}
+
+	var nilReporter *Reporter
+	nilReporter.Record("zai", http.StatusOK) // must not panic
+	if nilReporter.Enabled() {
+		t.Error("nil reporter is enabled")
+	}
+}
+
+func TestReporter_Send(t *testing.T) {
+	var received []Report
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report Report
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("decode report: %v", err)
+		}
+		received = append(received, report)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	r := New(config.TelemetryConfig{Enabled: true, Endpoint: srv.URL}, "1.2.3", nil)
+	r.Record("zai", http.StatusOK)
+	r.Record("zai", http.StatusInternalServerError)
+
+	status = http.StatusServiceUnavailable
+	if err := r.send(); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("send to a failing endpoint: err = %v", err)
+	}
+	// Undelivered counts are kept for the next report.
+	status = http.StatusOK
+	if err := r.send(); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got := received[1].Providers["zai"]; got.Requests != 2 || got.ErrorRate != "20%+" {
+		t.Errorf("delivered zai = %+v, want 2 requests at 20%%+", got)
+	}
+
+	// Delivered counts start afresh.
+	r.Record("zai", http.StatusOK)
+	if got := r.Preview().Providers["zai"]; got.Requests != 1 || got.ErrorRate != "0%" {
+		t.Errorf("zai after delivery = %+v, want 1 request at 0%%", got)
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	accounts := map[int]string{0: "0", 1: "1", 2: "2-5", 5: "2-5", 6: "6-20", 20: "6-20", 21: "21+"}
+	for n, want := range accounts {
+		if got := accountBucket(n); got != want {
+			t.Errorf("accountBucket(%d) = %q, want %q", n, got, want)
+		}
+	}
+	rates := []struct {
+		errors, requests int64
+		want             string
+	}{
+		{0, 0, "0%"}, {0, 10, "0%"}, {1, 200, "<1%"}, {1, 50, "1-5%"}, {1, 10, "5-20%"}, {1, 5, "20%+"},
+	}
+	for _, tc := range rates {
+		if got := errorRateBucket(tc.errors, tc.requests); got != tc.want {
+			t.Errorf("errorRateBucket(%d, %d) = %q, want %q", tc.errors, tc.requests, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Package tracing records OpenTelemetry-compatible spans for proxied requests and exports
+// them to a collector over OTLP/HTTP with JSON encoding.
+//
+// Spans are only recorded inside a request that the API middleware started a trace for;
+// without a configured tracer every function here is a cheap no-op.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// SpanKind is the OTLP span kind.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// Attr is a span attribute. Value is a string, bool, int, int64 or float64.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute.
+func String(key, value string) Attr { return Attr{key, value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attr { return Attr{key, int64(value)} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attr { return Attr{key, value} }
+
+// Tracer decides which traces are sampled and hands finished spans to the exporter.
+type Tracer struct {
+	exporter    *Exporter
+	sampleRatio float64
+}
+
+// New creates a tracer that exports to cfg.Endpoint. It returns nil when tracing is disabled.
+func New(cfg config.TracingConfig) *Tracer {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &Tracer{exporter: NewExporter(cfg), sampleRatio: cfg.SampleRatio}
+}
+
+// Shutdown exports queued spans and stops the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+var current atomic.Pointer[Tracer]
+
+// SetTracer installs t as the process-wide tracer. Pass nil to disable tracing.
+func SetTracer(t *Tracer) {
+	current.Store(t)
+}
+
+// Span is one timed operation within a trace. All methods are safe on a nil Span.
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	sampled  bool
+	name     string
+	kind     SpanKind
+	start    time.Time
+
+	mu        sync.Mutex
+	end       time.Time
+	ended     bool
+	attrs     []Attr
+	failed    bool
+	statusMsg string
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the current span, or nil when the context isn't traced.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWithSpan returns a context carrying s as the current span.
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// Start begins a span as a child of the span in ctx, or as the root of a new trace.
+// It returns ctx unchanged and a nil span when no tracer is installed.
+func Start(ctx context.Context, name string, kind SpanKind, attrs ...Attr) (context.Context, *Span) {
+	t := current.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now(), attrs: attrs}
+	s.spanID = newSpanID()
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+		s.sampled = parent.sampled
+	} else {
+		s.traceID = newTraceID()
+		s.sampled = sampleTrace(s.traceID, t.sampleRatio)
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+// StartChild begins a span only when ctx already belongs to a sampled trace, so background
+// work (quota refreshes, token renewals) doesn't start traces of its own.
+func StartChild(ctx context.Context, name string, kind SpanKind, attrs ...Attr) (context.Context, *Span) {
+	if parent := SpanFromContext(ctx); parent == nil || !parent.sampled {
+		return ctx, nil
+	}
+	return Start(ctx, name, kind, attrs...)
+}
+
+// RecordSpan records an already finished operation that began at start and ended now,
+// as a child of the span in ctx.
+func RecordSpan(ctx context.Context, name string, start time.Time, attrs ...Attr) {
+	if _, s := StartChild(ctx, name, KindInternal, attrs...); s != nil {
+		s.start = start
+		s.End()
+	}
+}
+
+// sampleTrace keeps ratio of traces, deciding from the trace ID so the choice is stable.
+func sampleTrace(id [16]byte, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+	var n uint64
+	for _, b := range id[8:] {
+		n = n<<8 | uint64(b)
+	}
+	return float64(n>>11)/float64(1<<53) < ratio
+}
+
+// TraceID returns the hex trace ID, or "" for a nil span.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed with err's message. A nil err is ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Fail(err.Error())
+}
+
+// Fail marks the span as failed with msg.
+func (s *Span) Fail(msg string) {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.mu.Lock()
+	s.failed, s.statusMsg = true, msg
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export. Calls after the first are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.end = true, time.Now()
+	s.mu.Unlock()
+
+	if s.sampled && s.tracer != nil {
+		s.tracer.exporter.enqueue(s)
+	}
+}
+
+// Extract returns ctx with the remote parent from a W3C traceparent header, if h has a
+// valid one, so the proxy's spans join the caller's trace.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	parts := strings.Split(strings.TrimSpace(h.Get("traceparent")), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ctx
+	}
+	traceID, err1 := hex.DecodeString(parts[1])
+	spanID, err2 := hex.DecodeString(parts[2])
+	flags, err3 := hex.DecodeString(parts[3])
+	if err1 != nil || err2 != nil || err3 != nil || len(traceID) != 16 || len(spanID) != 8 || len(flags) != 1 {
+		return ctx
+	}
+
+	remote := &Span{sampled: flags[0]&0x01 == 1, ended: true}
+	copy(remote.traceID[:], traceID)
+	copy(remote.spanID[:], spanID)
+	if remote.traceID == ([16]byte{}) || remote.spanID == ([8]byte{}) {
+		return ctx
+	}
+	return ContextWithSpan(ctx, remote)
+}
+
+func newTraceID() (id [16]byte) {
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() (id [8]byte) {
+	_, _ = rand.Read(id[:])
+	return id
+}
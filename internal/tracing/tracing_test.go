@@ -0,0 +1,259 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kuzerno1/multi-claude-proxy/internal/config"
+)
+
+// collector is a fake OTLP/HTTP endpoint that records exported spans.
+type collector struct {
+	mu      sync.Mutex
+	headers http.Header
+	spans   []otlpSpan
+	service string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = r.Header.Clone()
+	for _, rs := range req.ResourceSpans {
+		for _, kv := range rs.Resource.Attributes {
+			if kv.Key == "service.name" {
+				c.service, _ = kv.Value["stringValue"].(string)
+			}
+		}
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func (c *collector) byName(name string) (otlpSpan, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.spans {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return otlpSpan{}, false
+}
+
+// installTracer sets a tracer exporting to a fake collector and returns a flush func
+// that shuts the exporter down so every queued span is delivered.
+func installTracer(t *testing.T, ratio float64) (*collector, func()) {
+	t.Helper()
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	t.Cleanup(srv.Close)
+
+	tracer := New(config.TracingConfig{
+		Endpoint:    srv.URL + "/v1/traces",
+		Headers:     map[string]string{"Authorization": "Bearer t"},
+		ServiceName: "test-proxy",
+		SampleRatio: ratio,
+	})
+	SetTracer(tracer)
+	t.Cleanup(func() { SetTracer(nil) })
+	return c, func() {
+		if err := tracer.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStart_NoTracer(t *testing.T) {
+	SetTracer(nil)
+	ctx, span := Start(context.Background(), "op", KindServer)
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatal("expected no span without a tracer")
+	}
+	// Nil spans are safe to use.
+	span.SetAttributes(String("k", "v"))
+	span.SetError(io.EOF)
+	span.End()
+}
+
+func TestExport_ParentChildSpans(t *testing.T) {
+	c, flush := installTracer(t, 1)
+
+	ctx, root := Start(context.Background(), "POST /v1/messages", KindServer)
+	_, child := StartChild(ctx, "provider.select", KindInternal, String("model", "m"), Int("n", 3), Bool("ok", true))
+	child.SetError(io.ErrUnexpectedEOF)
+	child.End()
+	RecordSpan(ctx, "account.pick", time.Now().Add(-time.Second), String("account", "a@x"))
+	root.End()
+	root.End() // second End is ignored
+	flush()
+
+	if c.service != "test-proxy" || c.headers.Get("Authorization") != "Bearer t" {
+		t.Errorf("unexpected resource or headers: service=%q auth=%q", c.service, c.headers.Get("Authorization"))
+	}
+	if len(c.spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(c.spans))
+	}
+	rootSpan, _ := c.byName("POST /v1/messages")
+	selectSpan, _ := c.byName("provider.select")
+	pickSpan, _ := c.byName("account.pick")
+
+	if rootSpan.ParentSpanID != "" || rootSpan.Kind != KindServer {
+		t.Errorf("unexpected root span: %+v", rootSpan)
+	}
+	for _, s := range []otlpSpan{selectSpan, pickSpan} {
+		if s.TraceID != rootSpan.TraceID || s.ParentSpanID != rootSpan.SpanID {
+			t.Errorf("%s is not a child of the root span: %+v", s.Name, s)
+		}
+	}
+	if selectSpan.Status.Code != 2 || selectSpan.Status.Message != io.ErrUnexpectedEOF.Error() {
+		t.Errorf("expected error status, got %+v", selectSpan.Status)
+	}
+	if len(selectSpan.Attributes) != 3 || selectSpan.Attributes[1].Value["intValue"] != "3" || selectSpan.Attributes[2].Value["boolValue"] != true {
+		t.Errorf("unexpected attributes: %+v", selectSpan.Attributes)
+	}
+	start, _ := strconv.ParseInt(pickSpan.StartTimeUnixNano, 10, 64)
+	end, _ := strconv.ParseInt(pickSpan.EndTimeUnixNano, 10, 64)
+	if time.Duration(end-start) < time.Second {
+		t.Errorf("expected recorded span to last about a second, got %s", time.Duration(end-start))
+	}
+}
+
+func TestStartChild_RequiresSampledParent(t *testing.T) {
+	c, flush := installTracer(t, 0)
+
+	if _, span := StartChild(context.Background(), "background", KindClient); span != nil {
+		t.Error("expected no span without a parent")
+	}
+	ctx, root := Start(context.Background(), "root", KindServer)
+	if _, span := StartChild(ctx, "child", KindInternal); span != nil {
+		t.Error("expected no child span under an unsampled root")
+	}
+	root.End()
+	flush()
+
+	if len(c.spans) != 0 {
+		t.Errorf("expected unsampled trace not to be exported, got %d spans", len(c.spans))
+	}
+}
+
+func TestExtract(t *testing.T) {
+	_, flush := installTracer(t, 0) // the caller's sampled flag overrides the local ratio
+	defer flush()
+
+	tests := []struct {
+		name        string
+		traceparent string
+		wantParent  bool
+		wantSampled bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"missing", "", false, false},
+		{"malformed", "00-xyz-00f067aa0ba902b7-01", false, false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.traceparent != "" {
+				h.Set("traceparent", tt.traceparent)
+			}
+			_, span := Start(Extract(context.Background(), h), "root", KindServer)
+			if tt.wantParent {
+				if span.TraceID() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.parentID == ([8]byte{}) {
+					t.Errorf("expected span to join the caller's trace, got trace %s", span.TraceID())
+				}
+			} else if span.parentID != ([8]byte{}) {
+				t.Error("expected a new root span")
+			}
+			if tt.wantParent && span.sampled != tt.wantSampled {
+				t.Errorf("sampled = %v, want %v", span.sampled, tt.wantSampled)
+			}
+		})
+	}
+}
+
+func TestSampleTrace(t *testing.T) {
+	sampled := 0
+	for i := 0; i < 2000; i++ {
+		if sampleTrace(newTraceID(), 0.25) {
+			sampled++
+		}
+	}
+	if sampled < 350 || sampled > 650 {
+		t.Errorf("expected about 500 of 2000 traces sampled at 0.25, got %d", sampled)
+	}
+}
+
+func TestTransport_SpanCoversResponseBody(t *testing.T) {
+	c, flush := installTracer(t, 1)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = io.WriteString(w, "data: {}\n\n")
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: Transport(nil)}
+
+	// Without a traced request the transport records nothing.
+	resp, err := client.Get(upstream.URL + "/untraced")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	ctx, root := Start(context.Background(), "root", KindServer)
+	for _, path := range []string{"/stream?key=secret", "/fail"} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL+path, strings.NewReader("{}"))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	root.End()
+	flush()
+
+	var httpSpans []otlpSpan
+	for _, s := range c.spans {
+		if s.Name == "HTTP POST" {
+			httpSpans = append(httpSpans, s)
+		}
+	}
+	if len(httpSpans) != 2 {
+		t.Fatalf("expected 2 client spans, got %d of %d spans", len(httpSpans), len(c.spans))
+	}
+	for _, s := range httpSpans {
+		if s.Kind != KindClient {
+			t.Errorf("expected client span kind, got %d", s.Kind)
+		}
+		for _, kv := range s.Attributes {
+			if kv.Key == "url.path" && strings.Contains(kv.Value["stringValue"].(string), "secret") {
+				t.Errorf("query string leaked into span: %v", kv.Value)
+			}
+		}
+	}
+	if httpSpans[0].Status.Code != 0 || httpSpans[1].Status.Code != 2 {
+		t.Errorf("expected only the 429 span to fail, got %+v and %+v", httpSpans[0].Status, httpSpans[1].Status)
+	}
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Transport wraps base (http.DefaultTransport when nil) so upstream requests made within
+// a traced request get a client span. The span stays open until the response body is
+// read to the end or closed, so it covers the whole upstream stream.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	_, span := StartChild(req.Context(), "HTTP "+req.Method, KindClient,
+		String("http.request.method", req.Method),
+		String("server.address", req.URL.Hostname()),
+		String("url.path", req.URL.Path), // query strings may carry API keys
+	)
+	if span == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.SetError(err)
+		span.End()
+		return nil, err
+	}
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.Fail("HTTP " + strconv.Itoa(resp.StatusCode))
+	}
+	resp.Body = &spanBody{ReadCloser: resp.Body, span: span}
+	return resp, nil
+}
+
+// spanBody ends its span once the response body is drained or closed.
+type spanBody struct {
+	io.ReadCloser
+	span *Span
+}
+
+func (b *spanBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		if err != io.EOF {
+			b.span.SetError(err)
+		}
+		b.span.End()
+	}
+	return n, err
+}
+
+func (b *spanBody) Close() error {
+	b.span.End()
+	return b.ReadCloser.Close()
+}
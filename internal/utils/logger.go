@@ -26,13 +26,14 @@ const (
 type Logger struct {
 	mu           sync.RWMutex
 	debugEnabled bool
+	out          io.Writer
 	handler      slog.Handler
 	logger       *slog.Logger
 }
 
 // coloredHandler implements slog.Handler with colored output.
 type coloredHandler struct {
-	out          io.Writer
+	out          *io.Writer
 	debugEnabled *bool
 	mu           *sync.RWMutex
 }
@@ -71,7 +72,10 @@ func (h *coloredHandler) Handle(_ context.Context, r slog.Record) error {
 	timestamp := r.Time.Format("15:04:05")
 	msg := fmt.Sprintf("%s%s %s%s %s\n", color, timestamp, prefix, colorReset, r.Message)
 
-	_, err := h.out.Write([]byte(msg))
+	h.mu.RLock()
+	out := *h.out
+	h.mu.RUnlock()
+	_, err := out.Write([]byte(msg))
 	return err
 }
 
@@ -87,10 +91,11 @@ func (h *coloredHandler) WithGroup(name string) slog.Handler {
 func NewLogger() *Logger {
 	l := &Logger{
 		debugEnabled: false,
+		out:          os.Stdout,
 	}
 
 	l.handler = &coloredHandler{
-		out:          os.Stdout,
+		out:          &l.out,
 		debugEnabled: &l.debugEnabled,
 		mu:           &l.mu,
 	}
@@ -106,6 +111,13 @@ func (l *Logger) SetDebug(enabled bool) {
 	l.debugEnabled = enabled
 }
 
+// SetOutput redirects log output (stdout by default).
+func (l *Logger) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out = w
+}
+
 // IsDebugEnabled returns true if debug mode is enabled.
 func (l *Logger) IsDebugEnabled() bool {
 	l.mu.RLock()
@@ -137,7 +149,10 @@ func (l *Logger) Error(msg string, args ...any) {
 func (l *Logger) Success(msg string, args ...any) {
 	timestamp := time.Now().Format("15:04:05")
 	formatted := fmt.Sprintf(msg, args...)
-	fmt.Printf("%s%s [SUCCESS]%s %s\n", colorGreen, timestamp, colorReset, formatted)
+	l.mu.RLock()
+	out := l.out
+	l.mu.RUnlock()
+	fmt.Fprintf(out, "%s%s [SUCCESS]%s %s\n", colorGreen, timestamp, colorReset, formatted)
 }
 
 // DefaultLogger is the package-level logger instance.
@@ -148,6 +163,11 @@ func SetDebug(enabled bool) {
 	DefaultLogger.SetDebug(enabled)
 }
 
+// SetOutput redirects the default logger, e.g. to stderr when stdout carries JSON.
+func SetOutput(w io.Writer) {
+	DefaultLogger.SetOutput(w)
+}
+
 // IsDebugEnabled returns true if debug mode is enabled on the default logger.
 func IsDebugEnabled() bool {
 	return DefaultLogger.IsDebugEnabled()
@@ -0,0 +1,251 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// coreBlockTypes are the content block types every converter handles natively.
+var coreBlockTypes = map[string]bool{
+	"text":              true,
+	"image":             true,
+	"document":          true,
+	"tool_use":          true,
+	"tool_result":       true,
+	"thinking":          true,
+	"redacted_thinking": true,
+}
+
+// IsCoreBlockType reports whether a content block type is handled natively by all converters.
+func IsCoreBlockType(blockType string) bool {
+	return coreBlockTypes[blockType]
+}
+
+// ExtendedBlockType describes a content block type newer Anthropic clients emit beyond the core set.
+// Upstreams that speak the Anthropic format receive these blocks verbatim (see ContentBlock.Raw);
+// converters for other formats degrade them to text with Render.
+type ExtendedBlockType struct {
+	Name string
+
+	// Render returns a plain-text rendering of the block's JSON fields, or "" if there is nothing to show.
+	Render func(fields map[string]interface{}) string
+}
+
+// extendedBlockTypes is the registry of known extended block types.
+var extendedBlockTypes = map[string]ExtendedBlockType{
+	"search_result":                          {Name: "search_result", Render: renderSearchResult},
+	"mcp_tool_use":                           {Name: "mcp_tool_use", Render: renderMCPToolUse},
+	"mcp_tool_result":                        {Name: "mcp_tool_result", Render: toolResultRenderer("MCP tool result")},
+	"server_tool_use":                        {Name: "server_tool_use", Render: renderServerToolUse},
+	"web_search_tool_result":                 {Name: "web_search_tool_result", Render: renderWebSearchResult},
+	"web_fetch_tool_result":                  {Name: "web_fetch_tool_result", Render: toolResultRenderer("Web fetch result")},
+	"code_execution_tool_result":             {Name: "code_execution_tool_result", Render: renderCodeExecutionResult},
+	"bash_code_execution_tool_result":        {Name: "bash_code_execution_tool_result", Render: renderCodeExecutionResult},
+	"text_editor_code_execution_tool_result": {Name: "text_editor_code_execution_tool_result", Render: toolResultRenderer("Text editor result")},
+	"container_upload":                       {Name: "container_upload", Render: renderContainerUpload},
+	"image_generation_tool_result":           {Name: "image_generation_tool_result", Render: renderImageGenerationResult},
+}
+
+// LookupExtendedBlockType returns the registry entry for a known extended block type.
+func LookupExtendedBlockType(blockType string) (ExtendedBlockType, bool) {
+	t, ok := extendedBlockTypes[blockType]
+	return t, ok
+}
+
+// UnmarshalJSON decodes a content block, keeping the original JSON of non-core blocks in Raw.
+// Extended blocks may reuse field names with different shapes (search_result has a string
+// "source"), so source is only decoded into ImageSource when it is an object.
+func (b *ContentBlock) UnmarshalJSON(data []byte) error {
+	type plain ContentBlock
+	aux := struct {
+		*plain
+		Source json.RawMessage `json:"source,omitempty"`
+	}{plain: (*plain)(b)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	b.Source = nil
+	if src := bytes.TrimSpace(aux.Source); len(src) > 0 && src[0] == '{' {
+		var source ImageSource
+		if err := json.Unmarshal(src, &source); err != nil {
+			return err
+		}
+		b.Source = &source
+	}
+
+	b.Raw = nil
+	if !IsCoreBlockType(b.Type) {
+		b.Raw = append(json.RawMessage(nil), data...)
+	}
+	return nil
+}
+
+// MarshalJSON re-emits the original JSON of non-core blocks so they pass through unchanged.
+// tool_use blocks always carry input, which upstreams require even when it is empty.
+func (b ContentBlock) MarshalJSON() ([]byte, error) {
+	if len(b.Raw) > 0 && !IsCoreBlockType(b.Type) {
+		return b.Raw, nil
+	}
+	type plain ContentBlock
+	if b.Type == "tool_use" && len(b.Input) == 0 {
+		return json.Marshal(struct {
+			plain
+			Input map[string]interface{} `json:"input"`
+		}{plain(b), map[string]interface{}{}})
+	}
+	return json.Marshal(plain(b))
+}
+
+// ExtendedText returns a plain-text rendering of a non-core block for converters whose
+// upstream format has no equivalent. Registered types use their renderer; unregistered
+// types fall back to any text they carry. ok is false for core blocks and blocks with
+// nothing to render, which converters should drop.
+func (b ContentBlock) ExtendedText() (string, bool) {
+	if IsCoreBlockType(b.Type) || len(b.Raw) == 0 {
+		return "", false
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b.Raw, &fields); err != nil {
+		return "", false
+	}
+
+	var text string
+	if t, ok := LookupExtendedBlockType(b.Type); ok {
+		text = t.Render(fields)
+	} else {
+		text = strings.Join(collectText(fields), "\n")
+	}
+	text = strings.TrimSpace(text)
+	return text, text != ""
+}
+
+func renderSearchResult(f map[string]interface{}) string {
+	lines := []string{"Search result: " + stringField(f, "title")}
+	if source := stringField(f, "source"); source != "" {
+		lines = append(lines, "Source: "+source)
+	}
+	lines = append(lines, collectText(f["content"])...)
+	return strings.Join(lines, "\n")
+}
+
+func renderMCPToolUse(f map[string]interface{}) string {
+	return fmt.Sprintf("MCP tool call %s/%s: %s", stringField(f, "server_name"), stringField(f, "name"), compactJSON(f["input"]))
+}
+
+func renderServerToolUse(f map[string]interface{}) string {
+	return fmt.Sprintf("Server tool call %s: %s", stringField(f, "name"), compactJSON(f["input"]))
+}
+
+func renderContainerUpload(f map[string]interface{}) string {
+	return "Container upload: " + stringField(f, "file_id")
+}
+
+func renderWebSearchResult(f map[string]interface{}) string {
+	results, ok := f["content"].([]interface{})
+	if !ok {
+		// Errors are a single object, e.g. {"type":"web_search_tool_result_error","error_code":"..."}
+		if errObj, ok := f["content"].(map[string]interface{}); ok {
+			return "Web search failed: " + stringField(errObj, "error_code")
+		}
+		return ""
+	}
+
+	lines := []string{"Web search results:"}
+	for _, r := range results {
+		if m, ok := r.(map[string]interface{}); ok {
+			lines = append(lines, fmt.Sprintf("- %s (%s)", stringField(m, "title"), stringField(m, "url")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderImageGenerationResult renders the result of the proxy's image tool without the image data.
+func renderImageGenerationResult(f map[string]interface{}) string {
+	if isErr, _ := f["is_error"].(bool); isErr {
+		return "Image generation failed: " + strings.Join(collectText(f["content"]), "\n")
+	}
+	images := 0
+	if content, ok := f["content"].([]interface{}); ok {
+		for _, item := range content {
+			if m, ok := item.(map[string]interface{}); ok && m["type"] == "image" {
+				images++
+			}
+		}
+	}
+	return fmt.Sprintf("Image generation result: %d image(s)", images)
+}
+
+func renderCodeExecutionResult(f map[string]interface{}) string {
+	content, ok := f["content"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if code := stringField(content, "error_code"); code != "" {
+		return "Code execution failed: " + code
+	}
+
+	lines := []string{fmt.Sprintf("Code execution result (exit %v):", content["return_code"])}
+	for _, key := range []string{"stdout", "stderr"} {
+		if s := stringField(content, key); s != "" {
+			lines = append(lines, s)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// toolResultRenderer renders a server or MCP tool result block as "<label> (<tool_use_id>): <text>".
+func toolResultRenderer(label string) func(map[string]interface{}) string {
+	return func(f map[string]interface{}) string {
+		text := strings.Join(collectText(f["content"]), "\n")
+		if text == "" {
+			text = compactJSON(f["content"])
+		}
+		header := fmt.Sprintf("%s (%s)", label, stringField(f, "tool_use_id"))
+		if isErr, _ := f["is_error"].(bool); isErr {
+			header += " [error]"
+		}
+		return header + ": " + text
+	}
+}
+
+// collectText gathers text from a string, a block with a "text" field, or nested content arrays.
+func collectText(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		if val != "" {
+			return []string{val}
+		}
+	case []interface{}:
+		var texts []string
+		for _, item := range val {
+			texts = append(texts, collectText(item)...)
+		}
+		return texts
+	case map[string]interface{}:
+		if text, ok := val["text"].(string); ok {
+			return collectText(text)
+		}
+		return collectText(val["content"])
+	}
+	return nil
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func compactJSON(v interface{}) string {
+	if v == nil {
+		return "{}"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
@@ -0,0 +1,168 @@
+package types
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadExtendedFixture(t *testing.T) AnthropicRequest {
+	t.Helper()
+	data, err := os.ReadFile("testdata/extended_blocks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req AnthropicRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestContentBlock_ExtendedRoundTrip(t *testing.T) {
+	req := loadExtendedFixture(t)
+
+	for i, msg := range req.Messages {
+		blocks, err := ParseMessageContent(msg.Content)
+		if err != nil {
+			t.Fatalf("message %d: unexpected error: %v", i, err)
+		}
+
+		out, err := json.Marshal(blocks)
+		if err != nil {
+			t.Fatalf("message %d: marshal failed: %v", i, err)
+		}
+
+		var want, got interface{}
+		json.Unmarshal(msg.Content, &want)
+		json.Unmarshal(out, &got)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("message %d: round trip changed content\nwant %v\n got %v", i, want, got)
+		}
+	}
+}
+
+func TestContentBlock_MarshalToolUseInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ContentBlock
+		want  string
+	}{
+		{"empty input", ContentBlock{Type: "tool_use", ID: "toolu_1", Name: "list"}, `{"type":"tool_use","id":"toolu_1","name":"list","input":{}}`},
+		{"input", ContentBlock{Type: "tool_use", ID: "toolu_1", Name: "read", Input: map[string]interface{}{"path": "a"}}, `{"type":"tool_use","id":"toolu_1","name":"read","input":{"path":"a"}}`},
+		{"text", ContentBlock{Type: "text", Text: "hi"}, `{"type":"text","text":"hi"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := json.Marshal(tt.block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("got %s, want %s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentBlock_UnmarshalSource(t *testing.T) {
+	var blocks []ContentBlock
+	err := json.Unmarshal([]byte(`[
+		{"type":"search_result","source":"https://example.com","title":"t","content":[]},
+		{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}}
+	]`), &blocks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if blocks[0].Source != nil || len(blocks[0].Raw) == 0 {
+		t.Errorf("search_result: source = %+v, raw = %s", blocks[0].Source, blocks[0].Raw)
+	}
+	if blocks[1].Source == nil || blocks[1].Source.MediaType != "image/png" || blocks[1].Raw != nil {
+		t.Errorf("image: source = %+v, raw = %s", blocks[1].Source, blocks[1].Raw)
+	}
+}
+
+func TestContentBlock_ExtendedText(t *testing.T) {
+	tests := []struct {
+		name   string
+		block  string
+		want   []string
+		wantOK bool
+	}{
+		{
+			name:   "search result",
+			block:  `{"type":"search_result","source":"https://example.com/a","title":"A","content":[{"type":"text","text":"alpha"}]}`,
+			want:   []string{"Search result: A", "Source: https://example.com/a", "alpha"},
+			wantOK: true,
+		},
+		{
+			name:   "mcp tool use",
+			block:  `{"type":"mcp_tool_use","id":"m1","name":"lookup","server_name":"docs","input":{"q":"x"}}`,
+			want:   []string{`MCP tool call docs/lookup: {"q":"x"}`},
+			wantOK: true,
+		},
+		{
+			name:   "mcp tool result error",
+			block:  `{"type":"mcp_tool_result","tool_use_id":"m1","is_error":true,"content":"boom"}`,
+			want:   []string{"MCP tool result (m1) [error]: boom"},
+			wantOK: true,
+		},
+		{
+			name:   "web search results",
+			block:  `{"type":"web_search_tool_result","tool_use_id":"s1","content":[{"type":"web_search_result","title":"Go","url":"https://go.dev"}]}`,
+			want:   []string{"Web search results:", "- Go (https://go.dev)"},
+			wantOK: true,
+		},
+		{
+			name:   "code execution",
+			block:  `{"type":"code_execution_tool_result","tool_use_id":"c1","content":{"type":"code_execution_result","stdout":"hi","stderr":"","return_code":0}}`,
+			want:   []string{"Code execution result (exit 0):", "hi"},
+			wantOK: true,
+		},
+		{
+			name:   "image generation result",
+			block:  `{"type":"image_generation_tool_result","tool_use_id":"i1","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}}]}`,
+			want:   []string{"Image generation result: 1 image(s)"},
+			wantOK: true,
+		},
+		{
+			name:   "image generation error",
+			block:  `{"type":"image_generation_tool_result","tool_use_id":"i1","is_error":true,"content":[{"type":"text","text":"quota exhausted"}]}`,
+			want:   []string{"Image generation failed: quota exhausted"},
+			wantOK: true,
+		},
+		{
+			name:   "unregistered with text",
+			block:  `{"type":"future_block","text":"still readable"}`,
+			want:   []string{"still readable"},
+			wantOK: true,
+		},
+		{
+			name:  "unregistered without text",
+			block: `{"type":"opaque_block","payload":{"id":1}}`,
+		},
+		{
+			name:  "core block",
+			block: `{"type":"text","text":"plain"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var block ContentBlock
+			if err := json.Unmarshal([]byte(tt.block), &block); err != nil {
+				t.Fatal(err)
+			}
+			got, ok := block.ExtendedText()
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v (text %q)", ok, tt.wantOK, got)
+			}
+			if want := strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("text = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package types
+
+import "encoding/json"
+
+// MarshalJSON emits the fields Anthropic always sends for the event type, even when they
+// hold zero values: the message's stop_reason and stop_sequence (null) and every usage count
+// in message_start, "index" on content block events, the empty text/input of a starting
+// block and the delta's payload field (clients append deltas to these). message_delta usage
+// leaves out the counts that are unset.
+func (e StreamEvent) MarshalJSON() ([]byte, error) {
+	type plain StreamEvent
+	switch e.Type {
+	case "message_start":
+		if e.Message != nil {
+			return json.Marshal(struct {
+				Type    string        `json:"type"`
+				Message streamMessage `json:"message"`
+			}{e.Type, newStreamMessage(*e.Message)})
+		}
+	case "message_delta":
+		if e.Usage != nil {
+			return json.Marshal(struct {
+				Type  string     `json:"type"`
+				Delta *Delta     `json:"delta,omitempty"`
+				Usage deltaUsage `json:"usage"`
+			}{e.Type, e.Delta, deltaUsage(*e.Usage)})
+		}
+	case "content_block_start":
+		var block any
+		if e.ContentBlock != nil {
+			block = streamContentBlock(*e.ContentBlock)
+		}
+		return json.Marshal(struct {
+			Type         string `json:"type"`
+			Index        int    `json:"index"`
+			ContentBlock any    `json:"content_block"`
+		}{e.Type, e.Index, block})
+	case "content_block_delta", "content_block_stop":
+		return json.Marshal(struct {
+			plain
+			Index int `json:"index"`
+		}{plain(e), e.Index})
+	}
+	return json.Marshal(plain(e))
+}
+
+// streamMessage is the message of a message_start event.
+type streamMessage struct {
+	ID           string         `json:"id"`
+	Type         string         `json:"type"`
+	Role         string         `json:"role"`
+	Content      []ContentBlock `json:"content"`
+	Model        string         `json:"model"`
+	StopReason   *string        `json:"stop_reason"`
+	StopSequence *string        `json:"stop_sequence"`
+	Usage        startUsage     `json:"usage"`
+}
+
+func newStreamMessage(m AnthropicResponse) streamMessage {
+	msg := streamMessage{
+		ID:           m.ID,
+		Type:         m.Type,
+		Role:         m.Role,
+		Content:      m.Content,
+		Model:        m.Model,
+		StopSequence: m.StopSequence,
+		Usage:        startUsage(m.Usage),
+	}
+	if msg.Type == "" {
+		msg.Type = "message"
+	}
+	if msg.Role == "" {
+		msg.Role = "assistant"
+	}
+	if msg.Content == nil {
+		msg.Content = []ContentBlock{}
+	}
+	if m.StopReason != "" {
+		msg.StopReason = &m.StopReason
+	}
+	return msg
+}
+
+// startUsage is the usage of a message_start event, with every count.
+type startUsage struct {
+	InputTokens              int    `json:"input_tokens"`
+	OutputTokens             int    `json:"output_tokens"`
+	CacheReadInputTokens     int    `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int    `json:"cache_creation_input_tokens"`
+	ServiceTier              string `json:"service_tier,omitempty"`
+}
+
+// deltaUsage is the usage of a message_delta event: the output tokens, and the input counts
+// of providers that only know them at the end.
+type deltaUsage struct {
+	InputTokens              int    `json:"input_tokens,omitempty"`
+	OutputTokens             int    `json:"output_tokens"`
+	CacheReadInputTokens     int    `json:"cache_read_input_tokens,omitempty"`
+	CacheCreationInputTokens int    `json:"cache_creation_input_tokens,omitempty"`
+	ServiceTier              string `json:"service_tier,omitempty"`
+}
+
+// streamContentBlock returns the content_block of a content_block_start event.
+func streamContentBlock(b ContentBlock) any {
+	switch b.Type {
+	case "text":
+		return struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{b.Type, b.Text}
+	case "thinking":
+		return struct {
+			Type     string `json:"type"`
+			Thinking string `json:"thinking"`
+		}{b.Type, b.Thinking}
+	case "tool_use":
+		input := b.Input
+		if input == nil {
+			input = map[string]interface{}{}
+		}
+		return struct {
+			Type             string                 `json:"type"`
+			ID               string                 `json:"id"`
+			Name             string                 `json:"name"`
+			Input            map[string]interface{} `json:"input"`
+			ThoughtSignature string                 `json:"thoughtSignature,omitempty"`
+		}{b.Type, b.ID, b.Name, input, b.ThoughtSignature}
+	}
+	return b
+}
+
+// MarshalJSON always emits the payload field of typed deltas, and stop_sequence (null when
+// unset) in message_delta deltas.
+func (d Delta) MarshalJSON() ([]byte, error) {
+	type payload struct {
+		Type string `json:"type"`
+	}
+	switch d.Type {
+	case "text_delta":
+		return json.Marshal(struct {
+			payload
+			Text string `json:"text"`
+		}{payload{d.Type}, d.Text})
+	case "thinking_delta":
+		return json.Marshal(struct {
+			payload
+			Thinking string `json:"thinking"`
+		}{payload{d.Type}, d.Thinking})
+	case "input_json_delta":
+		return json.Marshal(struct {
+			payload
+			PartialJSON string `json:"partial_json"`
+		}{payload{d.Type}, d.PartialJSON})
+	case "signature_delta":
+		return json.Marshal(struct {
+			payload
+			Signature string `json:"signature"`
+		}{payload{d.Type}, d.Signature})
+	case "":
+		if d.StopReason != "" {
+			var stopSequence *string
+			if d.StopSequence != "" {
+				stopSequence = &d.StopSequence
+			}
+			return json.Marshal(struct {
+				StopReason   string  `json:"stop_reason"`
+				StopSequence *string `json:"stop_sequence"`
+			}{d.StopReason, stopSequence})
+		}
+	}
+	type plain Delta
+	return json.Marshal(plain(d))
+}
@@ -0,0 +1,41 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStreamEvent_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		event StreamEvent
+		want  string
+	}{
+		{
+			name:  "message_start",
+			event: StreamEvent{Type: "message_start", Message: &AnthropicResponse{ID: "msg_1", Model: "m", Usage: Usage{InputTokens: 7, CacheReadInputTokens: 3}}},
+			want:  `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"m","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":7,"output_tokens":0,"cache_read_input_tokens":3,"cache_creation_input_tokens":0}}}`,
+		},
+		{
+			name:  "message_delta",
+			event: StreamEvent{Type: "message_delta", Delta: &Delta{StopReason: "end_turn"}, Usage: &Usage{OutputTokens: 5}},
+			want:  `{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":5}}`,
+		},
+		{
+			name:  "tool_use start",
+			event: StreamEvent{Type: "content_block_start", Index: 1, ContentBlock: &ContentBlock{Type: "tool_use", ID: "toolu_1", Name: "do"}},
+			want:  `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"do","input":{}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// serverToolTypePrefixes are the Anthropic server tools: the upstream runs them and
+// returns server_tool_use and *_tool_result blocks instead of asking the client to.
+var serverToolTypePrefixes = []string{"web_search_", "web_fetch_", "code_execution_"}
+
+// IsServerTool reports whether t is an Anthropic server tool such as web_search_20250305.
+func (t Tool) IsServerTool() bool {
+	for _, prefix := range serverToolTypePrefixes {
+		if strings.HasPrefix(t.Type, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWebSearch reports whether t is the web_search server tool.
+func (t Tool) IsWebSearch() bool {
+	return strings.HasPrefix(t.Type, "web_search_")
+}
+
+// isCustomTool reports whether t is a client tool defined by its input schema.
+func (t Tool) isCustomTool() bool {
+	return t.Type == "" || t.Type == "custom"
+}
+
+// UnmarshalJSON decodes a tool definition, keeping the original JSON of Anthropic-defined
+// tools in Raw.
+func (t *Tool) UnmarshalJSON(data []byte) error {
+	type plain Tool
+	if err := json.Unmarshal(data, (*plain)(t)); err != nil {
+		return err
+	}
+	t.Raw = nil
+	if !t.isCustomTool() {
+		t.Raw = append(json.RawMessage(nil), data...)
+	}
+	return nil
+}
+
+// MarshalJSON re-emits the original JSON of Anthropic-defined tools so they pass through unchanged.
+func (t Tool) MarshalJSON() ([]byte, error) {
+	if len(t.Raw) > 0 && !t.isCustomTool() {
+		return t.Raw, nil
+	}
+	type plain Tool
+	return json.Marshal(plain(t))
+}
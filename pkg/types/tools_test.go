@@ -0,0 +1,61 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTool_ServerToolRoundTrip(t *testing.T) {
+	input := `[{"type":"web_search_20250305","name":"web_search","max_uses":5,"allowed_domains":["go.dev"]},` +
+		`{"name":"read_file","description":"Read a file","input_schema":{"type":"object"}}]`
+
+	var tools []Tool
+	if err := json.Unmarshal([]byte(input), &tools); err != nil {
+		t.Fatal(err)
+	}
+	if !tools[0].IsServerTool() || !tools[0].IsWebSearch() {
+		t.Errorf("expected web_search server tool, got %+v", tools[0])
+	}
+	if tools[1].IsServerTool() || tools[1].Raw != nil {
+		t.Errorf("expected custom tool without raw JSON, got %+v", tools[1])
+	}
+
+	out, err := json.Marshal(tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got interface{}
+	json.Unmarshal([]byte(input), &want)
+	json.Unmarshal(out, &got)
+	if string(mustJSON(t, want)) != string(mustJSON(t, got)) {
+		t.Errorf("round trip changed tools:\nwant %s\ngot  %s", input, out)
+	}
+}
+
+func TestTool_IsServerTool(t *testing.T) {
+	tests := []struct {
+		toolType string
+		want     bool
+	}{
+		{"", false},
+		{"custom", false},
+		{"web_search_20250305", true},
+		{"web_fetch_20250910", true},
+		{"code_execution_20250825", true},
+		{"bash_20250124", false}, // Anthropic-defined, but run by the client
+	}
+	for _, tt := range tests {
+		if got := (Tool{Type: tt.toolType}).IsServerTool(); got != tt.want {
+			t.Errorf("IsServerTool(%q) = %v, want %v", tt.toolType, got, tt.want)
+		}
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -22,6 +22,16 @@ type AnthropicRequest struct {
 	TopP          *float64        `json:"top_p,omitempty"`
 	TopK          *int            `json:"top_k,omitempty"`
 	StopSequences []string        `json:"stop_sequences,omitempty"`
+	Metadata      *Metadata       `json:"metadata,omitempty"`
+	// ServiceTier ("auto", "standard_only" or "priority") picks the accounts that serve the
+	// request. Only the Anthropic provider sends it upstream, mapped to the values the
+	// Messages API accepts.
+	ServiceTier string `json:"-"`
+}
+
+// Metadata is the request metadata of the Messages API.
+type Metadata struct {
+	UserID string `json:"user_id,omitempty"` // Opaque end-user identifier
 }
 
 // Message represents a conversation message.
@@ -58,6 +68,10 @@ type ContentBlock struct {
 
 	// Image block fields
 	Source *ImageSource `json:"source,omitempty"`
+
+	// Raw holds the original JSON of non-core block types (search_result, mcp_tool_use, ...)
+	// so they can be passed through verbatim. See blocks.go.
+	Raw json.RawMessage `json:"-"`
 }
 
 // ImageSource represents the source of an image in a content block.
@@ -82,10 +96,15 @@ type CacheControl struct {
 
 // Tool represents a tool definition.
 type Tool struct {
+	Type        string                 `json:"type,omitempty"` // "" or "custom"; versioned for Anthropic-defined tools, e.g. "web_search_20250305"
 	Name        string                 `json:"name"`
 	Description string                 `json:"description,omitempty"`
 	InputSchema map[string]interface{} `json:"input_schema,omitempty"`
 	Function    *FunctionDefinition    `json:"function,omitempty"` // OpenAI-style function
+
+	// Raw holds the original JSON of Anthropic-defined tools so their settings (max_uses,
+	// allowed_domains, ...) pass through verbatim. See tools.go.
+	Raw json.RawMessage `json:"-"`
 }
 
 // FunctionDefinition represents an OpenAI-style function definition.
@@ -97,8 +116,14 @@ type FunctionDefinition struct {
 
 // ToolChoice specifies how the model should use tools.
 type ToolChoice struct {
-	Type string `json:"type"`           // "auto", "any", "tool", "none"
-	Name string `json:"name,omitempty"` // Required when type is "tool"
+	Type                   string `json:"type"`                                // "auto", "any", "tool", "none"
+	Name                   string `json:"name,omitempty"`                      // Required when type is "tool"
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"` // At most one tool_use block per response
+}
+
+// AllowsParallelToolUse reports whether a response may contain more than one tool_use block.
+func (tc *ToolChoice) AllowsParallelToolUse() bool {
+	return tc == nil || !tc.DisableParallelToolUse
 }
 
 // ThinkingConfig configures thinking/reasoning for supported models.
@@ -121,10 +146,11 @@ type AnthropicResponse struct {
 
 // Usage contains token usage information.
 type Usage struct {
-	InputTokens              int `json:"input_tokens"`
-	OutputTokens             int `json:"output_tokens"`
-	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
-	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	InputTokens              int    `json:"input_tokens"`
+	OutputTokens             int    `json:"output_tokens"`
+	CacheReadInputTokens     int    `json:"cache_read_input_tokens,omitempty"`
+	CacheCreationInputTokens int    `json:"cache_creation_input_tokens,omitempty"`
+	ServiceTier              string `json:"service_tier,omitempty"` // "standard" or "priority"
 }
 
 // AnthropicError represents an error response from the API.
@@ -143,9 +169,10 @@ type ErrorDetail struct {
 type StreamEvent struct {
 	Type string `json:"type"`
 
-	// Raw allows providers to bypass struct-based marshaling and emit
-	// Node-parity JSON shapes (including explicit nulls/zero values).
-	// It is not serialized directly.
+	// Raw is the escape hatch for events the typed fields below can't express, such as
+	// those of providers that forward their upstream's events (a decoded map, or a
+	// json.RawMessage on the stream fast path). When set, it is sent instead of the
+	// typed fields.
 	Raw any `json:"-"`
 
 	// message_start
@@ -204,12 +231,13 @@ type ModelInfo struct {
 
 // ImageGenerationRequest represents an image generation request.
 type ImageGenerationRequest struct {
-	Prompt      string `json:"prompt"`                 // Required: text prompt for image generation
-	Model       string `json:"model,omitempty"`        // Optional: defaults to gemini-3-pro-image
-	AspectRatio string `json:"aspect_ratio,omitempty"` // Optional: 1:1, 16:9, 9:16, 4:3, 3:4
-	Count       int    `json:"count,omitempty"`        // Optional: 1-4, default 1
-	InputImage  string `json:"input_image,omitempty"`  // Optional: base64 image for editing
-	SessionID   string `json:"session_id,omitempty"`   // Optional: for character consistency
+	Prompt              string `json:"prompt"`                           // Required: text prompt for image generation
+	Model               string `json:"model,omitempty"`                  // Optional: defaults to gemini-3-pro-image
+	AspectRatio         string `json:"aspect_ratio,omitempty"`           // Optional: 1:1, 16:9, 9:16, 4:3, 3:4
+	Count               int    `json:"count,omitempty"`                  // Optional: 1-4, default 1
+	InputImage          string `json:"input_image,omitempty"`            // Optional: base64 image for editing
+	InputImageMediaType string `json:"input_image_media_type,omitempty"` // Optional: media type of InputImage, default image/png
+	SessionID           string `json:"session_id,omitempty"`             // Optional: for character consistency
 }
 
 // ImageGenerationResponse represents an image generation response.
@@ -238,7 +266,7 @@ type ProviderStatus struct {
 // AccountStatus represents the status of an individual account.
 type AccountStatus struct {
 	Email    string                `json:"email"`
-	Status   string                `json:"status"` // "ok", "rate-limited", "invalid", "error"
+	Status   string                `json:"status"` // "ok", "rate-limited", "invalid", "disabled", "error"
 	Error    string                `json:"error,omitempty"`
 	LastUsed *time.Time            `json:"last_used,omitempty"`
 	Limits   map[string]ModelQuota `json:"limits"`